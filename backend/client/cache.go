@@ -0,0 +1,258 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CacheConfig configures a Cache
+type CacheConfig struct {
+	// PollInterval makes the cache poll for changes at this interval instead of
+	// long-polling the watch endpoint, e.g. behind proxies that cut long requests
+	PollInterval time.Duration
+	// PersistPath is a file where the cache keeps the last known
+	// configurations, so that a consumer restarted during an outage of the
+	// server can still start. Optional.
+	PersistPath string
+	// OnChange is called from the refresh goroutine when a cached
+	// configuration changes. Optional.
+	OnChange func(nodeID int64, resolved *ResolvedConfiguration)
+	// OnCacheMiss is called when Get has to fetch a configuration from the
+	// server because the cache is not refreshing it yet. Optional.
+	OnCacheMiss func(nodeID int64, opts ResolveOptions)
+}
+
+// CachedConfiguration is a resolved configuration served by a Cache
+type CachedConfiguration struct {
+	*ResolvedConfiguration
+	FetchedAt time.Time // When the configuration was last confirmed current
+	Stale     bool      // The server could not be reached since FetchedAt
+}
+
+// Cache keeps the resolved configurations a consumer reads in memory and
+// refreshes them in the background. When the server is unreachable it keeps
+// serving the last known configurations, so consumers survive an outage.
+type Cache struct {
+	client *Client
+	cfg    CacheConfig
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	mu      sync.Mutex
+	entries map[string]*cacheEntry
+}
+
+// cacheEntry is one cached configuration, also the format of the persist file
+type cacheEntry struct {
+	NodeID    int64                  `json:"node_id"`
+	Options   ResolveOptions         `json:"options"`
+	Resolved  *ResolvedConfiguration `json:"resolved"`
+	Hash      string                 `json:"hash"`
+	FetchedAt time.Time              `json:"fetched_at"`
+
+	stale    bool
+	watching bool
+}
+
+// NewCache creates a cache reading through c. Configurations persisted by a
+// previous process are loaded, and served as stale until they are refreshed.
+func (c *Client) NewCache(cfg CacheConfig) (*Cache, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cache := &Cache{client: c, cfg: cfg, ctx: ctx, cancel: cancel, entries: make(map[string]*cacheEntry)}
+
+	if cfg.PersistPath != "" {
+		data, err := os.ReadFile(cfg.PersistPath)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			cancel()
+			return nil, err
+		}
+		if err == nil {
+			var persisted []*cacheEntry
+			if err := json.Unmarshal(data, &persisted); err != nil {
+				cancel()
+				return nil, fmt.Errorf("invalid cache file %s: %w", cfg.PersistPath, err)
+			}
+			for _, e := range persisted {
+				e.stale = true
+				cache.entries[cacheKey(e.NodeID, e.Options)] = e
+			}
+		}
+	}
+	return cache, nil
+}
+
+// cacheKey identifies a node resolved with some options
+func cacheKey(nodeID int64, opts ResolveOptions) string {
+	attrs := make([]string, 0, len(opts.Context))
+	for attr, value := range opts.Context {
+		attrs = append(attrs, attr+"="+value)
+	}
+	sort.Strings(attrs)
+	return fmt.Sprintf("%d|%s|%s", nodeID, opts.Environment, strings.Join(attrs, "&"))
+}
+
+// Get returns the resolved configuration of a node. The first read of a node
+// fetches it from the server and starts refreshing it in the background; later
+// reads are served from memory. A configuration the cache has never fetched,
+// and did not persist, is an error while the server is unreachable.
+func (ca *Cache) Get(ctx context.Context, nodeID int64, opts ResolveOptions) (*CachedConfiguration, error) {
+	if opts.AsOf != nil {
+		return nil, errors.New("historical configurations are not cached")
+	}
+	key := cacheKey(nodeID, opts)
+
+	ca.mu.Lock()
+	e := ca.entries[key]
+	if e != nil && e.watching {
+		defer ca.mu.Unlock()
+		return e.cached(), nil
+	}
+	ca.mu.Unlock()
+
+	if ca.cfg.OnCacheMiss != nil {
+		ca.cfg.OnCacheMiss(nodeID, opts)
+	}
+	resolved, hash, err := ca.client.Watch(ctx, nodeID, opts, "", 0)
+
+	ca.mu.Lock()
+	defer ca.mu.Unlock()
+	// Another reader may have started the refresh meanwhile
+	if current := ca.entries[key]; current != nil && current.watching {
+		return current.cached(), nil
+	}
+	if err != nil {
+		if e == nil {
+			return nil, err
+		}
+		log.Printf("config cache: serving persisted configuration of node %d: %v", nodeID, err)
+	} else {
+		if e == nil {
+			e = &cacheEntry{NodeID: nodeID, Options: opts}
+			ca.entries[key] = e
+		}
+		e.Resolved, e.Hash, e.FetchedAt, e.stale = resolved, hash, time.Now(), false
+		ca.persistLocked()
+	}
+
+	e.watching = true
+	ca.wg.Add(1)
+	go ca.refresh(key, nodeID, opts)
+	return e.cached(), nil
+}
+
+func (e *cacheEntry) cached() *CachedConfiguration {
+	return &CachedConfiguration{ResolvedConfiguration: e.Resolved, FetchedAt: e.FetchedAt, Stale: e.stale}
+}
+
+// refresh keeps one cached configuration current until the cache is closed
+func (ca *Cache) refresh(key string, nodeID int64, opts ResolveOptions) {
+	defer ca.wg.Done()
+
+	backoff := minBackoff
+	for {
+		ca.mu.Lock()
+		hash := ca.entries[key].Hash
+		ca.mu.Unlock()
+
+		var resolved *ResolvedConfiguration
+		var newHash string
+		var err error
+		if ca.cfg.PollInterval > 0 {
+			if !ca.sleep(ca.cfg.PollInterval) {
+				return
+			}
+			resolved, newHash, err = ca.client.Watch(ca.ctx, nodeID, opts, "", 0)
+		} else {
+			resolved, newHash, err = ca.client.Watch(ca.ctx, nodeID, opts, hash, 0)
+		}
+		if ca.ctx.Err() != nil {
+			return
+		}
+
+		if err != nil {
+			ca.mu.Lock()
+			if e := ca.entries[key]; !e.stale {
+				e.stale = true
+				log.Printf("config cache: refreshing node %d failed, serving the last known configuration: %v", nodeID, err)
+			}
+			ca.mu.Unlock()
+			if !ca.sleep(backoff) {
+				return
+			}
+			backoff = min(backoff*2, maxBackoff)
+			continue
+		}
+		backoff = minBackoff
+
+		changed := resolved != nil && newHash != hash
+		ca.mu.Lock()
+		e := ca.entries[key]
+		e.FetchedAt, e.stale = time.Now(), false
+		if changed {
+			e.Resolved, e.Hash = resolved, newHash
+			ca.persistLocked()
+		}
+		ca.mu.Unlock()
+
+		if changed && ca.cfg.OnChange != nil {
+			ca.cfg.OnChange(nodeID, resolved)
+		}
+	}
+}
+
+// sleep waits for d and reports false if the cache was closed meanwhile
+func (ca *Cache) sleep(d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ca.ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+// persistLocked writes the cached configurations to the persist file. Failures
+// are logged, as the cache keeps working from memory.
+func (ca *Cache) persistLocked() {
+	if ca.cfg.PersistPath == "" {
+		return
+	}
+
+	entries := make([]*cacheEntry, 0, len(ca.entries))
+	for _, e := range ca.entries {
+		if e.Resolved != nil {
+			entries = append(entries, e)
+		}
+	}
+	data, err := json.Marshal(entries)
+	if err == nil {
+		// Write to a temporary file first so a crash never leaves a torn file
+		tmp := ca.cfg.PersistPath + ".tmp"
+		if err = os.MkdirAll(filepath.Dir(tmp), 0o700); err == nil {
+			if err = os.WriteFile(tmp, data, 0o600); err == nil {
+				err = os.Rename(tmp, ca.cfg.PersistPath)
+			}
+		}
+	}
+	if err != nil {
+		log.Printf("config cache: failed to persist configurations: %v", err)
+	}
+}
+
+// Close stops refreshing and waits for the refresh goroutines to return
+func (ca *Cache) Close() {
+	ca.cancel()
+	ca.wg.Wait()
+}
@@ -0,0 +1,273 @@
+// Package client is a Go client for the configuration REST API. Its types are
+// the server's own models, so they cannot drift from what the API returns.
+//
+//	c, err := client.New(client.Config{BaseURL: "https://config.example.com", APIKey: key})
+//	resolved, err := c.Resolve(ctx, 12, client.ResolveOptions{Environment: "prod"})
+package client
+
+import (
+	"bytes"
+	"config-manager/internal/events"
+	"config-manager/internal/models"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Types returned by the API
+type (
+	Node                  = models.ConfigNode
+	NodeWithChildren      = models.ConfigNodeWithChildren
+	Property              = models.ConfigProperty
+	ResolvedConfiguration = models.ResolvedConfiguration
+	Change                = models.Change
+	ChangeFeed            = models.ChangeFeed
+	Event                 = events.Event
+)
+
+const defaultRequestTimeout = 30 * time.Second
+
+// Config configures a Client
+type Config struct {
+	BaseURL        string        // Server URL, e.g. https://config.example.com
+	APIKey         string        // Sent as X-API-Key when set
+	HTTPClient     *http.Client  // Default http.DefaultClient
+	RequestTimeout time.Duration // Bounds each request except watches, default 30s
+
+	// OnRequest is called with each request before it is sent, e.g. to add
+	// tracing headers, and the function it returns, if not nil, with the
+	// outcome once the response headers or an error are in. Use it for
+	// metrics, logging and tracing around every call. Optional.
+	OnRequest func(req *http.Request) func(RequestOutcome)
+}
+
+// RequestOutcome describes how a request ended
+type RequestOutcome struct {
+	StatusCode int // 0 when no response was received
+	Duration   time.Duration
+	Err        error // Error responses of the API are *Error
+}
+
+// Client calls the configuration API. It is safe for concurrent use.
+type Client struct {
+	baseURL        string
+	apiKey         string
+	http           *http.Client
+	requestTimeout time.Duration
+	onRequest      func(req *http.Request) func(RequestOutcome)
+}
+
+// New creates a client for the server at cfg.BaseURL
+func New(cfg Config) (*Client, error) {
+	u, err := url.Parse(cfg.BaseURL)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return nil, fmt.Errorf("invalid base URL %q", cfg.BaseURL)
+	}
+
+	c := &Client{
+		baseURL:        strings.TrimSuffix(cfg.BaseURL, "/"),
+		apiKey:         cfg.APIKey,
+		http:           cfg.HTTPClient,
+		requestTimeout: cfg.RequestTimeout,
+		onRequest:      cfg.OnRequest,
+	}
+	if c.http == nil {
+		c.http = http.DefaultClient
+	}
+	if c.requestTimeout <= 0 {
+		c.requestTimeout = defaultRequestTimeout
+	}
+	return c, nil
+}
+
+// Error is an error response of the API
+type Error struct {
+	StatusCode int
+	// Code is the stable error code of the problem, e.g. NODE_NOT_FOUND. It
+	// is empty for responses of older servers.
+	Code    string
+	Message string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%d %s: %s", e.StatusCode, http.StatusText(e.StatusCode), e.Message)
+}
+
+// IsNotFound reports whether err is a 404 response of the API
+func IsNotFound(err error) bool {
+	var apiErr *Error
+	return errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound
+}
+
+// send performs a request and returns the response unless it is an error
+// response. The caller closes the body.
+func (c *Client) send(ctx context.Context, method, path string, body interface{}) (*http.Response, error) {
+	var reader io.Reader
+	if body != nil {
+		payload, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reader = bytes.NewReader(payload)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, reader)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.apiKey != "" {
+		req.Header.Set("X-API-Key", c.apiKey)
+	}
+
+	var done func(RequestOutcome)
+	if c.onRequest != nil {
+		done = c.onRequest(req)
+	}
+	start := time.Now()
+	resp, err := c.roundTrip(req)
+	if done != nil {
+		outcome := RequestOutcome{Duration: time.Since(start), Err: err}
+		var apiErr *Error
+		if resp != nil {
+			outcome.StatusCode = resp.StatusCode
+		} else if errors.As(err, &apiErr) {
+			outcome.StatusCode = apiErr.StatusCode
+		}
+		done(outcome)
+	}
+	return resp, err
+}
+
+// roundTrip sends a request and turns error responses into *Error
+func (c *Client) roundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 400 {
+		defer resp.Body.Close()
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		// Problem details carry the message as detail, older servers as error
+		var errorBody struct {
+			Code   string `json:"code"`
+			Detail string `json:"detail"`
+			Error  string `json:"error"`
+		}
+		if json.Unmarshal(msg, &errorBody) == nil && errorBody.Detail != "" {
+			return nil, &Error{StatusCode: resp.StatusCode, Code: errorBody.Code, Message: errorBody.Detail}
+		}
+		if errorBody.Error != "" {
+			return nil, &Error{StatusCode: resp.StatusCode, Message: errorBody.Error}
+		}
+		return nil, &Error{StatusCode: resp.StatusCode, Message: strings.TrimSpace(string(msg))}
+	}
+	return resp, nil
+}
+
+// do performs a request within the request timeout and decodes the response into out
+func (c *Client) do(ctx context.Context, method, path string, body, out interface{}) error {
+	ctx, cancel := context.WithTimeout(ctx, c.requestTimeout)
+	defer cancel()
+
+	resp, err := c.send(ctx, method, path, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if out == nil {
+		_, err = io.Copy(io.Discard, resp.Body)
+		return err
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// GetRootNodes returns the root nodes the caller may read
+func (c *Client) GetRootNodes(ctx context.Context) ([]Node, error) {
+	var nodes []Node
+	err := c.do(ctx, http.MethodGet, "/api/nodes", nil, &nodes)
+	return nodes, err
+}
+
+// GetNode returns a node
+func (c *Client) GetNode(ctx context.Context, nodeID int64) (*Node, error) {
+	var node Node
+	if err := c.do(ctx, http.MethodGet, fmt.Sprintf("/api/nodes/%d", nodeID), nil, &node); err != nil {
+		return nil, err
+	}
+	return &node, nil
+}
+
+// GetNodeWithChildren returns a node and its direct children
+func (c *Client) GetNodeWithChildren(ctx context.Context, nodeID int64) (*NodeWithChildren, error) {
+	var node NodeWithChildren
+	if err := c.do(ctx, http.MethodGet, fmt.Sprintf("/api/nodes/%d/children", nodeID), nil, &node); err != nil {
+		return nil, err
+	}
+	return &node, nil
+}
+
+// GetProperties returns the properties set on a node itself
+func (c *Client) GetProperties(ctx context.Context, nodeID int64) ([]Property, error) {
+	var properties []Property
+	err := c.do(ctx, http.MethodGet, fmt.Sprintf("/api/nodes/%d/properties", nodeID), nil, &properties)
+	return properties, err
+}
+
+// ResolveOptions selects the configuration to resolve
+type ResolveOptions struct {
+	Environment string            `json:"environment,omitempty"`
+	Context     map[string]string `json:"context,omitempty"` // Attributes feature flags are evaluated against
+	AsOf        *time.Time        `json:"as_of,omitempty"`   // Resolve the configuration as it was at this time
+}
+
+func (o ResolveOptions) query() url.Values {
+	query := url.Values{}
+	if o.Environment != "" {
+		query.Set("env", o.Environment)
+	}
+	for attr, value := range o.Context {
+		query.Set("context["+attr+"]", value)
+	}
+	if o.AsOf != nil {
+		query.Set("asOf", o.AsOf.Format(time.RFC3339))
+	}
+	return query
+}
+
+// Resolve returns the effective configuration of a node after inheritance
+func (c *Client) Resolve(ctx context.Context, nodeID int64, opts ResolveOptions) (*ResolvedConfiguration, error) {
+	var resolved ResolvedConfiguration
+	path := fmt.Sprintf("/api/nodes/%d/resolve?%s", nodeID, opts.query().Encode())
+	if err := c.do(ctx, http.MethodGet, path, nil, &resolved); err != nil {
+		return nil, err
+	}
+	return &resolved, nil
+}
+
+// GetChanges returns the page of the change feed after the since cursor, at
+// most limit changes or the server default when limit is zero. Reading the
+// feed needs admin access.
+func (c *Client) GetChanges(ctx context.Context, since int64, limit int) (*ChangeFeed, error) {
+	query := url.Values{}
+	query.Set("since", strconv.FormatInt(since, 10))
+	if limit > 0 {
+		query.Set("limit", strconv.Itoa(limit))
+	}
+
+	var feed ChangeFeed
+	if err := c.do(ctx, http.MethodGet, "/api/changes?"+query.Encode(), nil, &feed); err != nil {
+		return nil, err
+	}
+	return &feed, nil
+}
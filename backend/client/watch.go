@@ -0,0 +1,98 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	// watchMargin is how much longer than the server's watch timeout a watch
+	// request may take before it is abandoned
+	watchMargin = 15 * time.Second
+	minBackoff  = time.Second
+	maxBackoff  = 30 * time.Second
+)
+
+// Watch long-polls the resolved configuration of a node. It returns as soon as
+// the configuration's hash differs from hash, with the configuration and its
+// new hash. If timeout (at most 5 minutes, server default when zero) passes
+// without a change, it returns a nil configuration. An empty hash returns the
+// current configuration immediately. AsOf is not supported.
+func (c *Client) Watch(ctx context.Context, nodeID int64, opts ResolveOptions, hash string, timeout time.Duration) (*ResolvedConfiguration, string, error) {
+	if opts.AsOf != nil {
+		return nil, "", errors.New("cannot watch a historical configuration")
+	}
+	query := opts.query()
+	if hash != "" {
+		query.Set("hash", hash)
+	}
+	wait := 30 * time.Second
+	if timeout > 0 {
+		query.Set("timeout", strconv.Itoa(int(timeout/time.Second)))
+		wait = timeout
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, wait+watchMargin)
+	defer cancel()
+
+	resp, err := c.send(ctx, http.MethodGet, fmt.Sprintf("/api/nodes/%d/resolve/watch?%s", nodeID, query.Encode()), nil)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified {
+		return nil, hash, nil
+	}
+	var resolved ResolvedConfiguration
+	if err := json.NewDecoder(resp.Body).Decode(&resolved); err != nil {
+		return nil, "", err
+	}
+	newHash, err := strconv.Unquote(resp.Header.Get("ETag"))
+	if err != nil {
+		return nil, "", fmt.Errorf("invalid ETag %q", resp.Header.Get("ETag"))
+	}
+	return &resolved, newHash, nil
+}
+
+// WatchChanges calls fn with the current resolved configuration of a node, and
+// again each time it changes, until ctx is done or fn returns an error.
+// Network and server errors are retried with backoff; client errors, such as
+// an unknown node or missing permission, are returned.
+func (c *Client) WatchChanges(ctx context.Context, nodeID int64, opts ResolveOptions, fn func(*ResolvedConfiguration) error) error {
+	var hash string
+	backoff := minBackoff
+	for {
+		resolved, newHash, err := c.Watch(ctx, nodeID, opts, hash, 0)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		var apiErr *Error
+		switch {
+		case errors.As(err, &apiErr) && apiErr.StatusCode < 500:
+			return err
+		case err != nil:
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(backoff):
+			}
+			backoff = min(backoff*2, maxBackoff)
+			continue
+		}
+
+		backoff = minBackoff
+		hash = newHash
+		if resolved != nil {
+			if err := fn(resolved); err != nil {
+				return err
+			}
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+type client struct {
+	server string
+	apiKey string
+	http   *http.Client
+}
+
+func newClient(server, apiKey string) *client {
+	return &client{
+		server: strings.TrimSuffix(server, "/"),
+		apiKey: apiKey,
+		http:   &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// apiError is an error response of the API
+type apiError struct {
+	Status  int
+	Message string
+}
+
+func (e *apiError) Error() string {
+	return fmt.Sprintf("%d %s: %s", e.Status, http.StatusText(e.Status), e.Message)
+}
+
+func (c *client) do(method, path string, body, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		payload, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(payload)
+	}
+
+	req, err := http.NewRequest(method, c.server+path, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if c.apiKey != "" {
+		req.Header.Set("X-API-Key", c.apiKey)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		// Problem details carry the message as detail, older servers as error
+		var errorBody struct {
+			Detail string `json:"detail"`
+			Error  string `json:"error"`
+		}
+		if json.Unmarshal(msg, &errorBody) == nil && errorBody.Detail != "" {
+			return &apiError{Status: resp.StatusCode, Message: errorBody.Detail}
+		}
+		if errorBody.Error != "" {
+			return &apiError{Status: resp.StatusCode, Message: errorBody.Error}
+		}
+		return &apiError{Status: resp.StatusCode, Message: strings.TrimSpace(string(msg))}
+	}
+	if out == nil {
+		_, err = io.Copy(io.Discard, resp.Body)
+		return err
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
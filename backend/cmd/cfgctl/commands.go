@@ -0,0 +1,376 @@
+package main
+
+import (
+	"config-manager/internal/models"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type cli struct {
+	client *client
+	out    *printer
+}
+
+// parseArgs parses the flags of a command and checks its number of arguments
+func parseArgs(flags *flag.FlagSet, args []string, want int, usage string) ([]string, error) {
+	if err := flags.Parse(args); err != nil {
+		return nil, err
+	}
+	if flags.NArg() != want {
+		return nil, fmt.Errorf("usage: cfgctl %s", usage)
+	}
+	return flags.Args(), nil
+}
+
+func parseNodeID(arg string) (int64, error) {
+	id, err := strconv.ParseInt(arg, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid node ID %q", arg)
+	}
+	return id, nil
+}
+
+func (c *cli) get(args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: cfgctl get nodes | node <nodeId> | properties <nodeId>")
+	}
+
+	switch args[0] {
+	case "nodes":
+		var nodes []models.ConfigNode
+		if err := c.client.do(http.MethodGet, "/api/nodes", nil, &nodes); err != nil {
+			return err
+		}
+		return c.out.print(nodes, func(w io.Writer) { nodeTable(w, nodes) })
+
+	case "node":
+		if len(args) != 2 {
+			return errors.New("usage: cfgctl get node <nodeId>")
+		}
+		nodeID, err := parseNodeID(args[1])
+		if err != nil {
+			return err
+		}
+		var node models.ConfigNodeWithChildren
+		if err := c.client.do(http.MethodGet, fmt.Sprintf("/api/nodes/%d/children", nodeID), nil, &node); err != nil {
+			return err
+		}
+		return c.out.print(node, func(w io.Writer) {
+			nodeTable(w, append([]models.ConfigNode{node.ConfigNode}, node.Children...))
+		})
+
+	case "properties":
+		if len(args) != 2 {
+			return errors.New("usage: cfgctl get properties <nodeId>")
+		}
+		nodeID, err := parseNodeID(args[1])
+		if err != nil {
+			return err
+		}
+		properties, err := c.properties(nodeID)
+		if err != nil {
+			return err
+		}
+		return c.out.print(properties, func(w io.Writer) {
+			row(w, "ID", "KEY", "ENVIRONMENT", "TYPE", "VALUE")
+			for _, p := range properties {
+				value := p.Value
+				if p.Encrypted {
+					value = "<encrypted>"
+				}
+				row(w, p.ID, p.Key, orDash(p.Environment), p.DataType, value)
+			}
+		})
+
+	default:
+		return fmt.Errorf("unknown resource %q, expected nodes, node or properties", args[0])
+	}
+}
+
+func nodeTable(w io.Writer, nodes []models.ConfigNode) {
+	row(w, "ID", "NAME", "TYPE", "PARENT")
+	for _, node := range nodes {
+		parent := "-"
+		if node.ParentID != nil {
+			parent = strconv.FormatInt(*node.ParentID, 10)
+		}
+		row(w, node.ID, node.Name, node.NodeType, parent)
+	}
+}
+
+func (c *cli) properties(nodeID int64) ([]models.ConfigProperty, error) {
+	var properties []models.ConfigProperty
+	err := c.client.do(http.MethodGet, fmt.Sprintf("/api/nodes/%d/properties", nodeID), nil, &properties)
+	return properties, err
+}
+
+// findProperty returns the property of a node with the given key and
+// environment, or nil
+func findProperty(properties []models.ConfigProperty, key string, environment *string) *models.ConfigProperty {
+	for i, p := range properties {
+		if p.Key == key && orDash(p.Environment) == orDash(environment) {
+			return &properties[i]
+		}
+	}
+	return nil
+}
+
+// parseValue turns a command line value into a serialized property value.
+// Without a data type, JSON literals keep their type and anything else is a
+// string.
+func parseValue(raw string, dataType models.DataType) (string, models.DataType, error) {
+	if dataType == models.DataTypeString {
+		return strconv.Quote(raw), dataType, nil
+	}
+
+	var value interface{}
+	if err := json.Unmarshal([]byte(raw), &value); err != nil {
+		if dataType != "" {
+			return "", "", fmt.Errorf("value is not valid JSON for type %s", dataType)
+		}
+		return strconv.Quote(raw), models.DataTypeString, nil
+	}
+	if dataType != "" {
+		return raw, dataType, nil
+	}
+
+	switch value.(type) {
+	case string:
+		dataType = models.DataTypeString
+	case float64:
+		dataType = models.DataTypeNumber
+	case bool:
+		dataType = models.DataTypeBoolean
+	case map[string]interface{}:
+		dataType = models.DataTypeObject
+	case []interface{}:
+		dataType = models.DataTypeArray
+	default:
+		dataType = models.DataTypeNull
+	}
+	return raw, dataType, nil
+}
+
+func (c *cli) set(args []string) error {
+	flags := flag.NewFlagSet("set", flag.ExitOnError)
+	environment := flags.String("env", "", "environment the value applies to (default all)")
+	dataType := flags.String("type", "", "data type (default inferred from the value)")
+	args, err := parseArgs(flags, args, 3, "set [-env e] [-type t] <nodeId> <key> <value>")
+	if err != nil {
+		return err
+	}
+	nodeID, err := parseNodeID(args[0])
+	if err != nil {
+		return err
+	}
+	key := args[1]
+	var env *string
+	if *environment != "" {
+		env = environment
+	}
+
+	value, valueType, err := parseValue(args[2], models.DataType(*dataType))
+	if err != nil {
+		return err
+	}
+
+	properties, err := c.properties(nodeID)
+	if err != nil {
+		return err
+	}
+
+	var result models.PropertyWriteResponse
+	if existing := findProperty(properties, key, env); existing != nil {
+		err = c.client.do(http.MethodPut, fmt.Sprintf("/api/properties/%d", existing.ID), models.UpdatePropertyRequest{
+			Value:    &value,
+			DataType: &valueType,
+		}, &result)
+	} else {
+		err = c.client.do(http.MethodPost, fmt.Sprintf("/api/nodes/%d/properties", nodeID), models.CreatePropertyRequest{
+			Key:         key,
+			Value:       value,
+			DataType:    valueType,
+			Environment: env,
+		}, &result)
+	}
+	if err != nil {
+		return err
+	}
+
+	return c.out.print(result, func(w io.Writer) {
+		row(w, "ID", "KEY", "ENVIRONMENT", "TYPE", "VALUE")
+		row(w, result.ID, result.Key, orDash(result.Environment), result.DataType, result.Value)
+		for _, warning := range result.Warnings {
+			row(w, "warning:", warning.Message)
+		}
+	})
+}
+
+func (c *cli) resolve(args []string) error {
+	flags := flag.NewFlagSet("resolve", flag.ExitOnError)
+	environment := flags.String("env", "", "environment to resolve for")
+	var contextAttrs []string
+	flags.Func("context", "context attribute as key=value, repeatable", func(attr string) error {
+		if !strings.Contains(attr, "=") {
+			return errors.New("expected key=value")
+		}
+		contextAttrs = append(contextAttrs, attr)
+		return nil
+	})
+	args, err := parseArgs(flags, args, 1, "resolve [-env e] [-context k=v] <nodeId>")
+	if err != nil {
+		return err
+	}
+	nodeID, err := parseNodeID(args[0])
+	if err != nil {
+		return err
+	}
+
+	query := url.Values{}
+	if *environment != "" {
+		query.Set("env", *environment)
+	}
+	for _, attr := range contextAttrs {
+		key, value, _ := strings.Cut(attr, "=")
+		query.Set("context["+key+"]", value)
+	}
+
+	var resolved models.ResolvedConfiguration
+	if err := c.client.do(http.MethodGet, fmt.Sprintf("/api/nodes/%d/resolve?%s", nodeID, query.Encode()), nil, &resolved); err != nil {
+		return err
+	}
+
+	return c.out.print(resolved, func(w io.Writer) {
+		keys := make([]string, 0, len(resolved.Properties))
+		for key := range resolved.Properties {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		row(w, "KEY", "VALUE")
+		for _, key := range keys {
+			row(w, key, compact(resolved.Properties[key]))
+		}
+	})
+}
+
+func (c *cli) diff(args []string) error {
+	flags := flag.NewFlagSet("diff", flag.ExitOnError)
+	environment := flags.String("env", "", "environment to compare")
+	args, err := parseArgs(flags, args, 2, "diff [-env e] <leftNodeId> <rightNodeId>")
+	if err != nil {
+		return err
+	}
+	left, err := parseNodeID(args[0])
+	if err != nil {
+		return err
+	}
+	right, err := parseNodeID(args[1])
+	if err != nil {
+		return err
+	}
+
+	query := url.Values{}
+	query.Set("left", strconv.FormatInt(left, 10))
+	query.Set("right", strconv.FormatInt(right, 10))
+	if *environment != "" {
+		query.Set("env", *environment)
+	}
+
+	var diff models.ConfigurationDiff
+	if err := c.client.do(http.MethodGet, "/api/diff?"+query.Encode(), nil, &diff); err != nil {
+		return err
+	}
+
+	return c.out.print(diff, func(w io.Writer) {
+		row(w, "", "KEY", diff.Left.Name, diff.Right.Name)
+		for _, d := range diff.Removed {
+			row(w, "-", d.Key, compact(d.Left), "")
+		}
+		for _, d := range diff.Added {
+			row(w, "+", d.Key, "", compact(d.Right))
+		}
+		for _, d := range diff.Changed {
+			row(w, "~", d.Key, compact(d.Left), compact(d.Right))
+		}
+		row(w, "", fmt.Sprintf("(%d unchanged)", diff.Unchanged), "", "")
+	})
+}
+
+func runProfile(config *config, args []string, out *printer) error {
+	if len(args) == 0 {
+		return errors.New("usage: cfgctl profile list | set [-server url] [-api-key key] <name> | use <name>")
+	}
+
+	switch args[0] {
+	case "list":
+		names := config.profileNames()
+		type listedProfile struct {
+			Name    string `json:"name"`
+			Server  string `json:"server"`
+			Current bool   `json:"current"`
+		}
+		listed := []listedProfile{}
+		for _, name := range names {
+			listed = append(listed, listedProfile{name, config.Profiles[name].Server, name == config.CurrentProfile})
+		}
+		return out.print(listed, func(w io.Writer) {
+			row(w, "CURRENT", "NAME", "SERVER")
+			for _, p := range listed {
+				current := ""
+				if p.Current {
+					current = "*"
+				}
+				row(w, current, p.Name, p.Server)
+			}
+		})
+
+	case "set":
+		flags := flag.NewFlagSet("profile set", flag.ExitOnError)
+		server := flags.String("server", "", "server URL")
+		apiKey := flags.String("api-key", "", "API key")
+		args, err := parseArgs(flags, args[1:], 1, "profile set [-server url] [-api-key key] <name>")
+		if err != nil {
+			return err
+		}
+		p, ok := config.Profiles[args[0]]
+		if !ok {
+			p = &profile{}
+			config.Profiles[args[0]] = p
+		}
+		if *server != "" {
+			p.Server = *server
+		}
+		if *apiKey != "" {
+			p.APIKey = *apiKey
+		}
+		if p.Server == "" {
+			return errors.New("a new profile needs -server")
+		}
+		if config.CurrentProfile == "" {
+			config.CurrentProfile = args[0]
+		}
+		return config.save()
+
+	case "use":
+		if len(args) != 2 {
+			return errors.New("usage: cfgctl profile use <name>")
+		}
+		if _, ok := config.Profiles[args[1]]; !ok {
+			return fmt.Errorf("unknown profile %q", args[1])
+		}
+		config.CurrentProfile = args[1]
+		return config.save()
+
+	default:
+		return fmt.Errorf("unknown profile command %q", args[0])
+	}
+}
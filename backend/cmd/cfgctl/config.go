@@ -0,0 +1,98 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"gopkg.in/yaml.v3"
+)
+
+// profile holds the settings for one server
+type profile struct {
+	Server string `yaml:"server"`
+	APIKey string `yaml:"api-key,omitempty"`
+}
+
+// config is the cfgctl configuration file
+type config struct {
+	CurrentProfile string              `yaml:"current-profile,omitempty"`
+	Profiles       map[string]*profile `yaml:"profiles"`
+
+	path string
+}
+
+func configPath() (string, error) {
+	if path := os.Getenv("CFGCTL_CONFIG"); path != "" {
+		return path, nil
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "cfgctl", "config.yaml"), nil
+}
+
+// loadConfig reads the configuration file; a missing file is an empty configuration
+func loadConfig() (*config, error) {
+	path, err := configPath()
+	if err != nil {
+		return nil, err
+	}
+
+	c := &config{Profiles: map[string]*profile{}, path: path}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := yaml.Unmarshal(data, c); err != nil {
+		return nil, fmt.Errorf("invalid configuration file %s: %w", path, err)
+	}
+	if c.Profiles == nil {
+		c.Profiles = map[string]*profile{}
+	}
+	return c, nil
+}
+
+func (c *config) save() error {
+	data, err := yaml.Marshal(c)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(c.path), 0o700); err != nil {
+		return err
+	}
+	// Profiles may hold API keys
+	return os.WriteFile(c.path, data, 0o600)
+}
+
+// profile returns a copy of the named profile, or of the current one when
+// name is empty. Without any profile, an empty one is returned so that
+// -server alone is enough.
+func (c *config) profile(name string) (profile, error) {
+	if name == "" {
+		name = c.CurrentProfile
+	}
+	if name == "" {
+		return profile{}, nil
+	}
+	p, ok := c.Profiles[name]
+	if !ok {
+		return profile{}, fmt.Errorf("unknown profile %q", name)
+	}
+	return *p, nil
+}
+
+func (c *config) profileNames() []string {
+	names := make([]string, 0, len(c.Profiles))
+	for name := range c.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,120 @@
+// Command cfgctl manages configuration through the REST API.
+//
+// Usage:
+//
+//	cfgctl [-profile name] [-server url] [-api-key key] [-o table|json] <command> [flags] [args]
+//
+// Commands:
+//
+//	get nodes                            list root nodes
+//	get node <nodeId>                    show a node and its children
+//	get properties <nodeId>              list the properties of a node
+//	set [-env e] [-type t] <nodeId> <key> <value>
+//	                                     create or update a property
+//	resolve [-env e] [-context k=v] <nodeId>
+//	                                     show the resolved configuration of a node
+//	diff [-env e] <leftNodeId> <rightNodeId>
+//	                                     compare the resolved configurations of two nodes
+//	export [-f file] <nodeId>            write a subtree as a YAML document
+//	import [-f file] [-parent nodeId]    create or update a subtree from a document
+//	drift [-f file] [-parent nodeId]     list differences between a document and the tree
+//	profile list                         list configured profiles
+//	profile set [-server url] [-api-key key] <name>
+//	                                     add or change a profile
+//	profile use <name>                   make a profile the default
+//
+// Profiles are kept in $CFGCTL_CONFIG, by default cfgctl/config.yaml in the
+// user configuration directory. The profile is chosen with -profile, then
+// $CFGCTL_PROFILE, then the current profile of the file; -server and -api-key
+// ($CFGCTL_SERVER, $CFGCTL_API_KEY) override its settings.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+)
+
+func main() {
+	global := flag.NewFlagSet("cfgctl", flag.ExitOnError)
+	profileName := global.String("profile", os.Getenv("CFGCTL_PROFILE"), "profile to use")
+	server := global.String("server", os.Getenv("CFGCTL_SERVER"), "server URL, overrides the profile")
+	apiKey := global.String("api-key", os.Getenv("CFGCTL_API_KEY"), "API key, overrides the profile")
+	output := global.String("o", "table", "output format: table or json")
+	global.Usage = usage
+	global.Parse(os.Args[1:])
+
+	if *output != "table" && *output != "json" {
+		fatalf("unknown output format %q", *output)
+	}
+	args := global.Args()
+	if len(args) == 0 {
+		usage()
+		os.Exit(2)
+	}
+
+	config, err := loadConfig()
+	if err != nil {
+		fatalf("%v", err)
+	}
+
+	// Profile management does not talk to a server
+	if args[0] == "profile" {
+		if err := runProfile(config, args[1:], newPrinter(*output)); err != nil {
+			fatalf("%v", err)
+		}
+		return
+	}
+
+	profile, err := config.profile(*profileName)
+	if err != nil {
+		fatalf("%v", err)
+	}
+	if *server != "" {
+		profile.Server = *server
+	}
+	if *apiKey != "" {
+		profile.APIKey = *apiKey
+	}
+	if profile.Server == "" {
+		fatalf("no server configured, use -server or cfgctl profile set")
+	}
+
+	cli := &cli{client: newClient(profile.Server, profile.APIKey), out: newPrinter(*output)}
+	commands := map[string]func([]string) error{
+		"get":     cli.get,
+		"set":     cli.set,
+		"resolve": cli.resolve,
+		"diff":    cli.diff,
+		"export":  cli.export,
+		"import":  cli.importDocument,
+		"drift":   cli.drift,
+	}
+	command, ok := commands[args[0]]
+	if !ok {
+		fatalf("unknown command %q", args[0])
+	}
+	if err := command(args[1:]); err != nil {
+		fatalf("%v", err)
+	}
+}
+
+func usage() {
+	fmt.Fprint(os.Stderr, `usage: cfgctl [-profile name] [-server url] [-api-key key] [-o table|json] <command> [flags] [args]
+
+commands:
+  get nodes | node <nodeId> | properties <nodeId>
+  set [-env e] [-type t] <nodeId> <key> <value>
+  resolve [-env e] [-context k=v] <nodeId>
+  diff [-env e] <leftNodeId> <rightNodeId>
+  export [-f file] <nodeId>
+  import [-f file] [-parent nodeId]
+  drift [-f file] [-parent nodeId]
+  profile list | set [-server url] [-api-key key] <name> | use <name>
+`)
+}
+
+func fatalf(format string, args ...interface{}) {
+	fmt.Fprintf(os.Stderr, "cfgctl: "+format+"\n", args...)
+	os.Exit(1)
+}
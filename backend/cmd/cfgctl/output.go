@@ -0,0 +1,60 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+)
+
+// printer writes command results either as aligned tables or as the JSON
+// returned by the API
+type printer struct {
+	json bool
+	w    io.Writer
+}
+
+func newPrinter(format string) *printer {
+	return &printer{json: format == "json", w: os.Stdout}
+}
+
+// print writes v as indented JSON, or calls table with a tab-separated writer
+func (p *printer) print(v interface{}, table func(w io.Writer)) error {
+	if p.json {
+		encoder := json.NewEncoder(p.w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(v)
+	}
+
+	tw := tabwriter.NewWriter(p.w, 0, 0, 2, ' ', 0)
+	table(tw)
+	return tw.Flush()
+}
+
+// row writes one table row
+func row(w io.Writer, columns ...interface{}) {
+	cells := make([]string, len(columns))
+	for i, column := range columns {
+		cells[i] = fmt.Sprint(column)
+	}
+	fmt.Fprintln(w, strings.Join(cells, "\t"))
+}
+
+// orDash shows optional values, such as environments, in tables
+func orDash(s *string) string {
+	if s == nil || *s == "" {
+		return "-"
+	}
+	return *s
+}
+
+// compact renders a JSON value on a single line for table cells
+func compact(value interface{}) string {
+	payload, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(payload)
+}
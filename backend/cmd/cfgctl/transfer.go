@@ -0,0 +1,340 @@
+package main
+
+import (
+	"config-manager/internal/models"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"reflect"
+
+	"gopkg.in/yaml.v3"
+)
+
+func (c *cli) export(args []string) error {
+	flags := flag.NewFlagSet("export", flag.ExitOnError)
+	file := flags.String("f", "", "file to write (default standard output)")
+	args, err := parseArgs(flags, args, 1, "export [-f file] <nodeId>")
+	if err != nil {
+		return err
+	}
+	nodeID, err := parseNodeID(args[0])
+	if err != nil {
+		return err
+	}
+
+	document, err := c.exportNode(nodeID)
+	if err != nil {
+		return err
+	}
+
+	var data []byte
+	if c.out.json {
+		data, err = json.MarshalIndent(document, "", "  ")
+		data = append(data, '\n')
+	} else {
+		data, err = yaml.Marshal(document)
+	}
+	if err != nil {
+		return err
+	}
+	if *file == "" {
+		_, err = os.Stdout.Write(data)
+		return err
+	}
+	return os.WriteFile(*file, data, 0o644)
+}
+
+// exportNode builds the document of a node and its subtree. Encrypted values
+// are left out.
+func (c *cli) exportNode(nodeID int64) (*models.DeclaredNode, error) {
+	var node models.ConfigNodeWithChildren
+	if err := c.client.do(http.MethodGet, fmt.Sprintf("/api/nodes/%d/children", nodeID), nil, &node); err != nil {
+		return nil, err
+	}
+	properties, err := c.properties(nodeID)
+	if err != nil {
+		return nil, err
+	}
+
+	document := &models.DeclaredNode{Name: node.Name, Type: node.NodeType, Description: node.Description}
+	for _, p := range properties {
+		declared := models.DeclaredProperty{
+			Key:         p.Key,
+			Environment: p.Environment,
+			Type:        p.DataType,
+			Description: p.Description,
+			Encrypted:   p.Encrypted,
+			Final:       p.IsFinal,
+		}
+		if !p.Encrypted {
+			if err := json.Unmarshal([]byte(p.Value), &declared.Value); err != nil {
+				return nil, fmt.Errorf("property %s of node %d: %w", p.Key, nodeID, err)
+			}
+		}
+		document.Properties = append(document.Properties, declared)
+	}
+	for _, child := range node.Children {
+		childDocument, err := c.exportNode(child.ID)
+		if err != nil {
+			return nil, err
+		}
+		document.Children = append(document.Children, *childDocument)
+	}
+	return document, nil
+}
+
+// importer applies a document to the tree and records what it did
+type importer struct {
+	*cli
+	actions []importAction
+}
+
+type importAction struct {
+	Action string `json:"action"` // created, updated or skipped
+	Path   string `json:"path"`
+	Detail string `json:"detail,omitempty"`
+}
+
+func (c *cli) importDocument(args []string) error {
+	flags := flag.NewFlagSet("import", flag.ExitOnError)
+	file := flags.String("f", "", "document to read (default standard input)")
+	parent := flags.Int64("parent", 0, "node to import under (default import as a root node)")
+	if _, err := parseArgs(flags, args, 0, "import [-f file] [-parent nodeId]"); err != nil {
+		return err
+	}
+
+	document, err := readDocument(*file)
+	if err != nil {
+		return err
+	}
+
+	var parentID *int64
+	if *parent != 0 {
+		parentID = parent
+	}
+
+	imp := &importer{cli: c}
+	importErr := imp.importNode(*document, parentID, "")
+	printErr := c.out.print(imp.actions, func(w io.Writer) {
+		row(w, "ACTION", "PATH", "DETAIL")
+		for _, a := range imp.actions {
+			row(w, a.Action, a.Path, a.Detail)
+		}
+	})
+	if importErr != nil {
+		return importErr
+	}
+	return printErr
+}
+
+// readDocument reads a document from a file, or from standard input when file
+// is empty. YAML is a superset of JSON, so both are accepted.
+func readDocument(file string) (*models.DeclaredNode, error) {
+	var data []byte
+	var err error
+	if file == "" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(file)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var document models.DeclaredNode
+	if err := yaml.Unmarshal(data, &document); err != nil {
+		return nil, fmt.Errorf("invalid document: %w", err)
+	}
+	return &document, nil
+}
+
+func (c *cli) drift(args []string) error {
+	flags := flag.NewFlagSet("drift", flag.ExitOnError)
+	file := flags.String("f", "", "document to read (default standard input)")
+	parent := flags.Int64("parent", 0, "node the document is declared under (default a root node)")
+	if _, err := parseArgs(flags, args, 0, "drift [-f file] [-parent nodeId]"); err != nil {
+		return err
+	}
+
+	document, err := readDocument(*file)
+	if err != nil {
+		return err
+	}
+	req := models.ApplyRequest{Node: *document}
+	if *parent != 0 {
+		req.ParentID = parent
+	}
+
+	var report models.DriftReport
+	if err := c.client.do(http.MethodPost, "/api/drift", req, &report); err != nil {
+		return err
+	}
+
+	err = c.out.print(report, func(w io.Writer) {
+		row(w, "STATUS", "PATH", "KEY", "ENVIRONMENT", "DECLARED", "LIVE")
+		for _, d := range report.Drift {
+			row(w, d.Status, d.Path, d.Key, orDash(d.Environment), compact(d.Declared), compact(d.Live))
+		}
+		for _, warning := range report.Warnings {
+			row(w, "warning:", warning)
+		}
+	})
+	if err != nil {
+		return err
+	}
+	// A non-zero exit status lets scripts fail on drift
+	if !report.InSync {
+		return fmt.Errorf("live configuration differs from the document in %d places", len(report.Drift))
+	}
+	return nil
+}
+
+func (imp *importer) record(action, path, detail string) {
+	imp.actions = append(imp.actions, importAction{Action: action, Path: path, Detail: detail})
+}
+
+// importNode creates or updates a declared node under parentID, matching
+// existing nodes by name, then imports its properties and children
+func (imp *importer) importNode(document models.DeclaredNode, parentID *int64, parentPath string) error {
+	path := parentPath + "/" + document.Name
+	if document.Name == "" {
+		return fmt.Errorf("%s: node without a name", parentPath+"/")
+	}
+
+	var siblings []models.ConfigNode
+	if parentID == nil {
+		if err := imp.client.do(http.MethodGet, "/api/nodes", nil, &siblings); err != nil {
+			return err
+		}
+	} else {
+		var parent models.ConfigNodeWithChildren
+		if err := imp.client.do(http.MethodGet, fmt.Sprintf("/api/nodes/%d/children", *parentID), nil, &parent); err != nil {
+			return err
+		}
+		siblings = parent.Children
+	}
+
+	var node *models.ConfigNode
+	for i := range siblings {
+		if siblings[i].Name == document.Name {
+			node = &siblings[i]
+			break
+		}
+	}
+
+	switch {
+	case node == nil:
+		node = &models.ConfigNode{}
+		err := imp.client.do(http.MethodPost, "/api/nodes", models.CreateNodeRequest{
+			Name:        document.Name,
+			NodeType:    document.Type,
+			ParentID:    parentID,
+			Description: document.Description,
+		}, node)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		imp.record("created", path, string(document.Type))
+	case node.NodeType != document.Type:
+		return fmt.Errorf("%s: is a %s, not a %s", path, node.NodeType, document.Type)
+	case node.Description != document.Description:
+		err := imp.client.do(http.MethodPut, fmt.Sprintf("/api/nodes/%d", node.ID), models.UpdateNodeRequest{
+			Description: &document.Description,
+		}, node)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		imp.record("updated", path, "description")
+	}
+
+	if err := imp.importProperties(node.ID, document.Properties, path); err != nil {
+		return err
+	}
+	for _, child := range document.Children {
+		if err := imp.importNode(child, &node.ID, path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (imp *importer) importProperties(nodeID int64, declared []models.DeclaredProperty, path string) error {
+	if len(declared) == 0 {
+		return nil
+	}
+	properties, err := imp.properties(nodeID)
+	if err != nil {
+		return err
+	}
+
+	for _, d := range declared {
+		propertyPath := path + ":" + d.Key
+		if d.Environment != nil {
+			propertyPath += "@" + *d.Environment
+		}
+		// Exports leave encrypted values out, there is nothing to import
+		if d.Encrypted && d.Value == nil {
+			imp.record("skipped", propertyPath, "encrypted value not included")
+			continue
+		}
+
+		payload, err := json.Marshal(d.Value)
+		if err != nil {
+			return fmt.Errorf("%s: %w", propertyPath, err)
+		}
+		value := string(payload)
+
+		existing := findProperty(properties, d.Key, d.Environment)
+		if existing == nil {
+			err := imp.client.do(http.MethodPost, fmt.Sprintf("/api/nodes/%d/properties", nodeID), models.CreatePropertyRequest{
+				Key:         d.Key,
+				Value:       value,
+				DataType:    d.Type,
+				Description: d.Description,
+				Encrypted:   d.Encrypted,
+				Environment: d.Environment,
+				IsFinal:     d.Final,
+			}, nil)
+			if err != nil {
+				return fmt.Errorf("%s: %w", propertyPath, err)
+			}
+			imp.record("created", propertyPath, compact(d.Value))
+			continue
+		}
+
+		same, err := sameValue(existing.Value, value)
+		if err != nil {
+			return fmt.Errorf("%s: %w", propertyPath, err)
+		}
+		if same && existing.DataType == d.Type && existing.Description == d.Description && existing.IsFinal == d.Final {
+			continue
+		}
+		err = imp.client.do(http.MethodPut, fmt.Sprintf("/api/properties/%d", existing.ID), models.UpdatePropertyRequest{
+			Value:       &value,
+			DataType:    &d.Type,
+			Description: &d.Description,
+			IsFinal:     &d.Final,
+		}, nil)
+		if err != nil {
+			return fmt.Errorf("%s: %w", propertyPath, err)
+		}
+		imp.record("updated", propertyPath, compact(d.Value))
+	}
+	return nil
+}
+
+// sameValue compares two serialized JSON values regardless of formatting
+func sameValue(a, b string) (bool, error) {
+	var va, vb interface{}
+	if err := json.Unmarshal([]byte(a), &va); err != nil {
+		return false, errors.New("stored value is not valid JSON")
+	}
+	if err := json.Unmarshal([]byte(b), &vb); err != nil {
+		return false, err
+	}
+	return reflect.DeepEqual(va, vb), nil
+}
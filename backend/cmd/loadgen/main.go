@@ -0,0 +1,386 @@
+// Command loadgen seeds a synthetic configuration tree on a running server and
+// replays a mix of reads and writes against it, reporting latency percentiles
+// per operation.
+//
+// Usage:
+//
+//	loadgen -target http://localhost:8080 -depth 4 -fanout 3 -duration 30s
+//
+// The mix is given as weights, e.g. -mix resolve=80,properties=15,write=5.
+// The seeded tree is deleted afterwards unless -keep is set.
+package main
+
+import (
+	"bytes"
+	"config-manager/internal/models"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"math/rand"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// operations lists the request types loadgen can replay, in report order
+var operations = []string{"resolve", "path", "properties", "write"}
+
+type client struct {
+	target string
+	apiKey string
+	http   *http.Client
+}
+
+func (c *client) do(method, path string, body, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		payload, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(payload)
+	}
+
+	req, err := http.NewRequest(method, c.target+path, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if c.apiKey != "" {
+		req.Header.Set("X-API-Key", c.apiKey)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s %s: %s: %s", method, path, resp.Status, strings.TrimSpace(string(msg)))
+	}
+	if out == nil {
+		_, err = io.Copy(io.Discard, resp.Body)
+		return err
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// tree is the seeded data the workload runs against
+type tree struct {
+	rootID     int64
+	nodes      []int64
+	leaves     []int64
+	properties []int64
+}
+
+// seed creates a tree of the given depth where every non-leaf node has fanout
+// children and every node has props properties. Each level is created with up
+// to concurrency requests in flight.
+func seed(c *client, depth, fanout, props, concurrency int) (*tree, error) {
+	t := &tree{}
+	var mu sync.Mutex
+
+	createNode := func(level, index int, parentID *int64) (int64, error) {
+		nodeType := models.NodeTypeTerritory
+		if level == depth-1 {
+			nodeType = models.NodeTypeCenter
+		}
+
+		var node models.ConfigNode
+		err := c.do(http.MethodPost, "/api/nodes", models.CreateNodeRequest{
+			Name:        fmt.Sprintf("loadgen-%d-%d", level, index),
+			NodeType:    nodeType,
+			ParentID:    parentID,
+			Description: "Synthetic node created by loadgen",
+		}, &node)
+		if err != nil {
+			return 0, err
+		}
+
+		for i := 0; i < props; i++ {
+			// Half of the keys repeat on every level so resolution exercises overrides
+			key := fmt.Sprintf("key_%d", i)
+			if i%2 == 1 {
+				key = fmt.Sprintf("key_%d_level_%d", i, level)
+			}
+
+			var prop models.PropertyWriteResponse
+			err := c.do(http.MethodPost, fmt.Sprintf("/api/nodes/%d/properties", node.ID), models.CreatePropertyRequest{
+				Key:      key,
+				Value:    strconv.Quote(fmt.Sprintf("value-%d-%d", node.ID, i)),
+				DataType: models.DataTypeString,
+			}, &prop)
+			if err != nil {
+				return 0, err
+			}
+
+			mu.Lock()
+			t.properties = append(t.properties, prop.ID)
+			mu.Unlock()
+		}
+
+		mu.Lock()
+		t.nodes = append(t.nodes, node.ID)
+		if nodeType == models.NodeTypeCenter {
+			t.leaves = append(t.leaves, node.ID)
+		}
+		mu.Unlock()
+		return node.ID, nil
+	}
+
+	rootID, err := createNode(0, 0, nil)
+	if err != nil {
+		return nil, err
+	}
+	t.rootID = rootID
+
+	parents := []int64{rootID}
+	for level := 1; level < depth; level++ {
+		children := make([]int64, len(parents)*fanout)
+		errs := make(chan error, len(children))
+		sem := make(chan struct{}, concurrency)
+		var wg sync.WaitGroup
+
+		for i := range children {
+			parentID := parents[i/fanout]
+			wg.Add(1)
+			sem <- struct{}{}
+			go func(i int) {
+				defer wg.Done()
+				defer func() { <-sem }()
+				id, err := createNode(level, i, &parentID)
+				if err != nil {
+					errs <- err
+					return
+				}
+				children[i] = id
+			}(i)
+		}
+		wg.Wait()
+		close(errs)
+
+		if err := <-errs; err != nil {
+			return t, err
+		}
+		parents = children
+	}
+
+	if len(t.leaves) == 0 {
+		t.leaves = []int64{rootID}
+	}
+	return t, nil
+}
+
+// parseMix parses weights such as "resolve=80,write=20" into a cumulative
+// table used to pick operations
+func parseMix(mix string) ([]string, []int, error) {
+	var ops []string
+	var cumulative []int
+	total := 0
+
+	for _, part := range strings.Split(mix, ",") {
+		name, weightStr, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			return nil, nil, fmt.Errorf("invalid mix entry %q", part)
+		}
+		known := false
+		for _, op := range operations {
+			known = known || op == name
+		}
+		if !known {
+			return nil, nil, fmt.Errorf("unknown operation %q (want one of %s)", name, strings.Join(operations, ", "))
+		}
+		weight, err := strconv.Atoi(weightStr)
+		if err != nil || weight < 0 {
+			return nil, nil, fmt.Errorf("invalid weight for %s", name)
+		}
+
+		total += weight
+		ops = append(ops, name)
+		cumulative = append(cumulative, total)
+	}
+
+	if total == 0 {
+		return nil, nil, fmt.Errorf("mix weights must not all be zero")
+	}
+	return ops, cumulative, nil
+}
+
+// results collects per-operation latencies and errors
+type results struct {
+	mu        sync.Mutex
+	latencies map[string][]time.Duration
+	errors    map[string]int
+	lastError map[string]error
+}
+
+func (r *results) record(op string, latency time.Duration, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err != nil {
+		r.errors[op]++
+		r.lastError[op] = err
+		return
+	}
+	r.latencies[op] = append(r.latencies[op], latency)
+}
+
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	index := int(math.Ceil(p*float64(len(sorted)))) - 1
+	if index < 0 {
+		index = 0
+	}
+	return sorted[index]
+}
+
+func (r *results) report(w io.Writer, elapsed time.Duration) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "operation\trequests\terrors\treq/s\tp50\tp90\tp99\tmax\t")
+
+	for _, op := range operations {
+		latencies := r.latencies[op]
+		if len(latencies) == 0 && r.errors[op] == 0 {
+			continue
+		}
+		sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%.1f\t%s\t%s\t%s\t%s\t\n",
+			op, len(latencies), r.errors[op], float64(len(latencies))/elapsed.Seconds(),
+			round(percentile(latencies, 0.50)), round(percentile(latencies, 0.90)),
+			round(percentile(latencies, 0.99)), round(percentile(latencies, 1)))
+	}
+	tw.Flush()
+
+	for _, op := range operations {
+		if err := r.lastError[op]; err != nil {
+			fmt.Fprintf(w, "last %s error: %v\n", op, err)
+		}
+	}
+}
+
+func round(d time.Duration) time.Duration {
+	return d.Round(10 * time.Microsecond)
+}
+
+func run(c *client, t *tree, ops []string, cumulative []int, duration time.Duration, concurrency int, seed int64) (*results, time.Duration) {
+	res := &results{
+		latencies: map[string][]time.Duration{},
+		errors:    map[string]int{},
+		lastError: map[string]error{},
+	}
+	deadline := time.Now().Add(duration)
+	start := time.Now()
+
+	var wg sync.WaitGroup
+	for w := 0; w < concurrency; w++ {
+		wg.Add(1)
+		go func(rng *rand.Rand) {
+			defer wg.Done()
+			for time.Now().Before(deadline) {
+				pick := rng.Intn(cumulative[len(cumulative)-1])
+				op := ops[sort.SearchInts(cumulative, pick+1)]
+
+				var path string
+				var body interface{}
+				method := http.MethodGet
+				switch op {
+				case "resolve":
+					path = fmt.Sprintf("/api/nodes/%d/resolve", t.leaves[rng.Intn(len(t.leaves))])
+				case "path":
+					path = fmt.Sprintf("/api/nodes/%d/path", t.leaves[rng.Intn(len(t.leaves))])
+				case "properties":
+					path = fmt.Sprintf("/api/nodes/%d/properties", t.nodes[rng.Intn(len(t.nodes))])
+				case "write":
+					if len(t.properties) == 0 {
+						continue
+					}
+					method = http.MethodPut
+					path = fmt.Sprintf("/api/properties/%d", t.properties[rng.Intn(len(t.properties))])
+					value := strconv.Quote(fmt.Sprintf("value-%d", rng.Int63()))
+					body = models.UpdatePropertyRequest{Value: &value}
+				}
+
+				began := time.Now()
+				err := c.do(method, path, body, nil)
+				res.record(op, time.Since(began), err)
+			}
+		}(rand.New(rand.NewSource(seed + int64(w))))
+	}
+	wg.Wait()
+
+	return res, time.Since(start)
+}
+
+func main() {
+	target := flag.String("target", "http://localhost:8080", "base URL of the server under test")
+	apiKey := flag.String("api-key", os.Getenv("LOADGEN_API_KEY"), "API key with the write scope (default $LOADGEN_API_KEY)")
+	depth := flag.Int("depth", 4, "levels in the seeded tree")
+	fanout := flag.Int("fanout", 3, "children per non-leaf node")
+	props := flag.Int("props", 5, "properties per node")
+	duration := flag.Duration("duration", 30*time.Second, "how long to replay the workload")
+	concurrency := flag.Int("concurrency", 8, "concurrent requests")
+	mix := flag.String("mix", "resolve=80,path=5,properties=10,write=5", "operation weights")
+	seedValue := flag.Int64("seed", time.Now().UnixNano(), "random seed for the workload")
+	keep := flag.Bool("keep", false, "keep the seeded tree instead of deleting it afterwards")
+	flag.Parse()
+
+	if *depth < 1 || *fanout < 1 || *props < 0 || *concurrency < 1 {
+		log.Fatal("depth, fanout and concurrency must be positive and props non-negative")
+	}
+	ops, cumulative, err := parseMix(*mix)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	c := &client{
+		target: strings.TrimRight(*target, "/"),
+		apiKey: *apiKey,
+		http: &http.Client{
+			Timeout:   30 * time.Second,
+			Transport: &http.Transport{MaxIdleConnsPerHost: *concurrency},
+		},
+	}
+
+	log.Printf("Seeding tree: depth %d, fanout %d, %d properties per node", *depth, *fanout, *props)
+	seedStart := time.Now()
+	t, err := seed(c, *depth, *fanout, *props, *concurrency)
+	if err != nil {
+		if t != nil && t.rootID != 0 && !*keep {
+			cleanup(c, t.rootID)
+		}
+		log.Fatal("Failed to seed tree: ", err)
+	}
+	log.Printf("Seeded %d nodes and %d properties in %s", len(t.nodes), len(t.properties), time.Since(seedStart).Round(time.Millisecond))
+
+	log.Printf("Replaying %s for %s with %d workers (seed %d)", *mix, *duration, *concurrency, *seedValue)
+	res, elapsed := run(c, t, ops, cumulative, *duration, *concurrency, *seedValue)
+	res.report(os.Stdout, elapsed)
+
+	if !*keep {
+		cleanup(c, t.rootID)
+	}
+}
+
+// cleanup deletes the seeded tree. The workload has just resolved it, so the
+// delete guard has to be overridden.
+func cleanup(c *client, rootID int64) {
+	path := fmt.Sprintf("/api/nodes/%d?force=true&reason=loadgen+cleanup", rootID)
+	if err := c.do(http.MethodDelete, path, nil, nil); err != nil {
+		log.Printf("Failed to delete seeded tree rooted at node %d: %v", rootID, err)
+		return
+	}
+	log.Printf("Deleted seeded tree rooted at node %d", rootID)
+}
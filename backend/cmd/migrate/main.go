@@ -0,0 +1,65 @@
+// Command migrate applies, rolls back and lists database schema migrations.
+//
+// Usage:
+//
+//	migrate up          apply all pending migrations
+//	migrate down [n]    roll back the last n migrations (default 1)
+//	migrate status      list migrations and whether they are applied
+package main
+
+import (
+	"config-manager/internal/database"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+
+	"github.com/joho/godotenv"
+)
+
+func main() {
+	if err := godotenv.Load(); err != nil {
+		log.Println("No .env file found")
+	}
+
+	if len(os.Args) < 2 {
+		log.Fatal("usage: migrate up | down [n] | status")
+	}
+
+	db, err := database.NewConnection()
+	if err != nil {
+		log.Fatal("Failed to connect to database:", err)
+	}
+	defer db.Close()
+
+	switch os.Args[1] {
+	case "up":
+		if err := db.RunMigrations(); err != nil {
+			log.Fatal("Failed to run migrations:", err)
+		}
+	case "down":
+		steps := 1
+		if len(os.Args) > 2 {
+			if steps, err = strconv.Atoi(os.Args[2]); err != nil || steps < 1 {
+				log.Fatal("down expects a positive number of steps")
+			}
+		}
+		if err := db.RollbackMigrations(steps); err != nil {
+			log.Fatal("Failed to roll back migrations:", err)
+		}
+	case "status":
+		statuses, err := db.MigrationStatuses()
+		if err != nil {
+			log.Fatal("Failed to read migration status:", err)
+		}
+		for _, s := range statuses {
+			state := "pending"
+			if s.Applied {
+				state = "applied"
+			}
+			fmt.Printf("%04d_%s\t%s\n", s.Version, s.Name, state)
+		}
+	default:
+		log.Fatalf("unknown command %q", os.Args[1])
+	}
+}
@@ -0,0 +1,109 @@
+// Command resolver serves a read-only subset of the configuration API: only
+// resolution endpoints are registered and migrations are never run, so it can
+// be pointed at a read replica and deployed close to the fleet.
+package main
+
+import (
+	"config-manager/internal/auth"
+	"config-manager/internal/config"
+	"config-manager/internal/database"
+	"config-manager/internal/encryption"
+	"config-manager/internal/handlers"
+	"config-manager/internal/server"
+	"config-manager/internal/signing"
+	"log"
+	"os"
+
+	"github.com/gin-gonic/gin"
+)
+
+func main() {
+	// The resolver listens on 8081 unless PORT says otherwise
+	if os.Getenv("PORT") == "" {
+		os.Setenv("PORT", "8081")
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatal("Failed to load configuration:", err)
+	}
+
+	// Prefer a dedicated replica, falling back to the primary database.
+	// REPLICA_DATABASE_URL is the name the resolver used before the server
+	// could read from a replica too.
+	dbURL := cfg.DatabaseReplicaURL
+	if dbURL == "" {
+		dbURL = os.Getenv("REPLICA_DATABASE_URL")
+	}
+	if dbURL == "" {
+		dbURL = cfg.DatabaseURL
+	}
+	if dbURL == "" {
+		log.Fatal("DATABASE_REPLICA_URL or DATABASE_URL environment variable is required")
+	}
+
+	db, err := database.Open(dbURL, database.PoolConfig{
+		MaxConns:        cfg.DBMaxOpenConns,
+		MaxConnIdleTime: cfg.DBConnMaxIdleTime,
+		MaxConnLifetime: cfg.DBConnMaxLifetime,
+	})
+	if err != nil {
+		log.Fatal("Failed to connect to database:", err)
+	}
+	defer db.Close()
+	db.SetQueryTimeout(cfg.DBQueryTimeout)
+
+	// Encrypted properties can only be resolved when the master key is available
+	keyring, err := encryption.NewKeyringFromEnv()
+	if err != nil {
+		log.Fatal("Failed to load encryption keyring:", err)
+	}
+
+	// The last use of API keys cannot be recorded on a replica
+	repo := database.NewRepository(db, keyring)
+	if dbURL != cfg.DatabaseURL {
+		repo.ReadOnly()
+	}
+	// The resolver may read from a replica, so it does not record node accesses
+	// for the delete guard and never deletes anything itself. Without change
+	// events, watch requests poll for changes.
+	handler := handlers.NewHandler(repo, auth.NewACL(repo, cfg.ACLEnforced, cfg.ACLAdmins, cfg.AdminTOTPRequired), 0, nil, cfg.RequireRegisteredKeys)
+	if cfg.ResolveSigningKeyFile != "" {
+		signer, err := signing.Load(cfg.ResolveSigningKeyFile, cfg.ResolveSigningKeyID)
+		if err != nil {
+			log.Fatal("Failed to load RESOLVE_SIGNING_KEY_FILE:", err)
+		}
+		handler.UseSigner(signer)
+	}
+
+	gin.SetMode(cfg.GinMode)
+	r := gin.Default()
+	if err := r.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		log.Fatal("Invalid TRUSTED_PROXIES:", err)
+	}
+	if cfg.CompressionMinSize > 0 {
+		r.Use(handlers.Compress(cfg.CompressionMinSize))
+	}
+	r.Use(handlers.Render())
+
+	// Health checks
+	r.GET("/healthz", handler.Liveness)
+	r.GET("/readyz", handler.Readiness)
+	r.GET("/.well-known/jwks.json", handler.SigningKeys)
+
+	// Resolution routes only, mutating routes are not registered
+	policy := auth.NetworkPolicy{Resolve: cfg.NetworkAllowResolve, Read: cfg.NetworkAllowRead, Write: cfg.NetworkAllowWrite}
+	nodes := r.Group("/api/nodes", auth.NetworkMiddleware(policy), auth.ClientCertMiddleware(cfg.TLSClientIdentities), auth.APIKeyMiddleware(repo, cfg.APIKeyRequired),
+		handlers.LimitBody(int64(cfg.MaxBodySize), int64(cfg.MaxImportBodySize)))
+	{
+		nodes.GET("/:nodeId/path", handler.GetNodePath)
+		nodes.GET("/:nodeId/resolve", handler.ResolveConfiguration)
+		nodes.GET("/:nodeId/resolve/watch", handler.WatchConfiguration)
+		nodes.POST("/:nodeId/evaluate", handler.EvaluateFlags)
+	}
+
+	log.Printf("Resolver starting on port %s", cfg.Port)
+	if err := server.Run(cfg, r); err != nil {
+		log.Fatal("Failed to start resolver:", err)
+	}
+}
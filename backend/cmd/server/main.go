@@ -1,90 +1,458 @@
 package main
 
 import (
+	"config-manager/internal/alerts"
+	"config-manager/internal/auth"
+	"config-manager/internal/config"
+	"config-manager/internal/consul"
 	"config-manager/internal/database"
+	"config-manager/internal/encryption"
+	"config-manager/internal/events"
+	"config-manager/internal/expiry"
+	"config-manager/internal/gitops"
 	"config-manager/internal/handlers"
+	"config-manager/internal/kafka"
+	"config-manager/internal/keyrotation"
+	"config-manager/internal/kube"
+	"config-manager/internal/materialize"
+	"config-manager/internal/metrics"
+	"config-manager/internal/nats"
+	"config-manager/internal/outbox"
+	"config-manager/internal/quota"
+	"config-manager/internal/scheduler"
+	"config-manager/internal/server"
+	"config-manager/internal/siem"
+	"config-manager/internal/signing"
+	"config-manager/internal/ssm"
+	"config-manager/internal/syncer"
+	"context"
 	"log"
-	"os"
+	"time"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
-	"github.com/joho/godotenv"
 )
 
 func main() {
-	// Load environment variables
-	if err := godotenv.Load(); err != nil {
-		log.Println("No .env file found")
+	// Load server configuration from the environment
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatal("Failed to load configuration:", err)
+	}
+	if cfg.DatabaseURL == "" {
+		runDemo(cfg)
+		return
+	}
+	if cfg.DatabaseDriver != database.DriverPostgres {
+		runCore(cfg)
+		return
 	}
 
 	// Initialize database
-	db, err := database.NewConnection()
+	db, err := database.Open(cfg.DatabaseURL, poolConfig(cfg))
 	if err != nil {
 		log.Fatal("Failed to connect to database:", err)
 	}
 	defer db.Close()
+	db.SetQueryTimeout(cfg.DBQueryTimeout)
 
 	// Run migrations
 	if err := db.RunMigrations(); err != nil {
 		log.Fatal("Failed to run migrations:", err)
 	}
 
-	// Initialize repository and handlers
-	repo := database.NewRepository(db)
-	handler := handlers.NewHandler(repo)
+	// Load the master key used to wrap per-subtree encryption keys
+	keyring, err := encryption.NewKeyringFromEnv()
+	if err != nil {
+		log.Fatal("Failed to load encryption keyring:", err)
+	}
+	if keyring == nil {
+		log.Println("ENCRYPTION_MASTER_KEY not set, encrypted properties are disabled")
+	}
+
+	// Initialize repository
+	repo := database.NewRepository(db, keyring)
+
+	// Background workers run until shutdown
+	workersCtx, stopWorkers := context.WithCancel(context.Background())
+	defer stopWorkers()
+	changes := events.NewBroadcaster()
+	publisher := events.Multi{events.LogPublisher{}, changes}
+
+	// Publish change events to Kafka
+	if len(cfg.KafkaBrokers) > 0 {
+		producer, err := kafka.NewPublisher(kafka.Config{
+			Brokers:      cfg.KafkaBrokers,
+			Topic:        cfg.KafkaTopic,
+			TLS:          cfg.KafkaTLS,
+			SASLUsername: cfg.KafkaSASLUsername,
+			SASLPassword: cfg.KafkaSASLPassword,
+		})
+		if err != nil {
+			log.Fatal("Failed to configure Kafka publishing:", err)
+		}
+		defer producer.Close()
+		publisher = append(publisher, producer)
+	}
+
+	// Publish change events to NATS JetStream
+	if cfg.NATSURL != "" {
+		producer, err := nats.NewPublisher(context.Background(), nats.Config{
+			URL:         cfg.NATSURL,
+			Subject:     cfg.NATSSubject,
+			Stream:      cfg.NATSStream,
+			Credentials: cfg.NATSCredentials,
+		})
+		if err != nil {
+			log.Fatal("Failed to configure NATS publishing:", err)
+		}
+		defer producer.Close()
+		publisher = append(publisher, producer)
+	}
+
+	// Stream the audit log and change events to a SIEM
+	if cfg.AuditExportURL != "" {
+		exporter, err := siem.New(repo, siem.Config{
+			URL:        cfg.AuditExportURL,
+			Token:      cfg.AuditExportToken,
+			BufferSize: cfg.AuditExportBuffer,
+			Interval:   cfg.AuditExportInterval,
+		})
+		if err != nil {
+			log.Fatal("Failed to configure audit export:", err)
+		}
+		publisher = append(publisher, exporter)
+		go exporter.Run(workersCtx)
+	}
+
+	// Mirror the configuration of selected nodes into Kubernetes ConfigMaps and Secrets
+	if cfg.K8sSyncBindings != "" {
+		bindings, err := syncer.ParseBindings(cfg.K8sSyncBindings)
+		if err != nil {
+			log.Fatal("Invalid K8S_SYNC_BINDINGS:", err)
+		}
+		client, err := kube.NewClient(cfg.K8sKubeconfig, cfg.K8sContext)
+		if err != nil {
+			log.Fatal("Failed to configure Kubernetes sync:", err)
+		}
+		controller := syncer.New(repo, kube.NewTarget(client), bindings, cfg.SyncInterval)
+		publisher = append(publisher, controller)
+		go controller.Run(workersCtx)
+	}
+
+	// Mirror the configuration of selected nodes into Consul KV
+	if cfg.ConsulSyncBindings != "" {
+		bindings, err := syncer.ParseBindings(cfg.ConsulSyncBindings)
+		if err != nil {
+			log.Fatal("Invalid CONSUL_SYNC_BINDINGS:", err)
+		}
+		controller := syncer.New(repo, consul.NewTarget(cfg.ConsulAddr, cfg.ConsulToken, cfg.ConsulKVPrefix), bindings, cfg.SyncInterval)
+		publisher = append(publisher, controller)
+		go controller.Run(workersCtx)
+	}
+
+	// Mirror the configuration of selected nodes into AWS SSM Parameter Store
+	var ssmController *syncer.Controller
+	if cfg.SSMSyncBindings != "" {
+		bindings, err := syncer.ParseBindings(cfg.SSMSyncBindings)
+		if err != nil {
+			log.Fatal("Invalid SSM_SYNC_BINDINGS:", err)
+		}
+		target, err := ssm.NewTarget(context.Background(), cfg.SSMRegion, cfg.SSMPathPrefix, cfg.SSMKMSKeyID)
+		if err != nil {
+			log.Fatal("Failed to configure SSM sync:", err)
+		}
+		controller := syncer.New(repo, target, bindings, cfg.SyncInterval)
+		controller.DryRun = cfg.SSMDryRun
+		publisher = append(publisher, controller)
+		go controller.Run(workersCtx)
+		ssmController = controller
+	}
+
+	// Commit the exported tree to a Git repository on every change
+	if cfg.GitOpsRepoURL != "" {
+		gitSyncer, err := gitops.New(repo, gitops.Config{
+			RepoURL:     cfg.GitOpsRepoURL,
+			Branch:      cfg.GitOpsBranch,
+			Dir:         cfg.GitOpsDir,
+			Path:        cfg.GitOpsPath,
+			Import:      cfg.GitOpsImport,
+			Interval:    cfg.GitOpsInterval,
+			AuthorName:  cfg.GitOpsAuthorName,
+			AuthorEmail: cfg.GitOpsAuthorEmail,
+		})
+		if err != nil {
+			log.Fatal("Failed to configure GitOps:", err)
+		}
+		publisher = append(publisher, gitSyncer)
+		go gitSyncer.Run(workersCtx)
+	}
+
+	// Answer resolutions from the materialized table, refreshed as changes are delivered
+	if cfg.ResolveMaterialized {
+		repo.MaterializeResolutions()
+		refresher := materialize.New(repo)
+		publisher = append(publisher, refresher)
+		go refresher.Fill(workersCtx)
+	}
+
+	// Initialize handlers
+	handler := handlers.NewHandler(repo, auth.NewACL(repo, cfg.ACLEnforced, cfg.ACLAdmins, cfg.AdminTOTPRequired), cfg.DeleteGuardWindow, changes, cfg.RequireRegisteredKeys)
+	if ssmController != nil {
+		handler.UseSSMSync(ssmController)
+	}
+
+	// Serve reads from a replica, migrations and writes stay on the primary
+	var reads database.ConfigRepository
+	if cfg.DatabaseReplicaURL != "" {
+		replicaDB, err := database.Open(cfg.DatabaseReplicaURL, poolConfig(cfg))
+		if err != nil {
+			log.Fatal("Failed to connect to database replica:", err)
+		}
+		defer replicaDB.Close()
+		replicaDB.SetQueryTimeout(cfg.DBQueryTimeout)
+		replica := database.NewRepository(replicaDB, keyring)
+		if cfg.ResolveMaterialized {
+			replica.MaterializeResolutions()
+		}
+		reads = replica
+		handler.UseHealthCheck("database_replica", replica.Ping)
+	}
+
+	// Cache resolutions and node lookups in memory, dropping them whenever any
+	// server commits a change to their configuration
+	var caches []database.InvalidatedCache
+	if cfg.ResolveCacheTTL > 0 {
+		if reads == nil {
+			reads = repo
+		}
+		cache := database.NewResolutionCache(reads, cfg.ResolveCacheTTL, cfg.ResolveCacheSize)
+		caches = append(caches, cache)
+		reads = cache
+	}
+	if cfg.NodeCacheSize > 0 {
+		if reads == nil {
+			reads = repo
+		}
+		cache := database.NewNodeCache(reads, cfg.NodeCacheSize)
+		caches = append(caches, cache)
+		reads = cache
+	}
+	if len(caches) > 0 {
+		go db.ListenInvalidations(workersCtx, caches...)
+		handler.UseHealthCheck("cache_invalidation", db.CheckInvalidations)
+	}
+	if reads != nil {
+		handler.UseReadRepository(reads)
+	}
+
+	signResponses(cfg, handler)
+
+	// The scheduler and the outbox dispatcher report their progress as
+	// metrics, and alerting rules are served for them
+	changeScheduler := scheduler.New(repo, cfg.SchedulerInterval)
+	dispatcher := outbox.New(repo, publisher, cfg.OutboxInterval, cfg.OutboxRetention)
+	registry := metrics.NewRegistry()
+	registerSchedulerMetrics(registry, changeScheduler)
+	registerOutboxMetrics(registry, dispatcher)
+	subsystems := []alerts.Subsystem{alerts.SubsystemAPI, alerts.SubsystemDatabase, alerts.SubsystemScheduler, alerts.SubsystemOutbox}
+
+	r := newRouter(cfg, repo, handler, registry, subsystems)
+
+	// Apply scheduled property changes in the background
+	go changeScheduler.Run(workersCtx)
 
+	// Delete expired properties in the background when enabled
+	if cfg.PurgeExpiredProperties {
+		go expiry.New(repo, cfg.ExpiryPurgeInterval).Run(workersCtx)
+	}
+
+	// Run requested encryption key rotations in the background
+	go keyrotation.New(repo, cfg.KeyRotationInterval, cfg.KeyRotationBatchSize).Run(workersCtx)
+
+	// Deliver the change events recorded in the outbox
+	go dispatcher.Run(workersCtx)
+
+	log.Printf("Server starting on port %s", cfg.Port)
+	if err := server.Run(cfg, r); err != nil {
+		log.Fatal("Failed to start server:", err)
+	}
+}
+
+// newRouter sets up the middleware, health checks and API routes of the server
+// on top of repo. Metrics are served from registry, and alerting rules for the
+// subsystems running.
+func newRouter(cfg *config.Config, repo database.ConfigRepository, handler *handlers.Handler, registry *metrics.Registry, subsystems []alerts.Subsystem) *gin.Engine {
 	// Setup Gin router
+	gin.SetMode(cfg.GinMode)
 	r := gin.Default()
+	if err := r.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		log.Fatal("Invalid TRUSTED_PROXIES:", err)
+	}
+
+	// Request metrics
+	registry.RegisterGauge("config_manager_database_up", "Whether the database answered a ping (1) or not (0).", func() float64 {
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+		if err := repo.Ping(ctx); err != nil {
+			return 0
+		}
+		return 1
+	})
+	if pooled, ok := repo.(interface{ PoolStats() database.PoolStats }); ok {
+		registerPoolGauges(registry, pooled.PoolStats)
+	}
+	r.Use(registry.Middleware())
 
 	// CORS middleware
-	config := cors.DefaultConfig()
-	config.AllowOrigins = []string{"http://localhost:3000", "http://localhost:3001"}
-	config.AllowCredentials = true
-	config.AllowHeaders = []string{"Origin", "Content-Length", "Content-Type", "Authorization"}
-	r.Use(cors.New(config))
+	corsConfig := cors.DefaultConfig()
+	corsConfig.AllowOrigins = cfg.CORSAllowedOrigins
+	corsConfig.AllowCredentials = true
+	corsConfig.AllowHeaders = []string{"Origin", "Content-Length", "Content-Type", "Authorization", auth.APIKeyHeader, handlers.IdempotencyKeyHeader, handlers.ReadPrimaryHeader}
+	r.Use(cors.New(corsConfig))
+	handler.UseAllowedOrigins(cfg.CORSAllowedOrigins)
 
-	// Health check
-	r.GET("/health", handler.HealthCheck)
+	// Response compression, zstd or gzip as negotiated
+	if cfg.CompressionMinSize > 0 {
+		r.Use(handlers.Compress(cfg.CompressionMinSize))
+	}
+	// YAML or TOML instead of JSON for clients that ask for them
+	r.Use(handlers.Render())
 
-	// API routes
-	api := r.Group("/api")
-	{
-		// Node routes
-		nodes := api.Group("/nodes")
+	// Health checks
+	r.GET("/healthz", handler.Liveness)
+	r.GET("/readyz", handler.Readiness)
+	r.GET("/health", handler.Readiness)
+	r.GET("/metrics", registry.Handler)
+	r.GET("/.well-known/jwks.json", handler.SigningKeys)
+
+	// Network allowlists are checked before any credentials, client
+	// certificates are recorded as cert principals
+	apiMiddleware := []gin.HandlerFunc{auth.NetworkMiddleware(networkPolicy(cfg)), auth.ClientCertMiddleware(cfg.TLSClientIdentities)}
+
+	// Single sign-on, authenticated users are recorded as user and group principals
+	if cfg.OIDCEnabled() {
+		sso, err := auth.NewOIDC(context.Background(), auth.OIDCConfig{
+			IssuerURL:         cfg.OIDCIssuerURL,
+			ClientID:          cfg.OIDCClientID,
+			ClientSecret:      cfg.OIDCClientSecret,
+			RedirectURL:       cfg.OIDCRedirectURL,
+			GroupsClaim:       cfg.OIDCGroupsClaim,
+			SessionSecret:     cfg.OIDCSessionSecret,
+			SessionTTL:        cfg.OIDCSessionTTL,
+			PostLoginRedirect: cfg.OIDCPostLoginRedirect,
+			SecureCookies:     cfg.OIDCSecureCookies,
+		})
+		if err != nil {
+			log.Fatal("Failed to configure OIDC:", err)
+		}
+
+		authRoutes := r.Group("/auth")
 		{
-			nodes.POST("", handler.CreateNode)
-			nodes.GET("", handler.GetRootNodes)
-			nodes.GET("/:id", handler.GetNode)
-			nodes.GET("/:id/children", handler.GetNodeWithChildren)
-			nodes.PUT("/:id", handler.UpdateNode)
-			nodes.DELETE("/:id", handler.DeleteNode)
-			nodes.GET("/:nodeId/path", handler.GetNodePath)
-			nodes.GET("/:nodeId/resolve", handler.ResolveConfiguration)
-		}
-
-		// Property routes
-		properties := api.Group("/nodes/:nodeId/properties")
+			authRoutes.GET("/login", sso.Login)
+			authRoutes.GET("/callback", sso.Callback)
+			authRoutes.POST("/logout", sso.Logout)
+			authRoutes.GET("/me", sso.Middleware(), sso.Me)
+		}
+
+		// Two-factor authentication with an authenticator app
+		sso.EnableTOTP(repo, cfg.TOTPIssuer)
+		totpRoutes := authRoutes.Group("/totp", sso.Middleware())
 		{
-			properties.POST("", handler.CreateProperty)
-			properties.GET("", handler.GetNodeProperties)
+			totpRoutes.GET("", sso.TOTPStatus)
+			totpRoutes.POST("/enroll", sso.EnrollTOTP)
+			totpRoutes.POST("/verify", sso.VerifyTOTP)
+			totpRoutes.POST("/disable", sso.DisableTOTP)
 		}
+		apiMiddleware = append(apiMiddleware, sso.Middleware())
+	}
 
-		// Individual property routes
-		api.PUT("/properties/:propertyId", handler.UpdateProperty)
-		api.DELETE("/properties/:propertyId", handler.DeleteProperty)
+	// Requests per minute of organization quotas, counted once authenticated
+	limiter := quota.NewLimiter(repo)
+	handler.UseRequestLimiter(limiter)
 
-		// Node with properties
-		api.GET("/nodes/:nodeId/details", handler.GetNodeWithProperties)
+	// API routes
+	handlers.LimitValueSize(cfg.MaxValueSize)
+	apiMiddleware = append(apiMiddleware, auth.APIKeyMiddleware(repo, cfg.APIKeyRequired), limiter.Middleware(), handlers.SparseFields(),
+		handlers.LimitBody(int64(cfg.MaxBodySize), int64(cfg.MaxImportBodySize)), handlers.Idempotency(repo, cfg.IdempotencyKeyTTL))
+	api := r.Group("/api", apiMiddleware...)
+	registerAPIRoutes(api, handler, subsystems)
+
+	return r
+}
+
+// networkPolicy returns the networks each group of API routes can be called from
+func networkPolicy(cfg *config.Config) auth.NetworkPolicy {
+	return auth.NetworkPolicy{
+		Resolve: cfg.NetworkAllowResolve,
+		Read:    cfg.NetworkAllowRead,
+		Write:   cfg.NetworkAllowWrite,
+		Admin:   cfg.NetworkAllowAdmin,
 	}
+}
 
-	// Get port from environment or default to 8080
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080"
+// signResponses signs resolve responses with the key in
+// RESOLVE_SIGNING_KEY_FILE, if set, for agents to verify
+func signResponses(cfg *config.Config, handler *handlers.Handler) {
+	if cfg.ResolveSigningKeyFile == "" {
+		return
+	}
+	signer, err := signing.Load(cfg.ResolveSigningKeyFile, cfg.ResolveSigningKeyID)
+	if err != nil {
+		log.Fatal("Failed to load RESOLVE_SIGNING_KEY_FILE:", err)
 	}
+	handler.UseSigner(signer)
+}
 
-	log.Printf("Server starting on port %s", port)
-	if err := r.Run(":" + port); err != nil {
-		log.Fatal("Failed to start server:", err)
+// poolConfig sizes the connection pool from the configuration
+func poolConfig(cfg *config.Config) database.PoolConfig {
+	return database.PoolConfig{
+		MaxConns:        cfg.DBMaxOpenConns,
+		MaxIdleConns:    cfg.DBMaxIdleConns,
+		MaxConnIdleTime: cfg.DBConnMaxIdleTime,
+		MaxConnLifetime: cfg.DBConnMaxLifetime,
 	}
-}
\ No newline at end of file
+}
+
+// registerPoolGauges exposes the connections of the database pool
+func registerPoolGauges(registry *metrics.Registry, stats func() database.PoolStats) {
+	registry.RegisterGauge("config_manager_db_pool_max_connections", "Maximum number of open database connections.", func() float64 {
+		return float64(stats().Max)
+	})
+	registry.RegisterGauge("config_manager_db_pool_open_connections", "Open database connections, in use or idle.", func() float64 {
+		return float64(stats().Open)
+	})
+	registry.RegisterGauge("config_manager_db_pool_in_use_connections", "Database connections in use.", func() float64 {
+		return float64(stats().InUse)
+	})
+	registry.RegisterGauge("config_manager_db_pool_idle_connections", "Idle database connections.", func() float64 {
+		return float64(stats().Idle)
+	})
+}
+
+// registerSchedulerMetrics exposes how late scheduled changes are applied
+func registerSchedulerMetrics(registry *metrics.Registry, changeScheduler *scheduler.Scheduler) {
+	registry.RegisterGauge("config_manager_scheduler_lag_seconds", "How late the last scheduler run applied or left pending a due change.", func() float64 {
+		return changeScheduler.Lag().Seconds()
+	})
+	registry.RegisterGauge("config_manager_scheduler_last_run_timestamp_seconds", "When the last scheduler run ended, as a Unix timestamp.", func() float64 {
+		lastRun := changeScheduler.LastRun()
+		if lastRun.IsZero() {
+			return 0
+		}
+		return float64(lastRun.UnixNano()) / 1e9
+	})
+	registry.RegisterGauge("config_manager_scheduler_interval_seconds", "The longest time between two scheduler runs.", func() float64 {
+		return changeScheduler.Interval().Seconds()
+	})
+}
+
+// registerOutboxMetrics exposes the deliveries of change events to publishers
+func registerOutboxMetrics(registry *metrics.Registry, dispatcher *outbox.Dispatcher) {
+	registry.RegisterCounter("config_manager_outbox_deliveries_total", "Batches of change events handed to the publishers.", func() float64 {
+		return float64(dispatcher.Deliveries())
+	})
+	registry.RegisterCounter("config_manager_outbox_delivery_failures_total", "Batches of change events the publishers failed to deliver, to be retried.", func() float64 {
+		return float64(dispatcher.Failures())
+	})
+}
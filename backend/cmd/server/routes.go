@@ -0,0 +1,193 @@
+package main
+
+import (
+	"config-manager/internal/alerts"
+	"config-manager/internal/handlers"
+
+	"github.com/gin-gonic/gin"
+)
+
+// registerAPIRoutes registers all /api routes of the server, serving alerting
+// rules for subsystems
+func registerAPIRoutes(api *gin.RouterGroup, handler *handlers.Handler, subsystems []alerts.Subsystem) {
+	// Node routes
+	nodes := api.Group("/nodes")
+	{
+		nodes.POST("", handler.CreateNode)
+		nodes.GET("", handler.GetRootNodes)
+		nodes.PATCH("/reorder", handler.ReorderRootNodes)
+		nodes.GET("/:nodeId", handler.GetNode)
+		nodes.GET("/:nodeId/children", handler.GetNodeWithChildren)
+		nodes.PUT("/:nodeId", handler.UpdateNode)
+		nodes.DELETE("/:nodeId", handler.DeleteNode)
+		nodes.PATCH("/:nodeId/reorder", handler.ReorderChildren)
+		nodes.GET("/:nodeId/path", handler.GetNodePath)
+		nodes.GET("/:nodeId/resolve", handler.ResolveConfiguration)
+		nodes.GET("/:nodeId/resolve/watch", handler.WatchConfiguration)
+		nodes.POST("/:nodeId/resolve/simulate", handler.SimulateResolution)
+		nodes.POST("/:nodeId/evaluate", handler.EvaluateFlags)
+		nodes.GET("/:nodeId/scheduled-changes", handler.GetScheduledChanges)
+		nodes.POST("/:nodeId/scheduled-changes", handler.CreateScheduledChange)
+		nodes.GET("/:nodeId/drafts", handler.GetPropertyDrafts)
+		nodes.POST("/:nodeId/drafts", handler.SavePropertyDraft)
+		nodes.DELETE("/:nodeId/drafts", handler.DiscardPropertyDrafts)
+		nodes.POST("/:nodeId/drafts/publish", handler.PublishPropertyDrafts)
+		nodes.GET("/:nodeId/snapshots", handler.GetSnapshots)
+		nodes.POST("/:nodeId/snapshots", handler.CreateSnapshot)
+		nodes.GET("/:nodeId/required-keys", handler.GetRequiredKeys)
+		nodes.POST("/:nodeId/required-keys", handler.RequireKey)
+		nodes.DELETE("/:nodeId/required-keys", handler.UnrequireKey)
+		nodes.GET("/:nodeId/validate", handler.ValidateNode)
+		nodes.GET("/:nodeId/overrides", handler.GetOverrides)
+		nodes.GET("/:nodeId/keys", handler.GetKeyInventory)
+		nodes.POST("/:nodeId/impact", handler.AnalyzeImpact)
+		nodes.GET("/:nodeId/templates", handler.GetNodeTemplates)
+		nodes.PUT("/:nodeId/templates", handler.AttachTemplate)
+		nodes.DELETE("/:nodeId/templates/:templateId", handler.DetachTemplate)
+		nodes.POST("/:nodeId/replace-values", handler.ReplaceValues)
+		nodes.GET("/:nodeId/quota", handler.GetOrganizationUsage)
+	}
+
+	// Nested nodes of a subtree, or the whole tree, in one response
+	api.GET("/tree", handler.GetTree)
+
+	// Property routes
+	properties := api.Group("/nodes/:nodeId/properties")
+	{
+		properties.POST("", handler.CreateProperty)
+		properties.GET("", handler.GetNodeProperties)
+	}
+
+	// Access control routes
+	api.GET("/nodes/:nodeId/permissions", handler.GetNodePermissions)
+	api.POST("/nodes/:nodeId/permissions", handler.GrantNodePermission)
+	api.DELETE("/permissions/:id", handler.RevokeNodePermission)
+
+	// Subtree protection routes
+	api.PUT("/nodes/:nodeId/protection", handler.SetNodeProtection)
+	api.DELETE("/nodes/:nodeId/protection", handler.RemoveNodeProtection)
+
+	// Individual property routes
+	api.PUT("/properties/:propertyId", handler.UpdateProperty)
+	api.DELETE("/properties/:propertyId", handler.DeleteProperty)
+	api.POST("/properties/:propertyId/promote", handler.PromoteProperty)
+	api.POST("/properties/:propertyId/push-down", handler.PushDownProperty)
+	api.POST("/properties/:propertyId/reveal", handler.RevealProperty)
+
+	// Find properties across the tree by tag
+	api.GET("/properties", handler.SearchProperties)
+
+	// Report expired and soon expiring properties
+	api.GET("/properties/expiring", handler.GetExpiringProperties)
+
+	// Property template routes
+	templates := api.Group("/templates")
+	{
+		templates.POST("", handler.CreateTemplate)
+		templates.GET("", handler.GetTemplates)
+		templates.GET("/:id", handler.GetTemplate)
+		templates.DELETE("/:id", handler.DeleteTemplate)
+		templates.GET("/:id/versions", handler.GetTemplateVersions)
+		templates.POST("/:id/versions", handler.CreateTemplateVersion)
+		templates.GET("/:id/versions/:version", handler.GetTemplateVersion)
+	}
+
+	// Key deprecation routes
+	deprecations := api.Group("/deprecations")
+	{
+		deprecations.GET("", handler.GetKeyDeprecations)
+		deprecations.POST("", handler.DeprecateKey)
+		deprecations.DELETE("", handler.UndeprecateKey)
+		deprecations.GET("/report", handler.GetDeprecationReport)
+	}
+
+	// Key registry, and renaming a key on every node defining it
+	keys := api.Group("/keys")
+	{
+		keys.GET("", handler.GetKeyCatalog)
+		keys.POST("", handler.RegisterKey)
+		keys.GET("/:key", handler.GetRegisteredKey)
+		keys.DELETE("/:key", handler.UnregisterKey)
+		keys.POST("/:key/rename", handler.RenameKey)
+	}
+
+	// Administrative operations spanning many nodes
+	api.GET("/audit-log", handler.GetAuditLog)
+
+	// Scheduled change routes
+	api.DELETE("/scheduled-changes/:id", handler.CancelScheduledChange)
+
+	// Compare the resolved configurations of two nodes
+	api.GET("/diff", handler.DiffConfigurations)
+
+	// Replayable feed of every change, for integrators catching up
+	api.GET("/changes", handler.GetChanges)
+
+	// Live resolved configurations over WebSocket
+	api.GET("/ws", handler.Subscribe)
+
+	// Converge a subtree to a declarative document
+	api.POST("/apply", handler.ApplyDocument)
+	api.POST("/drift", handler.DetectDrift)
+
+	// Write nodes and properties together in one transaction
+	api.POST("/batch", handler.ApplyBatch)
+
+	// Scan the tree for redundant or inconsistent properties
+	api.GET("/lint", handler.LintTree)
+
+	// Snapshot routes
+	api.GET("/snapshots/:id", handler.GetSnapshot)
+	api.GET("/snapshots/:id/compare", handler.CompareSnapshot)
+
+	// Node with properties
+	api.GET("/nodes/:nodeId/details", handler.GetNodeWithProperties)
+
+	// Workspace routes
+	workspaces := api.Group("/workspaces")
+	{
+		workspaces.POST("", handler.CreateWorkspace)
+		workspaces.GET("", handler.GetWorkspaces)
+		workspaces.GET("/:id", handler.GetWorkspace)
+		workspaces.DELETE("/:id", handler.DiscardWorkspace)
+		workspaces.POST("/:id/changes", handler.AddWorkspaceChange)
+		workspaces.GET("/:id/nodes/:nodeId/resolve", handler.ResolveInWorkspace)
+		workspaces.POST("/:id/merge", handler.MergeWorkspace)
+	}
+
+	// Change request routes
+	changeRequests := api.Group("/change-requests")
+	{
+		changeRequests.POST("", handler.CreateChangeRequest)
+		changeRequests.GET("", handler.GetChangeRequests)
+		changeRequests.GET("/:id", handler.GetChangeRequest)
+		changeRequests.POST("/:id/reviews", handler.ReviewChangeRequest)
+		changeRequests.POST("/:id/apply", handler.ApplyChangeRequest)
+		changeRequests.POST("/:id/withdraw", handler.WithdrawChangeRequest)
+	}
+
+	// Admin routes
+	admin := api.Group("/admin")
+	{
+		admin.POST("/rebuild", handler.RebuildDerivedData)
+		admin.GET("/stats", handler.GetStats)
+		admin.GET("/sync/ssm/drift", handler.GetSSMDrift)
+		admin.GET("/encryption-keys", handler.GetEncryptionKeys)
+		admin.POST("/encryption-keys", handler.CreateEncryptionKey)
+		admin.POST("/encryption-keys/:id/rotate", handler.RotateEncryptionKey)
+		admin.GET("/key-rotations", handler.GetKeyRotations)
+		admin.GET("/key-rotations/:id", handler.GetKeyRotation)
+		admin.DELETE("/key-rotations/:id", handler.CancelKeyRotation)
+		admin.POST("/key-rotations/:id/resume", handler.ResumeKeyRotation)
+		admin.GET("/alerting-rules", alerts.Handler(subsystems...))
+		admin.GET("/api-keys", handler.GetAPIKeys)
+		admin.POST("/api-keys", handler.CreateAPIKey)
+		admin.DELETE("/api-keys/:id", handler.RevokeAPIKey)
+		admin.POST("/api-keys/:id/rotate", handler.RotateAPIKey)
+		admin.DELETE("/totp/:subject", handler.ResetTOTP)
+		admin.GET("/protected-subtrees", handler.GetNodeProtections)
+		admin.GET("/quotas", handler.GetQuotas)
+		admin.PUT("/quotas/:nodeId", handler.SetQuota)
+		admin.DELETE("/quotas/:nodeId", handler.DeleteQuota)
+	}
+}
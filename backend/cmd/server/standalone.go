@@ -0,0 +1,56 @@
+package main
+
+import (
+	"config-manager/internal/alerts"
+	"config-manager/internal/auth"
+	"config-manager/internal/config"
+	"config-manager/internal/database"
+	"config-manager/internal/handlers"
+	"config-manager/internal/metrics"
+	"config-manager/internal/server"
+	"log"
+)
+
+// runDemo serves the API on an in-memory repository, to try the server out
+// without a database. Configuration is lost on restart.
+func runDemo(cfg *config.Config) {
+	log.Println("DATABASE_URL not set, running in demo mode: configuration is kept in memory and lost on restart")
+	runStandalone(cfg, database.NewMemoryRepository(), "in demo mode")
+}
+
+// runCore serves the API on the SQLite or MySQL database at DATABASE_URL, for
+// development and installs without a Postgres server
+func runCore(cfg *config.Config) {
+	db, err := database.OpenDriver(cfg.DatabaseDriver, cfg.DatabaseURL, poolConfig(cfg))
+	if err != nil {
+		log.Fatal("Failed to connect to database:", err)
+	}
+	defer db.Close()
+	db.SetQueryTimeout(cfg.DBQueryTimeout)
+
+	if err := db.RunMigrations(); err != nil {
+		log.Fatal("Failed to run migrations:", err)
+	}
+
+	log.Printf("Using %s: features beyond the tree and its properties, such as drafts, history and encryption, are not available", cfg.DatabaseDriver)
+	runStandalone(cfg, database.NewCoreRepository(db), "on "+cfg.DatabaseDriver)
+}
+
+// runStandalone serves the API on a repository that only keeps the tree and its
+// properties. The background workers and integrations, which need the full
+// Postgres schema, are not started.
+func runStandalone(cfg *config.Config, repo database.ConfigRepository, mode string) {
+	if len(cfg.KafkaBrokers) > 0 || cfg.NATSURL != "" || cfg.K8sSyncBindings != "" || cfg.ConsulSyncBindings != "" || cfg.SSMSyncBindings != "" || cfg.GitOpsRepoURL != "" || cfg.AuditExportURL != "" {
+		log.Fatal("Event publishing, audit export, configuration sync and GitOps require a PostgreSQL database")
+	}
+
+	// Without change events, watch requests poll for changes
+	handler := handlers.NewHandler(repo, auth.NewACL(repo, cfg.ACLEnforced, cfg.ACLAdmins, cfg.AdminTOTPRequired), cfg.DeleteGuardWindow, nil, cfg.RequireRegisteredKeys)
+	signResponses(cfg, handler)
+	r := newRouter(cfg, repo, handler, metrics.NewRegistry(), []alerts.Subsystem{alerts.SubsystemAPI, alerts.SubsystemDatabase})
+
+	log.Printf("Server starting %s on port %s", mode, cfg.Port)
+	if err := server.Run(cfg, r); err != nil {
+		log.Fatal("Failed to start server:", err)
+	}
+}
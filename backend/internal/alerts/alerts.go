@@ -0,0 +1,164 @@
+package alerts
+
+import (
+	"config-manager/internal/problem"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gopkg.in/yaml.v3"
+)
+
+// Subsystem identifies a part of the server that contributes alerting rules
+type Subsystem string
+
+const (
+	SubsystemAPI       Subsystem = "api"
+	SubsystemDatabase  Subsystem = "database"
+	SubsystemOutbox    Subsystem = "outbox"
+	SubsystemScheduler Subsystem = "scheduler"
+)
+
+// Rule is a single Prometheus alerting rule
+type Rule struct {
+	Alert       string            `yaml:"alert"`
+	Expr        string            `yaml:"expr"`
+	For         string            `yaml:"for,omitempty"`
+	Labels      map[string]string `yaml:"labels,omitempty"`
+	Annotations map[string]string `yaml:"annotations,omitempty"`
+}
+
+// RuleGroup is a named group of rules evaluated together
+type RuleGroup struct {
+	Name  string `yaml:"name"`
+	Rules []Rule `yaml:"rules"`
+}
+
+// RuleFile is the top-level structure of a Prometheus rules file
+type RuleFile struct {
+	Groups []RuleGroup `yaml:"groups"`
+}
+
+// catalog holds the recommended rules of every subsystem, built against the
+// metrics exposed on /metrics
+var catalog = map[Subsystem][]Rule{
+	SubsystemAPI: {
+		{
+			Alert: "ConfigManagerResolveErrorRate",
+			Expr: `sum(rate(config_manager_http_requests_total{route="/api/nodes/:nodeId/resolve",status=~"5.."}[5m]))
+  / sum(rate(config_manager_http_requests_total{route="/api/nodes/:nodeId/resolve"}[5m])) > 0.05`,
+			For:    "5m",
+			Labels: map[string]string{"severity": "critical"},
+			Annotations: map[string]string{
+				"summary": "More than 5% of configuration resolutions are failing",
+			},
+		},
+		{
+			Alert: "ConfigManagerHighErrorRate",
+			Expr: `sum(rate(config_manager_http_requests_total{status=~"5.."}[5m]))
+  / sum(rate(config_manager_http_requests_total[5m])) > 0.05`,
+			For:    "10m",
+			Labels: map[string]string{"severity": "warning"},
+			Annotations: map[string]string{
+				"summary": "More than 5% of API requests are failing",
+			},
+		},
+		{
+			Alert: "ConfigManagerSlowResolve",
+			Expr: `sum(rate(config_manager_http_request_duration_seconds_sum{route="/api/nodes/:nodeId/resolve"}[5m]))
+  / sum(rate(config_manager_http_request_duration_seconds_count{route="/api/nodes/:nodeId/resolve"}[5m])) > 0.5`,
+			For:    "10m",
+			Labels: map[string]string{"severity": "warning"},
+			Annotations: map[string]string{
+				"summary": "Average configuration resolution latency is above 500ms",
+			},
+		},
+	},
+	SubsystemDatabase: {
+		{
+			Alert:  "ConfigManagerDatabaseDown",
+			Expr:   `config_manager_database_up == 0`,
+			For:    "1m",
+			Labels: map[string]string{"severity": "critical"},
+			Annotations: map[string]string{
+				"summary": "The configuration database is unreachable",
+			},
+		},
+	},
+	SubsystemOutbox: {
+		{
+			Alert: "ConfigManagerEventDeliveryFailureRate",
+			Expr: `sum(rate(config_manager_outbox_delivery_failures_total[5m]))
+  / sum(rate(config_manager_outbox_deliveries_total[5m])) > 0.1`,
+			For:    "10m",
+			Labels: map[string]string{"severity": "warning"},
+			Annotations: map[string]string{
+				"summary": "More than 10% of change event deliveries to brokers, the SIEM and sync targets are failing",
+			},
+		},
+		{
+			Alert: "ConfigManagerEventDeliveryStalled",
+			Expr: `sum(increase(config_manager_outbox_deliveries_total[15m])) > 0
+  and sum(increase(config_manager_outbox_delivery_failures_total[15m])) >= sum(increase(config_manager_outbox_deliveries_total[15m]))`,
+			Labels: map[string]string{"severity": "critical"},
+			Annotations: map[string]string{
+				"summary": "No change event was delivered for 15 minutes, every attempt failed",
+			},
+		},
+	},
+	// Thresholds follow the SCHEDULER_INTERVAL each server exposes
+	SubsystemScheduler: {
+		{
+			Alert:  "ConfigManagerSchedulerLag",
+			Expr:   `config_manager_scheduler_lag_seconds > 2 * config_manager_scheduler_interval_seconds + 60`,
+			For:    "5m",
+			Labels: map[string]string{"severity": "warning"},
+			Annotations: map[string]string{
+				"summary": "Scheduled property changes are applied late",
+			},
+		},
+		{
+			Alert:  "ConfigManagerSchedulerStalled",
+			Expr:   `time() - config_manager_scheduler_last_run_timestamp_seconds > 3 * config_manager_scheduler_interval_seconds + 60`,
+			For:    "5m",
+			Labels: map[string]string{"severity": "critical"},
+			Annotations: map[string]string{
+				"summary": "The scheduler has not run for several intervals",
+			},
+		},
+	},
+}
+
+// Rules builds the rules file for the enabled subsystems, one group per subsystem
+func Rules(enabled []Subsystem) RuleFile {
+	file := RuleFile{Groups: []RuleGroup{}}
+	for _, subsystem := range enabled {
+		rules, ok := catalog[subsystem]
+		if !ok {
+			continue
+		}
+		file.Groups = append(file.Groups, RuleGroup{
+			Name:  "config-manager-" + string(subsystem),
+			Rules: rules,
+		})
+	}
+	return file
+}
+
+// YAML renders the rules file in the format Prometheus loads from rule_files
+func (f RuleFile) YAML() ([]byte, error) {
+	return yaml.Marshal(f)
+}
+
+// Handler serves the rules file for the enabled subsystems so it can be
+// scraped and loaded by Prometheus
+func Handler(enabled ...Subsystem) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		body, err := Rules(enabled).YAML()
+		if err != nil {
+			problem.Respond(c, http.StatusInternalServerError, problem.CodeInternal, "Failed to render alerting rules")
+			return
+		}
+
+		c.Data(http.StatusOK, "application/yaml", body)
+	}
+}
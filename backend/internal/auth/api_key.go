@@ -0,0 +1,98 @@
+package auth
+
+import (
+	"config-manager/internal/database"
+	"config-manager/internal/models"
+	"config-manager/internal/problem"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// APIKeyHeader is the header machine consumers send their key in
+const APIKeyHeader = "X-API-Key"
+
+// apiKeyContextKey is the gin context key holding the authenticated *models.APIKey
+const apiKeyContextKey = "api_key"
+
+// resolvePathSuffixes identify the read endpoints a resolve-only key may call
+var resolvePathSuffixes = []string{"/resolve", "/resolve/watch", "/path"}
+
+// evaluateRoute is read-only despite being a POST, as the context is too
+// large for a query string
+const evaluateRoute = "/api/nodes/:nodeId/evaluate"
+
+// subscribeRoute streams resolved configurations over WebSocket, the push
+// counterpart of /resolve
+const subscribeRoute = "/api/ws"
+
+// RequiredScope returns the scope needed to call the given method and route
+func RequiredScope(method, route string) models.APIKeyScope {
+	if route == evaluateRoute {
+		return models.APIKeyScopeResolve
+	}
+	if route == subscribeRoute && method == http.MethodGet {
+		return models.APIKeyScopeResolve
+	}
+	if method != http.MethodGet && method != http.MethodHead {
+		return models.APIKeyScopeWrite
+	}
+	if strings.HasPrefix(route, "/api/admin") {
+		return models.APIKeyScopeWrite
+	}
+	for _, suffix := range resolvePathSuffixes {
+		if strings.HasPrefix(route, "/api/nodes/") && strings.HasSuffix(route, suffix) {
+			return models.APIKeyScopeResolve
+		}
+	}
+	return models.APIKeyScopeRead
+}
+
+// APIKeyMiddleware authenticates requests carrying an X-API-Key header and checks
+// that one of the key's scopes allows the route. Requests without the header are
+// rejected only when required is true and no earlier middleware authenticated them.
+func APIKeyMiddleware(repo database.ConfigRepository, required bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		secret := c.GetHeader(APIKeyHeader)
+		if secret == "" {
+			if required && len(Principals(c)) == 0 {
+				problem.Abort(c, http.StatusUnauthorized, problem.CodeUnauthenticated, "API key required")
+				return
+			}
+			c.Next()
+			return
+		}
+
+		key, err := repo.AuthenticateAPIKey(c.Request.Context(), secret)
+		if err != nil {
+			problem.Abort(c, http.StatusInternalServerError, problem.CodeInternal, "Failed to authenticate API key")
+			return
+		}
+		if key == nil {
+			problem.Abort(c, http.StatusUnauthorized, problem.CodeUnauthenticated, "Invalid API key")
+			return
+		}
+
+		requiredScope := RequiredScope(c.Request.Method, c.FullPath())
+		for _, scope := range key.Scopes {
+			if scope.Allows(requiredScope) {
+				c.Set(apiKeyContextKey, key)
+				AddPrincipals(c, fmt.Sprintf("apikey:%d", key.ID))
+				c.Next()
+				return
+			}
+		}
+
+		problem.Abort(c, http.StatusForbidden, problem.CodePermissionDenied, "API key does not have the "+string(requiredScope)+" scope")
+	}
+}
+
+// APIKeyFromContext returns the API key that authenticated the request, if any
+func APIKeyFromContext(c *gin.Context) *models.APIKey {
+	if value, ok := c.Get(apiKeyContextKey); ok {
+		return value.(*models.APIKey)
+	}
+	return nil
+}
@@ -0,0 +1,33 @@
+package auth
+
+import (
+	"config-manager/internal/models"
+	"net/http"
+	"testing"
+)
+
+func TestRequiredScope(t *testing.T) {
+	tests := []struct {
+		method string
+		route  string
+		want   models.APIKeyScope
+	}{
+		{http.MethodGet, "/api/nodes/:nodeId/resolve", models.APIKeyScopeResolve},
+		{http.MethodGet, "/api/nodes/:nodeId/resolve/watch", models.APIKeyScopeResolve},
+		{http.MethodGet, "/api/nodes/:nodeId/path", models.APIKeyScopeResolve},
+		{http.MethodGet, "/api/ws", models.APIKeyScopeResolve},
+		{http.MethodPost, "/api/nodes/:nodeId/evaluate", models.APIKeyScopeResolve},
+		{http.MethodHead, "/api/nodes/:nodeId/resolve", models.APIKeyScopeResolve},
+		{http.MethodGet, "/api/nodes/:nodeId", models.APIKeyScopeRead},
+		{http.MethodGet, "/api/nodes/:nodeId/properties", models.APIKeyScopeRead},
+		{http.MethodPost, "/api/nodes/:nodeId/resolve/simulate", models.APIKeyScopeWrite},
+		{http.MethodPost, "/api/nodes", models.APIKeyScopeWrite},
+		{http.MethodGet, "/api/admin/stats", models.APIKeyScopeWrite},
+	}
+
+	for _, tt := range tests {
+		if got := RequiredScope(tt.method, tt.route); got != tt.want {
+			t.Errorf("RequiredScope(%s, %s) = %s, want %s", tt.method, tt.route, got, tt.want)
+		}
+	}
+}
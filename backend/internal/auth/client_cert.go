@@ -0,0 +1,55 @@
+package auth
+
+import (
+	"config-manager/internal/models"
+	"config-manager/internal/problem"
+	"crypto/x509"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ClientCertMiddleware authenticates requests by the verified TLS client
+// certificate of their connection. The identities of the certificate, its
+// common name and its DNS, URI and email subject alternative names, are looked
+// up in identities in that order, and the first one found is granted its
+// scope, resolve, read or write, as an API key would be, as the principal
+// cert:<identity>. Requests without a
+// certificate, or with one of no known identity, are left to the other
+// authentication methods.
+func ClientCertMiddleware(identities map[string]string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Request.TLS == nil || len(c.Request.TLS.VerifiedChains) == 0 {
+			c.Next()
+			return
+		}
+
+		for _, identity := range certIdentities(c.Request.TLS.VerifiedChains[0][0]) {
+			scope, ok := identities[identity]
+			if !ok {
+				continue
+			}
+			requiredScope := RequiredScope(c.Request.Method, c.FullPath())
+			if !models.APIKeyScope(scope).Allows(requiredScope) {
+				problem.Abort(c, http.StatusForbidden, problem.CodePermissionDenied, "Client certificate "+identity+" does not have the "+string(requiredScope)+" scope")
+				return
+			}
+			AddPrincipals(c, "cert:"+identity)
+			break
+		}
+		c.Next()
+	}
+}
+
+// certIdentities lists the names a certificate was issued to
+func certIdentities(cert *x509.Certificate) []string {
+	var identities []string
+	if cert.Subject.CommonName != "" {
+		identities = append(identities, cert.Subject.CommonName)
+	}
+	identities = append(identities, cert.DNSNames...)
+	for _, uri := range cert.URIs {
+		identities = append(identities, uri.String())
+	}
+	return append(identities, cert.EmailAddresses...)
+}
@@ -0,0 +1,59 @@
+package auth
+
+import (
+	"config-manager/internal/models"
+	"config-manager/internal/problem"
+	"net/http"
+	"net/netip"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// NetworkPolicy restricts the client networks each group of routes can be
+// called from. Resolve, read and write routes are those of the API key scopes,
+// see RequiredScope, except admin routes, which form their own group. Groups
+// without networks can be called from anywhere.
+type NetworkPolicy struct {
+	Resolve []netip.Prefix
+	Read    []netip.Prefix
+	Write   []netip.Prefix
+	Admin   []netip.Prefix
+}
+
+// networks returns the networks allowed to call method on route
+func (p NetworkPolicy) networks(method, route string) []netip.Prefix {
+	if strings.HasPrefix(route, "/api/admin") {
+		return p.Admin
+	}
+	switch RequiredScope(method, route) {
+	case models.APIKeyScopeResolve:
+		return p.Resolve
+	case models.APIKeyScopeRead:
+		return p.Read
+	}
+	return p.Write
+}
+
+// NetworkMiddleware rejects requests from client addresses outside the
+// networks of their route group with 403. The client address is that of the
+// connection unless it comes from a trusted proxy.
+func NetworkMiddleware(policy NetworkPolicy) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		networks := policy.networks(c.Request.Method, c.FullPath())
+		if len(networks) == 0 {
+			c.Next()
+			return
+		}
+
+		if addr, err := netip.ParseAddr(c.ClientIP()); err == nil {
+			for _, network := range networks {
+				if network.Contains(addr.Unmap()) {
+					c.Next()
+					return
+				}
+			}
+		}
+		problem.Abort(c, http.StatusForbidden, problem.CodeNetworkNotAllowed, "This endpoint cannot be called from your network")
+	}
+}
@@ -0,0 +1,277 @@
+package auth
+
+import (
+	"config-manager/internal/problem"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/gin-gonic/gin"
+	"golang.org/x/oauth2"
+)
+
+const (
+	sessionCookie = "cm_session"
+	stateCookie   = "cm_oidc_state"
+)
+
+// OIDCConfig holds the settings of the OpenID Connect login flow
+type OIDCConfig struct {
+	IssuerURL         string
+	ClientID          string
+	ClientSecret      string
+	RedirectURL       string
+	GroupsClaim       string
+	SessionSecret     string
+	SessionTTL        time.Duration
+	PostLoginRedirect string
+	SecureCookies     bool
+}
+
+// Session identifies a user authenticated against the identity provider
+type Session struct {
+	Subject   string   `json:"sub"`
+	Email     string   `json:"email,omitempty"`
+	Name      string   `json:"name,omitempty"`
+	Groups    []string `json:"groups"`
+	ExpiresAt int64    `json:"exp"`
+
+	// TOTPVerified is set once the user verifies a code of their authenticator app
+	TOTPVerified bool `json:"totp_verified,omitempty"`
+}
+
+// Principals returns the ACL principals of the session: the user and each group
+func (s *Session) Principals() []string {
+	principals := []string{"user:" + s.Subject}
+	if s.Email != "" {
+		principals = append(principals, "user:"+s.Email)
+	}
+	for _, group := range s.Groups {
+		principals = append(principals, "group:"+group)
+	}
+	return principals
+}
+
+// sessionContextKey is the gin context key holding the authenticated *Session
+const sessionContextKey = "session"
+
+// OIDC authenticates users against an OpenID Connect identity provider using the
+// authorization code flow, and keeps them signed in with an HMAC-signed session cookie
+type OIDC struct {
+	cfg      OIDCConfig
+	verifier *oidc.IDTokenVerifier
+	oauth    oauth2.Config
+	totp     *totp
+}
+
+// NewOIDC discovers the provider configuration from the issuer
+func NewOIDC(ctx context.Context, cfg OIDCConfig) (*OIDC, error) {
+	if cfg.ClientID == "" || cfg.RedirectURL == "" {
+		return nil, errors.New("OIDC client ID and redirect URL are required")
+	}
+	if len(cfg.SessionSecret) < 32 {
+		return nil, errors.New("OIDC session secret must be at least 32 characters")
+	}
+
+	provider, err := oidc.NewProvider(ctx, cfg.IssuerURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to discover OIDC provider: %w", err)
+	}
+
+	return &OIDC{
+		cfg:      cfg,
+		verifier: provider.Verifier(&oidc.Config{ClientID: cfg.ClientID}),
+		oauth: oauth2.Config{
+			ClientID:     cfg.ClientID,
+			ClientSecret: cfg.ClientSecret,
+			RedirectURL:  cfg.RedirectURL,
+			Endpoint:     provider.Endpoint(),
+			Scopes:       []string{oidc.ScopeOpenID, "profile", "email", "groups"},
+		},
+	}, nil
+}
+
+// Login redirects the user to the identity provider
+func (o *OIDC) Login(c *gin.Context) {
+	state, err := randomToken()
+	if err != nil {
+		problem.Respond(c, http.StatusInternalServerError, problem.CodeInternal, "Failed to start login")
+		return
+	}
+
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(stateCookie, state, 600, "/", "", o.cfg.SecureCookies, true)
+	c.Redirect(http.StatusFound, o.oauth.AuthCodeURL(state))
+}
+
+// Callback completes the authorization code flow and starts a session
+func (o *OIDC) Callback(c *gin.Context) {
+	state, err := c.Cookie(stateCookie)
+	if err != nil || state == "" || c.Query("state") != state {
+		problem.Respond(c, http.StatusBadRequest, problem.CodeInvalidRequest, "Invalid login state")
+		return
+	}
+	c.SetCookie(stateCookie, "", -1, "/", "", o.cfg.SecureCookies, true)
+
+	if errParam := c.Query("error"); errParam != "" {
+		problem.Respond(c, http.StatusUnauthorized, problem.CodeUnauthenticated, "Login failed: "+errParam)
+		return
+	}
+
+	token, err := o.oauth.Exchange(c.Request.Context(), c.Query("code"))
+	if err != nil {
+		problem.Respond(c, http.StatusUnauthorized, problem.CodeUnauthenticated, "Failed to exchange authorization code")
+		return
+	}
+
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok {
+		problem.Respond(c, http.StatusUnauthorized, problem.CodeUnauthenticated, "Identity provider did not return an ID token")
+		return
+	}
+
+	session, err := o.sessionFromIDToken(c.Request.Context(), rawIDToken)
+	if err != nil {
+		problem.Respond(c, http.StatusUnauthorized, problem.CodeUnauthenticated, "Invalid ID token")
+		return
+	}
+
+	value, err := o.encodeSession(session)
+	if err != nil {
+		problem.Respond(c, http.StatusInternalServerError, problem.CodeInternal, "Failed to create session")
+		return
+	}
+
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(sessionCookie, value, int(o.cfg.SessionTTL.Seconds()), "/", "", o.cfg.SecureCookies, true)
+	c.Redirect(http.StatusFound, o.cfg.PostLoginRedirect)
+}
+
+// Logout ends the session
+func (o *OIDC) Logout(c *gin.Context) {
+	c.SetCookie(sessionCookie, "", -1, "/", "", o.cfg.SecureCookies, true)
+	c.JSON(http.StatusNoContent, nil)
+}
+
+// Me returns the signed-in user
+func (o *OIDC) Me(c *gin.Context) {
+	session := SessionFromContext(c)
+	if session == nil {
+		problem.Respond(c, http.StatusUnauthorized, problem.CodeUnauthenticated, "Not signed in")
+		return
+	}
+	c.JSON(http.StatusOK, session)
+}
+
+// Middleware authenticates requests carrying a session cookie, or an ID token
+// issued by the provider in an Authorization: Bearer header, and records the
+// user and group principals. Unauthenticated requests pass through.
+func (o *OIDC) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var session *Session
+
+		if value, err := c.Cookie(sessionCookie); err == nil && value != "" {
+			session, _ = o.decodeSession(value)
+		} else if header := c.GetHeader("Authorization"); strings.HasPrefix(header, "Bearer ") {
+			session, _ = o.sessionFromIDToken(c.Request.Context(), strings.TrimPrefix(header, "Bearer "))
+		}
+
+		if session != nil {
+			c.Set(sessionContextKey, session)
+			AddPrincipals(c, session.Principals()...)
+		}
+		c.Next()
+	}
+}
+
+// SessionFromContext returns the user session that authenticated the request, if any
+func SessionFromContext(c *gin.Context) *Session {
+	if value, ok := c.Get(sessionContextKey); ok {
+		return value.(*Session)
+	}
+	return nil
+}
+
+func (o *OIDC) sessionFromIDToken(ctx context.Context, rawIDToken string) (*Session, error) {
+	idToken, err := o.verifier.Verify(ctx, rawIDToken)
+	if err != nil {
+		return nil, err
+	}
+
+	var claims map[string]interface{}
+	if err := idToken.Claims(&claims); err != nil {
+		return nil, err
+	}
+
+	session := &Session{
+		Subject:   idToken.Subject,
+		Groups:    []string{},
+		ExpiresAt: time.Now().Add(o.cfg.SessionTTL).Unix(),
+	}
+	session.Email, _ = claims["email"].(string)
+	session.Name, _ = claims["name"].(string)
+	if groups, ok := claims[o.cfg.GroupsClaim].([]interface{}); ok {
+		for _, group := range groups {
+			if name, ok := group.(string); ok {
+				session.Groups = append(session.Groups, name)
+			}
+		}
+	}
+
+	return session, nil
+}
+
+// encodeSession serializes a session as base64(json).base64(hmac)
+func (o *OIDC) encodeSession(session *Session) (string, error) {
+	payload, err := json.Marshal(session)
+	if err != nil {
+		return "", err
+	}
+
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	return encoded + "." + o.sign(encoded), nil
+}
+
+func (o *OIDC) decodeSession(value string) (*Session, error) {
+	encoded, signature, ok := strings.Cut(value, ".")
+	if !ok || !hmac.Equal([]byte(signature), []byte(o.sign(encoded))) {
+		return nil, errors.New("invalid session signature")
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, err
+	}
+
+	var session Session
+	if err := json.Unmarshal(payload, &session); err != nil {
+		return nil, err
+	}
+	if time.Now().Unix() > session.ExpiresAt {
+		return nil, errors.New("session expired")
+	}
+	return &session, nil
+}
+
+func (o *OIDC) sign(encoded string) string {
+	mac := hmac.New(sha256.New, []byte(o.cfg.SessionSecret))
+	mac.Write([]byte(encoded))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func randomToken() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,325 @@
+package auth
+
+import (
+	"config-manager/internal/database"
+	"config-manager/internal/models"
+	"config-manager/internal/problem"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base32"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TOTP parameters of RFC 6238, which every authenticator app supports
+const (
+	totpPeriod = 30 * time.Second
+	totpDigits = 6
+	totpSkew   = 1 // Steps of clock drift accepted either way
+)
+
+// A user who enters too many wrong codes in a row is locked out for a while,
+// as six digits are otherwise quick to guess
+const (
+	maxTOTPFailures = 5
+	totpLockout     = 5 * time.Minute
+)
+
+var totpEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// totpFailures counts the consecutive wrong codes of a user
+type totpFailures struct {
+	count       int
+	lockedUntil time.Time
+}
+
+// totp holds the state of two-factor authentication
+type totp struct {
+	repo   database.ConfigRepository
+	issuer string
+
+	mu       sync.Mutex
+	failures map[string]*totpFailures
+}
+
+// EnableTOTP lets signed-in users enroll an authenticator app, whose secrets are
+// kept in repo. Issuer names the service in the app.
+func (o *OIDC) EnableTOTP(repo database.ConfigRepository, issuer string) {
+	o.totp = &totp{repo: repo, issuer: issuer, failures: make(map[string]*totpFailures)}
+}
+
+// TOTPStatus reports whether the signed-in user enrolled an authenticator app
+// and verified a code in this session
+func (o *OIDC) TOTPStatus(c *gin.Context) {
+	session := o.totpSession(c)
+	if session == nil {
+		return
+	}
+
+	enrollment, err := o.totp.repo.GetTOTPEnrollment(c.Request.Context(), session.Subject)
+	if err != nil {
+		respondTOTPError(c, err, "Failed to load two-factor authentication")
+		return
+	}
+
+	status := models.TOTPStatus{Verified: session.TOTPVerified}
+	if enrollment != nil {
+		status.Enrolled = enrollment.ConfirmedAt != nil
+		status.Pending = enrollment.ConfirmedAt == nil
+		status.ConfirmedAt = enrollment.ConfirmedAt
+	}
+	c.JSON(http.StatusOK, status)
+}
+
+// EnrollTOTP generates the secret of a new authenticator app. The enrollment
+// is pending until the user verifies a first code; enrolling again before then
+// replaces the secret.
+func (o *OIDC) EnrollTOTP(c *gin.Context) {
+	session := o.totpSession(c)
+	if session == nil {
+		return
+	}
+
+	random := make([]byte, 20)
+	if _, err := rand.Read(random); err != nil {
+		problem.Respond(c, http.StatusInternalServerError, problem.CodeInternal, "Failed to generate secret")
+		return
+	}
+	secret := totpEncoding.EncodeToString(random)
+
+	if _, err := o.totp.repo.EnrollTOTP(c.Request.Context(), session.Subject, secret); err != nil {
+		respondTOTPError(c, err, "Failed to enroll authenticator app")
+		return
+	}
+
+	account := session.Email
+	if account == "" {
+		account = session.Subject
+	}
+	c.JSON(http.StatusCreated, models.TOTPSecret{Secret: secret, URI: o.totp.uri(account, secret)})
+}
+
+// VerifyTOTP checks a code of the user's authenticator app, confirming a
+// pending enrollment, and marks the session as verified
+func (o *OIDC) VerifyTOTP(c *gin.Context) {
+	session := o.totpSession(c)
+	if session == nil {
+		return
+	}
+
+	var req models.TOTPCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		problem.Respond(c, http.StatusBadRequest, problem.CodeInvalidRequest, err.Error())
+		return
+	}
+
+	enrollment, ok := o.checkTOTPCode(c, session, req.Code)
+	if !ok {
+		return
+	}
+
+	session.TOTPVerified = true
+	if !o.refreshSession(c, session) {
+		return
+	}
+
+	now := time.Now()
+	if enrollment.ConfirmedAt == nil {
+		enrollment.ConfirmedAt = &now
+	}
+	c.JSON(http.StatusOK, models.TOTPStatus{Enrolled: true, Verified: true, ConfirmedAt: enrollment.ConfirmedAt})
+}
+
+// DisableTOTP removes the user's authenticator app. A current code is required,
+// so that a stolen session cannot turn two-factor authentication off.
+func (o *OIDC) DisableTOTP(c *gin.Context) {
+	session := o.totpSession(c)
+	if session == nil {
+		return
+	}
+
+	var req models.TOTPCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		problem.Respond(c, http.StatusBadRequest, problem.CodeInvalidRequest, err.Error())
+		return
+	}
+
+	if _, ok := o.checkTOTPCode(c, session, req.Code); !ok {
+		return
+	}
+
+	if err := o.totp.repo.DeleteTOTPEnrollment(c.Request.Context(), session.Subject); err != nil {
+		respondTOTPError(c, err, "Failed to remove authenticator app")
+		return
+	}
+
+	session.TOTPVerified = false
+	if !o.refreshSession(c, session) {
+		return
+	}
+	c.JSON(http.StatusNoContent, nil)
+}
+
+// totpSession returns the session of a signed-in user, or writes an error
+// response and returns nil
+func (o *OIDC) totpSession(c *gin.Context) *Session {
+	session := SessionFromContext(c)
+	if session == nil {
+		problem.Respond(c, http.StatusUnauthorized, problem.CodeUnauthenticated, "Not signed in")
+		return nil
+	}
+	return session
+}
+
+// checkTOTPCode returns the user's enrollment if code is valid and was not
+// used before, or writes an error response and returns false
+func (o *OIDC) checkTOTPCode(c *gin.Context, session *Session, code string) (*models.TOTPEnrollment, bool) {
+	if !o.totp.allowAttempt(session.Subject) {
+		problem.Respond(c, http.StatusTooManyRequests, problem.CodeTooManyAttempts, "Too many invalid codes, try again later")
+		return nil, false
+	}
+
+	enrollment, err := o.totp.repo.GetTOTPEnrollment(c.Request.Context(), session.Subject)
+	if err != nil {
+		respondTOTPError(c, err, "Failed to load two-factor authentication")
+		return nil, false
+	}
+	if enrollment == nil {
+		respondTOTPError(c, database.ErrTOTPNotEnrolled, "")
+		return nil, false
+	}
+
+	step, ok := matchTOTP(enrollment.Secret, code, time.Now())
+	if ok {
+		if ok, err = o.totp.repo.UseTOTPStep(c.Request.Context(), session.Subject, step); err != nil {
+			respondTOTPError(c, err, "Failed to verify code")
+			return nil, false
+		}
+	}
+	if !ok {
+		o.totp.recordFailure(session.Subject)
+		problem.Respond(c, http.StatusBadRequest, problem.CodeInvalidValue, "Invalid verification code")
+		return nil, false
+	}
+
+	o.totp.resetFailures(session.Subject)
+	return enrollment, true
+}
+
+// refreshSession stores a changed session in the session cookie, or writes an
+// error response and returns false. Sessions authenticated by an ID token have
+// no cookie, and their changes last for the request only.
+func (o *OIDC) refreshSession(c *gin.Context, session *Session) bool {
+	if value, err := c.Cookie(sessionCookie); err != nil || value == "" {
+		return true
+	}
+
+	value, err := o.encodeSession(session)
+	if err != nil {
+		problem.Respond(c, http.StatusInternalServerError, problem.CodeInternal, "Failed to update session")
+		return false
+	}
+
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(sessionCookie, value, int(time.Until(time.Unix(session.ExpiresAt, 0)).Seconds()), "/", "", o.cfg.SecureCookies, true)
+	return true
+}
+
+// uri returns the otpauth:// URI authenticator apps import the secret from
+func (t *totp) uri(account, secret string) string {
+	params := url.Values{}
+	params.Set("secret", secret)
+	params.Set("issuer", t.issuer)
+	params.Set("algorithm", "SHA1")
+	params.Set("digits", fmt.Sprint(totpDigits))
+	params.Set("period", fmt.Sprint(int(totpPeriod.Seconds())))
+	return "otpauth://totp/" + url.PathEscape(t.issuer+":"+account) + "?" + params.Encode()
+}
+
+// allowAttempt reports whether the user may try a code
+func (t *totp) allowAttempt(subject string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	failures := t.failures[subject]
+	return failures == nil || time.Now().After(failures.lockedUntil)
+}
+
+func (t *totp) recordFailure(subject string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	failures := t.failures[subject]
+	if failures == nil {
+		failures = &totpFailures{}
+		t.failures[subject] = failures
+	}
+	failures.count++
+	if failures.count >= maxTOTPFailures {
+		failures.count = 0
+		failures.lockedUntil = time.Now().Add(totpLockout)
+	}
+}
+
+func (t *totp) resetFailures(subject string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.failures, subject)
+}
+
+// matchTOTP returns the time step code is valid for at now, accepting the
+// codes of adjacent steps to allow for clock drift
+func matchTOTP(secret, code string, now time.Time) (int64, bool) {
+	key, err := totpEncoding.DecodeString(strings.ToUpper(secret))
+	if err != nil {
+		return 0, false
+	}
+
+	code = strings.ReplaceAll(code, " ", "")
+	current := now.Unix() / int64(totpPeriod.Seconds())
+	for step := current - totpSkew; step <= current+totpSkew; step++ {
+		if hmac.Equal([]byte(totpCode(key, step)), []byte(code)) {
+			return step, true
+		}
+	}
+	return 0, false
+}
+
+// totpCode computes the code of a time step (RFC 4226 dynamic truncation)
+func totpCode(key []byte, step int64) string {
+	var counter [8]byte
+	binary.BigEndian.PutUint64(counter[:], uint64(step))
+
+	mac := hmac.New(sha1.New, key)
+	mac.Write(counter[:])
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	return fmt.Sprintf("%0*d", totpDigits, value%1000000)
+}
+
+// respondTOTPError writes the problem a repository error describes
+func respondTOTPError(c *gin.Context, err error, fallback string) {
+	switch {
+	case errors.Is(err, database.ErrTOTPEnrolled):
+		problem.Respond(c, http.StatusConflict, problem.CodeConflict, "An authenticator app is already enrolled; disable it before enrolling another")
+	case errors.Is(err, database.ErrTOTPNotEnrolled):
+		problem.Respond(c, http.StatusConflict, problem.CodeInvalidState, "No authenticator app is enrolled")
+	case errors.Is(err, database.ErrNotSupported):
+		problem.Respond(c, http.StatusNotImplemented, problem.CodeNotImplemented, "Two-factor authentication requires a PostgreSQL database")
+	default:
+		log.Printf("%s %s: %s: %v", c.Request.Method, c.Request.URL.Path, fallback, err)
+		problem.Respond(c, http.StatusInternalServerError, problem.CodeInternal, fallback)
+	}
+}
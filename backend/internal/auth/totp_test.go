@@ -0,0 +1,106 @@
+package auth
+
+import (
+	"testing"
+	"time"
+)
+
+// rfc6238Secret is the SHA-1 key of the RFC 6238 test vectors,
+// "12345678901234567890", in base32
+const rfc6238Secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestTOTPCode(t *testing.T) {
+	key, err := totpEncoding.DecodeString(rfc6238Secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The last six digits of the eight digit codes of RFC 6238, appendix B
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+	for _, tt := range tests {
+		if got := totpCode(key, tt.unix/int64(totpPeriod.Seconds())); got != tt.want {
+			t.Errorf("code at %d: got %s, want %s", tt.unix, got, tt.want)
+		}
+	}
+}
+
+func TestMatchTOTP(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	step := now.Unix() / int64(totpPeriod.Seconds())
+
+	tests := []struct {
+		name     string
+		secret   string
+		code     string
+		wantStep int64
+		wantOK   bool
+	}{
+		{"current code", rfc6238Secret, "050471", step, true},
+		{"lowercase secret", "gezdgnbvgy3tqojqgezdgnbvgy3tqojq", "050471", step, true},
+		{"code with spaces", rfc6238Secret, "050 471", step, true},
+		{"previous step", rfc6238Secret, "081804", step - 1, true},
+		{"wrong code", rfc6238Secret, "123456", 0, false},
+		{"code of two steps ago", rfc6238Secret, codeAt(t, step-2), 0, false},
+		{"code of two steps ahead", rfc6238Secret, codeAt(t, step+2), 0, false},
+		{"next step", rfc6238Secret, codeAt(t, step+1), step + 1, true},
+		{"invalid secret", "not base32!", "050471", 0, false},
+		{"empty code", rfc6238Secret, "", 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotStep, ok := matchTOTP(tt.secret, tt.code, now)
+			if ok != tt.wantOK || gotStep != tt.wantStep {
+				t.Errorf("got step %d, %v, want %d, %v", gotStep, ok, tt.wantStep, tt.wantOK)
+			}
+		})
+	}
+}
+
+func codeAt(t *testing.T, step int64) string {
+	t.Helper()
+	key, err := totpEncoding.DecodeString(rfc6238Secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return totpCode(key, step)
+}
+
+func TestTOTPLockout(t *testing.T) {
+	totp := &totp{failures: make(map[string]*totpFailures)}
+
+	for i := 0; i < maxTOTPFailures-1; i++ {
+		totp.recordFailure("alice")
+	}
+	if !totp.allowAttempt("alice") {
+		t.Fatal("locked out before reaching the failure limit")
+	}
+
+	totp.recordFailure("alice")
+	if totp.allowAttempt("alice") {
+		t.Error("not locked out after reaching the failure limit")
+	}
+	if !totp.allowAttempt("bob") {
+		t.Error("another user is locked out")
+	}
+
+	totp.failures["alice"].lockedUntil = time.Now().Add(-time.Second)
+	if !totp.allowAttempt("alice") {
+		t.Error("still locked out after the lockout expired")
+	}
+
+	totp.recordFailure("carol")
+	totp.resetFailures("carol")
+	if totp.failures["carol"] != nil {
+		t.Error("failures were not reset")
+	}
+}
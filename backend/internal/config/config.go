@@ -0,0 +1,467 @@
+package config
+
+import (
+	"fmt"
+	"net/netip"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/joho/godotenv"
+)
+
+// Config holds the server settings loaded from the environment
+type Config struct {
+	Port           string
+	DatabaseURL    string
+	DatabaseDriver string
+	GinMode        string
+
+	DatabaseReplicaURL string
+
+	CORSAllowedOrigins []string
+	TrustedProxies     []string
+
+	ReadTimeout     time.Duration
+	WriteTimeout    time.Duration
+	IdleTimeout     time.Duration
+	ShutdownTimeout time.Duration
+
+	CompressionMinSize int
+
+	MaxBodySize       int
+	MaxImportBodySize int
+	MaxValueSize      int
+
+	TLSCertFile           string
+	TLSKeyFile            string
+	TLSReloadInterval     time.Duration
+	TLSClientCAFile       string
+	TLSClientCertRequired bool
+	TLSClientIdentities   map[string]string
+
+	APIKeyRequired    bool
+	ACLEnforced       bool
+	ACLAdmins         []string
+	AdminTOTPRequired bool
+
+	NetworkAllowResolve []netip.Prefix
+	NetworkAllowRead    []netip.Prefix
+	NetworkAllowWrite   []netip.Prefix
+	NetworkAllowAdmin   []netip.Prefix
+
+	DeleteGuardWindow time.Duration
+	SchedulerInterval time.Duration
+	OutboxInterval    time.Duration
+	OutboxRetention   time.Duration
+
+	PurgeExpiredProperties bool
+	ExpiryPurgeInterval    time.Duration
+
+	KeyRotationInterval  time.Duration
+	KeyRotationBatchSize int
+
+	RequireRegisteredKeys bool
+
+	IdempotencyKeyTTL time.Duration
+
+	SyncInterval    time.Duration
+	K8sSyncBindings string
+	K8sKubeconfig   string
+	K8sContext      string
+
+	ConsulSyncBindings string
+	ConsulAddr         string
+	ConsulToken        string
+	ConsulKVPrefix     string
+
+	SSMSyncBindings string
+	SSMRegion       string
+	SSMPathPrefix   string
+	SSMKMSKeyID     string
+	SSMDryRun       bool
+
+	KafkaBrokers      []string
+	KafkaTopic        string
+	KafkaTLS          bool
+	KafkaSASLUsername string
+	KafkaSASLPassword string
+
+	NATSURL         string
+	NATSSubject     string
+	NATSStream      string
+	NATSCredentials string
+
+	AuditExportURL      string
+	AuditExportToken    string
+	AuditExportBuffer   int
+	AuditExportInterval time.Duration
+
+	GitOpsRepoURL     string
+	GitOpsBranch      string
+	GitOpsDir         string
+	GitOpsPath        string
+	GitOpsImport      bool
+	GitOpsInterval    time.Duration
+	GitOpsAuthorName  string
+	GitOpsAuthorEmail string
+
+	OIDCIssuerURL         string
+	OIDCClientID          string
+	OIDCClientSecret      string
+	OIDCRedirectURL       string
+	OIDCGroupsClaim       string
+	OIDCSessionSecret     string
+	OIDCSessionTTL        time.Duration
+	OIDCPostLoginRedirect string
+	OIDCSecureCookies     bool
+	TOTPIssuer            string
+
+	DBMaxOpenConns    int
+	DBMaxIdleConns    int
+	DBConnMaxIdleTime time.Duration
+	DBConnMaxLifetime time.Duration
+	DBQueryTimeout    time.Duration
+
+	ResolveCacheTTL  time.Duration
+	ResolveCacheSize int
+	NodeCacheSize    int
+
+	ResolveMaterialized bool
+
+	ResolveSigningKeyFile string
+	ResolveSigningKeyID   string
+}
+
+// Load reads the configuration from the environment. Variables from .env and
+// from the dotenv-formatted file named by CONFIG_FILE are loaded first, without
+// overriding variables that are already set.
+func Load() (*Config, error) {
+	if file := os.Getenv("CONFIG_FILE"); file != "" {
+		if err := godotenv.Load(file); err != nil {
+			return nil, fmt.Errorf("failed to load CONFIG_FILE %s: %w", file, err)
+		}
+	}
+	_ = godotenv.Load()
+
+	l := &loader{}
+	cfg := &Config{
+		Port:           l.str("PORT", "8080"),
+		DatabaseURL:    l.str("DATABASE_URL", ""),
+		DatabaseDriver: l.str("DATABASE_DRIVER", "postgres"),
+		GinMode:        l.str("GIN_MODE", gin.DebugMode),
+
+		DatabaseReplicaURL: l.str("DATABASE_REPLICA_URL", ""),
+
+		CORSAllowedOrigins: l.list("CORS_ALLOWED_ORIGINS", []string{"http://localhost:3000", "http://localhost:3001"}),
+		TrustedProxies:     l.list("TRUSTED_PROXIES", nil),
+
+		ReadTimeout:     l.duration("HTTP_READ_TIMEOUT", 15*time.Second),
+		WriteTimeout:    l.duration("HTTP_WRITE_TIMEOUT", 30*time.Second),
+		IdleTimeout:     l.duration("HTTP_IDLE_TIMEOUT", 60*time.Second),
+		ShutdownTimeout: l.duration("SHUTDOWN_TIMEOUT", 10*time.Second),
+
+		CompressionMinSize: l.integer("HTTP_COMPRESSION_MIN_SIZE", 1024),
+
+		MaxBodySize:       l.integer("HTTP_MAX_BODY_SIZE", 1<<20),
+		MaxImportBodySize: l.integer("HTTP_MAX_IMPORT_BODY_SIZE", 32<<20),
+		MaxValueSize:      l.integer("MAX_PROPERTY_VALUE_SIZE", 256<<10),
+
+		TLSCertFile:           l.str("TLS_CERT_FILE", ""),
+		TLSKeyFile:            l.str("TLS_KEY_FILE", ""),
+		TLSReloadInterval:     l.duration("TLS_RELOAD_INTERVAL", 30*time.Second),
+		TLSClientCAFile:       l.str("TLS_CLIENT_CA_FILE", ""),
+		TLSClientCertRequired: l.boolean("TLS_CLIENT_CERT_REQUIRED", false),
+		TLSClientIdentities:   l.mapping("TLS_CLIENT_IDENTITIES"),
+
+		APIKeyRequired:    l.boolean("API_KEY_REQUIRED", false),
+		ACLEnforced:       l.boolean("ACL_ENFORCED", false),
+		ACLAdmins:         l.list("ACL_ADMINS", nil),
+		AdminTOTPRequired: l.boolean("ADMIN_TOTP_REQUIRED", false),
+
+		NetworkAllowResolve: l.networks("NETWORK_ALLOW_RESOLVE"),
+		NetworkAllowRead:    l.networks("NETWORK_ALLOW_READ"),
+		NetworkAllowWrite:   l.networks("NETWORK_ALLOW_WRITE"),
+		NetworkAllowAdmin:   l.networks("NETWORK_ALLOW_ADMIN"),
+
+		DeleteGuardWindow: time.Duration(l.integer("DELETE_GUARD_DAYS", 7)) * 24 * time.Hour,
+		SchedulerInterval: l.duration("SCHEDULER_INTERVAL", 30*time.Second),
+		OutboxInterval:    l.duration("OUTBOX_INTERVAL", time.Second),
+		OutboxRetention:   l.duration("OUTBOX_RETENTION", 7*24*time.Hour),
+
+		PurgeExpiredProperties: l.boolean("PURGE_EXPIRED_PROPERTIES", false),
+		ExpiryPurgeInterval:    l.duration("EXPIRY_PURGE_INTERVAL", 5*time.Minute),
+
+		KeyRotationInterval:  l.duration("KEY_ROTATION_INTERVAL", 30*time.Second),
+		KeyRotationBatchSize: l.integer("KEY_ROTATION_BATCH_SIZE", 500),
+
+		RequireRegisteredKeys: l.boolean("REQUIRE_REGISTERED_KEYS", false),
+
+		IdempotencyKeyTTL: l.duration("IDEMPOTENCY_KEY_TTL", 24*time.Hour),
+
+		SyncInterval:    l.duration("SYNC_INTERVAL", time.Minute),
+		K8sSyncBindings: l.str("K8S_SYNC_BINDINGS", ""),
+		K8sKubeconfig:   l.str("K8S_KUBECONFIG", ""),
+		K8sContext:      l.str("K8S_CONTEXT", ""),
+
+		ConsulSyncBindings: l.str("CONSUL_SYNC_BINDINGS", ""),
+		ConsulAddr:         l.str("CONSUL_HTTP_ADDR", "http://127.0.0.1:8500"),
+		ConsulToken:        l.str("CONSUL_HTTP_TOKEN", ""),
+		ConsulKVPrefix:     l.str("CONSUL_KV_PREFIX", "config-manager"),
+
+		SSMSyncBindings: l.str("SSM_SYNC_BINDINGS", ""),
+		SSMRegion:       l.str("SSM_REGION", ""),
+		SSMPathPrefix:   l.str("SSM_PATH_PREFIX", "/config-manager"),
+		SSMKMSKeyID:     l.str("SSM_KMS_KEY_ID", ""),
+		SSMDryRun:       l.boolean("SSM_DRY_RUN", false),
+
+		KafkaBrokers:      l.list("KAFKA_BROKERS", nil),
+		KafkaTopic:        l.str("KAFKA_TOPIC", "config-manager.events"),
+		KafkaTLS:          l.boolean("KAFKA_TLS", false),
+		KafkaSASLUsername: l.str("KAFKA_SASL_USERNAME", ""),
+		KafkaSASLPassword: l.str("KAFKA_SASL_PASSWORD", ""),
+
+		NATSURL:         l.str("NATS_URL", ""),
+		NATSSubject:     l.str("NATS_SUBJECT", "config-manager.events"),
+		NATSStream:      l.str("NATS_STREAM", ""),
+		NATSCredentials: l.str("NATS_CREDS", ""),
+
+		AuditExportURL:      l.str("AUDIT_EXPORT_URL", ""),
+		AuditExportToken:    l.str("AUDIT_EXPORT_TOKEN", ""),
+		AuditExportBuffer:   l.integer("AUDIT_EXPORT_BUFFER", 10000),
+		AuditExportInterval: l.duration("AUDIT_EXPORT_INTERVAL", time.Second),
+
+		GitOpsRepoURL:     l.str("GITOPS_REPO_URL", ""),
+		GitOpsBranch:      l.str("GITOPS_BRANCH", "main"),
+		GitOpsDir:         l.str("GITOPS_DIR", "/var/lib/config-manager/gitops"),
+		GitOpsPath:        l.str("GITOPS_PATH", "config"),
+		GitOpsImport:      l.boolean("GITOPS_IMPORT", false),
+		GitOpsInterval:    l.duration("GITOPS_INTERVAL", 5*time.Minute),
+		GitOpsAuthorName:  l.str("GITOPS_AUTHOR_NAME", "config-manager"),
+		GitOpsAuthorEmail: l.str("GITOPS_AUTHOR_EMAIL", "config-manager@localhost"),
+
+		OIDCIssuerURL:         l.str("OIDC_ISSUER_URL", ""),
+		OIDCClientID:          l.str("OIDC_CLIENT_ID", ""),
+		OIDCClientSecret:      l.str("OIDC_CLIENT_SECRET", ""),
+		OIDCRedirectURL:       l.str("OIDC_REDIRECT_URL", ""),
+		OIDCGroupsClaim:       l.str("OIDC_GROUPS_CLAIM", "groups"),
+		OIDCSessionSecret:     l.str("OIDC_SESSION_SECRET", ""),
+		OIDCSessionTTL:        l.duration("OIDC_SESSION_TTL", 8*time.Hour),
+		OIDCPostLoginRedirect: l.str("OIDC_POST_LOGIN_REDIRECT", "/"),
+		OIDCSecureCookies:     l.boolean("OIDC_SECURE_COOKIES", true),
+		TOTPIssuer:            l.str("TOTP_ISSUER", "Config Manager"),
+
+		DBMaxOpenConns:    l.integer("DB_MAX_OPEN_CONNS", 25),
+		DBMaxIdleConns:    l.integer("DB_MAX_IDLE_CONNS", 5),
+		DBConnMaxIdleTime: l.duration("DB_CONN_MAX_IDLE_TIME", 30*time.Minute),
+		DBConnMaxLifetime: l.duration("DB_CONN_MAX_LIFETIME", 30*time.Minute),
+		DBQueryTimeout:    l.duration("DB_QUERY_TIMEOUT", 30*time.Second),
+
+		ResolveCacheTTL:  l.duration("RESOLVE_CACHE_TTL", 0),
+		ResolveCacheSize: l.integer("RESOLVE_CACHE_SIZE", 10000),
+		NodeCacheSize:    l.integer("NODE_CACHE_SIZE", 0),
+
+		ResolveMaterialized: l.boolean("RESOLVE_MATERIALIZED", false),
+
+		ResolveSigningKeyFile: l.str("RESOLVE_SIGNING_KEY_FILE", ""),
+		ResolveSigningKeyID:   l.str("RESOLVE_SIGNING_KEY_ID", ""),
+	}
+
+	if l.err != nil {
+		return nil, l.err
+	}
+
+	switch cfg.GinMode {
+	case gin.DebugMode, gin.ReleaseMode, gin.TestMode:
+	default:
+		return nil, fmt.Errorf("GIN_MODE must be one of debug, release or test, got %q", cfg.GinMode)
+	}
+
+	switch cfg.DatabaseDriver {
+	case "postgres", "sqlite", "mysql":
+	default:
+		return nil, fmt.Errorf("DATABASE_DRIVER must be postgres, sqlite or mysql, got %q", cfg.DatabaseDriver)
+	}
+	if cfg.DatabaseReplicaURL != "" && cfg.DatabaseDriver != "postgres" {
+		return nil, fmt.Errorf("DATABASE_REPLICA_URL is only supported with DATABASE_DRIVER=postgres")
+	}
+	if cfg.DBMaxOpenConns < 1 {
+		return nil, fmt.Errorf("DB_MAX_OPEN_CONNS must be at least 1, got %d", cfg.DBMaxOpenConns)
+	}
+	if cfg.ResolveCacheTTL > 0 && cfg.DatabaseDriver != "postgres" {
+		return nil, fmt.Errorf("RESOLVE_CACHE_TTL is only supported with DATABASE_DRIVER=postgres")
+	}
+	if cfg.ResolveCacheSize < 1 {
+		return nil, fmt.Errorf("RESOLVE_CACHE_SIZE must be at least 1, got %d", cfg.ResolveCacheSize)
+	}
+	if cfg.NodeCacheSize < 0 {
+		return nil, fmt.Errorf("NODE_CACHE_SIZE must not be negative, got %d", cfg.NodeCacheSize)
+	}
+	if cfg.NodeCacheSize > 0 && cfg.DatabaseDriver != "postgres" {
+		return nil, fmt.Errorf("NODE_CACHE_SIZE is only supported with DATABASE_DRIVER=postgres")
+	}
+	if cfg.ResolveMaterialized && cfg.DatabaseDriver != "postgres" {
+		return nil, fmt.Errorf("RESOLVE_MATERIALIZED is only supported with DATABASE_DRIVER=postgres")
+	}
+	if cfg.ResolveSigningKeyID != "" && cfg.ResolveSigningKeyFile == "" {
+		return nil, fmt.Errorf("RESOLVE_SIGNING_KEY_ID requires RESOLVE_SIGNING_KEY_FILE")
+	}
+	if (cfg.TLSCertFile == "") != (cfg.TLSKeyFile == "") {
+		return nil, fmt.Errorf("TLS_CERT_FILE and TLS_KEY_FILE must be set together")
+	}
+	if cfg.TLSReloadInterval < 0 {
+		return nil, fmt.Errorf("TLS_RELOAD_INTERVAL must not be negative, got %s", cfg.TLSReloadInterval)
+	}
+	if cfg.TLSClientCAFile != "" && cfg.TLSCertFile == "" {
+		return nil, fmt.Errorf("TLS_CLIENT_CA_FILE requires TLS_CERT_FILE and TLS_KEY_FILE")
+	}
+	if (cfg.TLSClientCertRequired || len(cfg.TLSClientIdentities) > 0) && cfg.TLSClientCAFile == "" {
+		return nil, fmt.Errorf("TLS_CLIENT_CERT_REQUIRED and TLS_CLIENT_IDENTITIES require TLS_CLIENT_CA_FILE")
+	}
+	for identity, scope := range cfg.TLSClientIdentities {
+		switch scope {
+		case "resolve", "read", "write":
+		default:
+			return nil, fmt.Errorf("TLS_CLIENT_IDENTITIES must map identities to resolve, read or write, got %q for %s", scope, identity)
+		}
+	}
+	if cfg.AuditExportURL != "" && cfg.DatabaseDriver != "postgres" {
+		return nil, fmt.Errorf("AUDIT_EXPORT_URL is only supported with DATABASE_DRIVER=postgres")
+	}
+	if cfg.AuditExportBuffer < 100 {
+		return nil, fmt.Errorf("AUDIT_EXPORT_BUFFER must be at least 100, got %d", cfg.AuditExportBuffer)
+	}
+	if cfg.AuditExportInterval <= 0 {
+		return nil, fmt.Errorf("AUDIT_EXPORT_INTERVAL must be positive, got %s", cfg.AuditExportInterval)
+	}
+	if cfg.KeyRotationInterval <= 0 {
+		return nil, fmt.Errorf("KEY_ROTATION_INTERVAL must be positive, got %s", cfg.KeyRotationInterval)
+	}
+	if cfg.KeyRotationBatchSize < 1 {
+		return nil, fmt.Errorf("KEY_ROTATION_BATCH_SIZE must be at least 1, got %d", cfg.KeyRotationBatchSize)
+	}
+	if cfg.MaxBodySize < 0 || cfg.MaxImportBodySize < 0 || cfg.MaxValueSize < 0 {
+		return nil, fmt.Errorf("HTTP_MAX_BODY_SIZE, HTTP_MAX_IMPORT_BODY_SIZE and MAX_PROPERTY_VALUE_SIZE must not be negative")
+	}
+	if cfg.CompressionMinSize < 0 {
+		return nil, fmt.Errorf("HTTP_COMPRESSION_MIN_SIZE must not be negative, got %d", cfg.CompressionMinSize)
+	}
+
+	return cfg, nil
+}
+
+// loader reads typed environment variables, keeping the first parse error
+type loader struct {
+	err error
+}
+
+func (l *loader) str(key, fallback string) string {
+	if value, ok := os.LookupEnv(key); ok && value != "" {
+		return value
+	}
+	return fallback
+}
+
+// list parses a comma-separated variable, ignoring empty entries
+func (l *loader) list(key string, fallback []string) []string {
+	value, ok := os.LookupEnv(key)
+	if !ok || strings.TrimSpace(value) == "" {
+		return fallback
+	}
+
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// mapping parses a comma-separated list of name=value pairs, names running up
+// to the last =
+func (l *loader) mapping(key string) map[string]string {
+	entries := l.list(key, nil)
+	if len(entries) == 0 {
+		return nil
+	}
+
+	pairs := make(map[string]string, len(entries))
+	for _, entry := range entries {
+		i := strings.LastIndex(entry, "=")
+		if i <= 0 {
+			if l.err == nil {
+				l.err = fmt.Errorf("%s must list name=value pairs, got %q", key, entry)
+			}
+			continue
+		}
+		pairs[strings.TrimSpace(entry[:i])] = strings.TrimSpace(entry[i+1:])
+	}
+	return pairs
+}
+
+// networks parses a comma-separated list of CIDRs, a bare IP standing for
+// itself alone
+func (l *loader) networks(key string) []netip.Prefix {
+	var networks []netip.Prefix
+	for _, entry := range l.list(key, nil) {
+		network, err := netip.ParsePrefix(entry)
+		if addr, addrErr := netip.ParseAddr(entry); addrErr == nil {
+			network, err = netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()), nil
+		}
+		if err != nil {
+			if l.err == nil {
+				l.err = fmt.Errorf("%s must list IPs or CIDRs such as 10.0.0.0/8: %w", key, err)
+			}
+			continue
+		}
+		networks = append(networks, network.Masked())
+	}
+	return networks
+}
+
+func (l *loader) integer(key string, fallback int) int {
+	value, ok := os.LookupEnv(key)
+	if !ok || value == "" {
+		return fallback
+	}
+
+	n, err := strconv.Atoi(value)
+	if err != nil && l.err == nil {
+		l.err = fmt.Errorf("%s must be an integer: %w", key, err)
+	}
+	return n
+}
+
+func (l *loader) boolean(key string, fallback bool) bool {
+	value, ok := os.LookupEnv(key)
+	if !ok || value == "" {
+		return fallback
+	}
+
+	b, err := strconv.ParseBool(value)
+	if err != nil && l.err == nil {
+		l.err = fmt.Errorf("%s must be true or false: %w", key, err)
+	}
+	return b
+}
+
+func (l *loader) duration(key string, fallback time.Duration) time.Duration {
+	value, ok := os.LookupEnv(key)
+	if !ok || value == "" {
+		return fallback
+	}
+
+	d, err := time.ParseDuration(value)
+	if err != nil && l.err == nil {
+		l.err = fmt.Errorf("%s must be a duration such as 30s: %w", key, err)
+	}
+	return d
+}
+
+// OIDCEnabled reports whether single sign-on is configured
+func (c *Config) OIDCEnabled() bool {
+	return c.OIDCIssuerURL != ""
+}
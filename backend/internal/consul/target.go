@@ -0,0 +1,119 @@
+package consul
+
+import (
+	"bytes"
+	"config-manager/internal/syncer"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Target mirrors the configuration of each bound node into Consul KV, one key
+// per property under <prefix>/<destination>/. Keys under that path that no
+// longer exist in the configuration are deleted. Encrypted properties are not
+// written, as KV values are readable by anyone with access to the path.
+type Target struct {
+	addr       string
+	token      string
+	prefix     string
+	httpClient *http.Client
+}
+
+// NewTarget creates a target for the Consul agent at addr, e.g. http://127.0.0.1:8500
+func NewTarget(addr, token, prefix string) *Target {
+	return &Target{
+		addr:       strings.TrimSuffix(addr, "/"),
+		token:      token,
+		prefix:     strings.Trim(prefix, "/"),
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+func (t *Target) Name() string {
+	return "Consul"
+}
+
+func (t *Target) Sync(ctx context.Context, config syncer.NodeConfig) error {
+	base := strings.Trim(config.Destination, "/")
+	if t.prefix != "" {
+		base = t.prefix + "/" + base
+	}
+
+	existing, err := t.keys(ctx, base+"/")
+	if err != nil {
+		return err
+	}
+
+	wanted := make(map[string]bool, len(config.Properties))
+	for key, value := range config.Properties {
+		s, err := syncer.StringValue(value)
+		if err != nil {
+			return err
+		}
+		path := base + "/" + key
+		wanted[path] = true
+		if err := t.do(ctx, http.MethodPut, path, []byte(s), nil); err != nil {
+			return fmt.Errorf("failed to write %s: %w", path, err)
+		}
+	}
+
+	for _, path := range existing {
+		if !wanted[path] {
+			if err := t.do(ctx, http.MethodDelete, path, nil, nil); err != nil {
+				return fmt.Errorf("failed to delete %s: %w", path, err)
+			}
+		}
+	}
+	return nil
+}
+
+// keys lists the keys under prefix
+func (t *Target) keys(ctx context.Context, prefix string) ([]string, error) {
+	var keys []string
+	err := t.do(ctx, http.MethodGet, prefix+"?keys", nil, &keys)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list %s: %w", prefix, err)
+	}
+	return keys, nil
+}
+
+// do calls the KV endpoint for path (which may carry a query string), decoding
+// a JSON response into out when given. A 404 leaves out untouched.
+func (t *Target) do(ctx context.Context, method, path string, body []byte, out interface{}) error {
+	key, query, _ := strings.Cut(path, "?")
+	endpoint := t.addr + "/v1/kv/" + (&url.URL{Path: key}).EscapedPath()
+	if query != "" {
+		endpoint += "?" + query
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	if t.token != "" {
+		req.Header.Set("X-Consul-Token", t.token)
+	}
+
+	resp, err := t.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound && method == http.MethodGet {
+		return nil
+	}
+	if resp.StatusCode >= 300 {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return fmt.Errorf("consul returned %s: %s", resp.Status, strings.TrimSpace(string(message)))
+	}
+	if out != nil {
+		return json.NewDecoder(resp.Body).Decode(out)
+	}
+	return nil
+}
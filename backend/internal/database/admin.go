@@ -0,0 +1,77 @@
+package database
+
+import (
+	"config-manager/internal/models"
+	"context"
+	"log"
+	"time"
+)
+
+// rebuildStep is a single unit of derived-data recomputation
+type rebuildStep struct {
+	name string
+	run  func() error
+}
+
+// rebuildSteps lists the derived data that can be recomputed from the base tables,
+// in the order it must be rebuilt
+func (r *Repository) rebuildSteps(ctx context.Context) []rebuildStep {
+	steps := []rebuildStep{
+		{name: "reindex_config_nodes", run: r.execStep(ctx, `REINDEX TABLE config_nodes`)},
+		{name: "reindex_config_properties", run: r.execStep(ctx, `REINDEX TABLE config_properties`)},
+		{name: "analyze_statistics", run: r.execStep(ctx, `ANALYZE config_nodes, config_properties`)},
+	}
+	if r.materialized {
+		steps = append(steps, rebuildStep{name: "refresh_resolved_configurations", run: func() error {
+			_, err := r.RefreshResolutions(ctx, nil)
+			return err
+		}})
+	}
+	return steps
+}
+
+func (r *Repository) execStep(ctx context.Context, query string) func() error {
+	return func() error {
+		_, err := r.db.ExecContext(ctx, query)
+		return err
+	}
+}
+
+// RebuildDerivedData recomputes all derived data from the base tables. Steps run
+// sequentially; once a step fails the remaining steps are reported as skipped.
+func (r *Repository) RebuildDerivedData(ctx context.Context) *models.RebuildReport {
+	steps := r.rebuildSteps(ctx)
+	report := &models.RebuildReport{
+		StartedAt: time.Now(),
+		Success:   true,
+		Steps:     make([]models.RebuildStepResult, 0, len(steps)),
+	}
+
+	for i, step := range steps {
+		result := models.RebuildStepResult{Name: step.name}
+
+		if !report.Success {
+			result.Status = "skipped"
+			report.Steps = append(report.Steps, result)
+			continue
+		}
+
+		log.Printf("Rebuild step %d/%d: %s", i+1, len(steps), step.name)
+		start := time.Now()
+		err := step.run()
+		result.DurationMs = time.Since(start).Milliseconds()
+
+		if err != nil {
+			log.Printf("Rebuild step %s failed: %v", step.name, err)
+			result.Status = "failed"
+			result.Error = err.Error()
+			report.Success = false
+		} else {
+			result.Status = "completed"
+		}
+		report.Steps = append(report.Steps, result)
+	}
+
+	report.CompletedAt = time.Now()
+	return report
+}
@@ -0,0 +1,199 @@
+package database
+
+import (
+	"config-manager/internal/models"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"log"
+	"time"
+)
+
+var ErrAPIKeyNotFound = errors.New("api key not found")
+
+const apiKeyColumns = `id, name, prefix, scopes, created_at, revoked_at, expires_at, last_used_at, rotated_at, previous_key_expires_at`
+
+// lastUsedResolution is how stale the recorded last use of a key may get, so
+// that busy keys are not written to on every request
+const lastUsedResolution = time.Minute
+
+func scanAPIKey(row rowScanner) (*models.APIKey, error) {
+	var key models.APIKey
+	var scopes []string
+	if err := row.Scan(&key.ID, &key.Name, &key.Prefix, textArray(&scopes), &key.CreatedAt, &key.RevokedAt,
+		&key.ExpiresAt, &key.LastUsedAt, &key.RotatedAt, &key.PreviousKeyExpiresAt); err != nil {
+		return nil, err
+	}
+
+	key.Scopes = make([]models.APIKeyScope, len(scopes))
+	for i, scope := range scopes {
+		key.Scopes[i] = models.APIKeyScope(scope)
+	}
+	return &key, nil
+}
+
+func hashAPIKey(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// newAPIKeySecret generates a secret and the prefix identifying it
+func newAPIKeySecret() (prefix, secret string, err error) {
+	random := make([]byte, 24)
+	if _, err := rand.Read(random); err != nil {
+		return "", "", err
+	}
+	encoded := hex.EncodeToString(random)
+	prefix = "cm_" + encoded[:8]
+	return prefix, prefix + "_" + encoded[8:], nil
+}
+
+// CreateAPIKey issues a new key. Only its hash is stored, so the returned secret
+// cannot be recovered later.
+func (r *Repository) CreateAPIKey(ctx context.Context, req models.CreateAPIKeyRequest) (*models.CreatedAPIKey, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	prefix, secret, err := newAPIKeySecret()
+	if err != nil {
+		return nil, err
+	}
+
+	scopes := make([]string, len(req.Scopes))
+	for i, scope := range req.Scopes {
+		scopes[i] = string(scope)
+	}
+
+	query := `
+		INSERT INTO api_keys (name, prefix, key_hash, scopes, created_at, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING ` + apiKeyColumns
+
+	key, err := scanAPIKey(r.db.QueryRowContext(ctx, query, req.Name, prefix, hashAPIKey(secret), scopes, time.Now(), req.ExpiresAt))
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.CreatedAPIKey{APIKey: *key, Key: secret}, nil
+}
+
+func (r *Repository) GetAPIKeys(ctx context.Context) ([]models.APIKey, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	query := `SELECT ` + apiKeyColumns + ` FROM api_keys ORDER BY created_at DESC`
+
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := []models.APIKey{}
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, *key)
+	}
+
+	return keys, nil
+}
+
+// RevokeAPIKey marks a key as revoked; revoked keys no longer authenticate
+func (r *Repository) RevokeAPIKey(ctx context.Context, id int64) error {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	query := `UPDATE api_keys SET revoked_at = $1 WHERE id = $2 AND revoked_at IS NULL`
+	result, err := r.db.ExecContext(ctx, query, time.Now(), id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrAPIKeyNotFound
+	}
+
+	return nil
+}
+
+// RotateAPIKey replaces the secret of an active key. The replaced secret stays
+// valid for the grace period, so that consumers can switch over without
+// downtime; rotating again ends the grace period of the secret before. The key
+// keeps its ID, and so its principal and permissions. A non-nil expiresAt
+// replaces the expiration date of the key.
+func (r *Repository) RotateAPIKey(ctx context.Context, id int64, grace time.Duration, expiresAt *time.Time) (*models.CreatedAPIKey, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	prefix, secret, err := newAPIKeySecret()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	query := `
+		UPDATE api_keys SET
+			prefix = $2,
+			key_hash = $3,
+			previous_key_hash = key_hash,
+			previous_key_expires_at = $4,
+			rotated_at = $5,
+			expires_at = COALESCE($6, expires_at)
+		WHERE id = $1 AND revoked_at IS NULL
+		RETURNING ` + apiKeyColumns
+
+	key, err := scanAPIKey(r.db.QueryRowContext(ctx, query, id, prefix, hashAPIKey(secret), now.Add(grace), now, expiresAt))
+	if err == sql.ErrNoRows {
+		return nil, ErrAPIKeyNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.CreatedAPIKey{APIKey: *key, Key: secret}, nil
+}
+
+// AuthenticateAPIKey returns the active key matching secret, or nil if there is
+// none. The secret replaced by the last rotation matches until its grace period
+// ends, and expired keys never match. The last use of the key is recorded
+// unless the repository is ReadOnly.
+func (r *Repository) AuthenticateAPIKey(ctx context.Context, secret string) (*models.APIKey, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	now := time.Now()
+	query := `
+		SELECT ` + apiKeyColumns + ` FROM api_keys
+		WHERE (key_hash = $1 OR (previous_key_hash = $1 AND previous_key_expires_at > $2))
+		AND revoked_at IS NULL AND (expires_at IS NULL OR expires_at > $2)`
+
+	hash := hashAPIKey(secret)
+	key, err := scanAPIKey(r.db.QueryRowContext(ctx, query, hash, now))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Recording the last use is best effort, it must not fail authentication
+	if !r.readOnly && (key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) >= lastUsedResolution) {
+		if _, err := r.db.ExecContext(ctx, `UPDATE api_keys SET last_used_at = $2 WHERE id = $1`, key.ID, now); err != nil {
+			log.Printf("Failed to record the last use of API key %d: %v", key.ID, err)
+		} else {
+			key.LastUsedAt = &now
+		}
+	}
+	return key, nil
+}
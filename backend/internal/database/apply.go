@@ -0,0 +1,360 @@
+package database
+
+import (
+	"config-manager/internal/models"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"time"
+)
+
+// ApplyError is returned when a declarative document cannot be applied to the
+// current tree, e.g. because it changes a node's type
+type ApplyError struct {
+	Path    string
+	Message string
+}
+
+func (e *ApplyError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Path, e.Message)
+}
+
+// FindNodesByName returns the children of parentID, or the root nodes when it
+// is nil, with the given name
+func (r *Repository) FindNodesByName(ctx context.Context, parentID *int64, name string) ([]models.ConfigNode, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	return findNodesByName(ctx, r.db, parentID, name)
+}
+
+func findNodesByName(ctx context.Context, q querier, parentID *int64, name string) ([]models.ConfigNode, error) {
+	rows, err := q.QueryContext(ctx, `
+		SELECT `+nodeColumns+`
+		FROM config_nodes
+		WHERE parent_id IS NOT DISTINCT FROM $1 AND name = $2
+		ORDER BY id`, parentID, name)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanNodes(rows)
+}
+
+// ApplyDocument converges the subtree named like doc under parentID to the
+// document: missing nodes and properties are created, differing ones updated
+// and undeclared ones deleted. Nothing outside the subtree is touched. With
+// dryRun the changes are planned in a transaction that is rolled back.
+// Encrypted values cannot be applied; declaring an encrypted property without
+// a value keeps the existing one.
+func (r *Repository) ApplyDocument(ctx context.Context, parentID *int64, doc models.DeclaredNode, dryRun bool) (*models.ApplyResult, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `SET LOCAL config_manager.event_source = 'apply'`); err != nil {
+		return nil, err
+	}
+
+	a := &applier{
+		tx:     tx,
+		now:    time.Now(),
+		dryRun: dryRun,
+		result: &models.ApplyResult{DryRun: dryRun, Changes: []models.ApplyChange{}, Warnings: []string{}},
+	}
+	rootID, err := a.applyNode(ctx, parentID, doc, "")
+	if err != nil {
+		return nil, err
+	}
+	if rootID != nil {
+		a.result.RootNodeID = rootID
+	}
+
+	if !dryRun {
+		if err := tx.Commit(); err != nil {
+			return nil, err
+		}
+	}
+	return a.result, nil
+}
+
+// applier walks a declarative document inside the apply transaction
+type applier struct {
+	tx     *sql.Tx
+	now    time.Time
+	dryRun bool
+	result *models.ApplyResult
+}
+
+func (a *applier) record(change models.ApplyChange) {
+	a.result.Changes = append(a.result.Changes, change)
+}
+
+// liveID hides the IDs of nodes created by a dry run, which are rolled back
+func (a *applier) liveID(id int64, created bool) *int64 {
+	if created && a.dryRun {
+		return nil
+	}
+	return &id
+}
+
+// applyNode converges one declared node under parentID and returns its ID
+func (a *applier) applyNode(ctx context.Context, parentID *int64, doc models.DeclaredNode, parentPath string) (*int64, error) {
+	path := parentPath + "/" + doc.Name
+
+	matches, err := findNodesByName(ctx, a.tx, parentID, doc.Name)
+	if err != nil {
+		return nil, err
+	}
+	if len(matches) > 1 {
+		return nil, &ApplyError{Path: path, Message: fmt.Sprintf("%d nodes have this name", len(matches))}
+	}
+
+	var nodeID int64
+	created := len(matches) == 0
+	if created {
+		err := a.tx.QueryRowContext(ctx, `
+			INSERT INTO config_nodes (name, node_type, parent_id, description, sort_index, created_at, updated_at)
+			VALUES ($1, $2, $3, $4, `+nextSortIndex("$3")+`, $5, $5)
+			RETURNING id`, doc.Name, doc.Type, parentID, doc.Description, a.now).Scan(&nodeID)
+		if isNodeNameConflict(err) {
+			return nil, &ApplyError{Path: path, Message: "a sibling node has this name in a different case"}
+		}
+		if err != nil {
+			return nil, err
+		}
+		a.record(models.ApplyChange{Action: "create", Kind: "node", Path: path, NodeID: a.liveID(nodeID, true), New: doc.Type})
+	} else {
+		node := matches[0]
+		nodeID = node.ID
+		switch {
+		case node.NodeType != doc.Type:
+			return nil, &ApplyError{Path: path, Message: fmt.Sprintf("is a %s, not a %s", node.NodeType, doc.Type)}
+		case node.Description != doc.Description:
+			if _, err := a.tx.ExecContext(ctx, `UPDATE config_nodes SET description = $1, updated_at = $2 WHERE id = $3`, doc.Description, a.now, nodeID); err != nil {
+				return nil, err
+			}
+			a.record(models.ApplyChange{Action: "update", Kind: "node", Path: path, NodeID: &nodeID, Fields: []string{"description"}, Old: node.Description, New: doc.Description})
+		default:
+			a.result.Unchanged++
+		}
+	}
+
+	if err := a.applyProperties(ctx, nodeID, created, doc.Properties, path); err != nil {
+		return nil, err
+	}
+
+	declared := make(map[string]bool, len(doc.Children))
+	for _, child := range doc.Children {
+		if declared[child.Name] {
+			return nil, &ApplyError{Path: path + "/" + child.Name, Message: "declared more than once"}
+		}
+		declared[child.Name] = true
+		if _, err := a.applyNode(ctx, &nodeID, child, path); err != nil {
+			return nil, err
+		}
+	}
+
+	if !created {
+		rows, err := a.tx.QueryContext(ctx, `SELECT `+nodeColumns+` FROM config_nodes WHERE parent_id = $1 ORDER BY sort_index, id`, nodeID)
+		if err != nil {
+			return nil, err
+		}
+		children, err := scanNodes(rows)
+		rows.Close()
+		if err != nil {
+			return nil, err
+		}
+		for _, child := range children {
+			if declared[child.Name] {
+				continue
+			}
+			// Deleting a node cascades to its subtree
+			if _, err := a.tx.ExecContext(ctx, `DELETE FROM config_nodes WHERE id = $1`, child.ID); err != nil {
+				return nil, err
+			}
+			childID := child.ID
+			a.record(models.ApplyChange{Action: "delete", Kind: "node", Path: path + "/" + child.Name, NodeID: &childID, Old: child.NodeType})
+		}
+	}
+
+	return a.liveID(nodeID, created), nil
+}
+
+// appliedProperty is a live property as stored, with its value still serialized
+type appliedProperty struct {
+	id          int64
+	key         string
+	environment *string
+	value       string
+	dataType    models.DataType
+	description string
+	encrypted   bool
+	isFinal     bool
+}
+
+func environmentKey(key string, environment *string) string {
+	if environment == nil {
+		return key + "@"
+	}
+	return key + "@" + *environment
+}
+
+// changedFields names the attributes converging p to d changes
+func changedFields(p *appliedProperty, d models.DeclaredProperty, sameValue bool) []string {
+	var fields []string
+	if !sameValue {
+		fields = append(fields, "value")
+	}
+	if p.dataType != d.Type {
+		fields = append(fields, "type")
+	}
+	if p.description != d.Description {
+		fields = append(fields, "description")
+	}
+	if p.isFinal != d.Final {
+		fields = append(fields, "final")
+	}
+	return fields
+}
+
+// applyProperties converges the properties of a node to the declared ones
+func (a *applier) applyProperties(ctx context.Context, nodeID int64, created bool, declared []models.DeclaredProperty, path string) error {
+	existing := make(map[string]*appliedProperty)
+	var order []string
+	if !created {
+		rows, err := a.tx.QueryContext(ctx, `
+			SELECT id, key, environment, value, data_type, description, encrypted, is_final
+			FROM config_properties WHERE node_id = $1
+			ORDER BY key, environment NULLS FIRST`, nodeID)
+		if err != nil {
+			return err
+		}
+		for rows.Next() {
+			p := &appliedProperty{}
+			if err := rows.Scan(&p.id, &p.key, &p.environment, &p.value, &p.dataType, &p.description, &p.encrypted, &p.isFinal); err != nil {
+				rows.Close()
+				return err
+			}
+			k := environmentKey(p.key, p.environment)
+			existing[k] = p
+			order = append(order, k)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+	}
+
+	seen := make(map[string]bool, len(declared))
+	for _, d := range declared {
+		k := environmentKey(d.Key, d.Environment)
+		propertyPath := path + ":" + d.Key
+		if d.Environment != nil {
+			propertyPath += "@" + *d.Environment
+		}
+		if seen[k] {
+			return &ApplyError{Path: propertyPath, Message: "declared more than once"}
+		}
+		seen[k] = true
+
+		change := models.ApplyChange{Kind: "property", Path: path, NodeID: a.liveID(nodeID, created), Key: d.Key, Environment: d.Environment}
+		p := existing[k]
+
+		if d.Encrypted {
+			if d.Value != nil {
+				return &ApplyError{Path: propertyPath, Message: "encrypted values cannot be applied; declare the property without a value and set it through the API"}
+			}
+			switch {
+			case p == nil:
+				a.result.Warnings = append(a.result.Warnings, propertyPath+": encrypted property has no value and was not created")
+			case !p.encrypted:
+				return &ApplyError{Path: propertyPath, Message: "property is not encrypted"}
+			case len(changedFields(p, d, true)) == 0:
+				a.result.Unchanged++
+			default:
+				change.Fields = changedFields(p, d, true)
+				_, err := a.tx.ExecContext(ctx, `UPDATE config_properties SET data_type = $1, description = $2, is_final = $3, updated_at = $4 WHERE id = $5`,
+					d.Type, d.Description, d.Final, a.now, p.id)
+				if err != nil {
+					return err
+				}
+				change.Action = "update"
+				a.record(change)
+			}
+			continue
+		}
+
+		payload, err := json.Marshal(d.Value)
+		if err != nil {
+			return &ApplyError{Path: propertyPath, Message: err.Error()}
+		}
+		change.New = d.Value
+
+		if p == nil {
+			_, err := a.tx.ExecContext(ctx, `
+				INSERT INTO config_properties (node_id, key, environment, value, data_type, description, is_final, created_at, updated_at)
+				VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $8)`,
+				nodeID, d.Key, d.Environment, string(payload), d.Type, d.Description, d.Final, a.now)
+			if err != nil {
+				return err
+			}
+			change.Action = "create"
+			a.record(change)
+			continue
+		}
+		if p.encrypted {
+			return &ApplyError{Path: propertyPath, Message: "property is encrypted; declare it with encrypted: true and no value"}
+		}
+
+		var old interface{}
+		if err := json.Unmarshal([]byte(p.value), &old); err != nil {
+			return fmt.Errorf("%s: stored value is not valid JSON", propertyPath)
+		}
+		var declaredValue interface{}
+		if err := json.Unmarshal(payload, &declaredValue); err != nil {
+			return err
+		}
+		change.Fields = changedFields(p, d, reflect.DeepEqual(old, declaredValue))
+		if len(change.Fields) == 0 {
+			a.result.Unchanged++
+			continue
+		}
+
+		_, err = a.tx.ExecContext(ctx, `UPDATE config_properties SET value = $1, data_type = $2, description = $3, is_final = $4, updated_at = $5 WHERE id = $6`,
+			string(payload), d.Type, d.Description, d.Final, a.now, p.id)
+		if err != nil {
+			return err
+		}
+		change.Action = "update"
+		change.Old = old
+		a.record(change)
+	}
+
+	for _, k := range order {
+		if seen[k] {
+			continue
+		}
+		p := existing[k]
+		if _, err := a.tx.ExecContext(ctx, `DELETE FROM config_properties WHERE id = $1`, p.id); err != nil {
+			return err
+		}
+		change := models.ApplyChange{Action: "delete", Kind: "property", Path: path, NodeID: &nodeID, Key: p.key, Environment: p.environment}
+		if !p.encrypted {
+			var old interface{}
+			if json.Unmarshal([]byte(p.value), &old) == nil {
+				change.Old = old
+			}
+		}
+		a.record(change)
+	}
+	return nil
+}
@@ -0,0 +1,132 @@
+package database
+
+import (
+	"config-manager/internal/models"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+)
+
+const auditColumns = `id, action, actor, details, occurred_at`
+
+// auditExportLockID is the advisory lock held while exporting the audit log, so
+// that only one server exports at a time and entries leave in order
+const auditExportLockID = 7305693462816140004
+
+func scanAuditEntry(row rowScanner) (*models.AuditEntry, error) {
+	var entry models.AuditEntry
+	var details []byte
+	if err := row.Scan(&entry.ID, &entry.Action, &entry.Actor, &details, &entry.OccurredAt); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(details, &entry.Details); err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+// recordAudit appends an entry to the audit log within tx, so that it is only
+// kept if the audited operation commits
+func recordAudit(ctx context.Context, tx *sql.Tx, action, actor string, details map[string]interface{}) (int64, error) {
+	encoded, err := json.Marshal(details)
+	if err != nil {
+		return 0, err
+	}
+
+	var id int64
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO config_audit_log (action, actor, details, occurred_at)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id`, action, actor, encoded, time.Now()).Scan(&id)
+	return id, err
+}
+
+// GetAuditLog returns up to limit audit entries, newest first, optionally only
+// those of one action
+func (r *Repository) GetAuditLog(ctx context.Context, action string, limit int) ([]models.AuditEntry, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT `+auditColumns+` FROM config_audit_log
+		WHERE $1 = '' OR action = $1
+		ORDER BY occurred_at DESC, id DESC
+		LIMIT $2`, action, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []models.AuditEntry{}
+	for rows.Next() {
+		entry, err := scanAuditEntry(rows)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, *entry)
+	}
+	return entries, rows.Err()
+}
+
+// DispatchAuditLog passes up to limit audit entries that were not exported yet
+// to export, oldest first, and marks them exported once it returns nil. If
+// export fails the entries are passed again on the next call. It returns the
+// number of entries exported, which is zero when another server holds the
+// export lock.
+func (r *Repository) DispatchAuditLog(ctx context.Context, limit int, export func([]models.AuditEntry) error) (int, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var locked bool
+	if err := tx.QueryRowContext(ctx, `SELECT pg_try_advisory_xact_lock($1)`, int64(auditExportLockID)).Scan(&locked); err != nil {
+		return 0, err
+	}
+	if !locked {
+		return 0, nil
+	}
+
+	rows, err := tx.QueryContext(ctx, `
+		SELECT `+auditColumns+` FROM config_audit_log
+		WHERE exported_at IS NULL
+		ORDER BY id
+		LIMIT $1`, limit)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	var ids []int64
+	pending := []models.AuditEntry{}
+	for rows.Next() {
+		entry, err := scanAuditEntry(rows)
+		if err != nil {
+			return 0, err
+		}
+		ids = append(ids, entry.ID)
+		pending = append(pending, *entry)
+	}
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+	rows.Close()
+
+	if len(pending) == 0 {
+		return 0, nil
+	}
+	if err := export(pending); err != nil {
+		return 0, err
+	}
+
+	_, err = tx.ExecContext(ctx, `UPDATE config_audit_log SET exported_at = $1 WHERE id = ANY($2)`, time.Now(), ids)
+	if err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return len(pending), nil
+}
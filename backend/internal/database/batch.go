@@ -0,0 +1,134 @@
+package database
+
+import (
+	"config-manager/internal/models"
+	"context"
+	"fmt"
+)
+
+// BatchError is returned when an operation of a batch fails, in which case
+// none of the batch is applied
+type BatchError struct {
+	Index int // Of the failed operation, from 0
+	Err   error
+}
+
+func (e *BatchError) Error() string {
+	return fmt.Sprintf("operations[%d]: %v", e.Index, e.Err)
+}
+
+func (e *BatchError) Unwrap() error {
+	return e.Err
+}
+
+// ApplyBatch applies the operations of a batch in order in a single
+// transaction, so that either all of them are applied or none. A negative node
+// ID refers to the node created by an earlier operation, -1 to the first one.
+// Properties cannot override keys an ancestor locks as final, including
+// ancestors created earlier in the batch.
+func (r *Repository) ApplyBatch(ctx context.Context, ops []models.BatchOperation) ([]models.BatchResult, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `SET LOCAL config_manager.event_source = 'batch'`); err != nil {
+		return nil, err
+	}
+
+	created := make([]int64, len(ops))
+	liveID := func(id *int64) *int64 {
+		if id == nil || *id >= 0 {
+			return id
+		}
+		mapped := int64(0)
+		if index := int(-*id) - 1; index < len(created) {
+			mapped = created[index]
+		}
+		return &mapped
+	}
+
+	results := make([]models.BatchResult, len(ops))
+	for i, op := range ops {
+		result := models.BatchResult{Op: op.Op, NodeID: liveID(op.NodeID), PropertyID: op.PropertyID}
+
+		switch op.Op {
+		case models.BatchOpCreateNode:
+			req := *op.CreateNode
+			req.ParentID = liveID(req.ParentID)
+			result.Node, err = createNode(ctx, tx, req)
+			if err == nil {
+				created[i] = result.Node.ID
+				result.NodeID = &result.Node.ID
+			}
+
+		case models.BatchOpUpdateNode:
+			result.Node, err = updateNode(ctx, tx, *result.NodeID, *op.UpdateNode)
+			if err == nil && result.Node == nil {
+				err = ErrNodeNotFound
+			}
+
+		case models.BatchOpDeleteNode:
+			err = deleteNode(ctx, tx, *result.NodeID)
+
+		case models.BatchOpCreateProperty:
+			result.Property, err = r.batchCreateProperty(ctx, tx, *result.NodeID, *op.CreateProperty)
+			if err == nil {
+				result.PropertyID = &result.Property.ID
+			}
+
+		case models.BatchOpUpdateProperty:
+			result.Property, err = r.updateProperty(ctx, tx, *op.PropertyID, *op.UpdateProperty)
+			if err == nil && result.Property == nil {
+				err = ErrPropertyNotFound
+			}
+			if err == nil {
+				result.NodeID = &result.Property.NodeID
+			}
+
+		case models.BatchOpDeleteProperty:
+			err = deleteProperty(ctx, tx, *op.PropertyID)
+
+		default:
+			err = fmt.Errorf("unknown op %q", op.Op)
+		}
+		if isNodeNameConflict(err) {
+			err = ErrNodeNameTaken
+		}
+		if err != nil {
+			return nil, &BatchError{Index: i, Err: err}
+		}
+		results[i] = result
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// batchCreateProperty creates a property within a batch, after checking that
+// its node exists and that no ancestor locks its key
+func (r *Repository) batchCreateProperty(ctx context.Context, tx querier, nodeID int64, req models.CreatePropertyRequest) (*models.ConfigProperty, error) {
+	var exists bool
+	if err := tx.QueryRowContext(ctx, `SELECT EXISTS(SELECT 1 FROM config_nodes WHERE id = $1)`, nodeID).Scan(&exists); err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, ErrNodeNotFound
+	}
+
+	locking, err := r.lockingProperty(ctx, tx, nodeID, req.Key)
+	if err != nil {
+		return nil, err
+	}
+	if locking != nil {
+		return nil, ErrKeyFinal
+	}
+
+	return r.createProperty(ctx, tx, nodeID, req)
+}
@@ -0,0 +1,384 @@
+package database
+
+import (
+	"config-manager/internal/models"
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+var (
+	ErrProtectionNotFound       = errors.New("node is not protected")
+	ErrChangeRequestNotFound    = errors.New("change request not found")
+	ErrChangeRequestNotOpen     = errors.New("change request is not open for review")
+	ErrChangeRequestNotApproved = errors.New("change request is not approved")
+	ErrChangeRequestExists      = errors.New("workspace already has an active change request")
+	ErrSelfReview               = errors.New("change requests cannot be reviewed by their author")
+)
+
+// Subtree protection
+
+const nodeProtectionColumns = `node_id, required_approvals, created_at`
+
+func scanNodeProtection(row rowScanner) (*models.NodeProtection, error) {
+	var protection models.NodeProtection
+	if err := row.Scan(&protection.NodeID, &protection.RequiredApprovals, &protection.CreatedAt); err != nil {
+		return nil, err
+	}
+	return &protection, nil
+}
+
+// SetNodeProtection protects the subtree rooted at nodeID, replacing the number
+// of required approvals if it already was
+func (r *Repository) SetNodeProtection(ctx context.Context, nodeID int64, requiredApprovals int) (*models.NodeProtection, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	query := `
+		INSERT INTO protected_subtrees (node_id, required_approvals, created_at)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (node_id)
+		DO UPDATE SET required_approvals = EXCLUDED.required_approvals
+		RETURNING ` + nodeProtectionColumns
+
+	return scanNodeProtection(r.db.QueryRowContext(ctx, query, nodeID, requiredApprovals, time.Now()))
+}
+
+func (r *Repository) GetNodeProtections(ctx context.Context) ([]models.NodeProtection, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := r.db.QueryContext(ctx, `SELECT `+nodeProtectionColumns+` FROM protected_subtrees ORDER BY node_id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	protections := []models.NodeProtection{}
+	for rows.Next() {
+		protection, err := scanNodeProtection(rows)
+		if err != nil {
+			return nil, err
+		}
+		protections = append(protections, *protection)
+	}
+
+	return protections, nil
+}
+
+func (r *Repository) RemoveNodeProtection(ctx context.Context, nodeID int64) error {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	result, err := r.db.ExecContext(ctx, `DELETE FROM protected_subtrees WHERE node_id = $1`, nodeID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrProtectionNotFound
+	}
+
+	return nil
+}
+
+// RequiredApprovals returns the number of approvals needed to modify nodeID:
+// the highest requirement of the protected subtrees containing it, or 0 if it
+// is not protected. With includeDescendants, protected subtrees below nodeID
+// count too, for operations such as deletes that affect the whole subtree.
+func (r *Repository) RequiredApprovals(ctx context.Context, nodeID int64, includeDescendants bool) (int, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	query := `
+		WITH RECURSIVE ancestors AS (
+			SELECT id, parent_id FROM config_nodes WHERE id = $1
+			UNION ALL
+			SELECT n.id, n.parent_id FROM config_nodes n JOIN ancestors a ON n.id = a.parent_id
+		),
+		descendants AS (
+			SELECT id FROM config_nodes WHERE id = $1 AND $2
+			UNION ALL
+			SELECT n.id FROM config_nodes n JOIN descendants d ON n.parent_id = d.id
+		)
+		SELECT COALESCE(MAX(p.required_approvals), 0) FROM protected_subtrees p
+		WHERE p.node_id IN (SELECT id FROM ancestors UNION SELECT id FROM descendants)`
+
+	var required int
+	err := r.db.QueryRowContext(ctx, query, nodeID, includeDescendants).Scan(&required)
+	return required, err
+}
+
+// Change requests
+
+const changeRequestColumns = `id, workspace_id, title, description, author, status, required_approvals, created_at, updated_at, applied_at`
+
+func scanChangeRequest(row rowScanner) (*models.ChangeRequest, error) {
+	var cr models.ChangeRequest
+	err := row.Scan(&cr.ID, &cr.WorkspaceID, &cr.Title, &cr.Description, &cr.Author, &cr.Status, &cr.RequiredApprovals, &cr.CreatedAt, &cr.UpdatedAt, &cr.AppliedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &cr, nil
+}
+
+// CreateChangeRequest submits an open workspace for review. The workspace can
+// no longer be edited while the request is open or approved.
+func (r *Repository) CreateChangeRequest(ctx context.Context, req models.CreateChangeRequestRequest, author string, requiredApprovals int) (*models.ChangeRequest, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	var status models.WorkspaceStatus
+	err := r.db.QueryRowContext(ctx, `SELECT status FROM workspaces WHERE id = $1`, req.WorkspaceID).Scan(&status)
+	if err == sql.ErrNoRows {
+		return nil, ErrWorkspaceNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if status != models.WorkspaceStatusOpen {
+		return nil, ErrWorkspaceNotOpen
+	}
+
+	existing, err := r.GetActiveChangeRequest(ctx, req.WorkspaceID)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		return nil, ErrChangeRequestExists
+	}
+
+	query := `
+		INSERT INTO change_requests (workspace_id, title, description, author, status, required_approvals, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $7)
+		RETURNING ` + changeRequestColumns
+
+	return scanChangeRequest(r.db.QueryRowContext(ctx, query,
+		req.WorkspaceID, req.Title, req.Description, author, models.ChangeRequestStatusOpen, requiredApprovals, time.Now()))
+}
+
+// GetChangeRequests lists change requests, optionally only those with the given status
+func (r *Repository) GetChangeRequests(ctx context.Context, status models.ChangeRequestStatus) ([]models.ChangeRequest, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	query := `SELECT ` + changeRequestColumns + ` FROM change_requests WHERE ($1 = '' OR status = $1) ORDER BY created_at DESC, id DESC`
+
+	rows, err := r.db.QueryContext(ctx, query, status)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	changeRequests := []models.ChangeRequest{}
+	for rows.Next() {
+		cr, err := scanChangeRequest(rows)
+		if err != nil {
+			return nil, err
+		}
+		changeRequests = append(changeRequests, *cr)
+	}
+
+	return changeRequests, nil
+}
+
+// GetChangeRequest returns a change request with its reviews and the proposed
+// workspace edits, or nil if it does not exist
+func (r *Repository) GetChangeRequest(ctx context.Context, id int64) (*models.ChangeRequestDetails, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	cr, err := scanChangeRequest(r.db.QueryRowContext(ctx, `SELECT `+changeRequestColumns+` FROM change_requests WHERE id = $1`, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	reviews, err := r.getChangeRequestReviews(ctx, r.db, id)
+	if err != nil {
+		return nil, err
+	}
+
+	changes, err := r.getWorkspaceChanges(ctx, r.db, cr.WorkspaceID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.ChangeRequestDetails{ChangeRequest: *cr, Reviews: reviews, Changes: changes}, nil
+}
+
+// GetActiveChangeRequest returns the open or approved change request of a workspace, if any
+func (r *Repository) GetActiveChangeRequest(ctx context.Context, workspaceID int64) (*models.ChangeRequest, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	cr, err := scanChangeRequest(r.db.QueryRowContext(ctx, `
+		SELECT `+changeRequestColumns+` FROM change_requests
+		WHERE workspace_id = $1 AND status IN ($2, $3)`,
+		workspaceID, models.ChangeRequestStatusOpen, models.ChangeRequestStatusApproved))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return cr, err
+}
+
+func (r *Repository) getChangeRequestReviews(ctx context.Context, q querier, changeRequestID int64) ([]models.ChangeRequestReview, error) {
+	rows, err := q.QueryContext(ctx, `
+		SELECT id, change_request_id, reviewer, decision, comment, created_at
+		FROM change_request_reviews WHERE change_request_id = $1 ORDER BY created_at, id`, changeRequestID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	reviews := []models.ChangeRequestReview{}
+	for rows.Next() {
+		var review models.ChangeRequestReview
+		if err := rows.Scan(&review.ID, &review.ChangeRequestID, &review.Reviewer, &review.Decision, &review.Comment, &review.CreatedAt); err != nil {
+			return nil, err
+		}
+		reviews = append(reviews, review)
+	}
+
+	return reviews, rows.Err()
+}
+
+// ReviewChangeRequest records a reviewer's decision. A rejection closes the
+// request; it is approved once enough distinct reviewers other than the author
+// have approved it.
+func (r *Repository) ReviewChangeRequest(ctx context.Context, id int64, reviewer string, req models.ReviewChangeRequestRequest) (*models.ChangeRequest, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	cr, err := scanChangeRequest(tx.QueryRowContext(ctx, `SELECT `+changeRequestColumns+` FROM change_requests WHERE id = $1 FOR UPDATE`, id))
+	if err == sql.ErrNoRows {
+		return nil, ErrChangeRequestNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if cr.Status != models.ChangeRequestStatusOpen {
+		return nil, ErrChangeRequestNotOpen
+	}
+	if cr.Author == reviewer {
+		return nil, ErrSelfReview
+	}
+
+	now := time.Now()
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO change_request_reviews (change_request_id, reviewer, decision, comment, created_at)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (change_request_id, reviewer)
+		DO UPDATE SET decision = EXCLUDED.decision, comment = EXCLUDED.comment, created_at = EXCLUDED.created_at`,
+		id, reviewer, req.Decision, req.Comment, now)
+	if err != nil {
+		return nil, err
+	}
+
+	status := cr.Status
+	if req.Decision == models.ReviewDecisionReject {
+		status = models.ChangeRequestStatusRejected
+	} else {
+		var approvals int
+		err := tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM change_request_reviews WHERE change_request_id = $1 AND decision = $2`,
+			id, models.ReviewDecisionApprove).Scan(&approvals)
+		if err != nil {
+			return nil, err
+		}
+		if approvals >= cr.RequiredApprovals {
+			status = models.ChangeRequestStatusApproved
+		}
+	}
+
+	cr, err = scanChangeRequest(tx.QueryRowContext(ctx, `
+		UPDATE change_requests SET status = $1, updated_at = $2 WHERE id = $3
+		RETURNING `+changeRequestColumns, status, now, id))
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return cr, nil
+}
+
+// WithdrawChangeRequest closes an open or approved request without applying it,
+// unlocking its workspace for further edits
+func (r *Repository) WithdrawChangeRequest(ctx context.Context, id int64) error {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	return r.closeChangeRequest(ctx, id, models.ChangeRequestStatusWithdrawn)
+}
+
+func (r *Repository) closeChangeRequest(ctx context.Context, id int64, status models.ChangeRequestStatus) error {
+	result, err := r.db.ExecContext(ctx, `
+		UPDATE change_requests SET status = $1, updated_at = $2
+		WHERE id = $3 AND status IN ($4, $5)`,
+		status, time.Now(), id, models.ChangeRequestStatusOpen, models.ChangeRequestStatusApproved)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		var exists bool
+		if err := r.db.QueryRowContext(ctx, `SELECT EXISTS(SELECT 1 FROM change_requests WHERE id = $1)`, id).Scan(&exists); err != nil {
+			return err
+		}
+		if !exists {
+			return ErrChangeRequestNotFound
+		}
+		return ErrChangeRequestNotOpen
+	}
+
+	return nil
+}
+
+// ApplyChangeRequest merges the workspace of an approved change request into
+// the live tree. Merge conflicts are returned as *WorkspaceConflictError and
+// leave the request approved, so it can be retried after rebasing the workspace.
+func (r *Repository) ApplyChangeRequest(ctx context.Context, id int64) (*models.WorkspaceMergeResult, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	cr, err := scanChangeRequest(r.db.QueryRowContext(ctx, `SELECT `+changeRequestColumns+` FROM change_requests WHERE id = $1`, id))
+	if err == sql.ErrNoRows {
+		return nil, ErrChangeRequestNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if cr.Status != models.ChangeRequestStatusApproved {
+		return nil, ErrChangeRequestNotApproved
+	}
+
+	result, err := r.MergeWorkspace(ctx, cr.WorkspaceID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	_, err = r.db.ExecContext(ctx, `UPDATE change_requests SET status = $1, updated_at = $2, applied_at = $2 WHERE id = $3`,
+		models.ChangeRequestStatusApplied, now, id)
+	return result, err
+}
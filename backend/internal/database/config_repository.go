@@ -0,0 +1,171 @@
+package database
+
+import (
+	"config-manager/internal/models"
+	"context"
+	"time"
+)
+
+// ConfigRepository is the storage the HTTP handlers and their middleware work
+// against. Repository implements it on Postgres; MemoryRepository keeps the
+// tree in memory for tests and demos.
+type ConfigRepository interface {
+	Ping(ctx context.Context) error
+
+	// Nodes and properties
+	CreateNode(ctx context.Context, req models.CreateNodeRequest) (*models.ConfigNode, error)
+	CreateProperty(ctx context.Context, nodeID int64, req models.CreatePropertyRequest) (*models.ConfigProperty, error)
+	DeleteNode(ctx context.Context, id int64) error
+	DeleteProperty(ctx context.Context, id int64) error
+	GetAllNodes(ctx context.Context) ([]models.ConfigNode, error)
+	GetChildNodes(ctx context.Context, parentID int64) ([]models.ConfigNode, error)
+	GetChildNodesPage(ctx context.Context, parentID int64, after *int64, limit int) ([]models.ConfigNode, error)
+	GetEffectiveProperties(ctx context.Context, nodeID int64, environment string) (map[string]models.ConfigProperty, error)
+	GetInheritedProperty(ctx context.Context, nodeID int64, key string, environment *string) (*models.ConfigProperty, error)
+	GetNodeByID(ctx context.Context, id int64) (*models.ConfigNode, error)
+	GetNodeWithChildren(ctx context.Context, id int64) (*models.ConfigNodeWithChildren, error)
+	GetNodeWithProperties(ctx context.Context, id int64) (*models.ConfigNodeWithProperties, error)
+	GetNodePath(ctx context.Context, nodeID int64) ([]models.ConfigNode, error)
+	GetPropertiesByNodeID(ctx context.Context, nodeID int64) ([]models.ConfigProperty, error)
+	GetRootNodes(ctx context.Context) ([]models.ConfigNode, error)
+	ResolveConfiguration(ctx context.Context, nodeID int64, opts models.ResolveOptions) (*models.ResolvedConfiguration, error)
+	UpdateNode(ctx context.Context, id int64, req models.UpdateNodeRequest) (*models.ConfigNode, error)
+	UpdateProperty(ctx context.Context, id int64, req models.UpdatePropertyRequest) (*models.ConfigProperty, error)
+	SiblingNameTaken(ctx context.Context, parentID *int64, name string, excludeID int64) (bool, error)
+	ReorderChildren(ctx context.Context, parentID *int64, ids []int64) ([]models.ConfigNode, error)
+	FindNodesByLabels(ctx context.Context, match map[string]string, keys []string) ([]models.ConfigNode, error)
+	GetTree(ctx context.Context, rootID *int64, maxDepth int) ([]models.ConfigTreeNode, error)
+	CheckEncryptionKey(ctx context.Context, nodeID int64) error
+	CountInheritingNodes(ctx context.Context, nodeID int64, key string, environment *string) (int, error)
+	GetProperty(ctx context.Context, id int64) (*models.ConfigProperty, error)
+	RevealProperty(ctx context.Context, id int64, actor string) (*models.ConfigProperty, error)
+	CountDescendantDefinitions(ctx context.Context, nodeID int64, key string) (int, error)
+	GetLockingProperty(ctx context.Context, nodeID int64, key string) (*models.ConfigProperty, error)
+	GetAllProperties(ctx context.Context) ([]models.ConfigProperty, error)
+	ApplyBatch(ctx context.Context, ops []models.BatchOperation) ([]models.BatchResult, error)
+
+	// Bulk edits
+	PromoteProperty(ctx context.Context, propertyID int64, removeIdentical bool) (*models.PromotePropertyResult, error)
+	PushDownProperty(ctx context.Context, propertyID int64) (*models.PushDownPropertyResult, error)
+	RenameKey(ctx context.Context, oldKey, newKey, actor string) (*models.KeyRenameResult, error)
+	RequiredApprovalsForKey(ctx context.Context, key string) (int, error)
+	ApplyValueReplacement(ctx context.Context, replacements []models.ValueReplacement, actor string, details map[string]interface{}) (int64, error)
+	PlanValueReplacement(ctx context.Context, rootID int64, key string, replace func(string) string) ([]models.ValueReplacement, error)
+	ApplyDocument(ctx context.Context, parentID *int64, doc models.DeclaredNode, dryRun bool) (*models.ApplyResult, error)
+	FindNodesByName(ctx context.Context, parentID *int64, name string) ([]models.ConfigNode, error)
+	DetectDrift(ctx context.Context, parentID *int64, doc models.DeclaredNode) (*models.DriftReport, error)
+
+	// Reports
+	GetOverrides(ctx context.Context, nodeID int64, environment string) (*models.OverrideReport, error)
+	AnalyzeImpact(ctx context.Context, change models.PropertyDraft) (*models.ImpactReport, error)
+	GetKeyInventory(ctx context.Context, rootID int64) ([]models.KeyInventoryEntry, error)
+	SearchPropertiesByTags(ctx context.Context, tags []string, rootID *int64) ([]models.PropertySearchResult, error)
+	GetExpiringProperties(ctx context.Context, until time.Time, rootID *int64) ([]models.PropertySearchResult, error)
+	DeprecateKey(ctx context.Context, req models.DeprecateKeyRequest, createdBy string) (*models.KeyDeprecation, error)
+	GetDeprecatedDefinitions(ctx context.Context, rootID *int64) ([]models.PropertySearchResult, error)
+	GetKeyDeprecations(ctx context.Context, keys []string) ([]models.KeyDeprecation, error)
+	UndeprecateKey(ctx context.Context, key string) (bool, error)
+	GetRequiredKeys(ctx context.Context, nodeID int64) ([]models.RequiredKey, error)
+	RequireKey(ctx context.Context, nodeID int64, req models.CreateRequiredKeyRequest, createdBy string) (*models.RequiredKey, error)
+	UnrequireKey(ctx context.Context, nodeID int64, key string) (bool, error)
+	ValidateCompleteness(ctx context.Context, nodeID int64, environment string) (*models.CompletenessReport, error)
+	CreateSnapshot(ctx context.Context, nodeID int64, req models.CreateSnapshotRequest, createdBy string) (*models.ConfigSnapshot, error)
+	GetSnapshot(ctx context.Context, id int64) (*models.ConfigSnapshot, error)
+	GetSnapshots(ctx context.Context, nodeID int64) ([]models.ConfigSnapshot, error)
+	ResolveRedacted(ctx context.Context, nodeID int64, environment string) (*models.ResolvedConfiguration, []string, error)
+
+	// Key registry
+	GetKeyCatalog(ctx context.Context) ([]models.KeyCatalogEntry, error)
+	GetRegisteredKey(ctx context.Context, key string) (*models.RegisteredKey, error)
+	RegisterKey(ctx context.Context, req models.RegisterKeyRequest, createdBy string) (*models.RegisteredKey, error)
+	UnregisterKey(ctx context.Context, key string) (bool, error)
+	UnregisteredKeys(ctx context.Context, keys []string) ([]string, error)
+
+	// Drafts, workspaces and change requests
+	DiscardPropertyDrafts(ctx context.Context, nodeID int64, key string) (int64, error)
+	GetPropertyDrafts(ctx context.Context, nodeID int64) ([]models.PropertyDraft, error)
+	PublishPropertyDrafts(ctx context.Context, nodeID int64) (*models.PublishDraftsResult, error)
+	SavePropertyDraft(ctx context.Context, nodeID int64, req models.SavePropertyDraftRequest) (*models.PropertyDraft, error)
+	AddWorkspaceChange(ctx context.Context, workspaceID int64, req models.WorkspaceChangeRequest) (*models.WorkspaceChange, error)
+	CreateWorkspace(ctx context.Context, req models.CreateWorkspaceRequest) (*models.Workspace, error)
+	DiscardWorkspace(ctx context.Context, id int64) error
+	GetWorkspace(ctx context.Context, id int64) (*models.WorkspaceWithChanges, error)
+	GetWorkspaces(ctx context.Context) ([]models.Workspace, error)
+	MergeWorkspace(ctx context.Context, workspaceID int64) (*models.WorkspaceMergeResult, error)
+	ResolveInWorkspace(ctx context.Context, workspaceID, nodeID int64) (*models.ResolvedConfiguration, error)
+	ApplyChangeRequest(ctx context.Context, id int64) (*models.WorkspaceMergeResult, error)
+	CreateChangeRequest(ctx context.Context, req models.CreateChangeRequestRequest, author string, requiredApprovals int) (*models.ChangeRequest, error)
+	GetActiveChangeRequest(ctx context.Context, workspaceID int64) (*models.ChangeRequest, error)
+	GetChangeRequest(ctx context.Context, id int64) (*models.ChangeRequestDetails, error)
+	GetChangeRequests(ctx context.Context, status models.ChangeRequestStatus) ([]models.ChangeRequest, error)
+	GetNodeProtections(ctx context.Context) ([]models.NodeProtection, error)
+	RemoveNodeProtection(ctx context.Context, nodeID int64) error
+	RequiredApprovals(ctx context.Context, nodeID int64, includeDescendants bool) (int, error)
+	ReviewChangeRequest(ctx context.Context, id int64, reviewer string, req models.ReviewChangeRequestRequest) (*models.ChangeRequest, error)
+	SetNodeProtection(ctx context.Context, nodeID int64, requiredApprovals int) (*models.NodeProtection, error)
+	WithdrawChangeRequest(ctx context.Context, id int64) error
+	CancelScheduledChange(ctx context.Context, id int64) error
+	CreateScheduledChange(ctx context.Context, nodeID int64, req models.CreateScheduledChangeRequest) (*models.ScheduledChange, error)
+	GetScheduledChangeNodeID(ctx context.Context, id int64) (*int64, error)
+	GetScheduledChanges(ctx context.Context, nodeID int64) ([]models.ScheduledChange, error)
+
+	// Templates
+	AttachTemplate(ctx context.Context, nodeID int64, req models.AttachTemplateRequest, attachedBy string) (*models.NodeTemplate, error)
+	CreateTemplate(ctx context.Context, req models.CreateTemplateRequest, createdBy string) (*models.PropertyTemplateWithVersion, error)
+	CreateTemplateVersion(ctx context.Context, id int64, req models.CreateTemplateVersionRequest, createdBy string) (*models.TemplateVersion, error)
+	DeleteTemplate(ctx context.Context, id int64) (bool, error)
+	DetachTemplate(ctx context.Context, nodeID, templateID int64) (bool, error)
+	GetNodeTemplates(ctx context.Context, nodeID int64) ([]models.NodeTemplate, error)
+	GetTemplate(ctx context.Context, id int64) (*models.PropertyTemplateWithVersion, error)
+	GetTemplateVersion(ctx context.Context, id int64, version int) (*models.TemplateVersion, error)
+	GetTemplateVersions(ctx context.Context, id int64) ([]models.TemplateVersion, error)
+	GetTemplates(ctx context.Context) ([]models.PropertyTemplate, error)
+
+	// Access control and API keys
+	GetNodePermissions(ctx context.Context, nodeID int64) ([]models.NodePermission, error)
+	GetPropertyNodeID(ctx context.Context, propertyID int64) (*int64, error)
+	GrantNodePermission(ctx context.Context, nodeID int64, req models.GrantPermissionRequest) (*models.NodePermission, error)
+	HasNodePermission(ctx context.Context, nodeID int64, principals []string, perm models.Permission) (bool, error)
+	RevokeNodePermission(ctx context.Context, id int64) error
+	AuthenticateAPIKey(ctx context.Context, secret string) (*models.APIKey, error)
+	CreateAPIKey(ctx context.Context, req models.CreateAPIKeyRequest) (*models.CreatedAPIKey, error)
+	GetAPIKeys(ctx context.Context) ([]models.APIKey, error)
+	RevokeAPIKey(ctx context.Context, id int64) error
+	RotateAPIKey(ctx context.Context, id int64, grace time.Duration, expiresAt *time.Time) (*models.CreatedAPIKey, error)
+	GetTOTPEnrollment(ctx context.Context, subject string) (*models.TOTPEnrollment, error)
+	EnrollTOTP(ctx context.Context, subject, secret string) (*models.TOTPEnrollment, error)
+	UseTOTPStep(ctx context.Context, subject string, step int64) (bool, error)
+	DeleteTOTPEnrollment(ctx context.Context, subject string) error
+	CreateEncryptionKey(ctx context.Context, nodeID int64) (*models.EncryptionKey, error)
+	GetEncryptionKeys(ctx context.Context) ([]models.EncryptionKey, error)
+	CreateKeyRotation(ctx context.Context, keyID int64, scheduledAt time.Time, actor string) (*models.KeyRotation, error)
+	GetKeyRotations(ctx context.Context) ([]models.KeyRotation, error)
+	GetKeyRotation(ctx context.Context, id int64) (*models.KeyRotation, error)
+	CancelKeyRotation(ctx context.Context, id int64) error
+	ResumeKeyRotation(ctx context.Context, id int64) (*models.KeyRotation, error)
+	LastSubtreeAccess(ctx context.Context, nodeID int64) (*time.Time, error)
+	RecordNodeAccess(ctx context.Context, nodeID int64) error
+	CompleteIdempotencyKey(ctx context.Context, principal, key string, status int, contentType string, body []byte) error
+	ReleaseIdempotencyKey(ctx context.Context, principal, key string) error
+	ReserveIdempotencyKey(ctx context.Context, principal, key, requestHash string, expiredBefore, abandonedBefore time.Time) (*IdempotencyRecord, error)
+
+	// Organization quotas
+	GetQuotas(ctx context.Context) ([]models.OrganizationQuota, error)
+	SetQuota(ctx context.Context, rootNodeID *int64, req models.SetQuotaRequest) (*models.OrganizationQuota, error)
+	DeleteQuota(ctx context.Context, rootNodeID *int64) error
+	GetRootNodeID(ctx context.Context, nodeID int64) (*int64, error)
+	GetOrganizationUsage(ctx context.Context, nodeID int64) (*models.OrganizationUsage, error)
+
+	// Change feed, audit and maintenance
+	GetChanges(ctx context.Context, since int64, limit int) ([]models.Change, error)
+	OldestChangeCursor(ctx context.Context) (*int64, error)
+	GetAuditLog(ctx context.Context, action string, limit int) ([]models.AuditEntry, error)
+	GetStats(ctx context.Context, topKeys int) (*models.AdminStats, error)
+	RebuildDerivedData(ctx context.Context) *models.RebuildReport
+}
+
+var (
+	_ ConfigRepository = (*Repository)(nil)
+	_ ConfigRepository = (*MemoryRepository)(nil)
+	_ ConfigRepository = (*CoreRepository)(nil)
+)
@@ -0,0 +1,1078 @@
+package database
+
+import (
+	"config-manager/internal/encryption"
+	"config-manager/internal/models"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// CoreRepository is a ConfigRepository on a SQLite or MySQL database, for
+// development and installs that do without a Postgres server. It covers the
+// same core as MemoryRepository, the tree, properties, resolution, batches,
+// the delete guard and idempotency keys, but keeps them in the database. The
+// other features return ErrNotSupported, and properties cannot be encrypted.
+type CoreRepository struct {
+	unsupportedFeatures
+
+	db      *DB
+	q       querier
+	dialect *dialect
+}
+
+// NewCoreRepository creates a repository on a database opened with
+// DriverSQLite or DriverMySQL and migrated
+func NewCoreRepository(db *DB) *CoreRepository {
+	d := &sqliteDialect
+	if db.driver == DriverMySQL {
+		d = &mysqlDialect
+	}
+	return &CoreRepository{db: db, q: d.querier(db), dialect: d}
+}
+
+// Ping checks that the underlying database is reachable
+func (s *CoreRepository) Ping(ctx context.Context) error {
+	return s.db.Ping(ctx)
+}
+
+// PoolStats returns the state of the connection pool of the database
+func (s *CoreRepository) PoolStats() PoolStats {
+	return s.db.PoolStats()
+}
+
+// withTimeout bounds a repository call by the query timeout, like Repository
+func (s *CoreRepository) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if s.db.queryTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, s.db.queryTimeout)
+}
+
+// begin starts a transaction, returned with a querier for it
+func (s *CoreRepository) begin(ctx context.Context) (*sql.Tx, querier, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	return tx, s.dialect.querier(tx), nil
+}
+
+// isNameConflict reports whether err is a violation of the unique sibling
+// name indexes
+func (s *CoreRepository) isNameConflict(err error) bool {
+	return s.dialect.isUniqueViolation(err, "idx_config_nodes_sibling_name") || s.dialect.isUniqueViolation(err, "idx_config_nodes_root_name")
+}
+
+// Node operations
+
+func (s *CoreRepository) CreateNode(ctx context.Context, req models.CreateNodeRequest) (*models.ConfigNode, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	return s.createNode(ctx, s.q, req)
+}
+
+func (s *CoreRepository) createNode(ctx context.Context, q querier, req models.CreateNodeRequest) (*models.ConfigNode, error) {
+	// MySQL only reads the table it inserts into with INSERT ... SELECT
+	query := `
+		INSERT INTO config_nodes (name, node_type, parent_id, description, labels, metadata, sort_index, created_at, updated_at)
+		SELECT $1, $2, $3, $4, $5, $6, COALESCE(MAX(sort_index) + 1, 0), $7, $8
+		FROM config_nodes WHERE parent_id ` + s.dialect.nullSafeEqual + ` $3`
+
+	labels, err := encodeLabels(req.Labels)
+	if err != nil {
+		return nil, err
+	}
+	metadata, err := encodeMetadata(req.Metadata)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+
+	result, err := q.ExecContext(ctx, query, req.Name, req.NodeType, req.ParentID, req.Description, string(labels), string(metadata), now, now)
+	if s.isNameConflict(err) {
+		return nil, ErrNodeNameTaken
+	}
+	if err != nil {
+		return nil, err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+
+	return scanNode(q.QueryRowContext(ctx, `SELECT `+nodeColumns+` FROM config_nodes WHERE id = $1`, id))
+}
+
+func (s *CoreRepository) GetNodeByID(ctx context.Context, id int64) (*models.ConfigNode, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	node, err := scanNode(s.q.QueryRowContext(ctx, `SELECT `+nodeColumns+` FROM config_nodes WHERE id = $1`, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return node, err
+}
+
+func (s *CoreRepository) GetRootNodes(ctx context.Context) ([]models.ConfigNode, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	return s.listNodes(ctx, `n.parent_id IS NULL`)
+}
+
+func (s *CoreRepository) GetChildNodes(ctx context.Context, parentID int64) ([]models.ConfigNode, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	return s.listNodes(ctx, `n.parent_id = $1`, parentID)
+}
+
+// GetChildNodesPage returns up to limit children of parentID in order, after the child after
+func (s *CoreRepository) GetChildNodesPage(ctx context.Context, parentID int64, after *int64, limit int) ([]models.ConfigNode, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	return childNodesPage(ctx, s.q, parentID, after, limit)
+}
+
+// GetNodeWithChildren returns the node with its children, or nil if it does not exist
+func (s *CoreRepository) GetNodeWithChildren(ctx context.Context, id int64) (*models.ConfigNodeWithChildren, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	node, err := scanNode(s.q.QueryRowContext(ctx, `SELECT `+nodeColumns+` FROM config_nodes WHERE id = $1`, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	children, err := s.listNodes(ctx, `n.parent_id = $1`, id)
+	if err != nil {
+		return nil, err
+	}
+	return &models.ConfigNodeWithChildren{ConfigNode: *node, Children: children}, nil
+}
+
+// listNodes returns the nodes aliased n matching where in order, with their counts
+func (s *CoreRepository) listNodes(ctx context.Context, where string, args ...interface{}) ([]models.ConfigNode, error) {
+	query := `
+		SELECT ` + prefixColumns("n", nodeColumns) + `, ` + nodeCountColumns + `
+		FROM config_nodes n WHERE ` + where + `
+		ORDER BY n.sort_index, n.id`
+
+	rows, err := s.q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanNodesWithCounts(rows)
+}
+
+// GetAllNodes returns every node of the tree, parents before their children
+func (s *CoreRepository) GetAllNodes(ctx context.Context) ([]models.ConfigNode, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	query := `
+		WITH RECURSIVE tree AS (
+			SELECT id, 0 AS depth FROM config_nodes WHERE parent_id IS NULL
+			UNION ALL
+			SELECT n.id, t.depth + 1 FROM config_nodes n JOIN tree t ON n.parent_id = t.id
+		)
+		SELECT ` + prefixColumns("n", nodeColumns) + `
+		FROM config_nodes n JOIN tree t ON n.id = t.id
+		ORDER BY t.depth, n.sort_index, n.id`
+
+	rows, err := s.q.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanNodes(rows)
+}
+
+func (s *CoreRepository) UpdateNode(ctx context.Context, id int64, req models.UpdateNodeRequest) (*models.ConfigNode, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	return s.updateNode(ctx, s.q, id, req)
+}
+
+func (s *CoreRepository) updateNode(ctx context.Context, q querier, id int64, req models.UpdateNodeRequest) (*models.ConfigNode, error) {
+	query := `
+		UPDATE config_nodes
+		SET name = COALESCE($1, name),
+		    description = COALESCE($2, description),
+		    labels = COALESCE($3, labels),
+		    metadata = COALESCE($4, metadata),
+		    updated_at = $5
+		WHERE id = $6`
+
+	var labels, metadata *string
+	if req.Labels != nil {
+		encoded, err := encodeLabels(req.Labels)
+		if err != nil {
+			return nil, err
+		}
+		labels = new(string)
+		*labels = string(encoded)
+	}
+	if req.Metadata != nil {
+		encoded, err := encodeMetadata(req.Metadata)
+		if err != nil {
+			return nil, err
+		}
+		metadata = new(string)
+		*metadata = string(encoded)
+	}
+
+	_, err := q.ExecContext(ctx, query, req.Name, req.Description, labels, metadata, time.Now(), id)
+	if s.isNameConflict(err) {
+		return nil, ErrNodeNameTaken
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	node, err := scanNode(q.QueryRowContext(ctx, `SELECT `+nodeColumns+` FROM config_nodes WHERE id = $1`, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return node, err
+}
+
+// DeleteNode deletes a node with its subtree and their properties
+func (s *CoreRepository) DeleteNode(ctx context.Context, id int64) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	return deleteNode(ctx, s.q, id)
+}
+
+func (s *CoreRepository) GetNodePath(ctx context.Context, nodeID int64) ([]models.ConfigNode, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	return s.nodePath(ctx, nodeID)
+}
+
+// nodePath returns the nodes from the root down to nodeID, empty if it does not exist
+func (s *CoreRepository) nodePath(ctx context.Context, nodeID int64) ([]models.ConfigNode, error) {
+	query := `
+		WITH RECURSIVE ancestors AS (
+			SELECT id, parent_id, 0 AS depth FROM config_nodes WHERE id = $1
+			UNION ALL
+			SELECT n.id, n.parent_id, a.depth + 1
+			FROM config_nodes n JOIN ancestors a ON n.id = a.parent_id
+		)
+		SELECT ` + prefixColumns("n", nodeColumns) + `
+		FROM config_nodes n JOIN ancestors a ON n.id = a.id
+		ORDER BY a.depth DESC`
+
+	rows, err := s.q.QueryContext(ctx, query, nodeID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanNodes(rows)
+}
+
+// SiblingNameTaken reports whether a child of parentID, or a root node when
+// parentID is nil, other than excludeID has name in any case
+func (s *CoreRepository) SiblingNameTaken(ctx context.Context, parentID *int64, name string, excludeID int64) (bool, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	query := `
+		SELECT EXISTS(
+			SELECT 1 FROM config_nodes
+			WHERE parent_id ` + s.dialect.nullSafeEqual + ` $1 AND lower(name) = lower($2) AND id <> $3
+		)`
+
+	var taken bool
+	err := s.q.QueryRowContext(ctx, query, parentID, name, excludeID).Scan(&taken)
+	return taken, err
+}
+
+// ReorderChildren sets the order of the children of parentID, or of the root
+// nodes when parentID is nil, to that of ids, which must list each of them
+// once. It returns the children in their new order.
+func (s *CoreRepository) ReorderChildren(ctx context.Context, parentID *int64, ids []int64) ([]models.ConfigNode, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	tx, q, err := s.begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	siblings := `FROM config_nodes WHERE parent_id ` + s.dialect.nullSafeEqual + ` $1`
+	rows, err := q.QueryContext(ctx, `SELECT id `+siblings, parentID)
+	if err != nil {
+		return nil, err
+	}
+	children := make(map[int64]bool)
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		children[id] = true
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(ids) != len(children) {
+		return nil, fmt.Errorf("%w: got %d IDs for %d children", ErrInvalidOrder, len(ids), len(children))
+	}
+	listed := make(map[int64]bool, len(ids))
+	for _, id := range ids {
+		if !children[id] {
+			return nil, fmt.Errorf("%w: node %d is not a child", ErrInvalidOrder, id)
+		}
+		if listed[id] {
+			return nil, fmt.Errorf("%w: node %d is listed twice", ErrInvalidOrder, id)
+		}
+		listed[id] = true
+	}
+
+	for position, id := range ids {
+		_, err := q.ExecContext(ctx, `UPDATE config_nodes SET sort_index = $1 WHERE id = $2 AND sort_index <> $1`, position, id)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	rows, err = q.QueryContext(ctx, `SELECT `+nodeColumns+` `+siblings+` ORDER BY sort_index, id`, parentID)
+	if err != nil {
+		return nil, err
+	}
+	nodes, err := scanNodes(rows)
+	rows.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	return nodes, tx.Commit()
+}
+
+// FindNodesByName returns the children of parentID, or the root nodes when it
+// is nil, with the given name
+func (s *CoreRepository) FindNodesByName(ctx context.Context, parentID *int64, name string) ([]models.ConfigNode, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := s.q.QueryContext(ctx, `
+		SELECT `+nodeColumns+`
+		FROM config_nodes
+		WHERE parent_id `+s.dialect.nullSafeEqual+` $1 AND name = $2
+		ORDER BY id`, parentID, name)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanNodes(rows)
+}
+
+// FindNodesByLabels returns the nodes anywhere in the tree carrying every label
+// in match with the same value, and every label in keys with any value
+func (s *CoreRepository) FindNodesByLabels(ctx context.Context, match map[string]string, keys []string) ([]models.ConfigNode, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	conditions := []string{"1 = 1"}
+	var args []interface{}
+	for key, value := range match {
+		args = append(args, key, value)
+		conditions = append(conditions, s.dialect.labelEquals(fmt.Sprintf("$%d", len(args)-1), fmt.Sprintf("$%d", len(args))))
+	}
+	for _, key := range keys {
+		args = append(args, key)
+		conditions = append(conditions, s.dialect.hasLabel(fmt.Sprintf("$%d", len(args))))
+	}
+
+	query := `
+		SELECT ` + nodeColumns + `
+		FROM config_nodes
+		WHERE ` + strings.Join(conditions, " AND ") + `
+		ORDER BY id`
+
+	rows, err := s.q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanNodes(rows)
+}
+
+// GetTree returns the subtree of rootID, or the whole tree when rootID is nil,
+// as nested nodes down to maxDepth levels below the roots, or all levels when
+// maxDepth is negative
+func (s *CoreRepository) GetTree(ctx context.Context, rootID *int64, maxDepth int) ([]models.ConfigTreeNode, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	query := `
+		WITH RECURSIVE tree AS (
+			SELECT id, 0 AS depth FROM config_nodes
+			WHERE ($1 IS NULL AND parent_id IS NULL) OR id = $1
+			UNION ALL
+			SELECT n.id, t.depth + 1 FROM config_nodes n JOIN tree t ON n.parent_id = t.id
+			WHERE $2 < 0 OR t.depth < $2
+		)
+		SELECT ` + prefixColumns("n", nodeColumns) + `, ` + nodeCountColumns + `
+		FROM config_nodes n JOIN tree t ON n.id = t.id
+		ORDER BY t.depth, n.sort_index, n.id`
+
+	rows, err := s.q.QueryContext(ctx, query, rootID, maxDepth)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	nodes, err := scanNodesWithCounts(rows)
+	if err != nil {
+		return nil, err
+	}
+	return nestTree(nodes, rootID, maxDepth), nil
+}
+
+// Property operations
+
+// corePropertyColumns are the propertyColumns with key quoted, as MySQL
+// reserves it. Connections to MySQL quote identifiers with double quotes.
+var corePropertyColumns = strings.Replace(propertyColumns, ", key,", `, "key",`, 1)
+
+// scanCoreProperty scans the corePropertyColumns of a row, with the tags
+// stored as a JSON array
+func scanCoreProperty(row rowScanner) (*models.ConfigProperty, error) {
+	var prop models.ConfigProperty
+	var tags string
+	err := row.Scan(
+		&prop.ID, &prop.NodeID, &prop.Key, &prop.Environment, &prop.Value, &prop.DataType, &prop.DefaultValue, &prop.Description, &prop.Encrypted, &prop.EncryptionKeyID, &prop.RolloutPercentage, &prop.RolloutKey, &prop.IsFinal, &prop.Namespace, &tags, &prop.ExpiresAt, &prop.CreatedAt, &prop.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(tags), &prop.Tags); err != nil {
+		return nil, err
+	}
+	if prop.Tags == nil {
+		prop.Tags = []string{}
+	}
+	return &prop, nil
+}
+
+// scanCoreProperties collects all rows of a property query
+func scanCoreProperties(rows *sql.Rows) ([]models.ConfigProperty, error) {
+	var properties []models.ConfigProperty
+	for rows.Next() {
+		prop, err := scanCoreProperty(rows)
+		if err != nil {
+			return nil, err
+		}
+		properties = append(properties, *prop)
+	}
+	return properties, rows.Err()
+}
+
+// encodeTags returns tags as a JSON array, or nil when tags is nil so that
+// updates keep the stored ones
+func encodeTags(tags []string) (*string, error) {
+	if tags == nil {
+		return nil, nil
+	}
+	encoded, err := json.Marshal(NormalizeTags(tags))
+	if err != nil {
+		return nil, err
+	}
+	tagList := string(encoded)
+	return &tagList, nil
+}
+
+// CreateProperty creates a property, or replaces the one defining the same
+// key for the same environment on the node
+func (s *CoreRepository) CreateProperty(ctx context.Context, nodeID int64, req models.CreatePropertyRequest) (*models.ConfigProperty, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	return s.createProperty(ctx, s.q, nodeID, req)
+}
+
+func (s *CoreRepository) createProperty(ctx context.Context, q querier, nodeID int64, req models.CreatePropertyRequest) (*models.ConfigProperty, error) {
+	if req.Encrypted {
+		return nil, encryption.ErrNotConfigured
+	}
+
+	query := `
+		INSERT INTO config_properties (node_id, "key", environment, value, data_type, default_value, description, rollout_percentage, rollout_key, is_final, namespace, tags, expires_at, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15)
+		` + s.dialect.upsert(`node_id, "key", COALESCE(environment, '')`, []string{
+		"value", "data_type", "default_value", "description", "rollout_percentage", "rollout_key",
+		"is_final", "namespace", "tags", "expires_at", "updated_at",
+	})
+
+	tags := req.Tags
+	if tags == nil {
+		tags = []string{}
+	}
+	encodedTags, err := encodeTags(tags)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	_, err = q.ExecContext(ctx, query, nodeID, req.Key, req.Environment, req.Value, req.DataType, req.DefaultValue, req.Description, req.RolloutPercentage, req.RolloutKey, req.IsFinal, req.Namespace, encodedTags, req.ExpiresAt, now, now)
+	if err != nil {
+		return nil, err
+	}
+
+	return scanCoreProperty(q.QueryRowContext(ctx, `
+		SELECT `+corePropertyColumns+` FROM config_properties
+		WHERE node_id = $1 AND "key" = $2 AND environment `+s.dialect.nullSafeEqual+` $3`, nodeID, req.Key, req.Environment))
+}
+
+func (s *CoreRepository) GetPropertiesByNodeID(ctx context.Context, nodeID int64) ([]models.ConfigProperty, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	return s.nodeProperties(ctx, nodeID)
+}
+
+// GetNodeWithProperties returns the node with its properties, or nil if it does not exist
+func (s *CoreRepository) GetNodeWithProperties(ctx context.Context, id int64) (*models.ConfigNodeWithProperties, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	node, err := scanNode(s.q.QueryRowContext(ctx, `SELECT `+nodeColumns+` FROM config_nodes WHERE id = $1`, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	properties, err := s.nodeProperties(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return &models.ConfigNodeWithProperties{ConfigNode: *node, Properties: properties}, nil
+}
+
+func (s *CoreRepository) nodeProperties(ctx context.Context, nodeID int64) ([]models.ConfigProperty, error) {
+	query := `
+		SELECT ` + corePropertyColumns + `
+		FROM config_properties WHERE node_id = $1
+		ORDER BY "key", environment IS NOT NULL, environment`
+
+	rows, err := s.q.QueryContext(ctx, query, nodeID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanCoreProperties(rows)
+}
+
+// GetAllProperties returns every property of the tree, grouped by node
+func (s *CoreRepository) GetAllProperties(ctx context.Context) ([]models.ConfigProperty, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	query := `
+		SELECT ` + corePropertyColumns + `
+		FROM config_properties
+		ORDER BY node_id, "key", environment IS NOT NULL, environment`
+
+	rows, err := s.q.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	properties, err := scanCoreProperties(rows)
+	if properties == nil && err == nil {
+		properties = []models.ConfigProperty{}
+	}
+	return properties, err
+}
+
+// GetProperty returns a property, or nil if it does not exist
+func (s *CoreRepository) GetProperty(ctx context.Context, id int64) (*models.ConfigProperty, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	prop, err := scanCoreProperty(s.q.QueryRowContext(ctx, `SELECT `+corePropertyColumns+` FROM config_properties WHERE id = $1`, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return prop, err
+}
+
+// GetPropertyNodeID returns the node a property belongs to, or nil if the property does not exist
+func (s *CoreRepository) GetPropertyNodeID(ctx context.Context, propertyID int64) (*int64, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	var nodeID int64
+	err := s.q.QueryRowContext(ctx, `SELECT node_id FROM config_properties WHERE id = $1`, propertyID).Scan(&nodeID)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &nodeID, nil
+}
+
+func (s *CoreRepository) UpdateProperty(ctx context.Context, id int64, req models.UpdatePropertyRequest) (*models.ConfigProperty, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	return s.updateProperty(ctx, s.q, id, req)
+}
+
+func (s *CoreRepository) updateProperty(ctx context.Context, q querier, id int64, req models.UpdatePropertyRequest) (*models.ConfigProperty, error) {
+	query := `
+		UPDATE config_properties
+		SET value = COALESCE($1, value),
+		    data_type = COALESCE($2, data_type),
+		    default_value = COALESCE($3, default_value),
+		    description = COALESCE($4, description),
+		    rollout_percentage = COALESCE($5, rollout_percentage),
+		    rollout_key = COALESCE($6, rollout_key),
+		    is_final = COALESCE($7, is_final),
+		    namespace = CASE WHEN $8 IS NULL THEN namespace ELSE NULLIF($8, '') END,
+		    tags = COALESCE($9, tags),
+		    expires_at = CASE WHEN $10 THEN NULL ELSE COALESCE($11, expires_at) END,
+		    updated_at = $12
+		WHERE id = $13`
+
+	tags, err := encodeTags(req.Tags)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = q.ExecContext(ctx, query, req.Value, req.DataType, req.DefaultValue, req.Description, req.RolloutPercentage, req.RolloutKey, req.IsFinal, req.Namespace, tags, req.ClearExpiry, req.ExpiresAt, time.Now(), id)
+	if err != nil {
+		return nil, err
+	}
+
+	prop, err := scanCoreProperty(q.QueryRowContext(ctx, `SELECT `+corePropertyColumns+` FROM config_properties WHERE id = $1`, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return prop, err
+}
+
+func (s *CoreRepository) DeleteProperty(ctx context.Context, id int64) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	return deleteProperty(ctx, s.q, id)
+}
+
+// Configuration resolution
+
+// ResolveConfiguration merges the properties along the path from the root to
+// nodeID like Repository. Past states are not kept, so opts.AsOf is not
+// supported, and there are no drafts to include.
+func (s *CoreRepository) ResolveConfiguration(ctx context.Context, nodeID int64, opts models.ResolveOptions) (*models.ResolvedConfiguration, error) {
+	if opts.AsOf != nil {
+		return nil, ErrNotSupported
+	}
+
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	path, err := s.nodePath(ctx, nodeID)
+	if err != nil {
+		return nil, err
+	}
+	if len(path) == 0 {
+		return nil, ErrNodeNotFound
+	}
+	return resolvePath(path, nil, opts, func(nodeID int64) ([]models.ConfigProperty, error) {
+		return s.nodeProperties(ctx, nodeID)
+	})
+}
+
+// GetEffectiveProperties returns the properties in effect on nodeID after
+// inheritance, keyed by property key, or nil if the node does not exist
+func (s *CoreRepository) GetEffectiveProperties(ctx context.Context, nodeID int64, environment string) (map[string]models.ConfigProperty, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	path, err := s.nodePath(ctx, nodeID)
+	if err != nil || len(path) == 0 {
+		return nil, err
+	}
+
+	effective := make(map[string]models.ConfigProperty)
+	locked := make(map[string]bool)
+	now := time.Now()
+	for _, node := range path {
+		properties, err := s.nodeProperties(ctx, node.ID)
+		if err != nil {
+			return nil, err
+		}
+		for _, prop := range unlocked(unexpired(forEnvironment(properties, environment), now), locked) {
+			effective[prop.Key] = prop
+		}
+	}
+	return effective, nil
+}
+
+// GetInheritedProperty returns the property with the given key defined on the
+// nearest ancestor of nodeID (excluding the node itself) for all environments or
+// for environment, or nil if there is none
+func (s *CoreRepository) GetInheritedProperty(ctx context.Context, nodeID int64, key string, environment *string) (*models.ConfigProperty, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	query := `
+		WITH RECURSIVE ancestors AS (
+			SELECT parent_id AS id, 1 AS depth FROM config_nodes WHERE id = $1
+			UNION ALL
+			SELECT n.parent_id, a.depth + 1
+			FROM config_nodes n JOIN ancestors a ON n.id = a.id
+			WHERE n.parent_id IS NOT NULL
+		)
+		SELECT ` + prefixColumns("p", corePropertyColumns) + `
+		FROM config_properties p
+		JOIN ancestors a ON p.node_id = a.id
+		WHERE p."key" = $2 AND (p.environment IS NULL OR p.environment = $3)
+		ORDER BY a.depth, p.environment IS NULL
+		LIMIT 1`
+
+	prop, err := scanCoreProperty(s.q.QueryRowContext(ctx, query, nodeID, key, environment))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return prop, err
+}
+
+// GetLockingProperty returns the final property that locks key for nodeID,
+// defined on its highest ancestor that locks it, or nil if key is not locked
+func (s *CoreRepository) GetLockingProperty(ctx context.Context, nodeID int64, key string) (*models.ConfigProperty, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	return s.lockingProperty(ctx, s.q, nodeID, key)
+}
+
+func (s *CoreRepository) lockingProperty(ctx context.Context, q querier, nodeID int64, key string) (*models.ConfigProperty, error) {
+	query := `
+		WITH RECURSIVE ancestors AS (
+			SELECT parent_id AS id, 1 AS depth FROM config_nodes WHERE id = $1
+			UNION ALL
+			SELECT n.parent_id, a.depth + 1
+			FROM config_nodes n JOIN ancestors a ON n.id = a.id
+			WHERE n.parent_id IS NOT NULL
+		)
+		SELECT ` + prefixColumns("p", corePropertyColumns) + `
+		FROM config_properties p
+		JOIN ancestors a ON p.node_id = a.id
+		WHERE p."key" = $2 AND p.is_final
+		ORDER BY a.depth DESC, p.environment IS NOT NULL, p.environment
+		LIMIT 1`
+
+	prop, err := scanCoreProperty(q.QueryRowContext(ctx, query, nodeID, key))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return prop, err
+}
+
+// CountDescendantDefinitions returns how many properties with key are defined
+// below nodeID, in any environment
+func (s *CoreRepository) CountDescendantDefinitions(ctx context.Context, nodeID int64, key string) (int, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	query := `
+		WITH RECURSIVE descendants AS (
+			SELECT id FROM config_nodes WHERE parent_id = $1
+			UNION ALL
+			SELECT n.id FROM config_nodes n JOIN descendants d ON n.parent_id = d.id
+		)
+		SELECT COUNT(*) FROM config_properties p JOIN descendants d ON p.node_id = d.id
+		WHERE p."key" = $2`
+
+	var count int
+	err := s.q.QueryRowContext(ctx, query, nodeID, key).Scan(&count)
+	return count, err
+}
+
+// CountInheritingNodes returns how many descendants of nodeID resolve key from
+// nodeID for environment, or for all environments when environment is nil,
+// because neither they nor a node between them define it
+func (s *CoreRepository) CountInheritingNodes(ctx context.Context, nodeID int64, key string, environment *string) (int, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	query := `
+		WITH RECURSIVE inheriting AS (
+			SELECT n.id FROM config_nodes n
+			WHERE n.parent_id = $1 AND NOT EXISTS (
+				SELECT 1 FROM config_properties p
+				WHERE p.node_id = n.id AND p."key" = $2 AND (p.environment IS NULL OR p.environment = $3))
+			UNION ALL
+			SELECT n.id FROM config_nodes n JOIN inheriting i ON n.parent_id = i.id
+			WHERE NOT EXISTS (
+				SELECT 1 FROM config_properties p
+				WHERE p.node_id = n.id AND p."key" = $2 AND (p.environment IS NULL OR p.environment = $3))
+		)
+		SELECT COUNT(*) FROM inheriting`
+
+	var count int
+	err := s.q.QueryRowContext(ctx, query, nodeID, key, environment).Scan(&count)
+	return count, err
+}
+
+// CheckEncryptionKey always fails, as properties cannot be encrypted
+func (s *CoreRepository) CheckEncryptionKey(ctx context.Context, nodeID int64) error {
+	return encryption.ErrNotConfigured
+}
+
+// Batches
+
+// ApplyBatch applies the operations of a batch in order in a single
+// transaction like Repository
+func (s *CoreRepository) ApplyBatch(ctx context.Context, ops []models.BatchOperation) ([]models.BatchResult, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	tx, q, err := s.begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	created := make([]int64, len(ops))
+	liveID := func(id *int64) *int64 {
+		if id == nil || *id >= 0 {
+			return id
+		}
+		mapped := int64(0)
+		if index := int(-*id) - 1; index < len(created) {
+			mapped = created[index]
+		}
+		return &mapped
+	}
+
+	results := make([]models.BatchResult, len(ops))
+	for i, op := range ops {
+		result := models.BatchResult{Op: op.Op, NodeID: liveID(op.NodeID), PropertyID: op.PropertyID}
+
+		switch op.Op {
+		case models.BatchOpCreateNode:
+			req := *op.CreateNode
+			req.ParentID = liveID(req.ParentID)
+			result.Node, err = s.createNode(ctx, q, req)
+			if err == nil {
+				created[i] = result.Node.ID
+				result.NodeID = &result.Node.ID
+			}
+
+		case models.BatchOpUpdateNode:
+			result.Node, err = s.updateNode(ctx, q, *result.NodeID, *op.UpdateNode)
+			if err == nil && result.Node == nil {
+				err = ErrNodeNotFound
+			}
+
+		case models.BatchOpDeleteNode:
+			err = deleteNode(ctx, q, *result.NodeID)
+
+		case models.BatchOpCreateProperty:
+			result.Property, err = s.batchCreateProperty(ctx, q, *result.NodeID, *op.CreateProperty)
+			if err == nil {
+				result.PropertyID = &result.Property.ID
+			}
+
+		case models.BatchOpUpdateProperty:
+			result.Property, err = s.updateProperty(ctx, q, *op.PropertyID, *op.UpdateProperty)
+			if err == nil && result.Property == nil {
+				err = ErrPropertyNotFound
+			}
+			if err == nil {
+				result.NodeID = &result.Property.NodeID
+			}
+
+		case models.BatchOpDeleteProperty:
+			err = deleteProperty(ctx, q, *op.PropertyID)
+
+		default:
+			err = fmt.Errorf("unknown op %q", op.Op)
+		}
+		if err != nil {
+			return nil, &BatchError{Index: i, Err: err}
+		}
+		results[i] = result
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// batchCreateProperty creates a property within a batch, after checking that
+// its node exists and that no ancestor locks its key
+func (s *CoreRepository) batchCreateProperty(ctx context.Context, tx querier, nodeID int64, req models.CreatePropertyRequest) (*models.ConfigProperty, error) {
+	var exists bool
+	if err := tx.QueryRowContext(ctx, `SELECT EXISTS(SELECT 1 FROM config_nodes WHERE id = $1)`, nodeID).Scan(&exists); err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, ErrNodeNotFound
+	}
+
+	locking, err := s.lockingProperty(ctx, tx, nodeID, req.Key)
+	if err != nil {
+		return nil, err
+	}
+	if locking != nil {
+		return nil, ErrKeyFinal
+	}
+
+	return s.createProperty(ctx, tx, nodeID, req)
+}
+
+// Delete guard
+
+// RecordNodeAccess notes that a consumer read the resolved configuration of a node
+func (s *CoreRepository) RecordNodeAccess(ctx context.Context, nodeID int64) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	now := time.Now()
+	_, err := s.q.ExecContext(ctx, `
+		UPDATE node_access SET last_accessed_at = $2
+		WHERE node_id = $1 AND `+s.dialect.sortableTime("last_accessed_at")+` < `+s.dialect.sortableTime("$3"),
+		nodeID, now, now.Add(-nodeAccessResolution))
+	if err != nil {
+		return err
+	}
+
+	_, err = s.q.ExecContext(ctx, s.dialect.insertIgnore+` INTO node_access (node_id, last_accessed_at) VALUES ($1, $2)`, nodeID, now)
+	return err
+}
+
+// LastSubtreeAccess returns the most recent time a consumer read the node or any
+// of its descendants, or nil if none of them was read
+func (s *CoreRepository) LastSubtreeAccess(ctx context.Context, nodeID int64) (*time.Time, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	query := `
+		WITH RECURSIVE subtree AS (
+			SELECT id FROM config_nodes WHERE id = $1
+			UNION ALL
+			SELECT n.id FROM config_nodes n JOIN subtree s ON n.parent_id = s.id
+		)
+		SELECT a.last_accessed_at FROM node_access a JOIN subtree s ON a.node_id = s.id
+		ORDER BY ` + s.dialect.sortableTime("a.last_accessed_at") + ` DESC
+		LIMIT 1`
+
+	var last time.Time
+	err := s.q.QueryRowContext(ctx, query, nodeID).Scan(&last)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &last, nil
+}
+
+// Idempotency keys
+
+// ReserveIdempotencyKey claims an idempotency key of a principal for a request
+// like Repository. It returns nil once the key is claimed, or the record of the
+// request that claimed it before.
+func (s *CoreRepository) ReserveIdempotencyKey(ctx context.Context, principal, key, requestHash string, expiredBefore, abandonedBefore time.Time) (*IdempotencyRecord, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	createdAt := s.dialect.sortableTime("created_at")
+	_, err := s.q.ExecContext(ctx, `
+		DELETE FROM config_idempotency_keys
+		WHERE `+createdAt+` < `+s.dialect.sortableTime("$1")+` OR (status_code IS NULL AND `+createdAt+` < `+s.dialect.sortableTime("$2")+`)`,
+		expiredBefore, abandonedBefore)
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		result, err := s.q.ExecContext(ctx, s.dialect.insertIgnore+` INTO config_idempotency_keys (principal, idempotency_key, request_hash, created_at)
+			VALUES ($1, $2, $3, $4)`,
+			principal, key, requestHash, time.Now())
+		if err != nil {
+			return nil, err
+		}
+		if claimed, err := result.RowsAffected(); err != nil || claimed == 1 {
+			return nil, err
+		}
+
+		var record IdempotencyRecord
+		var status sql.NullInt64
+		var contentType sql.NullString
+		err = s.q.QueryRowContext(ctx, `
+			SELECT request_hash, status_code, content_type, response_body
+			FROM config_idempotency_keys
+			WHERE principal = $1 AND idempotency_key = $2`, principal, key).
+			Scan(&record.RequestHash, &status, &contentType, &record.Body)
+		if err == sql.ErrNoRows {
+			// Released by the request that held it, claim it again
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		record.StatusCode = int(status.Int64)
+		record.ContentType = contentType.String
+		return &record, nil
+	}
+}
+
+// CompleteIdempotencyKey records the response to the request that claimed an
+// idempotency key, to be replayed on retries
+func (s *CoreRepository) CompleteIdempotencyKey(ctx context.Context, principal, key string, status int, contentType string, body []byte) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	_, err := s.q.ExecContext(ctx, `
+		UPDATE config_idempotency_keys
+		SET status_code = $3, content_type = $4, response_body = $5
+		WHERE principal = $1 AND idempotency_key = $2`,
+		principal, key, status, contentType, body)
+	return err
+}
+
+// ReleaseIdempotencyKey drops an idempotency key whose request failed, so that
+// a retry runs the request again
+func (s *CoreRepository) ReleaseIdempotencyKey(ctx context.Context, principal, key string) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	_, err := s.q.ExecContext(ctx, `
+		DELETE FROM config_idempotency_keys
+		WHERE principal = $1 AND idempotency_key = $2 AND status_code IS NULL`, principal, key)
+	return err
+}
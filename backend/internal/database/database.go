@@ -1,16 +1,48 @@
 package database
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"log"
 	"os"
+	"strings"
+	"sync/atomic"
+	"time"
 
-	_ "github.com/lib/pq"
+	"github.com/go-sql-driver/mysql"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/jackc/pgx/v5/stdlib"
+	_ "modernc.org/sqlite"
+)
+
+// Storage drivers, selected with DATABASE_DRIVER
+const (
+	DriverPostgres = "postgres"
+	DriverSQLite   = "sqlite"
+	DriverMySQL    = "mysql"
 )
 
 type DB struct {
 	*sql.DB
+
+	driver       string
+	pool         *pgxpool.Pool
+	queryTimeout time.Duration
+	listening    atomic.Bool // See CheckInvalidations
+}
+
+// PoolConfig sizes the connection pool. Zero values keep the defaults of the
+// driver.
+type PoolConfig struct {
+	// MaxConns caps the open connections
+	MaxConns int
+	// MaxIdleConns caps the idle connections kept open by MySQL. PostgreSQL
+	// closes idle connections after MaxConnIdleTime instead.
+	MaxIdleConns    int
+	MaxConnIdleTime time.Duration
+	MaxConnLifetime time.Duration
 }
 
 // NewConnection creates a new database connection
@@ -20,60 +52,193 @@ func NewConnection() (*DB, error) {
 		return nil, fmt.Errorf("DATABASE_URL environment variable is required")
 	}
 
-	db, err := sql.Open("postgres", dbURL)
+	driver := os.Getenv("DATABASE_DRIVER")
+	if driver == "" {
+		driver = DriverPostgres
+	}
+	return OpenDriver(driver, dbURL, PoolConfig{})
+}
+
+// Open creates a new database connection to the given PostgreSQL URL
+func Open(dbURL string, pool PoolConfig) (*DB, error) {
+	return OpenDriver(DriverPostgres, dbURL, pool)
+}
+
+// OpenDriver creates a new database connection with a storage driver. SQLite
+// takes the path of the database file, or :memory: for a database that lives
+// as long as the process. MySQL and MariaDB take a DSN such as
+// user:password@tcp(host:3306)/dbname. SQLite ignores the pool configuration
+// and keeps a single connection.
+func OpenDriver(driver, dbURL string, pool PoolConfig) (*DB, error) {
+	if driver == DriverPostgres {
+		return openPostgres(dbURL, pool)
+	}
+
+	var db *sql.DB
+	var err error
+	switch driver {
+	case DriverSQLite:
+		db, err = sql.Open("sqlite", sqliteDSN(dbURL))
+	case DriverMySQL:
+		var dsn string
+		if dsn, err = mysqlDSN(dbURL); err == nil {
+			db, err = sql.Open("mysql", dsn)
+		}
+	default:
+		return nil, fmt.Errorf("unknown database driver %q", driver)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
+	if driver == DriverSQLite {
+		// SQLite has a single writer, and every connection to :memory: would
+		// open a database of its own
+		db.SetMaxOpenConns(1)
+		db.SetConnMaxLifetime(0)
+	} else {
+		if pool.MaxConns > 0 {
+			db.SetMaxOpenConns(pool.MaxConns)
+		}
+		if pool.MaxIdleConns > 0 {
+			db.SetMaxIdleConns(pool.MaxIdleConns)
+		}
+		db.SetConnMaxIdleTime(pool.MaxConnIdleTime)
+		db.SetConnMaxLifetime(pool.MaxConnLifetime)
+	}
 
 	if err := db.Ping(); err != nil {
+		db.Close()
 		return nil, fmt.Errorf("failed to ping database: %w", err)
 	}
 
 	log.Println("Database connection established")
-	return &DB{db}, nil
+	return &DB{DB: db, driver: driver}, nil
+}
+
+// openPostgres connects to PostgreSQL through a pgx pool. Repositories query it
+// through database/sql, which hands every connection back to the pool as soon
+// as it is released.
+func openPostgres(dbURL string, pool PoolConfig) (*DB, error) {
+	cfg, err := pgxpool.ParseConfig(dbURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse database URL: %w", err)
+	}
+	if pool.MaxConns > 0 {
+		cfg.MaxConns = int32(pool.MaxConns)
+	}
+	if pool.MaxConnIdleTime > 0 {
+		cfg.MaxConnIdleTime = pool.MaxConnIdleTime
+	}
+	if pool.MaxConnLifetime > 0 {
+		cfg.MaxConnLifetime = pool.MaxConnLifetime
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	p, err := pgxpool.NewWithConfig(ctx, cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	if err := p.Ping(ctx); err != nil {
+		p.Close()
+		return nil, fmt.Errorf("failed to ping database: %w", err)
+	}
+
+	log.Println("Database connection established")
+	return &DB{DB: stdlib.OpenDBFromPool(p), driver: DriverPostgres, pool: p}, nil
+}
+
+// sqliteDSN turns a database path into a DSN with foreign keys enforced, which
+// SQLite leaves off by default, and times stored in a format its date
+// functions can compare
+func sqliteDSN(path string) string {
+	path = strings.TrimPrefix(path, "sqlite://")
+	if path == ":memory:" {
+		path = "file::memory:"
+	}
+	separator := "?"
+	if strings.Contains(path, "?") {
+		separator = "&"
+	}
+	return path + separator + "_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)&_time_format=sqlite"
+}
+
+// mysqlDSN completes a MySQL DSN with the settings the repository relies on:
+// times scanned as time.Time in UTC, migrations run as one multi-statement
+// script, and identifiers quoted with double quotes as in the other dialects
+func mysqlDSN(dsn string) (string, error) {
+	cfg, err := mysql.ParseDSN(strings.TrimPrefix(dsn, "mysql://"))
+	if err != nil {
+		return "", err
+	}
+	cfg.ParseTime = true
+	cfg.Loc = time.UTC
+	cfg.MultiStatements = true
+	if cfg.Params == nil {
+		cfg.Params = map[string]string{}
+	}
+	cfg.Params["sql_mode"] = "CONCAT(@@sql_mode, ',ANSI_QUOTES')"
+	return cfg.FormatDSN(), nil
+}
+
+// Driver returns the storage driver of the connection
+func (db *DB) Driver() string {
+	return db.driver
 }
 
 // Close closes the database connection
 func (db *DB) Close() error {
-	return db.DB.Close()
+	err := db.DB.Close()
+	if db.pool != nil {
+		db.pool.Close()
+	}
+	return err
 }
 
-// RunMigrations executes database migrations
-func (db *DB) RunMigrations() error {
-	migrations := []string{
-		`CREATE TABLE IF NOT EXISTS config_nodes (
-			id BIGSERIAL PRIMARY KEY,
-			name VARCHAR(255) NOT NULL,
-			node_type VARCHAR(50) NOT NULL CHECK (node_type IN ('territory', 'center')),
-			parent_id BIGINT REFERENCES config_nodes(id) ON DELETE CASCADE,
-			description TEXT DEFAULT '',
-			created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
-			updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
-		)`,
-		`CREATE TABLE IF NOT EXISTS config_properties (
-			id BIGSERIAL PRIMARY KEY,
-			node_id BIGINT NOT NULL REFERENCES config_nodes(id) ON DELETE CASCADE,
-			key VARCHAR(255) NOT NULL,
-			value TEXT NOT NULL,
-			data_type VARCHAR(50) NOT NULL CHECK (data_type IN ('string', 'number', 'boolean', 'object', 'array', 'null')),
-			default_value TEXT,
-			description TEXT DEFAULT '',
-			created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
-			updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
-			UNIQUE(node_id, key)
-		)`,
-		`CREATE INDEX IF NOT EXISTS idx_config_nodes_parent_id ON config_nodes(parent_id)`,
-		`CREATE INDEX IF NOT EXISTS idx_config_nodes_node_type ON config_nodes(node_type)`,
-		`CREATE INDEX IF NOT EXISTS idx_config_properties_node_id ON config_properties(node_id)`,
-		`CREATE INDEX IF NOT EXISTS idx_config_properties_key ON config_properties(key)`,
-	}
-
-	for _, migration := range migrations {
-		if _, err := db.Exec(migration); err != nil {
-			return fmt.Errorf("failed to execute migration: %w", err)
+// Ping verifies the database connection is alive
+func (db *DB) Ping(ctx context.Context) error {
+	return db.DB.PingContext(ctx)
+}
+
+// PoolStats counts the connections of the pool
+type PoolStats struct {
+	// Max is the most connections the pool opens
+	Max int
+	// Open connections are either in use or idle
+	Open  int
+	InUse int
+	Idle  int
+}
+
+// PoolStats returns the current state of the connection pool
+func (db *DB) PoolStats() PoolStats {
+	if db.pool != nil {
+		stat := db.pool.Stat()
+		return PoolStats{
+			Max:   int(stat.MaxConns()),
+			Open:  int(stat.TotalConns()),
+			InUse: int(stat.AcquiredConns()),
+			Idle:  int(stat.IdleConns()),
 		}
 	}
+	stats := db.Stats()
+	return PoolStats{
+		Max:   stats.MaxOpenConnections,
+		Open:  stats.OpenConnections,
+		InUse: stats.InUse,
+		Idle:  stats.Idle,
+	}
+}
 
-	log.Println("Database migrations completed successfully")
-	return nil
-}
\ No newline at end of file
+// SetQueryTimeout bounds every repository call, on top of the deadline of the
+// context it is given. Zero leaves calls bounded by their context only.
+func (db *DB) SetQueryTimeout(timeout time.Duration) {
+	db.queryTimeout = timeout
+}
+
+// textArray scans a PostgreSQL text[] into dest. database/sql leaves arrays to
+// the driver, and the type map pgx scans them with is not safe for concurrent
+// use, so every scan gets its own.
+func textArray(dest *[]string) sql.Scanner {
+	return pgtype.NewMap().SQLScanner(dest)
+}
@@ -0,0 +1,88 @@
+package database
+
+import (
+	"config-manager/internal/models"
+	"context"
+	"time"
+)
+
+const deprecationColumns = `key, replacement_key, sunset_at, reason, created_by, created_at`
+
+func scanDeprecation(row rowScanner) (*models.KeyDeprecation, error) {
+	var deprecation models.KeyDeprecation
+	err := row.Scan(&deprecation.Key, &deprecation.ReplacementKey, &deprecation.SunsetAt, &deprecation.Reason, &deprecation.CreatedBy, &deprecation.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &deprecation, nil
+}
+
+// DeprecateKey marks a key as deprecated. Deprecating a key again updates its
+// replacement, sunset and reason.
+func (r *Repository) DeprecateKey(ctx context.Context, req models.DeprecateKeyRequest, createdBy string) (*models.KeyDeprecation, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	query := `
+		INSERT INTO config_key_deprecations (key, replacement_key, sunset_at, reason, created_by, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (key) DO UPDATE SET
+			replacement_key = EXCLUDED.replacement_key,
+			sunset_at = EXCLUDED.sunset_at,
+			reason = EXCLUDED.reason
+		RETURNING ` + deprecationColumns
+
+	return scanDeprecation(r.db.QueryRowContext(ctx, query, req.Key, req.ReplacementKey, req.SunsetAt, req.Reason, createdBy, time.Now()))
+}
+
+// GetKeyDeprecations lists the deprecated keys, or only those among keys when
+// keys is not nil
+func (r *Repository) GetKeyDeprecations(ctx context.Context, keys []string) ([]models.KeyDeprecation, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	query := `SELECT ` + deprecationColumns + ` FROM config_key_deprecations ORDER BY key`
+	var args []interface{}
+	if keys != nil {
+		query = `SELECT ` + deprecationColumns + ` FROM config_key_deprecations WHERE key = ANY($1) ORDER BY key`
+		args = append(args, keys)
+	}
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	deprecations := []models.KeyDeprecation{}
+	for rows.Next() {
+		deprecation, err := scanDeprecation(rows)
+		if err != nil {
+			return nil, err
+		}
+		deprecations = append(deprecations, *deprecation)
+	}
+	return deprecations, rows.Err()
+}
+
+// UndeprecateKey removes the deprecation of a key, reporting whether it existed
+func (r *Repository) UndeprecateKey(ctx context.Context, key string) (bool, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	result, err := r.db.ExecContext(ctx, `DELETE FROM config_key_deprecations WHERE key = $1`, key)
+	if err != nil {
+		return false, err
+	}
+	rowsAffected, err := result.RowsAffected()
+	return rowsAffected > 0, err
+}
+
+// GetDeprecatedDefinitions returns the properties defining a deprecated key,
+// within the subtree of rootID or the whole tree when rootID is nil
+func (r *Repository) GetDeprecatedDefinitions(ctx context.Context, rootID *int64) ([]models.PropertySearchResult, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	return r.searchProperties(ctx, rootID, `c.key IN (SELECT key FROM config_key_deprecations)`)
+}
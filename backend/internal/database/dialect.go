@@ -0,0 +1,146 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/go-sql-driver/mysql"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// dialect holds the SQL that differs between the drivers of CoreRepository.
+// Queries are written with $n placeholders and in the SQL both drivers share;
+// the dialect fills in the rest.
+type dialect struct {
+	// nullSafeEqual compares two values, NULL being equal to NULL
+	nullSafeEqual string
+	// insertIgnore starts an insert that skips rows violating a unique index
+	insertIgnore string
+	// upsert returns the clause of an insert that updates columns of the row
+	// conflicting with the unique index on conflict instead
+	upsert func(conflict string, columns []string) string
+	// sortableTime returns an expression on a stored time that compares and
+	// sorts chronologically
+	sortableTime func(expr string) string
+	// hasLabel and labelEquals return conditions on the labels of a node
+	// given the parameters of a label key and value
+	hasLabel    func(key string) string
+	labelEquals func(key, value string) string
+	// isUniqueViolation reports whether err violates the unique index named index
+	isUniqueViolation func(err error, index string) bool
+	// rebind rewrites the $n placeholders of a query for drivers that only
+	// take ?, ordering the arguments to match
+	rebind bool
+}
+
+var sqliteDialect = dialect{
+	nullSafeEqual: "IS",
+	insertIgnore:  "INSERT OR IGNORE",
+	upsert: func(conflict string, columns []string) string {
+		set := make([]string, len(columns))
+		for i, column := range columns {
+			set[i] = column + " = excluded." + column
+		}
+		return "ON CONFLICT (" + conflict + ") DO UPDATE SET " + strings.Join(set, ", ")
+	},
+	sortableTime: func(expr string) string {
+		// Times are stored as text, which only sorts chronologically within
+		// one time zone
+		return "julianday(" + expr + ")"
+	},
+	hasLabel: func(key string) string {
+		return "EXISTS (SELECT 1 FROM json_each(labels) l WHERE l.key = " + key + ")"
+	},
+	labelEquals: func(key, value string) string {
+		return "EXISTS (SELECT 1 FROM json_each(labels) l WHERE l.key = " + key + " AND l.value = " + value + ")"
+	},
+	isUniqueViolation: func(err error, index string) bool {
+		var sqliteErr *sqlite.Error
+		return errors.As(err, &sqliteErr) && sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_UNIQUE && strings.Contains(sqliteErr.Error(), index)
+	},
+}
+
+var mysqlDialect = dialect{
+	nullSafeEqual: "<=>",
+	insertIgnore:  "INSERT IGNORE",
+	upsert: func(conflict string, columns []string) string {
+		set := make([]string, len(columns))
+		for i, column := range columns {
+			set[i] = column + " = VALUES(" + column + ")"
+		}
+		return "ON DUPLICATE KEY UPDATE " + strings.Join(set, ", ")
+	},
+	sortableTime: func(expr string) string {
+		return expr
+	},
+	hasLabel: func(key string) string {
+		return "JSON_CONTAINS_PATH(labels, 'one', CONCAT('$.\"', " + key + ", '\"'))"
+	},
+	labelEquals: func(key, value string) string {
+		return "JSON_UNQUOTE(JSON_EXTRACT(labels, CONCAT('$.\"', " + key + ", '\"'))) = " + value
+	},
+	isUniqueViolation: func(err error, index string) bool {
+		var mysqlErr *mysql.MySQLError
+		return errors.As(err, &mysqlErr) && mysqlErr.Number == 1062 && strings.Contains(mysqlErr.Message, index)
+	},
+	rebind: true,
+}
+
+// rebindPlaceholders rewrites the $n placeholders of query to ?, repeating and
+// ordering args to match. A $ without a parameter number, as in a JSON path, is
+// kept.
+func rebindPlaceholders(query string, args []interface{}) (string, []interface{}) {
+	var rebound strings.Builder
+	var ordered []interface{}
+	for i := 0; i < len(query); i++ {
+		if query[i] != '$' {
+			rebound.WriteByte(query[i])
+			continue
+		}
+		end := i + 1
+		for end < len(query) && query[end] >= '0' && query[end] <= '9' {
+			end++
+		}
+		n, err := strconv.Atoi(query[i+1 : end])
+		if err != nil || n < 1 || n > len(args) {
+			rebound.WriteByte(query[i])
+			continue
+		}
+		rebound.WriteByte('?')
+		ordered = append(ordered, args[n-1])
+		i = end - 1
+	}
+	return rebound.String(), ordered
+}
+
+// rebindQuerier runs the queries of a querier with their placeholders rebound
+type rebindQuerier struct {
+	q querier
+}
+
+func (r rebindQuerier) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	query, args = rebindPlaceholders(query, args)
+	return r.q.QueryContext(ctx, query, args...)
+}
+
+func (r rebindQuerier) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	query, args = rebindPlaceholders(query, args)
+	return r.q.QueryRowContext(ctx, query, args...)
+}
+
+func (r rebindQuerier) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	query, args = rebindPlaceholders(query, args)
+	return r.q.ExecContext(ctx, query, args...)
+}
+
+// querier returns q, rebinding its placeholders if the dialect needs it
+func (d *dialect) querier(q querier) querier {
+	if d.rebind {
+		return rebindQuerier{q}
+	}
+	return q
+}
@@ -0,0 +1,82 @@
+package database
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRebindPlaceholders(t *testing.T) {
+	tests := []struct {
+		name      string
+		query     string
+		args      []interface{}
+		wantQuery string
+		wantArgs  []interface{}
+	}{
+		{
+			name:      "no placeholders",
+			query:     "SELECT 1",
+			wantQuery: "SELECT 1",
+		},
+		{
+			name:      "in order",
+			query:     "SELECT * FROM t WHERE a = $1 AND b = $2",
+			args:      []interface{}{1, "x"},
+			wantQuery: "SELECT * FROM t WHERE a = ? AND b = ?",
+			wantArgs:  []interface{}{1, "x"},
+		},
+		{
+			name:      "out of order",
+			query:     "UPDATE t SET a = $2 WHERE id = $1",
+			args:      []interface{}{7, "x"},
+			wantQuery: "UPDATE t SET a = ? WHERE id = ?",
+			wantArgs:  []interface{}{"x", 7},
+		},
+		{
+			name:      "repeated",
+			query:     "SELECT * FROM t WHERE a = $1 OR b = $1",
+			args:      []interface{}{3},
+			wantQuery: "SELECT * FROM t WHERE a = ? OR b = ?",
+			wantArgs:  []interface{}{3, 3},
+		},
+		{
+			name:      "two digits",
+			query:     "VALUES ($1, $10)",
+			args:      []interface{}{1, 2, 3, 4, 5, 6, 7, 8, 9, 10},
+			wantQuery: "VALUES (?, ?)",
+			wantArgs:  []interface{}{1, 10},
+		},
+		{
+			name:      "JSON path",
+			query:     "SELECT JSON_EXTRACT(labels, '$.team') FROM t WHERE id = $1",
+			args:      []interface{}{5},
+			wantQuery: "SELECT JSON_EXTRACT(labels, '$.team') FROM t WHERE id = ?",
+			wantArgs:  []interface{}{5},
+		},
+		{
+			name:      "number beyond the arguments",
+			query:     "SELECT $1, $3",
+			args:      []interface{}{1},
+			wantQuery: "SELECT ?, $3",
+			wantArgs:  []interface{}{1},
+		},
+		{
+			name:      "zero and trailing dollar",
+			query:     "SELECT $0, $",
+			args:      []interface{}{1},
+			wantQuery: "SELECT $0, $",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, args := rebindPlaceholders(tt.query, tt.args)
+			if query != tt.wantQuery {
+				t.Errorf("query: got %q, want %q", query, tt.wantQuery)
+			}
+			if !reflect.DeepEqual(args, tt.wantArgs) {
+				t.Errorf("args: got %v, want %v", args, tt.wantArgs)
+			}
+		})
+	}
+}
@@ -0,0 +1,225 @@
+package database
+
+import (
+	"config-manager/internal/models"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	ErrNoDrafts       = errors.New("node has no drafts")
+	ErrDraftEncrypted = errors.New("encrypted properties cannot be drafted")
+)
+
+const draftColumns = `id, node_id, key, environment, value, data_type, default_value, description, delete, created_at, updated_at`
+
+func scanDraft(row rowScanner) (*models.PropertyDraft, error) {
+	var draft models.PropertyDraft
+	err := row.Scan(
+		&draft.ID, &draft.NodeID, &draft.Key, &draft.Environment, &draft.Value, &draft.DataType, &draft.DefaultValue,
+		&draft.Description, &draft.Delete, &draft.CreatedAt, &draft.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &draft, nil
+}
+
+// SavePropertyDraft stages an edit of a property, replacing any draft for the
+// same key and environment. Drafts are stored in plain text, so encrypted
+// properties are rejected.
+func (r *Repository) SavePropertyDraft(ctx context.Context, nodeID int64, req models.SavePropertyDraftRequest) (*models.PropertyDraft, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	var encrypted bool
+	err := r.db.QueryRowContext(ctx, `
+		SELECT encrypted FROM config_properties
+		WHERE node_id = $1 AND key = $2 AND COALESCE(environment, '') = COALESCE($3, '')`,
+		nodeID, req.Key, req.Environment).Scan(&encrypted)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+	if encrypted {
+		return nil, ErrDraftEncrypted
+	}
+
+	query := `
+		INSERT INTO property_drafts (node_id, key, environment, value, data_type, default_value, description, delete, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $9)
+		ON CONFLICT (node_id, key, (COALESCE(environment, '')))
+		DO UPDATE SET
+			value = EXCLUDED.value,
+			data_type = EXCLUDED.data_type,
+			default_value = EXCLUDED.default_value,
+			description = EXCLUDED.description,
+			delete = EXCLUDED.delete,
+			updated_at = EXCLUDED.updated_at
+		RETURNING ` + draftColumns
+
+	return scanDraft(r.db.QueryRowContext(ctx, query,
+		nodeID, req.Key, req.Environment, req.Value, req.DataType, req.DefaultValue, req.Description, req.Delete, time.Now()))
+}
+
+func (r *Repository) GetPropertyDrafts(ctx context.Context, nodeID int64) ([]models.PropertyDraft, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	return r.getPropertyDrafts(ctx, r.db, nodeID, false)
+}
+
+func (r *Repository) getPropertyDrafts(ctx context.Context, q querier, nodeID int64, forUpdate bool) ([]models.PropertyDraft, error) {
+	query := `SELECT ` + draftColumns + ` FROM property_drafts WHERE node_id = $1 ORDER BY key, environment NULLS FIRST`
+	if forUpdate {
+		query += ` FOR UPDATE`
+	}
+
+	rows, err := q.QueryContext(ctx, query, nodeID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	drafts := []models.PropertyDraft{}
+	for rows.Next() {
+		draft, err := scanDraft(rows)
+		if err != nil {
+			return nil, err
+		}
+		drafts = append(drafts, *draft)
+	}
+
+	return drafts, rows.Err()
+}
+
+// DiscardPropertyDrafts drops the drafts of a node, or only the draft of key
+// (in every environment) when key is not empty. It returns how many were dropped.
+func (r *Repository) DiscardPropertyDrafts(ctx context.Context, nodeID int64, key string) (int64, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	result, err := r.db.ExecContext(ctx, `DELETE FROM property_drafts WHERE node_id = $1 AND ($2 = '' OR key = $2)`, nodeID, key)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// PublishPropertyDrafts applies all drafts of a node to its live properties and
+// removes them, in a single transaction
+func (r *Repository) PublishPropertyDrafts(ctx context.Context, nodeID int64) (*models.PublishDraftsResult, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	drafts, err := r.getPropertyDrafts(ctx, tx, nodeID, true)
+	if err != nil {
+		return nil, err
+	}
+	if len(drafts) == 0 {
+		return nil, ErrNoDrafts
+	}
+
+	result := &models.PublishDraftsResult{
+		NodeID:    nodeID,
+		Published: []models.ConfigProperty{},
+		Deleted:   []models.PropertyDraft{},
+	}
+	now := time.Now()
+	for _, draft := range drafts {
+		if draft.Delete {
+			_, err := tx.ExecContext(ctx, `
+				DELETE FROM config_properties
+				WHERE node_id = $1 AND key = $2 AND COALESCE(environment, '') = COALESCE($3, '')`,
+				nodeID, draft.Key, draft.Environment)
+			if err != nil {
+				return nil, err
+			}
+			result.Deleted = append(result.Deleted, draft)
+			continue
+		}
+
+		// A property encrypted after it was drafted fails the whole publish
+		prop, err := r.scanProperty(ctx, tx.QueryRowContext(ctx, `
+			INSERT INTO config_properties (node_id, key, environment, value, data_type, default_value, description, created_at, updated_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $8)
+			ON CONFLICT (node_id, key, (COALESCE(environment, '')))
+			DO UPDATE SET
+				value = EXCLUDED.value,
+				data_type = EXCLUDED.data_type,
+				default_value = EXCLUDED.default_value,
+				description = EXCLUDED.description,
+				updated_at = EXCLUDED.updated_at
+			WHERE NOT config_properties.encrypted
+			RETURNING `+propertyColumns,
+			nodeID, draft.Key, draft.Environment, draft.Value, draft.DataType, draft.DefaultValue, draft.Description, now))
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("%w: %s", ErrDraftEncrypted, draft.Key)
+		}
+		if err != nil {
+			return nil, err
+		}
+		result.Published = append(result.Published, *prop)
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM property_drafts WHERE node_id = $1`, nodeID); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// overlayDrafts returns the properties of a node as they would be after
+// publishing its drafts
+func overlayDrafts(properties []models.ConfigProperty, drafts []models.PropertyDraft) []models.ConfigProperty {
+	if len(drafts) == 0 {
+		return properties
+	}
+
+	slot := func(key string, environment *string) string {
+		if environment == nil {
+			return key
+		}
+		return key + "\x00" + *environment
+	}
+
+	byDraft := make(map[string]models.PropertyDraft, len(drafts))
+	for _, draft := range drafts {
+		byDraft[slot(draft.Key, draft.Environment)] = draft
+	}
+
+	live := make(map[string]models.ConfigProperty, len(properties))
+	overlaid := make([]models.ConfigProperty, 0, len(properties)+len(drafts))
+	for _, prop := range properties {
+		if _, drafted := byDraft[slot(prop.Key, prop.Environment)]; drafted {
+			live[slot(prop.Key, prop.Environment)] = prop
+			continue
+		}
+		overlaid = append(overlaid, prop)
+	}
+
+	for _, draft := range drafts {
+		if draft.Delete || draft.Value == nil || draft.DataType == nil {
+			continue
+		}
+		// Publishing keeps the settings drafts do not carry, such as rollouts
+		prop := live[slot(draft.Key, draft.Environment)]
+		prop.NodeID, prop.Key, prop.Environment = draft.NodeID, draft.Key, draft.Environment
+		prop.Value, prop.DataType = *draft.Value, *draft.DataType
+		prop.DefaultValue, prop.Description = draft.DefaultValue, draft.Description
+		prop.UpdatedAt = draft.UpdatedAt
+		overlaid = append(overlaid, prop)
+	}
+	return overlaid
+}
@@ -0,0 +1,47 @@
+package database
+
+import (
+	"config-manager/internal/models"
+	"context"
+)
+
+// driftStatus describes the apply action that would remove a difference
+var driftStatus = map[string]string{
+	"create": "missing",
+	"update": "changed",
+	"delete": "unexpected",
+}
+
+// DetectDrift lists every difference between a declarative document and the
+// live subtree it declares, from a dry run of ApplyDocument
+func (r *Repository) DetectDrift(ctx context.Context, parentID *int64, doc models.DeclaredNode) (*models.DriftReport, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	plan, err := r.ApplyDocument(ctx, parentID, doc, true)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &models.DriftReport{
+		RootNodeID: plan.RootNodeID,
+		InSync:     len(plan.Changes) == 0,
+		Drift:      make([]models.Drift, 0, len(plan.Changes)),
+		Unchanged:  plan.Unchanged,
+		Warnings:   plan.Warnings,
+	}
+	for _, change := range plan.Changes {
+		report.Drift = append(report.Drift, models.Drift{
+			Status:      driftStatus[change.Action],
+			Kind:        change.Kind,
+			Path:        change.Path,
+			NodeID:      change.NodeID,
+			Key:         change.Key,
+			Environment: change.Environment,
+			Fields:      change.Fields,
+			Declared:    change.New,
+			Live:        change.Old,
+		})
+	}
+	return report, nil
+}
@@ -0,0 +1,66 @@
+package database
+
+import (
+	"config-manager/internal/models"
+	"context"
+	"database/sql"
+)
+
+// GetProperty returns a property, or nil if it does not exist
+func (r *Repository) GetProperty(ctx context.Context, id int64) (*models.ConfigProperty, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	prop, err := r.scanProperty(ctx, r.db.QueryRowContext(ctx, `SELECT `+propertyColumns+` FROM config_properties WHERE id = $1`, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return prop, err
+}
+
+// CountInheritingNodes returns how many descendants of nodeID resolve key from
+// nodeID for environment, or for all environments when environment is nil,
+// because neither they nor a node between them define it
+func (r *Repository) CountInheritingNodes(ctx context.Context, nodeID int64, key string, environment *string) (int, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	query := `
+		WITH RECURSIVE inheriting AS (
+			SELECT n.id FROM config_nodes n
+			WHERE n.parent_id = $1 AND NOT EXISTS (
+				SELECT 1 FROM config_properties p
+				WHERE p.node_id = n.id AND p.key = $2 AND (p.environment IS NULL OR p.environment = $3))
+			UNION ALL
+			SELECT n.id FROM config_nodes n JOIN inheriting i ON n.parent_id = i.id
+			WHERE NOT EXISTS (
+				SELECT 1 FROM config_properties p
+				WHERE p.node_id = n.id AND p.key = $2 AND (p.environment IS NULL OR p.environment = $3))
+		)
+		SELECT COUNT(*) FROM inheriting`
+
+	var count int
+	err := r.db.QueryRowContext(ctx, query, nodeID, key, environment).Scan(&count)
+	return count, err
+}
+
+// CheckEncryptionKey returns the error encrypting a property of nodeID would
+// fail with, if encryption is not set up for its subtree
+func (r *Repository) CheckEncryptionKey(ctx context.Context, nodeID int64) error {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	_, _, err := r.subtreeKey(ctx, r.db, nodeID)
+	return err
+}
+
+// draftsOf returns the drafts of one node
+func draftsOf(drafts []models.PropertyDraft, nodeID int64) []models.PropertyDraft {
+	var own []models.PropertyDraft
+	for _, draft := range drafts {
+		if draft.NodeID == nodeID {
+			own = append(own, draft)
+		}
+	}
+	return own
+}
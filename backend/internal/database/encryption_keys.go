@@ -0,0 +1,189 @@
+package database
+
+import (
+	"config-manager/internal/encryption"
+	"config-manager/internal/models"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+var (
+	ErrNoEncryptionKey     = errors.New("no encryption key is assigned to this subtree")
+	ErrEncryptionKeyExists = errors.New("node already has an encryption key")
+)
+
+// CreateEncryptionKey generates a new data key and assigns it to the subtree rooted at nodeID
+func (r *Repository) CreateEncryptionKey(ctx context.Context, nodeID int64) (*models.EncryptionKey, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	dataKey, wrapped, err := r.keyring.GenerateDataKey(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+		INSERT INTO encryption_keys (node_id, wrapped_key)
+		VALUES ($1, $2)
+		RETURNING id, node_id, created_at`
+
+	var key models.EncryptionKey
+	err = r.db.QueryRowContext(ctx, query, nodeID, wrapped).Scan(&key.ID, &key.NodeID, &key.CreatedAt)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			return nil, ErrEncryptionKeyExists
+		}
+		return nil, err
+	}
+
+	r.dataKeysMu.Lock()
+	r.dataKeys[key.ID] = dataKey
+	r.dataKeysMu.Unlock()
+
+	return &key, nil
+}
+
+func (r *Repository) GetEncryptionKeys(ctx context.Context) ([]models.EncryptionKey, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	query := `SELECT id, node_id, created_at, retired_at FROM encryption_keys ORDER BY node_id, id`
+
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := []models.EncryptionKey{}
+	for rows.Next() {
+		var key models.EncryptionKey
+		if err := rows.Scan(&key.ID, &key.NodeID, &key.CreatedAt, &key.RetiredAt); err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+
+	return keys, nil
+}
+
+// subtreeKey returns the active key assigned to the nearest ancestor of nodeID (including itself)
+func (r *Repository) subtreeKey(ctx context.Context, q querier, nodeID int64) (int64, []byte, error) {
+	if r.keyring == nil {
+		return 0, nil, encryption.ErrNotConfigured
+	}
+
+	query := `
+		WITH RECURSIVE ancestors AS (
+			SELECT id, parent_id, 0 AS depth FROM config_nodes WHERE id = $1
+			UNION ALL
+			SELECT n.id, n.parent_id, a.depth + 1
+			FROM config_nodes n JOIN ancestors a ON n.id = a.parent_id
+		)
+		SELECT k.id FROM encryption_keys k
+		JOIN ancestors a ON k.node_id = a.id
+		WHERE k.retired_at IS NULL
+		ORDER BY a.depth
+		LIMIT 1`
+
+	var keyID int64
+	err := q.QueryRowContext(ctx, query, nodeID).Scan(&keyID)
+	if err == sql.ErrNoRows {
+		return 0, nil, ErrNoEncryptionKey
+	}
+	if err != nil {
+		return 0, nil, err
+	}
+
+	dataKey, err := r.dataKey(ctx, keyID)
+	if err != nil {
+		return 0, nil, err
+	}
+	return keyID, dataKey, nil
+}
+
+// dataKey returns the unwrapped data key for an encryption key ID
+func (r *Repository) dataKey(ctx context.Context, keyID int64) ([]byte, error) {
+	r.dataKeysMu.Lock()
+	dataKey, ok := r.dataKeys[keyID]
+	r.dataKeysMu.Unlock()
+	if ok {
+		return dataKey, nil
+	}
+
+	var wrapped []byte
+	err := r.db.QueryRowContext(ctx, `SELECT wrapped_key FROM encryption_keys WHERE id = $1`, keyID).Scan(&wrapped)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load encryption key %d: %w", keyID, err)
+	}
+
+	dataKey, err = r.keyring.UnwrapDataKey(ctx, wrapped)
+	if err != nil {
+		return nil, err
+	}
+
+	r.dataKeysMu.Lock()
+	r.dataKeys[keyID] = dataKey
+	r.dataKeysMu.Unlock()
+
+	return dataKey, nil
+}
+
+// decryptProperty replaces the stored ciphertext of an encrypted property with its plaintext
+func (r *Repository) decryptProperty(ctx context.Context, prop *models.ConfigProperty) error {
+	if !prop.Encrypted || prop.EncryptionKeyID == nil {
+		return nil
+	}
+
+	dataKey, err := r.dataKey(ctx, *prop.EncryptionKeyID)
+	if err != nil {
+		return err
+	}
+
+	if prop.Value, err = encryption.Decrypt(dataKey, prop.Value); err != nil {
+		return fmt.Errorf("failed to decrypt property %s: %w", prop.Key, err)
+	}
+	if prop.DefaultValue != nil && encryption.IsEncrypted(*prop.DefaultValue) {
+		defaultValue, err := encryption.Decrypt(dataKey, *prop.DefaultValue)
+		if err != nil {
+			return fmt.Errorf("failed to decrypt property %s: %w", prop.Key, err)
+		}
+		prop.DefaultValue = &defaultValue
+	}
+	return nil
+}
+
+// reencrypt decrypts a stored value with the key oldKeyID and encrypts it with
+// dataKey. Values stored in plaintext, such as some defaults, are kept as is.
+func (r *Repository) reencrypt(ctx context.Context, oldKeyID int64, dataKey []byte, stored string) (*string, error) {
+	if !encryption.IsEncrypted(stored) {
+		return &stored, nil
+	}
+
+	oldKey, err := r.dataKey(ctx, oldKeyID)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := encryption.Decrypt(oldKey, stored)
+	if err != nil {
+		return nil, err
+	}
+	return encryptOptional(dataKey, &plaintext)
+}
+
+func encryptOptional(dataKey []byte, value *string) (*string, error) {
+	if value == nil {
+		return nil, nil
+	}
+
+	encrypted, err := encryption.Encrypt(dataKey, *value)
+	if err != nil {
+		return nil, err
+	}
+	return &encrypted, nil
+}
@@ -0,0 +1,45 @@
+package database
+
+import (
+	"config-manager/internal/models"
+	"context"
+	"time"
+)
+
+// GetExpiringProperties returns the properties that expire before until,
+// within the subtree of rootID or the whole tree when rootID is nil, ordered
+// by the path of their node
+func (r *Repository) GetExpiringProperties(ctx context.Context, until time.Time, rootID *int64) ([]models.PropertySearchResult, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	return r.searchProperties(ctx, rootID, `c.expires_at <= $2`, until)
+}
+
+// DeleteExpiredProperties deletes the properties that expired before now,
+// returning how many were deleted
+func (r *Repository) DeleteExpiredProperties(ctx context.Context, now time.Time) (int64, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	result, err := r.db.ExecContext(ctx, `DELETE FROM config_properties WHERE expires_at <= $1`, now)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// unexpired drops the properties that have expired at the given time
+func unexpired(properties []models.ConfigProperty, at time.Time) []models.ConfigProperty {
+	kept := properties[:0:0]
+	for _, prop := range properties {
+		if !isExpired(prop, at) {
+			kept = append(kept, prop)
+		}
+	}
+	return kept
+}
+
+func isExpired(prop models.ConfigProperty, at time.Time) bool {
+	return prop.ExpiresAt != nil && !prop.ExpiresAt.After(at)
+}
@@ -0,0 +1,65 @@
+package database
+
+import (
+	"config-manager/internal/models"
+	"context"
+	"database/sql"
+	"errors"
+)
+
+// ErrKeyFinal is returned when a property would override a key that an
+// ancestor locks as final
+var ErrKeyFinal = errors.New("the key is final on an ancestor and cannot be overridden")
+
+// GetLockingProperty returns the final property that locks key for nodeID,
+// defined on its highest ancestor that locks it, or nil if key is not locked
+func (r *Repository) GetLockingProperty(ctx context.Context, nodeID int64, key string) (*models.ConfigProperty, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	return r.lockingProperty(ctx, r.db, nodeID, key)
+}
+
+func (r *Repository) lockingProperty(ctx context.Context, q querier, nodeID int64, key string) (*models.ConfigProperty, error) {
+	query := `
+		WITH RECURSIVE ancestors AS (
+			SELECT parent_id AS id, 1 AS depth FROM config_nodes WHERE id = $1
+			UNION ALL
+			SELECT n.parent_id, a.depth + 1
+			FROM config_nodes n JOIN ancestors a ON n.id = a.id
+			WHERE n.parent_id IS NOT NULL
+		)
+		SELECT ` + prefixColumns("p", propertyColumns) + `
+		FROM config_properties p
+		JOIN ancestors a ON p.node_id = a.id
+		WHERE p.key = $2 AND p.is_final
+		ORDER BY a.depth DESC, p.environment NULLS FIRST
+		LIMIT 1`
+
+	prop, err := r.scanProperty(ctx, q.QueryRowContext(ctx, query, nodeID, key))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+
+	return prop, err
+}
+
+// CountDescendantDefinitions returns how many properties with key are defined
+// below nodeID, in any environment. They are ignored once the node locks key.
+func (r *Repository) CountDescendantDefinitions(ctx context.Context, nodeID int64, key string) (int, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	query := `
+		WITH RECURSIVE descendants AS (
+			SELECT id FROM config_nodes WHERE parent_id = $1
+			UNION ALL
+			SELECT n.id FROM config_nodes n JOIN descendants d ON n.parent_id = d.id
+		)
+		SELECT COUNT(*) FROM config_properties p JOIN descendants d ON p.node_id = d.id
+		WHERE p.key = $2`
+
+	var count int
+	err := r.db.QueryRowContext(ctx, query, nodeID, key).Scan(&count)
+	return count, err
+}
@@ -0,0 +1,74 @@
+package database
+
+import (
+	"config-manager/internal/models"
+	"context"
+	"database/sql"
+	"time"
+)
+
+// getNodeAt returns the version of a node that was current at the given time,
+// or nil if the node did not exist then
+func (r *Repository) getNodeAt(ctx context.Context, nodeID int64, at time.Time) (*models.ConfigNode, error) {
+	query := `
+		SELECT v.node_id, v.name, v.node_type, v.parent_id, v.description, '{}'::jsonb, '{}'::jsonb, 0,
+			(SELECT MIN(first.valid_from) FROM config_node_versions first WHERE first.node_id = v.node_id),
+			v.valid_from
+		FROM config_node_versions v
+		WHERE v.node_id = $1 AND v.valid_from <= $2 AND (v.valid_to IS NULL OR v.valid_to > $2)`
+
+	node, err := scanNode(r.db.QueryRowContext(ctx, query, nodeID, at))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return node, err
+}
+
+// getNodePathAt returns the path from the root to nodeID as the tree was at the given time
+func (r *Repository) getNodePathAt(ctx context.Context, nodeID int64, at time.Time) ([]models.ConfigNode, error) {
+	var path []models.ConfigNode
+	currentID := &nodeID
+
+	for currentID != nil {
+		node, err := r.getNodeAt(ctx, *currentID, at)
+		if err != nil {
+			return nil, err
+		}
+		if node == nil {
+			break
+		}
+
+		path = append([]models.ConfigNode{*node}, path...)
+		currentID = node.ParentID
+	}
+
+	return path, nil
+}
+
+// getPropertiesAt returns the properties defined on a node at the given time.
+// Encrypted values are decrypted with the key they were written with.
+func (r *Repository) getPropertiesAt(ctx context.Context, nodeID int64, at time.Time) ([]models.ConfigProperty, error) {
+	query := `
+		SELECT property_id, node_id, key, environment, value, data_type, default_value, description,
+			encrypted, encryption_key_id, rollout_percentage, rollout_key, is_final, namespace, tags, expires_at, valid_from, valid_from
+		FROM config_property_versions
+		WHERE node_id = $1 AND valid_from <= $2 AND (valid_to IS NULL OR valid_to > $2)
+		ORDER BY key, environment NULLS FIRST`
+
+	rows, err := r.db.QueryContext(ctx, query, nodeID, at)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var properties []models.ConfigProperty
+	for rows.Next() {
+		prop, err := r.scanProperty(ctx, rows)
+		if err != nil {
+			return nil, err
+		}
+		properties = append(properties, *prop)
+	}
+
+	return properties, rows.Err()
+}
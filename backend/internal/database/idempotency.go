@@ -0,0 +1,91 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// IdempotencyRecord is what is stored for an idempotency key: the hash of the
+// request it was first used for and, once that request completed, its response
+type IdempotencyRecord struct {
+	RequestHash string
+	// StatusCode is zero while the first request is still in flight
+	StatusCode  int
+	ContentType string
+	Body        []byte
+}
+
+// ReserveIdempotencyKey claims an idempotency key of a principal for a request.
+// It returns nil once the key is claimed, or the record of the request that
+// claimed it before. Keys created before expiredBefore, and keys of requests
+// still in flight since before abandonedBefore, such as those of a server that
+// crashed, are dropped first so they can be claimed again.
+func (r *Repository) ReserveIdempotencyKey(ctx context.Context, principal, key, requestHash string, expiredBefore, abandonedBefore time.Time) (*IdempotencyRecord, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	_, err := r.db.ExecContext(ctx, `
+		DELETE FROM config_idempotency_keys
+		WHERE created_at < $1 OR (status_code IS NULL AND created_at < $2)`, expiredBefore, abandonedBefore)
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		result, err := r.db.ExecContext(ctx, `
+			INSERT INTO config_idempotency_keys (principal, idempotency_key, request_hash, created_at)
+			VALUES ($1, $2, $3, $4)
+			ON CONFLICT (principal, idempotency_key) DO NOTHING`,
+			principal, key, requestHash, time.Now())
+		if err != nil {
+			return nil, err
+		}
+		if claimed, err := result.RowsAffected(); err != nil || claimed == 1 {
+			return nil, err
+		}
+
+		var record IdempotencyRecord
+		var status sql.NullInt64
+		err = r.db.QueryRowContext(ctx, `
+			SELECT request_hash, status_code, content_type, response_body
+			FROM config_idempotency_keys
+			WHERE principal = $1 AND idempotency_key = $2`, principal, key).
+			Scan(&record.RequestHash, &status, &record.ContentType, &record.Body)
+		if err == sql.ErrNoRows {
+			// Released by the request that held it, claim it again
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		record.StatusCode = int(status.Int64)
+		return &record, nil
+	}
+}
+
+// CompleteIdempotencyKey records the response to the request that claimed an
+// idempotency key, to be replayed on retries
+func (r *Repository) CompleteIdempotencyKey(ctx context.Context, principal, key string, status int, contentType string, body []byte) error {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE config_idempotency_keys
+		SET status_code = $3, content_type = $4, response_body = $5
+		WHERE principal = $1 AND idempotency_key = $2`,
+		principal, key, status, contentType, body)
+	return err
+}
+
+// ReleaseIdempotencyKey drops an idempotency key whose request failed, so that
+// a retry runs the request again
+func (r *Repository) ReleaseIdempotencyKey(ctx context.Context, principal, key string) error {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	_, err := r.db.ExecContext(ctx, `
+		DELETE FROM config_idempotency_keys
+		WHERE principal = $1 AND idempotency_key = $2 AND status_code IS NULL`, principal, key)
+	return err
+}
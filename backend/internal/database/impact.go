@@ -0,0 +1,126 @@
+package database
+
+import (
+	"config-manager/internal/models"
+	"context"
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// impactState is the value of a key resolved down a branch of the tree
+type impactState struct {
+	prop   *models.ConfigProperty
+	locked bool
+}
+
+// AnalyzeImpact lists the leaves below change.NodeID, or the node itself when
+// it has no children, whose value of change.Key would differ if the change
+// were applied. Values are compared for the change's environment, before
+// interpolation and rollouts.
+func (r *Repository) AnalyzeImpact(ctx context.Context, change models.PropertyDraft) (*models.ImpactReport, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	path, children, ids, err := r.getPathAndSubtree(ctx, change.NodeID)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := r.db.QueryContext(ctx, `SELECT `+propertyColumns+` FROM config_properties WHERE node_id = ANY($1) AND key = $2`, ids, change.Key)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	properties := make(map[int64][]models.ConfigProperty)
+	for rows.Next() {
+		prop, err := r.scanProperty(ctx, rows)
+		if err != nil {
+			return nil, err
+		}
+		properties[prop.NodeID] = append(properties[prop.NodeID], *prop)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	environment := ""
+	if change.Environment != nil {
+		environment = *change.Environment
+	}
+
+	// step applies the definitions of the key on a node
+	step := func(state impactState, properties []models.ConfigProperty) impactState {
+		locked := map[string]bool{change.Key: state.locked}
+		for _, prop := range unlocked(forEnvironment(properties, environment), locked) {
+			prop := prop
+			state.prop = &prop
+		}
+		state.locked = locked[change.Key]
+		return state
+	}
+
+	var before impactState
+	names := make([]string, 0, len(path))
+	for _, node := range path[:len(path)-1] {
+		names = append(names, node.Name)
+		before = step(before, properties[node.ID])
+	}
+
+	report := &models.ImpactReport{
+		NodeID:      change.NodeID,
+		Key:         change.Key,
+		Environment: change.Environment,
+		Ignored:     before.locked,
+		Affected:    []models.ImpactedLeaf{},
+	}
+
+	var walk func(node models.ConfigNode, names []string, before, after impactState)
+	walk = func(node models.ConfigNode, names []string, before, after impactState) {
+		names = append(names[:len(names):len(names)], node.Name)
+		before = step(before, properties[node.ID])
+		if node.ID == change.NodeID {
+			after = step(after, overlayDrafts(properties[node.ID], []models.PropertyDraft{change}))
+		} else {
+			after = step(after, properties[node.ID])
+		}
+
+		if len(children[node.ID]) > 0 {
+			for _, child := range children[node.ID] {
+				walk(child, names, before, after)
+			}
+			return
+		}
+
+		report.LeavesChecked++
+		leaf := models.ImpactedLeaf{NodeID: node.ID, NodeName: node.Name, Path: "/" + strings.Join(names, "/")}
+		var beforeValue, afterValue interface{}
+		leaf.HadKey, beforeValue = impactValue(before.prop)
+		leaf.HasKey, afterValue = impactValue(after.prop)
+		if leaf.HadKey == leaf.HasKey && reflect.DeepEqual(beforeValue, afterValue) {
+			return
+		}
+		leaf.Encrypted = (before.prop != nil && before.prop.Encrypted) || (after.prop != nil && after.prop.Encrypted)
+		if !leaf.Encrypted {
+			leaf.Before, leaf.After = beforeValue, afterValue
+		}
+		report.Affected = append(report.Affected, leaf)
+	}
+	walk(path[len(path)-1], names, before, before)
+
+	sort.Slice(report.Affected, func(i, j int) bool { return report.Affected[i].Path < report.Affected[j].Path })
+	return report, nil
+}
+
+// impactValue decodes the value a property resolves to
+func impactValue(prop *models.ConfigProperty) (bool, interface{}) {
+	if prop == nil {
+		return false, nil
+	}
+	var value interface{}
+	if err := json.Unmarshal([]byte(prop.Value), &value); err != nil {
+		value = prop.Value
+	}
+	return true, value
+}
@@ -0,0 +1,140 @@
+package database
+
+import (
+	"config-manager/internal/models"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// interpolationWarning is the resolve warning code of a key whose value could
+// not be interpolated
+const interpolationWarning = "interpolation_error"
+
+// InterpolationError is returned when a string value references a key that
+// does not resolve, forms a reference cycle, or is malformed
+type InterpolationError struct {
+	Key     string
+	Message string
+}
+
+func (e *InterpolationError) Error() string {
+	return fmt.Sprintf("cannot interpolate %q: %s", e.Key, e.Message)
+}
+
+// InterpolationFailure returns why key was left out of a resolved
+// configuration because its value could not be interpolated, or nil
+func InterpolationFailure(resolved *models.ResolvedConfiguration, key string) *InterpolationError {
+	for _, warning := range resolved.Warnings {
+		if warning.Code == interpolationWarning && warning.Key == key {
+			return &InterpolationError{Key: key, Message: warning.Message}
+		}
+	}
+	return nil
+}
+
+// interpolate expands ${other.key} placeholders in the string values of a
+// resolved configuration using the other resolved values. Referenced strings
+// are inserted as-is, other types as their JSON encoding. $${ produces a
+// literal ${ without starting a placeholder. Keys that cannot be expanded are
+// removed from the configuration and returned as warnings, ordered by key, so
+// that one broken value does not fail the whole resolution.
+func interpolate(resolved map[string]interface{}) []models.ResolveWarning {
+	in := &interpolator{
+		resolved: resolved,
+		expanded: make(map[string]string),
+		visiting: make(map[string]bool),
+	}
+
+	var warnings []models.ResolveWarning
+	for key, value := range resolved {
+		if _, ok := value.(string); !ok {
+			continue
+		}
+		expanded, err := in.expand(key, nil)
+		if err != nil {
+			warnings = append(warnings, models.ResolveWarning{Code: interpolationWarning, Key: key, Message: err.Message})
+			continue
+		}
+		resolved[key] = expanded
+	}
+
+	for _, warning := range warnings {
+		delete(resolved, warning.Key)
+	}
+	sort.Slice(warnings, func(i, j int) bool { return warnings[i].Key < warnings[j].Key })
+	return warnings
+}
+
+type interpolator struct {
+	resolved map[string]interface{}
+	expanded map[string]string
+	visiting map[string]bool
+}
+
+// expand returns the fully expanded string form of key. chain holds the keys
+// currently being expanded, to report cycles. Failures are reported for the
+// first key of the chain and are not remembered, as the keys of a cycle each
+// describe it from their own start.
+func (in *interpolator) expand(key string, chain []string) (string, *InterpolationError) {
+	if value, ok := in.expanded[key]; ok {
+		return value, nil
+	}
+	chain = append(chain, key)
+	if in.visiting[key] {
+		return "", &InterpolationError{Key: chain[0], Message: "reference cycle " + strings.Join(chain, " -> ")}
+	}
+
+	raw, ok := in.resolved[key].(string)
+	if !ok {
+		encoded, err := json.Marshal(in.resolved[key])
+		if err != nil {
+			return "", &InterpolationError{Key: chain[0], Message: fmt.Sprintf("cannot encode %q: %v", key, err)}
+		}
+		return string(encoded), nil
+	}
+
+	in.visiting[key] = true
+	defer delete(in.visiting, key)
+
+	var b strings.Builder
+	for i := 0; i < len(raw); {
+		switch {
+		case strings.HasPrefix(raw[i:], "$${"):
+			b.WriteString("${")
+			i += 3
+		case strings.HasPrefix(raw[i:], "${"):
+			end := strings.IndexByte(raw[i+2:], '}')
+			if end < 0 {
+				return "", &InterpolationError{Key: chain[0], Message: placeholderMessage(chain, "unterminated placeholder")}
+			}
+			ref := strings.TrimSpace(raw[i+2 : i+2+end])
+			if _, exists := in.resolved[ref]; !exists {
+				return "", &InterpolationError{Key: chain[0], Message: placeholderMessage(chain, fmt.Sprintf("unknown key %q", ref))}
+			}
+
+			value, err := in.expand(ref, chain)
+			if err != nil {
+				return "", err
+			}
+			b.WriteString(value)
+			i += 2 + end + 1
+		default:
+			b.WriteByte(raw[i])
+			i++
+		}
+	}
+
+	in.expanded[key] = b.String()
+	return in.expanded[key], nil
+}
+
+// placeholderMessage describes a bad placeholder in the last key of chain,
+// naming that key when it is only referenced by the first
+func placeholderMessage(chain []string, message string) string {
+	if len(chain) == 1 {
+		return message
+	}
+	return fmt.Sprintf("%s in referenced key %q", message, chain[len(chain)-1])
+}
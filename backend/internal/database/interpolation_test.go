@@ -0,0 +1,116 @@
+package database
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestInterpolate(t *testing.T) {
+	tests := []struct {
+		name     string
+		resolved map[string]interface{}
+		want     map[string]interface{}
+		warnings map[string]string // Key to message
+	}{
+		{
+			name:     "no placeholders",
+			resolved: map[string]interface{}{"a": "plain", "b": 3.0},
+			want:     map[string]interface{}{"a": "plain", "b": 3.0},
+		},
+		{
+			name:     "string reference",
+			resolved: map[string]interface{}{"host": "db", "dsn": "postgres://${host}/app"},
+			want:     map[string]interface{}{"host": "db", "dsn": "postgres://db/app"},
+		},
+		{
+			name:     "chained references",
+			resolved: map[string]interface{}{"a": "${b}!", "b": "${c}?", "c": "x"},
+			want:     map[string]interface{}{"a": "x?!", "b": "x?", "c": "x"},
+		},
+		{
+			name:     "non-string values as JSON",
+			resolved: map[string]interface{}{"port": 5432.0, "tls": true, "list": []interface{}{"a"}, "s": "${port} ${tls} ${list}"},
+			want:     map[string]interface{}{"port": 5432.0, "tls": true, "list": []interface{}{"a"}, "s": `5432 true ["a"]`},
+		},
+		{
+			name:     "whitespace in placeholder",
+			resolved: map[string]interface{}{"a": "x", "b": "${ a }"},
+			want:     map[string]interface{}{"a": "x", "b": "x"},
+		},
+		{
+			name:     "escaped placeholder",
+			resolved: map[string]interface{}{"a": "x", "b": "$${a} is ${a}", "c": "$$${a}"},
+			want:     map[string]interface{}{"a": "x", "b": "${a} is x", "c": "$${a}"},
+		},
+		{
+			name:     "escape survives being referenced",
+			resolved: map[string]interface{}{"a": "$${HOME}", "b": "${a}/bin"},
+			want:     map[string]interface{}{"a": "${HOME}", "b": "${HOME}/bin"},
+		},
+		{
+			name:     "lone dollar signs",
+			resolved: map[string]interface{}{"a": "$5 and $ and {x}"},
+			want:     map[string]interface{}{"a": "$5 and $ and {x}"},
+		},
+		{
+			name:     "unknown key",
+			resolved: map[string]interface{}{"a": "${missing}", "b": "ok"},
+			want:     map[string]interface{}{"b": "ok"},
+			warnings: map[string]string{"a": `unknown key "missing"`},
+		},
+		{
+			name:     "unterminated placeholder",
+			resolved: map[string]interface{}{"a": "${b", "b": "x"},
+			want:     map[string]interface{}{"b": "x"},
+			warnings: map[string]string{"a": "unterminated placeholder"},
+		},
+		{
+			name:     "self reference",
+			resolved: map[string]interface{}{"a": "${a}"},
+			want:     map[string]interface{}{},
+			warnings: map[string]string{"a": "reference cycle a -> a"},
+		},
+		{
+			name:     "cycle and a key depending on it",
+			resolved: map[string]interface{}{"a": "${b}", "b": "${a}", "c": "${a}", "d": "fine"},
+			want:     map[string]interface{}{"d": "fine"},
+			warnings: map[string]string{
+				"a": "reference cycle a -> b -> a",
+				"b": "reference cycle b -> a -> b",
+				"c": "reference cycle c -> a -> b -> a",
+			},
+		},
+		{
+			name:     "broken referenced key",
+			resolved: map[string]interface{}{"a": "${b}", "b": "${missing}"},
+			want:     map[string]interface{}{},
+			warnings: map[string]string{
+				"a": `unknown key "missing" in referenced key "b"`,
+				"b": `unknown key "missing"`,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			warnings := interpolate(tt.resolved)
+			if !reflect.DeepEqual(tt.resolved, tt.want) {
+				t.Errorf("resolved: got %v, want %v", tt.resolved, tt.want)
+			}
+
+			got := make(map[string]string)
+			for i, warning := range warnings {
+				if warning.Code != interpolationWarning {
+					t.Errorf("warning code: got %s", warning.Code)
+				}
+				if i > 0 && warnings[i-1].Key >= warning.Key {
+					t.Errorf("warnings are not ordered by key: %+v", warnings)
+				}
+				got[warning.Key] = warning.Message
+			}
+			if len(got) != len(tt.warnings) || (len(got) > 0 && !reflect.DeepEqual(got, tt.warnings)) {
+				t.Errorf("warnings: got %v, want %v", got, tt.warnings)
+			}
+		})
+	}
+}
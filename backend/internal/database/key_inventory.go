@@ -0,0 +1,61 @@
+package database
+
+import (
+	"config-manager/internal/models"
+	"context"
+	"sort"
+)
+
+// GetKeyInventory lists every key defined within the subtree of rootID, with
+// the nodes defining it ordered by path
+func (r *Repository) GetKeyInventory(ctx context.Context, rootID int64) ([]models.KeyInventoryEntry, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	query := pathsCTE + `
+		SELECT c.key, c.environment, c.node_id, p.path
+		FROM config_properties c JOIN paths p ON c.node_id = p.id
+		ORDER BY c.key, p.path, c.environment NULLS FIRST`
+
+	rows, err := r.db.QueryContext(ctx, query, rootID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	inventory := []models.KeyInventoryEntry{}
+	var entry *models.KeyInventoryEntry
+	var environments map[string]bool
+	for rows.Next() {
+		var key, path string
+		var environment *string
+		var nodeID int64
+		if err := rows.Scan(&key, &environment, &nodeID, &path); err != nil {
+			return nil, err
+		}
+
+		if entry == nil || entry.Key != key {
+			inventory = append(inventory, models.KeyInventoryEntry{Key: key, Environments: []string{}, Nodes: []models.KeyDefiningNode{}})
+			entry = &inventory[len(inventory)-1]
+			environments = make(map[string]bool)
+		}
+		entry.PropertyCount++
+		if environment != nil && !environments[*environment] {
+			environments[*environment] = true
+			entry.Environments = append(entry.Environments, *environment)
+		}
+		// Rows of a node are adjacent, as they share its path
+		if n := len(entry.Nodes); n == 0 || entry.Nodes[n-1].NodeID != nodeID {
+			entry.Nodes = append(entry.Nodes, models.KeyDefiningNode{NodeID: nodeID, Path: path})
+			entry.NodeCount++
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, entry := range inventory {
+		sort.Strings(entry.Environments)
+	}
+	return inventory, nil
+}
@@ -0,0 +1,134 @@
+package database
+
+import (
+	"config-manager/internal/models"
+	"context"
+	"database/sql"
+	"time"
+)
+
+const registeredKeyColumns = `key, description, expected_type, owner_team, schema_ref, created_by, created_at, updated_at`
+
+func scanRegisteredKey(row rowScanner) (*models.RegisteredKey, error) {
+	var registered models.RegisteredKey
+	err := row.Scan(&registered.Key, &registered.Description, &registered.ExpectedType, &registered.OwnerTeam,
+		&registered.SchemaRef, &registered.CreatedBy, &registered.CreatedAt, &registered.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &registered, nil
+}
+
+// RegisterKey adds a key to the registry, or updates its registration
+func (r *Repository) RegisterKey(ctx context.Context, req models.RegisterKeyRequest, createdBy string) (*models.RegisteredKey, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	query := `
+		INSERT INTO config_key_registry (key, description, expected_type, owner_team, schema_ref, created_by, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $7)
+		ON CONFLICT (key) DO UPDATE SET
+			description = EXCLUDED.description,
+			expected_type = EXCLUDED.expected_type,
+			owner_team = EXCLUDED.owner_team,
+			schema_ref = EXCLUDED.schema_ref,
+			updated_at = EXCLUDED.updated_at
+		RETURNING ` + registeredKeyColumns
+
+	return scanRegisteredKey(r.db.QueryRowContext(ctx, query, req.Key, req.Description, req.ExpectedType, req.OwnerTeam, req.SchemaRef, createdBy, time.Now()))
+}
+
+// GetRegisteredKey returns the registration of a key, or nil if it is not registered
+func (r *Repository) GetRegisteredKey(ctx context.Context, key string) (*models.RegisteredKey, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	registered, err := scanRegisteredKey(r.db.QueryRowContext(ctx, `SELECT `+registeredKeyColumns+` FROM config_key_registry WHERE key = $1`, key))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return registered, err
+}
+
+// UnregisterKey removes a key from the registry, reporting whether it was registered
+func (r *Repository) UnregisterKey(ctx context.Context, key string) (bool, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	result, err := r.db.ExecContext(ctx, `DELETE FROM config_key_registry WHERE key = $1`, key)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+// UnregisteredKeys returns the keys among keys that are not registered, in order
+func (r *Repository) UnregisteredKeys(ctx context.Context, keys []string) ([]string, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT DISTINCT k FROM unnest($1::text[]) AS k
+		WHERE NOT EXISTS (SELECT 1 FROM config_key_registry r WHERE r.key = k)
+		ORDER BY k`, keys)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var unregistered []string
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			return nil, err
+		}
+		unregistered = append(unregistered, key)
+	}
+	return unregistered, rows.Err()
+}
+
+// GetKeyCatalog lists every registered key and every key defined by a
+// property, with how many properties and nodes define it
+func (r *Repository) GetKeyCatalog(ctx context.Context) ([]models.KeyCatalogEntry, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	query := `
+		SELECT COALESCE(r.key, u.key), r.key IS NOT NULL,
+			COALESCE(r.description, ''), r.expected_type, COALESCE(r.owner_team, ''), COALESCE(r.schema_ref, ''),
+			COALESCE(r.created_by, ''), COALESCE(r.created_at, 'epoch'), COALESCE(r.updated_at, 'epoch'),
+			COALESCE(u.property_count, 0), COALESCE(u.node_count, 0)
+		FROM config_key_registry r
+		FULL OUTER JOIN (
+			SELECT key, COUNT(*) AS property_count, COUNT(DISTINCT node_id) AS node_count
+			FROM config_properties GROUP BY key
+		) u ON u.key = r.key
+		ORDER BY 1`
+
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	catalog := []models.KeyCatalogEntry{}
+	for rows.Next() {
+		var entry models.KeyCatalogEntry
+		var registered bool
+		var registration models.RegisteredKey
+		err := rows.Scan(&entry.Key, &registered,
+			&registration.Description, &registration.ExpectedType, &registration.OwnerTeam, &registration.SchemaRef,
+			&registration.CreatedBy, &registration.CreatedAt, &registration.UpdatedAt,
+			&entry.PropertyCount, &entry.NodeCount)
+		if err != nil {
+			return nil, err
+		}
+		if registered {
+			registration.Key = entry.Key
+			entry.Registration = &registration
+		}
+		catalog = append(catalog, entry)
+	}
+	return catalog, rows.Err()
+}
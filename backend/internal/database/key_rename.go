@@ -0,0 +1,174 @@
+package database
+
+import (
+	"config-manager/internal/models"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrKeyNotDefined is returned when renaming a key no node defines
+var ErrKeyNotDefined = errors.New("no node defines this key")
+
+// KeyRenameConflictError is returned when some nodes define both the old and
+// the new key for the same environment, so renaming would merge them
+type KeyRenameConflictError struct {
+	NodeIDs []int64
+}
+
+func (e *KeyRenameConflictError) Error() string {
+	return fmt.Sprintf("%d nodes already define the new key", len(e.NodeIDs))
+}
+
+// AuditActionKeyRename is the audit log action of a key rename
+const AuditActionKeyRename = "key.rename"
+
+// RenameKey renames a key on every node defining it, properties and drafts
+// alike, in one transaction recorded in the audit log. Values interpolating
+// the old key are not rewritten, they are reported instead.
+func (r *Repository) RenameKey(ctx context.Context, oldKey, newKey, actor string) (*models.KeyRenameResult, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// Lock the definitions of both keys so that none appear while checking
+	if _, err := tx.ExecContext(ctx, `SELECT id FROM config_properties WHERE key = $1 OR key = $2 FOR UPDATE`, oldKey, newKey); err != nil {
+		return nil, err
+	}
+
+	rows, err := tx.QueryContext(ctx, `
+		SELECT o.node_id FROM config_properties o
+		JOIN config_properties n ON n.node_id = o.node_id AND n.key = $2 AND COALESCE(n.environment, '') = COALESCE(o.environment, '')
+		WHERE o.key = $1
+		UNION
+		SELECT o.node_id FROM property_drafts o
+		JOIN property_drafts n ON n.node_id = o.node_id AND n.key = $2 AND COALESCE(n.environment, '') = COALESCE(o.environment, '')
+		WHERE o.key = $1
+		ORDER BY 1`, oldKey, newKey)
+	if err != nil {
+		return nil, err
+	}
+	var conflicts []int64
+	for rows.Next() {
+		var nodeID int64
+		if err := rows.Scan(&nodeID); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		conflicts = append(conflicts, nodeID)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(conflicts) > 0 {
+		return nil, &KeyRenameConflictError{NodeIDs: conflicts}
+	}
+
+	result := &models.KeyRenameResult{
+		OldKey:                 oldKey,
+		NewKey:                 newKey,
+		PropertyIDs:            []int64{},
+		NodeIDs:                []int64{},
+		ReferencingPropertyIDs: []int64{},
+	}
+	now := time.Now()
+
+	rows, err = tx.QueryContext(ctx, `UPDATE config_properties SET key = $2, updated_at = $3 WHERE key = $1 RETURNING id, node_id`, oldKey, newKey, now)
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[int64]bool)
+	for rows.Next() {
+		var propertyID, nodeID int64
+		if err := rows.Scan(&propertyID, &nodeID); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		result.PropertyIDs = append(result.PropertyIDs, propertyID)
+		if !seen[nodeID] {
+			seen[nodeID] = true
+			result.NodeIDs = append(result.NodeIDs, nodeID)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	drafts, err := tx.ExecContext(ctx, `UPDATE property_drafts SET key = $2, updated_at = $3 WHERE key = $1`, oldKey, newKey, now)
+	if err != nil {
+		return nil, err
+	}
+	renamedDrafts, err := drafts.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+	result.RenamedDrafts = int(renamedDrafts)
+
+	if len(result.PropertyIDs) == 0 && result.RenamedDrafts == 0 {
+		return nil, ErrKeyNotDefined
+	}
+
+	// strpos rather than LIKE, as keys commonly contain the _ wildcard
+	rows, err = tx.QueryContext(ctx, `
+		SELECT id FROM config_properties
+		WHERE NOT encrypted AND strpos(value, '${' || $1 || '}') > 0
+		ORDER BY id`, oldKey)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var propertyID int64
+		if err := rows.Scan(&propertyID); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		result.ReferencingPropertyIDs = append(result.ReferencingPropertyIDs, propertyID)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	result.AuditID, err = recordAudit(ctx, tx, AuditActionKeyRename, actor, map[string]interface{}{
+		"old_key":        oldKey,
+		"new_key":        newKey,
+		"property_ids":   result.PropertyIDs,
+		"node_ids":       result.NodeIDs,
+		"renamed_drafts": result.RenamedDrafts,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return result, tx.Commit()
+}
+
+// RequiredApprovalsForKey returns the most approvals required by a protected
+// subtree containing any node that defines key, or zero if none is protected
+func (r *Repository) RequiredApprovalsForKey(ctx context.Context, key string) (int, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	query := `
+		WITH RECURSIVE ancestors AS (
+			SELECT node_id AS id FROM config_properties WHERE key = $1
+			UNION
+			SELECT node_id FROM property_drafts WHERE key = $1
+			UNION
+			SELECT n.parent_id FROM config_nodes n JOIN ancestors a ON n.id = a.id WHERE n.parent_id IS NOT NULL
+		)
+		SELECT COALESCE(MAX(p.required_approvals), 0) FROM protected_subtrees p
+		WHERE p.node_id IN (SELECT id FROM ancestors)`
+
+	var required int
+	err := r.db.QueryRowContext(ctx, query, key).Scan(&required)
+	return required, err
+}
@@ -0,0 +1,391 @@
+package database
+
+import (
+	"config-manager/internal/encryption"
+	"config-manager/internal/models"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// AuditActionKeyRotate is the audit log action of requesting a key rotation
+const AuditActionKeyRotate = "encryption_key.rotate"
+
+var (
+	ErrEncryptionKeyNotFound = errors.New("encryption key not found")
+	ErrEncryptionKeyRetired  = errors.New("encryption key was already rotated")
+	ErrKeyRotationExists     = errors.New("a rotation of this key is already pending, running or failed")
+	ErrKeyRotationNotFound   = errors.New("key rotation not found")
+	ErrKeyRotationNotPending = errors.New("key rotation is no longer pending")
+	ErrKeyRotationNotFailed  = errors.New("only failed key rotations can be resumed")
+)
+
+const keyRotationColumns = `id, node_id, old_key_id, new_key_id, status, total_properties, rotated_properties, error, requested_by, scheduled_at, created_at, started_at, completed_at`
+
+func scanKeyRotation(row rowScanner) (*models.KeyRotation, error) {
+	var rotation models.KeyRotation
+	err := row.Scan(
+		&rotation.ID, &rotation.NodeID, &rotation.OldKeyID, &rotation.NewKeyID, &rotation.Status,
+		&rotation.TotalProperties, &rotation.RotatedProperties, &rotation.Error, &rotation.RequestedBy,
+		&rotation.ScheduledAt, &rotation.CreatedAt, &rotation.StartedAt, &rotation.CompletedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	setKeyRotationProgress(&rotation)
+	return &rotation, nil
+}
+
+// setKeyRotationProgress computes the share of the job done. Properties updated
+// through the API while the job runs move to the new key on their own, so a
+// completed job may have rotated fewer than its total.
+func setKeyRotationProgress(rotation *models.KeyRotation) {
+	switch {
+	case rotation.Status == models.KeyRotationStatusCompleted:
+		rotation.PercentComplete = 100
+	case rotation.TotalProperties > 0:
+		rotation.PercentComplete = float64(rotation.RotatedProperties) * 100 / float64(rotation.TotalProperties)
+	default:
+		rotation.PercentComplete = 0
+	}
+}
+
+// CreateKeyRotation schedules the rotation of an active key at scheduledAt and
+// records in the audit log that actor requested it
+func (r *Repository) CreateKeyRotation(ctx context.Context, keyID int64, scheduledAt time.Time, actor string) (*models.KeyRotation, error) {
+	if r.keyring == nil {
+		return nil, encryption.ErrNotConfigured
+	}
+
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var nodeID int64
+	var retiredAt sql.NullTime
+	err = tx.QueryRowContext(ctx, `SELECT node_id, retired_at FROM encryption_keys WHERE id = $1 FOR UPDATE`, keyID).Scan(&nodeID, &retiredAt)
+	if err == sql.ErrNoRows {
+		return nil, ErrEncryptionKeyNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if retiredAt.Valid {
+		return nil, ErrEncryptionKeyRetired
+	}
+
+	rotation, err := scanKeyRotation(tx.QueryRowContext(ctx, `
+		INSERT INTO key_rotations (node_id, old_key_id, status, requested_by, scheduled_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING `+keyRotationColumns,
+		nodeID, keyID, models.KeyRotationStatusPending, actor, scheduledAt, time.Now()))
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			return nil, ErrKeyRotationExists
+		}
+		return nil, err
+	}
+
+	_, err = recordAudit(ctx, tx, AuditActionKeyRotate, actor, map[string]interface{}{
+		"rotation_id":  rotation.ID,
+		"key_id":       keyID,
+		"node_id":      nodeID,
+		"scheduled_at": scheduledAt,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return rotation, nil
+}
+
+// GetKeyRotations returns the key rotations, newest first
+func (r *Repository) GetKeyRotations(ctx context.Context) ([]models.KeyRotation, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := r.db.QueryContext(ctx, `SELECT `+keyRotationColumns+` FROM key_rotations ORDER BY id DESC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rotations := []models.KeyRotation{}
+	for rows.Next() {
+		rotation, err := scanKeyRotation(rows)
+		if err != nil {
+			return nil, err
+		}
+		rotations = append(rotations, *rotation)
+	}
+	return rotations, rows.Err()
+}
+
+// GetKeyRotation returns a key rotation with its progress, or nil if it does not exist
+func (r *Repository) GetKeyRotation(ctx context.Context, id int64) (*models.KeyRotation, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	rotation, err := scanKeyRotation(r.db.QueryRowContext(ctx, `SELECT `+keyRotationColumns+` FROM key_rotations WHERE id = $1`, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return rotation, err
+}
+
+// CancelKeyRotation cancels a rotation that has not started yet
+func (r *Repository) CancelKeyRotation(ctx context.Context, id int64) error {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	result, err := r.db.ExecContext(ctx, `UPDATE key_rotations SET status = $1 WHERE id = $2 AND status = $3`,
+		models.KeyRotationStatusCancelled, id, models.KeyRotationStatusPending)
+	if err != nil {
+		return err
+	}
+	if affected, err := result.RowsAffected(); err != nil || affected > 0 {
+		return err
+	}
+	return r.keyRotationInState(ctx, id, ErrKeyRotationNotPending)
+}
+
+// ResumeKeyRotation queues a failed rotation again. It carries on from the
+// properties still encrypted with the old key.
+func (r *Repository) ResumeKeyRotation(ctx context.Context, id int64) (*models.KeyRotation, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	rotation, err := scanKeyRotation(r.db.QueryRowContext(ctx, `
+		UPDATE key_rotations
+		SET status = CASE WHEN new_key_id IS NULL THEN $1 ELSE $2 END, error = NULL
+		WHERE id = $3 AND status = $4
+		RETURNING `+keyRotationColumns,
+		models.KeyRotationStatusPending, models.KeyRotationStatusRunning, id, models.KeyRotationStatusFailed))
+	if err == sql.ErrNoRows {
+		return nil, r.keyRotationInState(ctx, id, ErrKeyRotationNotFailed)
+	}
+	return rotation, err
+}
+
+// keyRotationInState tells apart a rotation that does not exist from one in
+// the wrong state, returning wrongState, when an update matched no row
+func (r *Repository) keyRotationInState(ctx context.Context, id int64, wrongState error) error {
+	var exists bool
+	if err := r.db.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM key_rotations WHERE id = $1)`, id).Scan(&exists); err != nil {
+		return err
+	}
+	if !exists {
+		return ErrKeyRotationNotFound
+	}
+	return wrongState
+}
+
+// RunKeyRotationBatch advances the earliest due rotation by one step, in its
+// own transaction: a pending rotation is started by creating the new key and
+// retiring the old one, so that new values use the new key from then on, and a
+// running one re-encrypts up to batchSize properties still using the old key.
+// Rows locked by another server are skipped, so several replicas can rotate
+// keys. It returns nil when nothing is due; a rotation whose step fails is
+// marked failed and returned with its error.
+func (r *Repository) RunKeyRotationBatch(ctx context.Context, now time.Time, batchSize int) (*models.KeyRotation, error) {
+	if r.keyring == nil {
+		return nil, nil
+	}
+
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// Change events recorded by this transaction name the rotation as their source
+	if _, err := tx.ExecContext(ctx, `SET LOCAL config_manager.event_source = 'key-rotation'`); err != nil {
+		return nil, err
+	}
+
+	rotation, err := scanKeyRotation(tx.QueryRowContext(ctx, `
+		SELECT `+keyRotationColumns+` FROM key_rotations
+		WHERE status IN ($1, $2) AND scheduled_at <= $3
+		ORDER BY scheduled_at, id
+		LIMIT 1
+		FOR UPDATE SKIP LOCKED`, models.KeyRotationStatusPending, models.KeyRotationStatusRunning, now))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var newDataKey []byte
+	if rotation.Status == models.KeyRotationStatusPending {
+		newDataKey, err = r.startKeyRotation(ctx, tx, rotation, now)
+	} else {
+		err = r.rotateProperties(ctx, tx, rotation, batchSize, now)
+	}
+	if err != nil {
+		// Roll back the partial step, then record the failure on its own
+		tx.Rollback()
+		message := err.Error()
+		if _, updateErr := r.db.ExecContext(ctx, `UPDATE key_rotations SET status = $1, error = $2 WHERE id = $3`,
+			models.KeyRotationStatusFailed, message, rotation.ID); updateErr != nil {
+			return nil, updateErr
+		}
+		rotation.Status = models.KeyRotationStatusFailed
+		rotation.Error = &message
+		return rotation, err
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE key_rotations
+		SET status = $1, new_key_id = $2, total_properties = $3, rotated_properties = $4, started_at = $5, completed_at = $6
+		WHERE id = $7`,
+		rotation.Status, rotation.NewKeyID, rotation.TotalProperties, rotation.RotatedProperties, rotation.StartedAt, rotation.CompletedAt, rotation.ID)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	if newDataKey != nil {
+		r.dataKeysMu.Lock()
+		r.dataKeys[*rotation.NewKeyID] = newDataKey
+		r.dataKeysMu.Unlock()
+	}
+	setKeyRotationProgress(rotation)
+	return rotation, nil
+}
+
+// startKeyRotation replaces the old key of a rotation with a new one and counts
+// the properties to re-encrypt, returning the new data key
+func (r *Repository) startKeyRotation(ctx context.Context, tx *sql.Tx, rotation *models.KeyRotation, now time.Time) ([]byte, error) {
+	dataKey, wrapped, err := r.keyring.GenerateDataKey(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := tx.ExecContext(ctx, `UPDATE encryption_keys SET retired_at = $1 WHERE id = $2 AND retired_at IS NULL`, now, rotation.OldKeyID)
+	if err != nil {
+		return nil, err
+	}
+	if affected, err := result.RowsAffected(); err != nil || affected == 0 {
+		if err == nil {
+			err = ErrEncryptionKeyRetired
+		}
+		return nil, err
+	}
+
+	var newKeyID int64
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO encryption_keys (node_id, wrapped_key, created_at)
+		VALUES ($1, $2, $3)
+		RETURNING id`, rotation.NodeID, wrapped, now).Scan(&newKeyID)
+	if err != nil {
+		return nil, err
+	}
+
+	var total int
+	err = tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM config_properties WHERE encryption_key_id = $1`, rotation.OldKeyID).Scan(&total)
+	if err != nil {
+		return nil, err
+	}
+
+	rotation.Status = models.KeyRotationStatusRunning
+	rotation.NewKeyID = &newKeyID
+	rotation.TotalProperties = total
+	rotation.StartedAt = &now
+	return dataKey, nil
+}
+
+// rotateProperties re-encrypts up to batchSize properties still encrypted with
+// the old key of a rotation, completing it once none is left. Values are
+// rewritten without touching updated_at, as they did not change.
+func (r *Repository) rotateProperties(ctx context.Context, tx *sql.Tx, rotation *models.KeyRotation, batchSize int, now time.Time) error {
+	oldKey, err := r.dataKey(ctx, rotation.OldKeyID)
+	if err != nil {
+		return err
+	}
+	newKey, err := r.dataKey(ctx, *rotation.NewKeyID)
+	if err != nil {
+		return err
+	}
+
+	rows, err := tx.QueryContext(ctx, `
+		SELECT id, key, value, default_value FROM config_properties
+		WHERE encryption_key_id = $1
+		ORDER BY id
+		LIMIT $2
+		FOR UPDATE`, rotation.OldKeyID, batchSize)
+	if err != nil {
+		return err
+	}
+
+	type encryptedValues struct {
+		id           int64
+		key          string
+		value        string
+		defaultValue *string
+	}
+	var batch []encryptedValues
+	for rows.Next() {
+		var values encryptedValues
+		if err := rows.Scan(&values.id, &values.key, &values.value, &values.defaultValue); err != nil {
+			rows.Close()
+			return err
+		}
+		batch = append(batch, values)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, values := range batch {
+		value, err := encryption.Decrypt(oldKey, values.value)
+		if err != nil {
+			return fmt.Errorf("failed to decrypt property %s (%d): %w", values.key, values.id, err)
+		}
+		if value, err = encryption.Encrypt(newKey, value); err != nil {
+			return err
+		}
+
+		defaultValue := values.defaultValue
+		if defaultValue != nil && encryption.IsEncrypted(*defaultValue) {
+			plaintext, err := encryption.Decrypt(oldKey, *defaultValue)
+			if err != nil {
+				return fmt.Errorf("failed to decrypt property %s (%d): %w", values.key, values.id, err)
+			}
+			if defaultValue, err = encryptOptional(newKey, &plaintext); err != nil {
+				return err
+			}
+		}
+
+		_, err = tx.ExecContext(ctx, `UPDATE config_properties SET value = $1, default_value = $2, encryption_key_id = $3 WHERE id = $4`,
+			value, defaultValue, *rotation.NewKeyID, values.id)
+		if err != nil {
+			return err
+		}
+	}
+
+	rotation.RotatedProperties += len(batch)
+	if len(batch) < batchSize {
+		rotation.Status = models.KeyRotationStatusCompleted
+		rotation.CompletedAt = &now
+	}
+	return nil
+}
@@ -0,0 +1,44 @@
+package database
+
+import (
+	"config-manager/internal/models"
+	"context"
+	"encoding/json"
+)
+
+// FindNodesByLabels returns the nodes anywhere in the tree carrying every label
+// in match with the same value, and every label in keys with any value
+func (r *Repository) FindNodesByLabels(ctx context.Context, match map[string]string, keys []string) ([]models.ConfigNode, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	labels, err := encodeLabels(match)
+	if err != nil {
+		return nil, err
+	}
+	if keys == nil {
+		keys = []string{}
+	}
+
+	query := `
+		SELECT ` + nodeColumns + `
+		FROM config_nodes
+		WHERE labels @> $1::jsonb AND labels ?& $2::text[]
+		ORDER BY id`
+
+	rows, err := r.db.QueryContext(ctx, query, labels, keys)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanNodes(rows)
+}
+
+// encodeLabels returns the labels as a JSON object, empty when there are none
+func encodeLabels(labels map[string]string) ([]byte, error) {
+	if labels == nil {
+		labels = map[string]string{}
+	}
+	return json.Marshal(labels)
+}
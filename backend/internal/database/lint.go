@@ -0,0 +1,33 @@
+package database
+
+import (
+	"config-manager/internal/models"
+	"context"
+)
+
+// GetAllProperties returns every property of the tree, grouped by node
+func (r *Repository) GetAllProperties(ctx context.Context) ([]models.ConfigProperty, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	query := `
+		SELECT ` + propertyColumns + `
+		FROM config_properties
+		ORDER BY node_id, key, environment NULLS FIRST`
+
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	properties := []models.ConfigProperty{}
+	for rows.Next() {
+		prop, err := r.scanProperty(ctx, rows)
+		if err != nil {
+			return nil, err
+		}
+		properties = append(properties, *prop)
+	}
+	return properties, rows.Err()
+}
@@ -0,0 +1,1049 @@
+package database
+
+import (
+	"config-manager/internal/encryption"
+	"config-manager/internal/models"
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrNotSupported is returned by the repositories that only keep the tree and
+// its properties for the features that need the full Postgres schema
+var ErrNotSupported = errors.New("not supported by this storage driver")
+
+// MemoryRepository is a ConfigRepository keeping nodes and properties in
+// memory, for handler tests and for running the server without a database. It
+// covers the tree, properties, resolution and batches. Features built on
+// Postgres, such as drafts, workspaces, templates, history and the key
+// registry, return ErrNotSupported, and the checks they add to the core API
+// find nothing: no subtree is protected and no key is registered or
+// deprecated. Properties cannot be encrypted.
+type MemoryRepository struct {
+	unsupportedFeatures
+
+	mu             sync.RWMutex
+	nodes          map[int64]models.ConfigNode
+	properties     map[int64]models.ConfigProperty
+	nextNodeID     int64
+	nextPropertyID int64
+	accesses       map[int64]time.Time
+	idempotency    map[idempotencySlot]*memoryIdempotencyKey
+}
+
+type idempotencySlot struct {
+	principal, key string
+}
+
+type memoryIdempotencyKey struct {
+	record    IdempotencyRecord
+	createdAt time.Time
+}
+
+// NewMemoryRepository creates an empty in-memory repository
+func NewMemoryRepository() *MemoryRepository {
+	return &MemoryRepository{
+		nodes:       make(map[int64]models.ConfigNode),
+		properties:  make(map[int64]models.ConfigProperty),
+		accesses:    make(map[int64]time.Time),
+		idempotency: make(map[idempotencySlot]*memoryIdempotencyKey),
+	}
+}
+
+// Ping always succeeds, there is nothing to reach
+func (m *MemoryRepository) Ping(ctx context.Context) error {
+	return nil
+}
+
+// Node operations
+
+// copyNode returns a copy of a stored node that callers may modify
+func copyNode(node models.ConfigNode) models.ConfigNode {
+	labels := make(map[string]string, len(node.Labels))
+	for key, value := range node.Labels {
+		labels[key] = value
+	}
+	metadata := make(map[string]interface{}, len(node.Metadata))
+	for key, value := range node.Metadata {
+		metadata[key] = value
+	}
+	node.Labels, node.Metadata, node.NodeCounts = labels, metadata, nil
+	return node
+}
+
+// withCounts returns a copy of a node with its child and property counts, as
+// listed by Repository
+func (m *MemoryRepository) withCounts(node models.ConfigNode) models.ConfigNode {
+	counts := &models.NodeCounts{ChildCount: len(m.children(&node.ID))}
+	counts.HasChildren = counts.ChildCount > 0
+	for _, prop := range m.properties {
+		if prop.NodeID == node.ID {
+			counts.PropertyCount++
+		}
+	}
+	node = copyNode(node)
+	node.NodeCounts = counts
+	return node
+}
+
+func sameParent(a, b *int64) bool {
+	return (a == nil && b == nil) || (a != nil && b != nil && *a == *b)
+}
+
+// children returns the stored children of parentID, or the root nodes when it
+// is nil, in order
+func (m *MemoryRepository) children(parentID *int64) []models.ConfigNode {
+	var children []models.ConfigNode
+	for _, node := range m.nodes {
+		if sameParent(node.ParentID, parentID) {
+			children = append(children, node)
+		}
+	}
+	sort.Slice(children, func(i, j int) bool {
+		if children[i].SortIndex != children[j].SortIndex {
+			return children[i].SortIndex < children[j].SortIndex
+		}
+		return children[i].ID < children[j].ID
+	})
+	return children
+}
+
+// descendants returns the IDs of the nodes below nodeID
+func (m *MemoryRepository) descendants(nodeID int64) []int64 {
+	var ids []int64
+	for queue := []int64{nodeID}; len(queue) > 0; queue = queue[1:] {
+		for _, child := range m.children(&queue[0]) {
+			ids = append(ids, child.ID)
+			queue = append(queue, child.ID)
+		}
+	}
+	return ids
+}
+
+// path returns the nodes from the root down to nodeID, empty if it does not exist
+func (m *MemoryRepository) path(nodeID int64) []models.ConfigNode {
+	var path []models.ConfigNode
+	for id := &nodeID; id != nil; {
+		node, ok := m.nodes[*id]
+		if !ok {
+			break
+		}
+		path = append([]models.ConfigNode{copyNode(node)}, path...)
+		id = node.ParentID
+	}
+	return path
+}
+
+// nameTaken reports whether a sibling under parentID other than excludeID has
+// name in any case
+func (m *MemoryRepository) nameTaken(parentID *int64, name string, excludeID int64) bool {
+	for _, node := range m.nodes {
+		if node.ID != excludeID && sameParent(node.ParentID, parentID) && strings.EqualFold(node.Name, name) {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *MemoryRepository) CreateNode(ctx context.Context, req models.CreateNodeRequest) (*models.ConfigNode, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.createNode(req)
+}
+
+func (m *MemoryRepository) createNode(req models.CreateNodeRequest) (*models.ConfigNode, error) {
+	if req.ParentID != nil {
+		if _, ok := m.nodes[*req.ParentID]; !ok {
+			return nil, ErrNodeNotFound
+		}
+	}
+	if m.nameTaken(req.ParentID, req.Name, 0) {
+		return nil, ErrNodeNameTaken
+	}
+
+	sortIndex := 0
+	for _, sibling := range m.children(req.ParentID) {
+		if sibling.SortIndex >= sortIndex {
+			sortIndex = sibling.SortIndex + 1
+		}
+	}
+
+	m.nextNodeID++
+	now := time.Now()
+	node := copyNode(models.ConfigNode{
+		ID:          m.nextNodeID,
+		Name:        req.Name,
+		NodeType:    req.NodeType,
+		ParentID:    req.ParentID,
+		Description: req.Description,
+		Labels:      req.Labels,
+		Metadata:    req.Metadata,
+		SortIndex:   sortIndex,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	})
+	m.nodes[node.ID] = node
+
+	created := copyNode(node)
+	return &created, nil
+}
+
+func (m *MemoryRepository) GetNodeByID(ctx context.Context, id int64) (*models.ConfigNode, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	node, ok := m.nodes[id]
+	if !ok {
+		return nil, nil
+	}
+	node = copyNode(node)
+	return &node, nil
+}
+
+func (m *MemoryRepository) GetRootNodes(ctx context.Context) ([]models.ConfigNode, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.listWithCounts(m.children(nil)), nil
+}
+
+func (m *MemoryRepository) GetChildNodes(ctx context.Context, parentID int64) ([]models.ConfigNode, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.listWithCounts(m.children(&parentID)), nil
+}
+
+// GetChildNodesPage returns up to limit children of parentID in order, after the child after
+func (m *MemoryRepository) GetChildNodesPage(ctx context.Context, parentID int64, after *int64, limit int) ([]models.ConfigNode, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	children := m.children(&parentID)
+	if after != nil {
+		start := -1
+		for i, child := range children {
+			if child.ID == *after {
+				start = i + 1
+			}
+		}
+		if start < 0 {
+			return nil, ErrInvalidCursor
+		}
+		children = children[start:]
+	}
+	if len(children) > limit {
+		children = children[:limit]
+	}
+	return m.listWithCounts(children), nil
+}
+
+// GetNodeWithChildren returns the node with its children, or nil if it does not exist
+func (m *MemoryRepository) GetNodeWithChildren(ctx context.Context, id int64) (*models.ConfigNodeWithChildren, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	node, ok := m.nodes[id]
+	if !ok {
+		return nil, nil
+	}
+	return &models.ConfigNodeWithChildren{ConfigNode: copyNode(node), Children: m.listWithCounts(m.children(&id))}, nil
+}
+
+func (m *MemoryRepository) listWithCounts(nodes []models.ConfigNode) []models.ConfigNode {
+	listed := make([]models.ConfigNode, 0, len(nodes))
+	for _, node := range nodes {
+		listed = append(listed, m.withCounts(node))
+	}
+	return listed
+}
+
+// GetAllNodes returns every node of the tree, parents before their children
+func (m *MemoryRepository) GetAllNodes(ctx context.Context) ([]models.ConfigNode, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	depths := make(map[int64]int, len(m.nodes))
+	nodes := make([]models.ConfigNode, 0, len(m.nodes))
+	for level, depth := m.children(nil), 0; len(level) > 0; depth++ {
+		var next []models.ConfigNode
+		for _, node := range level {
+			depths[node.ID] = depth
+			nodes = append(nodes, copyNode(node))
+			next = append(next, m.children(&node.ID)...)
+		}
+		level = next
+	}
+
+	sort.SliceStable(nodes, func(i, j int) bool {
+		a, b := nodes[i], nodes[j]
+		if depths[a.ID] != depths[b.ID] {
+			return depths[a.ID] < depths[b.ID]
+		}
+		if a.SortIndex != b.SortIndex {
+			return a.SortIndex < b.SortIndex
+		}
+		return a.ID < b.ID
+	})
+	return nodes, nil
+}
+
+func (m *MemoryRepository) UpdateNode(ctx context.Context, id int64, req models.UpdateNodeRequest) (*models.ConfigNode, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.updateNode(id, req)
+}
+
+func (m *MemoryRepository) updateNode(id int64, req models.UpdateNodeRequest) (*models.ConfigNode, error) {
+	node, ok := m.nodes[id]
+	if !ok {
+		return nil, nil
+	}
+	if req.Name != nil && m.nameTaken(node.ParentID, *req.Name, id) {
+		return nil, ErrNodeNameTaken
+	}
+
+	if req.Name != nil {
+		node.Name = *req.Name
+	}
+	if req.Description != nil {
+		node.Description = *req.Description
+	}
+	if req.Labels != nil {
+		node.Labels = req.Labels
+	}
+	if req.Metadata != nil {
+		node.Metadata = req.Metadata
+	}
+	node.UpdatedAt = time.Now()
+	node = copyNode(node)
+	m.nodes[id] = node
+
+	updated := copyNode(node)
+	return &updated, nil
+}
+
+// DeleteNode deletes a node with its subtree and their properties
+func (m *MemoryRepository) DeleteNode(ctx context.Context, id int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.deleteNode(id)
+}
+
+func (m *MemoryRepository) deleteNode(id int64) error {
+	if _, ok := m.nodes[id]; !ok {
+		return ErrNodeNotFound
+	}
+
+	deleted := map[int64]bool{id: true}
+	for _, descendant := range m.descendants(id) {
+		deleted[descendant] = true
+	}
+	for nodeID := range deleted {
+		delete(m.nodes, nodeID)
+		delete(m.accesses, nodeID)
+	}
+	for propID, prop := range m.properties {
+		if deleted[prop.NodeID] {
+			delete(m.properties, propID)
+		}
+	}
+	return nil
+}
+
+func (m *MemoryRepository) GetNodePath(ctx context.Context, nodeID int64) ([]models.ConfigNode, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.path(nodeID), nil
+}
+
+// SiblingNameTaken reports whether a child of parentID, or a root node when
+// parentID is nil, other than excludeID has name in any case
+func (m *MemoryRepository) SiblingNameTaken(ctx context.Context, parentID *int64, name string, excludeID int64) (bool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.nameTaken(parentID, name, excludeID), nil
+}
+
+// ReorderChildren sets the order of the children of parentID, or of the root
+// nodes when parentID is nil, to that of ids, which must list each of them once
+func (m *MemoryRepository) ReorderChildren(ctx context.Context, parentID *int64, ids []int64) ([]models.ConfigNode, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	children := make(map[int64]bool)
+	for _, child := range m.children(parentID) {
+		children[child.ID] = true
+	}
+
+	if len(ids) != len(children) {
+		return nil, fmt.Errorf("%w: got %d IDs for %d children", ErrInvalidOrder, len(ids), len(children))
+	}
+	listed := make(map[int64]bool, len(ids))
+	for _, id := range ids {
+		if !children[id] {
+			return nil, fmt.Errorf("%w: node %d is not a child", ErrInvalidOrder, id)
+		}
+		if listed[id] {
+			return nil, fmt.Errorf("%w: node %d is listed twice", ErrInvalidOrder, id)
+		}
+		listed[id] = true
+	}
+
+	for position, id := range ids {
+		node := m.nodes[id]
+		node.SortIndex = position
+		m.nodes[id] = node
+	}
+
+	ordered := m.children(parentID)
+	for i := range ordered {
+		ordered[i] = copyNode(ordered[i])
+	}
+	return ordered, nil
+}
+
+// FindNodesByName returns the children of parentID, or the root nodes when it
+// is nil, with the given name
+func (m *MemoryRepository) FindNodesByName(ctx context.Context, parentID *int64, name string) ([]models.ConfigNode, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.findNodes(func(node models.ConfigNode) bool {
+		return sameParent(node.ParentID, parentID) && node.Name == name
+	}), nil
+}
+
+// FindNodesByLabels returns the nodes anywhere in the tree carrying every label
+// in match with the same value, and every label in keys with any value
+func (m *MemoryRepository) FindNodesByLabels(ctx context.Context, match map[string]string, keys []string) ([]models.ConfigNode, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.findNodes(func(node models.ConfigNode) bool {
+		for key, value := range match {
+			if own, ok := node.Labels[key]; !ok || own != value {
+				return false
+			}
+		}
+		for _, key := range keys {
+			if _, ok := node.Labels[key]; !ok {
+				return false
+			}
+		}
+		return true
+	}), nil
+}
+
+// findNodes returns copies of the nodes matching keep, ordered by ID
+func (m *MemoryRepository) findNodes(keep func(models.ConfigNode) bool) []models.ConfigNode {
+	var found []models.ConfigNode
+	for _, node := range m.nodes {
+		if keep(node) {
+			found = append(found, copyNode(node))
+		}
+	}
+	sort.Slice(found, func(i, j int) bool { return found[i].ID < found[j].ID })
+	return found
+}
+
+// GetTree returns the subtree of rootID, or the whole tree when rootID is nil,
+// as nested nodes down to maxDepth levels below the roots, or all levels when
+// maxDepth is negative
+func (m *MemoryRepository) GetTree(ctx context.Context, rootID *int64, maxDepth int) ([]models.ConfigTreeNode, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	roots := m.children(nil)
+	if rootID != nil {
+		roots = nil
+		if root, ok := m.nodes[*rootID]; ok {
+			roots = append(roots, root)
+		}
+	}
+
+	var build func(node models.ConfigNode, depth int) models.ConfigTreeNode
+	build = func(node models.ConfigNode, depth int) models.ConfigTreeNode {
+		tree := models.ConfigTreeNode{ConfigNode: m.withCounts(node)}
+		if maxDepth >= 0 && depth >= maxDepth {
+			return tree
+		}
+		children := m.children(&node.ID)
+		tree.Children = make([]models.ConfigTreeNode, 0, len(children))
+		for _, child := range children {
+			tree.Children = append(tree.Children, build(child, depth+1))
+		}
+		return tree
+	}
+
+	trees := make([]models.ConfigTreeNode, 0, len(roots))
+	for _, root := range roots {
+		trees = append(trees, build(root, 0))
+	}
+	return trees, nil
+}
+
+// Property operations
+
+// copyProperty returns a copy of a stored property that callers may modify
+func copyProperty(prop models.ConfigProperty) models.ConfigProperty {
+	prop.Tags = append([]string{}, prop.Tags...)
+	return prop
+}
+
+// nodeProperties returns the properties of a node ordered by key, those for
+// all environments before the overrides
+func (m *MemoryRepository) nodeProperties(nodeID int64) []models.ConfigProperty {
+	var properties []models.ConfigProperty
+	for _, prop := range m.properties {
+		if prop.NodeID == nodeID {
+			properties = append(properties, copyProperty(prop))
+		}
+	}
+	sortProperties(properties)
+	return properties
+}
+
+func sortProperties(properties []models.ConfigProperty) {
+	sort.Slice(properties, func(i, j int) bool {
+		a, b := properties[i], properties[j]
+		if a.NodeID != b.NodeID {
+			return a.NodeID < b.NodeID
+		}
+		if a.Key != b.Key {
+			return a.Key < b.Key
+		}
+		if a.Environment == nil || b.Environment == nil {
+			return a.Environment == nil && b.Environment != nil
+		}
+		return *a.Environment < *b.Environment
+	})
+}
+
+// definition returns the property defining key on nodeID for environment, or
+// for all environments when environment is nil
+func (m *MemoryRepository) definition(nodeID int64, key string, environment *string) (models.ConfigProperty, bool) {
+	for _, prop := range m.properties {
+		if prop.NodeID == nodeID && prop.Key == key && sameEnvironment(prop.Environment, environment) {
+			return prop, true
+		}
+	}
+	return models.ConfigProperty{}, false
+}
+
+func sameEnvironment(a, b *string) bool {
+	return (a == nil && b == nil) || (a != nil && b != nil && *a == *b)
+}
+
+// appliesTo reports whether a property applies to environment, that is it is
+// defined for all environments or for environment itself
+func appliesTo(prop models.ConfigProperty, environment *string) bool {
+	return prop.Environment == nil || (environment != nil && *prop.Environment == *environment)
+}
+
+// CreateProperty creates a property, or replaces the one defining the same
+// key for the same environment on the node
+func (m *MemoryRepository) CreateProperty(ctx context.Context, nodeID int64, req models.CreatePropertyRequest) (*models.ConfigProperty, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.createProperty(nodeID, req)
+}
+
+func (m *MemoryRepository) createProperty(nodeID int64, req models.CreatePropertyRequest) (*models.ConfigProperty, error) {
+	if _, ok := m.nodes[nodeID]; !ok {
+		return nil, ErrNodeNotFound
+	}
+	if req.Encrypted {
+		return nil, encryption.ErrNotConfigured
+	}
+
+	tags := NormalizeTags(req.Tags)
+	if tags == nil {
+		tags = []string{}
+	}
+	now := time.Now()
+	prop := models.ConfigProperty{
+		NodeID:            nodeID,
+		Key:               req.Key,
+		Environment:       req.Environment,
+		Value:             req.Value,
+		DataType:          req.DataType,
+		DefaultValue:      req.DefaultValue,
+		Description:       req.Description,
+		RolloutPercentage: req.RolloutPercentage,
+		RolloutKey:        req.RolloutKey,
+		IsFinal:           req.IsFinal,
+		Namespace:         req.Namespace,
+		Tags:              tags,
+		ExpiresAt:         req.ExpiresAt,
+		CreatedAt:         now,
+		UpdatedAt:         now,
+	}
+	if existing, ok := m.definition(nodeID, req.Key, req.Environment); ok {
+		prop.ID, prop.CreatedAt = existing.ID, existing.CreatedAt
+	} else {
+		m.nextPropertyID++
+		prop.ID = m.nextPropertyID
+	}
+	m.properties[prop.ID] = prop
+
+	created := copyProperty(prop)
+	return &created, nil
+}
+
+func (m *MemoryRepository) GetPropertiesByNodeID(ctx context.Context, nodeID int64) ([]models.ConfigProperty, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.nodeProperties(nodeID), nil
+}
+
+// GetNodeWithProperties returns the node with its properties, or nil if it does not exist
+func (m *MemoryRepository) GetNodeWithProperties(ctx context.Context, id int64) (*models.ConfigNodeWithProperties, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	node, ok := m.nodes[id]
+	if !ok {
+		return nil, nil
+	}
+	return &models.ConfigNodeWithProperties{ConfigNode: copyNode(node), Properties: m.nodeProperties(id)}, nil
+}
+
+// GetAllProperties returns every property of the tree, grouped by node
+func (m *MemoryRepository) GetAllProperties(ctx context.Context) ([]models.ConfigProperty, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	properties := make([]models.ConfigProperty, 0, len(m.properties))
+	for _, prop := range m.properties {
+		properties = append(properties, copyProperty(prop))
+	}
+	sortProperties(properties)
+	return properties, nil
+}
+
+// GetProperty returns a property, or nil if it does not exist
+func (m *MemoryRepository) GetProperty(ctx context.Context, id int64) (*models.ConfigProperty, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	prop, ok := m.properties[id]
+	if !ok {
+		return nil, nil
+	}
+	prop = copyProperty(prop)
+	return &prop, nil
+}
+
+// GetPropertyNodeID returns the node of a property, or nil if it does not exist
+func (m *MemoryRepository) GetPropertyNodeID(ctx context.Context, propertyID int64) (*int64, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	prop, ok := m.properties[propertyID]
+	if !ok {
+		return nil, nil
+	}
+	return &prop.NodeID, nil
+}
+
+func (m *MemoryRepository) UpdateProperty(ctx context.Context, id int64, req models.UpdatePropertyRequest) (*models.ConfigProperty, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.updateProperty(id, req)
+}
+
+func (m *MemoryRepository) updateProperty(id int64, req models.UpdatePropertyRequest) (*models.ConfigProperty, error) {
+	prop, ok := m.properties[id]
+	if !ok {
+		return nil, nil
+	}
+
+	if req.Value != nil {
+		prop.Value = *req.Value
+	}
+	if req.DataType != nil {
+		prop.DataType = *req.DataType
+	}
+	if req.DefaultValue != nil {
+		prop.DefaultValue = req.DefaultValue
+	}
+	if req.Description != nil {
+		prop.Description = *req.Description
+	}
+	if req.RolloutPercentage != nil {
+		prop.RolloutPercentage = req.RolloutPercentage
+	}
+	if req.RolloutKey != nil {
+		prop.RolloutKey = req.RolloutKey
+	}
+	if req.IsFinal != nil {
+		prop.IsFinal = *req.IsFinal
+	}
+	if req.Namespace != nil {
+		prop.Namespace = req.Namespace
+		if *req.Namespace == "" {
+			prop.Namespace = nil
+		}
+	}
+	if req.Tags != nil {
+		prop.Tags = NormalizeTags(req.Tags)
+	}
+	if req.ClearExpiry {
+		prop.ExpiresAt = nil
+	} else if req.ExpiresAt != nil {
+		prop.ExpiresAt = req.ExpiresAt
+	}
+	prop.UpdatedAt = time.Now()
+	m.properties[id] = prop
+
+	updated := copyProperty(prop)
+	return &updated, nil
+}
+
+func (m *MemoryRepository) DeleteProperty(ctx context.Context, id int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.deleteProperty(id)
+}
+
+func (m *MemoryRepository) deleteProperty(id int64) error {
+	if _, ok := m.properties[id]; !ok {
+		return ErrPropertyNotFound
+	}
+	delete(m.properties, id)
+	return nil
+}
+
+// Configuration resolution
+
+// ResolveConfiguration merges the properties along the path from the root to
+// nodeID like Repository. Past states are not kept, so opts.AsOf is not
+// supported, and there are no drafts to include.
+func (m *MemoryRepository) ResolveConfiguration(ctx context.Context, nodeID int64, opts models.ResolveOptions) (*models.ResolvedConfiguration, error) {
+	if opts.AsOf != nil {
+		return nil, ErrNotSupported
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	path := m.path(nodeID)
+	if len(path) == 0 {
+		return nil, ErrNodeNotFound
+	}
+	return resolvePath(path, nil, opts, func(nodeID int64) ([]models.ConfigProperty, error) {
+		return m.nodeProperties(nodeID), nil
+	})
+}
+
+// GetEffectiveProperties returns the properties in effect on nodeID after
+// inheritance, keyed by property key, or nil if the node does not exist
+func (m *MemoryRepository) GetEffectiveProperties(ctx context.Context, nodeID int64, environment string) (map[string]models.ConfigProperty, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	path := m.path(nodeID)
+	if len(path) == 0 {
+		return nil, nil
+	}
+
+	effective := make(map[string]models.ConfigProperty)
+	locked := make(map[string]bool)
+	now := time.Now()
+	for _, node := range path {
+		for _, prop := range unlocked(unexpired(forEnvironment(m.nodeProperties(node.ID), environment), now), locked) {
+			effective[prop.Key] = prop
+		}
+	}
+	return effective, nil
+}
+
+// GetInheritedProperty returns the property with the given key defined on the
+// nearest ancestor of nodeID (excluding the node itself) for all environments or
+// for environment, or nil if there is none
+func (m *MemoryRepository) GetInheritedProperty(ctx context.Context, nodeID int64, key string, environment *string) (*models.ConfigProperty, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	path := m.path(nodeID)
+	for i := len(path) - 2; i >= 0; i-- {
+		var inherited *models.ConfigProperty
+		for _, prop := range m.nodeProperties(path[i].ID) {
+			if prop.Key != key || !appliesTo(prop, environment) {
+				continue
+			}
+			// Overrides for the environment win over values for all of them
+			if inherited == nil || prop.Environment != nil {
+				prop := prop
+				inherited = &prop
+			}
+		}
+		if inherited != nil {
+			return inherited, nil
+		}
+	}
+	return nil, nil
+}
+
+// GetLockingProperty returns the final property that locks key for nodeID,
+// defined on its highest ancestor that locks it, or nil if key is not locked
+func (m *MemoryRepository) GetLockingProperty(ctx context.Context, nodeID int64, key string) (*models.ConfigProperty, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.lockingProperty(nodeID, key), nil
+}
+
+func (m *MemoryRepository) lockingProperty(nodeID int64, key string) *models.ConfigProperty {
+	path := m.path(nodeID)
+	for i := 0; i < len(path)-1; i++ {
+		for _, prop := range m.nodeProperties(path[i].ID) {
+			if prop.Key == key && prop.IsFinal {
+				return &prop
+			}
+		}
+	}
+	return nil
+}
+
+// CountDescendantDefinitions returns how many properties with key are defined
+// below nodeID, in any environment
+func (m *MemoryRepository) CountDescendantDefinitions(ctx context.Context, nodeID int64, key string) (int, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	below := make(map[int64]bool)
+	for _, id := range m.descendants(nodeID) {
+		below[id] = true
+	}
+
+	count := 0
+	for _, prop := range m.properties {
+		if below[prop.NodeID] && prop.Key == key {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// CountInheritingNodes returns how many descendants of nodeID resolve key from
+// nodeID for environment, or for all environments when environment is nil,
+// because neither they nor a node between them define it
+func (m *MemoryRepository) CountInheritingNodes(ctx context.Context, nodeID int64, key string, environment *string) (int, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	defines := func(nodeID int64) bool {
+		for _, prop := range m.properties {
+			if prop.NodeID == nodeID && prop.Key == key && appliesTo(prop, environment) {
+				return true
+			}
+		}
+		return false
+	}
+
+	count := 0
+	for queue := []int64{nodeID}; len(queue) > 0; queue = queue[1:] {
+		for _, child := range m.children(&queue[0]) {
+			if !defines(child.ID) {
+				count++
+				queue = append(queue, child.ID)
+			}
+		}
+	}
+	return count, nil
+}
+
+// CheckEncryptionKey always fails, as properties cannot be encrypted in memory
+func (m *MemoryRepository) CheckEncryptionKey(ctx context.Context, nodeID int64) error {
+	return encryption.ErrNotConfigured
+}
+
+// Batches
+
+// ApplyBatch applies the operations of a batch in order like Repository,
+// restoring the previous state when one of them fails
+func (m *MemoryRepository) ApplyBatch(ctx context.Context, ops []models.BatchOperation) ([]models.BatchResult, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	nodes := make(map[int64]models.ConfigNode, len(m.nodes))
+	for id, node := range m.nodes {
+		nodes[id] = node
+	}
+	properties := make(map[int64]models.ConfigProperty, len(m.properties))
+	for id, prop := range m.properties {
+		properties[id] = prop
+	}
+	nextNodeID, nextPropertyID := m.nextNodeID, m.nextPropertyID
+
+	results, err := m.applyBatch(ops)
+	if err != nil {
+		m.nodes, m.properties = nodes, properties
+		m.nextNodeID, m.nextPropertyID = nextNodeID, nextPropertyID
+		return nil, err
+	}
+	return results, nil
+}
+
+func (m *MemoryRepository) applyBatch(ops []models.BatchOperation) ([]models.BatchResult, error) {
+	created := make([]int64, len(ops))
+	liveID := func(id *int64) *int64 {
+		if id == nil || *id >= 0 {
+			return id
+		}
+		mapped := int64(0)
+		if index := int(-*id) - 1; index < len(created) {
+			mapped = created[index]
+		}
+		return &mapped
+	}
+
+	results := make([]models.BatchResult, len(ops))
+	for i, op := range ops {
+		result := models.BatchResult{Op: op.Op, NodeID: liveID(op.NodeID), PropertyID: op.PropertyID}
+
+		var err error
+		switch op.Op {
+		case models.BatchOpCreateNode:
+			req := *op.CreateNode
+			req.ParentID = liveID(req.ParentID)
+			result.Node, err = m.createNode(req)
+			if err == nil {
+				created[i] = result.Node.ID
+				result.NodeID = &result.Node.ID
+			}
+
+		case models.BatchOpUpdateNode:
+			result.Node, err = m.updateNode(*result.NodeID, *op.UpdateNode)
+			if err == nil && result.Node == nil {
+				err = ErrNodeNotFound
+			}
+
+		case models.BatchOpDeleteNode:
+			err = m.deleteNode(*result.NodeID)
+
+		case models.BatchOpCreateProperty:
+			if m.lockingProperty(*result.NodeID, op.CreateProperty.Key) != nil {
+				err = ErrKeyFinal
+				break
+			}
+			result.Property, err = m.createProperty(*result.NodeID, *op.CreateProperty)
+			if err == nil {
+				result.PropertyID = &result.Property.ID
+			}
+
+		case models.BatchOpUpdateProperty:
+			result.Property, err = m.updateProperty(*op.PropertyID, *op.UpdateProperty)
+			if err == nil && result.Property == nil {
+				err = ErrPropertyNotFound
+			}
+			if err == nil {
+				result.NodeID = &result.Property.NodeID
+			}
+
+		case models.BatchOpDeleteProperty:
+			err = m.deleteProperty(*op.PropertyID)
+
+		default:
+			err = fmt.Errorf("unknown op %q", op.Op)
+		}
+		if err != nil {
+			return nil, &BatchError{Index: i, Err: err}
+		}
+		results[i] = result
+	}
+	return results, nil
+}
+
+// Delete guard
+
+// RecordNodeAccess notes that a consumer read the resolved configuration of a node
+func (m *MemoryRepository) RecordNodeAccess(ctx context.Context, nodeID int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.accesses[nodeID] = time.Now()
+	return nil
+}
+
+// LastSubtreeAccess returns the most recent time a consumer read the node or any
+// of its descendants, or nil if none of them was read
+func (m *MemoryRepository) LastSubtreeAccess(ctx context.Context, nodeID int64) (*time.Time, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var last *time.Time
+	for _, id := range append([]int64{nodeID}, m.descendants(nodeID)...) {
+		if at, ok := m.accesses[id]; ok && (last == nil || at.After(*last)) {
+			at := at
+			last = &at
+		}
+	}
+	return last, nil
+}
+
+// Idempotency keys
+
+// ReserveIdempotencyKey claims an idempotency key of a principal for a request
+// like Repository. It returns nil once the key is claimed, or the record of the
+// request that claimed it before.
+func (m *MemoryRepository) ReserveIdempotencyKey(ctx context.Context, principal, key, requestHash string, expiredBefore, abandonedBefore time.Time) (*IdempotencyRecord, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for slot, stored := range m.idempotency {
+		if stored.createdAt.Before(expiredBefore) || (stored.record.StatusCode == 0 && stored.createdAt.Before(abandonedBefore)) {
+			delete(m.idempotency, slot)
+		}
+	}
+
+	slot := idempotencySlot{principal: principal, key: key}
+	if stored, ok := m.idempotency[slot]; ok {
+		record := stored.record
+		return &record, nil
+	}
+	m.idempotency[slot] = &memoryIdempotencyKey{record: IdempotencyRecord{RequestHash: requestHash}, createdAt: time.Now()}
+	return nil, nil
+}
+
+// CompleteIdempotencyKey records the response to the request that claimed an
+// idempotency key, to be replayed on retries
+func (m *MemoryRepository) CompleteIdempotencyKey(ctx context.Context, principal, key string, status int, contentType string, body []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if stored, ok := m.idempotency[idempotencySlot{principal: principal, key: key}]; ok {
+		stored.record.StatusCode = status
+		stored.record.ContentType = contentType
+		stored.record.Body = append([]byte(nil), body...)
+	}
+	return nil
+}
+
+// ReleaseIdempotencyKey drops an idempotency key whose request failed, so that
+// a retry runs the request again
+func (m *MemoryRepository) ReleaseIdempotencyKey(ctx context.Context, principal, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	slot := idempotencySlot{principal: principal, key: key}
+	if stored, ok := m.idempotency[slot]; ok && stored.record.StatusCode == 0 {
+		delete(m.idempotency, slot)
+	}
+	return nil
+}
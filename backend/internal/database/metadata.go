@@ -0,0 +1,12 @@
+package database
+
+import "encoding/json"
+
+// encodeMetadata returns the metadata of a node as a JSON object, empty when
+// there is none
+func encodeMetadata(metadata map[string]interface{}) ([]byte, error) {
+	if metadata == nil {
+		metadata = map[string]interface{}{}
+	}
+	return json.Marshal(metadata)
+}
@@ -0,0 +1,245 @@
+package database
+
+import (
+	"embed"
+	"fmt"
+	"io/fs"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Migrations are numbered SQL files named <version>_<name>.up.sql and
+// <version>_<name>.down.sql. Applied versions are recorded in schema_migrations.
+// SQLite and MySQL have their own migrations under migrations/sqlite and
+// migrations/mysql, covering the core tables they support.
+//
+//go:embed migrations/*.sql migrations/sqlite/*.sql migrations/mysql/*.sql
+var migrationFiles embed.FS
+
+type migration struct {
+	version int64
+	name    string
+	up      string
+	down    string
+}
+
+// MigrationStatus describes a known migration and whether it has been applied
+type MigrationStatus struct {
+	Version int64
+	Name    string
+	Applied bool
+}
+
+// migrationsDir returns the directory holding the migrations for the driver
+func (db *DB) migrationsDir() string {
+	switch db.driver {
+	case DriverSQLite:
+		return "migrations/sqlite"
+	case DriverMySQL:
+		return "migrations/mysql"
+	}
+	return "migrations"
+}
+
+// loadMigrations parses the embedded migration files in dir ordered by version
+func loadMigrations(dir string) ([]migration, error) {
+	entries, err := fs.ReadDir(migrationFiles, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int64]*migration)
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		fileName := entry.Name()
+
+		var direction string
+		switch {
+		case strings.HasSuffix(fileName, ".up.sql"):
+			direction = "up"
+		case strings.HasSuffix(fileName, ".down.sql"):
+			direction = "down"
+		default:
+			return nil, fmt.Errorf("unexpected migration file %s", fileName)
+		}
+
+		base := strings.TrimSuffix(fileName, "."+direction+".sql")
+		versionStr, name, ok := strings.Cut(base, "_")
+		if !ok {
+			return nil, fmt.Errorf("migration file %s must be named <version>_<name>", fileName)
+		}
+		version, err := strconv.ParseInt(versionStr, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("migration file %s has invalid version: %w", fileName, err)
+		}
+
+		contents, err := migrationFiles.ReadFile(dir + "/" + fileName)
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &migration{version: version, name: name}
+			byVersion[version] = m
+		}
+		if direction == "up" {
+			m.up = string(contents)
+		} else {
+			m.down = string(contents)
+		}
+	}
+
+	migrations := make([]migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.up == "" {
+			return nil, fmt.Errorf("migration %d_%s has no up file", m.version, m.name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].version < migrations[j].version })
+
+	return migrations, nil
+}
+
+func (db *DB) ensureMigrationsTable() error {
+	appliedAt := `TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP`
+	if db.driver == DriverMySQL {
+		appliedAt = `DATETIME(6) DEFAULT CURRENT_TIMESTAMP(6)`
+	}
+	_, err := db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+		version BIGINT PRIMARY KEY,
+		name VARCHAR(255) NOT NULL,
+		applied_at ` + appliedAt + `
+	)`)
+	return err
+}
+
+func (db *DB) appliedVersions() (map[int64]bool, error) {
+	if err := db.ensureMigrationsTable(); err != nil {
+		return nil, fmt.Errorf("failed to create schema_migrations table: %w", err)
+	}
+
+	rows, err := db.Query(`SELECT version FROM schema_migrations`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := make(map[int64]bool)
+	for rows.Next() {
+		var version int64
+		if err := rows.Scan(&version); err != nil {
+			return nil, err
+		}
+		applied[version] = true
+	}
+
+	return applied, rows.Err()
+}
+
+// RunMigrations applies all pending up migrations in version order. Each
+// migration runs in its own transaction together with its version record.
+func (db *DB) RunMigrations() error {
+	migrations, err := loadMigrations(db.migrationsDir())
+	if err != nil {
+		return err
+	}
+
+	applied, err := db.appliedVersions()
+	if err != nil {
+		return err
+	}
+
+	for _, m := range migrations {
+		if applied[m.version] {
+			continue
+		}
+
+		if err := db.applyMigration(m.up, `INSERT INTO schema_migrations (version, name) VALUES ($1, $2)`, m.version, m.name); err != nil {
+			return fmt.Errorf("failed to apply migration %d_%s: %w", m.version, m.name, err)
+		}
+		log.Printf("Applied migration %d_%s", m.version, m.name)
+	}
+
+	log.Println("Database migrations completed successfully")
+	return nil
+}
+
+// RollbackMigrations reverts the given number of most recently applied migrations
+func (db *DB) RollbackMigrations(steps int) error {
+	migrations, err := loadMigrations(db.migrationsDir())
+	if err != nil {
+		return err
+	}
+
+	applied, err := db.appliedVersions()
+	if err != nil {
+		return err
+	}
+
+	for i := len(migrations) - 1; i >= 0 && steps > 0; i-- {
+		m := migrations[i]
+		if !applied[m.version] {
+			continue
+		}
+		if m.down == "" {
+			return fmt.Errorf("migration %d_%s has no down file", m.version, m.name)
+		}
+
+		if err := db.applyMigration(m.down, `DELETE FROM schema_migrations WHERE version = $1`, m.version); err != nil {
+			return fmt.Errorf("failed to roll back migration %d_%s: %w", m.version, m.name, err)
+		}
+		log.Printf("Rolled back migration %d_%s", m.version, m.name)
+		steps--
+	}
+
+	return nil
+}
+
+// MigrationStatuses lists every known migration and whether it has been applied
+func (db *DB) MigrationStatuses() ([]MigrationStatus, error) {
+	migrations, err := loadMigrations(db.migrationsDir())
+	if err != nil {
+		return nil, err
+	}
+
+	applied, err := db.appliedVersions()
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]MigrationStatus, 0, len(migrations))
+	for _, m := range migrations {
+		statuses = append(statuses, MigrationStatus{Version: m.version, Name: m.name, Applied: applied[m.version]})
+	}
+	return statuses, nil
+}
+
+// applyMigration runs a migration script and the bookkeeping statement
+// atomically. MySQL commits schema changes as they run, so a failed migration
+// may leave part of its script applied.
+func (db *DB) applyMigration(script, bookkeeping string, args ...interface{}) error {
+	if db.driver == DriverMySQL {
+		bookkeeping, args = rebindPlaceholders(bookkeeping, args)
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(script); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(bookkeeping, args...); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
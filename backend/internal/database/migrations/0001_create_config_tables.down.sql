@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS config_properties;
+DROP TABLE IF EXISTS config_nodes;
@@ -0,0 +1,3 @@
+ALTER TABLE config_properties DROP COLUMN IF EXISTS encryption_key_id;
+ALTER TABLE config_properties DROP COLUMN IF EXISTS encrypted;
+DROP TABLE IF EXISTS encryption_keys;
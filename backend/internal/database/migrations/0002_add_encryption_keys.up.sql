@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS encryption_keys (
+    id BIGSERIAL PRIMARY KEY,
+    node_id BIGINT NOT NULL UNIQUE REFERENCES config_nodes(id) ON DELETE CASCADE,
+    wrapped_key BYTEA NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+ALTER TABLE config_properties ADD COLUMN IF NOT EXISTS encrypted BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE config_properties ADD COLUMN IF NOT EXISTS encryption_key_id BIGINT REFERENCES encryption_keys(id);
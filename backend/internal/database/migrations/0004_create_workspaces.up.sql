@@ -0,0 +1,23 @@
+CREATE TABLE IF NOT EXISTS workspaces (
+    id BIGSERIAL PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    root_node_id BIGINT NOT NULL REFERENCES config_nodes(id) ON DELETE CASCADE,
+    status VARCHAR(20) NOT NULL DEFAULT 'open' CHECK (status IN ('open', 'merged', 'discarded')),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    merged_at TIMESTAMP WITH TIME ZONE
+);
+
+-- node_id may reference a node created inside the workspace, identified by the
+-- negated ID of the create_node change, so it has no foreign key.
+CREATE TABLE IF NOT EXISTS workspace_changes (
+    id BIGSERIAL PRIMARY KEY,
+    workspace_id BIGINT NOT NULL REFERENCES workspaces(id) ON DELETE CASCADE,
+    op VARCHAR(50) NOT NULL,
+    node_id BIGINT,
+    payload JSONB NOT NULL,
+    base_updated_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_workspace_changes_workspace_id ON workspace_changes(workspace_id);
@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS node_permissions (
+    id BIGSERIAL PRIMARY KEY,
+    node_id BIGINT NOT NULL REFERENCES config_nodes(id) ON DELETE CASCADE,
+    principal VARCHAR(255) NOT NULL,
+    permission VARCHAR(20) NOT NULL CHECK (permission IN ('read', 'write')),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(node_id, principal)
+);
+
+CREATE INDEX IF NOT EXISTS idx_node_permissions_principal ON node_permissions(principal);
@@ -0,0 +1,4 @@
+CREATE TABLE IF NOT EXISTS node_access (
+    node_id BIGINT PRIMARY KEY REFERENCES config_nodes(id) ON DELETE CASCADE,
+    last_accessed_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
@@ -0,0 +1,4 @@
+DELETE FROM config_properties WHERE environment IS NOT NULL;
+DROP INDEX IF EXISTS idx_config_properties_node_key_env;
+ALTER TABLE config_properties DROP COLUMN IF EXISTS environment;
+ALTER TABLE config_properties ADD CONSTRAINT config_properties_node_id_key_key UNIQUE (node_id, key);
@@ -0,0 +1,7 @@
+-- Properties without an environment apply everywhere; rows with an environment
+-- override them when resolving for that environment
+ALTER TABLE config_properties ADD COLUMN IF NOT EXISTS environment VARCHAR(50);
+
+ALTER TABLE config_properties DROP CONSTRAINT IF EXISTS config_properties_node_id_key_key;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_config_properties_node_key_env
+    ON config_properties(node_id, key, (COALESCE(environment, '')));
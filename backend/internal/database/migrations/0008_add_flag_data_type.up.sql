@@ -0,0 +1,3 @@
+ALTER TABLE config_properties DROP CONSTRAINT IF EXISTS config_properties_data_type_check;
+ALTER TABLE config_properties ADD CONSTRAINT config_properties_data_type_check
+    CHECK (data_type IN ('string', 'number', 'boolean', 'object', 'array', 'null', 'flag'));
@@ -0,0 +1,2 @@
+ALTER TABLE config_properties DROP COLUMN IF EXISTS rollout_key;
+ALTER TABLE config_properties DROP COLUMN IF EXISTS rollout_percentage;
@@ -0,0 +1,5 @@
+-- Boolean properties with a rollout serve their value to rollout_percentage
+-- percent of the contexts, bucketed by the rollout_key attribute
+ALTER TABLE config_properties ADD COLUMN IF NOT EXISTS rollout_percentage DOUBLE PRECISION
+    CHECK (rollout_percentage >= 0 AND rollout_percentage <= 100);
+ALTER TABLE config_properties ADD COLUMN IF NOT EXISTS rollout_key VARCHAR(255);
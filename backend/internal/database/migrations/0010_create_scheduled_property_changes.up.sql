@@ -0,0 +1,19 @@
+CREATE TABLE IF NOT EXISTS scheduled_property_changes (
+    id BIGSERIAL PRIMARY KEY,
+    node_id BIGINT NOT NULL REFERENCES config_nodes(id) ON DELETE CASCADE,
+    key VARCHAR(255) NOT NULL,
+    environment VARCHAR(50),
+    value TEXT NOT NULL,
+    data_type VARCHAR(50) NOT NULL,
+    default_value TEXT,
+    description TEXT DEFAULT '',
+    effective_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'applied', 'cancelled', 'failed')),
+    property_id BIGINT,
+    error TEXT,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    applied_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX IF NOT EXISTS idx_scheduled_property_changes_node_id ON scheduled_property_changes(node_id);
+CREATE INDEX IF NOT EXISTS idx_scheduled_property_changes_due ON scheduled_property_changes(effective_at) WHERE status = 'pending';
@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS property_drafts (
+    id BIGSERIAL PRIMARY KEY,
+    node_id BIGINT NOT NULL REFERENCES config_nodes(id) ON DELETE CASCADE,
+    key VARCHAR(255) NOT NULL,
+    environment VARCHAR(50),
+    value TEXT,
+    data_type VARCHAR(50),
+    default_value TEXT,
+    description TEXT DEFAULT '',
+    delete BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_property_drafts_node_key_env
+    ON property_drafts(node_id, key, (COALESCE(environment, '')));
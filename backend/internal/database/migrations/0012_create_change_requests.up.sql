@@ -0,0 +1,33 @@
+-- Subtrees that may only be modified by applying an approved change request
+CREATE TABLE IF NOT EXISTS protected_subtrees (
+    node_id BIGINT PRIMARY KEY REFERENCES config_nodes(id) ON DELETE CASCADE,
+    required_approvals INTEGER NOT NULL DEFAULT 1 CHECK (required_approvals >= 1),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+-- A change request submits the edits recorded in a workspace for review
+CREATE TABLE IF NOT EXISTS change_requests (
+    id BIGSERIAL PRIMARY KEY,
+    workspace_id BIGINT NOT NULL REFERENCES workspaces(id) ON DELETE CASCADE,
+    title VARCHAR(255) NOT NULL,
+    description TEXT DEFAULT '',
+    author VARCHAR(255) NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'open' CHECK (status IN ('open', 'approved', 'rejected', 'applied', 'withdrawn')),
+    required_approvals INTEGER NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    applied_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_change_requests_active_workspace
+    ON change_requests(workspace_id) WHERE status IN ('open', 'approved');
+
+CREATE TABLE IF NOT EXISTS change_request_reviews (
+    id BIGSERIAL PRIMARY KEY,
+    change_request_id BIGINT NOT NULL REFERENCES change_requests(id) ON DELETE CASCADE,
+    reviewer VARCHAR(255) NOT NULL,
+    decision VARCHAR(20) NOT NULL CHECK (decision IN ('approve', 'reject')),
+    comment TEXT DEFAULT '',
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(change_request_id, reviewer)
+);
@@ -0,0 +1,6 @@
+DROP TRIGGER IF EXISTS config_properties_versions ON config_properties;
+DROP TRIGGER IF EXISTS config_nodes_versions ON config_nodes;
+DROP FUNCTION IF EXISTS record_config_property_version();
+DROP FUNCTION IF EXISTS record_config_node_version();
+DROP TABLE IF EXISTS config_property_versions;
+DROP TABLE IF EXISTS config_node_versions;
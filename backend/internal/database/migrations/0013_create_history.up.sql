@@ -0,0 +1,91 @@
+-- Every version of each node and property, valid from valid_from until valid_to
+-- (exclusive; NULL for the current version). Versions outlive the rows they
+-- describe so deleted configuration can still be reconstructed.
+CREATE TABLE IF NOT EXISTS config_node_versions (
+    id BIGSERIAL PRIMARY KEY,
+    node_id BIGINT NOT NULL,
+    name VARCHAR(255) NOT NULL,
+    node_type VARCHAR(50) NOT NULL,
+    parent_id BIGINT,
+    description TEXT DEFAULT '',
+    valid_from TIMESTAMP WITH TIME ZONE NOT NULL,
+    valid_to TIMESTAMP WITH TIME ZONE
+);
+
+CREATE TABLE IF NOT EXISTS config_property_versions (
+    id BIGSERIAL PRIMARY KEY,
+    property_id BIGINT NOT NULL,
+    node_id BIGINT NOT NULL,
+    key VARCHAR(255) NOT NULL,
+    environment VARCHAR(50),
+    value TEXT NOT NULL,
+    data_type VARCHAR(50) NOT NULL,
+    default_value TEXT,
+    description TEXT DEFAULT '',
+    encrypted BOOLEAN NOT NULL DEFAULT FALSE,
+    encryption_key_id BIGINT,
+    rollout_percentage DOUBLE PRECISION,
+    rollout_key VARCHAR(255),
+    valid_from TIMESTAMP WITH TIME ZONE NOT NULL,
+    valid_to TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX IF NOT EXISTS idx_config_node_versions_node_id ON config_node_versions(node_id, valid_from);
+CREATE INDEX IF NOT EXISTS idx_config_property_versions_node_id ON config_property_versions(node_id, valid_from);
+CREATE INDEX IF NOT EXISTS idx_config_property_versions_current ON config_property_versions(property_id) WHERE valid_to IS NULL;
+CREATE INDEX IF NOT EXISTS idx_config_node_versions_current ON config_node_versions(node_id) WHERE valid_to IS NULL;
+
+-- Versions are recorded by triggers so that every write path (API, scheduler,
+-- draft publishes, workspace merges, cascading deletes) is captured
+CREATE OR REPLACE FUNCTION record_config_node_version() RETURNS TRIGGER AS $$
+BEGIN
+    IF TG_OP IN ('UPDATE', 'DELETE') THEN
+        UPDATE config_node_versions SET valid_to = now() WHERE node_id = OLD.id AND valid_to IS NULL;
+    END IF;
+    IF TG_OP IN ('INSERT', 'UPDATE') THEN
+        INSERT INTO config_node_versions (node_id, name, node_type, parent_id, description, valid_from)
+        VALUES (NEW.id, NEW.name, NEW.node_type, NEW.parent_id, NEW.description, now());
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE FUNCTION record_config_property_version() RETURNS TRIGGER AS $$
+BEGIN
+    IF TG_OP IN ('UPDATE', 'DELETE') THEN
+        UPDATE config_property_versions SET valid_to = now() WHERE property_id = OLD.id AND valid_to IS NULL;
+    END IF;
+    IF TG_OP IN ('INSERT', 'UPDATE') THEN
+        INSERT INTO config_property_versions (
+            property_id, node_id, key, environment, value, data_type, default_value, description,
+            encrypted, encryption_key_id, rollout_percentage, rollout_key, valid_from)
+        VALUES (
+            NEW.id, NEW.node_id, NEW.key, NEW.environment, NEW.value, NEW.data_type, NEW.default_value, NEW.description,
+            NEW.encrypted, NEW.encryption_key_id, NEW.rollout_percentage, NEW.rollout_key, now());
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS config_nodes_versions ON config_nodes;
+CREATE TRIGGER config_nodes_versions AFTER INSERT OR UPDATE OR DELETE ON config_nodes
+    FOR EACH ROW EXECUTE FUNCTION record_config_node_version();
+
+DROP TRIGGER IF EXISTS config_properties_versions ON config_properties;
+CREATE TRIGGER config_properties_versions AFTER INSERT OR UPDATE OR DELETE ON config_properties
+    FOR EACH ROW EXECUTE FUNCTION record_config_property_version();
+
+-- Seed the current state. Earlier versions were never recorded, so each row is
+-- known from its last update onwards only.
+INSERT INTO config_node_versions (node_id, name, node_type, parent_id, description, valid_from)
+SELECT id, name, node_type, parent_id, description, COALESCE(updated_at, created_at, now())
+FROM config_nodes
+WHERE NOT EXISTS (SELECT 1 FROM config_node_versions);
+
+INSERT INTO config_property_versions (
+    property_id, node_id, key, environment, value, data_type, default_value, description,
+    encrypted, encryption_key_id, rollout_percentage, rollout_key, valid_from)
+SELECT id, node_id, key, environment, value, data_type, default_value, description,
+    encrypted, encryption_key_id, rollout_percentage, rollout_key, COALESCE(updated_at, created_at, now())
+FROM config_properties
+WHERE NOT EXISTS (SELECT 1 FROM config_property_versions);
@@ -0,0 +1,17 @@
+-- Snapshots keep a copy of the resolved configuration of a node, and outlive
+-- the node so that releases can always be traced back to their configuration
+CREATE TABLE IF NOT EXISTS config_snapshots (
+    id BIGSERIAL PRIMARY KEY,
+    node_id BIGINT NOT NULL,
+    node_name VARCHAR(255) NOT NULL,
+    name VARCHAR(255) NOT NULL,
+    description TEXT DEFAULT '',
+    environment VARCHAR(50) DEFAULT '',
+    properties JSONB NOT NULL,
+    redacted_keys JSONB NOT NULL DEFAULT '[]',
+    created_by VARCHAR(255) DEFAULT '',
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(node_id, name)
+);
+
+CREATE INDEX IF NOT EXISTS idx_config_snapshots_node_id ON config_snapshots(node_id, created_at);
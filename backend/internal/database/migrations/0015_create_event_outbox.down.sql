@@ -0,0 +1,6 @@
+DROP TRIGGER IF EXISTS config_properties_events ON config_properties;
+DROP TRIGGER IF EXISTS config_nodes_events ON config_nodes;
+DROP FUNCTION IF EXISTS record_config_property_event();
+DROP FUNCTION IF EXISTS record_config_node_event();
+DROP FUNCTION IF EXISTS config_event_source();
+DROP TABLE IF EXISTS event_outbox;
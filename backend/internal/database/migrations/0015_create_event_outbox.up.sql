@@ -0,0 +1,59 @@
+-- Change events waiting to be delivered. Rows are written by triggers in the
+-- transaction of the change itself, so an event is recorded if and only if the
+-- change is committed, whichever path made it. The dispatcher publishes rows in
+-- id order and sets published_at once every publisher accepted them.
+CREATE TABLE IF NOT EXISTS event_outbox (
+    id BIGSERIAL PRIMARY KEY,
+    event_id VARCHAR(32) NOT NULL DEFAULT md5(random()::text || clock_timestamp()::text),
+    type VARCHAR(50) NOT NULL,
+    node_id BIGINT NOT NULL,
+    property_id BIGINT,
+    key VARCHAR(255),
+    environment VARCHAR(50),
+    source VARCHAR(50) NOT NULL,
+    occurred_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+    published_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX IF NOT EXISTS idx_event_outbox_pending ON event_outbox(id) WHERE published_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_event_outbox_published_at ON event_outbox(published_at) WHERE published_at IS NOT NULL;
+
+-- Writers other than the API name themselves for the transaction with
+-- SET LOCAL config_manager.event_source, e.g. the scheduler
+CREATE OR REPLACE FUNCTION config_event_source() RETURNS TEXT AS $$
+    SELECT COALESCE(NULLIF(current_setting('config_manager.event_source', true), ''), 'api');
+$$ LANGUAGE sql STABLE;
+
+CREATE OR REPLACE FUNCTION record_config_node_event() RETURNS TRIGGER AS $$
+DECLARE
+    event_type TEXT := CASE TG_OP WHEN 'INSERT' THEN 'node.created' WHEN 'UPDATE' THEN 'node.updated' ELSE 'node.deleted' END;
+    row_id BIGINT := CASE TG_OP WHEN 'DELETE' THEN OLD.id ELSE NEW.id END;
+BEGIN
+    INSERT INTO event_outbox (type, node_id, source) VALUES (event_type, row_id, config_event_source());
+    INSERT INTO event_outbox (type, node_id, source) VALUES ('config.invalidated', row_id, config_event_source());
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE FUNCTION record_config_property_event() RETURNS TRIGGER AS $$
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        INSERT INTO event_outbox (type, node_id, property_id, key, environment, source)
+        VALUES ('property.deleted', OLD.node_id, OLD.id, OLD.key, OLD.environment, config_event_source());
+        INSERT INTO event_outbox (type, node_id, source) VALUES ('config.invalidated', OLD.node_id, config_event_source());
+    ELSE
+        INSERT INTO event_outbox (type, node_id, property_id, key, environment, source)
+        VALUES ('property.changed', NEW.node_id, NEW.id, NEW.key, NEW.environment, config_event_source());
+        INSERT INTO event_outbox (type, node_id, source) VALUES ('config.invalidated', NEW.node_id, config_event_source());
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS config_nodes_events ON config_nodes;
+CREATE TRIGGER config_nodes_events AFTER INSERT OR UPDATE OR DELETE ON config_nodes
+    FOR EACH ROW EXECUTE FUNCTION record_config_node_event();
+
+DROP TRIGGER IF EXISTS config_properties_events ON config_properties;
+CREATE TRIGGER config_properties_events AFTER INSERT OR UPDATE OR DELETE ON config_properties
+    FOR EACH ROW EXECUTE FUNCTION record_config_property_event();
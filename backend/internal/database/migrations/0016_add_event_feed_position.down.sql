@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_event_outbox_unsequenced;
+DROP INDEX IF EXISTS idx_event_outbox_position;
+ALTER TABLE event_outbox DROP COLUMN IF EXISTS position;
+DROP SEQUENCE IF EXISTS event_feed_position;
@@ -0,0 +1,11 @@
+-- Feed positions are assigned to outbox events by a single sequencer after
+-- their transaction committed, so they grow in the order events become
+-- visible and a reader that saw position N can never miss a later event
+-- with a smaller one. Outbox ids cannot serve as cursors: concurrent
+-- transactions commit out of id order.
+CREATE SEQUENCE IF NOT EXISTS event_feed_position;
+
+ALTER TABLE event_outbox ADD COLUMN IF NOT EXISTS position BIGINT;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_event_outbox_position ON event_outbox(position);
+CREATE INDEX IF NOT EXISTS idx_event_outbox_unsequenced ON event_outbox(id) WHERE position IS NULL;
@@ -0,0 +1,11 @@
+-- Keys a node requires every leaf below it to resolve, e.g. settings a center
+-- cannot go live without
+CREATE TABLE IF NOT EXISTS config_required_keys (
+    id BIGSERIAL PRIMARY KEY,
+    node_id BIGINT NOT NULL REFERENCES config_nodes(id) ON DELETE CASCADE,
+    key VARCHAR(255) NOT NULL,
+    description TEXT DEFAULT '',
+    created_by VARCHAR(255) DEFAULT '',
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(node_id, key)
+);
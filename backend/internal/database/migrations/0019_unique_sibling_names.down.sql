@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_config_nodes_root_name;
+DROP INDEX IF EXISTS idx_config_nodes_sibling_name;
@@ -0,0 +1,12 @@
+-- Sibling nodes, and root nodes, must have names that differ other than in
+-- case. Existing duplicates keep the oldest node's name; the others get their
+-- ID appended so the indexes can be created.
+UPDATE config_nodes n
+SET name = left(n.name, 230) || ' (' || n.id || ')', updated_at = now()
+WHERE EXISTS (
+    SELECT 1 FROM config_nodes o
+    WHERE o.parent_id IS NOT DISTINCT FROM n.parent_id AND lower(o.name) = lower(n.name) AND o.id < n.id
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_config_nodes_sibling_name ON config_nodes(parent_id, lower(name)) WHERE parent_id IS NOT NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_config_nodes_root_name ON config_nodes(lower(name)) WHERE parent_id IS NULL;
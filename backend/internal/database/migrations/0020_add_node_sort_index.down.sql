@@ -0,0 +1,10 @@
+DROP TRIGGER IF EXISTS config_nodes_events ON config_nodes;
+CREATE TRIGGER config_nodes_events AFTER INSERT OR UPDATE OR DELETE ON config_nodes
+    FOR EACH ROW EXECUTE FUNCTION record_config_node_event();
+
+DROP TRIGGER IF EXISTS config_nodes_versions ON config_nodes;
+CREATE TRIGGER config_nodes_versions AFTER INSERT OR UPDATE OR DELETE ON config_nodes
+    FOR EACH ROW EXECUTE FUNCTION record_config_node_version();
+
+DROP INDEX IF EXISTS idx_config_nodes_sort;
+ALTER TABLE config_nodes DROP COLUMN IF EXISTS sort_index;
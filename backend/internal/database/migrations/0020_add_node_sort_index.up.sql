@@ -0,0 +1,22 @@
+-- Siblings are listed by sort_index, then by ID. Existing siblings keep the
+-- order they were created in.
+ALTER TABLE config_nodes ADD COLUMN IF NOT EXISTS sort_index INTEGER NOT NULL DEFAULT 0;
+
+UPDATE config_nodes n SET sort_index = o.position
+FROM (
+    SELECT id, ROW_NUMBER() OVER (PARTITION BY parent_id ORDER BY created_at, id) - 1 AS position
+    FROM config_nodes
+) o
+WHERE n.id = o.id AND n.sort_index <> o.position;
+
+CREATE INDEX IF NOT EXISTS idx_config_nodes_sort ON config_nodes(parent_id, sort_index, id);
+
+-- Reordering is not a configuration change: it neither records a version nor
+-- invalidates resolved configurations
+DROP TRIGGER IF EXISTS config_nodes_versions ON config_nodes;
+CREATE TRIGGER config_nodes_versions AFTER INSERT OR UPDATE OF name, node_type, parent_id, description OR DELETE ON config_nodes
+    FOR EACH ROW EXECUTE FUNCTION record_config_node_version();
+
+DROP TRIGGER IF EXISTS config_nodes_events ON config_nodes;
+CREATE TRIGGER config_nodes_events AFTER INSERT OR UPDATE OF name, node_type, parent_id, description OR DELETE ON config_nodes
+    FOR EACH ROW EXECUTE FUNCTION record_config_node_event();
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_config_nodes_labels;
+ALTER TABLE config_nodes DROP COLUMN IF EXISTS labels;
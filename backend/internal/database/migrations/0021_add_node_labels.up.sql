@@ -0,0 +1,5 @@
+-- Key/value labels group nodes across the hierarchy, e.g. pilot or 24h
+-- centers. They do not take part in resolution.
+ALTER TABLE config_nodes ADD COLUMN IF NOT EXISTS labels JSONB NOT NULL DEFAULT '{}';
+
+CREATE INDEX IF NOT EXISTS idx_config_nodes_labels ON config_nodes USING GIN (labels);
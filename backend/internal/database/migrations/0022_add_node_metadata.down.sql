@@ -0,0 +1 @@
+ALTER TABLE config_nodes DROP COLUMN IF EXISTS metadata;
@@ -0,0 +1,3 @@
+-- Free-form metadata about a node, such as its owner, external IDs or
+-- coordinates. It is stored and returned as is and never resolved.
+ALTER TABLE config_nodes ADD COLUMN IF NOT EXISTS metadata JSONB NOT NULL DEFAULT '{}';
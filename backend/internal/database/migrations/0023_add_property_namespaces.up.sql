@@ -0,0 +1,23 @@
+-- An optional namespace, such as payments or logging, groups the keys of a
+-- node so they can be listed and resolved on their own
+ALTER TABLE config_properties ADD COLUMN IF NOT EXISTS namespace VARCHAR(50);
+ALTER TABLE config_property_versions ADD COLUMN IF NOT EXISTS namespace VARCHAR(50);
+
+CREATE INDEX IF NOT EXISTS idx_config_properties_namespace ON config_properties(node_id, namespace);
+
+CREATE OR REPLACE FUNCTION record_config_property_version() RETURNS TRIGGER AS $$
+BEGIN
+    IF TG_OP IN ('UPDATE', 'DELETE') THEN
+        UPDATE config_property_versions SET valid_to = now() WHERE property_id = OLD.id AND valid_to IS NULL;
+    END IF;
+    IF TG_OP IN ('INSERT', 'UPDATE') THEN
+        INSERT INTO config_property_versions (
+            property_id, node_id, key, environment, value, data_type, default_value, description,
+            encrypted, encryption_key_id, rollout_percentage, rollout_key, is_final, namespace, valid_from)
+        VALUES (
+            NEW.id, NEW.node_id, NEW.key, NEW.environment, NEW.value, NEW.data_type, NEW.default_value, NEW.description,
+            NEW.encrypted, NEW.encryption_key_id, NEW.rollout_percentage, NEW.rollout_key, NEW.is_final, NEW.namespace, now());
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
@@ -0,0 +1,10 @@
+-- Keys being retired across the whole tree, with the key replacing them and
+-- the date after which they should no longer be defined
+CREATE TABLE IF NOT EXISTS config_key_deprecations (
+    key VARCHAR(255) PRIMARY KEY,
+    replacement_key VARCHAR(255),
+    sunset_at TIMESTAMP WITH TIME ZONE,
+    reason TEXT DEFAULT '',
+    created_by VARCHAR(255) DEFAULT '',
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
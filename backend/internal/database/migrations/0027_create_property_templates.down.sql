@@ -0,0 +1,7 @@
+DROP TRIGGER IF EXISTS config_template_versions_events ON config_template_versions;
+DROP TRIGGER IF EXISTS config_node_templates_events ON config_node_templates;
+DROP FUNCTION IF EXISTS record_config_template_version_event();
+DROP FUNCTION IF EXISTS record_config_node_template_event();
+DROP TABLE IF EXISTS config_node_templates;
+DROP TABLE IF EXISTS config_template_versions;
+DROP TABLE IF EXISTS config_templates;
@@ -0,0 +1,62 @@
+-- Named bundles of properties, e.g. the standard settings of a retail center.
+-- Every change to a template's properties creates a new immutable version.
+CREATE TABLE IF NOT EXISTS config_templates (
+    id BIGSERIAL PRIMARY KEY,
+    name VARCHAR(255) NOT NULL UNIQUE,
+    description TEXT DEFAULT '',
+    latest_version INTEGER NOT NULL DEFAULT 1,
+    created_by VARCHAR(255) DEFAULT '',
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS config_template_versions (
+    template_id BIGINT NOT NULL REFERENCES config_templates(id) ON DELETE CASCADE,
+    version INTEGER NOT NULL,
+    properties JSONB NOT NULL DEFAULT '[]',
+    comment TEXT DEFAULT '',
+    created_by VARCHAR(255) DEFAULT '',
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (template_id, version)
+);
+
+-- Templates attached to a node contribute to its resolution below the node's
+-- own properties. A NULL version follows the latest version of the template.
+CREATE TABLE IF NOT EXISTS config_node_templates (
+    id BIGSERIAL PRIMARY KEY,
+    node_id BIGINT NOT NULL REFERENCES config_nodes(id) ON DELETE CASCADE,
+    template_id BIGINT NOT NULL REFERENCES config_templates(id),
+    version INTEGER,
+    attached_by VARCHAR(255) DEFAULT '',
+    attached_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(node_id, template_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_config_node_templates_template_id ON config_node_templates(template_id);
+
+-- Attaching, repinning or detaching a template, and publishing a version that
+-- nodes follow, change resolved configuration like a property write does
+CREATE OR REPLACE FUNCTION record_config_node_template_event() RETURNS TRIGGER AS $$
+BEGIN
+    INSERT INTO event_outbox (type, node_id, source)
+    VALUES ('config.invalidated', CASE TG_OP WHEN 'DELETE' THEN OLD.node_id ELSE NEW.node_id END, config_event_source());
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE FUNCTION record_config_template_version_event() RETURNS TRIGGER AS $$
+BEGIN
+    INSERT INTO event_outbox (type, node_id, source)
+    SELECT 'config.invalidated', node_id, config_event_source()
+    FROM config_node_templates WHERE template_id = NEW.template_id AND version IS NULL;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS config_node_templates_events ON config_node_templates;
+CREATE TRIGGER config_node_templates_events AFTER INSERT OR UPDATE OR DELETE ON config_node_templates
+    FOR EACH ROW EXECUTE FUNCTION record_config_node_template_event();
+
+DROP TRIGGER IF EXISTS config_template_versions_events ON config_template_versions;
+CREATE TRIGGER config_template_versions_events AFTER INSERT ON config_template_versions
+    FOR EACH ROW EXECUTE FUNCTION record_config_template_version_event();
@@ -0,0 +1,11 @@
+-- Administrative operations spanning many nodes, such as renaming a key
+-- across the tree, with who ran them and what they touched
+CREATE TABLE IF NOT EXISTS config_audit_log (
+    id BIGSERIAL PRIMARY KEY,
+    action VARCHAR(100) NOT NULL,
+    actor VARCHAR(255) DEFAULT '',
+    details JSONB NOT NULL DEFAULT '{}',
+    occurred_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_config_audit_log_action ON config_audit_log(action, occurred_at);
@@ -0,0 +1,11 @@
+-- Catalog of known keys, describing what each is for and who owns it
+CREATE TABLE IF NOT EXISTS config_key_registry (
+    key VARCHAR(255) PRIMARY KEY,
+    description TEXT DEFAULT '',
+    expected_type VARCHAR(50),
+    owner_team VARCHAR(255) DEFAULT '',
+    schema_ref TEXT DEFAULT '',
+    created_by VARCHAR(255) DEFAULT '',
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
@@ -0,0 +1,14 @@
+-- Responses to requests sent with an Idempotency-Key header, replayed when a
+-- client retries the same request. A NULL status marks a request in flight.
+CREATE TABLE IF NOT EXISTS config_idempotency_keys (
+    principal VARCHAR(255) NOT NULL DEFAULT '',
+    idempotency_key VARCHAR(255) NOT NULL,
+    request_hash CHAR(64) NOT NULL,
+    status_code INTEGER,
+    content_type VARCHAR(255) DEFAULT '',
+    response_body BYTEA,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (principal, idempotency_key)
+);
+
+CREATE INDEX IF NOT EXISTS idx_config_idempotency_keys_created_at ON config_idempotency_keys(created_at);
@@ -0,0 +1,2 @@
+DROP TRIGGER IF EXISTS event_outbox_notify_invalidated ON event_outbox;
+DROP FUNCTION IF EXISTS notify_config_invalidated();
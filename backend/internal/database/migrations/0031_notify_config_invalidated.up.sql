@@ -0,0 +1,14 @@
+-- Announce every config.invalidated event on the config_invalidated channel
+-- with the node as payload, so that each server can drop the resolutions it
+-- caches for that subtree. Notifications are delivered when the transaction
+-- recording the event commits, and repeated ones are sent once.
+CREATE OR REPLACE FUNCTION notify_config_invalidated() RETURNS TRIGGER AS $$
+BEGIN
+    PERFORM pg_notify('config_invalidated', NEW.node_id::text);
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS event_outbox_notify_invalidated ON event_outbox;
+CREATE TRIGGER event_outbox_notify_invalidated AFTER INSERT ON event_outbox
+    FOR EACH ROW WHEN (NEW.type = 'config.invalidated') EXECUTE FUNCTION notify_config_invalidated();
@@ -0,0 +1,3 @@
+DROP TRIGGER IF EXISTS event_outbox_mark_resolutions_stale ON event_outbox;
+DROP FUNCTION IF EXISTS mark_resolutions_stale();
+DROP TABLE IF EXISTS resolved_configurations;
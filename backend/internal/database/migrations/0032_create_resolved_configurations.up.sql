@@ -0,0 +1,50 @@
+-- Resolutions of each node without context, for no environment and for each
+-- environment properties are set for, so that resolving is a single read. The
+-- server refreshes them as change events are delivered when
+-- RESOLVE_MATERIALIZED is set. resolved is NULL for resolutions that are
+-- computed on every read instead, e.g. those holding encrypted values, and
+-- valid_until is when the next property on the path expires.
+CREATE TABLE IF NOT EXISTS resolved_configurations (
+    node_id BIGINT NOT NULL REFERENCES config_nodes(id) ON DELETE CASCADE,
+    environment VARCHAR(50) NOT NULL DEFAULT '',
+    resolved JSONB,
+    valid_until TIMESTAMP WITH TIME ZONE,
+    stale BOOLEAN NOT NULL DEFAULT FALSE,
+    version BIGINT NOT NULL DEFAULT 0,
+    refreshed_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+    PRIMARY KEY (node_id, environment)
+);
+
+-- A change marks the resolutions of its subtree stale in its own transaction,
+-- so that they are not read until they are refreshed, and bumps their version,
+-- so that a refresh that read the configuration before the change does not
+-- clear the mark. Rows are locked in key order to keep concurrent changes
+-- from deadlocking.
+CREATE OR REPLACE FUNCTION mark_resolutions_stale() RETURNS TRIGGER AS $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM resolved_configurations) THEN
+        RETURN NULL;
+    END IF;
+
+    UPDATE resolved_configurations r SET stale = TRUE, version = r.version + 1
+    FROM (
+        SELECT node_id, environment FROM resolved_configurations
+        WHERE node_id IN (
+            WITH RECURSIVE subtree AS (
+                SELECT NEW.node_id AS id
+                UNION ALL
+                SELECT n.id FROM config_nodes n JOIN subtree s ON n.parent_id = s.id
+            )
+            SELECT id FROM subtree
+        )
+        ORDER BY node_id, environment
+        FOR UPDATE
+    ) locked
+    WHERE r.node_id = locked.node_id AND r.environment = locked.environment;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS event_outbox_mark_resolutions_stale ON event_outbox;
+CREATE TRIGGER event_outbox_mark_resolutions_stale AFTER INSERT ON event_outbox
+    FOR EACH ROW WHEN (NEW.type = 'config.invalidated') EXECUTE FUNCTION mark_resolutions_stale();
@@ -0,0 +1,4 @@
+DROP TRIGGER IF EXISTS event_outbox_bump_config_version ON event_outbox;
+DROP FUNCTION IF EXISTS bump_config_version();
+DROP TABLE IF EXISTS config_versions;
+DROP SEQUENCE IF EXISTS config_version_seq;
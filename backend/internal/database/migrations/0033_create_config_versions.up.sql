@@ -0,0 +1,29 @@
+-- Configuration version numbers. Every change that can alter the resolution
+-- of a subtree gives the node at its top the next number of
+-- config_version_seq, so the version of a resolution, the highest number
+-- along its path, grows with every change to it.
+CREATE SEQUENCE IF NOT EXISTS config_version_seq;
+
+CREATE TABLE IF NOT EXISTS config_versions (
+    node_id BIGINT PRIMARY KEY REFERENCES config_nodes(id) ON DELETE CASCADE,
+    version BIGINT NOT NULL
+);
+
+INSERT INTO config_versions (node_id, version)
+SELECT id, nextval('config_version_seq') FROM config_nodes ORDER BY id
+ON CONFLICT (node_id) DO NOTHING;
+
+-- Deleted nodes have no version to bump
+CREATE OR REPLACE FUNCTION bump_config_version() RETURNS TRIGGER AS $$
+BEGIN
+    INSERT INTO config_versions (node_id, version)
+    SELECT NEW.node_id, nextval('config_version_seq')
+    WHERE EXISTS (SELECT 1 FROM config_nodes WHERE id = NEW.node_id)
+    ON CONFLICT (node_id) DO UPDATE SET version = EXCLUDED.version;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS event_outbox_bump_config_version ON event_outbox;
+CREATE TRIGGER event_outbox_bump_config_version AFTER INSERT ON event_outbox
+    FOR EACH ROW WHEN (NEW.type = 'config.invalidated') EXECUTE FUNCTION bump_config_version();
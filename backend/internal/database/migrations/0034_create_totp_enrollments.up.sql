@@ -0,0 +1,10 @@
+-- Authenticator apps enrolled by users for two-factor authentication. The
+-- last step is the most recent time step a code was accepted for, so that
+-- each code is used once.
+CREATE TABLE IF NOT EXISTS totp_enrollments (
+    subject VARCHAR(255) PRIMARY KEY,
+    secret VARCHAR(64) NOT NULL,
+    last_step BIGINT NOT NULL DEFAULT 0,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    confirmed_at TIMESTAMP WITH TIME ZONE
+);
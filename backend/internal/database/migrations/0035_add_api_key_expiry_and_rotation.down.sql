@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS idx_api_keys_previous_key_hash;
+ALTER TABLE api_keys DROP COLUMN IF EXISTS previous_key_expires_at;
+ALTER TABLE api_keys DROP COLUMN IF EXISTS previous_key_hash;
+ALTER TABLE api_keys DROP COLUMN IF EXISTS rotated_at;
+ALTER TABLE api_keys DROP COLUMN IF EXISTS last_used_at;
+ALTER TABLE api_keys DROP COLUMN IF EXISTS expires_at;
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_config_audit_log_unexported;
+ALTER TABLE config_audit_log DROP COLUMN IF EXISTS exported_at;
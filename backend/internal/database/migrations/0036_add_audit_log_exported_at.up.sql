@@ -0,0 +1,4 @@
+-- Entries are marked once they were handed to the SIEM exporter
+ALTER TABLE config_audit_log ADD COLUMN IF NOT EXISTS exported_at TIMESTAMP WITH TIME ZONE;
+
+CREATE INDEX IF NOT EXISTS idx_config_audit_log_unexported ON config_audit_log(id) WHERE exported_at IS NULL;
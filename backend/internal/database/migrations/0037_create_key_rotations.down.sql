@@ -0,0 +1,9 @@
+DROP TABLE IF EXISTS key_rotations;
+
+-- Retired keys no property uses are dropped; restoring one key per subtree
+-- fails while a rotation is unfinished
+DELETE FROM encryption_keys k
+WHERE retired_at IS NOT NULL AND NOT EXISTS (SELECT 1 FROM config_properties p WHERE p.encryption_key_id = k.id);
+DROP INDEX IF EXISTS idx_encryption_keys_active;
+ALTER TABLE encryption_keys ADD CONSTRAINT encryption_keys_node_id_key UNIQUE (node_id);
+ALTER TABLE encryption_keys DROP COLUMN IF EXISTS retired_at;
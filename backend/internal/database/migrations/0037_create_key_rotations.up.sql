@@ -0,0 +1,28 @@
+-- A subtree may have several keys: the one new values are encrypted with, and
+-- retired ones still holding values until they are re-encrypted, or history.
+ALTER TABLE encryption_keys ADD COLUMN IF NOT EXISTS retired_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE encryption_keys DROP CONSTRAINT IF EXISTS encryption_keys_node_id_key;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_encryption_keys_active ON encryption_keys(node_id) WHERE retired_at IS NULL;
+
+-- Jobs replacing a subtree key. When a job starts, a new key is created and
+-- the old one retired, then the properties encrypted with the old key are
+-- re-encrypted in batches. Progress is committed with each batch, so a job
+-- interrupted by a restart resumes where it stopped.
+CREATE TABLE IF NOT EXISTS key_rotations (
+    id BIGSERIAL PRIMARY KEY,
+    node_id BIGINT NOT NULL REFERENCES config_nodes(id) ON DELETE CASCADE,
+    old_key_id BIGINT NOT NULL REFERENCES encryption_keys(id),
+    new_key_id BIGINT REFERENCES encryption_keys(id),
+    status VARCHAR(20) NOT NULL,
+    total_properties INTEGER NOT NULL DEFAULT 0,
+    rotated_properties INTEGER NOT NULL DEFAULT 0,
+    error TEXT,
+    requested_by VARCHAR(255) NOT NULL,
+    scheduled_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    started_at TIMESTAMP WITH TIME ZONE,
+    completed_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX IF NOT EXISTS idx_key_rotations_due ON key_rotations(scheduled_at, id) WHERE status IN ('pending', 'running');
+CREATE UNIQUE INDEX IF NOT EXISTS idx_key_rotations_open ON key_rotations(old_key_id) WHERE status IN ('pending', 'running', 'failed');
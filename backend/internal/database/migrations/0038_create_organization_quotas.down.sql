@@ -0,0 +1,6 @@
+DROP TRIGGER IF EXISTS config_properties_quota ON config_properties;
+DROP TRIGGER IF EXISTS config_nodes_quota ON config_nodes;
+DROP FUNCTION IF EXISTS check_property_quota();
+DROP FUNCTION IF EXISTS check_node_quota();
+DROP FUNCTION IF EXISTS organization_limits(BIGINT);
+DROP TABLE IF EXISTS organization_quotas;
@@ -0,0 +1,126 @@
+-- Limits on the size of each organization, the subtree of a root node. The row
+-- without a root holds the defaults of organizations without a quota of their
+-- own. A NULL limit falls back to the default, 0 lifts it.
+CREATE TABLE IF NOT EXISTS organization_quotas (
+    root_node_id BIGINT REFERENCES config_nodes(id) ON DELETE CASCADE,
+    max_nodes INTEGER CHECK (max_nodes >= 0),
+    max_properties_per_node INTEGER CHECK (max_properties_per_node >= 0),
+    max_depth INTEGER CHECK (max_depth >= 0),
+    requests_per_minute INTEGER CHECK (requests_per_minute >= 0),
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_organization_quotas_root ON organization_quotas((COALESCE(root_node_id, 0)));
+
+-- The limits in effect for the organization of root, NULL when unlimited
+CREATE OR REPLACE FUNCTION organization_limits(root BIGINT)
+RETURNS TABLE (max_nodes INTEGER, max_properties_per_node INTEGER, max_depth INTEGER, requests_per_minute INTEGER) AS $$
+    SELECT
+        NULLIF(COALESCE(o.max_nodes, d.max_nodes), 0),
+        NULLIF(COALESCE(o.max_properties_per_node, d.max_properties_per_node), 0),
+        NULLIF(COALESCE(o.max_depth, d.max_depth), 0),
+        NULLIF(COALESCE(o.requests_per_minute, d.requests_per_minute), 0)
+    FROM (SELECT 1) one
+    LEFT JOIN organization_quotas o ON o.root_node_id = root
+    LEFT JOIN organization_quotas d ON d.root_node_id IS NULL;
+$$ LANGUAGE sql STABLE;
+
+-- Quotas are checked by triggers after each row is written, so that every
+-- write path (API, apply, batches, workspace merges, drafts, the scheduler) is
+-- held to them. Violations raise SQLSTATE CQ001, reported as 422.
+CREATE OR REPLACE FUNCTION check_node_quota() RETURNS TRIGGER AS $$
+DECLARE
+    root_id BIGINT;
+    root_name TEXT;
+    node_depth INTEGER;
+    limits RECORD;
+    used INTEGER;
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM organization_quotas) THEN
+        RETURN NULL;
+    END IF;
+
+    -- The root is at depth 1
+    WITH RECURSIVE ancestors AS (
+        SELECT n.id, n.parent_id, 1 AS depth FROM config_nodes n WHERE n.id = NEW.id
+        UNION ALL
+        SELECT n.id, n.parent_id, a.depth + 1 FROM config_nodes n JOIN ancestors a ON n.id = a.parent_id
+    )
+    SELECT a.id, a.depth INTO root_id, node_depth FROM ancestors a WHERE a.parent_id IS NULL;
+
+    SELECT * INTO limits FROM organization_limits(root_id);
+    SELECT n.name INTO root_name FROM config_nodes n WHERE n.id = root_id;
+
+    IF limits.max_depth IS NOT NULL THEN
+        -- A moved node brings its subtree along
+        used := node_depth;
+        IF TG_OP = 'UPDATE' THEN
+            WITH RECURSIVE subtree AS (
+                SELECT NEW.id AS id, 0 AS below
+                UNION ALL
+                SELECT n.id, s.below + 1 FROM config_nodes n JOIN subtree s ON n.parent_id = s.id
+            )
+            SELECT node_depth + MAX(s.below) INTO used FROM subtree s;
+        END IF;
+        IF used > limits.max_depth THEN
+            RAISE EXCEPTION 'organization "%" allows trees % levels deep, the change would make it % levels deep', root_name, limits.max_depth, used
+                USING ERRCODE = 'CQ001';
+        END IF;
+    END IF;
+
+    IF limits.max_nodes IS NOT NULL THEN
+        WITH RECURSIVE organization AS (
+            SELECT root_id AS id
+            UNION ALL
+            SELECT n.id FROM config_nodes n JOIN organization o ON n.parent_id = o.id
+        )
+        SELECT COUNT(*) INTO used FROM organization;
+        IF used > limits.max_nodes THEN
+            RAISE EXCEPTION 'organization "%" reached its quota of % nodes', root_name, limits.max_nodes
+                USING ERRCODE = 'CQ001';
+        END IF;
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE FUNCTION check_property_quota() RETURNS TRIGGER AS $$
+DECLARE
+    root_id BIGINT;
+    root_name TEXT;
+    limits RECORD;
+    used INTEGER;
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM organization_quotas) THEN
+        RETURN NULL;
+    END IF;
+
+    WITH RECURSIVE ancestors AS (
+        SELECT n.id, n.parent_id FROM config_nodes n WHERE n.id = NEW.node_id
+        UNION ALL
+        SELECT n.id, n.parent_id FROM config_nodes n JOIN ancestors a ON n.id = a.parent_id
+    )
+    SELECT a.id INTO root_id FROM ancestors a WHERE a.parent_id IS NULL;
+
+    SELECT * INTO limits FROM organization_limits(root_id);
+    IF limits.max_properties_per_node IS NULL THEN
+        RETURN NULL;
+    END IF;
+
+    SELECT COUNT(*) INTO used FROM config_properties p WHERE p.node_id = NEW.node_id;
+    IF used > limits.max_properties_per_node THEN
+        SELECT n.name INTO root_name FROM config_nodes n WHERE n.id = root_id;
+        RAISE EXCEPTION 'organization "%" allows % properties per node, node % would have %', root_name, limits.max_properties_per_node, NEW.node_id, used
+            USING ERRCODE = 'CQ001';
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS config_nodes_quota ON config_nodes;
+CREATE TRIGGER config_nodes_quota AFTER INSERT OR UPDATE OF parent_id ON config_nodes
+    FOR EACH ROW EXECUTE FUNCTION check_node_quota();
+
+DROP TRIGGER IF EXISTS config_properties_quota ON config_properties;
+CREATE TRIGGER config_properties_quota AFTER INSERT OR UPDATE OF node_id ON config_properties
+    FOR EACH ROW EXECUTE FUNCTION check_property_quota();
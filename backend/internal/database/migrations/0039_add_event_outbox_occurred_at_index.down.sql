@@ -0,0 +1 @@
+DROP INDEX IF EXISTS idx_event_outbox_occurred_at;
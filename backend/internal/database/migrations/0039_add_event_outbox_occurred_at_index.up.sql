@@ -0,0 +1,2 @@
+-- Lets the admin stats count recent changes without scanning the whole outbox
+CREATE INDEX IF NOT EXISTS idx_event_outbox_occurred_at ON event_outbox(occurred_at);
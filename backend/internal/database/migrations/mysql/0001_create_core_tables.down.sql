@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS config_idempotency_keys;
+DROP TABLE IF EXISTS node_access;
+DROP TABLE IF EXISTS config_properties;
+DROP TABLE IF EXISTS config_nodes;
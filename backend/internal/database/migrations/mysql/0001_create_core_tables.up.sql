@@ -0,0 +1,66 @@
+-- The core schema for MySQL 8 and MariaDB 10.5 or later: the tree, its
+-- properties, the delete guard and idempotency keys. The connection quotes
+-- identifiers with double quotes, as key is reserved. Values compare case
+-- sensitively, except node names, which siblings may not share in any case.
+CREATE TABLE IF NOT EXISTS config_nodes (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    name VARCHAR(255) COLLATE utf8mb4_unicode_ci NOT NULL,
+    node_type VARCHAR(50) NOT NULL CHECK (node_type IN ('territory', 'center')),
+    parent_id BIGINT,
+    -- Root nodes share parent_key 0, so the sibling name index covers them,
+    -- as unique indexes let NULLs repeat
+    parent_key BIGINT AS (COALESCE(parent_id, 0)) VIRTUAL,
+    description TEXT NOT NULL,
+    labels JSON NOT NULL,
+    metadata JSON NOT NULL,
+    sort_index INTEGER NOT NULL DEFAULT 0,
+    created_at DATETIME(6) DEFAULT CURRENT_TIMESTAMP(6),
+    updated_at DATETIME(6) DEFAULT CURRENT_TIMESTAMP(6),
+    CONSTRAINT fk_config_nodes_parent FOREIGN KEY (parent_id) REFERENCES config_nodes(id) ON DELETE CASCADE,
+    UNIQUE INDEX idx_config_nodes_sibling_name (parent_key, name),
+    INDEX idx_config_nodes_sort (parent_id, sort_index, id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_bin;
+
+-- Tags are a JSON array
+CREATE TABLE IF NOT EXISTS config_properties (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    node_id BIGINT NOT NULL,
+    "key" VARCHAR(255) NOT NULL,
+    environment VARCHAR(50),
+    environment_key VARCHAR(50) AS (COALESCE(environment, '')) VIRTUAL,
+    value LONGTEXT NOT NULL,
+    data_type VARCHAR(50) NOT NULL CHECK (data_type IN ('string', 'number', 'boolean', 'object', 'array', 'null', 'flag')),
+    default_value LONGTEXT,
+    description TEXT NOT NULL,
+    encrypted BOOLEAN NOT NULL DEFAULT FALSE,
+    encryption_key_id BIGINT,
+    rollout_percentage DOUBLE CHECK (rollout_percentage >= 0 AND rollout_percentage <= 100),
+    rollout_key VARCHAR(255),
+    is_final BOOLEAN NOT NULL DEFAULT FALSE,
+    namespace VARCHAR(50),
+    tags JSON NOT NULL,
+    expires_at DATETIME(6),
+    created_at DATETIME(6) DEFAULT CURRENT_TIMESTAMP(6),
+    updated_at DATETIME(6) DEFAULT CURRENT_TIMESTAMP(6),
+    CONSTRAINT fk_config_properties_node FOREIGN KEY (node_id) REFERENCES config_nodes(id) ON DELETE CASCADE,
+    UNIQUE INDEX idx_config_properties_node_key_env (node_id, "key", environment_key),
+    INDEX idx_config_properties_key ("key")
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_bin;
+
+CREATE TABLE IF NOT EXISTS node_access (
+    node_id BIGINT PRIMARY KEY,
+    last_accessed_at DATETIME(6) NOT NULL,
+    CONSTRAINT fk_node_access_node FOREIGN KEY (node_id) REFERENCES config_nodes(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_bin;
+
+CREATE TABLE IF NOT EXISTS config_idempotency_keys (
+    principal VARCHAR(255) NOT NULL DEFAULT '',
+    idempotency_key VARCHAR(255) NOT NULL,
+    request_hash CHAR(64) NOT NULL,
+    status_code INTEGER,
+    content_type VARCHAR(255) DEFAULT '',
+    response_body LONGBLOB,
+    created_at DATETIME(6) DEFAULT CURRENT_TIMESTAMP(6),
+    PRIMARY KEY (principal, idempotency_key),
+    INDEX idx_config_idempotency_keys_created_at (created_at)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_bin;
@@ -0,0 +1,65 @@
+-- The core schema for SQLite: the tree, its properties, the delete guard and
+-- idempotency keys. The features built on the rest of the Postgres schema are
+-- not available with SQLite.
+CREATE TABLE IF NOT EXISTS config_nodes (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name VARCHAR(255) NOT NULL,
+    node_type VARCHAR(50) NOT NULL CHECK (node_type IN ('territory', 'center')),
+    parent_id INTEGER REFERENCES config_nodes(id) ON DELETE CASCADE,
+    description TEXT DEFAULT '',
+    labels TEXT NOT NULL DEFAULT '{}',
+    metadata TEXT NOT NULL DEFAULT '{}',
+    sort_index INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_config_nodes_parent_id ON config_nodes(parent_id);
+CREATE INDEX IF NOT EXISTS idx_config_nodes_sort ON config_nodes(parent_id, sort_index, id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_config_nodes_sibling_name ON config_nodes(parent_id, lower(name)) WHERE parent_id IS NOT NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_config_nodes_root_name ON config_nodes(lower(name)) WHERE parent_id IS NULL;
+
+-- Tags are a JSON array, as SQLite has no array type
+CREATE TABLE IF NOT EXISTS config_properties (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    node_id INTEGER NOT NULL REFERENCES config_nodes(id) ON DELETE CASCADE,
+    key VARCHAR(255) NOT NULL,
+    environment VARCHAR(50),
+    value TEXT NOT NULL,
+    data_type VARCHAR(50) NOT NULL CHECK (data_type IN ('string', 'number', 'boolean', 'object', 'array', 'null', 'flag')),
+    default_value TEXT,
+    description TEXT DEFAULT '',
+    encrypted BOOLEAN NOT NULL DEFAULT FALSE,
+    encryption_key_id INTEGER,
+    rollout_percentage REAL CHECK (rollout_percentage >= 0 AND rollout_percentage <= 100),
+    rollout_key VARCHAR(255),
+    is_final BOOLEAN NOT NULL DEFAULT FALSE,
+    namespace VARCHAR(50),
+    tags TEXT NOT NULL DEFAULT '[]',
+    expires_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_config_properties_node_id ON config_properties(node_id);
+CREATE INDEX IF NOT EXISTS idx_config_properties_key ON config_properties(key);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_config_properties_node_key_env
+    ON config_properties(node_id, key, COALESCE(environment, ''));
+
+CREATE TABLE IF NOT EXISTS node_access (
+    node_id INTEGER PRIMARY KEY REFERENCES config_nodes(id) ON DELETE CASCADE,
+    last_accessed_at TIMESTAMP NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS config_idempotency_keys (
+    principal VARCHAR(255) NOT NULL DEFAULT '',
+    idempotency_key VARCHAR(255) NOT NULL,
+    request_hash CHAR(64) NOT NULL,
+    status_code INTEGER,
+    content_type VARCHAR(255) DEFAULT '',
+    response_body BLOB,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (principal, idempotency_key)
+);
+
+CREATE INDEX IF NOT EXISTS idx_config_idempotency_keys_created_at ON config_idempotency_keys(created_at);
@@ -0,0 +1,18 @@
+package database
+
+import "config-manager/internal/models"
+
+// FilterNamespace returns the properties in namespace
+func FilterNamespace(properties []models.ConfigProperty, namespace string) []models.ConfigProperty {
+	filtered := []models.ConfigProperty{}
+	for _, prop := range properties {
+		if inNamespace(prop, namespace) {
+			filtered = append(filtered, prop)
+		}
+	}
+	return filtered
+}
+
+func inNamespace(prop models.ConfigProperty, namespace string) bool {
+	return prop.Namespace != nil && *prop.Namespace == namespace
+}
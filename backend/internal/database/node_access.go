@@ -0,0 +1,54 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// nodeAccessResolution limits how often a node's access time is rewritten, so
+// hot read paths cost at most one write per node per interval
+const nodeAccessResolution = time.Minute
+
+// RecordNodeAccess notes that a consumer read the resolved configuration of a node
+func (r *Repository) RecordNodeAccess(ctx context.Context, nodeID int64) error {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	now := time.Now()
+	query := `
+		INSERT INTO node_access (node_id, last_accessed_at)
+		VALUES ($1, $2)
+		ON CONFLICT (node_id)
+		DO UPDATE SET last_accessed_at = EXCLUDED.last_accessed_at
+		WHERE node_access.last_accessed_at < $3`
+
+	_, err := r.db.ExecContext(ctx, query, nodeID, now, now.Add(-nodeAccessResolution))
+	return err
+}
+
+// LastSubtreeAccess returns the most recent time a consumer read the node or any
+// of its descendants, or nil if none of them was read. Resolving a descendant
+// reads every property inherited from the node, so this covers both deleting the
+// node and deleting one of its properties.
+func (r *Repository) LastSubtreeAccess(ctx context.Context, nodeID int64) (*time.Time, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	query := `
+		WITH RECURSIVE subtree AS (
+			SELECT id FROM config_nodes WHERE id = $1
+			UNION ALL
+			SELECT n.id FROM config_nodes n JOIN subtree s ON n.parent_id = s.id
+		)
+		SELECT MAX(a.last_accessed_at) FROM node_access a JOIN subtree s ON a.node_id = s.id`
+
+	var last sql.NullTime
+	if err := r.db.QueryRowContext(ctx, query, nodeID).Scan(&last); err != nil {
+		return nil, err
+	}
+	if !last.Valid {
+		return nil, nil
+	}
+	return &last.Time, nil
+}
@@ -0,0 +1,138 @@
+package database
+
+import (
+	"config-manager/internal/models"
+	"container/list"
+	"context"
+	"sync"
+)
+
+// NodeCache keeps up to size nodes and node paths in memory in front of the
+// node lookups of a repository, evicting the least recently used. Everything
+// else goes to the repository it wraps. Entries are dropped by Invalidate when
+// a node they contain changes. Nodes that do not exist are not cached.
+type NodeCache struct {
+	ConfigRepository
+
+	size int
+
+	mu      sync.Mutex
+	entries map[nodeCacheKey]*list.Element
+	// recent lists the entries, most recently used first
+	recent *list.List
+	// generation counts invalidations, so that a lookup that was being read
+	// while one happened is not stored
+	generation uint64
+}
+
+type nodeCacheKey struct {
+	nodeID int64
+	path   bool
+}
+
+// nodeCacheEntry holds the node of a lookup, or the nodes of a path
+type nodeCacheEntry struct {
+	key   nodeCacheKey
+	nodes []models.ConfigNode
+}
+
+func NewNodeCache(repo ConfigRepository, size int) *NodeCache {
+	return &NodeCache{ConfigRepository: repo, size: size, entries: make(map[nodeCacheKey]*list.Element), recent: list.New()}
+}
+
+func (c *NodeCache) GetNodeByID(ctx context.Context, id int64) (*models.ConfigNode, error) {
+	key := nodeCacheKey{nodeID: id}
+	nodes, generation, ok := c.lookup(key)
+	if ok {
+		return &nodes[0], nil
+	}
+
+	node, err := c.ConfigRepository.GetNodeByID(ctx, id)
+	if err != nil || node == nil {
+		return node, err
+	}
+	c.store(key, []models.ConfigNode{*node}, generation)
+	return node, nil
+}
+
+func (c *NodeCache) GetNodePath(ctx context.Context, nodeID int64) ([]models.ConfigNode, error) {
+	key := nodeCacheKey{nodeID: nodeID, path: true}
+	nodes, generation, ok := c.lookup(key)
+	if ok {
+		return nodes, nil
+	}
+
+	path, err := c.ConfigRepository.GetNodePath(ctx, nodeID)
+	if err != nil || len(path) == 0 {
+		return path, err
+	}
+	c.store(key, append([]models.ConfigNode(nil), path...), generation)
+	return path, nil
+}
+
+// lookup returns a copy of the nodes cached under key, or the generation to
+// store them with once they are read
+func (c *NodeCache) lookup(key nodeCacheKey) ([]models.ConfigNode, uint64, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, ok := c.entries[key]
+	if !ok {
+		return nil, c.generation, false
+	}
+	c.recent.MoveToFront(element)
+	return append([]models.ConfigNode(nil), element.Value.(*nodeCacheEntry).nodes...), c.generation, true
+}
+
+// store caches nodes under key unless an invalidation happened since
+// generation, evicting the least recently used entry when the cache is full
+func (c *NodeCache) store(key nodeCacheKey, nodes []models.ConfigNode, generation uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.generation != generation {
+		return
+	}
+	if element, ok := c.entries[key]; ok {
+		element.Value.(*nodeCacheEntry).nodes = nodes
+		c.recent.MoveToFront(element)
+		return
+	}
+	if c.recent.Len() >= c.size {
+		c.remove(c.recent.Back())
+	}
+	c.entries[key] = c.recent.PushFront(&nodeCacheEntry{key: key, nodes: nodes})
+}
+
+func (c *NodeCache) remove(element *list.Element) {
+	c.recent.Remove(element)
+	delete(c.entries, element.Value.(*nodeCacheEntry).key)
+}
+
+// Invalidate drops the cached node nodeID and the cached paths through it
+func (c *NodeCache) Invalidate(nodeID int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.generation++
+	for element := c.recent.Front(); element != nil; {
+		next := element.Next()
+		for _, node := range element.Value.(*nodeCacheEntry).nodes {
+			if node.ID == nodeID {
+				c.remove(element)
+				break
+			}
+		}
+		element = next
+	}
+}
+
+// Reset drops every cached node and path
+func (c *NodeCache) Reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.generation++
+	clear(c.entries)
+	c.recent.Init()
+}
@@ -0,0 +1,223 @@
+package database
+
+import (
+	"config-manager/internal/models"
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+)
+
+// ErrInvalidCursor is returned for a page cursor that is not one of the children listed
+var ErrInvalidCursor = errors.New("the cursor is not a child of the node")
+
+// GetNodeWithChildren returns the node with its children and their counts, or
+// nil if it does not exist. The node and its children are loaded in one query.
+func (r *Repository) GetNodeWithChildren(ctx context.Context, id int64) (*models.ConfigNodeWithChildren, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	query := `
+		SELECT ` + prefixColumns("n", nodeColumns) + `, ` + nodeCountColumns + `
+		FROM config_nodes n WHERE n.id = $1 OR n.parent_id = $1
+		ORDER BY n.id <> $1, n.sort_index, n.id`
+
+	rows, err := r.db.QueryContext(ctx, query, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	nodes, err := scanNodesWithCounts(rows)
+	if err != nil || len(nodes) == 0 || nodes[0].ID != id {
+		return nil, err
+	}
+
+	// Counts are only set in node lists
+	node := nodes[0]
+	node.NodeCounts = nil
+	return &models.ConfigNodeWithChildren{ConfigNode: node, Children: nodes[1:]}, nil
+}
+
+// GetChildNodesPage returns up to limit children of parentID with their counts,
+// in order, starting after the child after, or with the first one when after is
+// nil. Pages are read from the sort index, however far into the list they are.
+func (r *Repository) GetChildNodesPage(ctx context.Context, parentID int64, after *int64, limit int) ([]models.ConfigNode, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	return childNodesPage(ctx, r.db, parentID, after, limit)
+}
+
+func childNodesPage(ctx context.Context, q querier, parentID int64, after *int64, limit int) ([]models.ConfigNode, error) {
+	where, args := `n.parent_id = $1`, []interface{}{parentID, limit}
+	if after != nil {
+		var sortIndex int
+		err := q.QueryRowContext(ctx, `SELECT sort_index FROM config_nodes WHERE id = $1 AND parent_id = $2`, *after, parentID).Scan(&sortIndex)
+		if err == sql.ErrNoRows {
+			return nil, ErrInvalidCursor
+		}
+		if err != nil {
+			return nil, err
+		}
+		where += ` AND (n.sort_index, n.id) > ($3, $4)`
+		args = append(args, sortIndex, *after)
+	}
+
+	query := `
+		SELECT ` + prefixColumns("n", nodeColumns) + `, ` + nodeCountColumns + `
+		FROM config_nodes n WHERE ` + where + `
+		ORDER BY n.sort_index, n.id
+		LIMIT $2`
+
+	rows, err := q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanNodesWithCounts(rows)
+}
+
+// GetNodeWithProperties returns the node with its properties, or nil if it
+// does not exist. The node and its properties are loaded in one query.
+func (r *Repository) GetNodeWithProperties(ctx context.Context, id int64) (*models.ConfigNodeWithProperties, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	query := `
+		SELECT ` + prefixColumns("n", nodeColumns) + `, ` + prefixColumns("p", propertyColumns) + `
+		FROM config_nodes n
+		LEFT JOIN config_properties p ON p.node_id = n.id
+		WHERE n.id = $1
+		ORDER BY p.key, p.environment NULLS FIRST`
+
+	rows, err := r.db.QueryContext(ctx, query, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	nodes, properties, err := r.scanNodesWithProperties(ctx, rows)
+	if err != nil || len(nodes) == 0 {
+		return nil, err
+	}
+	return &models.ConfigNodeWithProperties{ConfigNode: nodes[0], Properties: properties[id]}, nil
+}
+
+// GetSubtreeWithProperties returns the nodes of the subtree of rootID, or of
+// the whole tree when rootID is nil, parents before their children, each with
+// its properties. The properties of all nodes are loaded in one query.
+func (r *Repository) GetSubtreeWithProperties(ctx context.Context, rootID *int64) ([]models.ConfigNodeWithProperties, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	query := `
+		WITH RECURSIVE tree AS (
+			SELECT id, 0 AS depth FROM config_nodes
+			WHERE ($1::bigint IS NULL AND parent_id IS NULL) OR id = $1
+			UNION ALL
+			SELECT n.id, t.depth + 1 FROM config_nodes n JOIN tree t ON n.parent_id = t.id
+		)
+		SELECT ` + prefixColumns("n", nodeColumns) + `
+		FROM config_nodes n JOIN tree t ON n.id = t.id
+		ORDER BY t.depth, n.sort_index, n.id`
+
+	rows, err := r.db.QueryContext(ctx, query, rootID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	nodes, err := scanNodes(rows)
+	if err != nil {
+		return nil, err
+	}
+	properties, err := r.propertiesOfNodes(ctx, nodeIDs(nodes))
+	if err != nil {
+		return nil, err
+	}
+
+	subtree := make([]models.ConfigNodeWithProperties, len(nodes))
+	for i, node := range nodes {
+		subtree[i] = models.ConfigNodeWithProperties{ConfigNode: node, Properties: properties[node.ID]}
+	}
+	return subtree, nil
+}
+
+// propertiesOfNodes returns the properties of the nodes with ids by node ID
+func (r *Repository) propertiesOfNodes(ctx context.Context, ids []int64) (map[int64][]models.ConfigProperty, error) {
+	query := `
+		SELECT ` + propertyColumns + `
+		FROM config_properties WHERE node_id = ANY($1)
+		ORDER BY node_id, key, environment NULLS FIRST`
+
+	rows, err := r.db.QueryContext(ctx, query, ids)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	properties := make(map[int64][]models.ConfigProperty)
+	for rows.Next() {
+		prop, err := r.scanProperty(ctx, rows)
+		if err != nil {
+			return nil, err
+		}
+		properties[prop.NodeID] = append(properties[prop.NodeID], *prop)
+	}
+	return properties, rows.Err()
+}
+
+// scanNodesWithProperties collects the rows of a query selecting the node
+// columns followed by the property columns of a LEFT JOIN, NULL for nodes
+// without properties. Nodes are returned in the order of their first row, and
+// their properties by node ID.
+func (r *Repository) scanNodesWithProperties(ctx context.Context, rows *sql.Rows) ([]models.ConfigNode, map[int64][]models.ConfigProperty, error) {
+	nodeCount := strings.Count(nodeColumns, ",") + 1
+	total := nodeCount + strings.Count(propertyColumns, ",") + 1
+
+	var nodes []models.ConfigNode
+	properties := make(map[int64][]models.ConfigProperty)
+	for rows.Next() {
+		node, err := scanNode(columnsFrom{rows, 0, total})
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(nodes) == 0 || nodes[len(nodes)-1].ID != node.ID {
+			nodes = append(nodes, *node)
+		}
+
+		var propertyID sql.NullInt64
+		if err := (columnsFrom{rows, nodeCount, total}).Scan(&propertyID); err != nil {
+			return nil, nil, err
+		}
+		if !propertyID.Valid {
+			continue
+		}
+		prop, err := r.scanProperty(ctx, columnsFrom{rows, nodeCount, total})
+		if err != nil {
+			return nil, nil, err
+		}
+		properties[node.ID] = append(properties[node.ID], *prop)
+	}
+	return nodes, properties, rows.Err()
+}
+
+// columnsFrom scans the columns of a row starting at offset into dest and
+// discards the others, so that a row joining several tables can be scanned
+// one table at a time
+type columnsFrom struct {
+	row    rowScanner
+	offset int
+	total  int
+}
+
+func (c columnsFrom) Scan(dest ...interface{}) error {
+	all := make([]interface{}, c.total)
+	for i := range all {
+		all[i] = new(interface{})
+	}
+	copy(all[c.offset:], dest)
+	return c.row.Scan(all...)
+}
@@ -0,0 +1,83 @@
+package database
+
+import (
+	"config-manager/internal/models"
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ErrInvalidOrder is returned when a reorder does not list every sibling once
+var ErrInvalidOrder = errors.New("the order must list every child exactly once")
+
+// nextSortIndex returns an SQL expression for the sort index placing a new
+// node after the children of the parent ID given by param
+func nextSortIndex(param string) string {
+	return `(SELECT COALESCE(MAX(sort_index) + 1, 0) FROM config_nodes WHERE parent_id IS NOT DISTINCT FROM ` + param + `)`
+}
+
+// ReorderChildren sets the order of the children of parentID, or of the root
+// nodes when parentID is nil, to that of ids, which must list each of them
+// once. It returns the children in their new order.
+func (r *Repository) ReorderChildren(ctx context.Context, parentID *int64, ids []int64) ([]models.ConfigNode, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, `SELECT id FROM config_nodes WHERE parent_id IS NOT DISTINCT FROM $1 FOR UPDATE`, parentID)
+	if err != nil {
+		return nil, err
+	}
+	children := make(map[int64]bool)
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		children[id] = true
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(ids) != len(children) {
+		return nil, fmt.Errorf("%w: got %d IDs for %d children", ErrInvalidOrder, len(ids), len(children))
+	}
+	listed := make(map[int64]bool, len(ids))
+	for _, id := range ids {
+		if !children[id] {
+			return nil, fmt.Errorf("%w: node %d is not a child", ErrInvalidOrder, id)
+		}
+		if listed[id] {
+			return nil, fmt.Errorf("%w: node %d is listed twice", ErrInvalidOrder, id)
+		}
+		listed[id] = true
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE config_nodes n SET sort_index = o.position - 1
+		FROM unnest($1::bigint[]) WITH ORDINALITY AS o(id, position)
+		WHERE n.id = o.id AND n.sort_index <> o.position - 1`, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err = tx.QueryContext(ctx, `SELECT `+nodeColumns+` FROM config_nodes WHERE parent_id IS NOT DISTINCT FROM $1 ORDER BY sort_index, id`, parentID)
+	if err != nil {
+		return nil, err
+	}
+	nodes, err := scanNodes(rows)
+	rows.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	return nodes, tx.Commit()
+}
@@ -0,0 +1,98 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strconv"
+	"time"
+)
+
+// InvalidationChannel is the PostgreSQL notification channel config.invalidated
+// events are announced on, with the ID of the node as payload
+const InvalidationChannel = "config_invalidated"
+
+// InvalidatedCache is a cache of configuration that ListenInvalidations keeps
+// up to date
+type InvalidatedCache interface {
+	// Invalidate drops what is cached about nodeID and its descendants
+	Invalidate(nodeID int64)
+	// Reset drops everything
+	Reset()
+}
+
+// listenRetryInterval is how long to wait before listening again after the
+// connection was lost
+const listenRetryInterval = 5 * time.Second
+
+// ErrNotListening is reported while ListenInvalidations is not connected
+var ErrNotListening = errors.New("not listening for invalidations")
+
+// CheckInvalidations returns ErrNotListening unless ListenInvalidations is
+// listening, as caches miss the changes of other servers meanwhile. Databases
+// other than PostgreSQL have nothing to listen to and always pass.
+func (db *DB) CheckInvalidations(ctx context.Context) error {
+	if db.pool != nil && !db.listening.Load() {
+		return ErrNotListening
+	}
+	return nil
+}
+
+// ListenInvalidations invalidates the node of every config.invalidated event
+// committed by any server in caches, until ctx is cancelled. Notifications sent
+// while the connection is down are lost, so the caches are reset each time the
+// listener starts, before the first notification. It holds a connection of its
+// own and returns immediately on databases other than PostgreSQL.
+func (db *DB) ListenInvalidations(ctx context.Context, caches ...InvalidatedCache) {
+	if db.pool == nil {
+		return
+	}
+
+	for {
+		err := db.listenInvalidations(ctx, caches)
+		if ctx.Err() != nil {
+			return
+		}
+		log.Printf("Stopped listening for invalidations, retrying in %s: %v", listenRetryInterval, err)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(listenRetryInterval):
+		}
+	}
+}
+
+func (db *DB) listenInvalidations(ctx context.Context, caches []InvalidatedCache) error {
+	pooled, err := db.pool.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	// A listening connection cannot go back to the pool
+	conn := pooled.Hijack()
+	defer conn.Close(context.Background())
+
+	if _, err := conn.Exec(ctx, "LISTEN "+InvalidationChannel); err != nil {
+		return err
+	}
+	for _, cache := range caches {
+		cache.Reset()
+	}
+	db.listening.Store(true)
+	defer db.listening.Store(false)
+
+	for {
+		notification, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+		nodeID, err := strconv.ParseInt(notification.Payload, 10, 64)
+		for _, cache := range caches {
+			if err != nil {
+				cache.Reset()
+			} else {
+				cache.Invalidate(nodeID)
+			}
+		}
+	}
+}
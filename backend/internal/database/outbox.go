@@ -0,0 +1,183 @@
+package database
+
+import (
+	"config-manager/internal/events"
+	"config-manager/internal/models"
+	"context"
+	"database/sql"
+	"time"
+)
+
+// outboxLockID is the advisory lock held while dispatching, so that only one
+// server publishes at a time and events leave in the order they were committed
+const outboxLockID = 7305693462816140001
+
+// feedLockID is the advisory lock held while assigning feed positions
+const feedLockID = 7305693462816140002
+
+const outboxColumns = `event_id, type, node_id, property_id, key, environment, source, occurred_at`
+
+func scanOutboxEvent(row rowScanner, dest ...interface{}) (events.Event, error) {
+	var key sql.NullString
+	event := events.Event{SchemaVersion: events.SchemaVersion}
+	err := row.Scan(append(dest, &event.ID, &event.Type, &event.NodeID, &event.PropertyID, &key, &event.Environment, &event.Source, &event.OccurredAt)...)
+	if err != nil {
+		return event, err
+	}
+	event.Key = key.String
+	event.OccurredAt = event.OccurredAt.UTC()
+	return event, nil
+}
+
+// DispatchOutbox passes up to limit pending change events to publish, in feed
+// order (see SequenceOutbox), and marks them published once it returns nil.
+// If publish fails the events stay pending and are passed again on the next
+// call. It returns the number of events published, which is zero when another
+// server holds the dispatch lock.
+func (r *Repository) DispatchOutbox(ctx context.Context, limit int, publish func([]events.Event) error) (int, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var locked bool
+	if err := tx.QueryRowContext(ctx, `SELECT pg_try_advisory_xact_lock($1)`, int64(outboxLockID)).Scan(&locked); err != nil {
+		return 0, err
+	}
+	if !locked {
+		return 0, nil
+	}
+
+	rows, err := tx.QueryContext(ctx, `
+		SELECT id, `+outboxColumns+`
+		FROM event_outbox
+		WHERE published_at IS NULL AND position IS NOT NULL
+		ORDER BY position
+		LIMIT $1`, limit)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	var ids []int64
+	pending := []events.Event{}
+	for rows.Next() {
+		var id int64
+		event, err := scanOutboxEvent(rows, &id)
+		if err != nil {
+			return 0, err
+		}
+		ids = append(ids, id)
+		pending = append(pending, event)
+	}
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+	rows.Close()
+
+	if len(pending) == 0 {
+		return 0, nil
+	}
+	if err := publish(pending); err != nil {
+		return 0, err
+	}
+
+	_, err = tx.ExecContext(ctx, `UPDATE event_outbox SET published_at = $1 WHERE id = ANY($2)`, time.Now(), ids)
+	if err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return len(pending), nil
+}
+
+// SequenceOutbox assigns feed positions to committed events in outbox order
+// and returns how many it assigned, zero when another server holds the lock
+func (r *Repository) SequenceOutbox(ctx context.Context) (int64, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var locked bool
+	if err := tx.QueryRowContext(ctx, `SELECT pg_try_advisory_xact_lock($1)`, int64(feedLockID)).Scan(&locked); err != nil {
+		return 0, err
+	}
+	if !locked {
+		return 0, nil
+	}
+
+	// nextval is evaluated as the ordered rows are read
+	result, err := tx.ExecContext(ctx, `
+		UPDATE event_outbox e SET position = p.position
+		FROM (
+			SELECT id, nextval('event_feed_position') AS position
+			FROM (SELECT id FROM event_outbox WHERE position IS NULL ORDER BY id) ordered
+		) p
+		WHERE e.id = p.id`)
+	if err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// GetChanges returns up to limit events with a feed position after since, in
+// feed order
+func (r *Repository) GetChanges(ctx context.Context, since int64, limit int) ([]models.Change, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT position, `+outboxColumns+`
+		FROM event_outbox
+		WHERE position > $1
+		ORDER BY position
+		LIMIT $2`, since, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	changes := []models.Change{}
+	for rows.Next() {
+		var change models.Change
+		change.Event, err = scanOutboxEvent(rows, &change.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		changes = append(changes, change)
+	}
+	return changes, rows.Err()
+}
+
+// OldestChangeCursor returns the feed position of the oldest retained event,
+// or nil if there is none
+func (r *Repository) OldestChangeCursor(ctx context.Context) (*int64, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	var oldest sql.NullInt64
+	if err := r.db.QueryRowContext(ctx, `SELECT MIN(position) FROM event_outbox`).Scan(&oldest); err != nil || !oldest.Valid {
+		return nil, err
+	}
+	return &oldest.Int64, nil
+}
+
+// PurgeOutbox deletes events published before the given time. They leave the
+// change feed too, so the retention bounds how far back integrators can replay.
+func (r *Repository) PurgeOutbox(ctx context.Context, before time.Time) (int64, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	result, err := r.db.ExecContext(ctx, `DELETE FROM event_outbox WHERE published_at < $1 AND position IS NOT NULL`, before)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
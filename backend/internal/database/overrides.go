@@ -0,0 +1,176 @@
+package database
+
+import (
+	"config-manager/internal/models"
+	"context"
+	"encoding/json"
+	"sort"
+	"strings"
+)
+
+// GetOverrides lists every key defined within the subtree of nodeID that an
+// ancestor of the defining node already defines, with the chain of
+// definitions from the root down. With an environment, its overrides take the
+// place of the values for all environments, as when resolving.
+func (r *Repository) GetOverrides(ctx context.Context, nodeID int64, environment string) (*models.OverrideReport, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	path, children, ids, err := r.getPathAndSubtree(ctx, nodeID)
+	if err != nil {
+		return nil, err
+	}
+
+	properties, err := r.getPropertiesOf(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &models.OverrideReport{NodeID: nodeID, Environment: environment, Overrides: []models.Override{}}
+
+	// visit extends the chains of the keys a node defines, and reports the
+	// overrides of nodes within the subtree
+	visit := func(node models.ConfigNode, names []string, chains map[string][]models.OverrideLink, locked map[string]bool, reporting bool) (map[string][]models.OverrideLink, map[string]bool) {
+		extended := make(map[string][]models.OverrideLink, len(chains))
+		for key, chain := range chains {
+			extended[key] = chain
+		}
+		nodeLocked := make(map[string]bool, len(locked))
+		for key := range locked {
+			nodeLocked[key] = true
+		}
+
+		// The last applicable definition of a key on the node wins
+		winners := make(map[string]models.ConfigProperty)
+		var keys []string
+		for _, prop := range forEnvironment(properties[node.ID], environment) {
+			if _, ok := winners[prop.Key]; !ok {
+				keys = append(keys, prop.Key)
+			}
+			winners[prop.Key] = prop
+		}
+
+		for _, key := range keys {
+			prop := winners[key]
+			link := overrideLink(node, prop)
+			chain := chains[key]
+			if reporting && len(chain) > 0 {
+				report.Overrides = append(report.Overrides, models.Override{
+					Key:     key,
+					NodeID:  node.ID,
+					Path:    "/" + strings.Join(names, "/"),
+					Chain:   append(chain[:len(chain):len(chain)], link),
+					Ignored: locked[key],
+				})
+			}
+			if locked[key] {
+				continue
+			}
+			extended[key] = append(chain[:len(chain):len(chain)], link)
+			if prop.IsFinal {
+				nodeLocked[key] = true
+			}
+		}
+		return extended, nodeLocked
+	}
+
+	chains := make(map[string][]models.OverrideLink)
+	locked := make(map[string]bool)
+	names := make([]string, 0, len(path))
+	for _, node := range path[:len(path)-1] {
+		names = append(names, node.Name)
+		chains, locked = visit(node, names, chains, locked, false)
+	}
+
+	var walk func(node models.ConfigNode, names []string, chains map[string][]models.OverrideLink, locked map[string]bool)
+	walk = func(node models.ConfigNode, names []string, chains map[string][]models.OverrideLink, locked map[string]bool) {
+		names = append(names[:len(names):len(names)], node.Name)
+		chains, locked = visit(node, names, chains, locked, true)
+		for _, child := range children[node.ID] {
+			walk(child, names, chains, locked)
+		}
+	}
+	walk(path[len(path)-1], names, chains, locked)
+
+	sort.SliceStable(report.Overrides, func(i, j int) bool {
+		a, b := report.Overrides[i], report.Overrides[j]
+		if a.Key != b.Key {
+			return a.Key < b.Key
+		}
+		return a.Path < b.Path
+	})
+	return report, nil
+}
+
+// getPathAndSubtree returns the path from the root to nodeID, the children of
+// every node in its subtree, and the IDs of all of those nodes. It returns
+// ErrNodeNotFound if the node does not exist.
+func (r *Repository) getPathAndSubtree(ctx context.Context, nodeID int64) ([]models.ConfigNode, map[int64][]models.ConfigNode, []int64, error) {
+	path, err := r.GetNodePath(ctx, nodeID)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if len(path) == 0 {
+		return nil, nil, nil, ErrNodeNotFound
+	}
+
+	subtree, err := r.getSubtree(ctx, nodeID)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	ids := make([]int64, 0, len(path)+len(subtree))
+	for _, node := range path[:len(path)-1] {
+		ids = append(ids, node.ID)
+	}
+	children := make(map[int64][]models.ConfigNode)
+	for _, node := range subtree {
+		ids = append(ids, node.ID)
+		if node.ID != nodeID {
+			children[*node.ParentID] = append(children[*node.ParentID], node)
+		}
+	}
+	return path, children, ids, nil
+}
+
+// getPropertiesOf returns the properties of the given nodes, keyed by node
+func (r *Repository) getPropertiesOf(ctx context.Context, ids []int64) (map[int64][]models.ConfigProperty, error) {
+	query := `
+		SELECT ` + propertyColumns + `
+		FROM config_properties WHERE node_id = ANY($1)
+		ORDER BY node_id, key, environment NULLS FIRST`
+
+	rows, err := r.db.QueryContext(ctx, query, ids)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	properties := make(map[int64][]models.ConfigProperty)
+	for rows.Next() {
+		prop, err := r.scanProperty(ctx, rows)
+		if err != nil {
+			return nil, err
+		}
+		properties[prop.NodeID] = append(properties[prop.NodeID], *prop)
+	}
+	return properties, rows.Err()
+}
+
+// overrideLink describes the definition of a key on a node, leaving out the
+// value of encrypted properties
+func overrideLink(node models.ConfigNode, prop models.ConfigProperty) models.OverrideLink {
+	link := models.OverrideLink{
+		NodeID:      node.ID,
+		NodeName:    node.Name,
+		PropertyID:  prop.ID,
+		Environment: prop.Environment,
+		Encrypted:   prop.Encrypted,
+	}
+	if !prop.Encrypted {
+		if err := json.Unmarshal([]byte(prop.Value), &link.Value); err != nil {
+			link.Value = prop.Value
+		}
+	}
+	return link
+}
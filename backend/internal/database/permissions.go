@@ -0,0 +1,130 @@
+package database
+
+import (
+	"config-manager/internal/models"
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+var ErrPermissionNotFound = errors.New("permission not found")
+
+const nodePermissionColumns = `id, node_id, principal, permission, created_at`
+
+func scanNodePermission(row rowScanner) (*models.NodePermission, error) {
+	var perm models.NodePermission
+	if err := row.Scan(&perm.ID, &perm.NodeID, &perm.Principal, &perm.Permission, &perm.CreatedAt); err != nil {
+		return nil, err
+	}
+	return &perm, nil
+}
+
+// GrantNodePermission grants a principal access to a subtree, replacing any
+// permission the principal already had on that node
+func (r *Repository) GrantNodePermission(ctx context.Context, nodeID int64, req models.GrantPermissionRequest) (*models.NodePermission, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	query := `
+		INSERT INTO node_permissions (node_id, principal, permission, created_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (node_id, principal)
+		DO UPDATE SET permission = EXCLUDED.permission
+		RETURNING ` + nodePermissionColumns
+
+	return scanNodePermission(r.db.QueryRowContext(ctx, query, nodeID, req.Principal, req.Permission, time.Now()))
+}
+
+func (r *Repository) GetNodePermissions(ctx context.Context, nodeID int64) ([]models.NodePermission, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	query := `SELECT ` + nodePermissionColumns + ` FROM node_permissions WHERE node_id = $1 ORDER BY principal`
+
+	rows, err := r.db.QueryContext(ctx, query, nodeID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	perms := []models.NodePermission{}
+	for rows.Next() {
+		perm, err := scanNodePermission(rows)
+		if err != nil {
+			return nil, err
+		}
+		perms = append(perms, *perm)
+	}
+
+	return perms, nil
+}
+
+func (r *Repository) RevokeNodePermission(ctx context.Context, id int64) error {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	result, err := r.db.ExecContext(ctx, `DELETE FROM node_permissions WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrPermissionNotFound
+	}
+
+	return nil
+}
+
+// HasNodePermission reports whether any of the principals was granted perm (or
+// a permission implying it) on the node or one of its ancestors
+func (r *Repository) HasNodePermission(ctx context.Context, nodeID int64, principals []string, perm models.Permission) (bool, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	if len(principals) == 0 {
+		return false, nil
+	}
+
+	permissions := []string{string(models.PermissionWrite)}
+	if perm == models.PermissionRead {
+		permissions = append(permissions, string(models.PermissionRead))
+	}
+
+	query := `
+		WITH RECURSIVE ancestors AS (
+			SELECT id, parent_id FROM config_nodes WHERE id = $1
+			UNION ALL
+			SELECT n.id, n.parent_id FROM config_nodes n JOIN ancestors a ON n.id = a.parent_id
+		)
+		SELECT EXISTS(
+			SELECT 1 FROM node_permissions p
+			JOIN ancestors a ON p.node_id = a.id
+			WHERE p.principal = ANY($2) AND p.permission = ANY($3)
+		)`
+
+	var allowed bool
+	err := r.db.QueryRowContext(ctx, query, nodeID, principals, permissions).Scan(&allowed)
+	return allowed, err
+}
+
+// GetPropertyNodeID returns the node a property belongs to, or nil if the property does not exist
+func (r *Repository) GetPropertyNodeID(ctx context.Context, propertyID int64) (*int64, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	var nodeID int64
+	err := r.db.QueryRowContext(ctx, `SELECT node_id FROM config_properties WHERE id = $1`, propertyID).Scan(&nodeID)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &nodeID, nil
+}
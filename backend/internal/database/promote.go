@@ -0,0 +1,158 @@
+package database
+
+import (
+	"config-manager/internal/flags"
+	"config-manager/internal/models"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"time"
+)
+
+var (
+	// ErrPromoteRootProperty is returned when promoting a property of a root node
+	ErrPromoteRootProperty = errors.New("the property is defined on a root node, which has no parent")
+
+	// ErrPromoteEncrypted is returned when promoting an encrypted property, whose
+	// value is encrypted with the key of the node's subtree
+	ErrPromoteEncrypted = errors.New("encrypted properties cannot be promoted")
+
+	// ErrParentDefinesKey is returned when the parent already defines the key
+	// for the environment of the promoted property
+	ErrParentDefinesKey = errors.New("the parent already defines this key")
+)
+
+// PromoteProperty moves a property to the parent of its node in one
+// transaction. With removeIdentical, the properties of the siblings defining
+// the same value for the key and environment are deleted as well, as they now
+// inherit it. It returns nil if the property does not exist.
+func (r *Repository) PromoteProperty(ctx context.Context, propertyID int64, removeIdentical bool) (*models.PromotePropertyResult, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	prop, err := r.scanProperty(ctx, tx.QueryRowContext(ctx, `SELECT `+propertyColumns+` FROM config_properties WHERE id = $1 FOR UPDATE`, propertyID))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if prop.Encrypted {
+		return nil, ErrPromoteEncrypted
+	}
+
+	var parentID *int64
+	if err := tx.QueryRowContext(ctx, `SELECT parent_id FROM config_nodes WHERE id = $1`, prop.NodeID).Scan(&parentID); err != nil {
+		return nil, err
+	}
+	if parentID == nil {
+		return nil, ErrPromoteRootProperty
+	}
+
+	var defined bool
+	err = tx.QueryRowContext(ctx, `
+		SELECT EXISTS (SELECT 1 FROM config_properties WHERE node_id = $1 AND key = $2 AND environment IS NOT DISTINCT FROM $3)`,
+		*parentID, prop.Key, prop.Environment).Scan(&defined)
+	if err != nil {
+		return nil, err
+	}
+	if defined {
+		return nil, ErrParentDefinesKey
+	}
+
+	now := time.Now()
+	promoted, err := r.scanProperty(ctx, tx.QueryRowContext(ctx, `
+		INSERT INTO config_properties (node_id, key, environment, value, data_type, default_value, description, encrypted, encryption_key_id, rollout_percentage, rollout_key, is_final, namespace, tags, expires_at, created_at, updated_at)
+		SELECT $1, key, environment, value, data_type, default_value, description, encrypted, encryption_key_id, rollout_percentage, rollout_key, is_final, namespace, tags, expires_at, $2, $2
+		FROM config_properties WHERE id = $3
+		RETURNING `+propertyColumns, *parentID, now, propertyID))
+	if err != nil {
+		return nil, err
+	}
+
+	result := &models.PromotePropertyResult{Property: *promoted, RemovedPropertyIDs: []int64{propertyID}, NowInheriting: []int64{}}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM config_properties WHERE id = $1`, propertyID); err != nil {
+		return nil, err
+	}
+
+	if removeIdentical {
+		rows, err := tx.QueryContext(ctx, `
+			SELECT `+prefixColumns("p", propertyColumns)+`
+			FROM config_properties p JOIN config_nodes n ON n.id = p.node_id
+			WHERE n.parent_id = $1 AND p.key = $2 AND p.environment IS NOT DISTINCT FROM $3
+			ORDER BY p.id`, *parentID, prop.Key, prop.Environment)
+		if err != nil {
+			return nil, err
+		}
+		var identical []int64
+		for rows.Next() {
+			sibling, err := r.scanProperty(ctx, rows)
+			if err != nil {
+				rows.Close()
+				return nil, err
+			}
+			if SameValue(*prop, *sibling) {
+				identical = append(identical, sibling.ID)
+			}
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+
+		for _, id := range identical {
+			if _, err := tx.ExecContext(ctx, `DELETE FROM config_properties WHERE id = $1`, id); err != nil {
+				return nil, err
+			}
+			result.RemovedPropertyIDs = append(result.RemovedPropertyIDs, id)
+		}
+	}
+
+	// Children defining the key for all environments, or for the property's
+	// environment, keep their own value
+	rows, err := tx.QueryContext(ctx, `
+		SELECT n.id FROM config_nodes n
+		WHERE n.parent_id = $1 AND NOT EXISTS (
+			SELECT 1 FROM config_properties p
+			WHERE p.node_id = n.id AND p.key = $2 AND (p.environment IS NULL OR p.environment IS NOT DISTINCT FROM $3)
+		)
+		ORDER BY n.sort_index, n.id`, *parentID, prop.Key, prop.Environment)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		result.NowInheriting = append(result.NowInheriting, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return result, tx.Commit()
+}
+
+// SameValue reports whether two plain properties hold the same typed value.
+// Encrypted properties and rollouts are never considered the same.
+func SameValue(a, b models.ConfigProperty) bool {
+	if a.Encrypted || b.Encrypted || flags.HasRollout(a) || flags.HasRollout(b) || a.DataType != b.DataType {
+		return false
+	}
+	var left, right interface{}
+	if json.Unmarshal([]byte(a.Value), &left) != nil || json.Unmarshal([]byte(b.Value), &right) != nil {
+		return a.Value == b.Value
+	}
+	return reflect.DeepEqual(left, right)
+}
@@ -0,0 +1,55 @@
+package database
+
+import (
+	"config-manager/internal/models"
+	"context"
+)
+
+// pathsCTE defines paths, the IDs and paths of the nodes in the subtree of $1,
+// or of the whole tree when $1 is NULL
+const pathsCTE = `
+		WITH RECURSIVE paths AS (
+			SELECT id, '/' || name AS path FROM config_nodes
+			WHERE ($1::bigint IS NULL AND parent_id IS NULL) OR id = $1
+			UNION ALL
+			SELECT n.id, p.path || '/' || n.name FROM config_nodes n JOIN paths p ON n.parent_id = p.id
+		)`
+
+// searchProperties returns the properties matching condition, within the
+// subtree of rootID or the whole tree when rootID is nil, ordered by the path
+// of their node. The condition refers to the properties as c, and to args from
+// $2 on.
+func (r *Repository) searchProperties(ctx context.Context, rootID *int64, condition string, args ...interface{}) ([]models.PropertySearchResult, error) {
+	query := pathsCTE + `
+		SELECT ` + prefixColumns("c", propertyColumns) + `, p.path
+		FROM config_properties c JOIN paths p ON c.node_id = p.id
+		WHERE ` + condition + `
+		ORDER BY p.path, c.key, c.environment NULLS FIRST`
+
+	rows, err := r.db.QueryContext(ctx, query, append([]interface{}{rootID}, args...)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	results := []models.PropertySearchResult{}
+	for rows.Next() {
+		var path string
+		prop, err := r.scanProperty(ctx, withPath{rows, &path})
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, models.PropertySearchResult{ConfigProperty: *prop, Path: path})
+	}
+	return results, rows.Err()
+}
+
+// withPath scans the property columns of a row followed by the path of its node
+type withPath struct {
+	row  rowScanner
+	path *string
+}
+
+func (w withPath) Scan(dest ...interface{}) error {
+	return w.row.Scan(append(dest, w.path)...)
+}
@@ -0,0 +1,101 @@
+package database
+
+import (
+	"config-manager/internal/models"
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+var (
+	// ErrPushDownLeaf is returned when pushing down a property of a node without children
+	ErrPushDownLeaf = errors.New("the node has no children to push the property down to")
+
+	// ErrPushDownFinal is returned when pushing down a final property. Its lock
+	// ignores the children's own definitions, which would take effect again.
+	ErrPushDownFinal = errors.New("final properties cannot be pushed down, unset is_final first")
+)
+
+// PushDownProperty replaces a property with copies on each direct child of its
+// node in one transaction, so that the children can diverge. Children that
+// define the key for all environments or for the property's environment
+// already resolve their own value and get no copy. It returns nil if the
+// property does not exist.
+func (r *Repository) PushDownProperty(ctx context.Context, propertyID int64) (*models.PushDownPropertyResult, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	prop, err := r.scanProperty(ctx, tx.QueryRowContext(ctx, `SELECT `+propertyColumns+` FROM config_properties WHERE id = $1 FOR UPDATE`, propertyID))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if prop.IsFinal {
+		return nil, ErrPushDownFinal
+	}
+
+	rows, err := tx.QueryContext(ctx, `
+		SELECT n.id, EXISTS (
+			SELECT 1 FROM config_properties p
+			WHERE p.node_id = n.id AND p.key = $2 AND (p.environment IS NULL OR p.environment IS NOT DISTINCT FROM $3)
+		)
+		FROM config_nodes n WHERE n.parent_id = $1
+		ORDER BY n.sort_index, n.id`, prop.NodeID, prop.Key, prop.Environment)
+	if err != nil {
+		return nil, err
+	}
+	var receiving []int64
+	result := &models.PushDownPropertyResult{RemovedPropertyID: propertyID, Created: []models.ConfigProperty{}, Kept: []int64{}}
+	children := 0
+	for rows.Next() {
+		var childID int64
+		var defines bool
+		if err := rows.Scan(&childID, &defines); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		children++
+		if defines {
+			result.Kept = append(result.Kept, childID)
+		} else {
+			receiving = append(receiving, childID)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if children == 0 {
+		return nil, ErrPushDownLeaf
+	}
+
+	// Encrypted values are copied as they are, still decryptable with the
+	// subtree key they reference
+	now := time.Now()
+	for _, childID := range receiving {
+		created, err := r.scanProperty(ctx, tx.QueryRowContext(ctx, `
+			INSERT INTO config_properties (node_id, key, environment, value, data_type, default_value, description, encrypted, encryption_key_id, rollout_percentage, rollout_key, is_final, namespace, tags, expires_at, created_at, updated_at)
+			SELECT $1, key, environment, value, data_type, default_value, description, encrypted, encryption_key_id, rollout_percentage, rollout_key, is_final, namespace, tags, expires_at, $2, $2
+			FROM config_properties WHERE id = $3
+			RETURNING `+propertyColumns, childID, now, propertyID))
+		if err != nil {
+			return nil, err
+		}
+		result.Created = append(result.Created, *created)
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM config_properties WHERE id = $1`, propertyID); err != nil {
+		return nil, err
+	}
+
+	return result, tx.Commit()
+}
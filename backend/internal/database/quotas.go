@@ -0,0 +1,175 @@
+package database
+
+import (
+	"config-manager/internal/models"
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// SQLStateQuotaExceeded is raised by the triggers enforcing organization quotas
+const SQLStateQuotaExceeded = "CQ001"
+
+var (
+	ErrQuotaNotFound = errors.New("quota not found")
+	ErrNotRootNode   = errors.New("quotas apply to organizations, the subtrees of root nodes")
+)
+
+const quotaColumns = `root_node_id, max_nodes, max_properties_per_node, max_depth, requests_per_minute, updated_at`
+
+func scanQuota(row rowScanner) (*models.OrganizationQuota, error) {
+	var quota models.OrganizationQuota
+	err := row.Scan(&quota.RootNodeID, &quota.MaxNodes, &quota.MaxPropertiesPerNode, &quota.MaxDepth, &quota.RequestsPerMinute, &quota.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &quota, nil
+}
+
+// GetQuotas returns the default quota, if set, followed by the quotas of
+// organizations
+func (r *Repository) GetQuotas(ctx context.Context) ([]models.OrganizationQuota, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := r.db.QueryContext(ctx, `SELECT `+quotaColumns+` FROM organization_quotas ORDER BY root_node_id NULLS FIRST`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	quotas := []models.OrganizationQuota{}
+	for rows.Next() {
+		quota, err := scanQuota(rows)
+		if err != nil {
+			return nil, err
+		}
+		quotas = append(quotas, *quota)
+	}
+	return quotas, rows.Err()
+}
+
+// SetQuota replaces the quota of the organization rooted at rootNodeID, or the
+// default quota when rootNodeID is nil. Existing data over a lowered limit is
+// kept; only writes growing past it fail.
+func (r *Repository) SetQuota(ctx context.Context, rootNodeID *int64, req models.SetQuotaRequest) (*models.OrganizationQuota, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	if rootNodeID != nil {
+		var parentID sql.NullInt64
+		err := r.db.QueryRowContext(ctx, `SELECT parent_id FROM config_nodes WHERE id = $1`, *rootNodeID).Scan(&parentID)
+		if err == sql.ErrNoRows {
+			return nil, ErrNodeNotFound
+		}
+		if err != nil {
+			return nil, err
+		}
+		if parentID.Valid {
+			return nil, ErrNotRootNode
+		}
+	}
+
+	return scanQuota(r.db.QueryRowContext(ctx, `
+		INSERT INTO organization_quotas (root_node_id, max_nodes, max_properties_per_node, max_depth, requests_per_minute, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT ((COALESCE(root_node_id, 0)))
+		DO UPDATE SET
+			max_nodes = EXCLUDED.max_nodes,
+			max_properties_per_node = EXCLUDED.max_properties_per_node,
+			max_depth = EXCLUDED.max_depth,
+			requests_per_minute = EXCLUDED.requests_per_minute,
+			updated_at = EXCLUDED.updated_at
+		RETURNING `+quotaColumns,
+		rootNodeID, req.MaxNodes, req.MaxPropertiesPerNode, req.MaxDepth, req.RequestsPerMinute, time.Now()))
+}
+
+// DeleteQuota removes the quota of the organization rooted at rootNodeID, or
+// the default quota when rootNodeID is nil
+func (r *Repository) DeleteQuota(ctx context.Context, rootNodeID *int64) error {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	result, err := r.db.ExecContext(ctx, `DELETE FROM organization_quotas WHERE COALESCE(root_node_id, 0) = COALESCE($1, 0)`, rootNodeID)
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrQuotaNotFound
+	}
+	return nil
+}
+
+// GetRootNodeID returns the root of the tree nodeID belongs to, or nil if the
+// node does not exist
+func (r *Repository) GetRootNodeID(ctx context.Context, nodeID int64) (*int64, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	query := `
+		WITH RECURSIVE ancestors AS (
+			SELECT id, parent_id FROM config_nodes WHERE id = $1
+			UNION ALL
+			SELECT n.id, n.parent_id FROM config_nodes n JOIN ancestors a ON n.id = a.parent_id
+		)
+		SELECT id FROM ancestors WHERE parent_id IS NULL`
+
+	var rootID int64
+	err := r.db.QueryRowContext(ctx, query, nodeID).Scan(&rootID)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &rootID, nil
+}
+
+// GetOrganizationUsage returns the usage of the organization nodeID belongs to
+// against its quota, or nil if the node does not exist. Requests are counted
+// by the server handling them, so RequestsPerMinute is left for the caller.
+func (r *Repository) GetOrganizationUsage(ctx context.Context, nodeID int64) (*models.OrganizationUsage, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	query := `
+		WITH RECURSIVE ancestors AS (
+			SELECT id, parent_id FROM config_nodes WHERE id = $1
+			UNION ALL
+			SELECT n.id, n.parent_id FROM config_nodes n JOIN ancestors a ON n.id = a.parent_id
+		),
+		organization AS (
+			SELECT id, 1 AS depth FROM ancestors WHERE parent_id IS NULL
+			UNION ALL
+			SELECT n.id, o.depth + 1 FROM config_nodes n JOIN organization o ON n.parent_id = o.id
+		)
+		SELECT root.id, root.name,
+			(SELECT COUNT(*) FROM organization),
+			(SELECT MAX(depth) FROM organization),
+			(SELECT COALESCE(MAX(properties), 0) FROM (
+				SELECT COUNT(*) AS properties FROM config_properties p JOIN organization o ON p.node_id = o.id GROUP BY p.node_id
+			) per_node),
+			l.max_nodes, l.max_properties_per_node, l.max_depth, l.requests_per_minute
+		FROM config_nodes root
+		CROSS JOIN organization_limits(root.id) l
+		WHERE root.id = (SELECT id FROM ancestors WHERE parent_id IS NULL)`
+
+	var usage models.OrganizationUsage
+	err := r.db.QueryRowContext(ctx, query, nodeID).Scan(
+		&usage.RootNodeID, &usage.RootNodeName,
+		&usage.Nodes.Used, &usage.Depth.Used, &usage.PropertiesPerNode.Used,
+		&usage.Nodes.Limit, &usage.PropertiesPerNode.Limit, &usage.Depth.Limit, &usage.RequestsPerMinute.Limit,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &usage, nil
+}
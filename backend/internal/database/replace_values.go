@@ -0,0 +1,138 @@
+package database
+
+import (
+	"bytes"
+	"config-manager/internal/models"
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+)
+
+// ErrReplacementStale is returned when a property changed between planning
+// and applying a value replacement
+var ErrReplacementStale = errors.New("a property changed since the replacement was previewed")
+
+// AuditActionValueReplace is the audit log action of a value replacement
+const AuditActionValueReplace = "value.replace"
+
+// PlanValueReplacement applies replace to the strings in the values of the
+// properties within the subtree of rootID, or only those of key when key is
+// not empty, and returns the properties whose value would change. Strings
+// nested in objects and arrays are replaced too, object keys are not.
+// Encrypted properties and values of other types are left out.
+func (r *Repository) PlanValueReplacement(ctx context.Context, rootID int64, key string, replace func(string) string) ([]models.ValueReplacement, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	results, err := r.searchProperties(ctx, &rootID,
+		`NOT c.encrypted AND c.data_type IN ('string', 'object', 'array') AND ($2 = '' OR c.key = $2)`, key)
+	if err != nil {
+		return nil, err
+	}
+
+	replacements := []models.ValueReplacement{}
+	for _, result := range results {
+		after, changed, err := replaceStrings(result.Value, replace)
+		if err != nil || !changed {
+			// Values that are not valid JSON are left alone, as writes do
+			continue
+		}
+		replacements = append(replacements, models.ValueReplacement{
+			PropertyID:  result.ID,
+			NodeID:      result.NodeID,
+			Path:        result.Path,
+			Key:         result.Key,
+			Environment: result.Environment,
+			Before:      result.Value,
+			After:       after,
+			UpdatedAt:   result.UpdatedAt,
+		})
+	}
+	return replacements, nil
+}
+
+// ApplyValueReplacement writes planned replacements in one transaction
+// recorded in the audit log with details. It fails with ErrReplacementStale if
+// any of the properties was updated after it was planned.
+func (r *Repository) ApplyValueReplacement(ctx context.Context, replacements []models.ValueReplacement, actor string, details map[string]interface{}) (int64, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	propertyIDs := make([]int64, len(replacements))
+	for i, replacement := range replacements {
+		result, err := tx.ExecContext(ctx, `UPDATE config_properties SET value = $1, updated_at = $2 WHERE id = $3 AND updated_at = $4`,
+			replacement.After, now, replacement.PropertyID, replacement.UpdatedAt)
+		if err != nil {
+			return 0, err
+		}
+		affected, err := result.RowsAffected()
+		if err != nil {
+			return 0, err
+		}
+		if affected == 0 {
+			return 0, ErrReplacementStale
+		}
+		propertyIDs[i] = replacement.PropertyID
+	}
+
+	details["property_ids"] = propertyIDs
+	auditID, err := recordAudit(ctx, tx, AuditActionValueReplace, actor, details)
+	if err != nil {
+		return 0, err
+	}
+	return auditID, tx.Commit()
+}
+
+// replaceStrings applies replace to the strings of a serialized JSON value
+// and reports whether any of them changed
+func replaceStrings(value string, replace func(string) string) (string, bool, error) {
+	decoder := json.NewDecoder(strings.NewReader(value))
+	decoder.UseNumber()
+	var decoded interface{}
+	if err := decoder.Decode(&decoded); err != nil {
+		return "", false, err
+	}
+
+	changed := false
+	var walk func(v interface{}) interface{}
+	walk = func(v interface{}) interface{} {
+		switch v := v.(type) {
+		case string:
+			replaced := replace(v)
+			if replaced != v {
+				changed = true
+			}
+			return replaced
+		case map[string]interface{}:
+			for k, child := range v {
+				v[k] = walk(child)
+			}
+		case []interface{}:
+			for i, child := range v {
+				v[i] = walk(child)
+			}
+		}
+		return v
+	}
+	decoded = walk(decoded)
+	if !changed {
+		return value, false, nil
+	}
+
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(decoded); err != nil {
+		return "", false, err
+	}
+	return strings.TrimSuffix(buf.String(), "\n"), true, nil
+}
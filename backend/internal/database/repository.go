@@ -1,135 +1,300 @@
 package database
 
 import (
+	"config-manager/internal/encryption"
+	"config-manager/internal/flags"
 	"config-manager/internal/models"
+	"context"
+	"crypto/sha256"
 	"database/sql"
+	"encoding/hex"
 	"encoding/json"
-	"fmt"
+	"errors"
+	"strings"
+	"sync"
 	"time"
 )
 
+// ErrNodeNotFound is returned when resolving a node that does not exist
+var ErrNodeNotFound = errors.New("node not found")
+
+// ErrPropertyNotFound is returned when deleting a property that does not exist
+var ErrPropertyNotFound = errors.New("property not found")
+
 type Repository struct {
-	db *DB
+	db      *DB
+	keyring *encryption.Keyring
+
+	dataKeysMu sync.Mutex
+	dataKeys   map[int64][]byte // unwrapped subtree keys by encryption key ID
+
+	materialized bool // see MaterializeResolutions
+	readOnly     bool // see ReadOnly
+}
+
+func NewRepository(db *DB, keyring *encryption.Keyring) *Repository {
+	return &Repository{db: db, keyring: keyring, dataKeys: make(map[int64][]byte)}
+}
+
+// ReadOnly marks the database as one that takes no writes, such as a read
+// replica, so that authenticating API keys does not record their last use
+func (r *Repository) ReadOnly() {
+	r.readOnly = true
+}
+
+// Ping checks that the underlying database is reachable
+func (r *Repository) Ping(ctx context.Context) error {
+	return r.db.Ping(ctx)
 }
 
-func NewRepository(db *DB) *Repository {
-	return &Repository{db: db}
+// PoolStats returns the state of the connection pool of the database
+func (r *Repository) PoolStats() PoolStats {
+	return r.db.PoolStats()
+}
+
+// withTimeout derives the context of a repository call from the caller's,
+// bounded by the query timeout, so that queries are cancelled when either the
+// caller gives up, e.g. the client disconnects, or they run too long
+func (r *Repository) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if r.db.queryTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, r.db.queryTimeout)
 }
 
 // Node operations
-func (r *Repository) CreateNode(req models.CreateNodeRequest) (*models.ConfigNode, error) {
+const nodeColumns = `id, name, node_type, parent_id, description, labels, metadata, sort_index, created_at, updated_at`
+
+func scanNode(row rowScanner) (*models.ConfigNode, error) {
+	var node models.ConfigNode
+	var labels, metadata []byte
+	err := row.Scan(
+		&node.ID, &node.Name, &node.NodeType, &node.ParentID, &node.Description, &labels, &metadata, &node.SortIndex, &node.CreatedAt, &node.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(labels, &node.Labels); err != nil {
+		return nil, err
+	}
+	if node.Labels == nil {
+		node.Labels = map[string]string{}
+	}
+	if err := json.Unmarshal(metadata, &node.Metadata); err != nil {
+		return nil, err
+	}
+	if node.Metadata == nil {
+		node.Metadata = map[string]interface{}{}
+	}
+	return &node, nil
+}
+
+// scanNodes collects all rows of a node query
+func scanNodes(rows *sql.Rows) ([]models.ConfigNode, error) {
+	var nodes []models.ConfigNode
+	for rows.Next() {
+		node, err := scanNode(rows)
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, *node)
+	}
+	
+	return nodes, nil
+}
+
+// nodeCountColumns selects the counts of a node aliased n, see scanNodesWithCounts
+const nodeCountColumns = `(SELECT COUNT(*) FROM config_nodes c WHERE c.parent_id = n.id),
+		(SELECT COUNT(*) FROM config_properties p WHERE p.node_id = n.id)`
+
+// scanNodesWithCounts collects all rows of a node query selecting the
+// nodeCountColumns after the node columns
+func scanNodesWithCounts(rows *sql.Rows) ([]models.ConfigNode, error) {
+	var nodes []models.ConfigNode
+	for rows.Next() {
+		counts := &models.NodeCounts{}
+		node, err := scanNode(withCounts{rows, counts})
+		if err != nil {
+			return nil, err
+		}
+		counts.HasChildren = counts.ChildCount > 0
+		node.NodeCounts = counts
+		nodes = append(nodes, *node)
+	}
+	return nodes, rows.Err()
+}
+
+// withCounts scans the node columns of a row followed by its counts
+type withCounts struct {
+	row    rowScanner
+	counts *models.NodeCounts
+}
+
+func (w withCounts) Scan(dest ...interface{}) error {
+	return w.row.Scan(append(dest, &w.counts.ChildCount, &w.counts.PropertyCount)...)
+}
+
+func (r *Repository) CreateNode(ctx context.Context, req models.CreateNodeRequest) (*models.ConfigNode, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	return createNode(ctx, r.db, req)
+}
+
+func createNode(ctx context.Context, q querier, req models.CreateNodeRequest) (*models.ConfigNode, error) {
 	query := `
-		INSERT INTO config_nodes (name, node_type, parent_id, description, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6)
-		RETURNING id, name, node_type, parent_id, description, created_at, updated_at`
+		INSERT INTO config_nodes (name, node_type, parent_id, description, labels, metadata, sort_index, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, ` + nextSortIndex("$3") + `, $7, $8)
+		RETURNING ` + nodeColumns
 	
+	labels, err := encodeLabels(req.Labels)
+	if err != nil {
+		return nil, err
+	}
+	metadata, err := encodeMetadata(req.Metadata)
+	if err != nil {
+		return nil, err
+	}
 	now := time.Now()
-	var node models.ConfigNode
 	
-	err := r.db.QueryRow(query, req.Name, req.NodeType, req.ParentID, req.Description, now, now).Scan(
-		&node.ID, &node.Name, &node.NodeType, &node.ParentID, &node.Description, &node.CreatedAt, &node.UpdatedAt,
-	)
+	node, err := scanNode(q.QueryRowContext(ctx, query, req.Name, req.NodeType, req.ParentID, req.Description, labels, metadata, now, now))
+	if isNodeNameConflict(err) {
+		return nil, ErrNodeNameTaken
+	}
 	
-	return &node, err
+	return node, err
 }
 
-func (r *Repository) GetNodeByID(id int64) (*models.ConfigNode, error) {
+func (r *Repository) GetNodeByID(ctx context.Context, id int64) (*models.ConfigNode, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
 	query := `
-		SELECT id, name, node_type, parent_id, description, created_at, updated_at
+		SELECT ` + nodeColumns + `
 		FROM config_nodes WHERE id = $1`
 	
-	var node models.ConfigNode
-	err := r.db.QueryRow(query, id).Scan(
-		&node.ID, &node.Name, &node.NodeType, &node.ParentID, &node.Description, &node.CreatedAt, &node.UpdatedAt,
-	)
-	
+	node, err := scanNode(r.db.QueryRowContext(ctx, query, id))
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
 	
-	return &node, err
+	return node, err
 }
 
-func (r *Repository) GetRootNodes() ([]models.ConfigNode, error) {
+func (r *Repository) GetRootNodes(ctx context.Context) ([]models.ConfigNode, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
 	query := `
-		SELECT id, name, node_type, parent_id, description, created_at, updated_at
-		FROM config_nodes WHERE parent_id IS NULL
-		ORDER BY created_at DESC`
+		SELECT ` + prefixColumns("n", nodeColumns) + `, ` + nodeCountColumns + `
+		FROM config_nodes n WHERE n.parent_id IS NULL
+		ORDER BY n.sort_index, n.id`
 	
-	rows, err := r.db.Query(query)
+	rows, err := r.db.QueryContext(ctx, query)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 	
-	var nodes []models.ConfigNode
-	for rows.Next() {
-		var node models.ConfigNode
-		err := rows.Scan(
-			&node.ID, &node.Name, &node.NodeType, &node.ParentID, &node.Description, &node.CreatedAt, &node.UpdatedAt,
+	return scanNodesWithCounts(rows)
+}
+
+// GetAllNodes returns every node of the tree, parents before their children
+func (r *Repository) GetAllNodes(ctx context.Context) ([]models.ConfigNode, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	query := `
+		WITH RECURSIVE tree AS (
+			SELECT id, 0 AS depth FROM config_nodes WHERE parent_id IS NULL
+			UNION ALL
+			SELECT n.id, t.depth + 1 FROM config_nodes n JOIN tree t ON n.parent_id = t.id
 		)
-		if err != nil {
-			return nil, err
-		}
-		nodes = append(nodes, node)
+		SELECT ` + prefixColumns("n", nodeColumns) + `
+		FROM config_nodes n JOIN tree t ON n.id = t.id
+		ORDER BY t.depth, n.sort_index, n.id`
+
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
 	}
-	
-	return nodes, nil
+	defer rows.Close()
+
+	return scanNodes(rows)
 }
 
-func (r *Repository) GetChildNodes(parentID int64) ([]models.ConfigNode, error) {
+func (r *Repository) GetChildNodes(ctx context.Context, parentID int64) ([]models.ConfigNode, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
 	query := `
-		SELECT id, name, node_type, parent_id, description, created_at, updated_at
-		FROM config_nodes WHERE parent_id = $1
-		ORDER BY created_at DESC`
+		SELECT ` + prefixColumns("n", nodeColumns) + `, ` + nodeCountColumns + `
+		FROM config_nodes n WHERE n.parent_id = $1
+		ORDER BY n.sort_index, n.id`
 	
-	rows, err := r.db.Query(query, parentID)
+	rows, err := r.db.QueryContext(ctx, query, parentID)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 	
-	var nodes []models.ConfigNode
-	for rows.Next() {
-		var node models.ConfigNode
-		err := rows.Scan(
-			&node.ID, &node.Name, &node.NodeType, &node.ParentID, &node.Description, &node.CreatedAt, &node.UpdatedAt,
-		)
-		if err != nil {
-			return nil, err
-		}
-		nodes = append(nodes, node)
-	}
-	
-	return nodes, nil
+	return scanNodesWithCounts(rows)
 }
 
-func (r *Repository) UpdateNode(id int64, req models.UpdateNodeRequest) (*models.ConfigNode, error) {
+func (r *Repository) UpdateNode(ctx context.Context, id int64, req models.UpdateNodeRequest) (*models.ConfigNode, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	return updateNode(ctx, r.db, id, req)
+}
+
+func updateNode(ctx context.Context, q querier, id int64, req models.UpdateNodeRequest) (*models.ConfigNode, error) {
 	query := `
 		UPDATE config_nodes 
 		SET name = COALESCE($1, name), 
 		    description = COALESCE($2, description),
-		    updated_at = $3
-		WHERE id = $4
-		RETURNING id, name, node_type, parent_id, description, created_at, updated_at`
+		    labels = COALESCE($3, labels),
+		    metadata = COALESCE($4, metadata),
+		    updated_at = $5
+		WHERE id = $6
+		RETURNING ` + nodeColumns
 	
+	var labels, metadata []byte
+	if req.Labels != nil {
+		var err error
+		if labels, err = encodeLabels(req.Labels); err != nil {
+			return nil, err
+		}
+	}
+	if req.Metadata != nil {
+		var err error
+		if metadata, err = encodeMetadata(req.Metadata); err != nil {
+			return nil, err
+		}
+	}
 	now := time.Now()
-	var node models.ConfigNode
-	
-	err := r.db.QueryRow(query, req.Name, req.Description, now, id).Scan(
-		&node.ID, &node.Name, &node.NodeType, &node.ParentID, &node.Description, &node.CreatedAt, &node.UpdatedAt,
-	)
 	
+	node, err := scanNode(q.QueryRowContext(ctx, query, req.Name, req.Description, labels, metadata, now, id))
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
+	if isNodeNameConflict(err) {
+		return nil, ErrNodeNameTaken
+	}
 	
-	return &node, err
+	return node, err
+}
+
+func (r *Repository) DeleteNode(ctx context.Context, id int64) error {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	return deleteNode(ctx, r.db, id)
 }
 
-func (r *Repository) DeleteNode(id int64) error {
+func deleteNode(ctx context.Context, q querier, id int64) error {
 	query := `DELETE FROM config_nodes WHERE id = $1`
-	result, err := r.db.Exec(query, id)
+	result, err := q.ExecContext(ctx, query, id)
 	if err != nil {
 		return err
 	}
@@ -140,43 +305,107 @@ func (r *Repository) DeleteNode(id int64) error {
 	}
 	
 	if rowsAffected == 0 {
-		return fmt.Errorf("node not found")
+		return ErrNodeNotFound
 	}
 	
 	return nil
 }
 
 // Property operations
-func (r *Repository) CreateProperty(nodeID int64, req models.CreatePropertyRequest) (*models.ConfigProperty, error) {
+const propertyColumns = `id, node_id, key, environment, value, data_type, default_value, description, encrypted, encryption_key_id, rollout_percentage, rollout_key, is_final, namespace, tags, expires_at, created_at, updated_at`
+
+// prefixColumns qualifies a comma-separated column list with a table alias
+func prefixColumns(alias, columns string) string {
+	names := strings.Split(columns, ", ")
+	for i, name := range names {
+		names[i] = alias + "." + name
+	}
+	return strings.Join(names, ", ")
+}
+
+// rowScanner is satisfied by both *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func (r *Repository) scanProperty(ctx context.Context, row rowScanner) (*models.ConfigProperty, error) {
+	var prop models.ConfigProperty
+	err := row.Scan(
+		&prop.ID, &prop.NodeID, &prop.Key, &prop.Environment, &prop.Value, &prop.DataType, &prop.DefaultValue, &prop.Description, &prop.Encrypted, &prop.EncryptionKeyID, &prop.RolloutPercentage, &prop.RolloutKey, &prop.IsFinal, &prop.Namespace, textArray(&prop.Tags), &prop.ExpiresAt, &prop.CreatedAt, &prop.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if prop.Tags == nil {
+		prop.Tags = []string{}
+	}
+
+	if err := r.decryptProperty(ctx, &prop); err != nil {
+		return nil, err
+	}
+	return &prop, nil
+}
+
+func (r *Repository) CreateProperty(ctx context.Context, nodeID int64, req models.CreatePropertyRequest) (*models.ConfigProperty, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	return r.createProperty(ctx, r.db, nodeID, req)
+}
+
+func (r *Repository) createProperty(ctx context.Context, q querier, nodeID int64, req models.CreatePropertyRequest) (*models.ConfigProperty, error) {
 	query := `
-		INSERT INTO config_properties (node_id, key, value, data_type, default_value, description, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
-		ON CONFLICT (node_id, key) 
+		INSERT INTO config_properties (node_id, key, environment, value, data_type, default_value, description, encrypted, encryption_key_id, rollout_percentage, rollout_key, is_final, namespace, tags, expires_at, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17)
+		ON CONFLICT (node_id, key, (COALESCE(environment, ''))) 
 		DO UPDATE SET 
 			value = EXCLUDED.value,
 			data_type = EXCLUDED.data_type,
 			default_value = EXCLUDED.default_value,
 			description = EXCLUDED.description,
+			encrypted = EXCLUDED.encrypted,
+			encryption_key_id = EXCLUDED.encryption_key_id,
+			rollout_percentage = EXCLUDED.rollout_percentage,
+			rollout_key = EXCLUDED.rollout_key,
+			is_final = EXCLUDED.is_final,
+			namespace = EXCLUDED.namespace,
+			tags = EXCLUDED.tags,
+			expires_at = EXCLUDED.expires_at,
 			updated_at = EXCLUDED.updated_at
-		RETURNING id, node_id, key, value, data_type, default_value, description, created_at, updated_at`
+		RETURNING ` + propertyColumns
 	
-	now := time.Now()
-	var prop models.ConfigProperty
+	value, defaultValue := req.Value, req.DefaultValue
+	var keyID *int64
+	if req.Encrypted {
+		id, dataKey, err := r.subtreeKey(ctx, q, nodeID)
+		if err != nil {
+			return nil, err
+		}
+		if value, err = encryption.Encrypt(dataKey, value); err != nil {
+			return nil, err
+		}
+		if defaultValue, err = encryptOptional(dataKey, defaultValue); err != nil {
+			return nil, err
+		}
+		keyID = &id
+	}
 	
-	err := r.db.QueryRow(query, nodeID, req.Key, req.Value, req.DataType, req.DefaultValue, req.Description, now, now).Scan(
-		&prop.ID, &prop.NodeID, &prop.Key, &prop.Value, &prop.DataType, &prop.DefaultValue, &prop.Description, &prop.CreatedAt, &prop.UpdatedAt,
-	)
+	now := time.Now()
+	row := q.QueryRowContext(ctx, query, nodeID, req.Key, req.Environment, value, req.DataType, defaultValue, req.Description, req.Encrypted, keyID, req.RolloutPercentage, req.RolloutKey, req.IsFinal, req.Namespace, NormalizeTags(req.Tags), req.ExpiresAt, now, now)
 	
-	return &prop, err
+	return r.scanProperty(ctx, row)
 }
 
-func (r *Repository) GetPropertiesByNodeID(nodeID int64) ([]models.ConfigProperty, error) {
+func (r *Repository) GetPropertiesByNodeID(ctx context.Context, nodeID int64) ([]models.ConfigProperty, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
 	query := `
-		SELECT id, node_id, key, value, data_type, default_value, description, created_at, updated_at
+		SELECT ` + propertyColumns + `
 		FROM config_properties WHERE node_id = $1
-		ORDER BY key`
+		ORDER BY key, environment NULLS FIRST`
 	
-	rows, err := r.db.Query(query, nodeID)
+	rows, err := r.db.QueryContext(ctx, query, nodeID)
 	if err != nil {
 		return nil, err
 	}
@@ -184,47 +413,110 @@ func (r *Repository) GetPropertiesByNodeID(nodeID int64) ([]models.ConfigPropert
 	
 	var properties []models.ConfigProperty
 	for rows.Next() {
-		var prop models.ConfigProperty
-		err := rows.Scan(
-			&prop.ID, &prop.NodeID, &prop.Key, &prop.Value, &prop.DataType, &prop.DefaultValue, &prop.Description, &prop.CreatedAt, &prop.UpdatedAt,
-		)
+		prop, err := r.scanProperty(ctx, rows)
 		if err != nil {
 			return nil, err
 		}
-		properties = append(properties, prop)
+		properties = append(properties, *prop)
 	}
 	
 	return properties, nil
 }
 
-func (r *Repository) UpdateProperty(id int64, req models.UpdatePropertyRequest) (*models.ConfigProperty, error) {
+func (r *Repository) UpdateProperty(ctx context.Context, id int64, req models.UpdatePropertyRequest) (*models.ConfigProperty, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	return r.updateProperty(ctx, r.db, id, req)
+}
+
+func (r *Repository) updateProperty(ctx context.Context, q querier, id int64, req models.UpdatePropertyRequest) (*models.ConfigProperty, error) {
 	query := `
 		UPDATE config_properties 
 		SET value = COALESCE($1, value),
 		    data_type = COALESCE($2, data_type),
 		    default_value = COALESCE($3, default_value),
 		    description = COALESCE($4, description),
-		    updated_at = $5
-		WHERE id = $6
-		RETURNING id, node_id, key, value, data_type, default_value, description, created_at, updated_at`
+		    encryption_key_id = COALESCE($5, encryption_key_id),
+		    rollout_percentage = COALESCE($6, rollout_percentage),
+		    rollout_key = COALESCE($7, rollout_key),
+		    is_final = COALESCE($8, is_final),
+		    namespace = CASE WHEN $9::text IS NULL THEN namespace ELSE NULLIF($9::text, '') END,
+		    tags = COALESCE($10::text[], tags),
+		    expires_at = CASE WHEN $11 THEN NULL ELSE COALESCE($12, expires_at) END,
+		    updated_at = $13
+		WHERE id = $14
+		RETURNING ` + propertyColumns
+	
+	value, defaultValue := req.Value, req.DefaultValue
+	var keyID *int64
+	if value != nil || defaultValue != nil {
+		var nodeID int64
+		var encrypted bool
+		var storedKeyID sql.NullInt64
+		var storedValue string
+		var storedDefault sql.NullString
+		err := q.QueryRowContext(ctx, `SELECT node_id, encrypted, encryption_key_id, value, default_value FROM config_properties WHERE id = $1`, id).
+			Scan(&nodeID, &encrypted, &storedKeyID, &storedValue, &storedDefault)
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		
+		// Encrypted properties are re-encrypted with the current subtree key
+		if encrypted {
+			kid, dataKey, err := r.subtreeKey(ctx, q, nodeID)
+			if err != nil {
+				return nil, err
+			}
+			if value, err = encryptOptional(dataKey, value); err != nil {
+				return nil, err
+			}
+			if defaultValue, err = encryptOptional(dataKey, defaultValue); err != nil {
+				return nil, err
+			}
+			
+			// Fields the request leaves alone must move to the new key too, or
+			// they could no longer be decrypted
+			if storedKeyID.Valid && storedKeyID.Int64 != kid {
+				if value == nil {
+					if value, err = r.reencrypt(ctx, storedKeyID.Int64, dataKey, storedValue); err != nil {
+						return nil, err
+					}
+				}
+				if defaultValue == nil && storedDefault.Valid {
+					if defaultValue, err = r.reencrypt(ctx, storedKeyID.Int64, dataKey, storedDefault.String); err != nil {
+						return nil, err
+					}
+				}
+			}
+			keyID = &kid
+		}
+	}
 	
 	now := time.Now()
-	var prop models.ConfigProperty
-	
-	err := r.db.QueryRow(query, req.Value, req.DataType, req.DefaultValue, req.Description, now, id).Scan(
-		&prop.ID, &prop.NodeID, &prop.Key, &prop.Value, &prop.DataType, &prop.DefaultValue, &prop.Description, &prop.CreatedAt, &prop.UpdatedAt,
-	)
+	row := q.QueryRowContext(ctx, query, value, req.DataType, defaultValue, req.Description, keyID, req.RolloutPercentage, req.RolloutKey, req.IsFinal, req.Namespace, NormalizeTags(req.Tags), req.ClearExpiry, req.ExpiresAt, now, id)
 	
+	prop, err := r.scanProperty(ctx, row)
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
 	
-	return &prop, err
+	return prop, err
 }
 
-func (r *Repository) DeleteProperty(id int64) error {
+func (r *Repository) DeleteProperty(ctx context.Context, id int64) error {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	return deleteProperty(ctx, r.db, id)
+}
+
+func deleteProperty(ctx context.Context, q querier, id int64) error {
 	query := `DELETE FROM config_properties WHERE id = $1`
-	result, err := r.db.Exec(query, id)
+	result, err := q.ExecContext(ctx, query, id)
 	if err != nil {
 		return err
 	}
@@ -235,68 +527,356 @@ func (r *Repository) DeleteProperty(id int64) error {
 	}
 	
 	if rowsAffected == 0 {
-		return fmt.Errorf("property not found")
+		return ErrPropertyNotFound
 	}
 	
 	return nil
 }
 
 // Configuration resolution
-func (r *Repository) GetNodePath(nodeID int64) ([]models.ConfigNode, error) {
-	var path []models.ConfigNode
-	currentID := &nodeID
+func (r *Repository) GetNodePath(ctx context.Context, nodeID int64) ([]models.ConfigNode, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	query := `
+		WITH RECURSIVE ancestors AS (
+			SELECT id, parent_id, 0 AS depth FROM config_nodes WHERE id = $1
+			UNION ALL
+			SELECT n.id, n.parent_id, a.depth + 1
+			FROM config_nodes n JOIN ancestors a ON n.id = a.parent_id
+		)
+		SELECT ` + prefixColumns("n", nodeColumns) + `
+		FROM config_nodes n JOIN ancestors a ON n.id = a.id
+		ORDER BY a.depth DESC`
 	
-	for currentID != nil {
-		node, err := r.GetNodeByID(*currentID)
-		if err != nil {
-			return nil, err
-		}
-		if node == nil {
-			break
-		}
-		
-		path = append([]models.ConfigNode{*node}, path...)
-		currentID = node.ParentID
+	rows, err := r.db.QueryContext(ctx, query, nodeID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	
+	return scanNodes(rows)
+}
+
+// resolutionChain returns the nodes from the root down to nodeID, empty if it
+// does not exist, and the properties of each by node ID. They are loaded in a
+// single query, so resolving a node takes one round trip however deep it is.
+func (r *Repository) resolutionChain(ctx context.Context, nodeID int64) ([]models.ConfigNode, map[int64][]models.ConfigProperty, error) {
+	query := `
+		WITH RECURSIVE ancestors AS (
+			SELECT id, parent_id, 0 AS depth FROM config_nodes WHERE id = $1
+			UNION ALL
+			SELECT n.id, n.parent_id, a.depth + 1
+			FROM config_nodes n JOIN ancestors a ON n.id = a.parent_id
+		)
+		SELECT ` + prefixColumns("n", nodeColumns) + `, ` + prefixColumns("p", propertyColumns) + `
+		FROM ancestors a
+		JOIN config_nodes n ON n.id = a.id
+		LEFT JOIN config_properties p ON p.node_id = n.id
+		ORDER BY a.depth DESC, p.key, p.environment NULLS FIRST`
+	
+	rows, err := r.db.QueryContext(ctx, query, nodeID)
+	if err != nil {
+		return nil, nil, err
 	}
+	defer rows.Close()
 	
-	return path, nil
+	return r.scanNodesWithProperties(ctx, rows)
 }
 
-func (r *Repository) ResolveConfiguration(nodeID int64) (*models.ResolvedConfiguration, error) {
-	path, err := r.GetNodePath(nodeID)
+// ResolveConfiguration merges the properties along the path from the root to
+// nodeID. When an environment is set, its overrides replace the values defined
+// for all environments on the same node. Rollouts are decided with the context.
+// Drafts are only applied when previewing them with opts.IncludeDrafts. With
+// opts.AsOf, the tree and properties are read from their recorded versions.
+func (r *Repository) ResolveConfiguration(ctx context.Context, nodeID int64, opts models.ResolveOptions) (*models.ResolvedConfiguration, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	if r.materialized && materializable(opts) {
+		resolved, err := r.materializedResolution(ctx, nodeID, opts.Environment)
+		if resolved != nil || err != nil {
+			return resolved, err
+		}
+	}
+
+	var path []models.ConfigNode
+	var current map[int64][]models.ConfigProperty
+	var err error
+	if opts.AsOf != nil {
+		path, err = r.getNodePathAt(ctx, nodeID, *opts.AsOf)
+	} else {
+		path, current, err = r.resolutionChain(ctx, nodeID)
+	}
 	if err != nil {
 		return nil, err
 	}
 	
 	if len(path) == 0 {
-		return nil, fmt.Errorf("node not found")
+		return nil, ErrNodeNotFound
 	}
 	
+	templates, err := r.getTemplateProperties(ctx, nodeIDs(path))
+	if err != nil {
+		return nil, err
+	}
+	
+	resolved, err := resolvePath(path, templates, opts, func(nodeID int64) ([]models.ConfigProperty, error) {
+		properties := current[nodeID]
+		if opts.AsOf != nil {
+			properties, err = r.getPropertiesAt(ctx, nodeID, *opts.AsOf)
+		}
+		if err != nil || !opts.IncludeDrafts {
+			return properties, err
+		}
+		
+		drafts, err := r.GetPropertyDrafts(ctx, nodeID)
+		if err != nil {
+			return nil, err
+		}
+		return overlayDrafts(properties, drafts), nil
+	})
+	if err != nil || opts.AsOf != nil {
+		return resolved, err
+	}
+
+	// Past versions are not numbered
+	versions, err := r.configVersions(ctx, nodeIDs(path))
+	if err != nil {
+		return nil, err
+	}
+	resolved.Version = pathVersion(path, versions)
+	return resolved, nil
+}
+
+// configVersions returns the configuration versions of the nodes with ids,
+// see pathVersion
+func (r *Repository) configVersions(ctx context.Context, ids []int64) (map[int64]int64, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT node_id, version FROM config_versions WHERE node_id = ANY($1)`, ids)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	versions := make(map[int64]int64, len(ids))
+	for rows.Next() {
+		var nodeID, version int64
+		if err := rows.Scan(&nodeID, &version); err != nil {
+			return nil, err
+		}
+		versions[nodeID] = version
+	}
+	return versions, rows.Err()
+}
+
+// pathVersion returns the version of the configuration resolved along path:
+// the highest version of its nodes, each of which takes a new version from a
+// sequence on every change that can alter the resolution of its subtree
+func pathVersion(path []models.ConfigNode, versions map[int64]int64) int64 {
+	var version int64
+	for _, node := range path {
+		if versions[node.ID] > version {
+			version = versions[node.ID]
+		}
+	}
+	return version
+}
+
+// resolvePath merges the properties of the nodes of path, from the root down,
+// with those of the templates attached to each node applying first. Properties
+// are loaded per node with properties; previewed drafts are applied on top.
+func resolvePath(path []models.ConfigNode, templates map[int64][]models.ConfigProperty, opts models.ResolveOptions, properties func(nodeID int64) ([]models.ConfigProperty, error)) (*models.ResolvedConfiguration, error) {
 	resolved := make(map[string]interface{})
+	effective := make(map[string]models.ConfigProperty)
+	locked := make(map[string]bool)
+	at := time.Now()
+	if opts.AsOf != nil {
+		at = *opts.AsOf
+	}
 	
-	// Apply properties from root to leaf (inheritance)
+	// Apply properties from root to leaf (inheritance). On each node, the
+	// properties of its templates apply first and its own properties win.
 	for _, node := range path {
-		properties, err := r.GetPropertiesByNodeID(node.ID)
+		provided := unlocked(forEnvironment(templates[node.ID], opts.Environment), locked)
+		applyProperties(resolved, provided)
+		for _, prop := range provided {
+			effective[prop.Key] = prop
+		}
+		
+		own, err := properties(node.ID)
 		if err != nil {
 			return nil, err
 		}
+		if len(opts.Preview) > 0 {
+			own = overlayDrafts(own, draftsOf(opts.Preview, node.ID))
+		}
 		
-		for _, prop := range properties {
-			var value interface{}
-			if err := json.Unmarshal([]byte(prop.Value), &value); err != nil {
-				// If unmarshal fails, store as string
-				value = prop.Value
+		own = unlocked(unexpired(forEnvironment(own, opts.Environment), at), locked)
+		applyProperties(resolved, own)
+		for _, prop := range own {
+			effective[prop.Key] = prop
+		}
+	}
+
+	for key, prop := range effective {
+		if flags.HasRollout(prop) {
+			resolved[key] = flags.EvaluateRollout(prop, opts.Context).Value
+		}
+	}
+
+	warnings := interpolate(resolved)
+	
+	// Keys outside the namespace may still be referenced by interpolation,
+	// so they are only dropped once resolved
+	if opts.Namespace != "" {
+		for key := range resolved {
+			if !inNamespace(effective[key], opts.Namespace) {
+				delete(resolved, key)
+			}
+		}
+		kept := warnings[:0]
+		for _, warning := range warnings {
+			if inNamespace(effective[warning.Key], opts.Namespace) {
+				kept = append(kept, warning)
 			}
-			resolved[prop.Key] = value
 		}
+		warnings = kept
 	}
 	
 	currentNode := path[len(path)-1]
 	
 	return &models.ResolvedConfiguration{
-		NodeID:     nodeID,
-		NodeName:   currentNode.Name,
-		Properties: resolved,
-		Path:       path,
+		NodeID:      currentNode.ID,
+		NodeName:    currentNode.Name,
+		Environment: opts.Environment,
+		Namespace:   opts.Namespace,
+		Properties:  resolved,
+		Path:        path,
+		AsOf:        opts.AsOf,
+		Checksum:    configChecksum(opts.Environment, resolved),
+		Warnings:    warnings,
 	}, nil
+}
+
+// configChecksum identifies a resolved configuration by its environment and
+// properties. Map keys are encoded in order, so equal configurations have equal
+// checksums wherever they are resolved.
+func configChecksum(environment string, properties map[string]interface{}) string {
+	payload, _ := json.Marshal(struct {
+		Environment string                 `json:"environment"`
+		Properties  map[string]interface{} `json:"properties"`
+	}{environment, properties})
+	sum := sha256.Sum256(payload)
+	return hex.EncodeToString(sum[:])
+}
+
+// GetEffectiveProperties returns the properties in effect on nodeID after
+// inheritance, keyed by property key, or nil if the node does not exist
+func (r *Repository) GetEffectiveProperties(ctx context.Context, nodeID int64, environment string) (map[string]models.ConfigProperty, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	path, properties, err := r.resolutionChain(ctx, nodeID)
+	if err != nil || len(path) == 0 {
+		return nil, err
+	}
+
+	templates, err := r.getTemplateProperties(ctx, nodeIDs(path))
+	if err != nil {
+		return nil, err
+	}
+
+	effective := make(map[string]models.ConfigProperty)
+	locked := make(map[string]bool)
+	now := time.Now()
+	for _, node := range path {
+		for _, prop := range unlocked(forEnvironment(templates[node.ID], environment), locked) {
+			effective[prop.Key] = prop
+		}
+		for _, prop := range unlocked(unexpired(forEnvironment(properties[node.ID], environment), now), locked) {
+			effective[prop.Key] = prop
+		}
+	}
+	return effective, nil
+}
+
+// forEnvironment returns the properties that apply to all environments followed
+// by the overrides for environment, so applying them in order lets overrides win
+func forEnvironment(properties []models.ConfigProperty, environment string) []models.ConfigProperty {
+	applicable := make([]models.ConfigProperty, 0, len(properties))
+	for _, prop := range properties {
+		if prop.Environment == nil {
+			applicable = append(applicable, prop)
+		}
+	}
+	if environment == "" {
+		return applicable
+	}
+	for _, prop := range properties {
+		if prop.Environment != nil && *prop.Environment == environment {
+			applicable = append(applicable, prop)
+		}
+	}
+	return applicable
+}
+
+// unlocked drops the properties of a node whose key an ancestor locked as
+// final, then adds the keys the node locks for its own descendants. Nodes are
+// passed from the root down.
+func unlocked(properties []models.ConfigProperty, locked map[string]bool) []models.ConfigProperty {
+	kept := properties[:0:0]
+	for _, prop := range properties {
+		if !locked[prop.Key] {
+			kept = append(kept, prop)
+		}
+	}
+	for _, prop := range kept {
+		if prop.IsFinal {
+			locked[prop.Key] = true
+		}
+	}
+	return kept
+}
+
+// applyProperties sets the decoded value of each property in resolved,
+// overriding values inherited from ancestors
+func applyProperties(resolved map[string]interface{}, properties []models.ConfigProperty) {
+	for _, prop := range properties {
+		var value interface{}
+		if err := json.Unmarshal([]byte(prop.Value), &value); err != nil {
+			// If unmarshal fails, store as string
+			value = prop.Value
+		}
+		resolved[prop.Key] = value
+	}
+}
+
+// GetInheritedProperty returns the property with the given key defined on the
+// nearest ancestor of nodeID (excluding the node itself) for all environments or
+// for environment, or nil if there is none
+func (r *Repository) GetInheritedProperty(ctx context.Context, nodeID int64, key string, environment *string) (*models.ConfigProperty, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	query := `
+		WITH RECURSIVE ancestors AS (
+			SELECT parent_id AS id, 1 AS depth FROM config_nodes WHERE id = $1
+			UNION ALL
+			SELECT n.parent_id, a.depth + 1
+			FROM config_nodes n JOIN ancestors a ON n.id = a.id
+			WHERE n.parent_id IS NOT NULL
+		)
+		SELECT ` + prefixColumns("p", propertyColumns) + `
+		FROM config_properties p
+		JOIN ancestors a ON p.node_id = a.id
+		WHERE p.key = $2 AND (p.environment IS NULL OR p.environment = $3)
+		ORDER BY a.depth, p.environment NULLS LAST
+		LIMIT 1`
+	
+	prop, err := r.scanProperty(ctx, r.db.QueryRowContext(ctx, query, nodeID, key, environment))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	
+	return prop, err
 }
\ No newline at end of file
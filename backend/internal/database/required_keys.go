@@ -0,0 +1,177 @@
+package database
+
+import (
+	"config-manager/internal/models"
+	"context"
+	"sort"
+	"strings"
+	"time"
+)
+
+const requiredKeyColumns = `id, node_id, key, description, created_by, created_at`
+
+func scanRequiredKey(row rowScanner) (*models.RequiredKey, error) {
+	var required models.RequiredKey
+	err := row.Scan(&required.ID, &required.NodeID, &required.Key, &required.Description, &required.CreatedBy, &required.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &required, nil
+}
+
+// RequireKey declares that every leaf below nodeID must resolve key. Declaring
+// a key again updates its description.
+func (r *Repository) RequireKey(ctx context.Context, nodeID int64, req models.CreateRequiredKeyRequest, createdBy string) (*models.RequiredKey, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	query := `
+		INSERT INTO config_required_keys (node_id, key, description, created_by, created_at)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (node_id, key) DO UPDATE SET description = EXCLUDED.description
+		RETURNING ` + requiredKeyColumns
+
+	return scanRequiredKey(r.db.QueryRowContext(ctx, query, nodeID, req.Key, req.Description, createdBy, time.Now()))
+}
+
+// GetRequiredKeys lists the keys declared as required on a node itself
+func (r *Repository) GetRequiredKeys(ctx context.Context, nodeID int64) ([]models.RequiredKey, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := r.db.QueryContext(ctx, `SELECT `+requiredKeyColumns+` FROM config_required_keys WHERE node_id = $1 ORDER BY key`, nodeID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	required := []models.RequiredKey{}
+	for rows.Next() {
+		key, err := scanRequiredKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		required = append(required, *key)
+	}
+	return required, rows.Err()
+}
+
+// UnrequireKey removes a required key declaration, reporting whether it existed
+func (r *Repository) UnrequireKey(ctx context.Context, nodeID int64, key string) (bool, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	result, err := r.db.ExecContext(ctx, `DELETE FROM config_required_keys WHERE node_id = $1 AND key = $2`, nodeID, key)
+	if err != nil {
+		return false, err
+	}
+	rowsAffected, err := result.RowsAffected()
+	return rowsAffected > 0, err
+}
+
+// ValidateCompleteness checks that every leaf below nodeID, or the node itself
+// when it has no children, defines or inherits each key required by its
+// ancestors or itself. With an environment, its overrides count as well.
+func (r *Repository) ValidateCompleteness(ctx context.Context, nodeID int64, environment string) (*models.CompletenessReport, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	path, children, ids, err := r.getPathAndSubtree(ctx, nodeID)
+	if err != nil {
+		return nil, err
+	}
+
+	required, err := r.keysByNode(ctx, `SELECT node_id, key FROM config_required_keys WHERE node_id = ANY($1)`, ids)
+	if err != nil {
+		return nil, err
+	}
+	defined, err := r.keysByNode(ctx, `
+		SELECT node_id, key FROM config_properties
+		WHERE node_id = ANY($1) AND (environment IS NULL OR environment = NULLIF($2, ''))`,
+		ids, environment)
+	if err != nil {
+		return nil, err
+	}
+
+	// Collect what the ancestors of the node require and define
+	names := make([]string, 0, len(path))
+	requiredAbove := make(map[string]bool)
+	definedAbove := make(map[string]bool)
+	for _, node := range path[:len(path)-1] {
+		names = append(names, node.Name)
+		addKeys(requiredAbove, required[node.ID])
+		addKeys(definedAbove, defined[node.ID])
+	}
+
+	report := &models.CompletenessReport{NodeID: nodeID, Environment: environment, Incomplete: []models.IncompleteLeaf{}}
+	var walk func(node models.ConfigNode, names []string, requiredAbove, definedAbove map[string]bool)
+	walk = func(node models.ConfigNode, names []string, requiredAbove, definedAbove map[string]bool) {
+		names = append(names[:len(names):len(names)], node.Name)
+		requiring := addKeys(copyKeys(requiredAbove), required[node.ID])
+		defining := addKeys(copyKeys(definedAbove), defined[node.ID])
+
+		if len(children[node.ID]) > 0 {
+			for _, child := range children[node.ID] {
+				walk(child, names, requiring, defining)
+			}
+			return
+		}
+
+		report.LeavesChecked++
+		var missing []string
+		for key := range requiring {
+			if !defining[key] {
+				missing = append(missing, key)
+			}
+		}
+		if len(missing) > 0 {
+			sort.Strings(missing)
+			report.Incomplete = append(report.Incomplete, models.IncompleteLeaf{
+				NodeID:      node.ID,
+				NodeName:    node.Name,
+				Path:        "/" + strings.Join(names, "/"),
+				MissingKeys: missing,
+			})
+		}
+	}
+	walk(path[len(path)-1], names, requiredAbove, definedAbove)
+
+	sort.Slice(report.Incomplete, func(i, j int) bool { return report.Incomplete[i].Path < report.Incomplete[j].Path })
+	report.Complete = len(report.Incomplete) == 0
+	return report, nil
+}
+
+// keysByNode runs a query returning node IDs and keys, and groups the keys by node
+func (r *Repository) keysByNode(ctx context.Context, query string, args ...interface{}) (map[int64][]string, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := make(map[int64][]string)
+	for rows.Next() {
+		var nodeID int64
+		var key string
+		if err := rows.Scan(&nodeID, &key); err != nil {
+			return nil, err
+		}
+		keys[nodeID] = append(keys[nodeID], key)
+	}
+	return keys, rows.Err()
+}
+
+func addKeys(set map[string]bool, keys []string) map[string]bool {
+	for _, key := range keys {
+		set[key] = true
+	}
+	return set
+}
+
+func copyKeys(set map[string]bool) map[string]bool {
+	copied := make(map[string]bool, len(set))
+	for key := range set {
+		copied[key] = true
+	}
+	return copied
+}
@@ -0,0 +1,124 @@
+package database
+
+import (
+	"config-manager/internal/models"
+	"context"
+	"encoding/json"
+	"maps"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ResolutionCache serves repeated resolutions of the same node, environment,
+// namespace and context from memory for up to ttl, keeping at most size of
+// them. Everything else goes to the repository it wraps. Entries are dropped
+// by Invalidate when the configuration of a node on their path changes; the
+// ttl bounds how stale an entry can get when an invalidation is missed.
+type ResolutionCache struct {
+	ConfigRepository
+
+	ttl  time.Duration
+	size int
+
+	mu      sync.Mutex
+	entries map[string]cachedResolution
+	// generation counts invalidations, so that a resolution that was being read
+	// while one happened is not stored
+	generation uint64
+}
+
+type cachedResolution struct {
+	resolved *models.ResolvedConfiguration
+	expires  time.Time
+}
+
+func NewResolutionCache(repo ConfigRepository, ttl time.Duration, size int) *ResolutionCache {
+	return &ResolutionCache{ConfigRepository: repo, ttl: ttl, size: size, entries: make(map[string]cachedResolution)}
+}
+
+// ResolveConfiguration returns the cached resolution if there is one. Drafts,
+// previews and point-in-time resolutions are not cached.
+func (c *ResolutionCache) ResolveConfiguration(ctx context.Context, nodeID int64, opts models.ResolveOptions) (*models.ResolvedConfiguration, error) {
+	if opts.IncludeDrafts || len(opts.Preview) > 0 || opts.AsOf != nil {
+		return c.ConfigRepository.ResolveConfiguration(ctx, nodeID, opts)
+	}
+	attributes, err := json.Marshal(opts.Context)
+	if err != nil {
+		return c.ConfigRepository.ResolveConfiguration(ctx, nodeID, opts)
+	}
+	key := strconv.FormatInt(nodeID, 10) + "\x00" + opts.Environment + "\x00" + opts.Namespace + "\x00" + string(attributes)
+
+	c.mu.Lock()
+	entry, ok := c.entries[key]
+	generation := c.generation
+	c.mu.Unlock()
+	if ok && time.Now().Before(entry.expires) {
+		return copyResolution(entry.resolved), nil
+	}
+
+	resolved, err := c.ConfigRepository.ResolveConfiguration(ctx, nodeID, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.generation == generation {
+		c.store(key, cachedResolution{resolved: copyResolution(resolved), expires: time.Now().Add(c.ttl)})
+	}
+	return resolved, nil
+}
+
+// store adds an entry, making room by dropping expired entries, or any entry
+// if none has expired
+func (c *ResolutionCache) store(key string, entry cachedResolution) {
+	if _, ok := c.entries[key]; !ok && len(c.entries) >= c.size {
+		now := time.Now()
+		for k, e := range c.entries {
+			if now.After(e.expires) {
+				delete(c.entries, k)
+			}
+		}
+		for k := range c.entries {
+			if len(c.entries) < c.size {
+				break
+			}
+			delete(c.entries, k)
+		}
+	}
+	c.entries[key] = entry
+}
+
+// Invalidate drops the cached resolutions of nodeID and its descendants
+func (c *ResolutionCache) Invalidate(nodeID int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.generation++
+	for key, entry := range c.entries {
+		for _, node := range entry.resolved.Path {
+			if node.ID == nodeID {
+				delete(c.entries, key)
+				break
+			}
+		}
+	}
+}
+
+// Reset drops every cached resolution
+func (c *ResolutionCache) Reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.generation++
+	clear(c.entries)
+}
+
+// copyResolution copies a resolution so that callers adding to it, e.g.
+// warnings, do not change the cached one
+func copyResolution(resolved *models.ResolvedConfiguration) *models.ResolvedConfiguration {
+	copied := *resolved
+	copied.Properties = maps.Clone(resolved.Properties)
+	return &copied
+}
@@ -0,0 +1,270 @@
+package database
+
+import (
+	"config-manager/internal/models"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+)
+
+// resolutionsLockID is the advisory lock held while refreshing materialized
+// resolutions, so that refreshes run one at a time
+const resolutionsLockID = 7305693462816140003
+
+// refreshBatchSize is the number of materialized resolutions written per statement
+const refreshBatchSize = 500
+
+// MaterializeResolutions makes ResolveConfiguration answer from the
+// resolved_configurations table when it holds an up-to-date resolution, which
+// RefreshResolutions keeps it doing. Only resolutions without context,
+// namespace, drafts, previews or point in time are materialized.
+func (r *Repository) MaterializeResolutions() {
+	r.materialized = true
+}
+
+func materializable(opts models.ResolveOptions) bool {
+	return len(opts.Context) == 0 && opts.Namespace == "" && !opts.IncludeDrafts && len(opts.Preview) == 0 && opts.AsOf == nil
+}
+
+// materializedResolution returns the materialized resolution of nodeID in
+// environment, or nil if there is none that is up to date
+func (r *Repository) materializedResolution(ctx context.Context, nodeID int64, environment string) (*models.ResolvedConfiguration, error) {
+	query := `
+		SELECT resolved FROM resolved_configurations
+		WHERE node_id = $1 AND environment = $2 AND resolved IS NOT NULL AND NOT stale
+			AND (valid_until IS NULL OR valid_until > now())`
+
+	var encoded []byte
+	err := r.db.QueryRowContext(ctx, query, nodeID, environment).Scan(&encoded)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var resolved models.ResolvedConfiguration
+	if err := json.Unmarshal(encoded, &resolved); err != nil {
+		return nil, err
+	}
+	return &resolved, nil
+}
+
+// CountMaterializedResolutions returns the number of materialized resolutions,
+// up to date or not
+func (r *Repository) CountMaterializedResolutions(ctx context.Context) (int, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	var count int
+	err := r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM resolved_configurations`).Scan(&count)
+	return count, err
+}
+
+// RefreshResolutions recomputes the materialized resolutions of the subtree of
+// rootID, or of the whole tree when rootID is nil, for no environment and for
+// each environment properties are set for on their paths. It returns the
+// number of resolutions written. A resolution invalidated while the refresh
+// runs is left stale for the refresh of that change. Refreshes run one at a
+// time across servers, and are not bounded by the query timeout as large
+// subtrees take a while.
+func (r *Repository) RefreshResolutions(ctx context.Context, rootID *int64) (int, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock($1)`, int64(resolutionsLockID)); err != nil {
+		return 0, err
+	}
+
+	// Versions are read before the configuration, so that rows a change marks
+	// stale from then on are not overwritten
+	versions, err := materializedVersions(ctx, tx, rootID)
+	if err != nil {
+		return 0, err
+	}
+
+	var ancestors []models.ConfigNode
+	properties := make(map[int64][]models.ConfigProperty)
+	if rootID != nil {
+		chain, chainProperties, err := r.resolutionChain(ctx, *rootID)
+		if err != nil || len(chain) == 0 {
+			return 0, err
+		}
+		ancestors = chain[:len(chain)-1]
+		properties = chainProperties
+	}
+	subtree, err := r.GetSubtreeWithProperties(ctx, rootID)
+	if err != nil {
+		return 0, err
+	}
+	ids := nodeIDs(ancestors)
+	for _, node := range subtree {
+		ids = append(ids, node.ID)
+		properties[node.ID] = node.Properties
+	}
+	templates, err := r.getTemplateProperties(ctx, ids)
+	if err != nil {
+		return 0, err
+	}
+	configVersions, err := r.configVersions(ctx, ids)
+	if err != nil {
+		return 0, err
+	}
+
+	environments := map[string]bool{"": true}
+	for key := range versions {
+		environments[key.environment] = true
+	}
+	for _, byNode := range []map[int64][]models.ConfigProperty{properties, templates} {
+		for _, props := range byNode {
+			for _, prop := range props {
+				if prop.Environment != nil {
+					environments[*prop.Environment] = true
+				}
+			}
+		}
+	}
+
+	// Paths are built from the root down, parents come before their children
+	paths := make(map[int64][]models.ConfigNode, len(subtree))
+	batch := make([]materializedRow, 0, refreshBatchSize)
+	written := 0
+	for _, node := range subtree {
+		path := append(append([]models.ConfigNode(nil), ancestors...), node.ConfigNode)
+		if node.ParentID != nil {
+			if parentPath, ok := paths[*node.ParentID]; ok {
+				path = append(append([]models.ConfigNode(nil), parentPath...), node.ConfigNode)
+			}
+		}
+		paths[node.ID] = path
+
+		for environment := range environments {
+			key := materializedKey{nodeID: node.ID, environment: environment}
+			batch = append(batch, materialize(key, versions[key], path, properties, templates, configVersions))
+			if len(batch) == refreshBatchSize {
+				if err := writeMaterialized(ctx, tx, batch); err != nil {
+					return 0, err
+				}
+				written += len(batch)
+				batch = batch[:0]
+			}
+		}
+	}
+	if err := writeMaterialized(ctx, tx, batch); err != nil {
+		return 0, err
+	}
+	written += len(batch)
+
+	return written, tx.Commit()
+}
+
+type materializedKey struct {
+	nodeID      int64
+	environment string
+}
+
+type materializedRow struct {
+	materializedKey
+	version    int64
+	resolved   *string
+	validUntil *time.Time
+}
+
+// materializedVersions returns the versions of the materialized resolutions
+// of the subtree of rootID, or of the whole tree when rootID is nil
+func materializedVersions(ctx context.Context, tx *sql.Tx, rootID *int64) (map[materializedKey]int64, error) {
+	query := `
+		WITH RECURSIVE tree AS (
+			SELECT id FROM config_nodes
+			WHERE ($1::bigint IS NULL AND parent_id IS NULL) OR id = $1
+			UNION ALL
+			SELECT n.id FROM config_nodes n JOIN tree t ON n.parent_id = t.id
+		)
+		SELECT r.node_id, r.environment, r.version
+		FROM resolved_configurations r JOIN tree t ON r.node_id = t.id`
+
+	rows, err := tx.QueryContext(ctx, query, rootID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	versions := make(map[materializedKey]int64)
+	for rows.Next() {
+		var key materializedKey
+		var version int64
+		if err := rows.Scan(&key.nodeID, &key.environment, &version); err != nil {
+			return nil, err
+		}
+		versions[key] = version
+	}
+	return versions, rows.Err()
+}
+
+// materialize resolves path in the environment of key. Resolutions holding
+// encrypted values are not stored in the clear, and those that fail, e.g. on
+// a broken reference, fail on read; neither is materialized.
+func materialize(key materializedKey, version int64, path []models.ConfigNode, properties, templates map[int64][]models.ConfigProperty, configVersions map[int64]int64) materializedRow {
+	row := materializedRow{materializedKey: key, version: version}
+
+	now := time.Now()
+	for _, node := range path {
+		for _, prop := range append(forEnvironment(templates[node.ID], key.environment), forEnvironment(properties[node.ID], key.environment)...) {
+			if prop.Encrypted {
+				return row
+			}
+			if prop.ExpiresAt != nil && prop.ExpiresAt.After(now) && (row.validUntil == nil || prop.ExpiresAt.Before(*row.validUntil)) {
+				row.validUntil = prop.ExpiresAt
+			}
+		}
+	}
+
+	resolved, err := resolvePath(path, templates, models.ResolveOptions{Environment: key.environment}, func(nodeID int64) ([]models.ConfigProperty, error) {
+		return properties[nodeID], nil
+	})
+	if err != nil {
+		row.validUntil = nil
+		return row
+	}
+	resolved.Version = pathVersion(path, configVersions)
+	encoded, err := json.Marshal(resolved)
+	if err != nil {
+		row.validUntil = nil
+		return row
+	}
+	stored := string(encoded)
+	row.resolved = &stored
+	return row
+}
+
+// writeMaterialized stores a batch of resolutions, skipping those whose
+// version changed since it was read
+func writeMaterialized(ctx context.Context, tx *sql.Tx, batch []materializedRow) error {
+	if len(batch) == 0 {
+		return nil
+	}
+
+	nodeIDs := make([]int64, len(batch))
+	environments := make([]string, len(batch))
+	resolved := make([]*string, len(batch))
+	validUntil := make([]*time.Time, len(batch))
+	versions := make([]int64, len(batch))
+	for i, row := range batch {
+		nodeIDs[i], environments[i], resolved[i], validUntil[i], versions[i] = row.nodeID, row.environment, row.resolved, row.validUntil, row.version
+	}
+
+	_, err := tx.ExecContext(ctx, `
+		INSERT INTO resolved_configurations (node_id, environment, resolved, valid_until, version)
+		SELECT node_id, environment, resolved::jsonb, valid_until, version
+		FROM unnest($1::bigint[], $2::text[], $3::text[], $4::timestamptz[], $5::bigint[])
+			AS t(node_id, environment, resolved, valid_until, version)
+		ON CONFLICT (node_id, environment) DO UPDATE
+		SET resolved = EXCLUDED.resolved, valid_until = EXCLUDED.valid_until, stale = FALSE, refreshed_at = now()
+		WHERE resolved_configurations.version = EXCLUDED.version`,
+		nodeIDs, environments, resolved, validUntil, versions)
+	return err
+}
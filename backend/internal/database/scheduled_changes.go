@@ -0,0 +1,223 @@
+package database
+
+import (
+	"config-manager/internal/models"
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+var (
+	ErrScheduledChangeNotFound   = errors.New("scheduled change not found")
+	ErrScheduledChangeNotPending = errors.New("scheduled change is no longer pending")
+	ErrScheduledChangeEncrypted  = errors.New("changes to encrypted properties cannot be scheduled")
+)
+
+const scheduledChangeColumns = `id, node_id, key, environment, value, data_type, default_value, description, effective_at, status, property_id, error, created_at, applied_at`
+
+func scanScheduledChange(row rowScanner) (*models.ScheduledChange, error) {
+	var change models.ScheduledChange
+	err := row.Scan(
+		&change.ID, &change.NodeID, &change.Key, &change.Environment, &change.Value, &change.DataType, &change.DefaultValue, &change.Description,
+		&change.EffectiveAt, &change.Status, &change.PropertyID, &change.Error, &change.CreatedAt, &change.AppliedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &change, nil
+}
+
+// CreateScheduledChange records a property write to apply at req.EffectiveAt.
+// Values are stored in plain text until applied, so encrypted properties are
+// rejected.
+func (r *Repository) CreateScheduledChange(ctx context.Context, nodeID int64, req models.CreateScheduledChangeRequest) (*models.ScheduledChange, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	var encrypted bool
+	err := r.db.QueryRowContext(ctx, `
+		SELECT encrypted FROM config_properties
+		WHERE node_id = $1 AND key = $2 AND COALESCE(environment, '') = COALESCE($3, '')`,
+		nodeID, req.Key, req.Environment).Scan(&encrypted)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+	if encrypted {
+		return nil, ErrScheduledChangeEncrypted
+	}
+
+	query := `
+		INSERT INTO scheduled_property_changes (node_id, key, environment, value, data_type, default_value, description, effective_at, status, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		RETURNING ` + scheduledChangeColumns
+
+	return scanScheduledChange(r.db.QueryRowContext(ctx, query,
+		nodeID, req.Key, req.Environment, req.Value, req.DataType, req.DefaultValue, req.Description,
+		req.EffectiveAt, models.ScheduledChangeStatusPending, time.Now()))
+}
+
+func (r *Repository) GetScheduledChanges(ctx context.Context, nodeID int64) ([]models.ScheduledChange, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	query := `SELECT ` + scheduledChangeColumns + ` FROM scheduled_property_changes WHERE node_id = $1 ORDER BY effective_at, id`
+
+	rows, err := r.db.QueryContext(ctx, query, nodeID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	changes := []models.ScheduledChange{}
+	for rows.Next() {
+		change, err := scanScheduledChange(rows)
+		if err != nil {
+			return nil, err
+		}
+		changes = append(changes, *change)
+	}
+
+	return changes, nil
+}
+
+// GetScheduledChangeNodeID returns the node a scheduled change targets, or nil if it does not exist
+func (r *Repository) GetScheduledChangeNodeID(ctx context.Context, id int64) (*int64, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	var nodeID int64
+	err := r.db.QueryRowContext(ctx, `SELECT node_id FROM scheduled_property_changes WHERE id = $1`, id).Scan(&nodeID)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &nodeID, nil
+}
+
+// CancelScheduledChange cancels a change that has not been applied yet
+func (r *Repository) CancelScheduledChange(ctx context.Context, id int64) error {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	result, err := r.db.ExecContext(ctx, `UPDATE scheduled_property_changes SET status = $1 WHERE id = $2 AND status = $3`,
+		models.ScheduledChangeStatusCancelled, id, models.ScheduledChangeStatusPending)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		nodeID, err := r.GetScheduledChangeNodeID(ctx, id)
+		if err != nil {
+			return err
+		}
+		if nodeID == nil {
+			return ErrScheduledChangeNotFound
+		}
+		return ErrScheduledChangeNotPending
+	}
+
+	return nil
+}
+
+// NextScheduledChangeAt returns when the earliest pending change is due, or nil if there is none
+func (r *Repository) NextScheduledChangeAt(ctx context.Context) (*time.Time, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	var next sql.NullTime
+	err := r.db.QueryRowContext(ctx, `SELECT MIN(effective_at) FROM scheduled_property_changes WHERE status = $1`,
+		models.ScheduledChangeStatusPending).Scan(&next)
+	if err != nil || !next.Valid {
+		return nil, err
+	}
+	return &next.Time, nil
+}
+
+// ApplyDueScheduledChange applies the earliest pending change due at now, each
+// in its own transaction. Rows locked by another server are skipped, so several
+// replicas can run the scheduler. It returns nil when nothing is due; a change
+// that cannot be applied is marked failed and returned with its error.
+func (r *Repository) ApplyDueScheduledChange(ctx context.Context, now time.Time) (*models.ScheduledChange, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// Change events recorded by this transaction name the scheduler as their source
+	if _, err := tx.ExecContext(ctx, `SET LOCAL config_manager.event_source = 'scheduler'`); err != nil {
+		return nil, err
+	}
+
+	change, err := scanScheduledChange(tx.QueryRowContext(ctx, `
+		SELECT `+scheduledChangeColumns+` FROM scheduled_property_changes
+		WHERE status = $1 AND effective_at <= $2
+		ORDER BY effective_at, id
+		LIMIT 1
+		FOR UPDATE SKIP LOCKED`, models.ScheduledChangeStatusPending, now))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Encrypted properties are left untouched, see CreateScheduledChange
+	var propertyID int64
+	applyErr := tx.QueryRowContext(ctx, `
+		INSERT INTO config_properties (node_id, key, environment, value, data_type, default_value, description, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $8)
+		ON CONFLICT (node_id, key, (COALESCE(environment, '')))
+		DO UPDATE SET
+			value = EXCLUDED.value,
+			data_type = EXCLUDED.data_type,
+			default_value = EXCLUDED.default_value,
+			description = EXCLUDED.description,
+			updated_at = EXCLUDED.updated_at
+		WHERE NOT config_properties.encrypted
+		RETURNING id`,
+		change.NodeID, change.Key, change.Environment, change.Value, change.DataType, change.DefaultValue, change.Description, now,
+	).Scan(&propertyID)
+
+	if applyErr != nil {
+		if applyErr == sql.ErrNoRows {
+			applyErr = ErrScheduledChangeEncrypted
+		}
+		// Roll back the partial write, then record the failure on its own
+		tx.Rollback()
+		message := applyErr.Error()
+		_, err := r.db.ExecContext(ctx, `UPDATE scheduled_property_changes SET status = $1, error = $2 WHERE id = $3`,
+			models.ScheduledChangeStatusFailed, message, change.ID)
+		if err != nil {
+			return nil, err
+		}
+		change.Status = models.ScheduledChangeStatusFailed
+		change.Error = &message
+		return change, applyErr
+	}
+
+	_, err = tx.ExecContext(ctx, `UPDATE scheduled_property_changes SET status = $1, property_id = $2, applied_at = $3 WHERE id = $4`,
+		models.ScheduledChangeStatusApplied, propertyID, now, change.ID)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	change.Status = models.ScheduledChangeStatusApplied
+	change.PropertyID = &propertyID
+	change.AppliedAt = &now
+	return change, nil
+}
@@ -0,0 +1,52 @@
+package database
+
+import (
+	"config-manager/internal/models"
+	"context"
+	"database/sql"
+	"errors"
+)
+
+// AuditActionPropertyReveal is the audit log action of revealing a secret value
+const AuditActionPropertyReveal = "property.reveal"
+
+var ErrPropertyNotSecret = errors.New("the property is not a secret")
+
+// RevealProperty returns an encrypted property with its plaintext value and
+// records in the audit log that actor revealed it. The value is only returned
+// once the entry is committed.
+func (r *Repository) RevealProperty(ctx context.Context, id int64, actor string) (*models.ConfigProperty, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	prop, err := r.scanProperty(ctx, tx.QueryRowContext(ctx, `SELECT `+propertyColumns+` FROM config_properties WHERE id = $1`, id))
+	if err == sql.ErrNoRows {
+		return nil, ErrPropertyNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !prop.Encrypted {
+		return nil, ErrPropertyNotSecret
+	}
+
+	_, err = recordAudit(ctx, tx, AuditActionPropertyReveal, actor, map[string]interface{}{
+		"property_id": prop.ID,
+		"node_id":     prop.NodeID,
+		"key":         prop.Key,
+		"environment": prop.Environment,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return prop, nil
+}
@@ -0,0 +1,37 @@
+package database
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// ErrNodeNameTaken is returned when a node would get the name of one of its
+// siblings, compared case-insensitively
+var ErrNodeNameTaken = errors.New("a sibling node already has this name")
+
+// SiblingNameTaken reports whether a child of parentID, or a root node when
+// parentID is nil, other than excludeID has name in any case
+func (r *Repository) SiblingNameTaken(ctx context.Context, parentID *int64, name string, excludeID int64) (bool, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	query := `
+		SELECT EXISTS(
+			SELECT 1 FROM config_nodes
+			WHERE parent_id IS NOT DISTINCT FROM $1 AND lower(name) = lower($2) AND id <> $3
+		)`
+
+	var taken bool
+	err := r.db.QueryRowContext(ctx, query, parentID, name, excludeID).Scan(&taken)
+	return taken, err
+}
+
+// isNodeNameConflict reports whether err is a violation of the unique sibling
+// name indexes
+func isNodeNameConflict(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23505" &&
+		(pgErr.ConstraintName == "idx_config_nodes_sibling_name" || pgErr.ConstraintName == "idx_config_nodes_root_name")
+}
@@ -0,0 +1,139 @@
+package database
+
+import (
+	"config-manager/internal/models"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+var ErrSnapshotNameTaken = errors.New("node already has a snapshot with this name")
+
+const snapshotColumns = `id, node_id, node_name, name, description, environment, properties, redacted_keys, created_by, created_at`
+
+func scanSnapshot(row rowScanner) (*models.ConfigSnapshot, error) {
+	var snapshot models.ConfigSnapshot
+	var properties, redacted []byte
+	err := row.Scan(
+		&snapshot.ID, &snapshot.NodeID, &snapshot.NodeName, &snapshot.Name, &snapshot.Description, &snapshot.Environment,
+		&properties, &redacted, &snapshot.CreatedBy, &snapshot.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(properties, &snapshot.Properties); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(redacted, &snapshot.RedactedKeys); err != nil {
+		return nil, err
+	}
+	return &snapshot, nil
+}
+
+// ResolveRedacted resolves the configuration of a node without its encrypted
+// properties, returning the keys that were left out. Resolution errors are
+// those of ResolveConfiguration.
+func (r *Repository) ResolveRedacted(ctx context.Context, nodeID int64, environment string) (*models.ResolvedConfiguration, []string, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	resolved, err := r.ResolveConfiguration(ctx, nodeID, models.ResolveOptions{Environment: environment})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	effective, err := r.GetEffectiveProperties(ctx, nodeID, environment)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	redacted := []string{}
+	for key, prop := range effective {
+		if prop.Encrypted {
+			delete(resolved.Properties, key)
+			redacted = append(redacted, key)
+		}
+	}
+	sort.Strings(redacted)
+
+	return resolved, redacted, nil
+}
+
+// CreateSnapshot freezes the current resolved configuration of a node.
+// Snapshot names are unique per node.
+func (r *Repository) CreateSnapshot(ctx context.Context, nodeID int64, req models.CreateSnapshotRequest, createdBy string) (*models.ConfigSnapshot, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	resolved, redacted, err := r.ResolveRedacted(ctx, nodeID, req.Environment)
+	if err != nil {
+		return nil, err
+	}
+
+	properties, err := json.Marshal(resolved.Properties)
+	if err != nil {
+		return nil, err
+	}
+	redactedKeys, err := json.Marshal(redacted)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+		INSERT INTO config_snapshots (node_id, node_name, name, description, environment, properties, redacted_keys, created_by, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		RETURNING ` + snapshotColumns
+
+	snapshot, err := scanSnapshot(r.db.QueryRowContext(ctx, query,
+		nodeID, resolved.NodeName, req.Name, req.Description, req.Environment, properties, redactedKeys, createdBy, time.Now()))
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			return nil, ErrSnapshotNameTaken
+		}
+		return nil, err
+	}
+	return snapshot, nil
+}
+
+// GetSnapshots lists the snapshots of a node, newest first
+func (r *Repository) GetSnapshots(ctx context.Context, nodeID int64) ([]models.ConfigSnapshot, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	query := `SELECT ` + snapshotColumns + ` FROM config_snapshots WHERE node_id = $1 ORDER BY created_at DESC, id DESC`
+
+	rows, err := r.db.QueryContext(ctx, query, nodeID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	snapshots := []models.ConfigSnapshot{}
+	for rows.Next() {
+		snapshot, err := scanSnapshot(rows)
+		if err != nil {
+			return nil, err
+		}
+		snapshots = append(snapshots, *snapshot)
+	}
+
+	return snapshots, rows.Err()
+}
+
+// GetSnapshot returns a snapshot, or nil if it does not exist
+func (r *Repository) GetSnapshot(ctx context.Context, id int64) (*models.ConfigSnapshot, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	snapshot, err := scanSnapshot(r.db.QueryRowContext(ctx, `SELECT `+snapshotColumns+` FROM config_snapshots WHERE id = $1`, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return snapshot, err
+}
@@ -0,0 +1,175 @@
+package database
+
+import (
+	"config-manager/internal/models"
+	"context"
+	"time"
+)
+
+// statsDays is the number of days, today included, of changes in the stats
+const statsDays = 7
+
+// GetStats summarizes the tree: node and property totals, the number of nodes
+// at each depth, the topKeys keys overridden by the most nodes and the volume
+// of changes over the last week. Each figure is one aggregate query, so the
+// stats are not a snapshot of a single moment.
+func (r *Repository) GetStats(ctx context.Context, topKeys int) (*models.AdminStats, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	stats := &models.AdminStats{GeneratedAt: time.Now()}
+
+	if err := r.countNodes(ctx, &stats.Nodes); err != nil {
+		return nil, err
+	}
+
+	err := r.db.QueryRowContext(ctx, `SELECT COUNT(*), COUNT(*) FILTER (WHERE encrypted) FROM config_properties`).
+		Scan(&stats.Properties.Total, &stats.Properties.Secrets)
+	if err != nil {
+		return nil, err
+	}
+
+	if stats.DepthDistribution, err = r.depthDistribution(ctx); err != nil {
+		return nil, err
+	}
+	if stats.MostOverriddenKeys, err = r.mostOverriddenKeys(ctx, topKeys); err != nil {
+		return nil, err
+	}
+	if err := r.countChanges(ctx, stats.GeneratedAt, &stats.RecentChanges); err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+func (r *Repository) countNodes(ctx context.Context, nodes *models.NodeStats) error {
+	rows, err := r.db.QueryContext(ctx, `SELECT node_type, COUNT(*) FROM config_nodes GROUP BY node_type`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	nodes.ByType = make(map[string]int)
+	for rows.Next() {
+		var nodeType string
+		var count int
+		if err := rows.Scan(&nodeType, &count); err != nil {
+			return err
+		}
+		nodes.ByType[nodeType] = count
+		nodes.Total += count
+	}
+	return rows.Err()
+}
+
+// depthDistribution returns the number of nodes at each level, roots first
+func (r *Repository) depthDistribution(ctx context.Context) ([]models.DepthCount, error) {
+	query := `
+		WITH RECURSIVE tree AS (
+			SELECT id, 1 AS depth FROM config_nodes WHERE parent_id IS NULL
+			UNION ALL
+			SELECT n.id, t.depth + 1 FROM config_nodes n JOIN tree t ON n.parent_id = t.id
+		)
+		SELECT depth, COUNT(*) FROM tree GROUP BY depth ORDER BY depth`
+
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	depths := []models.DepthCount{}
+	for rows.Next() {
+		var depth models.DepthCount
+		if err := rows.Scan(&depth.Depth, &depth.Nodes); err != nil {
+			return nil, err
+		}
+		depths = append(depths, depth)
+	}
+	return depths, rows.Err()
+}
+
+// mostOverriddenKeys returns up to limit keys by the number of nodes defining
+// them while an ancestor does too, in any environment. Each node carries the
+// IDs of its ancestors down the tree, so that an override is one index lookup
+// on the key.
+func (r *Repository) mostOverriddenKeys(ctx context.Context, limit int) ([]models.OverriddenKey, error) {
+	query := `
+		WITH RECURSIVE tree AS (
+			SELECT id, ARRAY[]::BIGINT[] AS ancestors FROM config_nodes WHERE parent_id IS NULL
+			UNION ALL
+			SELECT n.id, t.ancestors || t.id FROM config_nodes n JOIN tree t ON n.parent_id = t.id
+		)
+		SELECT p.key, COUNT(DISTINCT p.node_id) AS overrides
+		FROM tree t
+		JOIN config_properties p ON p.node_id = t.id
+		WHERE cardinality(t.ancestors) > 0 AND EXISTS (
+			SELECT 1 FROM config_properties a WHERE a.key = p.key AND a.node_id = ANY(t.ancestors)
+		)
+		GROUP BY p.key
+		ORDER BY overrides DESC, p.key
+		LIMIT $1`
+
+	rows, err := r.db.QueryContext(ctx, query, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := []models.OverriddenKey{}
+	for rows.Next() {
+		var key models.OverriddenKey
+		if err := rows.Scan(&key.Key, &key.Overrides); err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
+
+// countChanges counts the node and property events of the last statsDays days
+// in UTC, today included. Events are counted from the outbox, so days older
+// than its retention count none.
+func (r *Repository) countChanges(ctx context.Context, now time.Time, volume *models.ChangeVolume) error {
+	now = now.UTC()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	since := today.AddDate(0, 0, 1-statsDays)
+
+	query := `
+		SELECT to_char(occurred_at AT TIME ZONE 'UTC', 'YYYY-MM-DD'), type, COUNT(*),
+			COUNT(*) FILTER (WHERE occurred_at >= $2),
+			COUNT(*) FILTER (WHERE occurred_at >= $3)
+		FROM event_outbox
+		WHERE occurred_at >= $1 AND type <> 'config.invalidated'
+		GROUP BY 1, 2`
+
+	rows, err := r.db.QueryContext(ctx, query, since, now.Add(-time.Hour), now.Add(-24*time.Hour))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	byDay := make(map[string]int)
+	volume.ByType = make(map[string]int)
+	for rows.Next() {
+		var date, eventType string
+		var count, lastHour, lastDay int
+		if err := rows.Scan(&date, &eventType, &count, &lastHour, &lastDay); err != nil {
+			return err
+		}
+		byDay[date] += count
+		volume.ByType[eventType] += count
+		volume.Last7Days += count
+		volume.LastHour += lastHour
+		volume.Last24Hours += lastDay
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	volume.ByDay = make([]models.DailyChanges, statsDays)
+	for i := range volume.ByDay {
+		date := since.AddDate(0, 0, i).Format("2006-01-02")
+		volume.ByDay[i] = models.DailyChanges{Date: date, Changes: byDay[date]}
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+package database
+
+import (
+	"config-manager/internal/models"
+	"context"
+	"sort"
+)
+
+// SearchPropertiesByTags returns the properties carrying every tag in tags,
+// within the subtree of rootID or the whole tree when rootID is nil, ordered by
+// the path of their node
+func (r *Repository) SearchPropertiesByTags(ctx context.Context, tags []string, rootID *int64) ([]models.PropertySearchResult, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	return r.searchProperties(ctx, rootID, `c.tags @> $2::text[]`, NormalizeTags(tags))
+}
+
+// FilterTags returns the properties carrying every tag in tags
+func FilterTags(properties []models.ConfigProperty, tags []string) []models.ConfigProperty {
+	filtered := []models.ConfigProperty{}
+	for _, prop := range properties {
+		if hasTags(prop, tags) {
+			filtered = append(filtered, prop)
+		}
+	}
+	return filtered
+}
+
+// NormalizeTags sorts tags and drops duplicates, keeping nil as nil so that
+// updates can tell an absent list from an empty one
+func NormalizeTags(tags []string) []string {
+	if tags == nil {
+		return nil
+	}
+	normalized := make([]string, 0, len(tags))
+	seen := make(map[string]bool, len(tags))
+	for _, tag := range tags {
+		if !seen[tag] {
+			seen[tag] = true
+			normalized = append(normalized, tag)
+		}
+	}
+	sort.Strings(normalized)
+	return normalized
+}
+
+func hasTags(prop models.ConfigProperty, tags []string) bool {
+	for _, tag := range tags {
+		found := false
+		for _, own := range prop.Tags {
+			if own == tag {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,368 @@
+package database
+
+import (
+	"config-manager/internal/models"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+var (
+	// ErrTemplateNotFound is returned when a template does not exist
+	ErrTemplateNotFound = errors.New("template not found")
+
+	// ErrTemplateVersionNotFound is returned when pinning a version a template does not have
+	ErrTemplateVersionNotFound = errors.New("template version not found")
+
+	// ErrTemplateNameTaken is returned when a template would get the name of another one
+	ErrTemplateNameTaken = errors.New("a template already has this name")
+
+	// ErrTemplateInUse is returned when deleting a template that is attached to nodes
+	ErrTemplateInUse = errors.New("template is attached to nodes")
+)
+
+const templateColumns = `id, name, description, latest_version, created_by, created_at, updated_at`
+
+const templateVersionColumns = `template_id, version, properties, comment, created_by, created_at`
+
+func scanTemplate(row rowScanner) (*models.PropertyTemplate, error) {
+	var template models.PropertyTemplate
+	err := row.Scan(&template.ID, &template.Name, &template.Description, &template.LatestVersion, &template.CreatedBy, &template.CreatedAt, &template.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &template, nil
+}
+
+func scanTemplateVersion(row rowScanner) (*models.TemplateVersion, error) {
+	var version models.TemplateVersion
+	var properties []byte
+	err := row.Scan(&version.TemplateID, &version.Version, &properties, &version.Comment, &version.CreatedBy, &version.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(properties, &version.Properties); err != nil {
+		return nil, err
+	}
+	if version.Properties == nil {
+		version.Properties = []models.TemplateProperty{}
+	}
+	return &version, nil
+}
+
+// CreateTemplate creates a template with its properties as version 1
+func (r *Repository) CreateTemplate(ctx context.Context, req models.CreateTemplateRequest, createdBy string) (*models.PropertyTemplateWithVersion, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	properties, err := json.Marshal(req.Properties)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	template, err := scanTemplate(tx.QueryRowContext(ctx, `
+		INSERT INTO config_templates (name, description, latest_version, created_by, created_at, updated_at)
+		VALUES ($1, $2, 1, $3, $4, $4)
+		RETURNING `+templateColumns, req.Name, req.Description, createdBy, now))
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			return nil, ErrTemplateNameTaken
+		}
+		return nil, err
+	}
+
+	version, err := scanTemplateVersion(tx.QueryRowContext(ctx, `
+		INSERT INTO config_template_versions (template_id, version, properties, comment, created_by, created_at)
+		VALUES ($1, 1, $2, '', $3, $4)
+		RETURNING `+templateVersionColumns, template.ID, properties, createdBy, now))
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return &models.PropertyTemplateWithVersion{PropertyTemplate: *template, Properties: version.Properties}, nil
+}
+
+// GetTemplates lists the templates by name
+func (r *Repository) GetTemplates(ctx context.Context) ([]models.PropertyTemplate, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := r.db.QueryContext(ctx, `SELECT `+templateColumns+` FROM config_templates ORDER BY name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	templates := []models.PropertyTemplate{}
+	for rows.Next() {
+		template, err := scanTemplate(rows)
+		if err != nil {
+			return nil, err
+		}
+		templates = append(templates, *template)
+	}
+	return templates, rows.Err()
+}
+
+// GetTemplate returns a template with the properties of its latest version,
+// or nil if it does not exist
+func (r *Repository) GetTemplate(ctx context.Context, id int64) (*models.PropertyTemplateWithVersion, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	template, err := scanTemplate(r.db.QueryRowContext(ctx, `SELECT `+templateColumns+` FROM config_templates WHERE id = $1`, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	version, err := r.GetTemplateVersion(ctx, id, template.LatestVersion)
+	if err != nil {
+		return nil, err
+	}
+	return &models.PropertyTemplateWithVersion{PropertyTemplate: *template, Properties: version.Properties}, nil
+}
+
+// GetTemplateVersions lists the versions of a template, newest first
+func (r *Repository) GetTemplateVersions(ctx context.Context, id int64) ([]models.TemplateVersion, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := r.db.QueryContext(ctx, `SELECT `+templateVersionColumns+` FROM config_template_versions WHERE template_id = $1 ORDER BY version DESC`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	versions := []models.TemplateVersion{}
+	for rows.Next() {
+		version, err := scanTemplateVersion(rows)
+		if err != nil {
+			return nil, err
+		}
+		versions = append(versions, *version)
+	}
+	return versions, rows.Err()
+}
+
+// GetTemplateVersion returns one version of a template, or nil if it does not exist
+func (r *Repository) GetTemplateVersion(ctx context.Context, id int64, version int) (*models.TemplateVersion, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	query := `SELECT ` + templateVersionColumns + ` FROM config_template_versions WHERE template_id = $1 AND version = $2`
+
+	templateVersion, err := scanTemplateVersion(r.db.QueryRowContext(ctx, query, id, version))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return templateVersion, err
+}
+
+// CreateTemplateVersion publishes new properties for a template as its next
+// version. Nodes following the latest version pick them up immediately.
+func (r *Repository) CreateTemplateVersion(ctx context.Context, id int64, req models.CreateTemplateVersionRequest, createdBy string) (*models.TemplateVersion, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	properties, err := json.Marshal(req.Properties)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	var next int
+	err = tx.QueryRowContext(ctx, `
+		UPDATE config_templates SET latest_version = latest_version + 1, updated_at = $2
+		WHERE id = $1 RETURNING latest_version`, id, now).Scan(&next)
+	if err == sql.ErrNoRows {
+		return nil, ErrTemplateNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	version, err := scanTemplateVersion(tx.QueryRowContext(ctx, `
+		INSERT INTO config_template_versions (template_id, version, properties, comment, created_by, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING `+templateVersionColumns, id, next, properties, req.Comment, createdBy, now))
+	if err != nil {
+		return nil, err
+	}
+
+	return version, tx.Commit()
+}
+
+// DeleteTemplate deletes a template and its versions, reporting whether it
+// existed. Templates still attached to nodes cannot be deleted.
+func (r *Repository) DeleteTemplate(ctx context.Context, id int64) (bool, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	result, err := r.db.ExecContext(ctx, `DELETE FROM config_templates WHERE id = $1`, id)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23503" {
+			return false, ErrTemplateInUse
+		}
+		return false, err
+	}
+	rowsAffected, err := result.RowsAffected()
+	return rowsAffected > 0, err
+}
+
+const nodeTemplateQuery = `
+	SELECT nt.id, nt.node_id, nt.template_id, t.name, nt.version, COALESCE(nt.version, t.latest_version), nt.attached_by, nt.attached_at
+	FROM config_node_templates nt JOIN config_templates t ON t.id = nt.template_id`
+
+func scanNodeTemplate(row rowScanner) (*models.NodeTemplate, error) {
+	var attached models.NodeTemplate
+	err := row.Scan(&attached.ID, &attached.NodeID, &attached.TemplateID, &attached.TemplateName, &attached.Version, &attached.AppliedVersion, &attached.AttachedBy, &attached.AttachedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &attached, nil
+}
+
+// AttachTemplate attaches a template to a node, pinned to req.Version or
+// following the latest version. Attaching an attached template again changes
+// the version it is pinned to.
+func (r *Repository) AttachTemplate(ctx context.Context, nodeID int64, req models.AttachTemplateRequest, attachedBy string) (*models.NodeTemplate, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	var latest int
+	err := r.db.QueryRowContext(ctx, `SELECT latest_version FROM config_templates WHERE id = $1`, req.TemplateID).Scan(&latest)
+	if err == sql.ErrNoRows {
+		return nil, ErrTemplateNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if req.Version != nil && (*req.Version < 1 || *req.Version > latest) {
+		return nil, ErrTemplateVersionNotFound
+	}
+
+	var id int64
+	err = r.db.QueryRowContext(ctx, `
+		INSERT INTO config_node_templates (node_id, template_id, version, attached_by, attached_at)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (node_id, template_id) DO UPDATE SET version = EXCLUDED.version
+		RETURNING id`, nodeID, req.TemplateID, req.Version, attachedBy, time.Now()).Scan(&id)
+	if err != nil {
+		return nil, err
+	}
+
+	return scanNodeTemplate(r.db.QueryRowContext(ctx, nodeTemplateQuery+` WHERE nt.id = $1`, id))
+}
+
+// GetNodeTemplates lists the templates attached to a node, in the order they
+// apply: later attachments take precedence over earlier ones
+func (r *Repository) GetNodeTemplates(ctx context.Context, nodeID int64) ([]models.NodeTemplate, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := r.db.QueryContext(ctx, nodeTemplateQuery+` WHERE nt.node_id = $1 ORDER BY nt.attached_at, nt.id`, nodeID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	attached := []models.NodeTemplate{}
+	for rows.Next() {
+		template, err := scanNodeTemplate(rows)
+		if err != nil {
+			return nil, err
+		}
+		attached = append(attached, *template)
+	}
+	return attached, rows.Err()
+}
+
+// DetachTemplate detaches a template from a node, reporting whether it was attached
+func (r *Repository) DetachTemplate(ctx context.Context, nodeID, templateID int64) (bool, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	result, err := r.db.ExecContext(ctx, `DELETE FROM config_node_templates WHERE node_id = $1 AND template_id = $2`, nodeID, templateID)
+	if err != nil {
+		return false, err
+	}
+	rowsAffected, err := result.RowsAffected()
+	return rowsAffected > 0, err
+}
+
+// getTemplateProperties returns the properties that the templates attached to
+// the given nodes contribute, keyed by node, in the order they apply
+func (r *Repository) getTemplateProperties(ctx context.Context, ids []int64) (map[int64][]models.ConfigProperty, error) {
+	query := `
+		SELECT nt.node_id, v.properties
+		FROM config_node_templates nt
+		JOIN config_templates t ON t.id = nt.template_id
+		JOIN config_template_versions v ON v.template_id = t.id AND v.version = COALESCE(nt.version, t.latest_version)
+		WHERE nt.node_id = ANY($1)
+		ORDER BY nt.node_id, nt.attached_at, nt.id`
+
+	rows, err := r.db.QueryContext(ctx, query, ids)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	properties := make(map[int64][]models.ConfigProperty)
+	for rows.Next() {
+		var nodeID int64
+		var encoded []byte
+		if err := rows.Scan(&nodeID, &encoded); err != nil {
+			return nil, err
+		}
+		var provided []models.TemplateProperty
+		if err := json.Unmarshal(encoded, &provided); err != nil {
+			return nil, err
+		}
+		for _, prop := range provided {
+			properties[nodeID] = append(properties[nodeID], models.ConfigProperty{
+				NodeID:      nodeID,
+				Key:         prop.Key,
+				Environment: prop.Environment,
+				Value:       prop.Value,
+				DataType:    prop.DataType,
+				Description: prop.Description,
+				Tags:        []string{},
+			})
+		}
+	}
+	return properties, rows.Err()
+}
+
+// nodeIDs returns the IDs of the given nodes
+func nodeIDs(nodes []models.ConfigNode) []int64 {
+	ids := make([]int64, len(nodes))
+	for i, node := range nodes {
+		ids[i] = node.ID
+	}
+	return ids
+}
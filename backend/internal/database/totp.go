@@ -0,0 +1,107 @@
+package database
+
+import (
+	"config-manager/internal/models"
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+var (
+	ErrTOTPEnrolled    = errors.New("an authenticator app is already enrolled")
+	ErrTOTPNotEnrolled = errors.New("no authenticator app is enrolled")
+)
+
+const totpColumns = `subject, secret, last_step, created_at, confirmed_at`
+
+func scanTOTPEnrollment(row rowScanner) (*models.TOTPEnrollment, error) {
+	var enrollment models.TOTPEnrollment
+	if err := row.Scan(&enrollment.Subject, &enrollment.Secret, &enrollment.LastStep, &enrollment.CreatedAt, &enrollment.ConfirmedAt); err != nil {
+		return nil, err
+	}
+	return &enrollment, nil
+}
+
+// GetTOTPEnrollment returns the authenticator app enrolled by a user, or nil if
+// there is none
+func (r *Repository) GetTOTPEnrollment(ctx context.Context, subject string) (*models.TOTPEnrollment, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	query := `SELECT ` + totpColumns + ` FROM totp_enrollments WHERE subject = $1`
+
+	enrollment, err := scanTOTPEnrollment(r.db.QueryRowContext(ctx, query, subject))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return enrollment, err
+}
+
+// EnrollTOTP stores the secret of a new authenticator app. It replaces an
+// enrollment that was never confirmed, and fails with ErrTOTPEnrolled if the
+// user already confirmed one.
+func (r *Repository) EnrollTOTP(ctx context.Context, subject, secret string) (*models.TOTPEnrollment, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	query := `
+		INSERT INTO totp_enrollments (subject, secret, last_step, created_at)
+		VALUES ($1, $2, 0, $3)
+		ON CONFLICT (subject) DO UPDATE SET
+			secret = EXCLUDED.secret,
+			last_step = 0,
+			created_at = EXCLUDED.created_at
+		WHERE totp_enrollments.confirmed_at IS NULL
+		RETURNING ` + totpColumns
+
+	enrollment, err := scanTOTPEnrollment(r.db.QueryRowContext(ctx, query, subject, secret, time.Now()))
+	if err == sql.ErrNoRows {
+		return nil, ErrTOTPEnrolled
+	}
+	return enrollment, err
+}
+
+// UseTOTPStep records that a user verified the code of a time step, confirming
+// the enrollment the first time. It returns false if a code of that step or a
+// later one was already used, so that an intercepted code cannot be replayed.
+func (r *Repository) UseTOTPStep(ctx context.Context, subject string, step int64) (bool, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	query := `
+		UPDATE totp_enrollments
+		SET last_step = $2, confirmed_at = COALESCE(confirmed_at, $3)
+		WHERE subject = $1 AND last_step < $2`
+
+	result, err := r.db.ExecContext(ctx, query, subject, step, time.Now())
+	if err != nil {
+		return false, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return rowsAffected > 0, nil
+}
+
+// DeleteTOTPEnrollment removes the authenticator app of a user
+func (r *Repository) DeleteTOTPEnrollment(ctx context.Context, subject string) error {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	result, err := r.db.ExecContext(ctx, `DELETE FROM totp_enrollments WHERE subject = $1`, subject)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrTOTPNotEnrolled
+	}
+	return nil
+}
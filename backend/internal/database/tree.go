@@ -0,0 +1,72 @@
+package database
+
+import (
+	"config-manager/internal/models"
+	"context"
+)
+
+// GetTree returns the subtree of rootID, or the whole tree when rootID is nil,
+// as nested nodes down to maxDepth levels below the roots, or all levels when
+// maxDepth is negative. The nodes at maxDepth have nil children, as they were
+// not loaded, while leaves have none.
+func (r *Repository) GetTree(ctx context.Context, rootID *int64, maxDepth int) ([]models.ConfigTreeNode, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	query := `
+		WITH RECURSIVE tree AS (
+			SELECT id, 0 AS depth FROM config_nodes
+			WHERE ($1::bigint IS NULL AND parent_id IS NULL) OR id = $1
+			UNION ALL
+			SELECT n.id, t.depth + 1 FROM config_nodes n JOIN tree t ON n.parent_id = t.id
+			WHERE $2 < 0 OR t.depth < $2
+		)
+		SELECT ` + prefixColumns("n", nodeColumns) + `, ` + nodeCountColumns + `
+		FROM config_nodes n JOIN tree t ON n.id = t.id
+		ORDER BY t.depth, n.sort_index, n.id`
+
+	rows, err := r.db.QueryContext(ctx, query, rootID, maxDepth)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	nodes, err := scanNodesWithCounts(rows)
+	if err != nil {
+		return nil, err
+	}
+	return nestTree(nodes, rootID, maxDepth), nil
+}
+
+// nestTree nests the nodes loaded for GetTree, parents before their children
+// and siblings in order, below their roots
+func nestTree(nodes []models.ConfigNode, rootID *int64, maxDepth int) []models.ConfigTreeNode {
+	var roots []models.ConfigNode
+	children := make(map[int64][]models.ConfigNode)
+	for _, node := range nodes {
+		if node.ParentID == nil || (rootID != nil && node.ID == *rootID) {
+			roots = append(roots, node)
+		} else {
+			children[*node.ParentID] = append(children[*node.ParentID], node)
+		}
+	}
+
+	var build func(node models.ConfigNode, depth int) models.ConfigTreeNode
+	build = func(node models.ConfigNode, depth int) models.ConfigTreeNode {
+		tree := models.ConfigTreeNode{ConfigNode: node}
+		if maxDepth >= 0 && depth >= maxDepth {
+			return tree
+		}
+		tree.Children = make([]models.ConfigTreeNode, 0, len(children[node.ID]))
+		for _, child := range children[node.ID] {
+			tree.Children = append(tree.Children, build(child, depth+1))
+		}
+		return tree
+	}
+
+	trees := make([]models.ConfigTreeNode, 0, len(roots))
+	for _, root := range roots {
+		trees = append(trees, build(root, 0))
+	}
+	return trees
+}
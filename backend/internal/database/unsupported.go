@@ -0,0 +1,424 @@
+package database
+
+import (
+	"config-manager/internal/models"
+	"context"
+	"sort"
+	"time"
+)
+
+// unsupportedFeatures completes a ConfigRepository that only keeps the tree and
+// its properties. The core API consults features it does not keep, such as
+// subtree protection and the key registry, and these lookups find nothing; the
+// other features return ErrNotSupported.
+type unsupportedFeatures struct{}
+
+// AuthenticateAPIKey finds no key, as keys cannot be created
+func (unsupportedFeatures) AuthenticateAPIKey(ctx context.Context, secret string) (*models.APIKey, error) {
+	return nil, nil
+}
+
+// HasNodePermission grants nothing, as permissions cannot be granted
+func (unsupportedFeatures) HasNodePermission(ctx context.Context, nodeID int64, principals []string, perm models.Permission) (bool, error) {
+	return false, nil
+}
+
+// RequiredApprovals is zero, as subtrees cannot be protected
+func (unsupportedFeatures) RequiredApprovals(ctx context.Context, nodeID int64, includeDescendants bool) (int, error) {
+	return 0, nil
+}
+
+// RequiredApprovalsForKey is zero, as subtrees cannot be protected
+func (unsupportedFeatures) RequiredApprovalsForKey(ctx context.Context, key string) (int, error) {
+	return 0, nil
+}
+
+// GetActiveChangeRequest finds none, as change requests cannot be opened
+func (unsupportedFeatures) GetActiveChangeRequest(ctx context.Context, workspaceID int64) (*models.ChangeRequest, error) {
+	return nil, nil
+}
+
+// GetPropertyDrafts finds none, as drafts cannot be saved
+func (unsupportedFeatures) GetPropertyDrafts(ctx context.Context, nodeID int64) ([]models.PropertyDraft, error) {
+	return nil, nil
+}
+
+// GetKeyDeprecations finds none, as keys cannot be deprecated
+func (unsupportedFeatures) GetKeyDeprecations(ctx context.Context, keys []string) ([]models.KeyDeprecation, error) {
+	return nil, nil
+}
+
+// GetRegisteredKey finds none, as keys cannot be registered
+func (unsupportedFeatures) GetRegisteredKey(ctx context.Context, key string) (*models.RegisteredKey, error) {
+	return nil, nil
+}
+
+// UnregisteredKeys returns every key once, in order, as keys cannot be registered
+func (unsupportedFeatures) UnregisteredKeys(ctx context.Context, keys []string) ([]string, error) {
+	seen := make(map[string]bool, len(keys))
+	var unregistered []string
+	for _, key := range keys {
+		if !seen[key] {
+			seen[key] = true
+			unregistered = append(unregistered, key)
+		}
+	}
+	sort.Strings(unregistered)
+	return unregistered, nil
+}
+
+// RebuildDerivedData succeeds without steps, as no data is derived
+func (unsupportedFeatures) RebuildDerivedData(ctx context.Context) *models.RebuildReport {
+	now := time.Now()
+	return &models.RebuildReport{StartedAt: now, CompletedAt: now, Success: true, Steps: []models.RebuildStepResult{}}
+}
+
+// The features below need the full Postgres schema
+
+func (unsupportedFeatures) CreateAPIKey(ctx context.Context, req models.CreateAPIKeyRequest) (*models.CreatedAPIKey, error) {
+	return nil, ErrNotSupported
+}
+
+func (unsupportedFeatures) GetAPIKeys(ctx context.Context) ([]models.APIKey, error) {
+	return nil, ErrNotSupported
+}
+
+func (unsupportedFeatures) RevokeAPIKey(ctx context.Context, id int64) error {
+	return ErrNotSupported
+}
+
+func (unsupportedFeatures) RotateAPIKey(ctx context.Context, id int64, grace time.Duration, expiresAt *time.Time) (*models.CreatedAPIKey, error) {
+	return nil, ErrNotSupported
+}
+
+func (unsupportedFeatures) GetTOTPEnrollment(ctx context.Context, subject string) (*models.TOTPEnrollment, error) {
+	return nil, ErrNotSupported
+}
+
+func (unsupportedFeatures) EnrollTOTP(ctx context.Context, subject, secret string) (*models.TOTPEnrollment, error) {
+	return nil, ErrNotSupported
+}
+
+func (unsupportedFeatures) UseTOTPStep(ctx context.Context, subject string, step int64) (bool, error) {
+	return false, ErrNotSupported
+}
+
+func (unsupportedFeatures) DeleteTOTPEnrollment(ctx context.Context, subject string) error {
+	return ErrNotSupported
+}
+
+func (unsupportedFeatures) ApplyDocument(ctx context.Context, parentID *int64, doc models.DeclaredNode, dryRun bool) (*models.ApplyResult, error) {
+	return nil, ErrNotSupported
+}
+
+func (unsupportedFeatures) RevealProperty(ctx context.Context, id int64, actor string) (*models.ConfigProperty, error) {
+	return nil, ErrNotSupported
+}
+
+func (unsupportedFeatures) GetAuditLog(ctx context.Context, action string, limit int) ([]models.AuditEntry, error) {
+	return nil, ErrNotSupported
+}
+
+func (unsupportedFeatures) GetStats(ctx context.Context, topKeys int) (*models.AdminStats, error) {
+	return nil, ErrNotSupported
+}
+
+func (unsupportedFeatures) ApplyChangeRequest(ctx context.Context, id int64) (*models.WorkspaceMergeResult, error) {
+	return nil, ErrNotSupported
+}
+
+func (unsupportedFeatures) CreateChangeRequest(ctx context.Context, req models.CreateChangeRequestRequest, author string, requiredApprovals int) (*models.ChangeRequest, error) {
+	return nil, ErrNotSupported
+}
+
+func (unsupportedFeatures) GetChangeRequest(ctx context.Context, id int64) (*models.ChangeRequestDetails, error) {
+	return nil, ErrNotSupported
+}
+
+func (unsupportedFeatures) GetChangeRequests(ctx context.Context, status models.ChangeRequestStatus) ([]models.ChangeRequest, error) {
+	return nil, ErrNotSupported
+}
+
+func (unsupportedFeatures) GetNodeProtections(ctx context.Context) ([]models.NodeProtection, error) {
+	return nil, ErrNotSupported
+}
+
+func (unsupportedFeatures) RemoveNodeProtection(ctx context.Context, nodeID int64) error {
+	return ErrNotSupported
+}
+
+func (unsupportedFeatures) ReviewChangeRequest(ctx context.Context, id int64, reviewer string, req models.ReviewChangeRequestRequest) (*models.ChangeRequest, error) {
+	return nil, ErrNotSupported
+}
+
+func (unsupportedFeatures) SetNodeProtection(ctx context.Context, nodeID int64, requiredApprovals int) (*models.NodeProtection, error) {
+	return nil, ErrNotSupported
+}
+
+func (unsupportedFeatures) WithdrawChangeRequest(ctx context.Context, id int64) error {
+	return ErrNotSupported
+}
+
+func (unsupportedFeatures) DeprecateKey(ctx context.Context, req models.DeprecateKeyRequest, createdBy string) (*models.KeyDeprecation, error) {
+	return nil, ErrNotSupported
+}
+
+func (unsupportedFeatures) GetDeprecatedDefinitions(ctx context.Context, rootID *int64) ([]models.PropertySearchResult, error) {
+	return nil, ErrNotSupported
+}
+
+func (unsupportedFeatures) UndeprecateKey(ctx context.Context, key string) (bool, error) {
+	return false, ErrNotSupported
+}
+
+func (unsupportedFeatures) DiscardPropertyDrafts(ctx context.Context, nodeID int64, key string) (int64, error) {
+	return 0, ErrNotSupported
+}
+
+func (unsupportedFeatures) PublishPropertyDrafts(ctx context.Context, nodeID int64) (*models.PublishDraftsResult, error) {
+	return nil, ErrNotSupported
+}
+
+func (unsupportedFeatures) SavePropertyDraft(ctx context.Context, nodeID int64, req models.SavePropertyDraftRequest) (*models.PropertyDraft, error) {
+	return nil, ErrNotSupported
+}
+
+func (unsupportedFeatures) DetectDrift(ctx context.Context, parentID *int64, doc models.DeclaredNode) (*models.DriftReport, error) {
+	return nil, ErrNotSupported
+}
+
+func (unsupportedFeatures) CreateEncryptionKey(ctx context.Context, nodeID int64) (*models.EncryptionKey, error) {
+	return nil, ErrNotSupported
+}
+
+func (unsupportedFeatures) GetEncryptionKeys(ctx context.Context) ([]models.EncryptionKey, error) {
+	return nil, ErrNotSupported
+}
+
+func (unsupportedFeatures) CreateKeyRotation(ctx context.Context, keyID int64, scheduledAt time.Time, actor string) (*models.KeyRotation, error) {
+	return nil, ErrNotSupported
+}
+
+func (unsupportedFeatures) GetKeyRotations(ctx context.Context) ([]models.KeyRotation, error) {
+	return nil, ErrNotSupported
+}
+
+func (unsupportedFeatures) GetKeyRotation(ctx context.Context, id int64) (*models.KeyRotation, error) {
+	return nil, ErrNotSupported
+}
+
+func (unsupportedFeatures) CancelKeyRotation(ctx context.Context, id int64) error {
+	return ErrNotSupported
+}
+
+func (unsupportedFeatures) ResumeKeyRotation(ctx context.Context, id int64) (*models.KeyRotation, error) {
+	return nil, ErrNotSupported
+}
+
+func (unsupportedFeatures) GetQuotas(ctx context.Context) ([]models.OrganizationQuota, error) {
+	return nil, ErrNotSupported
+}
+
+func (unsupportedFeatures) SetQuota(ctx context.Context, rootNodeID *int64, req models.SetQuotaRequest) (*models.OrganizationQuota, error) {
+	return nil, ErrNotSupported
+}
+
+func (unsupportedFeatures) DeleteQuota(ctx context.Context, rootNodeID *int64) error {
+	return ErrNotSupported
+}
+
+func (unsupportedFeatures) GetRootNodeID(ctx context.Context, nodeID int64) (*int64, error) {
+	return nil, ErrNotSupported
+}
+
+func (unsupportedFeatures) GetOrganizationUsage(ctx context.Context, nodeID int64) (*models.OrganizationUsage, error) {
+	return nil, ErrNotSupported
+}
+
+func (unsupportedFeatures) GetExpiringProperties(ctx context.Context, until time.Time, rootID *int64) ([]models.PropertySearchResult, error) {
+	return nil, ErrNotSupported
+}
+
+func (unsupportedFeatures) AnalyzeImpact(ctx context.Context, change models.PropertyDraft) (*models.ImpactReport, error) {
+	return nil, ErrNotSupported
+}
+
+func (unsupportedFeatures) GetKeyInventory(ctx context.Context, rootID int64) ([]models.KeyInventoryEntry, error) {
+	return nil, ErrNotSupported
+}
+
+func (unsupportedFeatures) GetKeyCatalog(ctx context.Context) ([]models.KeyCatalogEntry, error) {
+	return nil, ErrNotSupported
+}
+
+func (unsupportedFeatures) RegisterKey(ctx context.Context, req models.RegisterKeyRequest, createdBy string) (*models.RegisteredKey, error) {
+	return nil, ErrNotSupported
+}
+
+func (unsupportedFeatures) UnregisterKey(ctx context.Context, key string) (bool, error) {
+	return false, ErrNotSupported
+}
+
+func (unsupportedFeatures) RenameKey(ctx context.Context, oldKey, newKey, actor string) (*models.KeyRenameResult, error) {
+	return nil, ErrNotSupported
+}
+
+func (unsupportedFeatures) GetChanges(ctx context.Context, since int64, limit int) ([]models.Change, error) {
+	return nil, ErrNotSupported
+}
+
+func (unsupportedFeatures) OldestChangeCursor(ctx context.Context) (*int64, error) {
+	return nil, ErrNotSupported
+}
+
+func (unsupportedFeatures) GetOverrides(ctx context.Context, nodeID int64, environment string) (*models.OverrideReport, error) {
+	return nil, ErrNotSupported
+}
+
+func (unsupportedFeatures) GetNodePermissions(ctx context.Context, nodeID int64) ([]models.NodePermission, error) {
+	return nil, ErrNotSupported
+}
+
+func (unsupportedFeatures) GrantNodePermission(ctx context.Context, nodeID int64, req models.GrantPermissionRequest) (*models.NodePermission, error) {
+	return nil, ErrNotSupported
+}
+
+func (unsupportedFeatures) RevokeNodePermission(ctx context.Context, id int64) error {
+	return ErrNotSupported
+}
+
+func (unsupportedFeatures) PromoteProperty(ctx context.Context, propertyID int64, removeIdentical bool) (*models.PromotePropertyResult, error) {
+	return nil, ErrNotSupported
+}
+
+func (unsupportedFeatures) PushDownProperty(ctx context.Context, propertyID int64) (*models.PushDownPropertyResult, error) {
+	return nil, ErrNotSupported
+}
+
+func (unsupportedFeatures) ApplyValueReplacement(ctx context.Context, replacements []models.ValueReplacement, actor string, details map[string]interface{}) (int64, error) {
+	return 0, ErrNotSupported
+}
+
+func (unsupportedFeatures) PlanValueReplacement(ctx context.Context, rootID int64, key string, replace func(string) string) ([]models.ValueReplacement, error) {
+	return nil, ErrNotSupported
+}
+
+func (unsupportedFeatures) GetRequiredKeys(ctx context.Context, nodeID int64) ([]models.RequiredKey, error) {
+	return nil, ErrNotSupported
+}
+
+func (unsupportedFeatures) RequireKey(ctx context.Context, nodeID int64, req models.CreateRequiredKeyRequest, createdBy string) (*models.RequiredKey, error) {
+	return nil, ErrNotSupported
+}
+
+func (unsupportedFeatures) UnrequireKey(ctx context.Context, nodeID int64, key string) (bool, error) {
+	return false, ErrNotSupported
+}
+
+func (unsupportedFeatures) ValidateCompleteness(ctx context.Context, nodeID int64, environment string) (*models.CompletenessReport, error) {
+	return nil, ErrNotSupported
+}
+
+func (unsupportedFeatures) CancelScheduledChange(ctx context.Context, id int64) error {
+	return ErrNotSupported
+}
+
+func (unsupportedFeatures) CreateScheduledChange(ctx context.Context, nodeID int64, req models.CreateScheduledChangeRequest) (*models.ScheduledChange, error) {
+	return nil, ErrNotSupported
+}
+
+func (unsupportedFeatures) GetScheduledChangeNodeID(ctx context.Context, id int64) (*int64, error) {
+	return nil, ErrNotSupported
+}
+
+func (unsupportedFeatures) GetScheduledChanges(ctx context.Context, nodeID int64) ([]models.ScheduledChange, error) {
+	return nil, ErrNotSupported
+}
+
+func (unsupportedFeatures) CreateSnapshot(ctx context.Context, nodeID int64, req models.CreateSnapshotRequest, createdBy string) (*models.ConfigSnapshot, error) {
+	return nil, ErrNotSupported
+}
+
+func (unsupportedFeatures) GetSnapshot(ctx context.Context, id int64) (*models.ConfigSnapshot, error) {
+	return nil, ErrNotSupported
+}
+
+func (unsupportedFeatures) GetSnapshots(ctx context.Context, nodeID int64) ([]models.ConfigSnapshot, error) {
+	return nil, ErrNotSupported
+}
+
+func (unsupportedFeatures) ResolveRedacted(ctx context.Context, nodeID int64, environment string) (*models.ResolvedConfiguration, []string, error) {
+	return nil, nil, ErrNotSupported
+}
+
+func (unsupportedFeatures) SearchPropertiesByTags(ctx context.Context, tags []string, rootID *int64) ([]models.PropertySearchResult, error) {
+	return nil, ErrNotSupported
+}
+
+func (unsupportedFeatures) AttachTemplate(ctx context.Context, nodeID int64, req models.AttachTemplateRequest, attachedBy string) (*models.NodeTemplate, error) {
+	return nil, ErrNotSupported
+}
+
+func (unsupportedFeatures) CreateTemplate(ctx context.Context, req models.CreateTemplateRequest, createdBy string) (*models.PropertyTemplateWithVersion, error) {
+	return nil, ErrNotSupported
+}
+
+func (unsupportedFeatures) CreateTemplateVersion(ctx context.Context, id int64, req models.CreateTemplateVersionRequest, createdBy string) (*models.TemplateVersion, error) {
+	return nil, ErrNotSupported
+}
+
+func (unsupportedFeatures) DeleteTemplate(ctx context.Context, id int64) (bool, error) {
+	return false, ErrNotSupported
+}
+
+func (unsupportedFeatures) DetachTemplate(ctx context.Context, nodeID, templateID int64) (bool, error) {
+	return false, ErrNotSupported
+}
+
+func (unsupportedFeatures) GetNodeTemplates(ctx context.Context, nodeID int64) ([]models.NodeTemplate, error) {
+	return nil, ErrNotSupported
+}
+
+func (unsupportedFeatures) GetTemplate(ctx context.Context, id int64) (*models.PropertyTemplateWithVersion, error) {
+	return nil, ErrNotSupported
+}
+
+func (unsupportedFeatures) GetTemplateVersion(ctx context.Context, id int64, version int) (*models.TemplateVersion, error) {
+	return nil, ErrNotSupported
+}
+
+func (unsupportedFeatures) GetTemplateVersions(ctx context.Context, id int64) ([]models.TemplateVersion, error) {
+	return nil, ErrNotSupported
+}
+
+func (unsupportedFeatures) GetTemplates(ctx context.Context) ([]models.PropertyTemplate, error) {
+	return nil, ErrNotSupported
+}
+
+func (unsupportedFeatures) AddWorkspaceChange(ctx context.Context, workspaceID int64, req models.WorkspaceChangeRequest) (*models.WorkspaceChange, error) {
+	return nil, ErrNotSupported
+}
+
+func (unsupportedFeatures) CreateWorkspace(ctx context.Context, req models.CreateWorkspaceRequest) (*models.Workspace, error) {
+	return nil, ErrNotSupported
+}
+
+func (unsupportedFeatures) DiscardWorkspace(ctx context.Context, id int64) error {
+	return ErrNotSupported
+}
+
+func (unsupportedFeatures) GetWorkspace(ctx context.Context, id int64) (*models.WorkspaceWithChanges, error) {
+	return nil, ErrNotSupported
+}
+
+func (unsupportedFeatures) GetWorkspaces(ctx context.Context) ([]models.Workspace, error) {
+	return nil, ErrNotSupported
+}
+
+func (unsupportedFeatures) MergeWorkspace(ctx context.Context, workspaceID int64) (*models.WorkspaceMergeResult, error) {
+	return nil, ErrNotSupported
+}
+
+func (unsupportedFeatures) ResolveInWorkspace(ctx context.Context, workspaceID, nodeID int64) (*models.ResolvedConfiguration, error) {
+	return nil, ErrNotSupported
+}
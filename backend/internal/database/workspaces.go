@@ -0,0 +1,690 @@
+package database
+
+import (
+	"config-manager/internal/models"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+var (
+	ErrWorkspaceNotFound      = errors.New("workspace not found")
+	ErrWorkspaceNotOpen       = errors.New("workspace is not open")
+	ErrInvalidWorkspaceChange = errors.New("invalid workspace change")
+)
+
+// WorkspaceConflictError is returned when a merge is rejected because the live
+// tree changed underneath some of the workspace edits
+type WorkspaceConflictError struct {
+	Conflicts []models.WorkspaceConflict
+}
+
+func (e *WorkspaceConflictError) Error() string {
+	return fmt.Sprintf("workspace has %d conflicting changes", len(e.Conflicts))
+}
+
+func invalidChange(format string, args ...interface{}) error {
+	return fmt.Errorf("%w: %s", ErrInvalidWorkspaceChange, fmt.Sprintf(format, args...))
+}
+
+const workspaceColumns = `id, name, root_node_id, status, created_at, updated_at, merged_at`
+
+func scanWorkspace(row rowScanner) (*models.Workspace, error) {
+	var ws models.Workspace
+	err := row.Scan(&ws.ID, &ws.Name, &ws.RootNodeID, &ws.Status, &ws.CreatedAt, &ws.UpdatedAt, &ws.MergedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &ws, nil
+}
+
+func (r *Repository) CreateWorkspace(ctx context.Context, req models.CreateWorkspaceRequest) (*models.Workspace, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	query := `
+		INSERT INTO workspaces (name, root_node_id, status, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING ` + workspaceColumns
+
+	now := time.Now()
+	return scanWorkspace(r.db.QueryRowContext(ctx, query, req.Name, req.RootNodeID, models.WorkspaceStatusOpen, now, now))
+}
+
+func (r *Repository) GetWorkspaces(ctx context.Context) ([]models.Workspace, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	query := `SELECT ` + workspaceColumns + ` FROM workspaces ORDER BY created_at DESC`
+
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	workspaces := []models.Workspace{}
+	for rows.Next() {
+		ws, err := scanWorkspace(rows)
+		if err != nil {
+			return nil, err
+		}
+		workspaces = append(workspaces, *ws)
+	}
+
+	return workspaces, nil
+}
+
+func (r *Repository) GetWorkspace(ctx context.Context, id int64) (*models.WorkspaceWithChanges, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	ws, err := scanWorkspace(r.db.QueryRowContext(ctx, `SELECT `+workspaceColumns+` FROM workspaces WHERE id = $1`, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	changes, err := r.getWorkspaceChanges(ctx, r.db, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.WorkspaceWithChanges{Workspace: *ws, Changes: changes}, nil
+}
+
+// DiscardWorkspace closes an open workspace without applying its changes
+func (r *Repository) DiscardWorkspace(ctx context.Context, id int64) error {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	query := `UPDATE workspaces SET status = $1, updated_at = $2 WHERE id = $3 AND status = $4`
+	result, err := r.db.ExecContext(ctx, query, models.WorkspaceStatusDiscarded, time.Now(), id, models.WorkspaceStatusOpen)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrWorkspaceNotOpen
+	}
+
+	return nil
+}
+
+// querier is satisfied by both *DB and *sql.Tx
+type querier interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+func (r *Repository) getWorkspaceChanges(ctx context.Context, q querier, workspaceID int64) ([]models.WorkspaceChange, error) {
+	query := `
+		SELECT id, workspace_id, payload, base_updated_at, created_at
+		FROM workspace_changes WHERE workspace_id = $1
+		ORDER BY id`
+
+	rows, err := q.QueryContext(ctx, query, workspaceID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	changes := []models.WorkspaceChange{}
+	for rows.Next() {
+		var change models.WorkspaceChange
+		var payload []byte
+		if err := rows.Scan(&change.ID, &change.WorkspaceID, &payload, &change.BaseUpdatedAt, &change.CreatedAt); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(payload, &change.Change); err != nil {
+			return nil, fmt.Errorf("malformed workspace change %d: %w", change.ID, err)
+		}
+		changes = append(changes, change)
+	}
+
+	return changes, nil
+}
+
+// workspaceState is the in-memory view of a workspace: the live subtree and its
+// ancestors with all recorded changes applied on top
+type workspaceState struct {
+	workspace *models.Workspace
+	nodes     map[int64]*models.ConfigNode
+	inSubtree map[int64]bool
+	props     map[int64]map[string]models.ConfigProperty
+}
+
+func (r *Repository) loadWorkspaceState(ctx context.Context, id int64) (*workspaceState, error) {
+	ws, err := r.GetWorkspace(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if ws == nil {
+		return nil, ErrWorkspaceNotFound
+	}
+
+	state := &workspaceState{
+		workspace: &ws.Workspace,
+		nodes:     make(map[int64]*models.ConfigNode),
+		inSubtree: make(map[int64]bool),
+		props:     make(map[int64]map[string]models.ConfigProperty),
+	}
+
+	ancestors, err := r.GetNodePath(ctx, ws.RootNodeID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range ancestors {
+		state.nodes[ancestors[i].ID] = &ancestors[i]
+	}
+
+	descendants, err := r.getSubtree(ctx, ws.RootNodeID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range descendants {
+		state.nodes[descendants[i].ID] = &descendants[i]
+		state.inSubtree[descendants[i].ID] = true
+	}
+
+	ids := make([]int64, 0, len(state.nodes))
+	for id := range state.nodes {
+		ids = append(ids, id)
+		state.props[id] = make(map[string]models.ConfigProperty)
+	}
+
+	rows, err := r.db.QueryContext(ctx, `SELECT `+propertyColumns+` FROM config_properties WHERE node_id = ANY($1) AND environment IS NULL`, ids)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		prop, err := r.scanProperty(ctx, rows)
+		if err != nil {
+			return nil, err
+		}
+		state.props[prop.NodeID][prop.Key] = *prop
+	}
+
+	for _, change := range ws.Changes {
+		state.apply(change)
+	}
+
+	return state, nil
+}
+
+// getSubtree returns the node and all of its descendants
+func (r *Repository) getSubtree(ctx context.Context, rootID int64) ([]models.ConfigNode, error) {
+	query := `
+		WITH RECURSIVE subtree AS (
+			SELECT * FROM config_nodes WHERE id = $1
+			UNION ALL
+			SELECT n.* FROM config_nodes n JOIN subtree s ON n.parent_id = s.id
+		)
+		SELECT ` + nodeColumns + ` FROM subtree`
+
+	rows, err := r.db.QueryContext(ctx, query, rootID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanNodes(rows)
+}
+
+// apply overlays a recorded change on the state
+func (s *workspaceState) apply(change models.WorkspaceChange) {
+	req := change.Change
+	switch req.Op {
+	case models.WorkspaceOpCreateNode:
+		node := &models.ConfigNode{
+			ID:        -change.ID,
+			NodeType:  req.NodeType,
+			ParentID:  req.ParentID,
+			CreatedAt: change.CreatedAt,
+			UpdatedAt: change.CreatedAt,
+		}
+		if req.Name != nil {
+			node.Name = *req.Name
+		}
+		if req.Description != nil {
+			node.Description = *req.Description
+		}
+		s.nodes[node.ID] = node
+		s.inSubtree[node.ID] = true
+		s.props[node.ID] = make(map[string]models.ConfigProperty)
+
+	case models.WorkspaceOpUpdateNode:
+		if node, ok := s.nodes[*req.NodeID]; ok {
+			if req.Name != nil {
+				node.Name = *req.Name
+			}
+			if req.Description != nil {
+				node.Description = *req.Description
+			}
+		}
+
+	case models.WorkspaceOpDeleteNode:
+		for _, id := range s.descendantsOf(*req.NodeID) {
+			delete(s.nodes, id)
+			delete(s.inSubtree, id)
+			delete(s.props, id)
+		}
+
+	case models.WorkspaceOpSetProperty:
+		props, ok := s.props[*req.NodeID]
+		if !ok {
+			return
+		}
+		prop := props[req.Key]
+		prop.NodeID = *req.NodeID
+		prop.Key = req.Key
+		prop.Value = *req.Value
+		prop.DataType = req.DataType
+		prop.DefaultValue = req.DefaultValue
+		if req.Description != nil {
+			prop.Description = *req.Description
+		}
+		prop.UpdatedAt = change.CreatedAt
+		props[req.Key] = prop
+
+	case models.WorkspaceOpDeleteProperty:
+		delete(s.props[*req.NodeID], req.Key)
+	}
+}
+
+// descendantsOf returns the IDs of a node and all its descendants in the state
+func (s *workspaceState) descendantsOf(id int64) []int64 {
+	ids := []int64{id}
+	for i := 0; i < len(ids); i++ {
+		for _, node := range s.nodes {
+			if node.ParentID != nil && *node.ParentID == ids[i] {
+				ids = append(ids, node.ID)
+			}
+		}
+	}
+	return ids
+}
+
+// path returns the nodes from the root of the tree down to id
+func (s *workspaceState) path(id int64) []models.ConfigNode {
+	var path []models.ConfigNode
+	for current := &id; current != nil; {
+		node, ok := s.nodes[*current]
+		if !ok {
+			break
+		}
+		path = append([]models.ConfigNode{*node}, path...)
+		current = node.ParentID
+	}
+	return path
+}
+
+// validate checks a change against the current workspace state
+func (s *workspaceState) validate(req models.WorkspaceChangeRequest) error {
+	requireNode := func() error {
+		if req.NodeID == nil {
+			return invalidChange("node_id is required for %s", req.Op)
+		}
+		if !s.inSubtree[*req.NodeID] {
+			return invalidChange("node %d is not part of the workspace subtree", *req.NodeID)
+		}
+		return nil
+	}
+
+	switch req.Op {
+	case models.WorkspaceOpCreateNode:
+		if req.ParentID == nil || !s.inSubtree[*req.ParentID] {
+			return invalidChange("parent_id must reference a node in the workspace subtree")
+		}
+		if req.Name == nil || *req.Name == "" {
+			return invalidChange("name is required for create_node")
+		}
+		if req.NodeType != models.NodeTypeTerritory && req.NodeType != models.NodeTypeCenter {
+			return invalidChange("node_type must be 'territory' or 'center'")
+		}
+
+	case models.WorkspaceOpUpdateNode:
+		return requireNode()
+
+	case models.WorkspaceOpDeleteNode:
+		if err := requireNode(); err != nil {
+			return err
+		}
+		if *req.NodeID == s.workspace.RootNodeID {
+			return invalidChange("the workspace root cannot be deleted")
+		}
+
+	case models.WorkspaceOpSetProperty:
+		if err := requireNode(); err != nil {
+			return err
+		}
+		if req.Key == "" || req.Value == nil || req.DataType == "" {
+			return invalidChange("key, value and data_type are required for set_property")
+		}
+		var decoded interface{}
+		if err := json.Unmarshal([]byte(*req.Value), &decoded); err != nil {
+			return invalidChange("value must be valid JSON")
+		}
+		if existing, ok := s.props[*req.NodeID][req.Key]; ok && existing.Encrypted {
+			return invalidChange("encrypted properties cannot be edited in a workspace")
+		}
+
+	case models.WorkspaceOpDeleteProperty:
+		if err := requireNode(); err != nil {
+			return err
+		}
+		if _, ok := s.props[*req.NodeID][req.Key]; !ok {
+			return invalidChange("property %s does not exist on node %d", req.Key, *req.NodeID)
+		}
+
+	default:
+		return invalidChange("unknown op %q", req.Op)
+	}
+
+	return nil
+}
+
+// liveVersion returns the updated_at of the live entity a change targets, or nil
+// if it targets a node created in the workspace or a property that does not exist yet
+func liveVersion(ctx context.Context, q querier, req models.WorkspaceChangeRequest) (*time.Time, error) {
+	if req.NodeID == nil || *req.NodeID < 0 {
+		return nil, nil
+	}
+
+	var query string
+	args := []interface{}{*req.NodeID}
+	switch req.Op {
+	case models.WorkspaceOpUpdateNode, models.WorkspaceOpDeleteNode:
+		query = `SELECT updated_at FROM config_nodes WHERE id = $1`
+	case models.WorkspaceOpSetProperty, models.WorkspaceOpDeleteProperty:
+		query = `SELECT updated_at FROM config_properties WHERE node_id = $1 AND key = $2 AND environment IS NULL`
+		args = append(args, req.Key)
+	default:
+		return nil, nil
+	}
+
+	var updatedAt time.Time
+	err := q.QueryRowContext(ctx, query, args...).Scan(&updatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &updatedAt, nil
+}
+
+// AddWorkspaceChange validates and records an edit in an open workspace
+func (r *Repository) AddWorkspaceChange(ctx context.Context, workspaceID int64, req models.WorkspaceChangeRequest) (*models.WorkspaceChange, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	state, err := r.loadWorkspaceState(ctx, workspaceID)
+	if err != nil {
+		return nil, err
+	}
+	if state.workspace.Status != models.WorkspaceStatusOpen {
+		return nil, ErrWorkspaceNotOpen
+	}
+	if err := state.validate(req); err != nil {
+		return nil, err
+	}
+
+	base, err := liveVersion(ctx, r.db, req)
+	if err != nil {
+		return nil, err
+	}
+
+	payload, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+		INSERT INTO workspace_changes (workspace_id, op, node_id, payload, base_updated_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, workspace_id, base_updated_at, created_at`
+
+	change := models.WorkspaceChange{Change: req}
+	err = r.db.QueryRowContext(ctx, query, workspaceID, req.Op, req.NodeID, payload, base, time.Now()).Scan(
+		&change.ID, &change.WorkspaceID, &change.BaseUpdatedAt, &change.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := r.db.ExecContext(ctx, `UPDATE workspaces SET updated_at = $1 WHERE id = $2`, change.CreatedAt, workspaceID); err != nil {
+		return nil, err
+	}
+
+	return &change, nil
+}
+
+// ResolveInWorkspace previews the resolved configuration of a node with the
+// workspace changes applied. Workspaces only edit the values shared by all
+// environments, so environment overrides are not applied.
+func (r *Repository) ResolveInWorkspace(ctx context.Context, workspaceID, nodeID int64) (*models.ResolvedConfiguration, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	state, err := r.loadWorkspaceState(ctx, workspaceID)
+	if err != nil {
+		return nil, err
+	}
+
+	path := state.path(nodeID)
+	if len(path) == 0 || !state.inSubtree[nodeID] {
+		return nil, nil
+	}
+
+	resolved := make(map[string]interface{})
+	locked := make(map[string]bool)
+	for _, node := range path {
+		props := state.props[node.ID]
+		keys := make([]string, 0, len(props))
+		for key := range props {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		properties := make([]models.ConfigProperty, 0, len(keys))
+		for _, key := range keys {
+			properties = append(properties, props[key])
+		}
+		applyProperties(resolved, unlocked(properties, locked))
+	}
+
+	warnings := interpolate(resolved)
+
+	return &models.ResolvedConfiguration{
+		NodeID:     nodeID,
+		NodeName:   path[len(path)-1].Name,
+		Properties: resolved,
+		Path:       path,
+		Checksum:   configChecksum("", resolved),
+		Warnings:   warnings,
+	}, nil
+}
+
+// MergeWorkspace applies all workspace changes to the live tree in a single
+// transaction. If any live entity changed since it was edited in the workspace,
+// nothing is applied and a *WorkspaceConflictError is returned.
+func (r *Repository) MergeWorkspace(ctx context.Context, workspaceID int64) (*models.WorkspaceMergeResult, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	ws, err := scanWorkspace(tx.QueryRowContext(ctx, `SELECT `+workspaceColumns+` FROM workspaces WHERE id = $1 FOR UPDATE`, workspaceID))
+	if err == sql.ErrNoRows {
+		return nil, ErrWorkspaceNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if ws.Status != models.WorkspaceStatusOpen {
+		return nil, ErrWorkspaceNotOpen
+	}
+
+	changes, err := r.getWorkspaceChanges(ctx, tx, workspaceID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Check every edit against the live tree before applying any of them
+	var conflicts []models.WorkspaceConflict
+	for _, change := range changes {
+		if reason, err := checkConflict(ctx, tx, change); err != nil {
+			return nil, err
+		} else if reason != "" {
+			conflicts = append(conflicts, models.WorkspaceConflict{ChangeID: change.ID, Reason: reason})
+		}
+	}
+	if len(conflicts) > 0 {
+		return nil, &WorkspaceConflictError{Conflicts: conflicts}
+	}
+
+	now := time.Now()
+	nodeIDs := make(map[int64]int64)
+	liveID := func(id *int64) *int64 {
+		if id == nil || *id >= 0 {
+			return id
+		}
+		mapped := nodeIDs[*id]
+		return &mapped
+	}
+
+	for _, change := range changes {
+		req := change.Change
+		switch req.Op {
+		case models.WorkspaceOpCreateNode:
+			description := ""
+			if req.Description != nil {
+				description = *req.Description
+			}
+			var id int64
+			err = tx.QueryRowContext(ctx, `
+				INSERT INTO config_nodes (name, node_type, parent_id, description, sort_index, created_at, updated_at)
+				VALUES ($1, $2, $3, $4, `+nextSortIndex("$3")+`, $5, $5)
+				RETURNING id`, *req.Name, req.NodeType, liveID(req.ParentID), description, now).Scan(&id)
+			nodeIDs[-change.ID] = id
+
+		case models.WorkspaceOpUpdateNode:
+			_, err = tx.ExecContext(ctx, `
+				UPDATE config_nodes
+				SET name = COALESCE($1, name), description = COALESCE($2, description), updated_at = $3
+				WHERE id = $4`, req.Name, req.Description, now, liveID(req.NodeID))
+
+		case models.WorkspaceOpDeleteNode:
+			_, err = tx.ExecContext(ctx, `DELETE FROM config_nodes WHERE id = $1`, liveID(req.NodeID))
+
+		case models.WorkspaceOpSetProperty:
+			description := ""
+			if req.Description != nil {
+				description = *req.Description
+			}
+			_, err = tx.ExecContext(ctx, `
+				INSERT INTO config_properties (node_id, key, value, data_type, default_value, description, created_at, updated_at)
+				VALUES ($1, $2, $3, $4, $5, $6, $7, $7)
+				ON CONFLICT (node_id, key, (COALESCE(environment, '')))
+				DO UPDATE SET
+					value = EXCLUDED.value,
+					data_type = EXCLUDED.data_type,
+					default_value = EXCLUDED.default_value,
+					description = EXCLUDED.description,
+					updated_at = EXCLUDED.updated_at`,
+				liveID(req.NodeID), req.Key, *req.Value, req.DataType, req.DefaultValue, description, now)
+
+		case models.WorkspaceOpDeleteProperty:
+			_, err = tx.ExecContext(ctx, `DELETE FROM config_properties WHERE node_id = $1 AND key = $2 AND environment IS NULL`, liveID(req.NodeID), req.Key)
+		}
+		if isNodeNameConflict(err) {
+			err = ErrNodeNameTaken
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to apply workspace change %d: %w", change.ID, err)
+		}
+	}
+
+	merged, err := scanWorkspace(tx.QueryRowContext(ctx, `
+		UPDATE workspaces SET status = $1, merged_at = $2, updated_at = $2
+		WHERE id = $3
+		RETURNING `+workspaceColumns, models.WorkspaceStatusMerged, now, workspaceID))
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return &models.WorkspaceMergeResult{Workspace: *merged, NodeIDs: nodeIDs}, nil
+}
+
+// checkConflict compares the live version of a change's target with the version
+// the change was based on, returning a reason if they differ
+func checkConflict(ctx context.Context, q querier, change models.WorkspaceChange) (string, error) {
+	req := change.Change
+
+	nodeExists := func(id int64) (bool, error) {
+		var exists bool
+		err := q.QueryRowContext(ctx, `SELECT EXISTS(SELECT 1 FROM config_nodes WHERE id = $1)`, id).Scan(&exists)
+		return exists, err
+	}
+
+	if req.Op == models.WorkspaceOpCreateNode {
+		if *req.ParentID < 0 {
+			return "", nil
+		}
+		exists, err := nodeExists(*req.ParentID)
+		if err != nil || !exists {
+			return fmt.Sprintf("parent node %d was deleted in the live tree", *req.ParentID), err
+		}
+		return "", nil
+	}
+
+	if *req.NodeID < 0 {
+		return "", nil
+	}
+
+	if req.Op == models.WorkspaceOpSetProperty || req.Op == models.WorkspaceOpDeleteProperty {
+		exists, err := nodeExists(*req.NodeID)
+		if err != nil || !exists {
+			return fmt.Sprintf("node %d was deleted in the live tree", *req.NodeID), err
+		}
+	}
+
+	live, err := liveVersion(ctx, q, req)
+	if err != nil {
+		return "", err
+	}
+
+	switch {
+	case live == nil && change.BaseUpdatedAt != nil:
+		return "target was deleted in the live tree", nil
+	case live == nil && (req.Op == models.WorkspaceOpUpdateNode || req.Op == models.WorkspaceOpDeleteNode):
+		return fmt.Sprintf("node %d was deleted in the live tree", *req.NodeID), nil
+	case live != nil && change.BaseUpdatedAt == nil:
+		return fmt.Sprintf("property %s was created in the live tree", req.Key), nil
+	case live != nil && !live.Equal(*change.BaseUpdatedAt):
+		return "target was modified in the live tree", nil
+	}
+	return "", nil
+}
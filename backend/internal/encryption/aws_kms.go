@@ -0,0 +1,53 @@
+package encryption
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/kms"
+)
+
+// kmsEncryptionContext is bound to every data key wrapped with AWS KMS, so
+// that the ciphertexts cannot be decrypted for another purpose
+var kmsEncryptionContext = map[string]string{"application": "config-manager"}
+
+// AWSKMS holds the master key in AWS KMS
+type AWSKMS struct {
+	client *kms.Client
+	keyID  string
+}
+
+// NewAWSKMS creates a provider for the symmetric KMS key keyID (a key ID, ARN,
+// alias name or alias ARN) using the default AWS credential chain
+// (environment, shared config, web identity or instance role)
+func NewAWSKMS(ctx context.Context, keyID string) (*AWSKMS, error) {
+	cfg, err := config.LoadDefaultConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &AWSKMS{client: kms.NewFromConfig(cfg), keyID: keyID}, nil
+}
+
+func (a *AWSKMS) WrapKey(ctx context.Context, plaintext []byte) ([]byte, error) {
+	out, err := a.client.Encrypt(ctx, &kms.EncryptInput{
+		KeyId:             &a.keyID,
+		Plaintext:         plaintext,
+		EncryptionContext: kmsEncryptionContext,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out.CiphertextBlob, nil
+}
+
+func (a *AWSKMS) UnwrapKey(ctx context.Context, wrapped []byte) ([]byte, error) {
+	out, err := a.client.Decrypt(ctx, &kms.DecryptInput{
+		KeyId:             &a.keyID,
+		CiphertextBlob:    wrapped,
+		EncryptionContext: kmsEncryptionContext,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out.Plaintext, nil
+}
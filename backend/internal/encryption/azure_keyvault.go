@@ -0,0 +1,190 @@
+package encryption
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/clientcredentials"
+)
+
+const (
+	azureKeyVaultAPIVersion = "7.4"
+	azureKeyVaultScope      = "https://vault.azure.net/.default"
+	azureKeyVaultResource   = "https://vault.azure.net"
+
+	// azureWrapAlgorithm is used with the RSA key the vault holds
+	azureWrapAlgorithm = "RSA-OAEP-256"
+
+	// azureIMDSEndpoint issues managed identity tokens on Azure hosts
+	azureIMDSEndpoint = "http://169.254.169.254/metadata/identity/oauth2/token"
+)
+
+// AzureKeyVault holds the master key, an RSA key, in Azure Key Vault. It calls
+// the REST API directly with a service principal or managed identity token.
+type AzureKeyVault struct {
+	client *http.Client
+	keyURL string
+	host   string
+}
+
+// azureWrappedKey is a data key wrapped by Key Vault. The ID of the key version
+// that wrapped it is kept so that it can still be unwrapped after the key is
+// rotated to a new version.
+type azureWrappedKey struct {
+	KeyID string `json:"kid"`
+	Value string `json:"value"`
+}
+
+// NewAzureKeyVault creates a provider for the key keyURL, in the form
+// https://<vault>.vault.azure.net/keys/<name>[/<version>], without a version
+// to wrap new data keys with the current one. The service principal in
+// AZURE_TENANT_ID, AZURE_CLIENT_ID and AZURE_CLIENT_SECRET is used when set,
+// and the host's managed identity, AZURE_CLIENT_ID selecting a user assigned
+// one, otherwise.
+func NewAzureKeyVault(ctx context.Context, keyURL string) (*AzureKeyVault, error) {
+	u, err := url.Parse(keyURL)
+	if err != nil || u.Scheme != "https" || u.Host == "" || !strings.HasPrefix(u.Path, "/keys/") {
+		return nil, fmt.Errorf("ENCRYPTION_KMS_KEY must be a Key Vault key URL https://<vault>.vault.azure.net/keys/<name>, got %q", keyURL)
+	}
+
+	tenantID, clientID, secret := os.Getenv("AZURE_TENANT_ID"), os.Getenv("AZURE_CLIENT_ID"), os.Getenv("AZURE_CLIENT_SECRET")
+	var tokens oauth2.TokenSource
+	if tenantID != "" && secret != "" {
+		creds := clientcredentials.Config{
+			ClientID:     clientID,
+			ClientSecret: secret,
+			TokenURL:     "https://login.microsoftonline.com/" + url.PathEscape(tenantID) + "/oauth2/v2.0/token",
+			Scopes:       []string{azureKeyVaultScope},
+		}
+		tokens = creds.TokenSource(ctx)
+	} else {
+		tokens = oauth2.ReuseTokenSource(nil, &managedIdentityTokens{
+			clientID: clientID,
+			client:   &http.Client{Timeout: 10 * time.Second},
+		})
+	}
+
+	return &AzureKeyVault{
+		client: oauth2.NewClient(ctx, tokens),
+		keyURL: strings.TrimSuffix(u.String(), "/"),
+		host:   u.Host,
+	}, nil
+}
+
+func (a *AzureKeyVault) WrapKey(ctx context.Context, plaintext []byte) ([]byte, error) {
+	result, err := a.call(ctx, a.keyURL, "wrapkey", plaintext)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(result)
+}
+
+func (a *AzureKeyVault) UnwrapKey(ctx context.Context, wrapped []byte) ([]byte, error) {
+	var key azureWrappedKey
+	if err := json.Unmarshal(wrapped, &key); err != nil {
+		return nil, fmt.Errorf("invalid wrapped key: %w", err)
+	}
+
+	// The key ID comes from the database; only send tokens to the configured vault
+	u, err := url.Parse(key.KeyID)
+	if err != nil || u.Scheme != "https" || u.Host != a.host {
+		return nil, fmt.Errorf("data key was wrapped by %q, not a key of vault %s", key.KeyID, a.host)
+	}
+
+	value, err := base64.RawURLEncoding.DecodeString(key.Value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid wrapped key: %w", err)
+	}
+	result, err := a.call(ctx, key.KeyID, "unwrapkey", value)
+	if err != nil {
+		return nil, err
+	}
+	return base64.RawURLEncoding.DecodeString(result.Value)
+}
+
+// call invokes a key operation of the key keyID. Values are sent and received
+// base64url encoded without padding.
+func (a *AzureKeyVault) call(ctx context.Context, keyID, operation string, value []byte) (*azureWrappedKey, error) {
+	body, err := json.Marshal(map[string]string{
+		"alg":   azureWrapAlgorithm,
+		"value": base64.RawURLEncoding.EncodeToString(value),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	endpoint := keyID + "/" + operation + "?api-version=" + azureKeyVaultAPIVersion
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, kmsStatusError("Key Vault", operation, resp)
+	}
+
+	var result azureWrappedKey
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// managedIdentityTokens fetches Key Vault tokens for the host's managed
+// identity from the instance metadata service
+type managedIdentityTokens struct {
+	clientID string
+	client   *http.Client
+}
+
+func (m *managedIdentityTokens) Token() (*oauth2.Token, error) {
+	query := url.Values{"api-version": {"2018-02-01"}, "resource": {azureKeyVaultResource}}
+	if m.clientID != "" {
+		query.Set("client_id", m.clientID)
+	}
+	req, err := http.NewRequest(http.MethodGet, azureIMDSEndpoint+"?"+query.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Metadata", "true")
+
+	resp, err := m.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get a managed identity token: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, kmsStatusError("Managed identity", "token", resp)
+	}
+
+	var body struct {
+		AccessToken string      `json:"access_token"`
+		ExpiresOn   json.Number `json:"expires_on"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, err
+	}
+
+	token := &oauth2.Token{AccessToken: body.AccessToken, TokenType: "Bearer"}
+	if expiresOn, err := body.ExpiresOn.Int64(); err == nil {
+		token.Expiry = time.Unix(expiresOn, 0)
+	}
+	return token, nil
+}
@@ -0,0 +1,232 @@
+package encryption
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// ciphertextPrefix marks stored values that were produced by Encrypt
+const ciphertextPrefix = "enc:v1:"
+
+// KeySize is the size in bytes of master and data keys (AES-256)
+const KeySize = 32
+
+var ErrNotConfigured = errors.New("encryption is not configured")
+
+// Keyring wraps and unwraps per-subtree data keys with the master key, so that
+// data keys can be stored alongside the data they protect. This is envelope
+// encryption: the master key never leaves its provider and only ever encrypts
+// data keys, which encrypt the values.
+type Keyring struct {
+	provider KeyProvider
+}
+
+// KeyProvider holds the master key
+type KeyProvider interface {
+	// WrapKey encrypts a data key with the master key
+	WrapKey(ctx context.Context, plaintext []byte) ([]byte, error)
+	// UnwrapKey decrypts a data key returned by WrapKey
+	UnwrapKey(ctx context.Context, wrapped []byte) ([]byte, error)
+}
+
+// Key providers selectable with ENCRYPTION_KEY_PROVIDER
+const (
+	ProviderLocal         = "local"
+	ProviderAWSKMS        = "aws-kms"
+	ProviderGCPKMS        = "gcp-kms"
+	ProviderAzureKeyVault = "azure-keyvault"
+)
+
+// NewKeyringFromEnv builds a keyring from the environment. ENCRYPTION_KEY_PROVIDER
+// selects where the master key is held: local, the default, reads it base64
+// encoded from ENCRYPTION_MASTER_KEY, and returns a nil keyring when that is
+// not set; aws-kms, gcp-kms and azure-keyvault use the key ENCRYPTION_KMS_KEY
+// names in the cloud provider's key management service.
+func NewKeyringFromEnv() (*Keyring, error) {
+	provider := os.Getenv("ENCRYPTION_KEY_PROVIDER")
+	kmsKey := os.Getenv("ENCRYPTION_KMS_KEY")
+	isKMS := provider == ProviderAWSKMS || provider == ProviderGCPKMS || provider == ProviderAzureKeyVault
+	if isKMS && kmsKey == "" {
+		return nil, fmt.Errorf("ENCRYPTION_KMS_KEY is required with ENCRYPTION_KEY_PROVIDER=%s", provider)
+	}
+
+	ctx := context.Background()
+	switch provider {
+	case "", ProviderLocal:
+		encoded := os.Getenv("ENCRYPTION_MASTER_KEY")
+		if encoded == "" {
+			return nil, nil
+		}
+
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("ENCRYPTION_MASTER_KEY must be base64 encoded: %w", err)
+		}
+		return NewKeyring(key)
+	case ProviderAWSKMS:
+		kms, err := NewAWSKMS(ctx, kmsKey)
+		if err != nil {
+			return nil, err
+		}
+		return NewKeyringWithProvider(kms), nil
+	case ProviderGCPKMS:
+		kms, err := NewGCPKMS(ctx, kmsKey)
+		if err != nil {
+			return nil, err
+		}
+		return NewKeyringWithProvider(kms), nil
+	case ProviderAzureKeyVault:
+		vault, err := NewAzureKeyVault(ctx, kmsKey)
+		if err != nil {
+			return nil, err
+		}
+		return NewKeyringWithProvider(vault), nil
+	default:
+		return nil, fmt.Errorf("ENCRYPTION_KEY_PROVIDER must be local, aws-kms, gcp-kms or azure-keyvault, got %q", provider)
+	}
+}
+
+// NewKeyring creates a keyring from a raw master key
+func NewKeyring(masterKey []byte) (*Keyring, error) {
+	aead, err := newAEAD(masterKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid master key: %w", err)
+	}
+	return &Keyring{provider: localKey{aead}}, nil
+}
+
+// NewKeyringWithProvider creates a keyring whose master key is held by provider
+func NewKeyringWithProvider(provider KeyProvider) *Keyring {
+	return &Keyring{provider: provider}
+}
+
+// GenerateDataKey creates a new random data key and returns it both in plaintext
+// and wrapped with the master key
+func (k *Keyring) GenerateDataKey(ctx context.Context) (plaintext, wrapped []byte, err error) {
+	if k == nil {
+		return nil, nil, ErrNotConfigured
+	}
+
+	plaintext = make([]byte, KeySize)
+	if _, err := rand.Read(plaintext); err != nil {
+		return nil, nil, err
+	}
+
+	wrapped, err = k.provider.WrapKey(ctx, plaintext)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to wrap data key: %w", err)
+	}
+	return plaintext, wrapped, nil
+}
+
+// UnwrapDataKey decrypts a data key previously returned by GenerateDataKey
+func (k *Keyring) UnwrapDataKey(ctx context.Context, wrapped []byte) ([]byte, error) {
+	if k == nil {
+		return nil, ErrNotConfigured
+	}
+
+	plaintext, err := k.provider.UnwrapKey(ctx, wrapped)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unwrap data key: %w", err)
+	}
+	if len(plaintext) != KeySize {
+		return nil, fmt.Errorf("unwrapped data key must be %d bytes, got %d", KeySize, len(plaintext))
+	}
+	return plaintext, nil
+}
+
+// localKey is a master key held in memory
+type localKey struct {
+	aead cipher.AEAD
+}
+
+func (l localKey) WrapKey(ctx context.Context, plaintext []byte) ([]byte, error) {
+	return seal(l.aead, plaintext)
+}
+
+func (l localKey) UnwrapKey(ctx context.Context, wrapped []byte) ([]byte, error) {
+	return open(l.aead, wrapped)
+}
+
+// Encrypt encrypts a property value with a data key and returns the encoded ciphertext
+func Encrypt(dataKey []byte, plaintext string) (string, error) {
+	aead, err := newAEAD(dataKey)
+	if err != nil {
+		return "", err
+	}
+
+	sealed, err := seal(aead, []byte(plaintext))
+	if err != nil {
+		return "", err
+	}
+	return ciphertextPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt reverses Encrypt
+func Decrypt(dataKey []byte, ciphertext string) (string, error) {
+	if !IsEncrypted(ciphertext) {
+		return "", errors.New("value is not encrypted")
+	}
+
+	sealed, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(ciphertext, ciphertextPrefix))
+	if err != nil {
+		return "", fmt.Errorf("malformed ciphertext: %w", err)
+	}
+
+	aead, err := newAEAD(dataKey)
+	if err != nil {
+		return "", err
+	}
+
+	plaintext, err := open(aead, sealed)
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
+
+// IsEncrypted reports whether a stored value was produced by Encrypt
+func IsEncrypted(value string) bool {
+	return strings.HasPrefix(value, ciphertextPrefix)
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	if len(key) != KeySize {
+		return nil, fmt.Errorf("key must be %d bytes, got %d", KeySize, len(key))
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// seal encrypts data and prepends the random nonce to the ciphertext
+func seal(aead cipher.AEAD, data []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, data, nil), nil
+}
+
+func open(aead cipher.AEAD, sealed []byte) ([]byte, error) {
+	if len(sealed) < aead.NonceSize() {
+		return nil, errors.New("ciphertext too short")
+	}
+
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	plaintext, err := aead.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt: %w", err)
+	}
+	return plaintext, nil
+}
@@ -0,0 +1,93 @@
+package encryption
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+
+	"golang.org/x/oauth2/google"
+)
+
+// gcpKeyNamePattern matches the resource name of a Cloud KMS crypto key
+var gcpKeyNamePattern = regexp.MustCompile(`^projects/[^/]+/locations/[^/]+/keyRings/[^/]+/cryptoKeys/[^/]+$`)
+
+const gcpKMSEndpoint = "https://cloudkms.googleapis.com/v1/"
+
+// GCPKMS holds the master key in Google Cloud KMS. It calls the REST API
+// directly with Application Default Credentials.
+type GCPKMS struct {
+	client *http.Client
+	name   string
+}
+
+// NewGCPKMS creates a provider for the symmetric crypto key name, in the form
+// projects/<project>/locations/<location>/keyRings/<ring>/cryptoKeys/<key>,
+// using Application Default Credentials (GOOGLE_APPLICATION_CREDENTIALS,
+// gcloud credentials or the metadata server)
+func NewGCPKMS(ctx context.Context, name string) (*GCPKMS, error) {
+	if !gcpKeyNamePattern.MatchString(name) {
+		return nil, fmt.Errorf("ENCRYPTION_KMS_KEY must be a Cloud KMS key name projects/.../locations/.../keyRings/.../cryptoKeys/..., got %q", name)
+	}
+
+	client, err := google.DefaultClient(ctx, "https://www.googleapis.com/auth/cloudkms")
+	if err != nil {
+		return nil, fmt.Errorf("failed to load Google credentials: %w", err)
+	}
+	return &GCPKMS{client: client, name: name}, nil
+}
+
+func (g *GCPKMS) WrapKey(ctx context.Context, plaintext []byte) ([]byte, error) {
+	var out struct {
+		Ciphertext []byte `json:"ciphertext"`
+	}
+	if err := g.call(ctx, "encrypt", map[string][]byte{"plaintext": plaintext}, &out); err != nil {
+		return nil, err
+	}
+	return out.Ciphertext, nil
+}
+
+func (g *GCPKMS) UnwrapKey(ctx context.Context, wrapped []byte) ([]byte, error) {
+	var out struct {
+		Plaintext []byte `json:"plaintext"`
+	}
+	if err := g.call(ctx, "decrypt", map[string][]byte{"ciphertext": wrapped}, &out); err != nil {
+		return nil, err
+	}
+	return out.Plaintext, nil
+}
+
+// call invokes a method of the crypto key. Byte slices are sent and received
+// base64 encoded, as encoding/json does.
+func (g *GCPKMS) call(ctx context.Context, method string, in map[string][]byte, out interface{}) error {
+	body, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, gcpKMSEndpoint+g.name+":"+method, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return kmsStatusError("Cloud KMS", method, resp)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// kmsStatusError describes an unsuccessful response from a key management service
+func kmsStatusError(service, method string, resp *http.Response) error {
+	detail, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	return fmt.Errorf("%s %s failed with status %d: %s", service, method, resp.StatusCode, bytes.TrimSpace(detail))
+}
@@ -0,0 +1,50 @@
+package events
+
+import (
+	"context"
+	"sync"
+)
+
+// Broadcaster wakes up in-process subscribers, such as long-polling requests,
+// whenever a config.invalidated event is published. Subscribers are only told
+// that something changed and re-read what they watch.
+type Broadcaster struct {
+	mu          sync.Mutex
+	subscribers map[chan struct{}]struct{}
+}
+
+func NewBroadcaster() *Broadcaster {
+	return &Broadcaster{subscribers: map[chan struct{}]struct{}{}}
+}
+
+// Subscribe returns a channel that receives a signal after each invalidation,
+// coalescing signals the subscriber has not consumed yet, and a function that
+// ends the subscription
+func (b *Broadcaster) Subscribe() (<-chan struct{}, func()) {
+	ch := make(chan struct{}, 1)
+	b.mu.Lock()
+	b.subscribers[ch] = struct{}{}
+	b.mu.Unlock()
+
+	return ch, func() {
+		b.mu.Lock()
+		delete(b.subscribers, ch)
+		b.mu.Unlock()
+	}
+}
+
+func (b *Broadcaster) Publish(ctx context.Context, event Event) error {
+	if event.Type != TypeConfigInvalidated {
+		return nil
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for ch := range b.subscribers {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+	return nil
+}
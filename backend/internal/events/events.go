@@ -0,0 +1,104 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"time"
+)
+
+// SchemaVersion is the version of the Event payload. It is bumped whenever a
+// field is removed or changes meaning; new optional fields keep the version.
+const SchemaVersion = 1
+
+// Type identifies what happened
+type Type string
+
+const (
+	// TypeNodeCreated, TypeNodeUpdated and TypeNodeDeleted are published when a
+	// node is written
+	TypeNodeCreated Type = "node.created"
+	TypeNodeUpdated Type = "node.updated"
+	TypeNodeDeleted Type = "node.deleted"
+	// TypePropertyChanged is published when a property value is written
+	TypePropertyChanged Type = "property.changed"
+	// TypePropertyDeleted is published when a property is removed
+	TypePropertyDeleted Type = "property.deleted"
+	// TypeConfigInvalidated is published after every change that can alter the
+	// resolved configuration of NodeID and all of its descendants
+	TypeConfigInvalidated Type = "config.invalidated"
+)
+
+// Event describes a change to the configuration tree
+type Event struct {
+	ID            string    `json:"id"` // Unique per event, for consumers that deduplicate
+	SchemaVersion int       `json:"schema_version"`
+	Type          Type      `json:"type"`
+	NodeID        int64     `json:"node_id"`
+	PropertyID    *int64    `json:"property_id,omitempty"`
+	Key           string    `json:"key,omitempty"`
+	Environment   *string   `json:"environment,omitempty"`
+	Source        string    `json:"source"` // What made the change, e.g. "scheduler"
+	OccurredAt    time.Time `json:"occurred_at"`
+}
+
+// Publisher delivers events to interested consumers
+type Publisher interface {
+	Publish(ctx context.Context, event Event) error
+}
+
+// BatchPublisher is implemented by publishers that deliver several events at
+// once. PublishBatch returns nil only once every event has been delivered.
+type BatchPublisher interface {
+	PublishBatch(ctx context.Context, events []Event) error
+}
+
+// PublishAll delivers events in order, as a single batch when the publisher
+// supports it
+func PublishAll(ctx context.Context, publisher Publisher, events []Event) error {
+	if batcher, ok := publisher.(BatchPublisher); ok {
+		return batcher.PublishBatch(ctx, events)
+	}
+	for _, event := range events {
+		if err := publisher.Publish(ctx, event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// LogPublisher writes events to the server log. It is used when no message
+// broker is configured.
+type LogPublisher struct{}
+
+func (LogPublisher) Publish(ctx context.Context, event Event) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	log.Printf("Event: %s", payload)
+	return nil
+}
+
+// Multi publishes each event to all of its publishers, returning the first error
+type Multi []Publisher
+
+func (m Multi) Publish(ctx context.Context, event Event) error {
+	var firstErr error
+	for _, publisher := range m {
+		if err := publisher.Publish(ctx, event); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+func (m Multi) PublishBatch(ctx context.Context, events []Event) error {
+	var firstErr error
+	for _, publisher := range m {
+		if err := PublishAll(ctx, publisher, events); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
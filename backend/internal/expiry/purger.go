@@ -0,0 +1,42 @@
+package expiry
+
+import (
+	"config-manager/internal/database"
+	"context"
+	"log"
+	"time"
+)
+
+// Purger deletes expired properties. Resolution already ignores them; purging
+// keeps forgotten temporary overrides from piling up. Versions and change
+// events are recorded by the database as for any other delete.
+type Purger struct {
+	repo     *database.Repository
+	interval time.Duration
+}
+
+// New creates a purger that deletes expired properties every interval
+func New(repo *database.Repository, interval time.Duration) *Purger {
+	return &Purger{repo: repo, interval: interval}
+}
+
+// Run purges expired properties until ctx is cancelled
+func (p *Purger) Run(ctx context.Context) {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		deleted, err := p.repo.DeleteExpiredProperties(ctx, time.Now())
+		if err != nil {
+			log.Printf("Failed to purge expired properties: %v", err)
+		} else if deleted > 0 {
+			log.Printf("Purged %d expired properties", deleted)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,186 @@
+package flags
+
+import (
+	"config-manager/internal/models"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Operator compares a context attribute with the values of a condition
+type Operator string
+
+const (
+	OperatorEquals     Operator = "equals"
+	OperatorNotEquals  Operator = "not_equals"
+	OperatorIn         Operator = "in"
+	OperatorNotIn      Operator = "not_in"
+	OperatorContains   Operator = "contains"
+	OperatorStartsWith Operator = "starts_with"
+	OperatorEndsWith   Operator = "ends_with"
+	OperatorGreater    Operator = "gt"
+	OperatorLess       Operator = "lt"
+)
+
+var operators = map[Operator]bool{
+	OperatorEquals: true, OperatorNotEquals: true, OperatorIn: true, OperatorNotIn: true,
+	OperatorContains: true, OperatorStartsWith: true, OperatorEndsWith: true,
+	OperatorGreater: true, OperatorLess: true,
+}
+
+// Condition matches when the context attribute compares true with one of the values
+type Condition struct {
+	Attribute string        `json:"attribute"`
+	Operator  Operator      `json:"operator"`
+	Values    []interface{} `json:"values"`
+}
+
+// Rule serves Value when all of its conditions match
+type Rule struct {
+	Conditions []Condition `json:"conditions"`
+	Value      interface{} `json:"value"`
+}
+
+// Definition is the value of a flag property: targeting rules evaluated in
+// order, falling back to Default when none matches
+type Definition struct {
+	Default interface{} `json:"default"`
+	Rules   []Rule      `json:"rules"`
+}
+
+// Parse decodes and validates a serialized flag definition
+func Parse(value string) (*Definition, error) {
+	var def Definition
+	decoder := json.NewDecoder(strings.NewReader(value))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&def); err != nil {
+		return nil, fmt.Errorf("invalid flag definition: %w", err)
+	}
+
+	for i, rule := range def.Rules {
+		if len(rule.Conditions) == 0 {
+			return nil, fmt.Errorf("rule %d has no conditions", i)
+		}
+		for _, cond := range rule.Conditions {
+			if cond.Attribute == "" {
+				return nil, fmt.Errorf("rule %d has a condition without an attribute", i)
+			}
+			if !operators[cond.Operator] {
+				return nil, fmt.Errorf("rule %d uses unknown operator %q", i, cond.Operator)
+			}
+			if len(cond.Values) == 0 {
+				return nil, fmt.Errorf("rule %d has a condition without values", i)
+			}
+		}
+	}
+	return &def, nil
+}
+
+// Evaluate decides the value of a serialized flag definition for a context of
+// attributes such as {"user_id": "42", "country": "DE"}
+func Evaluate(value string, context map[string]interface{}) models.FlagDecision {
+	def, err := Parse(value)
+	if err != nil {
+		return models.FlagDecision{Reason: models.FlagReasonError, Error: err.Error()}
+	}
+	return def.Evaluate(context)
+}
+
+// Evaluate returns the value of the first rule whose conditions all match
+func (d *Definition) Evaluate(context map[string]interface{}) models.FlagDecision {
+	for i, rule := range d.Rules {
+		if rule.matches(context) {
+			index := i
+			return models.FlagDecision{Value: rule.Value, Reason: models.FlagReasonRuleMatch, RuleIndex: &index}
+		}
+	}
+	return models.FlagDecision{Value: d.Default, Reason: models.FlagReasonDefault}
+}
+
+func (r Rule) matches(context map[string]interface{}) bool {
+	for _, cond := range r.Conditions {
+		if !cond.matches(context) {
+			return false
+		}
+	}
+	return true
+}
+
+func (c Condition) matches(context map[string]interface{}) bool {
+	attr, ok := context[c.Attribute]
+	if !ok || attr == nil {
+		// Negative operators hold for missing attributes
+		return c.Operator == OperatorNotEquals || c.Operator == OperatorNotIn
+	}
+
+	switch c.Operator {
+	case OperatorNotEquals, OperatorNotIn:
+		return !c.anyValue(attr, OperatorEquals)
+	case OperatorIn:
+		return c.anyValue(attr, OperatorEquals)
+	default:
+		return c.anyValue(attr, c.Operator)
+	}
+}
+
+func (c Condition) anyValue(attr interface{}, op Operator) bool {
+	for _, value := range c.Values {
+		if compare(attr, value, op) {
+			return true
+		}
+	}
+	return false
+}
+
+func compare(attr, value interface{}, op Operator) bool {
+	switch op {
+	case OperatorGreater, OperatorLess:
+		a, errA := toNumber(attr)
+		v, errV := toNumber(value)
+		if errA != nil || errV != nil {
+			return false
+		}
+		if op == OperatorGreater {
+			return a > v
+		}
+		return a < v
+	}
+
+	a, v := toString(attr), toString(value)
+	switch op {
+	case OperatorEquals:
+		return a == v
+	case OperatorContains:
+		return strings.Contains(a, v)
+	case OperatorStartsWith:
+		return strings.HasPrefix(a, v)
+	case OperatorEndsWith:
+		return strings.HasSuffix(a, v)
+	}
+	return false
+}
+
+func toString(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+var errNotNumber = errors.New("not a number")
+
+func toNumber(value interface{}) (float64, error) {
+	switch v := value.(type) {
+	case float64:
+		return v, nil
+	case string:
+		return strconv.ParseFloat(v, 64)
+	}
+	return 0, errNotNumber
+}
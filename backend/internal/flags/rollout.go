@@ -0,0 +1,43 @@
+package flags
+
+import (
+	"config-manager/internal/models"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+)
+
+// Bucket deterministically maps a bucketing value to [0, 100) with a
+// resolution of 0.01. The salt, usually the property key, keeps the buckets of
+// different rollouts independent.
+func Bucket(salt, value string) float64 {
+	sum := sha256.Sum256([]byte(salt + "/" + value))
+	return float64(binary.BigEndian.Uint32(sum[:4])%10000) / 100
+}
+
+// HasRollout reports whether a property is served through a percentage rollout
+func HasRollout(prop models.ConfigProperty) bool {
+	return prop.DataType == models.DataTypeBoolean && prop.RolloutPercentage != nil && prop.RolloutKey != nil
+}
+
+// EvaluateRollout decides the value of a boolean property with a rollout: its
+// value for contexts whose rollout key attribute falls in the rollout
+// percentage, the opposite for all others. Contexts without the attribute,
+// such as resolving without a context, cannot be bucketed and are served the
+// stored value as is.
+func EvaluateRollout(prop models.ConfigProperty, context map[string]interface{}) models.FlagDecision {
+	var value bool
+	if err := json.Unmarshal([]byte(prop.Value), &value); err != nil {
+		return models.FlagDecision{Reason: models.FlagReasonError, Error: fmt.Sprintf("rollout value is not a boolean: %v", err)}
+	}
+
+	attr, ok := context[*prop.RolloutKey]
+	if !ok || attr == nil {
+		return models.FlagDecision{Value: value, Reason: models.FlagReasonDefault}
+	}
+	if Bucket(prop.Key, toString(attr)) < *prop.RolloutPercentage {
+		return models.FlagDecision{Value: value, Reason: models.FlagReasonRollout}
+	}
+	return models.FlagDecision{Value: !value, Reason: models.FlagReasonRolloutExcluded}
+}
@@ -0,0 +1,76 @@
+package flags
+
+import (
+	"config-manager/internal/models"
+	"testing"
+)
+
+func rolloutProperty(value string, percentage float64) models.ConfigProperty {
+	key := "user_id"
+	return models.ConfigProperty{
+		Key:               "new_pricing_page",
+		Value:             value,
+		DataType:          models.DataTypeBoolean,
+		RolloutPercentage: &percentage,
+		RolloutKey:        &key,
+	}
+}
+
+func TestEvaluateRollout(t *testing.T) {
+	users := []string{"1", "42", "alice", "bob", "d9b2f0c4"}
+
+	tests := []struct {
+		name       string
+		value      string
+		percentage float64
+		context    map[string]interface{}
+		want       interface{}
+		reason     models.FlagReason
+	}{
+		{"0% excludes everyone", "true", 0, map[string]interface{}{"user_id": "42"}, false, models.FlagReasonRolloutExcluded},
+		{"100% includes everyone", "true", 100, map[string]interface{}{"user_id": "42"}, true, models.FlagReasonRollout},
+		{"100% of a false value", "false", 100, map[string]interface{}{"user_id": "42"}, false, models.FlagReasonRollout},
+		{"numeric attribute", "true", 100, map[string]interface{}{"user_id": 42}, true, models.FlagReasonRollout},
+		{"no context at 0%", "true", 0, nil, true, models.FlagReasonDefault},
+		{"no context at 100%", "true", 100, nil, true, models.FlagReasonDefault},
+		{"missing attribute", "false", 50, map[string]interface{}{"country": "DE"}, false, models.FlagReasonDefault},
+		{"null attribute", "true", 0, map[string]interface{}{"user_id": nil}, true, models.FlagReasonDefault},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decision := EvaluateRollout(rolloutProperty(tt.value, tt.percentage), tt.context)
+			if decision.Value != tt.want || decision.Reason != tt.reason {
+				t.Errorf("got %v (%s), want %v (%s)", decision.Value, decision.Reason, tt.want, tt.reason)
+			}
+		})
+	}
+
+	for _, percentage := range []float64{0, 100} {
+		for _, user := range users {
+			decision := EvaluateRollout(rolloutProperty("true", percentage), map[string]interface{}{"user_id": user})
+			if included := decision.Value == true; included != (percentage == 100) {
+				t.Errorf("user %s at %v%%: got %v", user, percentage, decision.Value)
+			}
+		}
+	}
+}
+
+func TestEvaluateRolloutInvalidValue(t *testing.T) {
+	decision := EvaluateRollout(rolloutProperty(`"yes"`, 50), map[string]interface{}{"user_id": "42"})
+	if decision.Reason != models.FlagReasonError || decision.Error == "" {
+		t.Errorf("got %+v, want an error decision", decision)
+	}
+}
+
+func TestBucket(t *testing.T) {
+	for _, value := range []string{"", "1", "42", "alice"} {
+		bucket := Bucket("new_pricing_page", value)
+		if bucket < 0 || bucket >= 100 {
+			t.Errorf("Bucket(%q) = %v, want [0, 100)", value, bucket)
+		}
+		if again := Bucket("new_pricing_page", value); again != bucket {
+			t.Errorf("Bucket(%q) is not deterministic: %v then %v", value, bucket, again)
+		}
+	}
+}
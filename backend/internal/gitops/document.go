@@ -0,0 +1,127 @@
+package gitops
+
+import (
+	"config-manager/internal/database"
+	"config-manager/internal/models"
+	"context"
+	"encoding/json"
+	"fmt"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// NodeDocument is the YAML file of one node. IDs identify nodes on import; file
+// paths only mirror the tree for readers.
+type NodeDocument struct {
+	ID          int64              `yaml:"id"`
+	Name        string             `yaml:"name"`
+	Type        models.NodeType    `yaml:"type"`
+	ParentID    *int64             `yaml:"parent_id,omitempty"`
+	Description string             `yaml:"description,omitempty"`
+	Properties  []PropertyDocument `yaml:"properties,omitempty"`
+}
+
+// PropertyDocument is a property of a node document. Values are decoded from
+// JSON so they read naturally in YAML. Encrypted values are never exported.
+type PropertyDocument struct {
+	Key               string          `yaml:"key"`
+	Environment       *string         `yaml:"environment,omitempty"`
+	Type              models.DataType `yaml:"type"`
+	Value             interface{}     `yaml:"value,omitempty"`
+	DefaultValue      *string         `yaml:"default_value,omitempty"`
+	Description       string          `yaml:"description,omitempty"`
+	Encrypted         bool            `yaml:"encrypted,omitempty"`
+	RolloutPercentage *float64        `yaml:"rollout_percentage,omitempty"`
+	RolloutKey        *string         `yaml:"rollout_key,omitempty"`
+	Final             bool            `yaml:"final,omitempty"`
+}
+
+var slugPattern = regexp.MustCompile(`[^a-z0-9]+`)
+
+// segment names a node in file paths, e.g. "north-america-12"
+func segment(node models.ConfigNode) string {
+	slug := strings.Trim(slugPattern.ReplaceAllString(strings.ToLower(node.Name), "-"), "-")
+	if slug == "" {
+		return strconv.FormatInt(node.ID, 10)
+	}
+	return slug + "-" + strconv.FormatInt(node.ID, 10)
+}
+
+// exportTree builds the document of every node, keyed by its path relative to
+// the export directory: <ancestor segments>/<segment>.yaml
+func exportTree(ctx context.Context, repo *database.Repository) (map[string]NodeDocument, error) {
+	nodes, err := repo.GetSubtreeWithProperties(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// Parents come before their children, so their directories are known
+	dirs := make(map[int64]string, len(nodes))
+	documents := make(map[string]NodeDocument, len(nodes))
+	for _, node := range nodes {
+		dir := ""
+		if node.ParentID != nil {
+			dir = dirs[*node.ParentID]
+		}
+		dirs[node.ID] = path.Join(dir, segment(node.ConfigNode))
+
+		document := NodeDocument{
+			ID:          node.ID,
+			Name:        node.Name,
+			Type:        node.NodeType,
+			ParentID:    node.ParentID,
+			Description: node.Description,
+		}
+		for _, prop := range node.Properties {
+			pd := PropertyDocument{
+				Key:               prop.Key,
+				Environment:       prop.Environment,
+				Type:              prop.DataType,
+				Description:       prop.Description,
+				Encrypted:         prop.Encrypted,
+				RolloutPercentage: prop.RolloutPercentage,
+				RolloutKey:        prop.RolloutKey,
+				Final:             prop.IsFinal,
+			}
+			if !prop.Encrypted {
+				pd.DefaultValue = prop.DefaultValue
+				if err := json.Unmarshal([]byte(prop.Value), &pd.Value); err != nil {
+					pd.Value = prop.Value
+				}
+			}
+			document.Properties = append(document.Properties, pd)
+		}
+
+		documents[path.Join(dir, segment(node.ConfigNode)+".yaml")] = document
+	}
+	return documents, nil
+}
+
+// createRequest converts an exported property back into a property write
+func (pd PropertyDocument) createRequest() (models.CreatePropertyRequest, error) {
+	value, err := json.Marshal(pd.Value)
+	if err != nil {
+		return models.CreatePropertyRequest{}, fmt.Errorf("property %s: %w", pd.Key, err)
+	}
+	return models.CreatePropertyRequest{
+		Key:               pd.Key,
+		Value:             string(value),
+		DataType:          pd.Type,
+		DefaultValue:      pd.DefaultValue,
+		Description:       pd.Description,
+		Environment:       pd.Environment,
+		RolloutPercentage: pd.RolloutPercentage,
+		RolloutKey:        pd.RolloutKey,
+		IsFinal:           pd.Final,
+	}, nil
+}
+
+// propertyID identifies a property within its node
+func propertyID(key string, environment *string) string {
+	if environment == nil {
+		return key
+	}
+	return key + "@" + *environment
+}
@@ -0,0 +1,104 @@
+package gitops
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// gitRepo runs git commands in a local clone of the configured repository
+type gitRepo struct {
+	dir         string
+	url         string
+	branch      string
+	authorName  string
+	authorEmail string
+}
+
+func (g *gitRepo) run(ctx context.Context, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = g.dir
+	// Never wait for credentials on a terminal
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("git %s: %w: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
+
+// ensureClone clones the repository unless dir already holds a clone
+func (g *gitRepo) ensureClone(ctx context.Context) error {
+	if _, err := os.Stat(filepath.Join(g.dir, ".git")); err == nil {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(g.dir), 0o755); err != nil {
+		return err
+	}
+
+	cmd := exec.CommandContext(ctx, "git", "clone", "--branch", g.branch, g.url, g.dir)
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+	if output, err := cmd.CombinedOutput(); err != nil {
+		// Never log the URL, it may carry credentials
+		return fmt.Errorf("git clone: %w: %s", err, strings.TrimSpace(strings.ReplaceAll(string(output), g.url, "<repository>")))
+	}
+	return nil
+}
+
+// fetch updates the remote branch and returns its head commit
+func (g *gitRepo) fetch(ctx context.Context) (string, error) {
+	if _, err := g.run(ctx, "fetch", "origin", g.branch); err != nil {
+		return "", err
+	}
+	return g.run(ctx, "rev-parse", "origin/"+g.branch)
+}
+
+func (g *gitRepo) head(ctx context.Context) (string, error) {
+	return g.run(ctx, "rev-parse", "HEAD")
+}
+
+// changedSince lists the files under dir changed on the remote branch since it
+// diverged from local, i.e. the changes made in the repository by others
+func (g *gitRepo) changedSince(ctx context.Context, local, remote, dir string) ([]string, error) {
+	output, err := g.run(ctx, "diff", "--name-only", "--diff-filter=AM", local+"..."+remote, "--", dir)
+	if err != nil || output == "" {
+		return nil, err
+	}
+	return strings.Split(output, "\n"), nil
+}
+
+func (g *gitRepo) resetTo(ctx context.Context, commit string) error {
+	_, err := g.run(ctx, "reset", "--hard", commit)
+	return err
+}
+
+// commit commits all changes under dir and reports whether there were any
+func (g *gitRepo) commit(ctx context.Context, dir, message string) (bool, error) {
+	if _, err := g.run(ctx, "add", "--all", "--", dir); err != nil {
+		return false, err
+	}
+
+	_, err := g.run(ctx, "diff", "--cached", "--quiet")
+	var exitErr *exec.ExitError
+	if err == nil {
+		return false, nil
+	}
+	if !errors.As(err, &exitErr) {
+		return false, err
+	}
+
+	_, err = g.run(ctx, "-c", "user.name="+g.authorName, "-c", "user.email="+g.authorEmail, "commit", "--quiet", "-m", message)
+	return err == nil, err
+}
+
+func (g *gitRepo) push(ctx context.Context) error {
+	_, err := g.run(ctx, "push", "origin", "HEAD:"+g.branch)
+	return err
+}
@@ -0,0 +1,268 @@
+package gitops
+
+import (
+	"config-manager/internal/database"
+	"config-manager/internal/events"
+	"config-manager/internal/flags"
+	"config-manager/internal/models"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config configures the repository the tree is exported to
+type Config struct {
+	RepoURL     string
+	Branch      string
+	Dir         string // Local clone
+	Path        string // Directory within the repository holding the node files
+	Import      bool   // Apply node files edited in the repository
+	Interval    time.Duration
+	AuthorName  string
+	AuthorEmail string
+}
+
+// Syncer commits the exported tree to a Git repository whenever it changes.
+// With imports enabled, node files edited in the repository since the last sync
+// are applied to the database first, so both sides converge.
+type Syncer struct {
+	repo   *database.Repository
+	git    *gitRepo
+	cfg    Config
+	notify chan struct{}
+}
+
+// New creates a syncer. The export directory is replaced on every sync, so it
+// must be a subdirectory of the repository.
+func New(repo *database.Repository, cfg Config) (*Syncer, error) {
+	cfg.Path = filepath.Clean(cfg.Path)
+	if cfg.Path == "." || filepath.IsAbs(cfg.Path) || strings.HasPrefix(cfg.Path, "..") {
+		return nil, fmt.Errorf("export path %q must be a subdirectory of the repository", cfg.Path)
+	}
+	if cfg.RepoURL == "" || cfg.Branch == "" || cfg.Dir == "" {
+		return nil, errors.New("repository URL, branch and clone directory are required")
+	}
+
+	return &Syncer{
+		repo: repo,
+		git: &gitRepo{
+			dir:         cfg.Dir,
+			url:         cfg.RepoURL,
+			branch:      cfg.Branch,
+			authorName:  cfg.AuthorName,
+			authorEmail: cfg.AuthorEmail,
+		},
+		cfg:    cfg,
+		notify: make(chan struct{}, 1),
+	}, nil
+}
+
+// Publish triggers a sync, so the syncer can be passed wherever change events are published
+func (s *Syncer) Publish(ctx context.Context, event events.Event) error {
+	select {
+	case s.notify <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// Run syncs on every change event and every interval until ctx is cancelled
+func (s *Syncer) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		if err := s.sync(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("GitOps sync failed: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-s.notify:
+		}
+	}
+}
+
+func (s *Syncer) sync(ctx context.Context) error {
+	if err := s.git.ensureClone(ctx); err != nil {
+		return err
+	}
+
+	remote, err := s.git.fetch(ctx)
+	if err != nil {
+		return err
+	}
+	local, err := s.git.head(ctx)
+	if err != nil {
+		return err
+	}
+
+	var changed []string
+	if s.cfg.Import && local != remote {
+		if changed, err = s.git.changedSince(ctx, local, remote, s.cfg.Path); err != nil {
+			return err
+		}
+	}
+
+	// Unpushed local commits are dropped, the export below recreates them
+	if err := s.git.resetTo(ctx, remote); err != nil {
+		return err
+	}
+
+	for _, file := range changed {
+		if err := s.importFile(ctx, file); err != nil {
+			log.Printf("GitOps: not importing %s: %v", file, err)
+		}
+	}
+
+	exported, err := s.export(ctx)
+	if err != nil {
+		return err
+	}
+
+	committed, err := s.git.commit(ctx, s.cfg.Path, fmt.Sprintf("Update configuration (%d nodes)", exported))
+	if err != nil || !committed {
+		return err
+	}
+	if err := s.git.push(ctx); err != nil {
+		return err
+	}
+	log.Printf("GitOps: pushed configuration of %d nodes to %s", exported, s.cfg.Branch)
+	return nil
+}
+
+// export replaces the export directory with the current tree, returning the number of nodes
+func (s *Syncer) export(ctx context.Context) (int, error) {
+	documents, err := exportTree(ctx, s.repo)
+	if err != nil {
+		return 0, err
+	}
+
+	root := filepath.Join(s.cfg.Dir, s.cfg.Path)
+	if err := os.RemoveAll(root); err != nil {
+		return 0, err
+	}
+	for file, document := range documents {
+		target := filepath.Join(root, filepath.FromSlash(file))
+		if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+			return 0, err
+		}
+		data, err := yaml.Marshal(document)
+		if err != nil {
+			return 0, err
+		}
+		if err := os.WriteFile(target, data, 0o644); err != nil {
+			return 0, err
+		}
+	}
+	return len(documents), nil
+}
+
+// importFile applies the node file at file, relative to the repository root.
+// Only the name, description and unencrypted properties of existing nodes are
+// imported; nodes in protected subtrees are left alone.
+func (s *Syncer) importFile(ctx context.Context, file string) error {
+	data, err := os.ReadFile(filepath.Join(s.cfg.Dir, filepath.FromSlash(file)))
+	if err != nil {
+		return err
+	}
+	var document NodeDocument
+	if err := yaml.Unmarshal(data, &document); err != nil {
+		return err
+	}
+
+	node, err := s.repo.GetNodeByID(ctx, document.ID)
+	if err != nil {
+		return err
+	}
+	if node == nil {
+		return fmt.Errorf("node %d does not exist, nodes are only created through the API", document.ID)
+	}
+	required, err := s.repo.RequiredApprovals(ctx, node.ID, false)
+	if err != nil {
+		return err
+	}
+	if required > 0 {
+		return fmt.Errorf("node %d is protected and only changes through change requests", node.ID)
+	}
+
+	if document.Name != node.Name || document.Description != node.Description {
+		if _, err := s.repo.UpdateNode(ctx, node.ID, models.UpdateNodeRequest{Name: &document.Name, Description: &document.Description}); err != nil {
+			return err
+		}
+	}
+
+	live, err := s.repo.GetPropertiesByNodeID(ctx, node.ID)
+	if err != nil {
+		return err
+	}
+	liveByID := make(map[string]models.ConfigProperty, len(live))
+	for _, prop := range live {
+		liveByID[propertyID(prop.Key, prop.Environment)] = prop
+	}
+
+	declared := make(map[string]bool, len(document.Properties))
+	for _, pd := range document.Properties {
+		id := propertyID(pd.Key, pd.Environment)
+		declared[id] = true
+		if pd.Encrypted {
+			continue
+		}
+
+		req, err := pd.createRequest()
+		if err != nil {
+			return err
+		}
+		if req.DataType == models.DataTypeFlag {
+			if _, err := flags.Parse(req.Value); err != nil {
+				return fmt.Errorf("property %s: %w", id, err)
+			}
+		}
+		if prop, ok := liveByID[id]; ok && !prop.Encrypted && sameProperty(prop, req) {
+			continue
+		}
+		if prop, ok := liveByID[id]; ok && prop.Encrypted {
+			return fmt.Errorf("property %s is encrypted and cannot be imported", id)
+		}
+		if _, err := s.repo.CreateProperty(ctx, node.ID, req); err != nil {
+			return fmt.Errorf("property %s: %w", id, err)
+		}
+	}
+
+	for id, prop := range liveByID {
+		if !declared[id] && !prop.Encrypted {
+			if err := s.repo.DeleteProperty(ctx, prop.ID); err != nil {
+				return fmt.Errorf("property %s: %w", id, err)
+			}
+		}
+	}
+
+	log.Printf("GitOps: imported node %d from %s", node.ID, file)
+	return nil
+}
+
+// sameProperty reports whether writing req would leave prop unchanged
+func sameProperty(prop models.ConfigProperty, req models.CreatePropertyRequest) bool {
+	var liveValue, value interface{}
+	if json.Unmarshal([]byte(prop.Value), &liveValue) != nil || json.Unmarshal([]byte(req.Value), &value) != nil {
+		return prop.Value == req.Value
+	}
+	return reflect.DeepEqual(liveValue, value) &&
+		prop.DataType == req.DataType &&
+		prop.Description == req.Description &&
+		reflect.DeepEqual(prop.DefaultValue, req.DefaultValue) &&
+		reflect.DeepEqual(prop.RolloutPercentage, req.RolloutPercentage) &&
+		reflect.DeepEqual(prop.RolloutKey, req.RolloutKey) &&
+		prop.IsFinal == req.IsFinal
+}
@@ -0,0 +1,176 @@
+package handlers
+
+import (
+        "config-manager/internal/auth"
+        "config-manager/internal/database"
+        "config-manager/internal/models"
+        "config-manager/internal/problem"
+        "errors"
+        "net/http"
+        "strconv"
+
+        "github.com/gin-gonic/gin"
+)
+
+// authorize writes an error response and returns false unless the request may
+// perform an operation needing perm on the subtree containing nodeID
+func (h *Handler) authorize(c *gin.Context, nodeID int64, perm models.Permission) bool {
+        if !h.acl.Enforced() {
+                return true
+        }
+        if len(auth.Principals(c)) == 0 {
+                problem.Respond(c, http.StatusUnauthorized, problem.CodeUnauthenticated, "Authentication required")
+                return false
+        }
+
+        allowed, err := h.acl.Allowed(c, nodeID, perm)
+        if err != nil {
+                respondError(c, err, "Failed to check permissions")
+                return false
+        }
+        if !allowed {
+                problem.Respond(c, http.StatusForbidden, problem.CodePermissionDenied, "Permission denied")
+                return false
+        }
+        return true
+}
+
+// authorizeProperty authorizes an operation on the node owning a property
+func (h *Handler) authorizeProperty(c *gin.Context, propertyID int64, perm models.Permission) bool {
+        if !h.acl.Enforced() {
+                return true
+        }
+
+        nodeID, err := h.repo.GetPropertyNodeID(c.Request.Context(), propertyID)
+        if err != nil {
+                respondError(c, err, "Failed to check permissions")
+                return false
+        }
+        if nodeID == nil {
+                problem.Respond(c, http.StatusNotFound, problem.CodePropertyNotFound, "Property not found")
+                return false
+        }
+        return h.authorize(c, *nodeID, perm)
+}
+
+// authorizeAdmin writes an error response and returns false unless the request
+// may bypass subtree permissions
+func (h *Handler) authorizeAdmin(c *gin.Context) bool {
+        if h.acl.IsAdmin(c) {
+                return true
+        }
+        if len(auth.Principals(c)) == 0 {
+                problem.Respond(c, http.StatusUnauthorized, problem.CodeUnauthenticated, "Authentication required")
+                return false
+        }
+        if h.acl.AwaitingTOTP(c) {
+                problem.Respond(c, http.StatusForbidden, problem.CodeTOTPRequired, "Verify a code of your authenticator app to use admin access")
+                return false
+        }
+        problem.Respond(c, http.StatusForbidden, problem.CodeAdminRequired, "Admin access required")
+        return false
+}
+
+// filterReadable drops the nodes the request may not read
+func (h *Handler) filterReadable(c *gin.Context, nodes []models.ConfigNode) ([]models.ConfigNode, error) {
+        if h.acl.IsAdmin(c) {
+                return nodes, nil
+        }
+
+        readable := []models.ConfigNode{}
+        for _, node := range nodes {
+                allowed, err := h.acl.Allowed(c, node.ID, models.PermissionRead)
+                if err != nil {
+                        return nil, err
+                }
+                if allowed {
+                        readable = append(readable, node)
+                }
+        }
+        return readable, nil
+}
+
+// Access control handlers
+func (h *Handler) GetNodePermissions(c *gin.Context) {
+        nodeID, err := strconv.ParseInt(c.Param("nodeId"), 10, 64)
+        if err != nil {
+                problem.Respond(c, http.StatusBadRequest, problem.CodeInvalidID, "Invalid node ID")
+                return
+        }
+
+        if !h.authorizeAdmin(c) {
+                return
+        }
+
+        perms, err := h.repo.GetNodePermissions(c.Request.Context(), nodeID)
+        if err != nil {
+                respondError(c, err, "Failed to get permissions")
+                return
+        }
+
+        c.JSON(http.StatusOK, perms)
+}
+
+func (h *Handler) GrantNodePermission(c *gin.Context) {
+        nodeID, err := strconv.ParseInt(c.Param("nodeId"), 10, 64)
+        if err != nil {
+                problem.Respond(c, http.StatusBadRequest, problem.CodeInvalidID, "Invalid node ID")
+                return
+        }
+
+        if !h.authorizeAdmin(c) {
+                return
+        }
+
+        var req models.GrantPermissionRequest
+        if err := c.ShouldBindJSON(&req); err != nil {
+                problem.Respond(c, http.StatusBadRequest, problem.CodeInvalidRequest, err.Error())
+                return
+        }
+
+        if req.Permission != models.PermissionRead && req.Permission != models.PermissionWrite {
+                problem.Respond(c, http.StatusBadRequest, problem.CodeInvalidRequest, "permission must be 'read' or 'write'")
+                return
+        }
+
+        node, err := h.repo.GetNodeByID(c.Request.Context(), nodeID)
+        if err != nil {
+                respondError(c, err, "Failed to validate node")
+                return
+        }
+        if node == nil {
+                problem.Respond(c, http.StatusNotFound, problem.CodeNodeNotFound, "Node not found")
+                return
+        }
+
+        perm, err := h.repo.GrantNodePermission(c.Request.Context(), nodeID, req)
+        if err != nil {
+                respondError(c, err, "Failed to grant permission")
+                return
+        }
+
+        c.JSON(http.StatusCreated, perm)
+}
+
+func (h *Handler) RevokeNodePermission(c *gin.Context) {
+        id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+        if err != nil {
+                problem.Respond(c, http.StatusBadRequest, problem.CodeInvalidID, "Invalid permission ID")
+                return
+        }
+
+        if !h.authorizeAdmin(c) {
+                return
+        }
+
+        if err := h.repo.RevokeNodePermission(c.Request.Context(), id); err != nil {
+                if errors.Is(err, database.ErrPermissionNotFound) {
+                        problem.Respond(c, http.StatusNotFound, problem.CodeNotFound, "Permission not found")
+                        return
+                }
+                respondError(c, err, "Failed to revoke permission")
+                return
+        }
+
+        c.JSON(http.StatusNoContent, nil)
+}
@@ -0,0 +1,187 @@
+package handlers
+
+import (
+        "config-manager/internal/database"
+        "config-manager/internal/flags"
+        "config-manager/internal/models"
+        "config-manager/internal/problem"
+        "encoding/json"
+        "errors"
+        "fmt"
+        "net/http"
+
+        "github.com/gin-gonic/gin"
+)
+
+// ApplyDocument converges a subtree to a declarative document, creating,
+// updating and deleting nodes and properties as needed. With ?dryRun=true
+// only the plan is returned. Documents may be sent as JSON or YAML.
+func (h *Handler) ApplyDocument(c *gin.Context) {
+        req, root, ok := h.bindDocument(c, models.PermissionWrite)
+        if !ok {
+                return
+        }
+
+        plan, err := h.repo.ApplyDocument(c.Request.Context(), req.ParentID, req.Node, true)
+        if err != nil {
+                writeApplyError(c, err, "Failed to apply document")
+                return
+        }
+
+        // Only properties the document creates need registered keys
+        var created []string
+        for _, change := range plan.Changes {
+                if change.Action == "create" && change.Kind == "property" {
+                        created = append(created, change.Key)
+                }
+        }
+        if !h.guardRegisteredKeys(c, created...) {
+                return
+        }
+
+        if c.Query("dryRun") == "true" {
+                c.JSON(http.StatusOK, plan)
+                return
+        }
+
+        if root != nil {
+                if !h.guardProtected(c, root.ID, true) {
+                        return
+                }
+        } else if req.ParentID != nil && !h.guardProtected(c, *req.ParentID, false) {
+                return
+        }
+
+        // Deletes are guarded like individual deletes of the same configuration
+        guarded := make(map[int64]bool)
+        for _, change := range plan.Changes {
+                if change.Action != "delete" || guarded[*change.NodeID] {
+                        continue
+                }
+                guarded[*change.NodeID] = true
+                if !h.guardDelete(c, *change.NodeID) {
+                        return
+                }
+        }
+
+        result, err := h.repo.ApplyDocument(c.Request.Context(), req.ParentID, req.Node, false)
+        if err != nil {
+                writeApplyError(c, err, "Failed to apply document")
+                return
+        }
+
+        c.JSON(http.StatusOK, result)
+}
+
+// bindDocument reads and validates the document of an apply or drift request,
+// and authorizes perm on the subtree it declares. Declaring a subtree that does
+// not exist needs perm on its parent, or admin access for a root node. The
+// existing subtree root is returned if there is one.
+func (h *Handler) bindDocument(c *gin.Context, perm models.Permission) (*models.ApplyRequest, *models.ConfigNode, bool) {
+        var req models.ApplyRequest
+        var err error
+        switch c.ContentType() {
+        case "application/yaml", "application/x-yaml":
+                err = c.ShouldBindYAML(&req)
+        default:
+                err = c.ShouldBindJSON(&req)
+        }
+        if err != nil {
+                problem.Respond(c, http.StatusBadRequest, problem.CodeInvalidRequest, err.Error())
+                return nil, nil, false
+        }
+
+        if err := validateDeclaredNode(req.Node, ""); err != nil {
+                respondInvalid(c, err)
+                return nil, nil, false
+        }
+
+        if req.ParentID != nil {
+                parent, err := h.repo.GetNodeByID(c.Request.Context(), *req.ParentID)
+                if err != nil {
+                        respondError(c, err, "Failed to validate parent node")
+                        return nil, nil, false
+                }
+                if parent == nil {
+                        problem.Respond(c, http.StatusNotFound, problem.CodeParentNotFound, "Parent node not found")
+                        return nil, nil, false
+                }
+        }
+
+        roots, err := h.repo.FindNodesByName(c.Request.Context(), req.ParentID, req.Node.Name)
+        if err != nil {
+                respondError(c, err, "Failed to find subtree")
+                return nil, nil, false
+        }
+        var root *models.ConfigNode
+        switch {
+        case len(roots) == 1:
+                root = &roots[0]
+                if !h.authorize(c, root.ID, perm) {
+                        return nil, nil, false
+                }
+        case req.ParentID != nil:
+                if !h.authorize(c, *req.ParentID, perm) {
+                        return nil, nil, false
+                }
+        default:
+                if !h.authorizeAdmin(c) {
+                        return nil, nil, false
+                }
+        }
+        return &req, root, true
+}
+
+// validateDeclaredNode checks a document with the rules of the node and
+// property endpoints
+func validateDeclaredNode(node models.DeclaredNode, parentPath string) error {
+        path := parentPath + "/" + node.Name
+        if node.Name == "" {
+                return fmt.Errorf("%s: node without a name", parentPath+"/")
+        }
+        if node.Type != models.NodeTypeTerritory && node.Type != models.NodeTypeCenter {
+                return fmt.Errorf("%s: type must be 'territory' or 'center'", path)
+        }
+
+        for _, p := range node.Properties {
+                if p.Key == "" {
+                        return fmt.Errorf("%s: property without a key", path)
+                }
+                propertyPath := path + ":" + p.Key
+                if !validDataTypes[p.Type] {
+                        return fmt.Errorf("%s: invalid data type", propertyPath)
+                }
+                if p.Environment != nil && !environmentPattern.MatchString(*p.Environment) {
+                        return fmt.Errorf("%s: invalid environment", propertyPath)
+                }
+                value, err := json.Marshal(p.Value)
+                if err != nil {
+                        return fmt.Errorf("%s: %w", propertyPath, err)
+                }
+                if err := checkValueSize(string(value)); err != nil {
+                        return fmt.Errorf("%s: %w", propertyPath, err)
+                }
+                if p.Type == models.DataTypeFlag && !p.Encrypted {
+                        if _, err := flags.Parse(string(value)); err != nil {
+                                return fmt.Errorf("%s: %w", propertyPath, err)
+                        }
+                }
+        }
+
+        for _, child := range node.Children {
+                if err := validateDeclaredNode(child, path); err != nil {
+                        return err
+                }
+        }
+        return nil
+}
+
+// writeApplyError maps apply and drift repository errors to responses
+func writeApplyError(c *gin.Context, err error, fallback string) {
+        var applyErr *database.ApplyError
+        if errors.As(err, &applyErr) {
+                problem.Respond(c, http.StatusUnprocessableEntity, problem.CodeInvalidDocument, err.Error())
+                return
+        }
+        respondError(c, err, fallback)
+}
@@ -0,0 +1,40 @@
+package handlers
+
+import (
+        "config-manager/internal/problem"
+        "net/http"
+        "strconv"
+
+        "github.com/gin-gonic/gin"
+)
+
+const (
+        defaultAuditLimit = 100
+        maxAuditLimit     = 1000
+)
+
+// GetAuditLog lists audited administrative operations, newest first,
+// optionally only those of the ?action= given
+func (h *Handler) GetAuditLog(c *gin.Context) {
+        if !h.authorizeAdmin(c) {
+                return
+        }
+
+        limit := defaultAuditLimit
+        if limitStr := c.Query("limit"); limitStr != "" {
+                var err error
+                limit, err = strconv.Atoi(limitStr)
+                if err != nil || limit < 1 || limit > maxAuditLimit {
+                        problem.Respond(c, http.StatusBadRequest, problem.CodeInvalidRequest, "limit must be between 1 and 1000")
+                        return
+                }
+        }
+
+        entries, err := h.repo.GetAuditLog(c.Request.Context(), c.Query("action"), limit)
+        if err != nil {
+                respondError(c, err, "Failed to get audit log")
+                return
+        }
+
+        c.JSON(http.StatusOK, entries)
+}
@@ -0,0 +1,174 @@
+package handlers
+
+import (
+        "config-manager/internal/models"
+        "config-manager/internal/problem"
+        "fmt"
+        "net/http"
+
+        "github.com/gin-gonic/gin"
+)
+
+// maxBatchOperations bounds the operations of a batch, all of which run in
+// one transaction
+const maxBatchOperations = 1000
+
+// ApplyBatch applies a mixed list of node and property writes in a single
+// transaction: either all of them are applied or, when one fails, none.
+// Operations refer to a node created earlier in the batch with a negative ID,
+// -1 being the node created by the first operation, so that a node and its
+// properties can be provisioned together.
+func (h *Handler) ApplyBatch(c *gin.Context) {
+        var req models.BatchRequest
+        if err := c.ShouldBindJSON(&req); err != nil {
+                problem.Respond(c, http.StatusBadRequest, problem.CodeInvalidRequest, err.Error())
+                return
+        }
+        if len(req.Operations) > maxBatchOperations {
+                problem.Respond(c, http.StatusBadRequest, problem.CodeInvalidRequest, fmt.Sprintf("a batch may have at most %d operations", maxBatchOperations))
+                return
+        }
+
+        var keys []string
+        for i := range req.Operations {
+                if err := validateBatchOperation(req.Operations, i); err != nil {
+                        respondError(c, fmt.Errorf("operations[%d]: %w", i, err), "Invalid batch")
+                        return
+                }
+                if op := req.Operations[i]; op.Op == models.BatchOpCreateProperty {
+                        keys = append(keys, op.CreateProperty.Key)
+                }
+        }
+
+        for _, op := range req.Operations {
+                if !h.guardBatchOperation(c, op) {
+                        return
+                }
+        }
+        if !h.guardRegisteredKeys(c, keys...) {
+                return
+        }
+
+        results, err := h.repo.ApplyBatch(c.Request.Context(), req.Operations)
+        if err != nil {
+                respondError(c, err, "Failed to apply batch")
+                return
+        }
+
+        c.JSON(http.StatusOK, models.BatchResponse{Results: results})
+}
+
+// validateBatchOperation checks the operation at index of a batch with the
+// rules of the node and property endpoints, and that its negative node IDs
+// refer to nodes created by earlier operations
+func validateBatchOperation(ops []models.BatchOperation, index int) error {
+        op := ops[index]
+        reference := func(id *int64) error {
+                if id == nil || *id >= 0 {
+                        return nil
+                }
+                if created := int(-*id) - 1; created >= index || ops[created].Op != models.BatchOpCreateNode {
+                        return invalidRequest(problem.CodeInvalidID, fmt.Sprintf("node %d does not refer to a node created by an earlier operation", *id))
+                }
+                return nil
+        }
+        required := func(present bool, field string) error {
+                if !present {
+                        return invalidRequest(problem.CodeInvalidRequest, fmt.Sprintf("%s is required for %s", field, op.Op))
+                }
+                return nil
+        }
+
+        switch op.Op {
+        case models.BatchOpCreateNode:
+                if err := required(op.CreateNode != nil, "create_node"); err != nil {
+                        return err
+                }
+                if err := reference(op.CreateNode.ParentID); err != nil {
+                        return err
+                }
+                return validateCreateNode(*op.CreateNode)
+
+        case models.BatchOpUpdateNode:
+                if err := required(op.NodeID != nil, "node_id"); err != nil {
+                        return err
+                }
+                if err := required(op.UpdateNode != nil, "update_node"); err != nil {
+                        return err
+                }
+                if err := reference(op.NodeID); err != nil {
+                        return err
+                }
+                return validateUpdateNode(*op.UpdateNode)
+
+        case models.BatchOpDeleteNode:
+                if err := required(op.NodeID != nil, "node_id"); err != nil {
+                        return err
+                }
+                return reference(op.NodeID)
+
+        case models.BatchOpCreateProperty:
+                if err := required(op.NodeID != nil, "node_id"); err != nil {
+                        return err
+                }
+                if err := required(op.CreateProperty != nil, "create_property"); err != nil {
+                        return err
+                }
+                if err := reference(op.NodeID); err != nil {
+                        return err
+                }
+                return validateCreateProperty(op.CreateProperty)
+
+        case models.BatchOpUpdateProperty:
+                if err := required(op.PropertyID != nil, "property_id"); err != nil {
+                        return err
+                }
+                if err := required(op.UpdateProperty != nil, "update_property"); err != nil {
+                        return err
+                }
+                return validateUpdateProperty(*op.UpdateProperty)
+
+        case models.BatchOpDeleteProperty:
+                return required(op.PropertyID != nil, "property_id")
+        }
+        return invalidRequest(problem.CodeInvalidRequest, "op must be one of create_node, update_node, delete_node, create_property, update_property or delete_property")
+}
+
+// guardBatchOperation applies the access, protection and delete checks of the
+// node and property endpoints to an operation of a batch. Nodes created by
+// the batch are covered by the checks of the operation creating them.
+func (h *Handler) guardBatchOperation(c *gin.Context, op models.BatchOperation) bool {
+        existing := func(id *int64) bool {
+                return id != nil && *id >= 0
+        }
+
+        switch op.Op {
+        case models.BatchOpCreateNode:
+                parentID := op.CreateNode.ParentID
+                if parentID == nil {
+                        return h.authorizeAdmin(c)
+                }
+                return !existing(parentID) || h.authorize(c, *parentID, models.PermissionWrite) && h.guardProtected(c, *parentID, false)
+
+        case models.BatchOpUpdateNode, models.BatchOpCreateProperty:
+                return !existing(op.NodeID) || h.authorize(c, *op.NodeID, models.PermissionWrite) && h.guardProtected(c, *op.NodeID, false)
+
+        case models.BatchOpDeleteNode:
+                return !existing(op.NodeID) || h.authorize(c, *op.NodeID, models.PermissionWrite) && h.guardProtected(c, *op.NodeID, true) && h.guardDelete(c, *op.NodeID)
+
+        case models.BatchOpUpdateProperty:
+                return h.authorizeProperty(c, *op.PropertyID, models.PermissionWrite) && h.guardPropertyProtected(c, *op.PropertyID)
+
+        case models.BatchOpDeleteProperty:
+                if !h.authorizeProperty(c, *op.PropertyID, models.PermissionWrite) || !h.guardPropertyProtected(c, *op.PropertyID) {
+                        return false
+                }
+                nodeID, err := h.repo.GetPropertyNodeID(c.Request.Context(), *op.PropertyID)
+                if err != nil {
+                        respondError(c, err, "Failed to check configuration usage")
+                        return false
+                }
+                return nodeID == nil || h.guardDelete(c, *nodeID)
+        }
+        return true
+}
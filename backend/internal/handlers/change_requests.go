@@ -0,0 +1,372 @@
+package handlers
+
+import (
+        "config-manager/internal/auth"
+        "config-manager/internal/database"
+        "config-manager/internal/models"
+        "config-manager/internal/problem"
+        "errors"
+        "net/http"
+        "strconv"
+
+        "github.com/gin-gonic/gin"
+)
+
+// guardProtected writes an error response and returns false if nodeID lies in
+// a protected subtree, which may only be modified by applying an approved change
+// request. includeDescendants also protects nodeID when a subtree below it is
+// protected, for operations such as deletes that affect the whole subtree.
+func (h *Handler) guardProtected(c *gin.Context, nodeID int64, includeDescendants bool) bool {
+        required, err := h.repo.RequiredApprovals(c.Request.Context(), nodeID, includeDescendants)
+        if err != nil {
+                respondError(c, err, "Failed to check subtree protection")
+                return false
+        }
+        if required > 0 {
+                problem.Respond(c, http.StatusForbidden, problem.CodeSubtreeProtected, "Subtree is protected; changes require an approved change request", gin.H{
+                        "required_approvals": required,
+                })
+                return false
+        }
+        return true
+}
+
+// guardPropertyProtected applies guardProtected to the node owning a property
+func (h *Handler) guardPropertyProtected(c *gin.Context, propertyID int64) bool {
+        nodeID, err := h.repo.GetPropertyNodeID(c.Request.Context(), propertyID)
+        if err != nil {
+                respondError(c, err, "Failed to check subtree protection")
+                return false
+        }
+        if nodeID == nil {
+                problem.Respond(c, http.StatusNotFound, problem.CodePropertyNotFound, "Property not found")
+                return false
+        }
+        return h.guardProtected(c, *nodeID, false)
+}
+
+// guardUnderReview writes an error response and returns false if the workspace
+// has an open or approved change request, which freezes its edits
+func (h *Handler) guardUnderReview(c *gin.Context, workspaceID int64) bool {
+        cr, err := h.repo.GetActiveChangeRequest(c.Request.Context(), workspaceID)
+        if err != nil {
+                respondError(c, err, "Failed to check change requests")
+                return false
+        }
+        if cr != nil {
+                problem.Respond(c, http.StatusConflict, problem.CodeUnderReview, "Workspace is under review; withdraw its change request first", gin.H{
+                        "change_request_id": cr.ID,
+                })
+                return false
+        }
+        return true
+}
+
+// Subtree protection handlers
+func (h *Handler) GetNodeProtections(c *gin.Context) {
+        if !h.authorizeAdmin(c) {
+                return
+        }
+
+        protections, err := h.repo.GetNodeProtections(c.Request.Context())
+        if err != nil {
+                respondError(c, err, "Failed to get protected subtrees")
+                return
+        }
+
+        c.JSON(http.StatusOK, protections)
+}
+
+func (h *Handler) SetNodeProtection(c *gin.Context) {
+        nodeID, err := strconv.ParseInt(c.Param("nodeId"), 10, 64)
+        if err != nil {
+                problem.Respond(c, http.StatusBadRequest, problem.CodeInvalidID, "Invalid node ID")
+                return
+        }
+
+        if !h.authorizeAdmin(c) {
+                return
+        }
+
+        var req models.SetNodeProtectionRequest
+        if err := c.ShouldBindJSON(&req); err != nil {
+                problem.Respond(c, http.StatusBadRequest, problem.CodeInvalidRequest, err.Error())
+                return
+        }
+        if req.RequiredApprovals == 0 {
+                req.RequiredApprovals = 1
+        }
+
+        node, err := h.repo.GetNodeByID(c.Request.Context(), nodeID)
+        if err != nil {
+                respondError(c, err, "Failed to validate node")
+                return
+        }
+        if node == nil {
+                problem.Respond(c, http.StatusNotFound, problem.CodeNodeNotFound, "Node not found")
+                return
+        }
+
+        protection, err := h.repo.SetNodeProtection(c.Request.Context(), nodeID, req.RequiredApprovals)
+        if err != nil {
+                respondError(c, err, "Failed to protect subtree")
+                return
+        }
+
+        c.JSON(http.StatusOK, protection)
+}
+
+func (h *Handler) RemoveNodeProtection(c *gin.Context) {
+        nodeID, err := strconv.ParseInt(c.Param("nodeId"), 10, 64)
+        if err != nil {
+                problem.Respond(c, http.StatusBadRequest, problem.CodeInvalidID, "Invalid node ID")
+                return
+        }
+
+        if !h.authorizeAdmin(c) {
+                return
+        }
+
+        err = h.repo.RemoveNodeProtection(c.Request.Context(), nodeID)
+        switch {
+        case errors.Is(err, database.ErrProtectionNotFound):
+                problem.Respond(c, http.StatusNotFound, problem.CodeNotFound, err.Error())
+        case err != nil:
+                respondError(c, err, "Failed to remove subtree protection")
+        default:
+                c.JSON(http.StatusNoContent, nil)
+        }
+}
+
+// Change request handlers
+func (h *Handler) CreateChangeRequest(c *gin.Context) {
+        var req models.CreateChangeRequestRequest
+        if err := c.ShouldBindJSON(&req); err != nil {
+                problem.Respond(c, http.StatusBadRequest, problem.CodeInvalidRequest, err.Error())
+                return
+        }
+
+        author := auth.Identity(c)
+        if author == "" {
+                problem.Respond(c, http.StatusUnauthorized, problem.CodeUnauthenticated, "Authentication required to submit change requests")
+                return
+        }
+
+        workspace, err := h.repo.GetWorkspace(c.Request.Context(), req.WorkspaceID)
+        if err != nil {
+                respondError(c, err, "Failed to get workspace")
+                return
+        }
+        if workspace == nil {
+                problem.Respond(c, http.StatusNotFound, problem.CodeWorkspaceNotFound, "Workspace not found")
+                return
+        }
+
+        if !h.authorize(c, workspace.RootNodeID, models.PermissionWrite) {
+                return
+        }
+
+        // Merging may delete protected nodes below the root, so count those too
+        required, err := h.repo.RequiredApprovals(c.Request.Context(), workspace.RootNodeID, true)
+        if err != nil {
+                respondError(c, err, "Failed to check subtree protection")
+                return
+        }
+        if required == 0 {
+                required = 1
+        }
+
+        cr, err := h.repo.CreateChangeRequest(c.Request.Context(), req, author, required)
+        if err != nil {
+                writeChangeRequestError(c, err, "Failed to create change request")
+                return
+        }
+
+        c.JSON(http.StatusCreated, cr)
+}
+
+func (h *Handler) GetChangeRequests(c *gin.Context) {
+        status := models.ChangeRequestStatus(c.Query("status"))
+
+        changeRequests, err := h.repo.GetChangeRequests(c.Request.Context(), status)
+        if err != nil {
+                respondError(c, err, "Failed to get change requests")
+                return
+        }
+
+        if !h.acl.IsAdmin(c) {
+                readable := []models.ChangeRequest{}
+                for _, cr := range changeRequests {
+                        allowed, err := h.changeRequestAllowed(c, cr.WorkspaceID, models.PermissionRead)
+                        if err != nil {
+                                respondError(c, err, "Failed to check permissions")
+                                return
+                        }
+                        if allowed {
+                                readable = append(readable, cr)
+                        }
+                }
+                changeRequests = readable
+        }
+
+        c.JSON(http.StatusOK, changeRequests)
+}
+
+func (h *Handler) GetChangeRequest(c *gin.Context) {
+        id, ok := changeRequestIDParam(c)
+        if !ok {
+                return
+        }
+
+        cr, err := h.repo.GetChangeRequest(c.Request.Context(), id)
+        if err != nil {
+                respondError(c, err, "Failed to get change request")
+                return
+        }
+        if cr == nil {
+                problem.Respond(c, http.StatusNotFound, problem.CodeChangeRequestNotFound, "Change request not found")
+                return
+        }
+
+        if !h.authorizeWorkspace(c, cr.WorkspaceID, models.PermissionRead) {
+                return
+        }
+
+        c.JSON(http.StatusOK, cr)
+}
+
+func (h *Handler) ReviewChangeRequest(c *gin.Context) {
+        id, ok := changeRequestIDParam(c)
+        if !ok {
+                return
+        }
+
+        var req models.ReviewChangeRequestRequest
+        if err := c.ShouldBindJSON(&req); err != nil {
+                problem.Respond(c, http.StatusBadRequest, problem.CodeInvalidRequest, err.Error())
+                return
+        }
+        if req.Decision != models.ReviewDecisionApprove && req.Decision != models.ReviewDecisionReject {
+                problem.Respond(c, http.StatusBadRequest, problem.CodeInvalidRequest, "decision must be 'approve' or 'reject'")
+                return
+        }
+
+        reviewer := auth.Identity(c)
+        if reviewer == "" {
+                problem.Respond(c, http.StatusUnauthorized, problem.CodeUnauthenticated, "Authentication required to review change requests")
+                return
+        }
+
+        if !h.authorizeChangeRequest(c, id, models.PermissionWrite) {
+                return
+        }
+
+        cr, err := h.repo.ReviewChangeRequest(c.Request.Context(), id, reviewer, req)
+        if err != nil {
+                writeChangeRequestError(c, err, "Failed to review change request")
+                return
+        }
+
+        c.JSON(http.StatusOK, cr)
+}
+
+func (h *Handler) ApplyChangeRequest(c *gin.Context) {
+        id, ok := changeRequestIDParam(c)
+        if !ok {
+                return
+        }
+
+        if !h.authorizeChangeRequest(c, id, models.PermissionWrite) {
+                return
+        }
+
+        result, err := h.repo.ApplyChangeRequest(c.Request.Context(), id)
+        if err != nil {
+                writeChangeRequestError(c, err, "Failed to apply change request")
+                return
+        }
+
+        c.JSON(http.StatusOK, result)
+}
+
+func (h *Handler) WithdrawChangeRequest(c *gin.Context) {
+        id, ok := changeRequestIDParam(c)
+        if !ok {
+                return
+        }
+
+        cr, err := h.repo.GetChangeRequest(c.Request.Context(), id)
+        if err != nil {
+                respondError(c, err, "Failed to get change request")
+                return
+        }
+        if cr == nil {
+                problem.Respond(c, http.StatusNotFound, problem.CodeChangeRequestNotFound, "Change request not found")
+                return
+        }
+
+        // Only the author or an admin may withdraw a request
+        if cr.Author != auth.Identity(c) && !h.authorizeAdmin(c) {
+                return
+        }
+
+        if err := h.repo.WithdrawChangeRequest(c.Request.Context(), id); err != nil {
+                writeChangeRequestError(c, err, "Failed to withdraw change request")
+                return
+        }
+
+        c.JSON(http.StatusNoContent, nil)
+}
+
+// authorizeChangeRequest authorizes an operation on the subtree a change request modifies
+func (h *Handler) authorizeChangeRequest(c *gin.Context, id int64, perm models.Permission) bool {
+        if !h.acl.Enforced() {
+                return true
+        }
+
+        cr, err := h.repo.GetChangeRequest(c.Request.Context(), id)
+        if err != nil {
+                respondError(c, err, "Failed to check permissions")
+                return false
+        }
+        if cr == nil {
+                problem.Respond(c, http.StatusNotFound, problem.CodeChangeRequestNotFound, "Change request not found")
+                return false
+        }
+        return h.authorizeWorkspace(c, cr.WorkspaceID, perm)
+}
+
+// changeRequestAllowed reports whether the request may perform an operation
+// needing perm on the subtree of a workspace
+func (h *Handler) changeRequestAllowed(c *gin.Context, workspaceID int64, perm models.Permission) (bool, error) {
+        workspace, err := h.repo.GetWorkspace(c.Request.Context(), workspaceID)
+        if err != nil || workspace == nil {
+                return false, err
+        }
+        return h.acl.Allowed(c, workspace.RootNodeID, perm)
+}
+
+func changeRequestIDParam(c *gin.Context) (int64, bool) {
+        id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+        if err != nil {
+                problem.Respond(c, http.StatusBadRequest, problem.CodeInvalidID, "Invalid change request ID")
+                return 0, false
+        }
+        return id, true
+}
+
+// writeChangeRequestError maps change request repository errors to responses
+func writeChangeRequestError(c *gin.Context, err error, fallback string) {
+        switch {
+        case errors.Is(err, database.ErrChangeRequestNotFound):
+                problem.Respond(c, http.StatusNotFound, problem.CodeChangeRequestNotFound, "Change request not found")
+        case errors.Is(err, database.ErrSelfReview):
+                problem.Respond(c, http.StatusForbidden, problem.CodePermissionDenied, err.Error())
+        case errors.Is(err, database.ErrChangeRequestNotOpen),
+                errors.Is(err, database.ErrChangeRequestNotApproved),
+                errors.Is(err, database.ErrChangeRequestExists):
+                problem.Respond(c, http.StatusConflict, problem.CodeInvalidState, err.Error())
+        default:
+                writeWorkspaceError(c, err, fallback)
+        }
+}
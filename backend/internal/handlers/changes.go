@@ -0,0 +1,73 @@
+package handlers
+
+import (
+        "config-manager/internal/models"
+        "config-manager/internal/problem"
+        "net/http"
+        "strconv"
+
+        "github.com/gin-gonic/gin"
+)
+
+const (
+        defaultChangesLimit = 100
+        maxChangesLimit     = 1000
+)
+
+// GetChanges returns the change feed: every change event in the order it was
+// committed, after the ?since= cursor. Integrators resume from the
+// next_cursor of the last page they processed.
+func (h *Handler) GetChanges(c *gin.Context) {
+        if !h.authorizeAdmin(c) {
+                return
+        }
+
+        var since int64
+        if sinceStr := c.Query("since"); sinceStr != "" {
+                var err error
+                since, err = strconv.ParseInt(sinceStr, 10, 64)
+                if err != nil || since < 0 {
+                        problem.Respond(c, http.StatusBadRequest, problem.CodeInvalidRequest, "Invalid cursor")
+                        return
+                }
+        }
+
+        limit := defaultChangesLimit
+        if limitStr := c.Query("limit"); limitStr != "" {
+                var err error
+                limit, err = strconv.Atoi(limitStr)
+                if err != nil || limit < 1 || limit > maxChangesLimit {
+                        problem.Respond(c, http.StatusBadRequest, problem.CodeInvalidRequest, "limit must be between 1 and 1000")
+                        return
+                }
+        }
+
+        // Changes older than the outbox retention are gone, the caller has to resync
+        if since > 0 {
+                oldest, err := h.repo.OldestChangeCursor(c.Request.Context())
+                if err != nil {
+                        respondError(c, err, "Failed to get changes")
+                        return
+                }
+                if oldest != nil && since < *oldest-1 {
+                        problem.Respond(c, http.StatusGone, problem.CodeCursorExpired, "Cursor has expired, changes up to " + strconv.FormatInt(*oldest-1, 10) + " were purged")
+                        return
+                }
+        }
+
+        changes, err := h.repo.GetChanges(c.Request.Context(), since, limit+1)
+        if err != nil {
+                respondError(c, err, "Failed to get changes")
+                return
+        }
+
+        feed := models.ChangeFeed{Changes: changes, NextCursor: since}
+        if len(changes) > limit {
+                feed.Changes = changes[:limit]
+                feed.HasMore = true
+        }
+        if len(feed.Changes) > 0 {
+                feed.NextCursor = feed.Changes[len(feed.Changes)-1].Cursor
+        }
+        c.JSON(http.StatusOK, feed)
+}
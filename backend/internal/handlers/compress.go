@@ -0,0 +1,203 @@
+package handlers
+
+import (
+        "io"
+        "mime"
+        "net/http"
+        "strconv"
+        "strings"
+        "sync"
+
+        "github.com/gin-gonic/gin"
+        "github.com/klauspost/compress/gzip"
+        "github.com/klauspost/compress/zstd"
+)
+
+// encoder compresses the body of a response
+type encoder interface {
+        io.Writer
+        Reset(w io.Writer)
+        Flush() error
+        Close() error
+}
+
+// Encoders are reused across responses, zstd ones in particular allocate
+// their window up front
+var encoders = map[string]*sync.Pool{
+        "zstd": {New: func() interface{} {
+                // Browsers only decode zstd responses with windows of up to 8 MB
+                enc, _ := zstd.NewWriter(nil, zstd.WithWindowSize(8<<20), zstd.WithEncoderConcurrency(1))
+                return enc
+        }},
+        "gzip": {New: func() interface{} {
+                return gzip.NewWriter(nil)
+        }},
+}
+
+// Compress compresses the responses of at least minSize bytes with zstd or
+// gzip, whichever the client prefers in Accept-Encoding, zstd on a tie. Only
+// text, JSON, YAML, XML and protocol buffer bodies are compressed. Smaller
+// responses are sent as they are, as compressing them saves less than it costs.
+func Compress(minSize int) gin.HandlerFunc {
+        return func(c *gin.Context) {
+                // Upgraded connections and bodiless responses have nothing to compress
+                if c.Request.Method == http.MethodHead || c.GetHeader("Upgrade") != "" {
+                        c.Next()
+                        return
+                }
+
+                c.Writer.Header().Add("Vary", "Accept-Encoding")
+                encoding := negotiateEncoding(c.GetHeader("Accept-Encoding"))
+                if encoding == "" {
+                        c.Next()
+                        return
+                }
+
+                writer := &compressWriter{ResponseWriter: c.Writer, encoding: encoding, minSize: minSize}
+                c.Writer = writer
+                defer func() { c.Writer = writer.ResponseWriter }()
+                c.Next()
+                writer.finish()
+        }
+}
+
+// negotiateEncoding picks zstd or gzip from an Accept-Encoding header, or ""
+// when the client accepts neither
+func negotiateEncoding(header string) string {
+        quality := make(map[string]float64)
+        for _, part := range strings.Split(header, ",") {
+                name, params, _ := strings.Cut(part, ";")
+                q := 1.0
+                if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+                        if parsed, err := strconv.ParseFloat(value, 64); err == nil {
+                                q = parsed
+                        }
+                }
+                quality[strings.ToLower(strings.TrimSpace(name))] = q
+        }
+
+        best, bestQuality := "", 0.0
+        for _, encoding := range []string{"zstd", "gzip"} {
+                q, ok := quality[encoding]
+                if !ok {
+                        q = quality["*"]
+                }
+                if q > bestQuality {
+                        best, bestQuality = encoding, q
+                }
+        }
+        return best
+}
+
+// compressible reports whether a response of contentType is worth compressing
+func compressible(contentType string) bool {
+        mediaType, _, err := mime.ParseMediaType(contentType)
+        if err != nil {
+                return false
+        }
+        switch {
+        case mediaType == "text/event-stream":
+                // Events have to reach watchers as they happen
+                return false
+        case strings.HasPrefix(mediaType, "text/"),
+                strings.HasSuffix(mediaType, "+json"), strings.HasSuffix(mediaType, "+xml"):
+                return true
+        }
+        switch mediaType {
+        case "application/json", "application/x-ndjson", "application/yaml", "application/x-yaml", "application/xml", "application/javascript",
+                "application/x-protobuf":
+                return true
+        }
+        return false
+}
+
+// compressWriter holds back the start of a response body until it reaches
+// minSize bytes, then compresses it and the rest as it is written. Bodies that
+// stay smaller are written as they are once the handler is done.
+type compressWriter struct {
+        gin.ResponseWriter
+        encoding string
+        minSize  int
+        buffered []byte
+        started  bool
+        encoder  encoder
+}
+
+func (w *compressWriter) Write(data []byte) (int, error) {
+        if w.started {
+                if w.encoder != nil {
+                        return w.encoder.Write(data)
+                }
+                return w.ResponseWriter.Write(data)
+        }
+
+        w.buffered = append(w.buffered, data...)
+        if len(w.buffered) < w.minSize {
+                return len(data), nil
+        }
+        if err := w.start(true); err != nil {
+                return 0, err
+        }
+        return len(data), nil
+}
+
+func (w *compressWriter) WriteString(s string) (int, error) {
+        return w.Write([]byte(s))
+}
+
+// start sends the headers and the buffered body, compressing the response if
+// compress is set and the response is eligible
+func (w *compressWriter) start(compress bool) error {
+        w.started = true
+        header := w.Header()
+        status := w.Status()
+        if compress && !w.Written() && header.Get("Content-Encoding") == "" && compressible(header.Get("Content-Type")) &&
+                status >= http.StatusOK && status != http.StatusNoContent && status != http.StatusNotModified {
+                header.Set("Content-Encoding", w.encoding)
+                header.Del("Content-Length")
+                w.encoder = encoders[w.encoding].Get().(encoder)
+                w.encoder.Reset(w.ResponseWriter)
+        }
+
+        buffered := w.buffered
+        w.buffered = nil
+        if len(buffered) == 0 {
+                return nil
+        }
+        if w.encoder != nil {
+                _, err := w.encoder.Write(buffered)
+                return err
+        }
+        _, err := w.ResponseWriter.Write(buffered)
+        return err
+}
+
+// Flush sends what was written so far, compressed if the body is large enough
+func (w *compressWriter) Flush() {
+        if !w.started {
+                w.start(len(w.buffered) >= w.minSize)
+        }
+        if w.encoder != nil {
+                w.encoder.Flush()
+        }
+        w.ResponseWriter.Flush()
+}
+
+// Unwrap returns the wrapped writer, so that http.ResponseController reaches
+// the connection, e.g. to extend the write deadline of long polls
+func (w *compressWriter) Unwrap() http.ResponseWriter {
+        return w.ResponseWriter
+}
+
+// finish writes out a body that stayed small, or ends the compressed one
+func (w *compressWriter) finish() {
+        if !w.started {
+                w.start(false)
+                return
+        }
+        if w.encoder != nil {
+                w.encoder.Close()
+                encoders[w.encoding].Put(w.encoder)
+                w.encoder = nil
+        }
+}
@@ -0,0 +1,169 @@
+package handlers
+
+import (
+        "config-manager/internal/auth"
+        "config-manager/internal/models"
+        "config-manager/internal/problem"
+        "context"
+        "fmt"
+        "log"
+        "net/http"
+        "sort"
+        "time"
+
+        "github.com/gin-gonic/gin"
+)
+
+// Key deprecation handlers
+func (h *Handler) DeprecateKey(c *gin.Context) {
+        if !h.authorizeAdmin(c) {
+                return
+        }
+
+        var req models.DeprecateKeyRequest
+        if err := c.ShouldBindJSON(&req); err != nil {
+                problem.Respond(c, http.StatusBadRequest, problem.CodeInvalidRequest, err.Error())
+                return
+        }
+        if req.ReplacementKey != nil && (*req.ReplacementKey == "" || *req.ReplacementKey == req.Key) {
+                problem.Respond(c, http.StatusBadRequest, problem.CodeInvalidRequest, "replacement_key must name a different key")
+                return
+        }
+
+        deprecation, err := h.repo.DeprecateKey(c.Request.Context(), req, auth.Identity(c))
+        if err != nil {
+                respondError(c, err, "Failed to deprecate key")
+                return
+        }
+
+        c.JSON(http.StatusCreated, deprecation)
+}
+
+func (h *Handler) GetKeyDeprecations(c *gin.Context) {
+        deprecations, err := h.repo.GetKeyDeprecations(c.Request.Context(), nil)
+        if err != nil {
+                respondError(c, err, "Failed to get deprecated keys")
+                return
+        }
+
+        c.JSON(http.StatusOK, deprecations)
+}
+
+// UndeprecateKey removes the deprecation of the key given as ?key=
+func (h *Handler) UndeprecateKey(c *gin.Context) {
+        if !h.authorizeAdmin(c) {
+                return
+        }
+
+        key := c.Query("key")
+        if key == "" {
+                problem.Respond(c, http.StatusBadRequest, problem.CodeInvalidRequest, "key is required")
+                return
+        }
+
+        removed, err := h.repo.UndeprecateKey(c.Request.Context(), key)
+        if err != nil {
+                respondError(c, err, "Failed to remove deprecation")
+                return
+        }
+        if !removed {
+                problem.Respond(c, http.StatusNotFound, problem.CodeKeyNotFound, "Key is not deprecated")
+                return
+        }
+
+        c.JSON(http.StatusNoContent, nil)
+}
+
+// GetDeprecationReport lists the deprecated keys that nodes still define,
+// with every definition, across the tree or within the subtree given as
+// ?nodeId=
+func (h *Handler) GetDeprecationReport(c *gin.Context) {
+        rootID, ok := h.searchRoot(c)
+        if !ok {
+                return
+        }
+
+        deprecations, err := h.repo.GetKeyDeprecations(c.Request.Context(), nil)
+        if err != nil {
+                respondError(c, err, "Failed to get deprecated keys")
+                return
+        }
+        definitions, err := h.repo.GetDeprecatedDefinitions(c.Request.Context(), rootID)
+        if err != nil {
+                respondError(c, err, "Failed to get deprecated key definitions")
+                return
+        }
+        if rootID == nil {
+                if definitions, err = h.filterReadableResults(c, definitions); err != nil {
+                        respondError(c, err, "Failed to check permissions")
+                        return
+                }
+        }
+
+        byKey := make(map[string][]models.PropertySearchResult)
+        for _, definition := range definitions {
+                byKey[definition.Key] = append(byKey[definition.Key], definition)
+        }
+
+        now := time.Now()
+        report := models.DeprecationReport{RootNodeID: rootID, Keys: []models.DeprecatedKeyUsage{}}
+        for _, deprecation := range deprecations {
+                if len(byKey[deprecation.Key]) == 0 {
+                        continue
+                }
+                report.Keys = append(report.Keys, models.DeprecatedKeyUsage{
+                        KeyDeprecation: deprecation,
+                        PastSunset:     deprecation.SunsetAt != nil && !deprecation.SunsetAt.After(now),
+                        Definitions:    byKey[deprecation.Key],
+                })
+        }
+
+        c.JSON(http.StatusOK, report)
+}
+
+// deprecationWarnings warns about the deprecated keys of a resolved
+// configuration. Warnings are best effort: lookup failures are logged.
+func (h *Handler) deprecationWarnings(ctx context.Context, resolved *models.ResolvedConfiguration) []models.ResolveWarning {
+        keys := make([]string, 0, len(resolved.Properties))
+        for key := range resolved.Properties {
+                keys = append(keys, key)
+        }
+        sort.Strings(keys)
+
+        deprecations, err := h.repo.GetKeyDeprecations(ctx, keys)
+        if err != nil {
+                log.Printf("Failed to check deprecated keys for node %d: %v", resolved.NodeID, err)
+                return nil
+        }
+
+        var warnings []models.ResolveWarning
+        for _, deprecation := range deprecations {
+                warnings = append(warnings, models.ResolveWarning{
+                        Code:           "deprecated_key",
+                        Key:            deprecation.Key,
+                        Message:        deprecationMessage(deprecation),
+                        ReplacementKey: deprecation.ReplacementKey,
+                        SunsetAt:       deprecation.SunsetAt,
+                })
+        }
+        return warnings
+}
+
+// deprecationMessage describes a deprecated key, its replacement and sunset
+func deprecationMessage(deprecation models.KeyDeprecation) string {
+        message := fmt.Sprintf("%s is deprecated", deprecation.Key)
+        if deprecation.ReplacementKey != nil {
+                message += fmt.Sprintf(", use %s instead", *deprecation.ReplacementKey)
+        }
+        if deprecation.SunsetAt != nil {
+                verb := "is sunset on"
+                if !deprecation.SunsetAt.After(time.Now()) {
+                        verb = "was sunset on"
+                }
+                message += fmt.Sprintf("; it %s %s", verb, deprecation.SunsetAt.Format("2006-01-02"))
+        }
+        if deprecation.Reason != "" {
+                message += " (" + deprecation.Reason + ")"
+        }
+        return message
+}
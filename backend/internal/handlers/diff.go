@@ -0,0 +1,94 @@
+package handlers
+
+import (
+        "config-manager/internal/models"
+        "config-manager/internal/problem"
+        "net/http"
+        "reflect"
+        "sort"
+        "strconv"
+
+        "github.com/gin-gonic/gin"
+)
+
+// DiffConfigurations compares the resolved configurations of the nodes given as
+// ?left= and ?right=, optionally for one ?env= and targeting ?context[attr]=
+func (h *Handler) DiffConfigurations(c *gin.Context) {
+        leftID, err := strconv.ParseInt(c.Query("left"), 10, 64)
+        if err != nil {
+                problem.Respond(c, http.StatusBadRequest, problem.CodeInvalidID, "Invalid left node ID")
+                return
+        }
+        rightID, err := strconv.ParseInt(c.Query("right"), 10, 64)
+        if err != nil {
+                problem.Respond(c, http.StatusBadRequest, problem.CodeInvalidID, "Invalid right node ID")
+                return
+        }
+
+        if !h.authorize(c, leftID, models.PermissionRead) || !h.authorize(c, rightID, models.PermissionRead) {
+                return
+        }
+
+        environment, context, ok := resolveQuery(c)
+        if !ok {
+                return
+        }
+        opts := models.ResolveOptions{Environment: environment, Context: context}
+
+        var resolved [2]*models.ResolvedConfiguration
+        for i, nodeID := range []int64{leftID, rightID} {
+                node, err := h.repo.GetNodeByID(c.Request.Context(), nodeID)
+                if err != nil {
+                        respondError(c, err, "Failed to get node")
+                        return
+                }
+                if node == nil {
+                        problem.Respond(c, http.StatusNotFound, problem.CodeNodeNotFound, "Node " + strconv.FormatInt(nodeID, 10) + " not found")
+                        return
+                }
+
+                resolved[i], err = h.repo.ResolveConfiguration(c.Request.Context(), nodeID, opts)
+                if err != nil {
+                        respondError(c, err, "Failed to resolve configuration")
+                        return
+                }
+        }
+
+        c.JSON(http.StatusOK, models.ConfigurationDiff{
+                Left:            resolved[0].Path[len(resolved[0].Path)-1],
+                Right:           resolved[1].Path[len(resolved[1].Path)-1],
+                Environment:     environment,
+                PropertyChanges: diffProperties(resolved[0].Properties, resolved[1].Properties),
+        })
+}
+
+// diffProperties compares two resolved property maps, listing keys in order
+func diffProperties(left, right map[string]interface{}) models.PropertyChanges {
+        diff := models.PropertyChanges{
+                Added:   []models.PropertyDiff{},
+                Removed: []models.PropertyDiff{},
+                Changed: []models.PropertyDiff{},
+        }
+
+        for key, leftValue := range left {
+                rightValue, ok := right[key]
+                switch {
+                case !ok:
+                        diff.Removed = append(diff.Removed, models.PropertyDiff{Key: key, Left: leftValue})
+                case !reflect.DeepEqual(leftValue, rightValue):
+                        diff.Changed = append(diff.Changed, models.PropertyDiff{Key: key, Left: leftValue, Right: rightValue})
+                default:
+                        diff.Unchanged++
+                }
+        }
+        for key, rightValue := range right {
+                if _, ok := left[key]; !ok {
+                        diff.Added = append(diff.Added, models.PropertyDiff{Key: key, Right: rightValue})
+                }
+        }
+
+        for _, entries := range [][]models.PropertyDiff{diff.Added, diff.Removed, diff.Changed} {
+                sort.Slice(entries, func(i, j int) bool { return entries[i].Key < entries[j].Key })
+        }
+        return diff
+}
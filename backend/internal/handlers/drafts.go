@@ -0,0 +1,154 @@
+package handlers
+
+import (
+        "config-manager/internal/database"
+        "config-manager/internal/flags"
+        "config-manager/internal/models"
+        "config-manager/internal/problem"
+        "encoding/json"
+        "errors"
+        "net/http"
+        "strconv"
+
+        "github.com/gin-gonic/gin"
+)
+
+// draftNodeParam parses the node ID and authorizes the request, writing an
+// error response and returning false on failure
+func (h *Handler) draftNodeParam(c *gin.Context, perm models.Permission) (int64, bool) {
+        nodeID, err := strconv.ParseInt(c.Param("nodeId"), 10, 64)
+        if err != nil {
+                problem.Respond(c, http.StatusBadRequest, problem.CodeInvalidID, "Invalid node ID")
+                return 0, false
+        }
+        if !h.authorize(c, nodeID, perm) {
+                return 0, false
+        }
+        return nodeID, true
+}
+
+// validateDraft checks a staged property edit
+func validateDraft(req models.SavePropertyDraftRequest) error {
+        if !req.Delete {
+                if req.Value == nil || req.DataType == nil {
+                        return errors.New("value and data_type are required unless delete is set")
+                }
+                if err := checkValueSize(*req.Value); err != nil {
+                        return err
+                }
+                var jsonValue interface{}
+                if err := json.Unmarshal([]byte(*req.Value), &jsonValue); err != nil {
+                        return errors.New("Value must be valid JSON")
+                }
+                if !validDataTypes[*req.DataType] {
+                        return errors.New("Invalid data type")
+                }
+                if *req.DataType == models.DataTypeFlag {
+                        if _, err := flags.Parse(*req.Value); err != nil {
+                                return err
+                        }
+                }
+        }
+        if req.Environment != nil && !environmentPattern.MatchString(*req.Environment) {
+                return errors.New("Invalid environment")
+        }
+        return nil
+}
+
+// Draft handlers
+func (h *Handler) SavePropertyDraft(c *gin.Context) {
+        nodeID, ok := h.draftNodeParam(c, models.PermissionWrite)
+        if !ok {
+                return
+        }
+
+        var req models.SavePropertyDraftRequest
+        if err := c.ShouldBindJSON(&req); err != nil {
+                problem.Respond(c, http.StatusBadRequest, problem.CodeInvalidRequest, err.Error())
+                return
+        }
+
+        if err := validateDraft(req); err != nil {
+                respondInvalid(c, err)
+                return
+        }
+
+        node, err := h.repo.GetNodeByID(c.Request.Context(), nodeID)
+        if err != nil {
+                respondError(c, err, "Failed to validate node")
+                return
+        }
+        if node == nil {
+                problem.Respond(c, http.StatusNotFound, problem.CodeNodeNotFound, "Node not found")
+                return
+        }
+
+        if !req.Delete && (!h.guardFinal(c, nodeID, req.Key) || !h.guardRegisteredKeys(c, req.Key)) {
+                return
+        }
+
+        draft, err := h.repo.SavePropertyDraft(c.Request.Context(), nodeID, req)
+        if errors.Is(err, database.ErrDraftEncrypted) {
+                problem.Respond(c, http.StatusBadRequest, problem.CodeInvalidRequest, err.Error())
+                return
+        }
+        if err != nil {
+                respondError(c, err, "Failed to save draft")
+                return
+        }
+
+        c.JSON(http.StatusOK, draft)
+}
+
+func (h *Handler) GetPropertyDrafts(c *gin.Context) {
+        nodeID, ok := h.draftNodeParam(c, models.PermissionRead)
+        if !ok {
+                return
+        }
+
+        drafts, err := h.repo.GetPropertyDrafts(c.Request.Context(), nodeID)
+        if err != nil {
+                respondError(c, err, "Failed to get drafts")
+                return
+        }
+
+        c.JSON(http.StatusOK, drafts)
+}
+
+// DiscardPropertyDrafts drops all drafts of a node, or those of ?key= only
+func (h *Handler) DiscardPropertyDrafts(c *gin.Context) {
+        nodeID, ok := h.draftNodeParam(c, models.PermissionWrite)
+        if !ok {
+                return
+        }
+
+        if _, err := h.repo.DiscardPropertyDrafts(c.Request.Context(), nodeID, c.Query("key")); err != nil {
+                respondError(c, err, "Failed to discard drafts")
+                return
+        }
+
+        c.JSON(http.StatusNoContent, nil)
+}
+
+func (h *Handler) PublishPropertyDrafts(c *gin.Context) {
+        nodeID, ok := h.draftNodeParam(c, models.PermissionWrite)
+        if !ok {
+                return
+        }
+
+        if !h.guardProtected(c, nodeID, false) {
+                return
+        }
+
+        result, err := h.repo.PublishPropertyDrafts(c.Request.Context(), nodeID)
+        switch {
+        case errors.Is(err, database.ErrNoDrafts):
+                problem.Respond(c, http.StatusConflict, problem.CodeInvalidState, err.Error())
+        case errors.Is(err, database.ErrDraftEncrypted):
+                problem.Respond(c, http.StatusConflict, problem.CodeInvalidRequest, err.Error())
+        case err != nil:
+                respondError(c, err, "Failed to publish drafts")
+        default:
+                c.JSON(http.StatusOK, result)
+        }
+}
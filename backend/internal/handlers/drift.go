@@ -0,0 +1,26 @@
+package handlers
+
+import (
+        "config-manager/internal/models"
+        "net/http"
+
+        "github.com/gin-gonic/gin"
+)
+
+// DetectDrift compares a declarative document, in the format accepted by
+// POST /api/apply, with the live tree and lists every difference without
+// changing anything. Only read access to the subtree is needed.
+func (h *Handler) DetectDrift(c *gin.Context) {
+        req, _, ok := h.bindDocument(c, models.PermissionRead)
+        if !ok {
+                return
+        }
+
+        report, err := h.repo.DetectDrift(c.Request.Context(), req.ParentID, req.Node)
+        if err != nil {
+                writeApplyError(c, err, "Failed to detect drift")
+                return
+        }
+
+        c.JSON(http.StatusOK, report)
+}
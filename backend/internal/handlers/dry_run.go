@@ -0,0 +1,124 @@
+package handlers
+
+import (
+        "config-manager/internal/database"
+        "config-manager/internal/models"
+        "config-manager/internal/problem"
+        "net/http"
+        "reflect"
+        "time"
+
+        "github.com/gin-gonic/gin"
+)
+
+// isDryRun reports whether the request asks to validate a write without
+// persisting it
+func isDryRun(c *gin.Context) bool {
+        return c.Query("dryRun") == "true"
+}
+
+// respondDryRun reports how writing prop would change the resolution of its
+// node, without persisting it. prop has passed validation.
+func (h *Handler) respondDryRun(c *gin.Context, prop *models.ConfigProperty) {
+        if prop.Encrypted {
+                if err := h.repo.CheckEncryptionKey(c.Request.Context(), prop.NodeID); err != nil {
+                        if isEncryptionSetupError(err) {
+                                problem.Respond(c, http.StatusBadRequest, problem.CodeEncryptionUnavailable, err.Error())
+                                return
+                        }
+                        respondError(c, err, "Failed to check encryption key")
+                        return
+                }
+        }
+
+        environment := ""
+        if prop.Environment != nil {
+                environment = *prop.Environment
+        }
+        warnings := h.propertyWarnings(c.Request.Context(), prop)
+
+        var before, after interface{}
+        var hadKey, hasKey bool
+
+        current, err := h.repo.ResolveConfiguration(c.Request.Context(), prop.NodeID, models.ResolveOptions{Environment: environment})
+        if err != nil {
+                respondError(c, err, "Failed to resolve configuration")
+                return
+        }
+        before, hadKey = current.Properties[prop.Key]
+
+        preview, err := h.repo.ResolveConfiguration(c.Request.Context(), prop.NodeID, models.ResolveOptions{
+                Environment: environment,
+                Preview: []models.PropertyDraft{{
+                        NodeID:       prop.NodeID,
+                        Key:          prop.Key,
+                        Environment:  prop.Environment,
+                        Value:        &prop.Value,
+                        DataType:     &prop.DataType,
+                        DefaultValue: prop.DefaultValue,
+                        Description:  prop.Description,
+                }},
+        })
+        if err != nil {
+                respondError(c, err, "Failed to resolve configuration")
+                return
+        }
+        after, hasKey = preview.Properties[prop.Key]
+
+        inheriting, err := h.repo.CountInheritingNodes(c.Request.Context(), prop.NodeID, prop.Key, prop.Environment)
+        if err != nil {
+                respondError(c, err, "Failed to count inheriting nodes")
+                return
+        }
+
+        c.JSON(http.StatusOK, models.PropertyDryRunResponse{
+                DryRun:          true,
+                Property:        *prop,
+                Warnings:        warnings,
+                Before:          before,
+                After:           after,
+                Changed:         hadKey != hasKey || !reflect.DeepEqual(before, after),
+                InheritingNodes: inheriting,
+        })
+}
+
+// updatedProperty returns prop with the fields of an update applied
+func updatedProperty(prop models.ConfigProperty, req models.UpdatePropertyRequest) *models.ConfigProperty {
+        if req.Value != nil {
+                prop.Value = *req.Value
+        }
+        if req.DataType != nil {
+                prop.DataType = *req.DataType
+        }
+        if req.DefaultValue != nil {
+                prop.DefaultValue = req.DefaultValue
+        }
+        if req.Description != nil {
+                prop.Description = *req.Description
+        }
+        if req.RolloutPercentage != nil {
+                prop.RolloutPercentage = req.RolloutPercentage
+        }
+        if req.RolloutKey != nil {
+                prop.RolloutKey = req.RolloutKey
+        }
+        if req.IsFinal != nil {
+                prop.IsFinal = *req.IsFinal
+        }
+        if req.ClearExpiry {
+                prop.ExpiresAt = nil
+        } else if req.ExpiresAt != nil {
+                prop.ExpiresAt = req.ExpiresAt
+        }
+        if req.Tags != nil {
+                prop.Tags = database.NormalizeTags(req.Tags)
+        }
+        if req.Namespace != nil {
+                prop.Namespace = req.Namespace
+                if *req.Namespace == "" {
+                        prop.Namespace = nil
+                }
+        }
+        prop.UpdatedAt = time.Now()
+        return &prop
+}
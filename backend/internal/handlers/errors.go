@@ -0,0 +1,164 @@
+package handlers
+
+import (
+        "config-manager/internal/database"
+        "config-manager/internal/encryption"
+        "config-manager/internal/problem"
+        "context"
+        "database/sql"
+        "database/sql/driver"
+        "errors"
+        "fmt"
+        "log"
+        "net/http"
+
+        "github.com/gin-gonic/gin"
+        "github.com/jackc/pgx/v5/pgconn"
+)
+
+// errorCodes maps the sentinel errors of the repository to responses
+var errorCodes = []struct {
+        err    error
+        status int
+        code   problem.Code
+}{
+        {database.ErrNodeNotFound, http.StatusNotFound, problem.CodeNodeNotFound},
+        {database.ErrPropertyNotFound, http.StatusNotFound, problem.CodePropertyNotFound},
+        {database.ErrKeyNotDefined, http.StatusNotFound, problem.CodeKeyNotFound},
+        {database.ErrTemplateNotFound, http.StatusNotFound, problem.CodeTemplateNotFound},
+        {database.ErrTemplateVersionNotFound, http.StatusNotFound, problem.CodeTemplateNotFound},
+        {database.ErrWorkspaceNotFound, http.StatusNotFound, problem.CodeWorkspaceNotFound},
+        {database.ErrChangeRequestNotFound, http.StatusNotFound, problem.CodeChangeRequestNotFound},
+        {database.ErrScheduledChangeNotFound, http.StatusNotFound, problem.CodeNotFound},
+        {database.ErrAPIKeyNotFound, http.StatusNotFound, problem.CodeNotFound},
+        {database.ErrPermissionNotFound, http.StatusNotFound, problem.CodeNotFound},
+        {database.ErrProtectionNotFound, http.StatusNotFound, problem.CodeNotFound},
+        {database.ErrTOTPNotEnrolled, http.StatusNotFound, problem.CodeNotFound},
+        {database.ErrEncryptionKeyNotFound, http.StatusNotFound, problem.CodeNotFound},
+        {database.ErrKeyRotationNotFound, http.StatusNotFound, problem.CodeNotFound},
+        {database.ErrQuotaNotFound, http.StatusNotFound, problem.CodeNotFound},
+        {database.ErrNodeNameTaken, http.StatusConflict, problem.CodeNameTaken},
+        {database.ErrTemplateNameTaken, http.StatusConflict, problem.CodeNameTaken},
+        {database.ErrSnapshotNameTaken, http.StatusConflict, problem.CodeNameTaken},
+        {database.ErrParentDefinesKey, http.StatusConflict, problem.CodeKeyConflict},
+        {database.ErrKeyFinal, http.StatusConflict, problem.CodeKeyFinal},
+        {database.ErrTemplateInUse, http.StatusConflict, problem.CodeInUse},
+        {database.ErrEncryptionKeyExists, http.StatusConflict, problem.CodeConflict},
+        {database.ErrKeyRotationExists, http.StatusConflict, problem.CodeConflict},
+        {database.ErrReplacementStale, http.StatusConflict, problem.CodeVersionConflict},
+        {database.ErrWorkspaceNotOpen, http.StatusConflict, problem.CodeInvalidState},
+        {database.ErrChangeRequestNotOpen, http.StatusConflict, problem.CodeInvalidState},
+        {database.ErrChangeRequestNotApproved, http.StatusConflict, problem.CodeInvalidState},
+        {database.ErrChangeRequestExists, http.StatusConflict, problem.CodeInvalidState},
+        {database.ErrScheduledChangeNotPending, http.StatusConflict, problem.CodeInvalidState},
+        {database.ErrEncryptionKeyRetired, http.StatusConflict, problem.CodeInvalidState},
+        {database.ErrKeyRotationNotPending, http.StatusConflict, problem.CodeInvalidState},
+        {database.ErrKeyRotationNotFailed, http.StatusConflict, problem.CodeInvalidState},
+        {database.ErrNoDrafts, http.StatusConflict, problem.CodeInvalidState},
+        {database.ErrSelfReview, http.StatusForbidden, problem.CodePermissionDenied},
+        {database.ErrInvalidOrder, http.StatusBadRequest, problem.CodeInvalidRequest},
+        {database.ErrInvalidCursor, http.StatusBadRequest, problem.CodeInvalidRequest},
+        {database.ErrInvalidWorkspaceChange, http.StatusBadRequest, problem.CodeInvalidRequest},
+        {database.ErrDraftEncrypted, http.StatusBadRequest, problem.CodeInvalidRequest},
+        {database.ErrScheduledChangeEncrypted, http.StatusBadRequest, problem.CodeInvalidRequest},
+        {database.ErrPromoteEncrypted, http.StatusBadRequest, problem.CodeInvalidRequest},
+        {database.ErrPropertyNotSecret, http.StatusBadRequest, problem.CodeInvalidRequest},
+        {database.ErrPromoteRootProperty, http.StatusBadRequest, problem.CodeInvalidRequest},
+        {database.ErrPushDownLeaf, http.StatusBadRequest, problem.CodeInvalidRequest},
+        {database.ErrNotRootNode, http.StatusBadRequest, problem.CodeInvalidRequest},
+        {database.ErrPushDownFinal, http.StatusBadRequest, problem.CodeKeyFinal},
+        {database.ErrNoEncryptionKey, http.StatusBadRequest, problem.CodeEncryptionUnavailable},
+        {encryption.ErrNotConfigured, http.StatusServiceUnavailable, problem.CodeEncryptionUnavailable},
+        {database.ErrNotSupported, http.StatusNotImplemented, problem.CodeNotImplemented},
+}
+
+// respondError writes the problem an error describes. Errors that are not
+// recognized are logged and reported as internal errors with the fallback
+// detail, so that their text never reaches clients.
+func respondError(c *gin.Context, err error, fallback string) {
+        status, code, detail, extensions := translateError(err)
+        if status == http.StatusInternalServerError {
+                log.Printf("%s %s: %s: %v", c.Request.Method, c.Request.URL.Path, fallback, err)
+                detail = fallback
+        }
+        problem.Respond(c, status, code, detail, extensions...)
+}
+
+// translateError maps repository, driver and context errors to a status, a
+// problem code, a detail and any extension members
+func translateError(err error) (int, problem.Code, string, []gin.H) {
+        // Failed operations of a batch are reported with their index
+        var batchErr *database.BatchError
+        if errors.As(err, &batchErr) {
+                status, code, detail, extensions := translateError(batchErr.Err)
+                if detail != "" {
+                        detail = fmt.Sprintf("operations[%d]: %s", batchErr.Index, detail)
+                }
+                return status, code, detail, append(extensions, gin.H{"operation": batchErr.Index})
+        }
+
+        var tooLarge *valueTooLargeError
+        if errors.As(err, &tooLarge) {
+                return http.StatusRequestEntityTooLarge, problem.CodePayloadTooLarge, err.Error(), nil
+        }
+
+        var reqErr *requestError
+        if errors.As(err, &reqErr) {
+                return http.StatusBadRequest, reqErr.code, err.Error(), nil
+        }
+
+        for _, known := range errorCodes {
+                if errors.Is(err, known.err) {
+                        return known.status, known.code, err.Error(), nil
+                }
+        }
+
+        var interpolationErr *database.InterpolationError
+        var applyErr *database.ApplyError
+        var workspaceConflictErr *database.WorkspaceConflictError
+        var renameConflictErr *database.KeyRenameConflictError
+        var pgErr *pgconn.PgError
+        var connectErr *pgconn.ConnectError
+        switch {
+        case errors.As(err, &interpolationErr):
+                return http.StatusUnprocessableEntity, problem.CodeInterpolationFailed, err.Error(), nil
+        case errors.As(err, &applyErr):
+                return http.StatusUnprocessableEntity, problem.CodeInvalidDocument, err.Error(), nil
+        case errors.As(err, &workspaceConflictErr):
+                return http.StatusConflict, problem.CodeVersionConflict, err.Error(),
+                        []gin.H{{"conflicts": workspaceConflictErr.Conflicts}}
+        case errors.As(err, &renameConflictErr):
+                return http.StatusConflict, problem.CodeKeyConflict, err.Error(),
+                        []gin.H{{"node_ids": renameConflictErr.NodeIDs}}
+        case errors.Is(err, context.DeadlineExceeded):
+                return http.StatusGatewayTimeout, problem.CodeTimeout, "The request timed out", nil
+        case errors.Is(err, driver.ErrBadConn), errors.Is(err, sql.ErrConnDone), errors.As(err, &connectErr):
+                return http.StatusServiceUnavailable, problem.CodeDatabaseUnavailable, "The database is unavailable", nil
+        case errors.As(err, &pgErr):
+                return translatePgError(pgErr)
+        }
+        return http.StatusInternalServerError, problem.CodeInternal, "", nil
+}
+
+// translatePgError maps the PostgreSQL errors that are caused by the request
+// or the state of the database rather than by a bug
+func translatePgError(err *pgconn.PgError) (int, problem.Code, string, []gin.H) {
+        switch err.Code {
+        case "23505":
+                return http.StatusConflict, problem.CodeConflict, "A resource with the same unique values already exists", nil
+        case "23503":
+                return http.StatusConflict, problem.CodeConflict, "The change references a missing resource or one still in use", nil
+        case "23514", "22001", "22003":
+                return http.StatusBadRequest, problem.CodeInvalidValue, "A value is out of range: " + err.Message, nil
+        case "40001", "40P01":
+                return http.StatusConflict, problem.CodeVersionConflict, "The change conflicted with a concurrent update, retry it", nil
+        case "57014":
+                return http.StatusGatewayTimeout, problem.CodeTimeout, "The request timed out", nil
+        case database.SQLStateQuotaExceeded:
+                return http.StatusUnprocessableEntity, problem.CodeQuotaExceeded, err.Message, nil
+        }
+        if class := err.Code[:2]; class == "08" || class == "53" || class == "57" {
+                return http.StatusServiceUnavailable, problem.CodeDatabaseUnavailable, "The database is unavailable", nil
+        }
+        return http.StatusInternalServerError, problem.CodeInternal, "", nil
+}
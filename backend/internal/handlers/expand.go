@@ -0,0 +1,141 @@
+package handlers
+
+import (
+        "config-manager/internal/models"
+        "config-manager/internal/problem"
+        "net/http"
+        "strconv"
+        "strings"
+
+        "github.com/gin-gonic/gin"
+)
+
+const (
+        defaultChildrenLimit = 100
+        maxChildrenLimit     = 1000
+)
+
+// expandable lists the parts ?expand= can add to a node
+var expandable = map[string]bool{"children": true, "properties": true, "path": true}
+
+// parseExpand reads the comma-separated ?expand= parts, nil when there are
+// none. It writes an error response and returns false for unknown parts.
+func parseExpand(c *gin.Context) (map[string]bool, bool) {
+        value := c.Query("expand")
+        if value == "" {
+                return nil, true
+        }
+
+        expand := make(map[string]bool)
+        for _, part := range strings.Split(value, ",") {
+                part = strings.TrimSpace(part)
+                if !expandable[part] {
+                        problem.Respond(c, http.StatusBadRequest, problem.CodeInvalidRequest, "expand must list children, properties or path")
+                        return nil, false
+                }
+                expand[part] = true
+        }
+        return expand, true
+}
+
+// parseChildrenPage reads the ?after= cursor and ?limit= of a page of
+// children. Children are only paged when either is set. It writes an error
+// response and returns false when they are invalid.
+func parseChildrenPage(c *gin.Context) (after *int64, limit int, paged bool, ok bool) {
+        afterStr, limitStr := c.Query("after"), c.Query("limit")
+        if afterStr == "" && limitStr == "" {
+                return nil, 0, false, true
+        }
+
+        if afterStr != "" {
+                id, err := strconv.ParseInt(afterStr, 10, 64)
+                if err != nil {
+                        problem.Respond(c, http.StatusBadRequest, problem.CodeInvalidRequest, "Invalid cursor")
+                        return nil, 0, false, false
+                }
+                after = &id
+        }
+
+        limit = defaultChildrenLimit
+        if limitStr != "" {
+                var err error
+                limit, err = strconv.Atoi(limitStr)
+                if err != nil || limit < 1 || limit > maxChildrenLimit {
+                        problem.Respond(c, http.StatusBadRequest, problem.CodeInvalidRequest, "limit must be between 1 and 1000")
+                        return nil, 0, false, false
+                }
+        }
+        return after, limit, true, true
+}
+
+// respondExpanded responds with node and the parts of expand
+func (h *Handler) respondExpanded(c *gin.Context, node *models.ConfigNode, expand map[string]bool) {
+        result := models.ExpandedNode{ConfigNode: *node}
+
+        if expand["children"] {
+                after, limit, paged, ok := parseChildrenPage(c)
+                if !ok {
+                        return
+                }
+
+                var children []models.ConfigNode
+                var err error
+                if paged {
+                        children, err = h.reads(c).GetChildNodesPage(c.Request.Context(), node.ID, after, limit+1)
+                } else {
+                        children, err = h.reads(c).GetChildNodes(c.Request.Context(), node.ID)
+                }
+                if err != nil {
+                        respondError(c, err, "Failed to get child nodes")
+                        return
+                }
+                if children == nil {
+                        children = []models.ConfigNode{}
+                }
+
+                if paged {
+                        page := &models.ChildrenPage{NextCursor: after}
+                        if len(children) > limit {
+                                children = children[:limit]
+                                page.HasMore = true
+                        }
+                        if len(children) > 0 {
+                                page.NextCursor = &children[len(children)-1].ID
+                        }
+                        result.ChildrenPage = page
+                }
+                result.Children = &children
+        }
+
+        if expand["properties"] {
+                properties, err := h.reads(c).GetPropertiesByNodeID(c.Request.Context(), node.ID)
+                if err != nil {
+                        respondError(c, err, "Failed to get properties")
+                        return
+                }
+                if properties == nil {
+                        properties = []models.ConfigProperty{}
+                }
+                maskSecrets(properties)
+                result.Properties = &properties
+        }
+
+        if expand["path"] {
+                path, err := h.reads(c).GetNodePath(c.Request.Context(), node.ID)
+                if err != nil {
+                        respondError(c, err, "Failed to get node path")
+                        return
+                }
+                result.Path = &path
+                h.recordAccess(c.Request.Context(), node.ID)
+        }
+
+        c.JSON(http.StatusOK, result)
+}
+
+// deprecatedRoute marks the response of a route being phased out, pointing
+// clients to the request replacing it
+func deprecatedRoute(c *gin.Context, successor string) {
+        c.Header("Deprecation", "true")
+        c.Header("Link", "<"+successor+">; rel=\"successor-version\"")
+}
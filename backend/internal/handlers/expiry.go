@@ -0,0 +1,73 @@
+package handlers
+
+import (
+        "config-manager/internal/models"
+        "config-manager/internal/problem"
+        "errors"
+        "net/http"
+        "time"
+
+        "github.com/gin-gonic/gin"
+)
+
+// GetExpiringProperties reports the expired properties that are still stored
+// and, with ?within= such as 72h, those expiring within that window, across
+// the tree or within the subtree given as ?nodeId=
+func (h *Handler) GetExpiringProperties(c *gin.Context) {
+        var within time.Duration
+        if value := c.Query("within"); value != "" {
+                d, err := time.ParseDuration(value)
+                if err != nil || d < 0 {
+                        problem.Respond(c, http.StatusBadRequest, problem.CodeInvalidRequest, "within must be a positive duration such as 72h")
+                        return
+                }
+                within = d
+        }
+
+        rootID, ok := h.searchRoot(c)
+        if !ok {
+                return
+        }
+
+        now := time.Now()
+        results, err := h.repo.GetExpiringProperties(c.Request.Context(), now.Add(within), rootID)
+        if err != nil {
+                respondError(c, err, "Failed to get expiring properties")
+                return
+        }
+        if rootID == nil {
+                if results, err = h.filterReadableResults(c, results); err != nil {
+                        respondError(c, err, "Failed to check permissions")
+                        return
+                }
+        }
+
+        maskSecretResults(results)
+        report := models.ExpiryReport{
+                Now:      now,
+                Expired:  []models.PropertySearchResult{},
+                Expiring: []models.PropertySearchResult{},
+        }
+        if within > 0 {
+                report.Within = within.String()
+        }
+        for _, result := range results {
+                if result.ExpiresAt.After(now) {
+                        report.Expiring = append(report.Expiring, result)
+                } else {
+                        report.Expired = append(report.Expired, result)
+                }
+        }
+
+        c.JSON(http.StatusOK, report)
+}
+
+var errExpiryInPast = errors.New("expires_at must be in the future")
+
+// validateExpiry checks that a property is not written with an expiry in the past
+func validateExpiry(expiresAt *time.Time) error {
+        if expiresAt != nil && !expiresAt.After(time.Now()) {
+                return errExpiryInPast
+        }
+        return nil
+}
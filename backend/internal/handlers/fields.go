@@ -0,0 +1,91 @@
+package handlers
+
+import (
+        "config-manager/internal/problem"
+        "bytes"
+        "encoding/json"
+        "net/http"
+        "strings"
+
+        "github.com/gin-gonic/gin"
+)
+
+// SparseFields trims successful JSON responses to the comma-separated
+// ?fields= given, e.g. ?fields=id,name,node_type. Every object with an id,
+// such as a node or a property, keeps only the listed fields, at any depth:
+// listing children keeps the nested children of a tree, trimmed alike.
+// Responses without ids, such as resolved configurations, are left as they are.
+func SparseFields() gin.HandlerFunc {
+        return func(c *gin.Context) {
+                value := c.Query("fields")
+                if value == "" {
+                        c.Next()
+                        return
+                }
+
+                fields := make(map[string]bool)
+                for _, field := range strings.Split(value, ",") {
+                        if field = strings.TrimSpace(field); field != "" {
+                                fields[field] = true
+                        }
+                }
+                if len(fields) == 0 {
+                        problem.Abort(c, http.StatusBadRequest, problem.CodeInvalidRequest, "fields must list at least one field")
+                        return
+                }
+
+                writer := &bufferedWriter{ResponseWriter: c.Writer}
+                c.Writer = writer
+                c.Next()
+                c.Writer = writer.ResponseWriter
+
+                body := writer.body.Bytes()
+                if writer.Status() >= 200 && writer.Status() < 300 && strings.HasPrefix(writer.Header().Get("Content-Type"), "application/json") {
+                        // Numbers are kept as written, IDs may not fit a float64
+                        decoder := json.NewDecoder(bytes.NewReader(body))
+                        decoder.UseNumber()
+                        var decoded interface{}
+                        if err := decoder.Decode(&decoded); err == nil {
+                                if trimmed, err := json.Marshal(trimFields(decoded, fields)); err == nil {
+                                        body = trimmed
+                                }
+                        }
+                }
+                writer.ResponseWriter.Write(body)
+        }
+}
+
+// trimFields removes the fields not listed from the objects with an id in value
+func trimFields(value interface{}, fields map[string]bool) interface{} {
+        switch value := value.(type) {
+        case map[string]interface{}:
+                _, isResource := value["id"]
+                for key, child := range value {
+                        if isResource && !fields[key] {
+                                delete(value, key)
+                                continue
+                        }
+                        value[key] = trimFields(child, fields)
+                }
+        case []interface{}:
+                for i, child := range value {
+                        value[i] = trimFields(child, fields)
+                }
+        }
+        return value
+}
+
+// bufferedWriter holds back the body of a response so that it can be
+// rewritten once the handler is done
+type bufferedWriter struct {
+        gin.ResponseWriter
+        body bytes.Buffer
+}
+
+func (w *bufferedWriter) Write(data []byte) (int, error) {
+        return w.body.Write(data)
+}
+
+func (w *bufferedWriter) WriteString(s string) (int, error) {
+        return w.body.WriteString(s)
+}
@@ -0,0 +1,71 @@
+package handlers
+
+import (
+        "config-manager/internal/flags"
+        "config-manager/internal/models"
+        "config-manager/internal/problem"
+        "net/http"
+        "strconv"
+
+        "github.com/gin-gonic/gin"
+)
+
+// EvaluateFlags evaluates the flags and boolean rollouts in effect on a node
+// against the targeting context in the request body
+func (h *Handler) EvaluateFlags(c *gin.Context) {
+        nodeIDStr := c.Param("nodeId")
+        nodeID, err := strconv.ParseInt(nodeIDStr, 10, 64)
+        if err != nil {
+                problem.Respond(c, http.StatusBadRequest, problem.CodeInvalidID, "Invalid node ID")
+                return
+        }
+
+        if !h.authorize(c, nodeID, models.PermissionRead) {
+                return
+        }
+
+        var req models.EvaluateFlagsRequest
+        if err := c.ShouldBindJSON(&req); err != nil {
+                problem.Respond(c, http.StatusBadRequest, problem.CodeInvalidRequest, err.Error())
+                return
+        }
+        if req.Environment != "" && !environmentPattern.MatchString(req.Environment) {
+                problem.Respond(c, http.StatusBadRequest, problem.CodeInvalidEnvironment, "Invalid environment")
+                return
+        }
+
+        properties, err := h.repo.GetEffectiveProperties(c.Request.Context(), nodeID, req.Environment)
+        if err != nil {
+                respondError(c, err, "Failed to evaluate flags")
+                return
+        }
+        if properties == nil {
+                problem.Respond(c, http.StatusNotFound, problem.CodeNodeNotFound, "Node not found")
+                return
+        }
+
+        requested := make(map[string]bool, len(req.Flags))
+        for _, key := range req.Flags {
+                requested[key] = true
+        }
+
+        evaluation := models.FlagEvaluation{
+                NodeID:      nodeID,
+                Environment: req.Environment,
+                Flags:       make(map[string]models.FlagDecision),
+        }
+        for key, prop := range properties {
+                if len(requested) > 0 && !requested[key] {
+                        continue
+                }
+                switch {
+                case prop.DataType == models.DataTypeFlag:
+                        evaluation.Flags[key] = flags.Evaluate(prop.Value, req.Context)
+                case flags.HasRollout(prop):
+                        evaluation.Flags[key] = flags.EvaluateRollout(prop, req.Context)
+                }
+        }
+
+        h.recordAccess(c.Request.Context(), nodeID)
+        c.JSON(http.StatusOK, evaluation)
+}
@@ -1,75 +1,157 @@
 package handlers
 
 import (
+        "config-manager/internal/auth"
         "config-manager/internal/database"
+        "config-manager/internal/encryption"
+        "config-manager/internal/events"
+        "config-manager/internal/flags"
         "config-manager/internal/models"
+        "config-manager/internal/problem"
+        "config-manager/internal/quota"
+        "config-manager/internal/resolvepb"
+        "config-manager/internal/signing"
+        "config-manager/internal/syncer"
+        "context"
         "encoding/json"
+        "errors"
+        "io"
         "net/http"
+        "regexp"
         "strconv"
+        "strings"
+        "time"
 
         "github.com/gin-gonic/gin"
 )
 
+// validDataTypes lists the data types a property may be written with
+var validDataTypes = map[models.DataType]bool{
+        models.DataTypeString:  true,
+        models.DataTypeNumber:  true,
+        models.DataTypeBoolean: true,
+        models.DataTypeObject:  true,
+        models.DataTypeArray:   true,
+        models.DataTypeNull:    true,
+        models.DataTypeFlag:    true,
+}
+
+// environmentPattern restricts environment names to short lowercase slugs such as "staging"
+var environmentPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,49}$`)
+
+// namespacePattern restricts property namespaces to the same slugs, e.g. "payments"
+var namespacePattern = environmentPattern
+
 type Handler struct {
-        repo *database.Repository
+        repo        database.ConfigRepository
+        acl         *auth.ACL
+        deleteGuard time.Duration
+        changes     *events.Broadcaster
+        readRepo    database.ConfigRepository
+        signer      *signing.Signer
+        limiter     *quota.Limiter
+        ssmSync     *syncer.Controller
+        checks      []componentCheck
+        wsOrigins   []string
+
+        requireRegisteredKeys bool
 }
 
-func NewHandler(repo *database.Repository) *Handler {
-        return &Handler{repo: repo}
+// NewHandler creates the API handlers. Deleting configuration that consumers
+// resolved within deleteGuard requires ?force=true; zero disables the guard.
+// Watch requests wake up on changes announced by changes, and poll without it.
+// With requireRegisteredKeys, only keys in the key registry can be defined.
+func NewHandler(repo database.ConfigRepository, acl *auth.ACL, deleteGuard time.Duration, changes *events.Broadcaster, requireRegisteredKeys bool) *Handler {
+        return &Handler{repo: repo, acl: acl, deleteGuard: deleteGuard, changes: changes, requireRegisteredKeys: requireRegisteredKeys}
 }
 
 // Node handlers
 func (h *Handler) CreateNode(c *gin.Context) {
         var req models.CreateNodeRequest
         if err := c.ShouldBindJSON(&req); err != nil {
-                c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+                problem.Respond(c, http.StatusBadRequest, problem.CodeInvalidRequest, err.Error())
                 return
         }
 
-        // Validate node type
-        if req.NodeType != models.NodeTypeTerritory && req.NodeType != models.NodeTypeCenter {
-                c.JSON(http.StatusBadRequest, gin.H{"error": "nodeType must be 'territory' or 'center'"})
+        if err := validateCreateNode(req); err != nil {
+                respondError(c, err, "Invalid node")
+                return
+        }
+
+        // Creating a root node requires admin access, a child needs write access on its parent
+        if req.ParentID == nil {
+                if !h.authorizeAdmin(c) {
+                        return
+                }
+        } else if !h.authorize(c, *req.ParentID, models.PermissionWrite) {
                 return
         }
 
         // If parent_id is provided, validate parent exists
         if req.ParentID != nil {
-                parent, err := h.repo.GetNodeByID(*req.ParentID)
+                parent, err := h.repo.GetNodeByID(c.Request.Context(), *req.ParentID)
                 if err != nil {
-                        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to validate parent node"})
+                        respondError(c, err, "Failed to validate parent node")
                         return
                 }
                 if parent == nil {
-                        c.JSON(http.StatusBadRequest, gin.H{"error": "Parent node not found"})
+                        problem.Respond(c, http.StatusBadRequest, problem.CodeParentNotFound, "Parent node not found")
+                        return
+                }
+                if !h.guardProtected(c, *req.ParentID, false) {
                         return
                 }
         }
 
-        node, err := h.repo.CreateNode(req)
+        if !h.guardSiblingName(c, req.ParentID, req.Name, 0) {
+                return
+        }
+
+        node, err := h.repo.CreateNode(c.Request.Context(), req)
+        if errors.Is(err, database.ErrNodeNameTaken) {
+                problem.Respond(c, http.StatusConflict, problem.CodeNameTaken, err.Error())
+                return
+        }
         if err != nil {
-                c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create node"})
+                respondError(c, err, "Failed to create node")
                 return
         }
 
         c.JSON(http.StatusCreated, node)
 }
 
+// GetNode returns a node, with ?expand=children,properties,path the parts
+// listed as well
 func (h *Handler) GetNode(c *gin.Context) {
-        idStr := c.Param("id")
+        idStr := c.Param("nodeId")
         id, err := strconv.ParseInt(idStr, 10, 64)
         if err != nil {
-                c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid node ID"})
+                problem.Respond(c, http.StatusBadRequest, problem.CodeInvalidID, "Invalid node ID")
                 return
         }
 
-        node, err := h.repo.GetNodeByID(id)
+        if !h.authorize(c, id, models.PermissionRead) {
+                return
+        }
+
+        expand, ok := parseExpand(c)
+        if !ok {
+                return
+        }
+
+        node, err := h.reads(c).GetNodeByID(c.Request.Context(), id)
         if err != nil {
-                c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get node"})
+                respondError(c, err, "Failed to get node")
                 return
         }
 
         if node == nil {
-                c.JSON(http.StatusNotFound, gin.H{"error": "Node not found"})
+                problem.Respond(c, http.StatusNotFound, problem.CodeNodeNotFound, "Node not found")
+                return
+        }
+
+        if expand != nil {
+                h.respondExpanded(c, node, expand)
                 return
         }
 
@@ -77,42 +159,50 @@ func (h *Handler) GetNode(c *gin.Context) {
 }
 
 func (h *Handler) GetNodeWithChildren(c *gin.Context) {
-        idStr := c.Param("id")
+        idStr := c.Param("nodeId")
         id, err := strconv.ParseInt(idStr, 10, 64)
         if err != nil {
-                c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid node ID"})
+                problem.Respond(c, http.StatusBadRequest, problem.CodeInvalidID, "Invalid node ID")
                 return
         }
 
-        node, err := h.repo.GetNodeByID(id)
-        if err != nil {
-                c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get node"})
+        if !h.authorize(c, id, models.PermissionRead) {
                 return
         }
 
-        if node == nil {
-                c.JSON(http.StatusNotFound, gin.H{"error": "Node not found"})
+        result, err := h.reads(c).GetNodeWithChildren(c.Request.Context(), id)
+        if err != nil {
+                respondError(c, err, "Failed to get node")
                 return
         }
 
-        children, err := h.repo.GetChildNodes(id)
-        if err != nil {
-                c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get child nodes"})
+        if result == nil {
+                problem.Respond(c, http.StatusNotFound, problem.CodeNodeNotFound, "Node not found")
                 return
         }
 
-        result := models.ConfigNodeWithChildren{
-                ConfigNode: *node,
-                Children:   children,
-        }
+        deprecatedRoute(c, "/api/nodes/"+idStr+"?expand=children")
 
         c.JSON(http.StatusOK, result)
 }
 
+// GetRootNodes lists the root nodes, or with ?label= selectors such as
+// tier=gold, the nodes anywhere in the tree carrying all of those labels
 func (h *Handler) GetRootNodes(c *gin.Context) {
-        nodes, err := h.repo.GetRootNodes()
+        if selectors := c.QueryArray("label"); len(selectors) > 0 {
+                h.findNodesByLabels(c, selectors)
+                return
+        }
+
+        nodes, err := h.reads(c).GetRootNodes(c.Request.Context())
+        if err != nil {
+                respondError(c, err, "Failed to get root nodes")
+                return
+        }
+
+        nodes, err = h.filterReadable(c, nodes)
         if err != nil {
-                c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get root nodes"})
+                respondError(c, err, "Failed to check permissions")
                 return
         }
 
@@ -120,27 +210,59 @@ func (h *Handler) GetRootNodes(c *gin.Context) {
 }
 
 func (h *Handler) UpdateNode(c *gin.Context) {
-        idStr := c.Param("id")
+        idStr := c.Param("nodeId")
         id, err := strconv.ParseInt(idStr, 10, 64)
         if err != nil {
-                c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid node ID"})
+                problem.Respond(c, http.StatusBadRequest, problem.CodeInvalidID, "Invalid node ID")
+                return
+        }
+
+        if !h.authorize(c, id, models.PermissionWrite) {
+                return
+        }
+
+        if !h.guardProtected(c, id, false) {
                 return
         }
 
         var req models.UpdateNodeRequest
         if err := c.ShouldBindJSON(&req); err != nil {
-                c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+                problem.Respond(c, http.StatusBadRequest, problem.CodeInvalidRequest, err.Error())
+                return
+        }
+
+        if err := validateUpdateNode(req); err != nil {
+                respondError(c, err, "Invalid node")
                 return
         }
 
-        node, err := h.repo.UpdateNode(id, req)
+        if req.Name != nil {
+                current, err := h.repo.GetNodeByID(c.Request.Context(), id)
+                if err != nil {
+                        respondError(c, err, "Failed to get node")
+                        return
+                }
+                if current == nil {
+                        problem.Respond(c, http.StatusNotFound, problem.CodeNodeNotFound, "Node not found")
+                        return
+                }
+                if !h.guardSiblingName(c, current.ParentID, *req.Name, id) {
+                        return
+                }
+        }
+
+        node, err := h.repo.UpdateNode(c.Request.Context(), id, req)
+        if errors.Is(err, database.ErrNodeNameTaken) {
+                problem.Respond(c, http.StatusConflict, problem.CodeNameTaken, err.Error())
+                return
+        }
         if err != nil {
-                c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update node"})
+                respondError(c, err, "Failed to update node")
                 return
         }
 
         if node == nil {
-                c.JSON(http.StatusNotFound, gin.H{"error": "Node not found"})
+                problem.Respond(c, http.StatusNotFound, problem.CodeNodeNotFound, "Node not found")
                 return
         }
 
@@ -148,16 +270,28 @@ func (h *Handler) UpdateNode(c *gin.Context) {
 }
 
 func (h *Handler) DeleteNode(c *gin.Context) {
-        idStr := c.Param("id")
+        idStr := c.Param("nodeId")
         id, err := strconv.ParseInt(idStr, 10, 64)
         if err != nil {
-                c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid node ID"})
+                problem.Respond(c, http.StatusBadRequest, problem.CodeInvalidID, "Invalid node ID")
+                return
+        }
+
+        if !h.authorize(c, id, models.PermissionWrite) {
+                return
+        }
+
+        if !h.guardProtected(c, id, true) {
+                return
+        }
+
+        if !h.guardDelete(c, id) {
                 return
         }
 
-        err = h.repo.DeleteNode(id)
+        err = h.repo.DeleteNode(c.Request.Context(), id)
         if err != nil {
-                c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete node"})
+                respondError(c, err, "Failed to delete node")
                 return
         }
 
@@ -169,72 +303,185 @@ func (h *Handler) CreateProperty(c *gin.Context) {
         nodeIDStr := c.Param("nodeId")
         nodeID, err := strconv.ParseInt(nodeIDStr, 10, 64)
         if err != nil {
-                c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid node ID"})
+                problem.Respond(c, http.StatusBadRequest, problem.CodeInvalidID, "Invalid node ID")
                 return
         }
 
-        var req models.CreatePropertyRequest
-        if err := c.ShouldBindJSON(&req); err != nil {
-                c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        if !h.authorize(c, nodeID, models.PermissionWrite) {
                 return
         }
 
-        // Validate JSON value
-        var jsonValue interface{}
-        if err := json.Unmarshal([]byte(req.Value), &jsonValue); err != nil {
-                c.JSON(http.StatusBadRequest, gin.H{"error": "Value must be valid JSON"})
+        if !h.guardProtected(c, nodeID, false) {
+                return
+        }
+
+        var req models.CreatePropertyRequest
+        if err := c.ShouldBindJSON(&req); err != nil {
+                problem.Respond(c, http.StatusBadRequest, problem.CodeInvalidRequest, err.Error())
                 return
         }
 
-        // Validate data type
-        validTypes := map[models.DataType]bool{
-                models.DataTypeString:  true,
-                models.DataTypeNumber:  true,
-                models.DataTypeBoolean: true,
-                models.DataTypeObject:  true,
-                models.DataTypeArray:   true,
-                models.DataTypeNull:    true,
+        if err := validateCreateProperty(&req); err != nil {
+                respondError(c, err, "Invalid property")
+                return
         }
 
-        if !validTypes[req.DataType] {
-                c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid data type"})
+        if !h.guardRegisteredKeys(c, req.Key) {
                 return
         }
 
         // Verify node exists
-        node, err := h.repo.GetNodeByID(nodeID)
+        node, err := h.repo.GetNodeByID(c.Request.Context(), nodeID)
         if err != nil {
-                c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to validate node"})
+                respondError(c, err, "Failed to validate node")
                 return
         }
         if node == nil {
-                c.JSON(http.StatusNotFound, gin.H{"error": "Node not found"})
+                problem.Respond(c, http.StatusNotFound, problem.CodeNodeNotFound, "Node not found")
+                return
+        }
+
+        if !h.guardFinal(c, nodeID, req.Key) {
+                return
+        }
+
+        now := time.Now()
+        prop := &models.ConfigProperty{
+                NodeID:            nodeID,
+                Key:               req.Key,
+                Environment:       req.Environment,
+                Value:             req.Value,
+                DataType:          req.DataType,
+                DefaultValue:      req.DefaultValue,
+                Description:       req.Description,
+                Encrypted:         req.Encrypted,
+                RolloutPercentage: req.RolloutPercentage,
+                RolloutKey:        req.RolloutKey,
+                IsFinal:           req.IsFinal,
+                Namespace:         req.Namespace,
+                Tags:              nonNilTags(database.NormalizeTags(req.Tags)),
+                ExpiresAt:         req.ExpiresAt,
+                CreatedAt:         now,
+                UpdatedAt:         now,
+        }
+        if !h.guardInterpolation(c, prop) {
+                return
+        }
+
+        if isDryRun(c) {
+                h.respondDryRun(c, prop)
                 return
         }
 
-        property, err := h.repo.CreateProperty(nodeID, req)
+        property, err := h.repo.CreateProperty(c.Request.Context(), nodeID, req)
         if err != nil {
-                c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create property"})
+                if isEncryptionSetupError(err) {
+                        problem.Respond(c, http.StatusBadRequest, problem.CodeEncryptionUnavailable, err.Error())
+                        return
+                }
+                respondError(c, err, "Failed to create property")
                 return
         }
 
-        c.JSON(http.StatusCreated, property)
+        response := models.PropertyWriteResponse{
+                ConfigProperty: *property,
+                Warnings:       h.propertyWarnings(c.Request.Context(), property),
+        }
+        maskSecret(&response.ConfigProperty)
+        c.JSON(http.StatusCreated, response)
 }
 
+// guardSiblingName writes an error response and returns false if a sibling of
+// a node under parentID other than excludeID is named name, in any case
+func (h *Handler) guardSiblingName(c *gin.Context, parentID *int64, name string, excludeID int64) bool {
+        taken, err := h.repo.SiblingNameTaken(c.Request.Context(), parentID, name, excludeID)
+        if err != nil {
+                respondError(c, err, "Failed to check sibling names")
+                return false
+        }
+        if taken {
+                problem.Respond(c, http.StatusConflict, problem.CodeNameTaken, "A sibling node is already named " + name)
+                return false
+        }
+        return true
+}
+
+// guardFinal writes an error response and returns false if an ancestor of
+// nodeID locks key as final
+func (h *Handler) guardFinal(c *gin.Context, nodeID int64, key string) bool {
+        locking, err := h.repo.GetLockingProperty(c.Request.Context(), nodeID, key)
+        if err != nil {
+                respondError(c, err, "Failed to check final properties")
+                return false
+        }
+        if locking != nil {
+                problem.Respond(c, http.StatusConflict, problem.CodeKeyFinal, "Property " + key + " is final on an ancestor and cannot be overridden", gin.H{
+                        "locked_by": locking.NodeID,
+                })
+                return false
+        }
+        return true
+}
+
+// guardInterpolation writes an error response and returns false if the
+// placeholders in the value of prop would not expand on its node, because they
+// reference unknown keys, form a cycle or are unterminated
+func (h *Handler) guardInterpolation(c *gin.Context, prop *models.ConfigProperty) bool {
+        if !strings.Contains(prop.Value, "${") {
+                return true
+        }
+
+        environment := ""
+        if prop.Environment != nil {
+                environment = *prop.Environment
+        }
+        preview, err := h.repo.ResolveConfiguration(c.Request.Context(), prop.NodeID, models.ResolveOptions{
+                Environment: environment,
+                Preview: []models.PropertyDraft{{
+                        NodeID:      prop.NodeID,
+                        Key:         prop.Key,
+                        Environment: prop.Environment,
+                        Value:       &prop.Value,
+                        DataType:    &prop.DataType,
+                }},
+        })
+        if err != nil {
+                respondError(c, err, "Failed to check interpolation")
+                return false
+        }
+        if failure := database.InterpolationFailure(preview, prop.Key); failure != nil {
+                respondError(c, failure, "Invalid property")
+                return false
+        }
+        return true
+}
+
+// GetNodeProperties lists the properties of a node, optionally only those in
+// the namespace given as ?namespace= and carrying every ?tag=
 func (h *Handler) GetNodeProperties(c *gin.Context) {
         nodeIDStr := c.Param("nodeId")
         nodeID, err := strconv.ParseInt(nodeIDStr, 10, 64)
         if err != nil {
-                c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid node ID"})
+                problem.Respond(c, http.StatusBadRequest, problem.CodeInvalidID, "Invalid node ID")
+                return
+        }
+
+        if !h.authorize(c, nodeID, models.PermissionRead) {
                 return
         }
 
-        properties, err := h.repo.GetPropertiesByNodeID(nodeID)
+        properties, err := h.reads(c).GetPropertiesByNodeID(c.Request.Context(), nodeID)
         if err != nil {
-                c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get properties"})
+                respondError(c, err, "Failed to get properties")
                 return
         }
 
+        properties, ok := filterPropertyQuery(c, properties)
+        if !ok {
+                return
+        }
+
+        maskSecrets(properties)
         c.JSON(http.StatusOK, properties)
 }
 
@@ -242,32 +489,28 @@ func (h *Handler) GetNodeWithProperties(c *gin.Context) {
         nodeIDStr := c.Param("nodeId")
         nodeID, err := strconv.ParseInt(nodeIDStr, 10, 64)
         if err != nil {
-                c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid node ID"})
+                problem.Respond(c, http.StatusBadRequest, problem.CodeInvalidID, "Invalid node ID")
                 return
         }
 
-        node, err := h.repo.GetNodeByID(nodeID)
-        if err != nil {
-                c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get node"})
+        if !h.authorize(c, nodeID, models.PermissionRead) {
                 return
         }
 
-        if node == nil {
-                c.JSON(http.StatusNotFound, gin.H{"error": "Node not found"})
+        result, err := h.reads(c).GetNodeWithProperties(c.Request.Context(), nodeID)
+        if err != nil {
+                respondError(c, err, "Failed to get node")
                 return
         }
 
-        properties, err := h.repo.GetPropertiesByNodeID(nodeID)
-        if err != nil {
-                c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get properties"})
+        if result == nil {
+                problem.Respond(c, http.StatusNotFound, problem.CodeNodeNotFound, "Node not found")
                 return
         }
 
-        result := models.ConfigNodeWithProperties{
-                ConfigNode: *node,
-                Properties: properties,
-        }
+        deprecatedRoute(c, "/api/nodes/"+nodeIDStr+"?expand=properties")
 
+        maskSecrets(result.Properties)
         c.JSON(http.StatusOK, result)
 }
 
@@ -275,71 +518,290 @@ func (h *Handler) UpdateProperty(c *gin.Context) {
         propertyIDStr := c.Param("propertyId")
         propertyID, err := strconv.ParseInt(propertyIDStr, 10, 64)
         if err != nil {
-                c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid property ID"})
+                problem.Respond(c, http.StatusBadRequest, problem.CodeInvalidID, "Invalid property ID")
+                return
+        }
+
+        if !h.authorizeProperty(c, propertyID, models.PermissionWrite) {
+                return
+        }
+
+        if !h.guardPropertyProtected(c, propertyID) {
                 return
         }
 
         var req models.UpdatePropertyRequest
         if err := c.ShouldBindJSON(&req); err != nil {
-                c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+                problem.Respond(c, http.StatusBadRequest, problem.CodeInvalidRequest, err.Error())
                 return
         }
 
-        // Validate JSON value if provided
-        if req.Value != nil {
-                var jsonValue interface{}
-                if err := json.Unmarshal([]byte(*req.Value), &jsonValue); err != nil {
-                        c.JSON(http.StatusBadRequest, gin.H{"error": "Value must be valid JSON"})
-                        return
-                }
+        if err := validateUpdateProperty(req); err != nil {
+                respondError(c, err, "Invalid property")
+                return
+        }
+
+        current, err := h.repo.GetProperty(c.Request.Context(), propertyID)
+        if err != nil {
+                respondError(c, err, "Failed to get property")
+                return
+        }
+        if current == nil {
+                problem.Respond(c, http.StatusNotFound, problem.CodePropertyNotFound, "Property not found")
+                return
+        }
+        updated := updatedProperty(*current, req)
+        if !h.guardInterpolation(c, updated) {
+                return
         }
 
-        property, err := h.repo.UpdateProperty(propertyID, req)
+        if isDryRun(c) {
+                h.respondDryRun(c, updated)
+                return
+        }
+
+        property, err := h.repo.UpdateProperty(c.Request.Context(), propertyID, req)
         if err != nil {
-                c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update property"})
+                if isEncryptionSetupError(err) {
+                        problem.Respond(c, http.StatusBadRequest, problem.CodeEncryptionUnavailable, err.Error())
+                        return
+                }
+                respondError(c, err, "Failed to update property")
                 return
         }
 
         if property == nil {
-                c.JSON(http.StatusNotFound, gin.H{"error": "Property not found"})
+                problem.Respond(c, http.StatusNotFound, problem.CodePropertyNotFound, "Property not found")
                 return
         }
 
-        c.JSON(http.StatusOK, property)
+        response := models.PropertyWriteResponse{
+                ConfigProperty: *property,
+                Warnings:       h.propertyWarnings(c.Request.Context(), property),
+        }
+        maskSecret(&response.ConfigProperty)
+        c.JSON(http.StatusOK, response)
 }
 
 func (h *Handler) DeleteProperty(c *gin.Context) {
         propertyIDStr := c.Param("propertyId")
         propertyID, err := strconv.ParseInt(propertyIDStr, 10, 64)
         if err != nil {
-                c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid property ID"})
+                problem.Respond(c, http.StatusBadRequest, problem.CodeInvalidID, "Invalid property ID")
+                return
+        }
+
+        if !h.authorizeProperty(c, propertyID, models.PermissionWrite) {
                 return
         }
 
-        err = h.repo.DeleteProperty(propertyID)
+        nodeID, err := h.repo.GetPropertyNodeID(c.Request.Context(), propertyID)
         if err != nil {
-                c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete property"})
+                respondError(c, err, "Failed to delete property")
+                return
+        }
+        if nodeID == nil {
+                problem.Respond(c, http.StatusNotFound, problem.CodePropertyNotFound, "Property not found")
+                return
+        }
+
+        if !h.guardProtected(c, *nodeID, false) {
+                return
+        }
+
+        if !h.guardDelete(c, *nodeID) {
+                return
+        }
+
+        err = h.repo.DeleteProperty(c.Request.Context(), propertyID)
+        if err != nil {
+                respondError(c, err, "Failed to delete property")
                 return
         }
 
         c.JSON(http.StatusNoContent, nil)
 }
 
+// requestError is a request that failed validation, with the code of the problem
+type requestError struct {
+        code   problem.Code
+        detail string
+}
+
+func (e *requestError) Error() string {
+        return e.detail
+}
+
+// invalidRequest describes a request that failed validation
+func invalidRequest(code problem.Code, detail string) error {
+        return &requestError{code: code, detail: detail}
+}
+
+// validateCreateNode checks the request of a new node
+func validateCreateNode(req models.CreateNodeRequest) error {
+        if req.NodeType != models.NodeTypeTerritory && req.NodeType != models.NodeTypeCenter {
+                return invalidRequest(problem.CodeInvalidRequest, "nodeType must be 'territory' or 'center'")
+        }
+        if err := validateLabels(req.Labels); err != nil {
+                return invalidRequest(problem.CodeInvalidRequest, err.Error())
+        }
+        if err := validateMetadata(req.Metadata); err != nil {
+                return invalidRequest(problem.CodeInvalidRequest, err.Error())
+        }
+        return nil
+}
+
+// validateUpdateNode checks the request of a node update
+func validateUpdateNode(req models.UpdateNodeRequest) error {
+        if err := validateLabels(req.Labels); err != nil {
+                return invalidRequest(problem.CodeInvalidRequest, err.Error())
+        }
+        if err := validateMetadata(req.Metadata); err != nil {
+                return invalidRequest(problem.CodeInvalidRequest, err.Error())
+        }
+        return nil
+}
+
+// validateCreateProperty checks the request of a new property, clearing an
+// empty namespace
+func validateCreateProperty(req *models.CreatePropertyRequest) error {
+        if err := checkValueSize(req.Value); err != nil {
+                return err
+        }
+        if req.DefaultValue != nil {
+                if err := checkValueSize(*req.DefaultValue); err != nil {
+                        return err
+                }
+        }
+
+        var jsonValue interface{}
+        if err := json.Unmarshal([]byte(req.Value), &jsonValue); err != nil {
+                return invalidRequest(problem.CodeInvalidValue, "Value must be valid JSON")
+        }
+
+        if !validDataTypes[req.DataType] {
+                return invalidRequest(problem.CodeInvalidDataType, "Invalid data type")
+        }
+
+        if req.DataType == models.DataTypeFlag {
+                if _, err := flags.Parse(req.Value); err != nil {
+                        return invalidRequest(problem.CodeInvalidValue, err.Error())
+                }
+        }
+
+        if req.Environment != nil && !environmentPattern.MatchString(*req.Environment) {
+                return invalidRequest(problem.CodeInvalidEnvironment, "Invalid environment")
+        }
+
+        if req.Namespace != nil && *req.Namespace == "" {
+                req.Namespace = nil
+        }
+        if req.Namespace != nil && !namespacePattern.MatchString(*req.Namespace) {
+                return invalidRequest(problem.CodeInvalidNamespace, "Invalid namespace")
+        }
+
+        if err := validateTags(req.Tags); err != nil {
+                return invalidRequest(problem.CodeInvalidRequest, err.Error())
+        }
+
+        if err := validateExpiry(req.ExpiresAt); err != nil {
+                return invalidRequest(problem.CodeInvalidRequest, err.Error())
+        }
+
+        if req.RolloutPercentage != nil || req.RolloutKey != nil {
+                if err := validateRollout(&req.DataType, req.RolloutPercentage, req.RolloutKey); err != nil {
+                        return invalidRequest(problem.CodeInvalidRequest, err.Error())
+                }
+                if req.RolloutPercentage == nil || req.RolloutKey == nil {
+                        return invalidRequest(problem.CodeInvalidRequest, "rollout_percentage and rollout_key must be set together")
+                }
+        }
+        return nil
+}
+
+// validateUpdateProperty checks the request of a property update
+func validateUpdateProperty(req models.UpdatePropertyRequest) error {
+        for _, value := range []*string{req.Value, req.DefaultValue} {
+                if value == nil {
+                        continue
+                }
+                if err := checkValueSize(*value); err != nil {
+                        return err
+                }
+        }
+
+        if req.Value != nil {
+                var jsonValue interface{}
+                if err := json.Unmarshal([]byte(*req.Value), &jsonValue); err != nil {
+                        return invalidRequest(problem.CodeInvalidValue, "Value must be valid JSON")
+                }
+
+                if req.DataType != nil && *req.DataType == models.DataTypeFlag {
+                        if _, err := flags.Parse(*req.Value); err != nil {
+                                return invalidRequest(problem.CodeInvalidValue, err.Error())
+                        }
+                }
+        }
+
+        if req.RolloutPercentage != nil || req.RolloutKey != nil {
+                if err := validateRollout(req.DataType, req.RolloutPercentage, req.RolloutKey); err != nil {
+                        return invalidRequest(problem.CodeInvalidRequest, err.Error())
+                }
+        }
+
+        if req.Namespace != nil && *req.Namespace != "" && !namespacePattern.MatchString(*req.Namespace) {
+                return invalidRequest(problem.CodeInvalidNamespace, "Invalid namespace")
+        }
+
+        if err := validateTags(req.Tags); err != nil {
+                return invalidRequest(problem.CodeInvalidRequest, err.Error())
+        }
+
+        if req.ClearExpiry && req.ExpiresAt != nil {
+                return invalidRequest(problem.CodeInvalidRequest, "expires_at cannot be combined with clear_expiry")
+        }
+        if err := validateExpiry(req.ExpiresAt); err != nil {
+                return invalidRequest(problem.CodeInvalidRequest, err.Error())
+        }
+        return nil
+}
+
+// validateRollout checks rollout settings; dataType is nil when a write keeps
+// the current data type
+func validateRollout(dataType *models.DataType, percentage *float64, key *string) error {
+        if dataType != nil && *dataType != models.DataTypeBoolean {
+                return errors.New("rollouts are only supported for boolean properties")
+        }
+        if percentage != nil && (*percentage < 0 || *percentage > 100) {
+                return errors.New("rollout_percentage must be between 0 and 100")
+        }
+        if key != nil && *key == "" {
+                return errors.New("rollout_key must not be empty")
+        }
+        return nil
+}
+
 // Configuration resolution handlers
 func (h *Handler) GetNodePath(c *gin.Context) {
         nodeIDStr := c.Param("nodeId")
         nodeID, err := strconv.ParseInt(nodeIDStr, 10, 64)
         if err != nil {
-                c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid node ID"})
+                problem.Respond(c, http.StatusBadRequest, problem.CodeInvalidID, "Invalid node ID")
+                return
+        }
+
+        if !h.authorize(c, nodeID, models.PermissionRead) {
                 return
         }
 
-        path, err := h.repo.GetNodePath(nodeID)
+        path, err := h.reads(c).GetNodePath(c.Request.Context(), nodeID)
         if err != nil {
-                c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get node path"})
+                respondError(c, err, "Failed to get node path")
                 return
         }
 
+        h.recordAccess(c.Request.Context(), nodeID)
+
         c.JSON(http.StatusOK, path)
 }
 
@@ -347,23 +809,373 @@ func (h *Handler) ResolveConfiguration(c *gin.Context) {
         nodeIDStr := c.Param("nodeId")
         nodeID, err := strconv.ParseInt(nodeIDStr, 10, 64)
         if err != nil {
-                c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid node ID"})
+                problem.Respond(c, http.StatusBadRequest, problem.CodeInvalidID, "Invalid node ID")
+                return
+        }
+
+        if !h.authorize(c, nodeID, models.PermissionRead) {
+                return
+        }
+
+        environment, context, ok := resolveQuery(c)
+        if !ok {
+                return
+        }
+
+        includeDrafts := c.Query("include_drafts") == "true"
+
+        namespace := c.Query("namespace")
+        if namespace != "" && !namespacePattern.MatchString(namespace) {
+                problem.Respond(c, http.StatusBadRequest, problem.CodeInvalidNamespace, "Invalid namespace")
+                return
+        }
+
+        var asOf *time.Time
+        if asOfStr := c.Query("asOf"); asOfStr != "" {
+                t, err := time.Parse(time.RFC3339, asOfStr)
+                if err != nil {
+                        problem.Respond(c, http.StatusBadRequest, problem.CodeInvalidRequest, "asOf must be an RFC 3339 timestamp")
+                        return
+                }
+                if includeDrafts {
+                        problem.Respond(c, http.StatusBadRequest, problem.CodeInvalidRequest, "asOf cannot be combined with include_drafts")
+                        return
+                }
+                asOf = &t
+        }
+
+        resolved, err := h.reads(c).ResolveConfiguration(c.Request.Context(), nodeID, models.ResolveOptions{
+                Environment:   environment,
+                Context:       context,
+                IncludeDrafts: includeDrafts,
+                AsOf:          asOf,
+                Namespace:     namespace,
+        })
+        if errors.Is(err, database.ErrNodeNotFound) {
+                if asOf != nil {
+                        problem.Respond(c, http.StatusNotFound, problem.CodeNodeNotFound, "Node did not exist at " + asOf.Format(time.RFC3339))
+                        return
+                }
+                problem.Respond(c, http.StatusNotFound, problem.CodeNodeNotFound, "Node not found")
+                return
+        }
+        if err != nil {
+                respondError(c, err, "Failed to resolve configuration")
+                return
+        }
+
+        // Draft previews and historical reads are not consumer reads
+        if !includeDrafts && asOf == nil {
+                h.recordAccess(c.Request.Context(), nodeID)
+        }
+
+        resolved.Warnings = append(resolved.Warnings, h.deprecationWarnings(c.Request.Context(), resolved)...)
+
+        c.Header("ETag", strconv.Quote(resolved.Checksum))
+        h.respondResolved(c, resolved)
+}
+
+// respondResolved sends a resolved configuration as protocol buffers to
+// clients that ask for them in Accept, and as JSON otherwise, signed if
+// responses are
+func (h *Handler) respondResolved(c *gin.Context, resolved *models.ResolvedConfiguration) {
+        if c.NegotiateFormat(gin.MIMEJSON, resolvepb.ContentType) == resolvepb.ContentType {
+                h.respondSigned(c, resolvepb.ContentType, resolvepb.Marshal(resolved))
+                return
+        }
+        if h.signer == nil {
+                c.JSON(http.StatusOK, resolved)
+                return
+        }
+
+        body, err := json.Marshal(resolved)
+        if err != nil {
+                respondError(c, err, "Failed to encode configuration")
+                return
+        }
+        h.respondSigned(c, gin.MIMEJSON+"; charset=utf-8", body)
+}
+
+// resolveQuery parses the environment and context attributes of a resolve request
+func resolveQuery(c *gin.Context) (string, map[string]interface{}, bool) {
+        environment := c.Query("env")
+        if environment != "" && !environmentPattern.MatchString(environment) {
+                problem.Respond(c, http.StatusBadRequest, problem.CodeInvalidEnvironment, "Invalid environment")
+                return "", nil, false
+        }
+
+        context := make(map[string]interface{})
+        for attr, value := range c.QueryMap("context") {
+                context[attr] = value
+        }
+        return environment, context, true
+}
+
+// Admin handlers
+func (h *Handler) RebuildDerivedData(c *gin.Context) {
+        if !h.authorizeAdmin(c) {
+                return
+        }
+
+        report := h.repo.RebuildDerivedData(c.Request.Context())
+        if !report.Success {
+                c.JSON(http.StatusInternalServerError, report)
+                return
+        }
+
+        c.JSON(http.StatusOK, report)
+}
+
+func (h *Handler) CreateEncryptionKey(c *gin.Context) {
+        if !h.authorizeAdmin(c) {
+                return
+        }
+
+        var req models.CreateEncryptionKeyRequest
+        if err := c.ShouldBindJSON(&req); err != nil {
+                problem.Respond(c, http.StatusBadRequest, problem.CodeInvalidRequest, err.Error())
+                return
+        }
+
+        node, err := h.repo.GetNodeByID(c.Request.Context(), req.NodeID)
+        if err != nil {
+                respondError(c, err, "Failed to validate node")
+                return
+        }
+        if node == nil {
+                problem.Respond(c, http.StatusNotFound, problem.CodeNodeNotFound, "Node not found")
                 return
         }
 
-        resolved, err := h.repo.ResolveConfiguration(nodeID)
+        key, err := h.repo.CreateEncryptionKey(c.Request.Context(), req.NodeID)
         if err != nil {
-                c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to resolve configuration"})
+                switch {
+                case errors.Is(err, database.ErrEncryptionKeyExists):
+                        problem.Respond(c, http.StatusConflict, problem.CodeConflict, err.Error())
+                case errors.Is(err, encryption.ErrNotConfigured):
+                        problem.Respond(c, http.StatusServiceUnavailable, problem.CodeEncryptionUnavailable, err.Error())
+                default:
+                        respondError(c, err, "Failed to create encryption key")
+                }
                 return
         }
 
-        c.JSON(http.StatusOK, resolved)
+        c.JSON(http.StatusCreated, key)
 }
 
-// Health check
-func (h *Handler) HealthCheck(c *gin.Context) {
-        c.JSON(http.StatusOK, gin.H{
-                "status":    "healthy",
-                "timestamp": "2024-12-06T09:14:00Z",
+func (h *Handler) GetEncryptionKeys(c *gin.Context) {
+        if !h.authorizeAdmin(c) {
+                return
+        }
+
+        keys, err := h.repo.GetEncryptionKeys(c.Request.Context())
+        if err != nil {
+                respondError(c, err, "Failed to get encryption keys")
+                return
+        }
+
+        c.JSON(http.StatusOK, keys)
+}
+
+func (h *Handler) CreateAPIKey(c *gin.Context) {
+        if !h.authorizeAdmin(c) {
+                return
+        }
+
+        var req models.CreateAPIKeyRequest
+        if err := c.ShouldBindJSON(&req); err != nil {
+                problem.Respond(c, http.StatusBadRequest, problem.CodeInvalidRequest, err.Error())
+                return
+        }
+
+        for _, scope := range req.Scopes {
+                if scope != models.APIKeyScopeResolve && scope != models.APIKeyScopeRead && scope != models.APIKeyScopeWrite {
+                        problem.Respond(c, http.StatusBadRequest, problem.CodeInvalidRequest, "scopes must be 'resolve', 'read' or 'write'")
+                        return
+                }
+        }
+        if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+                problem.Respond(c, http.StatusBadRequest, problem.CodeInvalidRequest, "expires_at must be in the future")
+                return
+        }
+
+        key, err := h.repo.CreateAPIKey(c.Request.Context(), req)
+        if err != nil {
+                respondError(c, err, "Failed to create API key")
+                return
+        }
+
+        c.JSON(http.StatusCreated, key)
+}
+
+func (h *Handler) GetAPIKeys(c *gin.Context) {
+        if !h.authorizeAdmin(c) {
+                return
+        }
+
+        keys, err := h.repo.GetAPIKeys(c.Request.Context())
+        if err != nil {
+                respondError(c, err, "Failed to get API keys")
+                return
+        }
+
+        c.JSON(http.StatusOK, keys)
+}
+
+func (h *Handler) RevokeAPIKey(c *gin.Context) {
+        if !h.authorizeAdmin(c) {
+                return
+        }
+
+        idStr := c.Param("id")
+        id, err := strconv.ParseInt(idStr, 10, 64)
+        if err != nil {
+                problem.Respond(c, http.StatusBadRequest, problem.CodeInvalidID, "Invalid API key ID")
+                return
+        }
+
+        err = h.repo.RevokeAPIKey(c.Request.Context(), id)
+        if err != nil {
+                if errors.Is(err, database.ErrAPIKeyNotFound) {
+                        problem.Respond(c, http.StatusNotFound, problem.CodeNotFound, "API key not found")
+                        return
+                }
+                respondError(c, err, "Failed to revoke API key")
+                return
+        }
+
+        c.JSON(http.StatusNoContent, nil)
+}
+
+// defaultRotationGracePeriod is how long the secret replaced by a rotation
+// stays valid when the request does not say
+const defaultRotationGracePeriod = 24 * time.Hour
+
+// RotateAPIKey issues a new secret for a key, keeping the old one valid for a
+// grace period given as a duration such as 24h
+func (h *Handler) RotateAPIKey(c *gin.Context) {
+        if !h.authorizeAdmin(c) {
+                return
+        }
+
+        id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+        if err != nil {
+                problem.Respond(c, http.StatusBadRequest, problem.CodeInvalidID, "Invalid API key ID")
+                return
+        }
+
+        // The body is optional
+        var req models.RotateAPIKeyRequest
+        if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+                problem.Respond(c, http.StatusBadRequest, problem.CodeInvalidRequest, err.Error())
+                return
+        }
+
+        grace := defaultRotationGracePeriod
+        if req.GracePeriod != "" {
+                if grace, err = time.ParseDuration(req.GracePeriod); err != nil || grace < 0 {
+                        problem.Respond(c, http.StatusBadRequest, problem.CodeInvalidRequest, "grace_period must be a positive duration such as 24h")
+                        return
+                }
+        }
+        if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+                problem.Respond(c, http.StatusBadRequest, problem.CodeInvalidRequest, "expires_at must be in the future")
+                return
+        }
+
+        key, err := h.repo.RotateAPIKey(c.Request.Context(), id, grace, req.ExpiresAt)
+        if err != nil {
+                respondError(c, err, "Failed to rotate API key")
+                return
+        }
+
+        c.JSON(http.StatusOK, key)
+}
+
+// isEncryptionSetupError reports whether err is caused by missing encryption
+// configuration rather than a server failure
+func isEncryptionSetupError(err error) bool {
+        return errors.Is(err, encryption.ErrNotConfigured) || errors.Is(err, database.ErrNoEncryptionKey)
+}
+
+// Health checks
+const healthCheckTimeout = 2 * time.Second
+
+// componentCheck reports a backing component as down when ping fails
+type componentCheck struct {
+        name string
+        ping func(context.Context) error
+}
+
+// UseHealthCheck adds a component to the liveness and readiness probes, next
+// to the database, e.g. a replica or the cache invalidation listener
+func (h *Handler) UseHealthCheck(name string, ping func(context.Context) error) {
+        h.checks = append(h.checks, componentCheck{name: name, ping: ping})
+}
+
+// checkComponents pings every backing component and reports its status and latency
+func (h *Handler) checkComponents(ctx context.Context) ([]models.ComponentStatus, bool) {
+        checks := append([]componentCheck{{name: "database", ping: h.repo.Ping}}, h.checks...)
+
+        healthy := true
+        components := make([]models.ComponentStatus, 0, len(checks))
+        for _, check := range checks {
+                ctx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+                start := time.Now()
+                err := check.ping(ctx)
+                cancel()
+
+                component := models.ComponentStatus{
+                        Name:      check.name,
+                        Status:    "up",
+                        LatencyMs: float64(time.Since(start).Microseconds()) / 1000,
+                }
+                if err != nil {
+                        component.Status = "down"
+                        component.Error = err.Error()
+                        healthy = false
+                }
+                components = append(components, component)
+        }
+
+        return components, healthy
+}
+
+// Liveness reports whether the process is running. Component failures are
+// reported but do not fail the probe, so an outage of a dependency does not
+// cause the orchestrator to restart healthy processes.
+func (h *Handler) Liveness(c *gin.Context) {
+        components, healthy := h.checkComponents(c.Request.Context())
+
+        status := "healthy"
+        if !healthy {
+                status = "degraded"
+        }
+
+        c.JSON(http.StatusOK, models.HealthStatus{
+                Status:     status,
+                Timestamp:  time.Now().UTC(),
+                Components: components,
+        })
+}
+
+// Readiness reports whether the process can serve traffic, failing with 503
+// when any component is unavailable
+func (h *Handler) Readiness(c *gin.Context) {
+        components, healthy := h.checkComponents(c.Request.Context())
+
+        if !healthy {
+                c.JSON(http.StatusServiceUnavailable, models.HealthStatus{
+                        Status:     "unavailable",
+                        Timestamp:  time.Now().UTC(),
+                        Components: components,
+                })
+                return
+        }
+
+        c.JSON(http.StatusOK, models.HealthStatus{
+                Status:     "ready",
+                Timestamp:  time.Now().UTC(),
+                Components: components,
         })
 }
\ No newline at end of file
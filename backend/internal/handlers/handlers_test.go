@@ -0,0 +1,281 @@
+package handlers
+
+import (
+        "config-manager/internal/auth"
+        "config-manager/internal/database"
+        "config-manager/internal/models"
+        "encoding/json"
+        "net/http"
+        "net/http/httptest"
+        "strconv"
+        "strings"
+        "testing"
+
+        "github.com/gin-gonic/gin"
+)
+
+func init() {
+        gin.SetMode(gin.TestMode)
+}
+
+// newTestRouter serves the node, property and resolve routes from an
+// in-memory repository, without access control
+func newTestRouter() *gin.Engine {
+        repo := database.NewMemoryRepository()
+        h := NewHandler(repo, auth.NewACL(repo, false, nil, false), 0, nil, false)
+
+        r := gin.New()
+        r.POST("/api/nodes", h.CreateNode)
+        r.GET("/api/nodes/:nodeId", h.GetNode)
+        r.PUT("/api/nodes/:nodeId", h.UpdateNode)
+        r.DELETE("/api/nodes/:nodeId", h.DeleteNode)
+        r.GET("/api/nodes/:nodeId/resolve", h.ResolveConfiguration)
+        r.POST("/api/nodes/:nodeId/properties", h.CreateProperty)
+        r.GET("/api/nodes/:nodeId/properties", h.GetNodeProperties)
+        r.PUT("/api/properties/:propertyId", h.UpdateProperty)
+        r.DELETE("/api/properties/:propertyId", h.DeleteProperty)
+        return r
+}
+
+// send makes a JSON request and decodes the response body into out, unless
+// out is nil or the status is not want
+func send(t *testing.T, r *gin.Engine, method, path, body string, want int, out interface{}) {
+        t.Helper()
+
+        req := httptest.NewRequest(method, path, strings.NewReader(body))
+        req.Header.Set("Content-Type", "application/json")
+        w := httptest.NewRecorder()
+        r.ServeHTTP(w, req)
+
+        if w.Code != want {
+                t.Fatalf("%s %s: got %d, want %d: %s", method, path, w.Code, want, w.Body.String())
+        }
+        if out != nil {
+                if err := json.Unmarshal(w.Body.Bytes(), out); err != nil {
+                        t.Fatalf("%s %s: decoding %s: %v", method, path, w.Body.String(), err)
+                }
+        }
+}
+
+func itoa(id int64) string {
+        return strconv.FormatInt(id, 10)
+}
+
+func createNode(t *testing.T, r *gin.Engine, body string) models.ConfigNode {
+        t.Helper()
+        var node models.ConfigNode
+        send(t, r, http.MethodPost, "/api/nodes", body, http.StatusCreated, &node)
+        return node
+}
+
+func createProperty(t *testing.T, r *gin.Engine, nodeID int64, body string) models.ConfigProperty {
+        t.Helper()
+        var prop models.ConfigProperty
+        send(t, r, http.MethodPost, "/api/nodes/"+itoa(nodeID)+"/properties", body, http.StatusCreated, &prop)
+        return prop
+}
+
+func resolve(t *testing.T, r *gin.Engine, nodeID int64, query string) models.ResolvedConfiguration {
+        t.Helper()
+        var resolved models.ResolvedConfiguration
+        send(t, r, http.MethodGet, "/api/nodes/"+itoa(nodeID)+"/resolve"+query, "", http.StatusOK, &resolved)
+        return resolved
+}
+
+func TestNodeCRUD(t *testing.T) {
+        r := newTestRouter()
+
+        root := createNode(t, r, `{"name": "emea", "nodeType": "territory"}`)
+        child := createNode(t, r, `{"name": "berlin", "nodeType": "center", "parentId": `+itoa(root.ID)+`}`)
+        if child.ParentID == nil || *child.ParentID != root.ID {
+                t.Fatalf("child parent: got %v, want %d", child.ParentID, root.ID)
+        }
+
+        var fetched models.ConfigNode
+        send(t, r, http.MethodGet, "/api/nodes/"+itoa(child.ID), "", http.StatusOK, &fetched)
+        if fetched.Name != "berlin" {
+                t.Errorf("fetched name: got %q, want berlin", fetched.Name)
+        }
+
+        var updated models.ConfigNode
+        send(t, r, http.MethodPut, "/api/nodes/"+itoa(child.ID), `{"name": "munich", "description": "HQ"}`, http.StatusOK, &updated)
+        if updated.Name != "munich" || updated.Description != "HQ" {
+                t.Errorf("updated node: got %q %q, want munich HQ", updated.Name, updated.Description)
+        }
+
+        send(t, r, http.MethodDelete, "/api/nodes/"+itoa(child.ID), "", http.StatusNoContent, nil)
+        send(t, r, http.MethodGet, "/api/nodes/"+itoa(child.ID), "", http.StatusNotFound, nil)
+        send(t, r, http.MethodGet, "/api/nodes/"+itoa(root.ID), "", http.StatusOK, nil)
+}
+
+func TestCreateNodeErrors(t *testing.T) {
+        r := newTestRouter()
+        root := createNode(t, r, `{"name": "emea", "nodeType": "territory"}`)
+
+        tests := []struct {
+                name string
+                body string
+                want int
+        }{
+                {"missing name", `{"nodeType": "territory"}`, http.StatusBadRequest},
+                {"unknown type", `{"name": "x", "nodeType": "planet"}`, http.StatusBadRequest},
+                {"unknown parent", `{"name": "x", "nodeType": "center", "parentId": 999}`, http.StatusBadRequest},
+                {"sibling name taken", `{"name": "EMEA", "nodeType": "territory"}`, http.StatusConflict},
+                {"malformed JSON", `{"name": `, http.StatusBadRequest},
+        }
+        for _, tt := range tests {
+                t.Run(tt.name, func(t *testing.T) {
+                        send(t, r, http.MethodPost, "/api/nodes", tt.body, tt.want, nil)
+                })
+        }
+
+        send(t, r, http.MethodGet, "/api/nodes/abc", "", http.StatusBadRequest, nil)
+        send(t, r, http.MethodGet, "/api/nodes/"+itoa(root.ID+100), "", http.StatusNotFound, nil)
+}
+
+func TestPropertyCRUD(t *testing.T) {
+        r := newTestRouter()
+        node := createNode(t, r, `{"name": "emea", "nodeType": "territory"}`)
+
+        prop := createProperty(t, r, node.ID, `{"key": "timeout", "value": "30", "data_type": "number"}`)
+        if prop.NodeID != node.ID || prop.Key != "timeout" || prop.Value != "30" {
+                t.Fatalf("created property: got %+v", prop)
+        }
+
+        var listed []models.ConfigProperty
+        send(t, r, http.MethodGet, "/api/nodes/"+itoa(node.ID)+"/properties", "", http.StatusOK, &listed)
+        if len(listed) != 1 || listed[0].ID != prop.ID {
+                t.Fatalf("listed properties: got %+v", listed)
+        }
+
+        var updated models.ConfigProperty
+        send(t, r, http.MethodPut, "/api/properties/"+itoa(prop.ID), `{"value": "45"}`, http.StatusOK, &updated)
+        if updated.Value != "45" {
+                t.Errorf("updated value: got %s, want 45", updated.Value)
+        }
+
+        send(t, r, http.MethodDelete, "/api/properties/"+itoa(prop.ID), "", http.StatusNoContent, nil)
+        send(t, r, http.MethodGet, "/api/nodes/"+itoa(node.ID)+"/properties", "", http.StatusOK, &listed)
+        if len(listed) != 0 {
+                t.Errorf("properties after delete: got %+v", listed)
+        }
+        send(t, r, http.MethodDelete, "/api/properties/"+itoa(prop.ID), "", http.StatusNotFound, nil)
+        send(t, r, http.MethodPut, "/api/properties/"+itoa(prop.ID), `{"value": "1"}`, http.StatusNotFound, nil)
+}
+
+func TestCreatePropertyErrors(t *testing.T) {
+        r := newTestRouter()
+        node := createNode(t, r, `{"name": "emea", "nodeType": "territory"}`)
+        createProperty(t, r, node.ID, `{"key": "base_url", "value": "\"https://api.example.com\"", "data_type": "string"}`)
+        createProperty(t, r, node.ID, `{"key": "orders_url", "value": "\"${base_url}/orders\"", "data_type": "string"}`)
+
+        tests := []struct {
+                name string
+                body string
+                want int
+        }{
+                {"missing data type", `{"key": "a", "value": "1"}`, http.StatusBadRequest},
+                {"value not JSON", `{"key": "a", "value": "x", "data_type": "string"}`, http.StatusBadRequest},
+                {"unknown data type", `{"key": "a", "value": "1", "data_type": "decimal"}`, http.StatusBadRequest},
+                {"invalid environment", `{"key": "a", "value": "1", "data_type": "number", "environment": "Prod!"}`, http.StatusBadRequest},
+                {"unknown reference", `{"key": "a", "value": "\"${nope}\"", "data_type": "string"}`, http.StatusUnprocessableEntity},
+                {"unterminated placeholder", `{"key": "a", "value": "\"${base_url\"", "data_type": "string"}`, http.StatusUnprocessableEntity},
+                {"reference to itself", `{"key": "a", "value": "\"${a}\"", "data_type": "string"}`, http.StatusUnprocessableEntity},
+                {"escaped placeholder", `{"key": "a", "value": "\"$${HOME}\"", "data_type": "string"}`, http.StatusCreated},
+        }
+        for _, tt := range tests {
+                t.Run(tt.name, func(t *testing.T) {
+                        send(t, r, http.MethodPost, "/api/nodes/"+itoa(node.ID)+"/properties", tt.body, tt.want, nil)
+                })
+        }
+
+        send(t, r, http.MethodPost, "/api/nodes/999/properties", `{"key": "a", "value": "1", "data_type": "number"}`, http.StatusNotFound, nil)
+}
+
+func TestUpdatePropertyRejectsCycle(t *testing.T) {
+        r := newTestRouter()
+        node := createNode(t, r, `{"name": "emea", "nodeType": "territory"}`)
+        host := createProperty(t, r, node.ID, `{"key": "host", "value": "\"db\"", "data_type": "string"}`)
+        createProperty(t, r, node.ID, `{"key": "dsn", "value": "\"postgres://${host}\"", "data_type": "string"}`)
+
+        var failure struct {
+                Code string `json:"code"`
+        }
+        send(t, r, http.MethodPut, "/api/properties/"+itoa(host.ID), `{"value": "\"${dsn}\""}`, http.StatusUnprocessableEntity, &failure)
+        if failure.Code != "INTERPOLATION_FAILED" {
+                t.Errorf("code: got %s, want INTERPOLATION_FAILED", failure.Code)
+        }
+        send(t, r, http.MethodPut, "/api/properties/"+itoa(host.ID)+"?dryRun=true", `{"value": "\"${dsn}\""}`, http.StatusUnprocessableEntity, nil)
+
+        if got := resolve(t, r, node.ID, "").Properties["dsn"]; got != "postgres://db" {
+                t.Errorf("dsn after rejected update: got %v", got)
+        }
+}
+
+func TestResolveConfiguration(t *testing.T) {
+        r := newTestRouter()
+        root := createNode(t, r, `{"name": "emea", "nodeType": "territory"}`)
+        child := createNode(t, r, `{"name": "berlin", "nodeType": "center", "parentId": `+itoa(root.ID)+`}`)
+
+        createProperty(t, r, root.ID, `{"key": "timeout", "value": "30", "data_type": "number"}`)
+        createProperty(t, r, root.ID, `{"key": "region", "value": "\"eu\"", "data_type": "string"}`)
+        createProperty(t, r, root.ID, `{"key": "endpoint", "value": "\"https://${region}.example.com\"", "data_type": "string"}`)
+        createProperty(t, r, child.ID, `{"key": "timeout", "value": "60", "data_type": "number"}`)
+        createProperty(t, r, child.ID, `{"key": "region", "value": "\"eu-central\"", "data_type": "string", "environment": "prod"}`)
+
+        tests := []struct {
+                name   string
+                nodeID int64
+                query  string
+                want   map[string]interface{}
+        }{
+                {"root", root.ID, "", map[string]interface{}{"timeout": 30.0, "region": "eu", "endpoint": "https://eu.example.com"}},
+                {"child overrides", child.ID, "", map[string]interface{}{"timeout": 60.0, "region": "eu", "endpoint": "https://eu.example.com"}},
+                {"environment override", child.ID, "?env=prod", map[string]interface{}{"timeout": 60.0, "region": "eu-central", "endpoint": "https://eu-central.example.com"}},
+        }
+        for _, tt := range tests {
+                t.Run(tt.name, func(t *testing.T) {
+                        resolved := resolve(t, r, tt.nodeID, tt.query)
+                        if len(resolved.Properties) != len(tt.want) {
+                                t.Errorf("got %v, want %v", resolved.Properties, tt.want)
+                        }
+                        for key, want := range tt.want {
+                                if got := resolved.Properties[key]; got != want {
+                                        t.Errorf("%s: got %v, want %v", key, got, want)
+                                }
+                        }
+                        if len(resolved.Path) == 0 || resolved.Path[len(resolved.Path)-1].ID != tt.nodeID {
+                                t.Errorf("path does not end at node %d: %+v", tt.nodeID, resolved.Path)
+                        }
+                })
+        }
+
+        if resolve(t, r, root.ID, "").Checksum != resolve(t, r, root.ID, "").Checksum {
+                t.Error("checksum of an unchanged configuration changed")
+        }
+
+        send(t, r, http.MethodGet, "/api/nodes/999/resolve", "", http.StatusNotFound, nil)
+        send(t, r, http.MethodGet, "/api/nodes/x/resolve", "", http.StatusBadRequest, nil)
+}
+
+func TestResolveReportsBrokenKeys(t *testing.T) {
+        r := newTestRouter()
+        node := createNode(t, r, `{"name": "emea", "nodeType": "territory"}`)
+        region := createProperty(t, r, node.ID, `{"key": "region", "value": "\"eu\"", "data_type": "string"}`)
+        createProperty(t, r, node.ID, `{"key": "endpoint", "value": "\"https://${region}.example.com\"", "data_type": "string"}`)
+        createProperty(t, r, node.ID, `{"key": "timeout", "value": "30", "data_type": "number"}`)
+
+        // Deleting a referenced key breaks the reference only
+        send(t, r, http.MethodDelete, "/api/properties/"+itoa(region.ID), "", http.StatusNoContent, nil)
+
+        resolved := resolve(t, r, node.ID, "")
+        if _, ok := resolved.Properties["endpoint"]; ok {
+                t.Errorf("broken endpoint was resolved: %v", resolved.Properties)
+        }
+        if resolved.Properties["timeout"] != 30.0 {
+                t.Errorf("timeout: got %v, want 30", resolved.Properties["timeout"])
+        }
+        if len(resolved.Warnings) != 1 || resolved.Warnings[0].Code != "interpolation_error" || resolved.Warnings[0].Key != "endpoint" {
+                t.Errorf("warnings: got %+v, want an interpolation_error for endpoint", resolved.Warnings)
+        }
+}
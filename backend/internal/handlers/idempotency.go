@@ -0,0 +1,99 @@
+package handlers
+
+import (
+        "config-manager/internal/auth"
+        "config-manager/internal/database"
+        "config-manager/internal/problem"
+        "bytes"
+        "crypto/sha256"
+        "encoding/hex"
+        "io"
+        "log"
+        "net/http"
+        "time"
+
+        "github.com/gin-gonic/gin"
+)
+
+// IdempotencyKeyHeader is the header clients set to make a POST safe to retry
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// idempotencyAbandonAfter is how long a request may hold its idempotency key
+// before a retry may take it over, in case the server died while running it
+const idempotencyAbandonAfter = 5 * time.Minute
+
+// Idempotency makes POST requests sent with an Idempotency-Key header safe to
+// retry. The first request with a key runs and its response is stored; a retry
+// with the same key, method, path and body gets the stored response back
+// instead of running again, marked with an Idempotent-Replayed header. Keys are
+// scoped to the caller and expire after ttl. Responses with a 5xx status are
+// not stored, so that a retry runs the request again.
+func Idempotency(repo database.ConfigRepository, ttl time.Duration) gin.HandlerFunc {
+        return func(c *gin.Context) {
+                key := c.GetHeader(IdempotencyKeyHeader)
+                if key == "" || c.Request.Method != http.MethodPost {
+                        c.Next()
+                        return
+                }
+                if len(key) > 255 {
+                        problem.Abort(c, http.StatusBadRequest, problem.CodeInvalidRequest, IdempotencyKeyHeader+" must be at most 255 characters")
+                        return
+                }
+
+                body, err := io.ReadAll(c.Request.Body)
+                if err != nil {
+                        problem.Abort(c, http.StatusBadRequest, problem.CodeInvalidRequest, "Failed to read request body")
+                        return
+                }
+                c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+                hash := sha256.New()
+                hash.Write([]byte(c.Request.Method + " " + c.Request.URL.RequestURI() + "\n"))
+                hash.Write(body)
+                requestHash := hex.EncodeToString(hash.Sum(nil))
+
+                principal := auth.Identity(c)
+                now := time.Now()
+                record, err := repo.ReserveIdempotencyKey(c.Request.Context(), principal, key, requestHash, now.Add(-ttl), now.Add(-idempotencyAbandonAfter))
+                if err != nil {
+                        respondError(c, err, "Failed to check idempotency key")
+                        c.Abort()
+                        return
+                }
+                if record != nil {
+                        switch {
+                        case record.RequestHash != requestHash:
+                                problem.Abort(c, http.StatusUnprocessableEntity, problem.CodeIdempotencyKeyReused, IdempotencyKeyHeader+" was already used for a different request")
+                        case record.StatusCode == 0:
+                                problem.Abort(c, http.StatusConflict, problem.CodeRequestInProgress, "A request with this "+IdempotencyKeyHeader+" is still in progress")
+                        default:
+                                c.Header("Idempotent-Replayed", "true")
+                                c.Data(record.StatusCode, record.ContentType, record.Body)
+                                c.Abort()
+                        }
+                        return
+                }
+
+                defer func() {
+                        if recovered := recover(); recovered != nil {
+                                repo.ReleaseIdempotencyKey(c.Request.Context(), principal, key)
+                                panic(recovered)
+                        }
+                }()
+
+                writer := &bufferedWriter{ResponseWriter: c.Writer}
+                c.Writer = writer
+                c.Next()
+                c.Writer = writer.ResponseWriter
+
+                if writer.Status() >= http.StatusInternalServerError {
+                        err = repo.ReleaseIdempotencyKey(c.Request.Context(), principal, key)
+                } else {
+                        err = repo.CompleteIdempotencyKey(c.Request.Context(), principal, key, writer.Status(), writer.Header().Get("Content-Type"), writer.body.Bytes())
+                }
+                if err != nil {
+                        log.Printf("Failed to store the response for idempotency key %q: %v", key, err)
+                }
+                writer.ResponseWriter.Write(writer.body.Bytes())
+        }
+}
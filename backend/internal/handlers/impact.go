@@ -0,0 +1,59 @@
+package handlers
+
+import (
+        "config-manager/internal/database"
+        "config-manager/internal/models"
+        "config-manager/internal/problem"
+        "errors"
+        "net/http"
+        "strconv"
+
+        "github.com/gin-gonic/gin"
+)
+
+// AnalyzeImpact lists the leaves below a node whose value of a key would
+// change, and to what, if the node's property were set as in the body, which
+// takes the fields of a draft, or deleted with "delete": true
+func (h *Handler) AnalyzeImpact(c *gin.Context) {
+        nodeIDStr := c.Param("nodeId")
+        nodeID, err := strconv.ParseInt(nodeIDStr, 10, 64)
+        if err != nil {
+                problem.Respond(c, http.StatusBadRequest, problem.CodeInvalidID, "Invalid node ID")
+                return
+        }
+
+        if !h.authorize(c, nodeID, models.PermissionRead) {
+                return
+        }
+
+        var req models.SavePropertyDraftRequest
+        if err := c.ShouldBindJSON(&req); err != nil {
+                problem.Respond(c, http.StatusBadRequest, problem.CodeInvalidRequest, err.Error())
+                return
+        }
+        if err := validateDraft(req); err != nil {
+                respondInvalid(c, err)
+                return
+        }
+
+        report, err := h.repo.AnalyzeImpact(c.Request.Context(), models.PropertyDraft{
+                NodeID:       nodeID,
+                Key:          req.Key,
+                Environment:  req.Environment,
+                Value:        req.Value,
+                DataType:     req.DataType,
+                DefaultValue: req.DefaultValue,
+                Description:  req.Description,
+                Delete:       req.Delete,
+        })
+        if errors.Is(err, database.ErrNodeNotFound) {
+                problem.Respond(c, http.StatusNotFound, problem.CodeNodeNotFound, "Node not found")
+                return
+        }
+        if err != nil {
+                respondError(c, err, "Failed to analyze impact")
+                return
+        }
+
+        c.JSON(http.StatusOK, report)
+}
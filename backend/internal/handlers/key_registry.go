@@ -0,0 +1,121 @@
+package handlers
+
+import (
+        "config-manager/internal/auth"
+        "config-manager/internal/models"
+        "config-manager/internal/problem"
+        "net/http"
+
+        "github.com/gin-gonic/gin"
+)
+
+// Key registry handlers
+func (h *Handler) RegisterKey(c *gin.Context) {
+        if !h.authorizeAdmin(c) {
+                return
+        }
+
+        var req models.RegisterKeyRequest
+        if err := c.ShouldBindJSON(&req); err != nil {
+                problem.Respond(c, http.StatusBadRequest, problem.CodeInvalidRequest, err.Error())
+                return
+        }
+        if len(req.Key) > 255 {
+                problem.Respond(c, http.StatusBadRequest, problem.CodeInvalidRequest, "key must be at most 255 characters")
+                return
+        }
+        if req.ExpectedType != nil && !validDataTypes[*req.ExpectedType] {
+                problem.Respond(c, http.StatusBadRequest, problem.CodeInvalidDataType, "Invalid expected type")
+                return
+        }
+
+        registered, err := h.repo.RegisterKey(c.Request.Context(), req, auth.Identity(c))
+        if err != nil {
+                respondError(c, err, "Failed to register key")
+                return
+        }
+
+        c.JSON(http.StatusOK, registered)
+}
+
+// GetKeyCatalog lists the registered keys and the keys properties define,
+// with their usage. ?registered=false only lists keys defined without being
+// registered, ?registered=true only registered keys.
+func (h *Handler) GetKeyCatalog(c *gin.Context) {
+        filter := c.Query("registered")
+        if filter != "" && filter != "true" && filter != "false" {
+                problem.Respond(c, http.StatusBadRequest, problem.CodeInvalidRequest, "registered must be true or false")
+                return
+        }
+
+        catalog, err := h.repo.GetKeyCatalog(c.Request.Context())
+        if err != nil {
+                respondError(c, err, "Failed to get keys")
+                return
+        }
+
+        if filter != "" {
+                filtered := []models.KeyCatalogEntry{}
+                for _, entry := range catalog {
+                        if (entry.Registration != nil) == (filter == "true") {
+                                filtered = append(filtered, entry)
+                        }
+                }
+                catalog = filtered
+        }
+
+        c.JSON(http.StatusOK, catalog)
+}
+
+func (h *Handler) GetRegisteredKey(c *gin.Context) {
+        registered, err := h.repo.GetRegisteredKey(c.Request.Context(), c.Param("key"))
+        if err != nil {
+                respondError(c, err, "Failed to get key")
+                return
+        }
+        if registered == nil {
+                problem.Respond(c, http.StatusNotFound, problem.CodeKeyNotFound, "Key is not registered")
+                return
+        }
+
+        c.JSON(http.StatusOK, registered)
+}
+
+func (h *Handler) UnregisterKey(c *gin.Context) {
+        if !h.authorizeAdmin(c) {
+                return
+        }
+
+        removed, err := h.repo.UnregisterKey(c.Request.Context(), c.Param("key"))
+        if err != nil {
+                respondError(c, err, "Failed to unregister key")
+                return
+        }
+        if !removed {
+                problem.Respond(c, http.StatusNotFound, problem.CodeKeyNotFound, "Key is not registered")
+                return
+        }
+
+        c.JSON(http.StatusNoContent, nil)
+}
+
+// guardRegisteredKeys writes an error response and returns false if keys are
+// required to be registered and some of keys are not
+func (h *Handler) guardRegisteredKeys(c *gin.Context, keys ...string) bool {
+        if !h.requireRegisteredKeys || len(keys) == 0 {
+                return true
+        }
+
+        unregistered, err := h.repo.UnregisteredKeys(c.Request.Context(), keys)
+        if err != nil {
+                respondError(c, err, "Failed to check the key registry")
+                return false
+        }
+        if len(unregistered) > 0 {
+                problem.Respond(c, http.StatusBadRequest, problem.CodeKeyNotRegistered, "Keys must be registered before they are defined", gin.H{
+                        "unregistered_keys": unregistered,
+                })
+                return false
+        }
+        return true
+}
@@ -0,0 +1,133 @@
+package handlers
+
+import (
+        "config-manager/internal/auth"
+        "config-manager/internal/models"
+        "config-manager/internal/problem"
+        "errors"
+        "io"
+        "net/http"
+        "strconv"
+        "time"
+
+        "github.com/gin-gonic/gin"
+)
+
+// RotateEncryptionKey schedules the replacement of a subtree key and the
+// re-encryption of the properties encrypted with it, now or at scheduled_at.
+// The job runs in the background; its progress is read from the rotation.
+func (h *Handler) RotateEncryptionKey(c *gin.Context) {
+        if !h.authorizeAdmin(c) {
+                return
+        }
+
+        keyID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+        if err != nil {
+                problem.Respond(c, http.StatusBadRequest, problem.CodeInvalidID, "Invalid encryption key ID")
+                return
+        }
+
+        // The body is optional, rotating right away
+        var req models.CreateKeyRotationRequest
+        if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+                problem.Respond(c, http.StatusBadRequest, problem.CodeInvalidRequest, err.Error())
+                return
+        }
+
+        scheduledAt := time.Now()
+        if req.ScheduledAt != nil {
+                if req.ScheduledAt.Before(scheduledAt) {
+                        problem.Respond(c, http.StatusBadRequest, problem.CodeInvalidRequest, "scheduled_at must be in the future")
+                        return
+                }
+                scheduledAt = *req.ScheduledAt
+        }
+
+        rotation, err := h.repo.CreateKeyRotation(c.Request.Context(), keyID, scheduledAt, auth.Identity(c))
+        if err != nil {
+                respondError(c, err, "Failed to rotate encryption key")
+                return
+        }
+
+        c.JSON(http.StatusAccepted, rotation)
+}
+
+func (h *Handler) GetKeyRotations(c *gin.Context) {
+        if !h.authorizeAdmin(c) {
+                return
+        }
+
+        rotations, err := h.repo.GetKeyRotations(c.Request.Context())
+        if err != nil {
+                respondError(c, err, "Failed to get key rotations")
+                return
+        }
+
+        c.JSON(http.StatusOK, rotations)
+}
+
+func (h *Handler) GetKeyRotation(c *gin.Context) {
+        if !h.authorizeAdmin(c) {
+                return
+        }
+
+        id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+        if err != nil {
+                problem.Respond(c, http.StatusBadRequest, problem.CodeInvalidID, "Invalid key rotation ID")
+                return
+        }
+
+        rotation, err := h.repo.GetKeyRotation(c.Request.Context(), id)
+        if err != nil {
+                respondError(c, err, "Failed to get key rotation")
+                return
+        }
+        if rotation == nil {
+                problem.Respond(c, http.StatusNotFound, problem.CodeNotFound, "Key rotation not found")
+                return
+        }
+
+        c.JSON(http.StatusOK, rotation)
+}
+
+// CancelKeyRotation cancels a rotation that has not started
+func (h *Handler) CancelKeyRotation(c *gin.Context) {
+        if !h.authorizeAdmin(c) {
+                return
+        }
+
+        id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+        if err != nil {
+                problem.Respond(c, http.StatusBadRequest, problem.CodeInvalidID, "Invalid key rotation ID")
+                return
+        }
+
+        if err := h.repo.CancelKeyRotation(c.Request.Context(), id); err != nil {
+                respondError(c, err, "Failed to cancel key rotation")
+                return
+        }
+
+        c.JSON(http.StatusNoContent, nil)
+}
+
+// ResumeKeyRotation restarts a failed rotation from where it stopped, once
+// the cause of the failure, e.g. an unreachable key provider, is fixed
+func (h *Handler) ResumeKeyRotation(c *gin.Context) {
+        if !h.authorizeAdmin(c) {
+                return
+        }
+
+        id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+        if err != nil {
+                problem.Respond(c, http.StatusBadRequest, problem.CodeInvalidID, "Invalid key rotation ID")
+                return
+        }
+
+        rotation, err := h.repo.ResumeKeyRotation(c.Request.Context(), id)
+        if err != nil {
+                respondError(c, err, "Failed to resume key rotation")
+                return
+        }
+
+        c.JSON(http.StatusAccepted, rotation)
+}
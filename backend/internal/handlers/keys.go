@@ -0,0 +1,101 @@
+package handlers
+
+import (
+        "config-manager/internal/auth"
+        "config-manager/internal/database"
+        "config-manager/internal/models"
+        "config-manager/internal/problem"
+        "errors"
+        "net/http"
+        "strconv"
+
+        "github.com/gin-gonic/gin"
+)
+
+// RenameKey renames a key on every node defining it in one transaction, so
+// that clients switch over from one resolution to the next
+func (h *Handler) RenameKey(c *gin.Context) {
+        if !h.authorizeAdmin(c) {
+                return
+        }
+
+        oldKey := c.Param("key")
+        var req models.RenameKeyRequest
+        if err := c.ShouldBindJSON(&req); err != nil {
+                problem.Respond(c, http.StatusBadRequest, problem.CodeInvalidRequest, err.Error())
+                return
+        }
+        if len(req.NewKey) > 255 {
+                problem.Respond(c, http.StatusBadRequest, problem.CodeInvalidRequest, "new_key must be at most 255 characters")
+                return
+        }
+        if req.NewKey == oldKey {
+                problem.Respond(c, http.StatusBadRequest, problem.CodeInvalidRequest, "new_key must differ from the key being renamed")
+                return
+        }
+
+        if !h.guardRegisteredKeys(c, req.NewKey) {
+                return
+        }
+
+        required, err := h.repo.RequiredApprovalsForKey(c.Request.Context(), oldKey)
+        if err != nil {
+                respondError(c, err, "Failed to check subtree protection")
+                return
+        }
+        if required > 0 {
+                problem.Respond(c, http.StatusForbidden, problem.CodeSubtreeProtected, "The key is defined in a protected subtree; changes require an approved change request", gin.H{
+                        "required_approvals": required,
+                })
+                return
+        }
+
+        result, err := h.repo.RenameKey(c.Request.Context(), oldKey, req.NewKey, auth.Identity(c))
+        var conflictErr *database.KeyRenameConflictError
+        if errors.As(err, &conflictErr) {
+                problem.Respond(c, http.StatusConflict, problem.CodeKeyConflict, err.Error(), gin.H{"node_ids": conflictErr.NodeIDs})
+                return
+        }
+        if errors.Is(err, database.ErrKeyNotDefined) {
+                problem.Respond(c, http.StatusNotFound, problem.CodeKeyNotFound, "No node defines this key")
+                return
+        }
+        if err != nil {
+                respondError(c, err, "Failed to rename key")
+                return
+        }
+
+        c.JSON(http.StatusOK, result)
+}
+
+// GetKeyInventory lists every key defined within the subtree of a node, with
+// how often and where it is defined
+func (h *Handler) GetKeyInventory(c *gin.Context) {
+        nodeID, err := strconv.ParseInt(c.Param("nodeId"), 10, 64)
+        if err != nil {
+                problem.Respond(c, http.StatusBadRequest, problem.CodeInvalidID, "Invalid node ID")
+                return
+        }
+
+        if !h.authorize(c, nodeID, models.PermissionRead) {
+                return
+        }
+
+        node, err := h.repo.GetNodeByID(c.Request.Context(), nodeID)
+        if err != nil {
+                respondError(c, err, "Failed to get node")
+                return
+        }
+        if node == nil {
+                problem.Respond(c, http.StatusNotFound, problem.CodeNodeNotFound, "Node not found")
+                return
+        }
+
+        inventory, err := h.repo.GetKeyInventory(c.Request.Context(), nodeID)
+        if err != nil {
+                respondError(c, err, "Failed to get keys")
+                return
+        }
+
+        c.JSON(http.StatusOK, inventory)
+}
@@ -0,0 +1,85 @@
+package handlers
+
+import (
+        "config-manager/internal/models"
+        "config-manager/internal/problem"
+        "fmt"
+        "net/http"
+        "regexp"
+        "strings"
+
+        "github.com/gin-gonic/gin"
+)
+
+// labelKeyPattern restricts label keys to lowercase names such as "tier" or "team.io/owner"
+var labelKeyPattern = regexp.MustCompile(`^[a-z0-9]([a-z0-9._/-]{0,61}[a-z0-9])?$`)
+
+const (
+        maxLabels          = 64
+        maxLabelValueBytes = 255
+)
+
+// validateLabels checks the labels a node is created or updated with
+func validateLabels(labels map[string]string) error {
+        if len(labels) > maxLabels {
+                return fmt.Errorf("a node may have at most %d labels", maxLabels)
+        }
+        for key, value := range labels {
+                if !labelKeyPattern.MatchString(key) {
+                        return fmt.Errorf("invalid label key %q", key)
+                }
+                if len(value) > maxLabelValueBytes {
+                        return fmt.Errorf("value of label %q exceeds %d bytes", key, maxLabelValueBytes)
+                }
+        }
+        return nil
+}
+
+// parseLabelSelectors splits ?label= selectors into the labels that must have
+// a given value ("tier=gold") and the labels that must merely be present ("tier")
+func parseLabelSelectors(selectors []string) (map[string]string, []string, error) {
+        match := make(map[string]string)
+        var keys []string
+        for _, selector := range selectors {
+                key, value, hasValue := strings.Cut(selector, "=")
+                if !labelKeyPattern.MatchString(key) {
+                        return nil, nil, fmt.Errorf("invalid label selector %q", selector)
+                }
+                if !hasValue {
+                        keys = append(keys, key)
+                        continue
+                }
+                if existing, ok := match[key]; ok && existing != value {
+                        return nil, nil, fmt.Errorf("conflicting values for label %q", key)
+                }
+                match[key] = value
+        }
+        return match, keys, nil
+}
+
+// findNodesByLabels responds with the readable nodes of the whole tree that
+// match every ?label= selector
+func (h *Handler) findNodesByLabels(c *gin.Context, selectors []string) {
+        match, keys, err := parseLabelSelectors(selectors)
+        if err != nil {
+                problem.Respond(c, http.StatusBadRequest, problem.CodeInvalidRequest, err.Error())
+                return
+        }
+
+        nodes, err := h.reads(c).FindNodesByLabels(c.Request.Context(), match, keys)
+        if err != nil {
+                respondError(c, err, "Failed to find nodes")
+                return
+        }
+
+        nodes, err = h.filterReadable(c, nodes)
+        if err != nil {
+                respondError(c, err, "Failed to check permissions")
+                return
+        }
+        if nodes == nil {
+                nodes = []models.ConfigNode{}
+        }
+
+        c.JSON(http.StatusOK, nodes)
+}
@@ -0,0 +1,93 @@
+package handlers
+
+import (
+        "bytes"
+        "config-manager/internal/problem"
+        "errors"
+        "fmt"
+        "io"
+        "net/http"
+
+        "github.com/gin-gonic/gin"
+)
+
+// importRoutes take whole documents and batches, which may be larger than the
+// bodies of other requests
+var importRoutes = map[string]bool{
+        "/api/apply": true,
+        "/api/drift": true,
+        "/api/batch": true,
+}
+
+// maxValueSize bounds the serialized size of property values written through
+// the API, see LimitValueSize
+var maxValueSize = 256 << 10
+
+// LimitValueSize bounds the serialized size of the property values written
+// through the API to size bytes, 0 lifting the limit. A single oversized value
+// would otherwise be stored as it is and slow down every resolution below it.
+func LimitValueSize(size int) {
+        maxValueSize = size
+}
+
+// valueTooLargeError reports a property value over maxValueSize
+type valueTooLargeError struct {
+        size int
+}
+
+func (e *valueTooLargeError) Error() string {
+        return fmt.Sprintf("value is %d bytes, more than the limit of %d", e.size, maxValueSize)
+}
+
+// checkValueSize fails for a serialized value over maxValueSize
+func checkValueSize(value string) error {
+        if maxValueSize > 0 && len(value) > maxValueSize {
+                return &valueTooLargeError{size: len(value)}
+        }
+        return nil
+}
+
+// respondInvalid reports a request that failed validation, with 413 if a
+// value is too large and 400 otherwise
+func respondInvalid(c *gin.Context, err error) {
+        var tooLarge *valueTooLargeError
+        if errors.As(err, &tooLarge) {
+                problem.Respond(c, http.StatusRequestEntityTooLarge, problem.CodePayloadTooLarge, err.Error())
+                return
+        }
+        problem.Respond(c, http.StatusBadRequest, problem.CodeInvalidRequest, err.Error())
+}
+
+// LimitBody rejects request bodies over maxSize bytes, or over importSize
+// bytes on the routes importing documents and batches, with 413. Bodies are
+// read up front, so that handlers never bind a truncated one. A zero size
+// lifts the limit.
+func LimitBody(maxSize, importSize int64) gin.HandlerFunc {
+        return func(c *gin.Context) {
+                limit := maxSize
+                if importRoutes[c.FullPath()] {
+                        limit = importSize
+                }
+                if limit <= 0 || c.Request.Body == nil || c.Request.Body == http.NoBody {
+                        c.Next()
+                        return
+                }
+
+                tooLarge := fmt.Sprintf("The request body is larger than the limit of %d bytes", limit)
+                if c.Request.ContentLength > limit {
+                        problem.Abort(c, http.StatusRequestEntityTooLarge, problem.CodePayloadTooLarge, tooLarge)
+                        return
+                }
+                body, err := io.ReadAll(io.LimitReader(c.Request.Body, limit+1))
+                if err != nil {
+                        problem.Abort(c, http.StatusBadRequest, problem.CodeInvalidRequest, "Failed to read the request body")
+                        return
+                }
+                if int64(len(body)) > limit {
+                        problem.Abort(c, http.StatusRequestEntityTooLarge, problem.CodePayloadTooLarge, tooLarge)
+                        return
+                }
+                c.Request.Body = io.NopCloser(bytes.NewReader(body))
+                c.Next()
+        }
+}
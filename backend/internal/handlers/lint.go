@@ -0,0 +1,233 @@
+package handlers
+
+import (
+        "config-manager/internal/database"
+        "config-manager/internal/models"
+        "config-manager/internal/problem"
+        "fmt"
+        "net/http"
+        "sort"
+        "strconv"
+
+        "github.com/gin-gonic/gin"
+)
+
+// lintTree is the whole tree loaded for linting
+type lintTree struct {
+        nodes      map[int64]models.ConfigNode
+        children   map[int64][]int64
+        properties map[int64][]*models.ConfigProperty
+        paths      map[int64]string
+}
+
+// LintTree scans the hierarchy for cruft: children that all override a key
+// with the same value, overrides equal to the inherited value, values that do
+// not match their data type and default values that are never used. Linting
+// the whole tree needs admin access; ?nodeId= lints one subtree instead.
+func (h *Handler) LintTree(c *gin.Context) {
+        var rootID *int64
+        if value := c.Query("nodeId"); value != "" {
+                id, err := strconv.ParseInt(value, 10, 64)
+                if err != nil {
+                        problem.Respond(c, http.StatusBadRequest, problem.CodeInvalidID, "Invalid node ID")
+                        return
+                }
+                if !h.authorize(c, id, models.PermissionRead) {
+                        return
+                }
+                rootID = &id
+        } else if !h.authorizeAdmin(c) {
+                return
+        }
+
+        nodes, err := h.repo.GetAllNodes(c.Request.Context())
+        if err != nil {
+                respondError(c, err, "Failed to get nodes")
+                return
+        }
+        properties, err := h.repo.GetAllProperties(c.Request.Context())
+        if err != nil {
+                respondError(c, err, "Failed to get properties")
+                return
+        }
+
+        tree := newLintTree(nodes, properties)
+        if rootID != nil {
+                if _, ok := tree.nodes[*rootID]; !ok {
+                        problem.Respond(c, http.StatusNotFound, problem.CodeNodeNotFound, "Node not found")
+                        return
+                }
+        }
+
+        report := models.LintReport{RootNodeID: rootID, Counts: map[string]int{}, Findings: []models.LintFinding{}}
+        for _, node := range nodes {
+                if rootID != nil && !tree.isWithin(node.ID, *rootID) {
+                        continue
+                }
+                report.NodesScanned++
+                report.PropertiesScanned += len(tree.properties[node.ID])
+                report.Findings = append(report.Findings, tree.lintNode(node.ID)...)
+        }
+
+        sort.SliceStable(report.Findings, func(i, j int) bool {
+                a, b := report.Findings[i], report.Findings[j]
+                if a.Path != b.Path {
+                        return a.Path < b.Path
+                }
+                return a.Key < b.Key
+        })
+        for _, finding := range report.Findings {
+                report.Counts[finding.Code]++
+        }
+
+        c.JSON(http.StatusOK, report)
+}
+
+// newLintTree indexes the tree; nodes come parents first
+func newLintTree(nodes []models.ConfigNode, properties []models.ConfigProperty) *lintTree {
+        tree := &lintTree{
+                nodes:      make(map[int64]models.ConfigNode, len(nodes)),
+                children:   make(map[int64][]int64),
+                properties: make(map[int64][]*models.ConfigProperty),
+                paths:      make(map[int64]string, len(nodes)),
+        }
+        for _, node := range nodes {
+                tree.nodes[node.ID] = node
+                if node.ParentID != nil {
+                        tree.children[*node.ParentID] = append(tree.children[*node.ParentID], node.ID)
+                        tree.paths[node.ID] = tree.paths[*node.ParentID] + "/" + node.Name
+                } else {
+                        tree.paths[node.ID] = "/" + node.Name
+                }
+        }
+        for i := range properties {
+                prop := &properties[i]
+                tree.properties[prop.NodeID] = append(tree.properties[prop.NodeID], prop)
+        }
+        return tree
+}
+
+// isWithin reports whether nodeID is rootID or one of its descendants
+func (t *lintTree) isWithin(nodeID, rootID int64) bool {
+        for id := &nodeID; id != nil; id = t.nodes[*id].ParentID {
+                if *id == rootID {
+                        return true
+                }
+        }
+        return false
+}
+
+// find returns the property of a node with key, for environment or for all
+// environments when environment is nil
+func (t *lintTree) find(nodeID int64, key string, environment *string) *models.ConfigProperty {
+        for _, prop := range t.properties[nodeID] {
+                if prop.Key == key && sameEnvironment(prop.Environment, environment) {
+                        return prop
+                }
+        }
+        return nil
+}
+
+// inherited returns the property whose value the node of prop would resolve
+// for prop's environment without prop, or nil if the key would not resolve
+func (t *lintTree) inherited(prop *models.ConfigProperty) *models.ConfigProperty {
+        if prop.Environment != nil {
+                if base := t.find(prop.NodeID, prop.Key, nil); base != nil {
+                        return base
+                }
+        }
+        for id := t.nodes[prop.NodeID].ParentID; id != nil; id = t.nodes[*id].ParentID {
+                if prop.Environment != nil {
+                        if override := t.find(*id, prop.Key, prop.Environment); override != nil {
+                                return override
+                        }
+                }
+                if base := t.find(*id, prop.Key, nil); base != nil {
+                        return base
+                }
+        }
+        return nil
+}
+
+// definedAbove returns the nearest ancestor of nodeID defining key in any
+// environment, or nil
+func (t *lintTree) definedAbove(nodeID int64, key string) *int64 {
+        for id := t.nodes[nodeID].ParentID; id != nil; id = t.nodes[*id].ParentID {
+                for _, prop := range t.properties[*id] {
+                        if prop.Key == key {
+                                return id
+                        }
+                }
+        }
+        return nil
+}
+
+// lintNode returns the findings about the properties of a node and the
+// overrides its children share
+func (t *lintTree) lintNode(nodeID int64) []models.LintFinding {
+        var findings []models.LintFinding
+        finding := func(code string, prop *models.ConfigProperty, message string) {
+                findings = append(findings, models.LintFinding{
+                        Code:        code,
+                        NodeID:      nodeID,
+                        Path:        t.paths[nodeID],
+                        Key:         prop.Key,
+                        Environment: prop.Environment,
+                        PropertyIDs: []int64{prop.ID},
+                        Message:     message,
+                })
+        }
+
+        for _, prop := range t.properties[nodeID] {
+                if !valueMatchesDataType(prop.Value, prop.DataType) {
+                        finding("data_type_mismatch", prop, fmt.Sprintf("value does not look like a JSON %s", prop.DataType))
+                }
+
+                if inherited := t.inherited(prop); inherited != nil && database.SameValue(*prop, *inherited) {
+                        finding("same_as_inherited", prop, fmt.Sprintf("value equals the value inherited from node %d", inherited.NodeID))
+                }
+
+                if prop.DefaultValue != nil {
+                        if !valueMatchesDataType(*prop.DefaultValue, prop.DataType) {
+                                finding("dangling_default_value", prop, fmt.Sprintf("default_value does not look like a JSON %s", prop.DataType))
+                        } else if ancestor := t.definedAbove(nodeID, prop.Key); ancestor != nil {
+                                finding("dangling_default_value", prop, fmt.Sprintf("default_value is never used, the key is already defined on node %d", *ancestor))
+                        }
+                }
+        }
+
+        children := t.children[nodeID]
+        if len(children) < 2 {
+                return findings
+        }
+        for _, first := range t.properties[children[0]] {
+                ids := []int64{first.ID}
+                for _, child := range children[1:] {
+                        prop := t.find(child, first.Key, first.Environment)
+                        if prop == nil || !database.SameValue(*first, *prop) {
+                                ids = nil
+                                break
+                        }
+                        ids = append(ids, prop.ID)
+                }
+                if ids != nil {
+                        findings = append(findings, models.LintFinding{
+                                Code:        "identical_child_overrides",
+                                NodeID:      nodeID,
+                                Path:        t.paths[nodeID],
+                                Key:         first.Key,
+                                Environment: first.Environment,
+                                PropertyIDs: ids,
+                                Message:     fmt.Sprintf("all %d children define the same value, which could be defined once on this node", len(ids)),
+                        })
+                }
+        }
+        return findings
+}
+
+func sameEnvironment(a, b *string) bool {
+        if a == nil || b == nil {
+                return a == nil && b == nil
+        }
+        return *a == *b
+}
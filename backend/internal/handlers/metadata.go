@@ -0,0 +1,21 @@
+package handlers
+
+import (
+        "encoding/json"
+        "fmt"
+)
+
+// maxMetadataBytes bounds the encoded metadata of a node
+const maxMetadataBytes = 16 * 1024
+
+// validateMetadata checks the metadata a node is created or updated with
+func validateMetadata(metadata map[string]interface{}) error {
+        encoded, err := json.Marshal(metadata)
+        if err != nil {
+                return err
+        }
+        if len(encoded) > maxMetadataBytes {
+                return fmt.Errorf("metadata exceeds %d bytes", maxMetadataBytes)
+        }
+        return nil
+}
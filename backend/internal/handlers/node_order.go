@@ -0,0 +1,70 @@
+package handlers
+
+import (
+        "config-manager/internal/database"
+        "config-manager/internal/models"
+        "config-manager/internal/problem"
+        "errors"
+        "net/http"
+        "strconv"
+
+        "github.com/gin-gonic/gin"
+)
+
+// ReorderChildren sets the order in which the children of a node are listed
+func (h *Handler) ReorderChildren(c *gin.Context) {
+        nodeIDStr := c.Param("nodeId")
+        nodeID, err := strconv.ParseInt(nodeIDStr, 10, 64)
+        if err != nil {
+                problem.Respond(c, http.StatusBadRequest, problem.CodeInvalidID, "Invalid node ID")
+                return
+        }
+
+        if !h.authorize(c, nodeID, models.PermissionWrite) {
+                return
+        }
+
+        node, err := h.repo.GetNodeByID(c.Request.Context(), nodeID)
+        if err != nil {
+                respondError(c, err, "Failed to get node")
+                return
+        }
+        if node == nil {
+                problem.Respond(c, http.StatusNotFound, problem.CodeNodeNotFound, "Node not found")
+                return
+        }
+
+        h.reorder(c, &nodeID)
+}
+
+// ReorderRootNodes sets the order in which root nodes are listed
+func (h *Handler) ReorderRootNodes(c *gin.Context) {
+        if !h.authorizeAdmin(c) {
+                return
+        }
+
+        h.reorder(c, nil)
+}
+
+func (h *Handler) reorder(c *gin.Context, parentID *int64) {
+        var req models.ReorderNodesRequest
+        if err := c.ShouldBindJSON(&req); err != nil {
+                problem.Respond(c, http.StatusBadRequest, problem.CodeInvalidRequest, err.Error())
+                return
+        }
+
+        nodes, err := h.repo.ReorderChildren(c.Request.Context(), parentID, req.ChildIDs)
+        if errors.Is(err, database.ErrInvalidOrder) {
+                problem.Respond(c, http.StatusBadRequest, problem.CodeInvalidRequest, err.Error())
+                return
+        }
+        if err != nil {
+                respondError(c, err, "Failed to reorder nodes")
+                return
+        }
+        if nodes == nil {
+                nodes = []models.ConfigNode{}
+        }
+
+        c.JSON(http.StatusOK, nodes)
+}
@@ -0,0 +1,46 @@
+package handlers
+
+import (
+        "config-manager/internal/database"
+        "config-manager/internal/models"
+        "config-manager/internal/problem"
+        "errors"
+        "net/http"
+        "strconv"
+
+        "github.com/gin-gonic/gin"
+)
+
+// GetOverrides lists every key overridden within the subtree of a node, with
+// the chain of values from the root to the overriding node, optionally for
+// the environment given as ?env=
+func (h *Handler) GetOverrides(c *gin.Context) {
+        nodeIDStr := c.Param("nodeId")
+        nodeID, err := strconv.ParseInt(nodeIDStr, 10, 64)
+        if err != nil {
+                problem.Respond(c, http.StatusBadRequest, problem.CodeInvalidID, "Invalid node ID")
+                return
+        }
+
+        if !h.authorize(c, nodeID, models.PermissionRead) {
+                return
+        }
+
+        environment := c.Query("env")
+        if environment != "" && !environmentPattern.MatchString(environment) {
+                problem.Respond(c, http.StatusBadRequest, problem.CodeInvalidEnvironment, "Invalid environment")
+                return
+        }
+
+        report, err := h.repo.GetOverrides(c.Request.Context(), nodeID, environment)
+        if errors.Is(err, database.ErrNodeNotFound) {
+                problem.Respond(c, http.StatusNotFound, problem.CodeNodeNotFound, "Node not found")
+                return
+        }
+        if err != nil {
+                respondError(c, err, "Failed to get overrides")
+                return
+        }
+
+        c.JSON(http.StatusOK, report)
+}
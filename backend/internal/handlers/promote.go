@@ -0,0 +1,75 @@
+package handlers
+
+import (
+        "config-manager/internal/database"
+        "config-manager/internal/models"
+        "config-manager/internal/problem"
+        "errors"
+        "net/http"
+        "strconv"
+
+        "github.com/gin-gonic/gin"
+)
+
+// PromoteProperty moves a property to the parent of its node. With
+// ?removeIdenticalSiblings=true, sibling overrides holding the same value are
+// deleted in the same transaction, as they now inherit it.
+func (h *Handler) PromoteProperty(c *gin.Context) {
+        propertyID, err := strconv.ParseInt(c.Param("propertyId"), 10, 64)
+        if err != nil {
+                problem.Respond(c, http.StatusBadRequest, problem.CodeInvalidID, "Invalid property ID")
+                return
+        }
+        removeIdentical := c.Query("removeIdenticalSiblings") == "true"
+
+        prop, err := h.repo.GetProperty(c.Request.Context(), propertyID)
+        if err != nil {
+                respondError(c, err, "Failed to get property")
+                return
+        }
+        if prop == nil {
+                problem.Respond(c, http.StatusNotFound, problem.CodePropertyNotFound, "Property not found")
+                return
+        }
+        node, err := h.repo.GetNodeByID(c.Request.Context(), prop.NodeID)
+        if err != nil {
+                respondError(c, err, "Failed to get node")
+                return
+        }
+        if node == nil || node.ParentID == nil {
+                problem.Respond(c, http.StatusBadRequest, problem.CodeInvalidRequest, database.ErrPromoteRootProperty.Error())
+                return
+        }
+
+        // Promoting writes the parent, and removing overrides writes its children
+        if !h.authorize(c, prop.NodeID, models.PermissionWrite) || !h.authorize(c, *node.ParentID, models.PermissionWrite) {
+                return
+        }
+        if !h.guardProtected(c, prop.NodeID, false) || !h.guardProtected(c, *node.ParentID, removeIdentical) {
+                return
+        }
+        if !h.guardFinal(c, *node.ParentID, prop.Key) {
+                return
+        }
+
+        result, err := h.repo.PromoteProperty(c.Request.Context(), propertyID, removeIdentical)
+        if errors.Is(err, database.ErrPromoteRootProperty) || errors.Is(err, database.ErrPromoteEncrypted) {
+                problem.Respond(c, http.StatusBadRequest, problem.CodeInvalidRequest, err.Error())
+                return
+        }
+        if errors.Is(err, database.ErrParentDefinesKey) {
+                problem.Respond(c, http.StatusConflict, problem.CodeKeyConflict, err.Error())
+                return
+        }
+        if err != nil {
+                respondError(c, err, "Failed to promote property")
+                return
+        }
+        if result == nil {
+                problem.Respond(c, http.StatusNotFound, problem.CodePropertyNotFound, "Property not found")
+                return
+        }
+
+        result.Warnings = h.propertyWarnings(c.Request.Context(), &result.Property)
+        c.JSON(http.StatusOK, result)
+}
@@ -0,0 +1,55 @@
+package handlers
+
+import (
+        "config-manager/internal/database"
+        "config-manager/internal/models"
+        "config-manager/internal/problem"
+        "errors"
+        "net/http"
+        "strconv"
+
+        "github.com/gin-gonic/gin"
+)
+
+// PushDownProperty replaces a property with copies on each direct child of its
+// node, so their values can start to diverge without a gap in between
+func (h *Handler) PushDownProperty(c *gin.Context) {
+        propertyID, err := strconv.ParseInt(c.Param("propertyId"), 10, 64)
+        if err != nil {
+                problem.Respond(c, http.StatusBadRequest, problem.CodeInvalidID, "Invalid property ID")
+                return
+        }
+
+        nodeID, err := h.repo.GetPropertyNodeID(c.Request.Context(), propertyID)
+        if err != nil {
+                respondError(c, err, "Failed to get property")
+                return
+        }
+        if nodeID == nil {
+                problem.Respond(c, http.StatusNotFound, problem.CodePropertyNotFound, "Property not found")
+                return
+        }
+
+        if !h.authorize(c, *nodeID, models.PermissionWrite) {
+                return
+        }
+        if !h.guardProtected(c, *nodeID, true) {
+                return
+        }
+
+        result, err := h.repo.PushDownProperty(c.Request.Context(), propertyID)
+        if errors.Is(err, database.ErrPushDownLeaf) || errors.Is(err, database.ErrPushDownFinal) {
+                problem.Respond(c, http.StatusBadRequest, problem.CodeInvalidRequest, err.Error())
+                return
+        }
+        if err != nil {
+                respondError(c, err, "Failed to push down property")
+                return
+        }
+        if result == nil {
+                problem.Respond(c, http.StatusNotFound, problem.CodePropertyNotFound, "Property not found")
+                return
+        }
+
+        c.JSON(http.StatusOK, result)
+}
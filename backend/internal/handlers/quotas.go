@@ -0,0 +1,121 @@
+package handlers
+
+import (
+        "config-manager/internal/models"
+        "config-manager/internal/problem"
+        "config-manager/internal/quota"
+        "net/http"
+        "strconv"
+
+        "github.com/gin-gonic/gin"
+)
+
+// UseRequestLimiter reports the requests limiter counted in the usage of
+// organizations. The limiter itself is installed as middleware.
+func (h *Handler) UseRequestLimiter(limiter *quota.Limiter) {
+        h.limiter = limiter
+}
+
+// GetOrganizationUsage returns the usage of the organization a node belongs
+// to against its quota
+func (h *Handler) GetOrganizationUsage(c *gin.Context) {
+        nodeID, err := strconv.ParseInt(c.Param("nodeId"), 10, 64)
+        if err != nil {
+                problem.Respond(c, http.StatusBadRequest, problem.CodeInvalidID, "Invalid node ID")
+                return
+        }
+
+        if !h.authorize(c, nodeID, models.PermissionRead) {
+                return
+        }
+
+        usage, err := h.repo.GetOrganizationUsage(c.Request.Context(), nodeID)
+        if err != nil {
+                respondError(c, err, "Failed to get quota usage")
+                return
+        }
+        if usage == nil {
+                problem.Respond(c, http.StatusNotFound, problem.CodeNodeNotFound, "Node not found")
+                return
+        }
+        if h.limiter != nil {
+                usage.RequestsPerMinute.Used = h.limiter.Used(usage.RootNodeID)
+        }
+
+        c.JSON(http.StatusOK, usage)
+}
+
+func (h *Handler) GetQuotas(c *gin.Context) {
+        if !h.authorizeAdmin(c) {
+                return
+        }
+
+        quotas, err := h.repo.GetQuotas(c.Request.Context())
+        if err != nil {
+                respondError(c, err, "Failed to get quotas")
+                return
+        }
+
+        c.JSON(http.StatusOK, quotas)
+}
+
+// SetQuota replaces the quota of the organization rooted at :nodeId, or the
+// default quota for "default"
+func (h *Handler) SetQuota(c *gin.Context) {
+        if !h.authorizeAdmin(c) {
+                return
+        }
+
+        rootNodeID, ok := quotaRoot(c)
+        if !ok {
+                return
+        }
+
+        var req models.SetQuotaRequest
+        if err := c.ShouldBindJSON(&req); err != nil {
+                problem.Respond(c, http.StatusBadRequest, problem.CodeInvalidRequest, err.Error())
+                return
+        }
+
+        saved, err := h.repo.SetQuota(c.Request.Context(), rootNodeID, req)
+        if err != nil {
+                respondError(c, err, "Failed to set quota")
+                return
+        }
+
+        c.JSON(http.StatusOK, saved)
+}
+
+// DeleteQuota removes the quota of an organization, which falls back to the
+// default quota, or the default quota itself
+func (h *Handler) DeleteQuota(c *gin.Context) {
+        if !h.authorizeAdmin(c) {
+                return
+        }
+
+        rootNodeID, ok := quotaRoot(c)
+        if !ok {
+                return
+        }
+
+        if err := h.repo.DeleteQuota(c.Request.Context(), rootNodeID); err != nil {
+                respondError(c, err, "Failed to delete quota")
+                return
+        }
+
+        c.JSON(http.StatusNoContent, nil)
+}
+
+// quotaRoot parses the :nodeId of a quota route, nil for the default quota
+func quotaRoot(c *gin.Context) (*int64, bool) {
+        if c.Param("nodeId") == "default" {
+                return nil, true
+        }
+
+        nodeID, err := strconv.ParseInt(c.Param("nodeId"), 10, 64)
+        if err != nil {
+                problem.Respond(c, http.StatusBadRequest, problem.CodeInvalidID, "Invalid node ID")
+                return nil, false
+        }
+        return &nodeID, true
+}
@@ -0,0 +1,28 @@
+package handlers
+
+import (
+        "config-manager/internal/database"
+
+        "github.com/gin-gonic/gin"
+)
+
+// ReadPrimaryHeader is the header clients set to true to read from the primary
+// database, e.g. right after a write that a replica or cache may not reflect yet
+const ReadPrimaryHeader = "X-Read-Primary"
+
+// UseReadRepository serves node reads, resolutions and searches from reads,
+// e.g. a repository on a replica of the database or a resolution cache. Writes,
+// including the node accesses recorded for the delete guard, and permission
+// checks stay on the primary.
+func (h *Handler) UseReadRepository(reads database.ConfigRepository) {
+        h.readRepo = reads
+}
+
+// reads returns the repository to serve the reads of a request from, the read
+// repository unless there is none or the request asks for the primary
+func (h *Handler) reads(c *gin.Context) database.ConfigRepository {
+        if h.readRepo == nil || c.GetHeader(ReadPrimaryHeader) == "true" {
+                return h.repo
+        }
+        return h.readRepo
+}
@@ -0,0 +1,214 @@
+package handlers
+
+import (
+        "bytes"
+        "encoding/json"
+        "mime"
+        "net/http"
+        "strconv"
+        "strings"
+
+        "github.com/gin-gonic/gin"
+        "github.com/pelletier/go-toml/v2"
+        "gopkg.in/yaml.v3"
+)
+
+// renderFormat is a representation JSON responses can be converted to
+type renderFormat struct {
+        mediaType string
+        aliases   []string
+        convert   func(data []byte) ([]byte, error)
+}
+
+var renderFormats = []renderFormat{
+        {mediaType: "application/yaml", aliases: []string{"application/x-yaml", "text/yaml"}, convert: jsonToYAML},
+        {mediaType: "application/toml", convert: jsonToTOML},
+}
+
+// Render converts the JSON responses of GET requests to YAML or TOML when the
+// client prefers either to JSON in Accept. Wildcards only match JSON, so
+// clients that accept anything keep getting it. Other responses, errors and
+// event streams included, are sent as they are.
+func Render() gin.HandlerFunc {
+        return func(c *gin.Context) {
+                if c.Request.Method != http.MethodGet || c.GetHeader("Upgrade") != "" {
+                        c.Next()
+                        return
+                }
+
+                c.Writer.Header().Add("Vary", "Accept")
+                format := negotiateFormat(c.GetHeader("Accept"))
+                if format == nil {
+                        c.Next()
+                        return
+                }
+
+                writer := &renderWriter{ResponseWriter: c.Writer, format: format}
+                c.Writer = writer
+                defer func() { c.Writer = writer.ResponseWriter }()
+                c.Next()
+                writer.finish()
+        }
+}
+
+// negotiateFormat picks the format from an Accept header, or nil for JSON
+func negotiateFormat(header string) *renderFormat {
+        quality := make(map[string]float64)
+        for _, part := range strings.Split(header, ",") {
+                mediaType, params, err := mime.ParseMediaType(part)
+                if err != nil {
+                        continue
+                }
+                q := 1.0
+                if parsed, err := strconv.ParseFloat(params["q"], 64); err == nil {
+                        q = parsed
+                }
+                quality[mediaType] = q
+        }
+
+        jsonQuality := 0.0
+        for _, mediaType := range []string{"application/json", "application/*", "*/*"} {
+                if q, ok := quality[mediaType]; ok {
+                        jsonQuality = q
+                        break
+                }
+        }
+
+        var best *renderFormat
+        bestQuality := jsonQuality
+        for i := range renderFormats {
+                format := &renderFormats[i]
+                for _, mediaType := range append([]string{format.mediaType}, format.aliases...) {
+                        if q := quality[mediaType]; q > bestQuality {
+                                best, bestQuality = format, q
+                        }
+                }
+        }
+        return best
+}
+
+// renderWriter holds back a JSON body to convert it once the handler is done.
+// Bodies of other types are written through.
+type renderWriter struct {
+        gin.ResponseWriter
+        format    *renderFormat
+        body      bytes.Buffer
+        decided   bool
+        buffering bool
+}
+
+func (w *renderWriter) Write(data []byte) (int, error) {
+        if !w.decided {
+                w.decided = true
+                mediaType, _, _ := mime.ParseMediaType(w.Header().Get("Content-Type"))
+                w.buffering = mediaType == "application/json" && w.Header().Get("Content-Encoding") == ""
+        }
+        if w.buffering {
+                return w.body.Write(data)
+        }
+        return w.ResponseWriter.Write(data)
+}
+
+func (w *renderWriter) WriteString(s string) (int, error) {
+        return w.Write([]byte(s))
+}
+
+// Flush passes through unless the body is held back for conversion
+func (w *renderWriter) Flush() {
+        if !w.buffering {
+                w.ResponseWriter.Flush()
+        }
+}
+
+// Unwrap returns the wrapped writer, so that http.ResponseController reaches
+// the connection, e.g. to extend the write deadline of long polls
+func (w *renderWriter) Unwrap() http.ResponseWriter {
+        return w.ResponseWriter
+}
+
+// finish writes out the converted body, or the JSON one if it has no
+// representation in the format
+func (w *renderWriter) finish() {
+        if !w.buffering {
+                return
+        }
+        body := w.body.Bytes()
+        if converted, err := w.format.convert(body); err == nil {
+                // Signatures only hold for the JSON body
+                w.Header().Set("Content-Type", w.format.mediaType+"; charset=utf-8")
+                w.Header().Del(SignatureHeader)
+                body = converted
+        }
+        w.Header().Del("Content-Length")
+        w.ResponseWriter.Write(body)
+}
+
+// jsonToYAML converts a JSON document to block-style YAML, keeping the order
+// of keys. Strings that would read as another type stay quoted.
+func jsonToYAML(data []byte) ([]byte, error) {
+        var document yaml.Node
+        if err := yaml.Unmarshal(data, &document); err != nil {
+                return nil, err
+        }
+        var unstyle func(node *yaml.Node)
+        unstyle = func(node *yaml.Node) {
+                node.Style = 0
+                for _, child := range node.Content {
+                        unstyle(child)
+                }
+        }
+        unstyle(&document)
+
+        var out bytes.Buffer
+        encoder := yaml.NewEncoder(&out)
+        encoder.SetIndent(2)
+        if err := encoder.Encode(&document); err != nil {
+                return nil, err
+        }
+        if err := encoder.Close(); err != nil {
+                return nil, err
+        }
+        return out.Bytes(), nil
+}
+
+// jsonToTOML converts a JSON object to TOML. TOML has no null, so null values
+// are left out; documents that are not objects cannot be converted.
+func jsonToTOML(data []byte) ([]byte, error) {
+        decoder := json.NewDecoder(bytes.NewReader(data))
+        decoder.UseNumber()
+        var document map[string]interface{}
+        if err := decoder.Decode(&document); err != nil {
+                return nil, err
+        }
+        return toml.Marshal(tomlValue(document))
+}
+
+// tomlValue drops the null values of a decoded JSON value and turns its
+// numbers into integers or floats
+func tomlValue(value interface{}) interface{} {
+        switch v := value.(type) {
+        case map[string]interface{}:
+                for key, item := range v {
+                        if item == nil {
+                                delete(v, key)
+                                continue
+                        }
+                        v[key] = tomlValue(item)
+                }
+        case []interface{}:
+                items := v[:0]
+                for _, item := range v {
+                        if item != nil {
+                                items = append(items, tomlValue(item))
+                        }
+                }
+                return items
+        case json.Number:
+                if i, err := v.Int64(); err == nil {
+                        return i
+                }
+                f, _ := v.Float64()
+                return f
+        }
+        return value
+}
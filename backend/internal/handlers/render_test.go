@@ -0,0 +1,38 @@
+package handlers
+
+import "testing"
+
+func TestNegotiateFormat(t *testing.T) {
+        tests := []struct {
+                accept string
+                want   string // Media type, "" for JSON
+        }{
+                {"", ""},
+                {"application/json", ""},
+                {"*/*", ""},
+                {"application/yaml", "application/yaml"},
+                {"text/yaml", "application/yaml"},
+                {"application/x-yaml", "application/yaml"},
+                {"application/toml", "application/toml"},
+                {"application/yaml, application/json", ""},
+                {"application/json;q=0.5, application/yaml", "application/yaml"},
+                {"application/yaml;q=0.4, application/toml;q=0.8", "application/toml"},
+                {"application/yaml, */*;q=0.1", "application/yaml"},
+                {"*/*, application/toml;q=0.9", ""},
+                {"text/html", ""},
+                {"application/yaml;q=abc", "application/yaml"},
+                {"not a media type, application/toml", "application/toml"},
+        }
+
+        for _, tt := range tests {
+                t.Run(tt.accept, func(t *testing.T) {
+                        got := ""
+                        if format := negotiateFormat(tt.accept); format != nil {
+                                got = format.mediaType
+                        }
+                        if got != tt.want {
+                                t.Errorf("negotiateFormat(%q) = %q, want %q", tt.accept, got, tt.want)
+                        }
+                })
+        }
+}
@@ -0,0 +1,116 @@
+package handlers
+
+import (
+        "config-manager/internal/auth"
+        "config-manager/internal/database"
+        "config-manager/internal/models"
+        "config-manager/internal/problem"
+        "crypto/sha256"
+        "encoding/hex"
+        "errors"
+        "fmt"
+        "net/http"
+        "regexp"
+        "strconv"
+        "strings"
+
+        "github.com/gin-gonic/gin"
+)
+
+// ReplaceValues finds and replaces strings in the property values of a
+// subtree. The replacement has to be previewed with ?dryRun=true first, and is
+// only applied with the preview_token of a preview listing the same changes.
+func (h *Handler) ReplaceValues(c *gin.Context) {
+        nodeID, err := strconv.ParseInt(c.Param("nodeId"), 10, 64)
+        if err != nil {
+                problem.Respond(c, http.StatusBadRequest, problem.CodeInvalidID, "Invalid node ID")
+                return
+        }
+
+        if !h.authorizeAdmin(c) {
+                return
+        }
+
+        var req models.ReplaceValuesRequest
+        if err := c.ShouldBindJSON(&req); err != nil {
+                problem.Respond(c, http.StatusBadRequest, problem.CodeInvalidRequest, err.Error())
+                return
+        }
+
+        replace := func(s string) string { return strings.ReplaceAll(s, req.Find, req.Replace) }
+        if req.Regex {
+                pattern, err := regexp.Compile(req.Find)
+                if err != nil {
+                        problem.Respond(c, http.StatusBadRequest, problem.CodeInvalidRequest, "Invalid regular expression: " + err.Error())
+                        return
+                }
+                replace = func(s string) string { return pattern.ReplaceAllString(s, req.Replace) }
+        }
+
+        node, err := h.repo.GetNodeByID(c.Request.Context(), nodeID)
+        if err != nil {
+                respondError(c, err, "Failed to get node")
+                return
+        }
+        if node == nil {
+                problem.Respond(c, http.StatusNotFound, problem.CodeNodeNotFound, "Node not found")
+                return
+        }
+
+        replacements, err := h.repo.PlanValueReplacement(c.Request.Context(), nodeID, req.Key, replace)
+        if err != nil {
+                respondError(c, err, "Failed to find matching values")
+                return
+        }
+        token := previewToken(nodeID, req, replacements)
+
+        if isDryRun(c) {
+                c.JSON(http.StatusOK, models.ReplaceValuesResponse{DryRun: true, Replacements: replacements, PreviewToken: token})
+                return
+        }
+
+        if req.PreviewToken == "" {
+                problem.Respond(c, http.StatusBadRequest, problem.CodePreviewRequired, "Preview the replacement with ?dryRun=true and pass its preview_token")
+                return
+        }
+        if req.PreviewToken != token {
+                problem.Respond(c, http.StatusConflict, problem.CodeVersionConflict, "The matching values changed since the preview, preview the replacement again")
+                return
+        }
+
+        if !h.guardProtected(c, nodeID, true) {
+                return
+        }
+
+        response := models.ReplaceValuesResponse{Replacements: replacements}
+        if len(replacements) > 0 {
+                response.AuditID, err = h.repo.ApplyValueReplacement(c.Request.Context(), replacements, auth.Identity(c), map[string]interface{}{
+                        "node_id": nodeID,
+                        "find":    req.Find,
+                        "replace": req.Replace,
+                        "regex":   req.Regex,
+                        "key":     req.Key,
+                })
+                if errors.Is(err, database.ErrReplacementStale) {
+                        problem.Respond(c, http.StatusConflict, problem.CodeVersionConflict, "The matching values changed since the preview, preview the replacement again")
+                        return
+                }
+                if err != nil {
+                        respondError(c, err, "Failed to replace values")
+                        return
+                }
+        }
+
+        c.JSON(http.StatusOK, response)
+}
+
+// previewToken identifies a replacement and the exact changes it plans, so
+// that a preview only confirms the changes it listed
+func previewToken(nodeID int64, req models.ReplaceValuesRequest, replacements []models.ValueReplacement) string {
+        hash := sha256.New()
+        fmt.Fprintf(hash, "%d\x00%q\x00%q\x00%t\x00%q\x00", nodeID, req.Find, req.Replace, req.Regex, req.Key)
+        for _, replacement := range replacements {
+                fmt.Fprintf(hash, "%d\x00%d\x00%q\x00", replacement.PropertyID, replacement.UpdatedAt.UnixNano(), replacement.After)
+        }
+        return hex.EncodeToString(hash.Sum(nil))
+}
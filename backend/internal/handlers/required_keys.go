@@ -0,0 +1,137 @@
+package handlers
+
+import (
+        "config-manager/internal/auth"
+        "config-manager/internal/database"
+        "config-manager/internal/models"
+        "config-manager/internal/problem"
+        "errors"
+        "net/http"
+        "strconv"
+
+        "github.com/gin-gonic/gin"
+)
+
+// Required key handlers
+func (h *Handler) RequireKey(c *gin.Context) {
+        nodeIDStr := c.Param("nodeId")
+        nodeID, err := strconv.ParseInt(nodeIDStr, 10, 64)
+        if err != nil {
+                problem.Respond(c, http.StatusBadRequest, problem.CodeInvalidID, "Invalid node ID")
+                return
+        }
+
+        if !h.authorize(c, nodeID, models.PermissionWrite) {
+                return
+        }
+
+        var req models.CreateRequiredKeyRequest
+        if err := c.ShouldBindJSON(&req); err != nil {
+                problem.Respond(c, http.StatusBadRequest, problem.CodeInvalidRequest, err.Error())
+                return
+        }
+
+        node, err := h.repo.GetNodeByID(c.Request.Context(), nodeID)
+        if err != nil {
+                respondError(c, err, "Failed to get node")
+                return
+        }
+        if node == nil {
+                problem.Respond(c, http.StatusNotFound, problem.CodeNodeNotFound, "Node not found")
+                return
+        }
+
+        required, err := h.repo.RequireKey(c.Request.Context(), nodeID, req, auth.Identity(c))
+        if err != nil {
+                respondError(c, err, "Failed to require key")
+                return
+        }
+
+        c.JSON(http.StatusCreated, required)
+}
+
+func (h *Handler) GetRequiredKeys(c *gin.Context) {
+        nodeIDStr := c.Param("nodeId")
+        nodeID, err := strconv.ParseInt(nodeIDStr, 10, 64)
+        if err != nil {
+                problem.Respond(c, http.StatusBadRequest, problem.CodeInvalidID, "Invalid node ID")
+                return
+        }
+
+        if !h.authorize(c, nodeID, models.PermissionRead) {
+                return
+        }
+
+        required, err := h.repo.GetRequiredKeys(c.Request.Context(), nodeID)
+        if err != nil {
+                respondError(c, err, "Failed to get required keys")
+                return
+        }
+
+        c.JSON(http.StatusOK, required)
+}
+
+// UnrequireKey removes the requirement for the key given as ?key=
+func (h *Handler) UnrequireKey(c *gin.Context) {
+        nodeIDStr := c.Param("nodeId")
+        nodeID, err := strconv.ParseInt(nodeIDStr, 10, 64)
+        if err != nil {
+                problem.Respond(c, http.StatusBadRequest, problem.CodeInvalidID, "Invalid node ID")
+                return
+        }
+
+        if !h.authorize(c, nodeID, models.PermissionWrite) {
+                return
+        }
+
+        key := c.Query("key")
+        if key == "" {
+                problem.Respond(c, http.StatusBadRequest, problem.CodeInvalidRequest, "key is required")
+                return
+        }
+
+        removed, err := h.repo.UnrequireKey(c.Request.Context(), nodeID, key)
+        if err != nil {
+                respondError(c, err, "Failed to remove required key")
+                return
+        }
+        if !removed {
+                problem.Respond(c, http.StatusNotFound, problem.CodeKeyNotFound, "Key is not required on this node")
+                return
+        }
+
+        c.JSON(http.StatusNoContent, nil)
+}
+
+// ValidateNode reports the leaves below a node that do not resolve every key
+// required by their ancestors, optionally for the environment given as ?env=
+func (h *Handler) ValidateNode(c *gin.Context) {
+        nodeIDStr := c.Param("nodeId")
+        nodeID, err := strconv.ParseInt(nodeIDStr, 10, 64)
+        if err != nil {
+                problem.Respond(c, http.StatusBadRequest, problem.CodeInvalidID, "Invalid node ID")
+                return
+        }
+
+        if !h.authorize(c, nodeID, models.PermissionRead) {
+                return
+        }
+
+        environment := c.Query("env")
+        if environment != "" && !environmentPattern.MatchString(environment) {
+                problem.Respond(c, http.StatusBadRequest, problem.CodeInvalidEnvironment, "Invalid environment")
+                return
+        }
+
+        report, err := h.repo.ValidateCompleteness(c.Request.Context(), nodeID, environment)
+        if errors.Is(err, database.ErrNodeNotFound) {
+                problem.Respond(c, http.StatusNotFound, problem.CodeNodeNotFound, "Node not found")
+                return
+        }
+        if err != nil {
+                respondError(c, err, "Failed to validate node")
+                return
+        }
+
+        c.JSON(http.StatusOK, report)
+}
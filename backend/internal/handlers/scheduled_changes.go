@@ -0,0 +1,152 @@
+package handlers
+
+import (
+        "config-manager/internal/database"
+        "config-manager/internal/flags"
+        "config-manager/internal/models"
+        "config-manager/internal/problem"
+        "encoding/json"
+        "errors"
+        "net/http"
+        "strconv"
+        "time"
+
+        "github.com/gin-gonic/gin"
+)
+
+// Scheduled change handlers
+func (h *Handler) CreateScheduledChange(c *gin.Context) {
+        nodeIDStr := c.Param("nodeId")
+        nodeID, err := strconv.ParseInt(nodeIDStr, 10, 64)
+        if err != nil {
+                problem.Respond(c, http.StatusBadRequest, problem.CodeInvalidID, "Invalid node ID")
+                return
+        }
+
+        if !h.authorize(c, nodeID, models.PermissionWrite) {
+                return
+        }
+
+        if !h.guardProtected(c, nodeID, false) {
+                return
+        }
+
+        var req models.CreateScheduledChangeRequest
+        if err := c.ShouldBindJSON(&req); err != nil {
+                problem.Respond(c, http.StatusBadRequest, problem.CodeInvalidRequest, err.Error())
+                return
+        }
+
+        if err := checkValueSize(req.Value); err != nil {
+                respondInvalid(c, err)
+                return
+        }
+        var jsonValue interface{}
+        if err := json.Unmarshal([]byte(req.Value), &jsonValue); err != nil {
+                problem.Respond(c, http.StatusBadRequest, problem.CodeInvalidValue, "Value must be valid JSON")
+                return
+        }
+        if !validDataTypes[req.DataType] {
+                problem.Respond(c, http.StatusBadRequest, problem.CodeInvalidDataType, "Invalid data type")
+                return
+        }
+        if req.DataType == models.DataTypeFlag {
+                if _, err := flags.Parse(req.Value); err != nil {
+                        problem.Respond(c, http.StatusBadRequest, problem.CodeInvalidValue, err.Error())
+                        return
+                }
+        }
+        if req.Environment != nil && !environmentPattern.MatchString(*req.Environment) {
+                problem.Respond(c, http.StatusBadRequest, problem.CodeInvalidEnvironment, "Invalid environment")
+                return
+        }
+        if !req.EffectiveAt.After(time.Now()) {
+                problem.Respond(c, http.StatusBadRequest, problem.CodeInvalidRequest, "effective_at must be in the future")
+                return
+        }
+        if !h.guardRegisteredKeys(c, req.Key) {
+                return
+        }
+
+        node, err := h.repo.GetNodeByID(c.Request.Context(), nodeID)
+        if err != nil {
+                respondError(c, err, "Failed to validate node")
+                return
+        }
+        if node == nil {
+                problem.Respond(c, http.StatusNotFound, problem.CodeNodeNotFound, "Node not found")
+                return
+        }
+
+        if !h.guardFinal(c, nodeID, req.Key) {
+                return
+        }
+
+        change, err := h.repo.CreateScheduledChange(c.Request.Context(), nodeID, req)
+        if errors.Is(err, database.ErrScheduledChangeEncrypted) {
+                problem.Respond(c, http.StatusBadRequest, problem.CodeInvalidRequest, err.Error())
+                return
+        }
+        if err != nil {
+                respondError(c, err, "Failed to schedule change")
+                return
+        }
+
+        c.JSON(http.StatusCreated, change)
+}
+
+func (h *Handler) GetScheduledChanges(c *gin.Context) {
+        nodeIDStr := c.Param("nodeId")
+        nodeID, err := strconv.ParseInt(nodeIDStr, 10, 64)
+        if err != nil {
+                problem.Respond(c, http.StatusBadRequest, problem.CodeInvalidID, "Invalid node ID")
+                return
+        }
+
+        if !h.authorize(c, nodeID, models.PermissionRead) {
+                return
+        }
+
+        changes, err := h.repo.GetScheduledChanges(c.Request.Context(), nodeID)
+        if err != nil {
+                respondError(c, err, "Failed to get scheduled changes")
+                return
+        }
+
+        c.JSON(http.StatusOK, changes)
+}
+
+func (h *Handler) CancelScheduledChange(c *gin.Context) {
+        id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+        if err != nil {
+                problem.Respond(c, http.StatusBadRequest, problem.CodeInvalidID, "Invalid scheduled change ID")
+                return
+        }
+
+        if h.acl.Enforced() {
+                nodeID, err := h.repo.GetScheduledChangeNodeID(c.Request.Context(), id)
+                if err != nil {
+                        respondError(c, err, "Failed to check permissions")
+                        return
+                }
+                if nodeID == nil {
+                        problem.Respond(c, http.StatusNotFound, problem.CodeNotFound, "Scheduled change not found")
+                        return
+                }
+                if !h.authorize(c, *nodeID, models.PermissionWrite) {
+                        return
+                }
+        }
+
+        err = h.repo.CancelScheduledChange(c.Request.Context(), id)
+        switch {
+        case errors.Is(err, database.ErrScheduledChangeNotFound):
+                problem.Respond(c, http.StatusNotFound, problem.CodeNotFound, "Scheduled change not found")
+        case errors.Is(err, database.ErrScheduledChangeNotPending):
+                problem.Respond(c, http.StatusConflict, problem.CodeInvalidState, err.Error())
+        case err != nil:
+                respondError(c, err, "Failed to cancel scheduled change")
+        default:
+                c.JSON(http.StatusNoContent, nil)
+        }
+}
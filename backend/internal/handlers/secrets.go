@@ -0,0 +1,65 @@
+package handlers
+
+import (
+        "config-manager/internal/auth"
+        "config-manager/internal/models"
+        "config-manager/internal/problem"
+        "net/http"
+        "strconv"
+
+        "github.com/gin-gonic/gin"
+)
+
+// maskedValue stands in for the values of secret properties, which are only
+// returned by RevealProperty
+const maskedValue = `"*****"`
+
+// maskSecret masks the value and default value of an encrypted property
+func maskSecret(property *models.ConfigProperty) {
+        if !property.Encrypted {
+                return
+        }
+        property.Value = maskedValue
+        if property.DefaultValue != nil {
+                masked := maskedValue
+                property.DefaultValue = &masked
+        }
+}
+
+// maskSecrets masks the secret properties of a list
+func maskSecrets(properties []models.ConfigProperty) {
+        for i := range properties {
+                maskSecret(&properties[i])
+        }
+}
+
+// maskSecretResults masks the secret properties of search results
+func maskSecretResults(results []models.PropertySearchResult) {
+        for i := range results {
+                maskSecret(&results[i].ConfigProperty)
+        }
+}
+
+// RevealProperty returns the plaintext value of a secret property. It needs
+// write permission on the node, more than reading the masked property, and
+// records who revealed the value in the audit log.
+func (h *Handler) RevealProperty(c *gin.Context) {
+        propertyID, err := strconv.ParseInt(c.Param("propertyId"), 10, 64)
+        if err != nil {
+                problem.Respond(c, http.StatusBadRequest, problem.CodeInvalidID, "Invalid property ID")
+                return
+        }
+
+        if !h.authorizeProperty(c, propertyID, models.PermissionWrite) {
+                return
+        }
+
+        property, err := h.repo.RevealProperty(c.Request.Context(), propertyID, auth.Identity(c))
+        if err != nil {
+                respondError(c, err, "Failed to reveal property")
+                return
+        }
+
+        c.Header("Cache-Control", "no-store")
+        c.JSON(http.StatusOK, property)
+}
@@ -0,0 +1,41 @@
+package handlers
+
+import (
+        "config-manager/internal/signing"
+        "net/http"
+
+        "github.com/gin-gonic/gin"
+)
+
+// SignatureHeader carries the detached JWS over the body of signed responses
+const SignatureHeader = "X-Config-Signature"
+
+// UseSigner signs resolve responses with signer. Signatures cover the body as
+// sent, JSON or protocol buffers; responses converted to YAML or TOML are not
+// signed.
+func (h *Handler) UseSigner(signer *signing.Signer) {
+        h.signer = signer
+}
+
+// respondSigned sends body with its signature, if responses are signed
+func (h *Handler) respondSigned(c *gin.Context, contentType string, body []byte) {
+        if h.signer != nil {
+                signature, err := h.signer.Sign(body)
+                if err != nil {
+                        respondError(c, err, "Failed to sign configuration")
+                        return
+                }
+                c.Header(SignatureHeader, signature)
+        }
+        c.Data(http.StatusOK, contentType, body)
+}
+
+// SigningKeys lists the keys signed responses can be verified with, as a JSON
+// Web Key Set. The set is empty when responses are not signed.
+func (h *Handler) SigningKeys(c *gin.Context) {
+        keys := []signing.JWK{}
+        if h.signer != nil {
+                keys = append(keys, h.signer.PublicKey())
+        }
+        c.JSON(http.StatusOK, gin.H{"keys": keys})
+}
@@ -0,0 +1,116 @@
+package handlers
+
+import (
+        "config-manager/internal/database"
+        "config-manager/internal/models"
+        "config-manager/internal/problem"
+        "errors"
+        "fmt"
+        "net/http"
+        "strconv"
+
+        "github.com/gin-gonic/gin"
+)
+
+// SimulateResolution resolves a node as if the property edits in the body
+// were applied to it or its ancestors, and diffs the result against the
+// current configuration. Environment and context are given as for resolving.
+// Nothing is persisted.
+func (h *Handler) SimulateResolution(c *gin.Context) {
+        nodeIDStr := c.Param("nodeId")
+        nodeID, err := strconv.ParseInt(nodeIDStr, 10, 64)
+        if err != nil {
+                problem.Respond(c, http.StatusBadRequest, problem.CodeInvalidID, "Invalid node ID")
+                return
+        }
+
+        if !h.authorize(c, nodeID, models.PermissionRead) {
+                return
+        }
+
+        environment, context, ok := resolveQuery(c)
+        if !ok {
+                return
+        }
+
+        var req models.SimulateRequest
+        if err := c.ShouldBindJSON(&req); err != nil {
+                problem.Respond(c, http.StatusBadRequest, problem.CodeInvalidRequest, err.Error())
+                return
+        }
+
+        path, err := h.repo.GetNodePath(c.Request.Context(), nodeID)
+        if err != nil {
+                respondError(c, err, "Failed to get node path")
+                return
+        }
+        if len(path) == 0 {
+                problem.Respond(c, http.StatusNotFound, problem.CodeNodeNotFound, "Node not found")
+                return
+        }
+        onPath := make(map[int64]bool, len(path))
+        for _, node := range path {
+                onPath[node.ID] = true
+        }
+
+        result := models.SimulationResult{Ignored: []int{}}
+        preview := make([]models.PropertyDraft, 0, len(req.Changes))
+        for i, change := range req.Changes {
+                if err := validateDraft(change.SavePropertyDraftRequest); err != nil {
+                        respondInvalid(c, fmt.Errorf("changes[%d]: %w", i, err))
+                        return
+                }
+                if !onPath[change.NodeID] {
+                        problem.Respond(c, http.StatusBadRequest, problem.CodeInvalidRequest, fmt.Sprintf("changes[%d]: node %d is not node %d or one of its ancestors", i, change.NodeID, nodeID))
+                        return
+                }
+
+                if !change.Delete {
+                        locking, err := h.repo.GetLockingProperty(c.Request.Context(), change.NodeID, change.Key)
+                        if err != nil {
+                                respondError(c, err, "Failed to check final properties")
+                                return
+                        }
+                        if locking != nil {
+                                result.Ignored = append(result.Ignored, i)
+                        }
+                }
+
+                preview = append(preview, models.PropertyDraft{
+                        NodeID:       change.NodeID,
+                        Key:          change.Key,
+                        Environment:  change.Environment,
+                        Value:        change.Value,
+                        DataType:     change.DataType,
+                        DefaultValue: change.DefaultValue,
+                        Description:  change.Description,
+                        Delete:       change.Delete,
+                })
+        }
+
+        opts := models.ResolveOptions{Environment: environment, Context: context}
+        current, err := h.repo.ResolveConfiguration(c.Request.Context(), nodeID, opts)
+        if err != nil {
+                writeSimulationError(c, err)
+                return
+        }
+        opts.Preview = preview
+        result.Resolved, err = h.repo.ResolveConfiguration(c.Request.Context(), nodeID, opts)
+        if err != nil {
+                writeSimulationError(c, err)
+                return
+        }
+
+        result.PropertyChanges = diffProperties(current.Properties, result.Resolved.Properties)
+        c.JSON(http.StatusOK, result)
+}
+
+// writeSimulationError maps resolution errors of a simulation to responses
+func writeSimulationError(c *gin.Context, err error) {
+        switch {
+        case errors.Is(err, database.ErrNodeNotFound):
+                problem.Respond(c, http.StatusNotFound, problem.CodeNodeNotFound, "Node not found")
+        default:
+                respondError(c, err, "Failed to resolve configuration")
+        }
+}
@@ -0,0 +1,144 @@
+package handlers
+
+import (
+        "config-manager/internal/auth"
+        "config-manager/internal/database"
+        "config-manager/internal/models"
+        "config-manager/internal/problem"
+        "errors"
+        "net/http"
+        "strconv"
+
+        "github.com/gin-gonic/gin"
+)
+
+// Snapshot handlers
+func (h *Handler) CreateSnapshot(c *gin.Context) {
+        nodeIDStr := c.Param("nodeId")
+        nodeID, err := strconv.ParseInt(nodeIDStr, 10, 64)
+        if err != nil {
+                problem.Respond(c, http.StatusBadRequest, problem.CodeInvalidID, "Invalid node ID")
+                return
+        }
+
+        if !h.authorize(c, nodeID, models.PermissionWrite) {
+                return
+        }
+
+        var req models.CreateSnapshotRequest
+        if err := c.ShouldBindJSON(&req); err != nil {
+                problem.Respond(c, http.StatusBadRequest, problem.CodeInvalidRequest, err.Error())
+                return
+        }
+        if req.Environment != "" && !environmentPattern.MatchString(req.Environment) {
+                problem.Respond(c, http.StatusBadRequest, problem.CodeInvalidEnvironment, "Invalid environment")
+                return
+        }
+
+        snapshot, err := h.repo.CreateSnapshot(c.Request.Context(), nodeID, req, auth.Identity(c))
+        if err != nil {
+                writeSnapshotError(c, err, "Failed to create snapshot")
+                return
+        }
+
+        c.JSON(http.StatusCreated, snapshot)
+}
+
+func (h *Handler) GetSnapshots(c *gin.Context) {
+        nodeIDStr := c.Param("nodeId")
+        nodeID, err := strconv.ParseInt(nodeIDStr, 10, 64)
+        if err != nil {
+                problem.Respond(c, http.StatusBadRequest, problem.CodeInvalidID, "Invalid node ID")
+                return
+        }
+
+        if !h.authorize(c, nodeID, models.PermissionRead) {
+                return
+        }
+
+        snapshots, err := h.repo.GetSnapshots(c.Request.Context(), nodeID)
+        if err != nil {
+                respondError(c, err, "Failed to get snapshots")
+                return
+        }
+
+        c.JSON(http.StatusOK, snapshots)
+}
+
+func (h *Handler) GetSnapshot(c *gin.Context) {
+        snapshot, ok := h.snapshotParam(c, "id")
+        if !ok {
+                return
+        }
+
+        c.JSON(http.StatusOK, snapshot)
+}
+
+// CompareSnapshot compares a snapshot with the snapshot given as ?to=, or with
+// the current configuration of its node when ?to= is omitted
+func (h *Handler) CompareSnapshot(c *gin.Context) {
+        left, ok := h.snapshotParam(c, "id")
+        if !ok {
+                return
+        }
+
+        diff := models.SnapshotDiff{Left: left.ID}
+        if c.Query("to") != "" {
+                right, ok := h.snapshotParam(c, "to")
+                if !ok {
+                        return
+                }
+                diff.Right = &right.ID
+                diff.PropertyChanges = diffProperties(left.Properties, right.Properties)
+        } else {
+                current, _, err := h.repo.ResolveRedacted(c.Request.Context(), left.NodeID, left.Environment)
+                if err != nil {
+                        writeSnapshotError(c, err, "Failed to resolve configuration")
+                        return
+                }
+                diff.PropertyChanges = diffProperties(left.Properties, current.Properties)
+        }
+
+        c.JSON(http.StatusOK, diff)
+}
+
+// snapshotParam loads the snapshot whose ID is given by the named path or query
+// parameter and authorizes reading it, writing an error response on failure
+func (h *Handler) snapshotParam(c *gin.Context, name string) (*models.ConfigSnapshot, bool) {
+        value := c.Param(name)
+        if value == "" {
+                value = c.Query(name)
+        }
+        id, err := strconv.ParseInt(value, 10, 64)
+        if err != nil {
+                problem.Respond(c, http.StatusBadRequest, problem.CodeInvalidID, "Invalid snapshot ID")
+                return nil, false
+        }
+
+        snapshot, err := h.repo.GetSnapshot(c.Request.Context(), id)
+        if err != nil {
+                respondError(c, err, "Failed to get snapshot")
+                return nil, false
+        }
+        if snapshot == nil {
+                problem.Respond(c, http.StatusNotFound, problem.CodeNotFound, "Snapshot " + value + " not found")
+                return nil, false
+        }
+
+        if !h.authorize(c, snapshot.NodeID, models.PermissionRead) {
+                return nil, false
+        }
+        return snapshot, true
+}
+
+// writeSnapshotError maps snapshot repository errors to responses
+func writeSnapshotError(c *gin.Context, err error, fallback string) {
+        switch {
+        case errors.Is(err, database.ErrNodeNotFound):
+                problem.Respond(c, http.StatusNotFound, problem.CodeNodeNotFound, "Node not found")
+        case errors.Is(err, database.ErrSnapshotNameTaken):
+                problem.Respond(c, http.StatusConflict, problem.CodeNameTaken, err.Error())
+        default:
+                respondError(c, err, fallback)
+        }
+}
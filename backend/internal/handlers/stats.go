@@ -0,0 +1,40 @@
+package handlers
+
+import (
+        "config-manager/internal/problem"
+        "net/http"
+        "strconv"
+
+        "github.com/gin-gonic/gin"
+)
+
+const (
+        defaultStatsTopKeys = 10
+        maxStatsTopKeys     = 100
+)
+
+// GetStats summarizes the tree for the operations dashboard, listing the
+// ?top_keys= most overridden keys
+func (h *Handler) GetStats(c *gin.Context) {
+        if !h.authorizeAdmin(c) {
+                return
+        }
+
+        topKeys := defaultStatsTopKeys
+        if topKeysStr := c.Query("top_keys"); topKeysStr != "" {
+                var err error
+                topKeys, err = strconv.Atoi(topKeysStr)
+                if err != nil || topKeys < 1 || topKeys > maxStatsTopKeys {
+                        problem.Respond(c, http.StatusBadRequest, problem.CodeInvalidRequest, "top_keys must be between 1 and 100")
+                        return
+                }
+        }
+
+        stats, err := h.reads(c).GetStats(c.Request.Context(), topKeys)
+        if err != nil {
+                respondError(c, err, "Failed to get stats")
+                return
+        }
+
+        c.JSON(http.StatusOK, stats)
+}
@@ -0,0 +1,40 @@
+package handlers
+
+import (
+        "config-manager/internal/problem"
+        "config-manager/internal/syncer"
+        "errors"
+        "net/http"
+
+        "github.com/gin-gonic/gin"
+)
+
+// UseSSMSync serves the drift report of the AWS SSM Parameter Store sync
+func (h *Handler) UseSSMSync(controller *syncer.Controller) {
+        h.ssmSync = controller
+}
+
+// GetSSMDrift reports the changes the next SSM sync would make, reading the
+// bound parameters from AWS
+func (h *Handler) GetSSMDrift(c *gin.Context) {
+        if !h.authorizeAdmin(c) {
+                return
+        }
+
+        if h.ssmSync == nil {
+                problem.Respond(c, http.StatusNotImplemented, problem.CodeNotImplemented, "SSM sync is not configured")
+                return
+        }
+
+        drifts, err := h.ssmSync.Drift(c.Request.Context())
+        if err != nil {
+                if errors.Is(err, syncer.ErrDriftNotSupported) {
+                        problem.Respond(c, http.StatusNotImplemented, problem.CodeNotImplemented, err.Error())
+                        return
+                }
+                respondError(c, err, "Failed to report SSM drift")
+                return
+        }
+
+        c.JSON(http.StatusOK, drifts)
+}
@@ -0,0 +1,142 @@
+package handlers
+
+import (
+        "config-manager/internal/database"
+        "config-manager/internal/models"
+        "config-manager/internal/problem"
+        "fmt"
+        "net/http"
+        "regexp"
+        "strconv"
+
+        "github.com/gin-gonic/gin"
+)
+
+// tagPattern restricts property tags to lowercase slugs such as "restart-required"
+var tagPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,49}$`)
+
+// maxPropertyTags bounds the tags of a property
+const maxPropertyTags = 20
+
+// validateTags checks the tags a property is written with, or filtered by
+func validateTags(tags []string) error {
+        if len(tags) > maxPropertyTags {
+                return fmt.Errorf("a property may have at most %d tags", maxPropertyTags)
+        }
+        for _, tag := range tags {
+                if !tagPattern.MatchString(tag) {
+                        return fmt.Errorf("invalid tag %q", tag)
+                }
+        }
+        return nil
+}
+
+// SearchProperties lists the properties carrying every ?tag= across the tree,
+// or within the subtree given as ?nodeId=, leaving out nodes the request may
+// not read
+func (h *Handler) SearchProperties(c *gin.Context) {
+        tags := c.QueryArray("tag")
+        if len(tags) == 0 {
+                problem.Respond(c, http.StatusBadRequest, problem.CodeInvalidRequest, "At least one tag is required")
+                return
+        }
+        if err := validateTags(tags); err != nil {
+                problem.Respond(c, http.StatusBadRequest, problem.CodeInvalidRequest, err.Error())
+                return
+        }
+
+        rootID, ok := h.searchRoot(c)
+        if !ok {
+                return
+        }
+
+        results, err := h.reads(c).SearchPropertiesByTags(c.Request.Context(), tags, rootID)
+        if err != nil {
+                respondError(c, err, "Failed to search properties")
+                return
+        }
+
+        if rootID == nil {
+                if results, err = h.filterReadableResults(c, results); err != nil {
+                        respondError(c, err, "Failed to check permissions")
+                        return
+                }
+        }
+
+        maskSecretResults(results)
+        c.JSON(http.StatusOK, results)
+}
+
+// searchRoot returns the subtree given as ?nodeId= to search, or nil for the
+// whole tree. It writes an error response and returns false if the node is
+// invalid or not readable.
+func (h *Handler) searchRoot(c *gin.Context) (*int64, bool) {
+        value := c.Query("nodeId")
+        if value == "" {
+                return nil, true
+        }
+        id, err := strconv.ParseInt(value, 10, 64)
+        if err != nil {
+                problem.Respond(c, http.StatusBadRequest, problem.CodeInvalidID, "Invalid node ID")
+                return nil, false
+        }
+        if !h.authorize(c, id, models.PermissionRead) {
+                return nil, false
+        }
+        return &id, true
+}
+
+// filterReadableResults drops the search results on nodes the request may not read
+func (h *Handler) filterReadableResults(c *gin.Context, results []models.PropertySearchResult) ([]models.PropertySearchResult, error) {
+        if h.acl.IsAdmin(c) {
+                return results, nil
+        }
+
+        readable := make(map[int64]bool)
+        visible := []models.PropertySearchResult{}
+        for _, result := range results {
+                allowed, checked := readable[result.NodeID]
+                if !checked {
+                        var err error
+                        if allowed, err = h.acl.Allowed(c, result.NodeID, models.PermissionRead); err != nil {
+                                return nil, err
+                        }
+                        readable[result.NodeID] = allowed
+                }
+                if allowed {
+                        visible = append(visible, result)
+                }
+        }
+        return visible, nil
+}
+
+func nonNilTags(tags []string) []string {
+        if tags == nil {
+                return []string{}
+        }
+        return tags
+}
+
+// filterPropertyQuery narrows the properties of a node to the ?namespace= and
+// ?tag= filters of the request, writing an error response and returning false
+// if they are invalid
+func filterPropertyQuery(c *gin.Context, properties []models.ConfigProperty) ([]models.ConfigProperty, bool) {
+        namespace := c.Query("namespace")
+        if namespace != "" && !namespacePattern.MatchString(namespace) {
+                problem.Respond(c, http.StatusBadRequest, problem.CodeInvalidNamespace, "Invalid namespace")
+                return nil, false
+        }
+        tags := c.QueryArray("tag")
+        if err := validateTags(tags); err != nil {
+                problem.Respond(c, http.StatusBadRequest, problem.CodeInvalidRequest, err.Error())
+                return nil, false
+        }
+
+        if namespace != "" {
+                properties = database.FilterNamespace(properties, namespace)
+        }
+        if len(tags) > 0 {
+                properties = database.FilterTags(properties, tags)
+        }
+        return properties, true
+}
@@ -0,0 +1,335 @@
+package handlers
+
+import (
+        "config-manager/internal/auth"
+        "config-manager/internal/database"
+        "config-manager/internal/flags"
+        "config-manager/internal/models"
+        "config-manager/internal/problem"
+        "encoding/json"
+        "errors"
+        "fmt"
+        "net/http"
+        "strconv"
+
+        "github.com/gin-gonic/gin"
+)
+
+// Template handlers
+func (h *Handler) CreateTemplate(c *gin.Context) {
+        if !h.authorizeAdmin(c) {
+                return
+        }
+
+        var req models.CreateTemplateRequest
+        if err := c.ShouldBindJSON(&req); err != nil {
+                problem.Respond(c, http.StatusBadRequest, problem.CodeInvalidRequest, err.Error())
+                return
+        }
+        if err := validateTemplateProperties(req.Properties); err != nil {
+                respondInvalid(c, err)
+                return
+        }
+        if !h.guardRegisteredKeys(c, templateKeys(req.Properties)...) {
+                return
+        }
+
+        template, err := h.repo.CreateTemplate(c.Request.Context(), req, auth.Identity(c))
+        if errors.Is(err, database.ErrTemplateNameTaken) {
+                problem.Respond(c, http.StatusConflict, problem.CodeNameTaken, err.Error())
+                return
+        }
+        if err != nil {
+                respondError(c, err, "Failed to create template")
+                return
+        }
+
+        c.JSON(http.StatusCreated, template)
+}
+
+func (h *Handler) GetTemplates(c *gin.Context) {
+        templates, err := h.repo.GetTemplates(c.Request.Context())
+        if err != nil {
+                respondError(c, err, "Failed to get templates")
+                return
+        }
+
+        c.JSON(http.StatusOK, templates)
+}
+
+func (h *Handler) GetTemplate(c *gin.Context) {
+        id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+        if err != nil {
+                problem.Respond(c, http.StatusBadRequest, problem.CodeInvalidID, "Invalid template ID")
+                return
+        }
+
+        template, err := h.repo.GetTemplate(c.Request.Context(), id)
+        if err != nil {
+                respondError(c, err, "Failed to get template")
+                return
+        }
+        if template == nil {
+                problem.Respond(c, http.StatusNotFound, problem.CodeTemplateNotFound, "Template not found")
+                return
+        }
+
+        c.JSON(http.StatusOK, template)
+}
+
+func (h *Handler) DeleteTemplate(c *gin.Context) {
+        if !h.authorizeAdmin(c) {
+                return
+        }
+
+        id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+        if err != nil {
+                problem.Respond(c, http.StatusBadRequest, problem.CodeInvalidID, "Invalid template ID")
+                return
+        }
+
+        deleted, err := h.repo.DeleteTemplate(c.Request.Context(), id)
+        if errors.Is(err, database.ErrTemplateInUse) {
+                problem.Respond(c, http.StatusConflict, problem.CodeInUse, "Template is attached to nodes, detach it first")
+                return
+        }
+        if err != nil {
+                respondError(c, err, "Failed to delete template")
+                return
+        }
+        if !deleted {
+                problem.Respond(c, http.StatusNotFound, problem.CodeTemplateNotFound, "Template not found")
+                return
+        }
+
+        c.JSON(http.StatusNoContent, nil)
+}
+
+// CreateTemplateVersion publishes new properties for a template. Nodes that
+// follow the latest version resolve them immediately.
+func (h *Handler) CreateTemplateVersion(c *gin.Context) {
+        if !h.authorizeAdmin(c) {
+                return
+        }
+
+        id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+        if err != nil {
+                problem.Respond(c, http.StatusBadRequest, problem.CodeInvalidID, "Invalid template ID")
+                return
+        }
+
+        var req models.CreateTemplateVersionRequest
+        if err := c.ShouldBindJSON(&req); err != nil {
+                problem.Respond(c, http.StatusBadRequest, problem.CodeInvalidRequest, err.Error())
+                return
+        }
+        if err := validateTemplateProperties(req.Properties); err != nil {
+                respondInvalid(c, err)
+                return
+        }
+        if !h.guardRegisteredKeys(c, templateKeys(req.Properties)...) {
+                return
+        }
+
+        version, err := h.repo.CreateTemplateVersion(c.Request.Context(), id, req, auth.Identity(c))
+        if errors.Is(err, database.ErrTemplateNotFound) {
+                problem.Respond(c, http.StatusNotFound, problem.CodeTemplateNotFound, "Template not found")
+                return
+        }
+        if err != nil {
+                respondError(c, err, "Failed to create template version")
+                return
+        }
+
+        c.JSON(http.StatusCreated, version)
+}
+
+func (h *Handler) GetTemplateVersions(c *gin.Context) {
+        id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+        if err != nil {
+                problem.Respond(c, http.StatusBadRequest, problem.CodeInvalidID, "Invalid template ID")
+                return
+        }
+
+        versions, err := h.repo.GetTemplateVersions(c.Request.Context(), id)
+        if err != nil {
+                respondError(c, err, "Failed to get template versions")
+                return
+        }
+        if len(versions) == 0 {
+                problem.Respond(c, http.StatusNotFound, problem.CodeTemplateNotFound, "Template not found")
+                return
+        }
+
+        c.JSON(http.StatusOK, versions)
+}
+
+func (h *Handler) GetTemplateVersion(c *gin.Context) {
+        id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+        if err != nil {
+                problem.Respond(c, http.StatusBadRequest, problem.CodeInvalidID, "Invalid template ID")
+                return
+        }
+        version, err := strconv.Atoi(c.Param("version"))
+        if err != nil {
+                problem.Respond(c, http.StatusBadRequest, problem.CodeInvalidID, "Invalid version")
+                return
+        }
+
+        templateVersion, err := h.repo.GetTemplateVersion(c.Request.Context(), id, version)
+        if err != nil {
+                respondError(c, err, "Failed to get template version")
+                return
+        }
+        if templateVersion == nil {
+                problem.Respond(c, http.StatusNotFound, problem.CodeTemplateNotFound, "Template version not found")
+                return
+        }
+
+        c.JSON(http.StatusOK, templateVersion)
+}
+
+// Node template handlers
+func (h *Handler) GetNodeTemplates(c *gin.Context) {
+        nodeID, err := strconv.ParseInt(c.Param("nodeId"), 10, 64)
+        if err != nil {
+                problem.Respond(c, http.StatusBadRequest, problem.CodeInvalidID, "Invalid node ID")
+                return
+        }
+
+        if !h.authorize(c, nodeID, models.PermissionRead) {
+                return
+        }
+
+        attached, err := h.repo.GetNodeTemplates(c.Request.Context(), nodeID)
+        if err != nil {
+                respondError(c, err, "Failed to get node templates")
+                return
+        }
+
+        c.JSON(http.StatusOK, attached)
+}
+
+// AttachTemplate attaches a template to a node, or changes the version an
+// attached template is pinned to
+func (h *Handler) AttachTemplate(c *gin.Context) {
+        nodeID, err := strconv.ParseInt(c.Param("nodeId"), 10, 64)
+        if err != nil {
+                problem.Respond(c, http.StatusBadRequest, problem.CodeInvalidID, "Invalid node ID")
+                return
+        }
+
+        if !h.authorize(c, nodeID, models.PermissionWrite) {
+                return
+        }
+
+        if !h.guardProtected(c, nodeID, false) {
+                return
+        }
+
+        var req models.AttachTemplateRequest
+        if err := c.ShouldBindJSON(&req); err != nil {
+                problem.Respond(c, http.StatusBadRequest, problem.CodeInvalidRequest, err.Error())
+                return
+        }
+
+        node, err := h.repo.GetNodeByID(c.Request.Context(), nodeID)
+        if err != nil {
+                respondError(c, err, "Failed to get node")
+                return
+        }
+        if node == nil {
+                problem.Respond(c, http.StatusNotFound, problem.CodeNodeNotFound, "Node not found")
+                return
+        }
+
+        attached, err := h.repo.AttachTemplate(c.Request.Context(), nodeID, req, auth.Identity(c))
+        if errors.Is(err, database.ErrTemplateNotFound) || errors.Is(err, database.ErrTemplateVersionNotFound) {
+                problem.Respond(c, http.StatusBadRequest, problem.CodeTemplateNotFound, err.Error())
+                return
+        }
+        if err != nil {
+                respondError(c, err, "Failed to attach template")
+                return
+        }
+
+        c.JSON(http.StatusOK, attached)
+}
+
+func (h *Handler) DetachTemplate(c *gin.Context) {
+        nodeID, err := strconv.ParseInt(c.Param("nodeId"), 10, 64)
+        if err != nil {
+                problem.Respond(c, http.StatusBadRequest, problem.CodeInvalidID, "Invalid node ID")
+                return
+        }
+        templateID, err := strconv.ParseInt(c.Param("templateId"), 10, 64)
+        if err != nil {
+                problem.Respond(c, http.StatusBadRequest, problem.CodeInvalidID, "Invalid template ID")
+                return
+        }
+
+        if !h.authorize(c, nodeID, models.PermissionWrite) {
+                return
+        }
+
+        if !h.guardProtected(c, nodeID, false) {
+                return
+        }
+
+        detached, err := h.repo.DetachTemplate(c.Request.Context(), nodeID, templateID)
+        if err != nil {
+                respondError(c, err, "Failed to detach template")
+                return
+        }
+        if !detached {
+                problem.Respond(c, http.StatusNotFound, problem.CodeTemplateNotFound, "Template is not attached to this node")
+                return
+        }
+
+        c.JSON(http.StatusNoContent, nil)
+}
+
+// validateTemplateProperties checks the properties of a template version like
+// property writes, and that each key is given once per environment
+func validateTemplateProperties(properties []models.TemplateProperty) error {
+        seen := make(map[string]bool, len(properties))
+        for _, prop := range properties {
+                if err := checkValueSize(prop.Value); err != nil {
+                        return fmt.Errorf("%s: %w", prop.Key, err)
+                }
+                var value interface{}
+                if err := json.Unmarshal([]byte(prop.Value), &value); err != nil {
+                        return fmt.Errorf("value of %s must be valid JSON", prop.Key)
+                }
+                if !validDataTypes[prop.DataType] {
+                        return fmt.Errorf("invalid data type for %s", prop.Key)
+                }
+                if prop.DataType == models.DataTypeFlag {
+                        if _, err := flags.Parse(prop.Value); err != nil {
+                                return fmt.Errorf("%s: %v", prop.Key, err)
+                        }
+                }
+
+                environment := ""
+                if prop.Environment != nil {
+                        if !environmentPattern.MatchString(*prop.Environment) {
+                                return fmt.Errorf("invalid environment for %s", prop.Key)
+                        }
+                        environment = *prop.Environment
+                }
+                if seen[prop.Key+"\x00"+environment] {
+                        return fmt.Errorf("%s is given more than once for the same environment", prop.Key)
+                }
+                seen[prop.Key+"\x00"+environment] = true
+        }
+        return nil
+}
+
+// templateKeys returns the keys the properties of a template version define
+func templateKeys(properties []models.TemplateProperty) []string {
+        keys := make([]string, len(properties))
+        for i, prop := range properties {
+                keys[i] = prop.Key
+        }
+        return keys
+}
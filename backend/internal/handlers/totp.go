@@ -0,0 +1,22 @@
+package handlers
+
+import (
+        "net/http"
+
+        "github.com/gin-gonic/gin"
+)
+
+// ResetTOTP removes the authenticator app of a user who lost it, so that they
+// can enroll a new one
+func (h *Handler) ResetTOTP(c *gin.Context) {
+        if !h.authorizeAdmin(c) {
+                return
+        }
+
+        if err := h.repo.DeleteTOTPEnrollment(c.Request.Context(), c.Param("subject")); err != nil {
+                respondError(c, err, "Failed to reset two-factor authentication")
+                return
+        }
+
+        c.JSON(http.StatusNoContent, nil)
+}
@@ -0,0 +1,79 @@
+package handlers
+
+import (
+        "config-manager/internal/models"
+        "config-manager/internal/problem"
+        "net/http"
+        "strconv"
+
+        "github.com/gin-gonic/gin"
+)
+
+// GetTree returns the subtree of the ?root= node, or every readable root
+// with its subtree, as nested nodes in one response. ?depth= limits how many
+// levels below the roots are loaded; the nodes at the limit have null
+// children.
+func (h *Handler) GetTree(c *gin.Context) {
+        depth := -1
+        if depthStr := c.Query("depth"); depthStr != "" {
+                var err error
+                depth, err = strconv.Atoi(depthStr)
+                if err != nil || depth < 0 {
+                        problem.Respond(c, http.StatusBadRequest, problem.CodeInvalidRequest, "depth must be a non-negative integer")
+                        return
+                }
+        }
+
+        if rootStr := c.Query("root"); rootStr != "" {
+                rootID, err := strconv.ParseInt(rootStr, 10, 64)
+                if err != nil {
+                        problem.Respond(c, http.StatusBadRequest, problem.CodeInvalidID, "Invalid root node ID")
+                        return
+                }
+                if !h.authorize(c, rootID, models.PermissionRead) {
+                        return
+                }
+
+                trees, err := h.reads(c).GetTree(c.Request.Context(), &rootID, depth)
+                if err != nil {
+                        respondError(c, err, "Failed to get tree")
+                        return
+                }
+                if len(trees) == 0 {
+                        problem.Respond(c, http.StatusNotFound, problem.CodeNodeNotFound, "Node not found")
+                        return
+                }
+
+                c.JSON(http.StatusOK, trees[0])
+                return
+        }
+
+        trees, err := h.reads(c).GetTree(c.Request.Context(), nil, depth)
+        if err != nil {
+                respondError(c, err, "Failed to get tree")
+                return
+        }
+
+        // Like the root node list, only the readable roots are returned
+        roots := make([]models.ConfigNode, len(trees))
+        for i, tree := range trees {
+                roots[i] = tree.ConfigNode
+        }
+        readable, err := h.filterReadable(c, roots)
+        if err != nil {
+                respondError(c, err, "Failed to check permissions")
+                return
+        }
+        allowed := make(map[int64]bool, len(readable))
+        for _, root := range readable {
+                allowed[root.ID] = true
+        }
+        visible := []models.ConfigTreeNode{}
+        for _, tree := range trees {
+                if allowed[tree.ID] {
+                        visible = append(visible, tree)
+                }
+        }
+
+        c.JSON(http.StatusOK, visible)
+}
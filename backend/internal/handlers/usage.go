@@ -0,0 +1,59 @@
+package handlers
+
+import (
+        "config-manager/internal/auth"
+        "config-manager/internal/problem"
+        "context"
+        "log"
+        "net/http"
+        "strings"
+        "time"
+
+        "github.com/gin-gonic/gin"
+)
+
+// recordAccess notes that a consumer read a node's configuration. Failures only
+// weaken the delete guard, so they are logged rather than failing the read.
+func (h *Handler) recordAccess(ctx context.Context, nodeID int64) {
+        if h.deleteGuard <= 0 {
+                return
+        }
+        if err := h.repo.RecordNodeAccess(ctx, nodeID); err != nil {
+                log.Printf("Failed to record access to node %d: %v", nodeID, err)
+        }
+}
+
+// guardDelete writes an error response and returns false if consumers resolved
+// the subtree rooted at nodeID within the guard window, unless the request sets
+// ?force=true and gives a reason, which is logged
+func (h *Handler) guardDelete(c *gin.Context, nodeID int64) bool {
+        if h.deleteGuard <= 0 {
+                return true
+        }
+
+        lastAccess, err := h.repo.LastSubtreeAccess(c.Request.Context(), nodeID)
+        if err != nil {
+                respondError(c, err, "Failed to check configuration usage")
+                return false
+        }
+        if lastAccess == nil || time.Since(*lastAccess) > h.deleteGuard {
+                return true
+        }
+
+        if c.Query("force") != "true" {
+                problem.Respond(c, http.StatusConflict, problem.CodeInUse, "Configuration is in use; retry with ?force=true&reason=... to delete it", gin.H{
+                        "last_accessed_at": lastAccess,
+                })
+                return false
+        }
+
+        reason := strings.TrimSpace(c.Query("reason"))
+        if reason == "" {
+                problem.Respond(c, http.StatusBadRequest, problem.CodeInvalidRequest, "A reason is required to force deletion of configuration in use")
+                return false
+        }
+
+        log.Printf("Forced deletion under node %d (last accessed %s) by %v: %s",
+                nodeID, lastAccess.Format(time.RFC3339), auth.Principals(c), reason)
+        return true
+}
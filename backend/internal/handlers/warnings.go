@@ -0,0 +1,108 @@
+package handlers
+
+import (
+        "config-manager/internal/flags"
+        "config-manager/internal/models"
+        "context"
+        "encoding/json"
+        "fmt"
+        "log"
+)
+
+// keyLengthWarningThreshold is the key length above which writes are warned
+// about, as keys are limited to 255 characters
+const keyLengthWarningThreshold = 200
+
+// propertyWarnings computes non-fatal advice about a property that was just
+// written. Warnings are best effort: lookup failures are logged, not returned.
+func (h *Handler) propertyWarnings(ctx context.Context, prop *models.ConfigProperty) []models.ValidationWarning {
+        warnings := []models.ValidationWarning{}
+
+        if !valueMatchesDataType(prop.Value, prop.DataType) {
+                warnings = append(warnings, models.ValidationWarning{
+                        Code:    "data_type_mismatch",
+                        Message: fmt.Sprintf("value does not look like a JSON %s", prop.DataType),
+                })
+        }
+
+        if len(prop.Key) > keyLengthWarningThreshold {
+                warnings = append(warnings, models.ValidationWarning{
+                        Code:    "key_near_limit",
+                        Message: fmt.Sprintf("key is %d characters long, the limit is 255", len(prop.Key)),
+                })
+        }
+
+        deprecations, err := h.repo.GetKeyDeprecations(ctx, []string{prop.Key})
+        if err != nil {
+                log.Printf("Failed to check whether %s is deprecated: %v", prop.Key, err)
+        }
+        for _, deprecation := range deprecations {
+                warnings = append(warnings, models.ValidationWarning{
+                        Code:    "deprecated_key",
+                        Message: deprecationMessage(deprecation),
+                })
+        }
+
+        registered, err := h.repo.GetRegisteredKey(ctx, prop.Key)
+        if err != nil {
+                log.Printf("Failed to look up %s in the key registry: %v", prop.Key, err)
+        } else if registered != nil && registered.ExpectedType != nil && *registered.ExpectedType != prop.DataType {
+                warnings = append(warnings, models.ValidationWarning{
+                        Code:    "unexpected_data_type",
+                        Message: fmt.Sprintf("the key registry expects the %s type", *registered.ExpectedType),
+                })
+        }
+
+        inherited, err := h.repo.GetInheritedProperty(ctx, prop.NodeID, prop.Key, prop.Environment)
+        if err != nil {
+                log.Printf("Failed to check inherited property %s for node %d: %v", prop.Key, prop.NodeID, err)
+        } else if inherited != nil {
+                warnings = append(warnings, models.ValidationWarning{
+                        Code:    "overrides_inherited",
+                        Message: fmt.Sprintf("overrides the value inherited from node %d", inherited.NodeID),
+                })
+        }
+
+        if prop.IsFinal {
+                overrides, err := h.repo.CountDescendantDefinitions(ctx, prop.NodeID, prop.Key)
+                if err != nil {
+                        log.Printf("Failed to count overrides of final property %s for node %d: %v", prop.Key, prop.NodeID, err)
+                } else if overrides > 0 {
+                        warnings = append(warnings, models.ValidationWarning{
+                                Code:    "final_ignores_overrides",
+                                Message: fmt.Sprintf("%d descendant properties define this key and are now ignored", overrides),
+                        })
+                }
+        }
+
+        return warnings
+}
+
+// valueMatchesDataType reports whether a serialized JSON value has the declared type
+func valueMatchesDataType(value string, dataType models.DataType) bool {
+        if dataType == models.DataTypeFlag {
+                _, err := flags.Parse(value)
+                return err == nil
+        }
+
+        var decoded interface{}
+        if err := json.Unmarshal([]byte(value), &decoded); err != nil {
+                return false
+        }
+
+        switch decoded.(type) {
+        case string:
+                return dataType == models.DataTypeString
+        case float64:
+                return dataType == models.DataTypeNumber
+        case bool:
+                return dataType == models.DataTypeBoolean
+        case map[string]interface{}:
+                return dataType == models.DataTypeObject
+        case []interface{}:
+                return dataType == models.DataTypeArray
+        case nil:
+                return dataType == models.DataTypeNull
+        }
+        return false
+}
@@ -0,0 +1,103 @@
+package handlers
+
+import (
+        "config-manager/internal/database"
+        "config-manager/internal/models"
+        "config-manager/internal/problem"
+        "errors"
+        "net/http"
+        "strconv"
+        "strings"
+        "time"
+
+        "github.com/gin-gonic/gin"
+)
+
+const (
+        defaultWatchTimeout = 30 * time.Second
+        maxWatchTimeout     = 5 * time.Minute
+        // watchPollInterval bounds how late a watch notices changes announced
+        // on another server, or on none when running without change events
+        watchPollInterval = 5 * time.Second
+)
+
+// WatchConfiguration long-polls the resolved configuration of a node. It
+// responds as soon as the hash of the configuration differs from ?hash= (or
+// If-None-Match), with the configuration and its new hash as the ETag, and with
+// 304 Not Modified when ?timeout= seconds pass without a change.
+func (h *Handler) WatchConfiguration(c *gin.Context) {
+        nodeID, err := strconv.ParseInt(c.Param("nodeId"), 10, 64)
+        if err != nil {
+                problem.Respond(c, http.StatusBadRequest, problem.CodeInvalidID, "Invalid node ID")
+                return
+        }
+
+        if !h.authorize(c, nodeID, models.PermissionRead) {
+                return
+        }
+
+        environment, context, ok := resolveQuery(c)
+        if !ok {
+                return
+        }
+
+        timeout := defaultWatchTimeout
+        if timeoutStr := c.Query("timeout"); timeoutStr != "" {
+                seconds, err := strconv.Atoi(timeoutStr)
+                if err != nil || seconds < 1 || time.Duration(seconds)*time.Second > maxWatchTimeout {
+                        problem.Respond(c, http.StatusBadRequest, problem.CodeInvalidRequest, "timeout must be between 1 and 300 seconds")
+                        return
+                }
+                timeout = time.Duration(seconds) * time.Second
+        }
+
+        known := c.Query("hash")
+        if known == "" {
+                known = strings.Trim(c.GetHeader("If-None-Match"), `"`)
+        }
+
+        // The response may be written after the server's write timeout
+        http.NewResponseController(c.Writer).SetWriteDeadline(time.Now().Add(timeout + 10*time.Second))
+
+        var notify <-chan struct{}
+        if h.changes != nil {
+                var unsubscribe func()
+                notify, unsubscribe = h.changes.Subscribe()
+                defer unsubscribe()
+        }
+        deadline := time.NewTimer(timeout)
+        defer deadline.Stop()
+        poll := time.NewTicker(watchPollInterval)
+        defer poll.Stop()
+
+        h.recordAccess(c.Request.Context(), nodeID)
+        for {
+                resolved, err := h.repo.ResolveConfiguration(c.Request.Context(), nodeID, models.ResolveOptions{Environment: environment, Context: context})
+                if errors.Is(err, database.ErrNodeNotFound) {
+                        problem.Respond(c, http.StatusNotFound, problem.CodeNodeNotFound, "Node not found")
+                        return
+                }
+                if err != nil {
+                        respondError(c, err, "Failed to resolve configuration")
+                        return
+                }
+
+                hash := resolved.Checksum
+                if hash != known {
+                        c.Header("ETag", strconv.Quote(hash))
+                        h.respondResolved(c, resolved)
+                        return
+                }
+
+                select {
+                case <-c.Request.Context().Done():
+                        return
+                case <-deadline.C:
+                        c.Header("ETag", strconv.Quote(hash))
+                        c.Status(http.StatusNotModified)
+                        return
+                case <-notify:
+                case <-poll.C:
+                }
+        }
+}
@@ -0,0 +1,269 @@
+package handlers
+
+import (
+        "config-manager/internal/database"
+        "config-manager/internal/models"
+        "config-manager/internal/problem"
+        "errors"
+        "net/http"
+        "strconv"
+
+        "github.com/gin-gonic/gin"
+)
+
+// Workspace handlers
+func (h *Handler) CreateWorkspace(c *gin.Context) {
+        var req models.CreateWorkspaceRequest
+        if err := c.ShouldBindJSON(&req); err != nil {
+                problem.Respond(c, http.StatusBadRequest, problem.CodeInvalidRequest, err.Error())
+                return
+        }
+
+        if !h.authorize(c, req.RootNodeID, models.PermissionWrite) {
+                return
+        }
+
+        root, err := h.repo.GetNodeByID(c.Request.Context(), req.RootNodeID)
+        if err != nil {
+                respondError(c, err, "Failed to validate root node")
+                return
+        }
+        if root == nil {
+                problem.Respond(c, http.StatusBadRequest, problem.CodeNodeNotFound, "Root node not found")
+                return
+        }
+
+        workspace, err := h.repo.CreateWorkspace(c.Request.Context(), req)
+        if err != nil {
+                respondError(c, err, "Failed to create workspace")
+                return
+        }
+
+        c.JSON(http.StatusCreated, workspace)
+}
+
+func (h *Handler) GetWorkspaces(c *gin.Context) {
+        workspaces, err := h.repo.GetWorkspaces(c.Request.Context())
+        if err != nil {
+                respondError(c, err, "Failed to get workspaces")
+                return
+        }
+
+        if !h.acl.IsAdmin(c) {
+                readable := []models.Workspace{}
+                for _, workspace := range workspaces {
+                        allowed, err := h.acl.Allowed(c, workspace.RootNodeID, models.PermissionRead)
+                        if err != nil {
+                                respondError(c, err, "Failed to check permissions")
+                                return
+                        }
+                        if allowed {
+                                readable = append(readable, workspace)
+                        }
+                }
+                workspaces = readable
+        }
+
+        c.JSON(http.StatusOK, workspaces)
+}
+
+func (h *Handler) GetWorkspace(c *gin.Context) {
+        id, ok := workspaceIDParam(c)
+        if !ok {
+                return
+        }
+
+        if !h.authorizeWorkspace(c, id, models.PermissionRead) {
+                return
+        }
+
+        workspace, err := h.repo.GetWorkspace(c.Request.Context(), id)
+        if err != nil {
+                respondError(c, err, "Failed to get workspace")
+                return
+        }
+
+        if workspace == nil {
+                problem.Respond(c, http.StatusNotFound, problem.CodeWorkspaceNotFound, "Workspace not found")
+                return
+        }
+
+        c.JSON(http.StatusOK, workspace)
+}
+
+func (h *Handler) AddWorkspaceChange(c *gin.Context) {
+        id, ok := workspaceIDParam(c)
+        if !ok {
+                return
+        }
+
+        if !h.authorizeWorkspace(c, id, models.PermissionWrite) {
+                return
+        }
+
+        if !h.guardUnderReview(c, id) {
+                return
+        }
+
+        var req models.WorkspaceChangeRequest
+        if err := c.ShouldBindJSON(&req); err != nil {
+                problem.Respond(c, http.StatusBadRequest, problem.CodeInvalidRequest, err.Error())
+                return
+        }
+        if req.Op == models.WorkspaceOpSetProperty && !h.guardRegisteredKeys(c, req.Key) {
+                return
+        }
+        for _, value := range []*string{req.Value, req.DefaultValue} {
+                if value == nil {
+                        continue
+                }
+                if err := checkValueSize(*value); err != nil {
+                        respondInvalid(c, err)
+                        return
+                }
+        }
+
+        change, err := h.repo.AddWorkspaceChange(c.Request.Context(), id, req)
+        if err != nil {
+                writeWorkspaceError(c, err, "Failed to record workspace change")
+                return
+        }
+
+        c.JSON(http.StatusCreated, change)
+}
+
+func (h *Handler) ResolveInWorkspace(c *gin.Context) {
+        id, ok := workspaceIDParam(c)
+        if !ok {
+                return
+        }
+
+        if !h.authorizeWorkspace(c, id, models.PermissionRead) {
+                return
+        }
+
+        nodeIDStr := c.Param("nodeId")
+        nodeID, err := strconv.ParseInt(nodeIDStr, 10, 64)
+        if err != nil {
+                problem.Respond(c, http.StatusBadRequest, problem.CodeInvalidID, "Invalid node ID")
+                return
+        }
+
+        resolved, err := h.repo.ResolveInWorkspace(c.Request.Context(), id, nodeID)
+        if err != nil {
+                writeWorkspaceError(c, err, "Failed to resolve configuration")
+                return
+        }
+
+        if resolved == nil {
+                problem.Respond(c, http.StatusNotFound, problem.CodeNodeNotFound, "Node not found in workspace")
+                return
+        }
+
+        c.JSON(http.StatusOK, resolved)
+}
+
+func (h *Handler) MergeWorkspace(c *gin.Context) {
+        id, ok := workspaceIDParam(c)
+        if !ok {
+                return
+        }
+
+        if !h.authorizeWorkspace(c, id, models.PermissionWrite) {
+                return
+        }
+
+        if !h.guardUnderReview(c, id) {
+                return
+        }
+
+        // Protected subtrees only accept merges through approved change requests
+        workspace, err := h.repo.GetWorkspace(c.Request.Context(), id)
+        if err != nil {
+                respondError(c, err, "Failed to get workspace")
+                return
+        }
+        if workspace == nil {
+                problem.Respond(c, http.StatusNotFound, problem.CodeWorkspaceNotFound, "Workspace not found")
+                return
+        }
+        if !h.guardProtected(c, workspace.RootNodeID, true) {
+                return
+        }
+
+        result, err := h.repo.MergeWorkspace(c.Request.Context(), id)
+        if err != nil {
+                writeWorkspaceError(c, err, "Failed to merge workspace")
+                return
+        }
+
+        c.JSON(http.StatusOK, result)
+}
+
+func (h *Handler) DiscardWorkspace(c *gin.Context) {
+        id, ok := workspaceIDParam(c)
+        if !ok {
+                return
+        }
+
+        if !h.authorizeWorkspace(c, id, models.PermissionWrite) {
+                return
+        }
+
+        if !h.guardUnderReview(c, id) {
+                return
+        }
+
+        if err := h.repo.DiscardWorkspace(c.Request.Context(), id); err != nil {
+                writeWorkspaceError(c, err, "Failed to discard workspace")
+                return
+        }
+
+        c.JSON(http.StatusNoContent, nil)
+}
+
+// authorizeWorkspace authorizes an operation on the subtree a workspace branches
+func (h *Handler) authorizeWorkspace(c *gin.Context, id int64, perm models.Permission) bool {
+        if !h.acl.Enforced() {
+                return true
+        }
+
+        workspace, err := h.repo.GetWorkspace(c.Request.Context(), id)
+        if err != nil {
+                respondError(c, err, "Failed to check permissions")
+                return false
+        }
+        if workspace == nil {
+                problem.Respond(c, http.StatusNotFound, problem.CodeWorkspaceNotFound, "Workspace not found")
+                return false
+        }
+        return h.authorize(c, workspace.RootNodeID, perm)
+}
+
+func workspaceIDParam(c *gin.Context) (int64, bool) {
+        id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+        if err != nil {
+                problem.Respond(c, http.StatusBadRequest, problem.CodeInvalidID, "Invalid workspace ID")
+                return 0, false
+        }
+        return id, true
+}
+
+// writeWorkspaceError maps workspace repository errors to responses
+func writeWorkspaceError(c *gin.Context, err error, fallback string) {
+        var conflictErr *database.WorkspaceConflictError
+        switch {
+        case errors.As(err, &conflictErr):
+                problem.Respond(c, http.StatusConflict, problem.CodeVersionConflict, err.Error(), gin.H{"conflicts": conflictErr.Conflicts})
+        case errors.Is(err, database.ErrWorkspaceNotFound):
+                problem.Respond(c, http.StatusNotFound, problem.CodeWorkspaceNotFound, "Workspace not found")
+        case errors.Is(err, database.ErrWorkspaceNotOpen):
+                problem.Respond(c, http.StatusConflict, problem.CodeInvalidState, err.Error())
+        case errors.Is(err, database.ErrNodeNameTaken):
+                problem.Respond(c, http.StatusConflict, problem.CodeNameTaken, err.Error())
+        case errors.Is(err, database.ErrInvalidWorkspaceChange):
+                problem.Respond(c, http.StatusBadRequest, problem.CodeInvalidRequest, err.Error())
+        default:
+                respondError(c, err, fallback)
+        }
+}
@@ -0,0 +1,362 @@
+package handlers
+
+import (
+        "config-manager/internal/auth"
+        "config-manager/internal/database"
+        "config-manager/internal/models"
+        "encoding/json"
+        "errors"
+        "fmt"
+        "log"
+        "net/http"
+        "net/url"
+        "reflect"
+        "sort"
+        "strings"
+        "time"
+
+        "github.com/gin-gonic/gin"
+        "github.com/gorilla/websocket"
+)
+
+const (
+        maxWSSubscriptions = 100
+        wsWriteTimeout     = 10 * time.Second
+        wsPongTimeout      = 60 * time.Second
+        wsPingInterval     = 25 * time.Second
+)
+
+// UseAllowedOrigins accepts WebSocket connections from browsers on origins,
+// the origins CORS allows, "*" allowing any. Connections from the origin of
+// the server itself and from clients that send no Origin are always accepted.
+func (h *Handler) UseAllowedOrigins(origins []string) {
+        h.wsOrigins = origins
+}
+
+// checkWSOrigin reports whether the origin of a WebSocket handshake is allowed
+func (h *Handler) checkWSOrigin(r *http.Request) bool {
+        origin := r.Header.Get("Origin")
+        if origin == "" {
+                return true
+        }
+        for _, allowed := range h.wsOrigins {
+                if allowed == "*" || strings.EqualFold(allowed, origin) {
+                        return true
+                }
+        }
+        u, err := url.Parse(origin)
+        return err == nil && strings.EqualFold(u.Host, r.Host)
+}
+
+// wsRequest is a message from a WebSocket client
+type wsRequest struct {
+        Type        string  `json:"type"` // subscribe or unsubscribe
+        NodeIDs     []int64 `json:"node_ids"`
+        Environment string  `json:"environment"`
+}
+
+// patchOperation is an RFC 6902 JSON Patch operation
+type patchOperation struct {
+        Op    string          `json:"op"`
+        Path  string          `json:"path"`
+        Value json.RawMessage `json:"value,omitempty"` // Left out of removals only
+}
+
+// wsSubscription is the resolved configuration a connection last received for a node
+type wsSubscription struct {
+        environment string
+        resolved    *models.ResolvedConfiguration
+}
+
+// Subscribe serves WebSocket subscriptions to resolved configurations. Clients
+// send {"type": "subscribe", "node_ids": [...], "environment": "prod"} and get
+// a snapshot of each node's resolved configuration, then a JSON Patch against
+// it whenever it changes, until they unsubscribe or the node is deleted. The
+// connection is closed once its API key or session stops authenticating it,
+// or it loses read access to a node it is subscribed to.
+func (h *Handler) Subscribe(c *gin.Context) {
+        upgrader := websocket.Upgrader{CheckOrigin: h.checkWSOrigin}
+        conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+        if err != nil {
+                // The upgrader has already written an error response
+                return
+        }
+        defer conn.Close()
+
+        requests := make(chan wsRequest)
+        done := make(chan struct{})
+        go func() {
+                defer close(done)
+                conn.SetReadLimit(64 << 10)
+                conn.SetReadDeadline(time.Now().Add(wsPongTimeout))
+                conn.SetPongHandler(func(string) error {
+                        return conn.SetReadDeadline(time.Now().Add(wsPongTimeout))
+                })
+                for {
+                        var req wsRequest
+                        if err := conn.ReadJSON(&req); err != nil {
+                                return
+                        }
+                        select {
+                        case requests <- req:
+                        case <-c.Request.Context().Done():
+                                return
+                        }
+                }
+        }()
+
+        var notify <-chan struct{}
+        if h.changes != nil {
+                var unsubscribe func()
+                notify, unsubscribe = h.changes.Subscribe()
+                defer unsubscribe()
+        }
+        poll := time.NewTicker(watchPollInterval)
+        defer poll.Stop()
+        ping := time.NewTicker(wsPingInterval)
+        defer ping.Stop()
+
+        send := func(msg interface{}) bool {
+                conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+                return conn.WriteJSON(msg) == nil
+        }
+        deny := func(reason string) {
+                message := websocket.FormatCloseMessage(websocket.ClosePolicyViolation, reason)
+                conn.WriteControl(websocket.CloseMessage, message, time.Now().Add(wsWriteTimeout))
+        }
+
+        subscriptions := make(map[int64]*wsSubscription)
+        for {
+                select {
+                case <-done:
+                        return
+
+                case req := <-requests:
+                        if !h.handleWSRequest(c, req, subscriptions, send, deny) {
+                                return
+                        }
+
+                case <-ping.C:
+                        conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+                        if err := conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+                                return
+                        }
+
+                case <-notify:
+                        if !h.refreshWSSubscriptions(c, subscriptions, send, deny) {
+                                return
+                        }
+                case <-poll.C:
+                        if !h.refreshWSSubscriptions(c, subscriptions, send, deny) {
+                                return
+                        }
+                }
+        }
+}
+
+// handleWSRequest applies a subscribe or unsubscribe request, and returns
+// false once the connection is broken or denied
+func (h *Handler) handleWSRequest(c *gin.Context, req wsRequest, subscriptions map[int64]*wsSubscription, send func(interface{}) bool, deny func(string)) bool {
+        switch req.Type {
+        case "subscribe":
+                if req.Environment != "" && !environmentPattern.MatchString(req.Environment) {
+                        return send(gin.H{"type": "error", "error": "Invalid environment"})
+                }
+                if reason := h.wsCredentialsError(c); reason != "" {
+                        deny(reason)
+                        return false
+                }
+                for _, nodeID := range req.NodeIDs {
+                        if _, ok := subscriptions[nodeID]; !ok && len(subscriptions) >= maxWSSubscriptions {
+                                return send(gin.H{"type": "error", "error": fmt.Sprintf("At most %d nodes can be subscribed", maxWSSubscriptions)})
+                        }
+
+                        allowed := true
+                        if h.acl.Enforced() {
+                                var err error
+                                if allowed, err = h.acl.Allowed(c, nodeID, models.PermissionRead); err != nil {
+                                        return send(gin.H{"type": "error", "node_id": nodeID, "error": "Failed to check permissions"})
+                                }
+                        }
+                        if !allowed {
+                                if !send(gin.H{"type": "error", "node_id": nodeID, "error": "Permission denied"}) {
+                                        return false
+                                }
+                                continue
+                        }
+
+                        resolved, err := h.repo.ResolveConfiguration(c.Request.Context(), nodeID, models.ResolveOptions{Environment: req.Environment})
+                        if err != nil {
+                                if !send(wsResolveError(nodeID, err)) {
+                                        return false
+                                }
+                                continue
+                        }
+                        h.recordAccess(c.Request.Context(), nodeID)
+                        subscriptions[nodeID] = &wsSubscription{environment: req.Environment, resolved: resolved}
+                        if !send(gin.H{"type": "snapshot", "node_id": nodeID, "config": resolved}) {
+                                return false
+                        }
+                }
+                return true
+
+        case "unsubscribe":
+                for _, nodeID := range req.NodeIDs {
+                        delete(subscriptions, nodeID)
+                }
+                return true
+
+        default:
+                return send(gin.H{"type": "error", "error": "type must be 'subscribe' or 'unsubscribe'"})
+        }
+}
+
+// refreshWSSubscriptions re-resolves every subscribed node and sends a patch
+// for each one that changed, checking first that the connection may still
+// read it. It returns false once the connection is broken or denied.
+func (h *Handler) refreshWSSubscriptions(c *gin.Context, subscriptions map[int64]*wsSubscription, send func(interface{}) bool, deny func(string)) bool {
+        credentialsChecked := false
+        for nodeID, sub := range subscriptions {
+                resolved, err := h.repo.ResolveConfiguration(c.Request.Context(), nodeID, models.ResolveOptions{Environment: sub.environment})
+                if errors.Is(err, database.ErrNodeNotFound) {
+                        delete(subscriptions, nodeID)
+                        if !send(gin.H{"type": "removed", "node_id": nodeID}) {
+                                return false
+                        }
+                        continue
+                }
+                if err != nil {
+                        // Keep the last configuration and try again on the next change
+                        log.Printf("Failed to refresh subscription to node %d: %v", nodeID, err)
+                        continue
+                }
+
+                patch := configPatch(sub.resolved, resolved)
+                if len(patch) == 0 {
+                        continue
+                }
+
+                // Credentials are checked once per refresh, permissions per node
+                if !credentialsChecked {
+                        if reason := h.wsCredentialsError(c); reason != "" {
+                                deny(reason)
+                                return false
+                        }
+                        credentialsChecked = true
+                }
+                if reason := h.wsPermissionError(c, nodeID); reason != "" {
+                        deny(reason)
+                        return false
+                }
+
+                sub.resolved = resolved
+                if !send(gin.H{"type": "patch", "node_id": nodeID, "patch": patch}) {
+                        return false
+                }
+        }
+        return true
+}
+
+// wsCredentialsError returns why the API key or session that opened a
+// WebSocket connection no longer authenticates it, e.g. the key was revoked
+// or has expired, or "" if it still does
+func (h *Handler) wsCredentialsError(c *gin.Context) string {
+        if session := auth.SessionFromContext(c); session != nil && time.Now().Unix() >= session.ExpiresAt {
+                return "Session expired"
+        }
+
+        if auth.APIKeyFromContext(c) == nil {
+                return ""
+        }
+        key, err := h.repo.AuthenticateAPIKey(c.Request.Context(), c.GetHeader(auth.APIKeyHeader))
+        if err != nil {
+                log.Printf("Failed to authenticate the API key of a subscription: %v", err)
+                return "Failed to authenticate API key"
+        }
+        if key == nil {
+                return "API key is no longer valid"
+        }
+        required := auth.RequiredScope(c.Request.Method, c.FullPath())
+        for _, scope := range key.Scopes {
+                if scope.Allows(required) {
+                        return ""
+                }
+        }
+        return "API key scope does not allow this route"
+}
+
+// wsPermissionError returns why the connection may no longer read nodeID, or
+// "" if it may
+func (h *Handler) wsPermissionError(c *gin.Context, nodeID int64) string {
+        if !h.acl.Enforced() {
+                return ""
+        }
+        allowed, err := h.acl.Allowed(c, nodeID, models.PermissionRead)
+        if err != nil {
+                log.Printf("Failed to check the permissions of a subscription to node %d: %v", nodeID, err)
+                return "Failed to check permissions"
+        }
+        if !allowed {
+                return fmt.Sprintf("Permission to read node %d was revoked", nodeID)
+        }
+        return ""
+}
+
+func wsResolveError(nodeID int64, err error) gin.H {
+        switch {
+        case errors.Is(err, database.ErrNodeNotFound):
+                return gin.H{"type": "error", "node_id": nodeID, "error": "Node not found"}
+        default:
+                return gin.H{"type": "error", "node_id": nodeID, "error": "Failed to resolve configuration"}
+        }
+}
+
+// configPatch returns the JSON Patch turning one resolved configuration into
+// another. Changed property values are replaced as a whole.
+func configPatch(from, to *models.ResolvedConfiguration) []patchOperation {
+        var patch []patchOperation
+        if from.NodeName != to.NodeName {
+                patch = append(patch, patchOperation{Op: "replace", Path: "/node_name", Value: patchValue(to.NodeName)})
+        }
+        fromPath, _ := json.Marshal(from.Path)
+        toPath, _ := json.Marshal(to.Path)
+        if string(fromPath) != string(toPath) {
+                patch = append(patch, patchOperation{Op: "replace", Path: "/path", Value: toPath})
+        }
+
+        keys := make([]string, 0, len(from.Properties)+len(to.Properties))
+        for key := range from.Properties {
+                keys = append(keys, key)
+        }
+        for key := range to.Properties {
+                if _, ok := from.Properties[key]; !ok {
+                        keys = append(keys, key)
+                }
+        }
+        sort.Strings(keys)
+
+        for _, key := range keys {
+                path := "/properties/" + strings.NewReplacer("~", "~0", "/", "~1").Replace(key)
+                old, hadKey := from.Properties[key]
+                value, hasKey := to.Properties[key]
+                switch {
+                case !hasKey:
+                        patch = append(patch, patchOperation{Op: "remove", Path: path})
+                case !hadKey:
+                        patch = append(patch, patchOperation{Op: "add", Path: path, Value: patchValue(value)})
+                case !reflect.DeepEqual(old, value):
+                        patch = append(patch, patchOperation{Op: "replace", Path: path, Value: patchValue(value)})
+                }
+        }
+        return patch
+}
+
+// patchValue encodes the value of a patch operation; null is kept, unlike an
+// empty value
+func patchValue(value interface{}) json.RawMessage {
+        payload, err := json.Marshal(value)
+        if err != nil {
+                return json.RawMessage("null")
+        }
+        return payload
+}
@@ -0,0 +1,39 @@
+package handlers
+
+import (
+        "net/http/httptest"
+        "testing"
+)
+
+func TestCheckWSOrigin(t *testing.T) {
+        tests := []struct {
+                name    string
+                allowed []string
+                origin  string
+                want    bool
+        }{
+                {"no origin", nil, "", true},
+                {"same origin", nil, "http://config.example.com", true},
+                {"foreign origin", nil, "http://evil.example", false},
+                {"allowed origin", []string{"http://localhost:3000"}, "http://localhost:3000", true},
+                {"allowed origin in another case", []string{"http://localhost:3000"}, "http://LOCALHOST:3000", true},
+                {"other port", []string{"http://localhost:3000"}, "http://localhost:3001", false},
+                {"any origin", []string{"*"}, "http://evil.example", true},
+                {"malformed origin", nil, "://", false},
+        }
+
+        for _, tt := range tests {
+                t.Run(tt.name, func(t *testing.T) {
+                        h := &Handler{}
+                        h.UseAllowedOrigins(tt.allowed)
+
+                        req := httptest.NewRequest("GET", "http://config.example.com/api/ws", nil)
+                        if tt.origin != "" {
+                                req.Header.Set("Origin", tt.origin)
+                        }
+                        if got := h.checkWSOrigin(req); got != tt.want {
+                                t.Errorf("got %v, want %v", got, tt.want)
+                        }
+                })
+        }
+}
@@ -0,0 +1,90 @@
+package kafka
+
+import (
+	"config-manager/internal/events"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"time"
+
+	kafkago "github.com/segmentio/kafka-go"
+	"github.com/segmentio/kafka-go/sasl/plain"
+)
+
+// Config describes the Kafka cluster and topic events are published to
+type Config struct {
+	Brokers      []string
+	Topic        string
+	TLS          bool
+	SASLUsername string // SASL/PLAIN is used when set
+	SASLPassword string
+}
+
+// Publisher writes change events to a Kafka topic. Messages are keyed by node
+// ID, so the events of a node stay in order within their partition, and the
+// value is the JSON encoded events.Event. The type and schema version are also
+// sent as headers so consumers can filter without decoding the value.
+//
+// Writes are synchronous and acknowledged by all in-sync replicas.
+type Publisher struct {
+	writer *kafkago.Writer
+}
+
+// NewPublisher creates a publisher for cfg.Topic, which must already exist
+func NewPublisher(cfg Config) (*Publisher, error) {
+	if len(cfg.Brokers) == 0 {
+		return nil, errors.New("no Kafka brokers configured")
+	}
+	if cfg.Topic == "" {
+		return nil, errors.New("no Kafka topic configured")
+	}
+
+	transport := &kafkago.Transport{}
+	if cfg.TLS {
+		transport.TLS = &tls.Config{MinVersion: tls.VersionTLS12}
+	}
+	if cfg.SASLUsername != "" {
+		transport.SASL = plain.Mechanism{Username: cfg.SASLUsername, Password: cfg.SASLPassword}
+	}
+
+	writer := &kafkago.Writer{
+		Addr:         kafkago.TCP(cfg.Brokers...),
+		Topic:        cfg.Topic,
+		Balancer:     &kafkago.Hash{},
+		RequiredAcks: kafkago.RequireAll,
+		BatchTimeout: 10 * time.Millisecond,
+		Transport:    transport,
+	}
+	return &Publisher{writer: writer}, nil
+}
+
+func (p *Publisher) Publish(ctx context.Context, event events.Event) error {
+	return p.PublishBatch(ctx, []events.Event{event})
+}
+
+func (p *Publisher) PublishBatch(ctx context.Context, batch []events.Event) error {
+	messages := make([]kafkago.Message, 0, len(batch))
+	for _, event := range batch {
+		value, err := json.Marshal(event)
+		if err != nil {
+			return err
+		}
+		messages = append(messages, kafkago.Message{
+			Key:   []byte(strconv.FormatInt(event.NodeID, 10)),
+			Value: value,
+			Headers: []kafkago.Header{
+				{Key: "type", Value: []byte(event.Type)},
+				{Key: "schema_version", Value: []byte(strconv.Itoa(event.SchemaVersion))},
+			},
+			Time: event.OccurredAt,
+		})
+	}
+	return p.writer.WriteMessages(ctx, messages...)
+}
+
+// Close closes the connections to the brokers
+func (p *Publisher) Close() error {
+	return p.writer.Close()
+}
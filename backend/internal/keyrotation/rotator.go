@@ -0,0 +1,68 @@
+package keyrotation
+
+import (
+	"config-manager/internal/database"
+	"config-manager/internal/models"
+	"context"
+	"log"
+	"time"
+)
+
+// Rotator runs key rotations once they are due. Each batch of re-encrypted
+// properties is committed with the progress of its rotation, so a rotation
+// interrupted by a restart carries on from the properties still left.
+type Rotator struct {
+	repo      *database.Repository
+	interval  time.Duration
+	batchSize int
+}
+
+// New creates a rotator that checks for due rotations every interval and
+// re-encrypts batchSize properties per transaction
+func New(repo *database.Repository, interval time.Duration, batchSize int) *Rotator {
+	return &Rotator{repo: repo, interval: interval, batchSize: batchSize}
+}
+
+// Run rotates keys until ctx is cancelled
+func (r *Rotator) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		r.runDue(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// runDue advances due rotations batch by batch until none is left
+func (r *Rotator) runDue(ctx context.Context) {
+	for ctx.Err() == nil {
+		rotation, err := r.repo.RunKeyRotationBatch(ctx, time.Now(), r.batchSize)
+		if rotation == nil {
+			if err != nil {
+				log.Printf("Failed to rotate encryption keys: %v", err)
+			}
+			return
+		}
+		if err != nil {
+			log.Printf("Rotation %d of encryption key %d failed: %v", rotation.ID, rotation.OldKeyID, err)
+			continue
+		}
+
+		switch rotation.Status {
+		case models.KeyRotationStatusRunning:
+			if rotation.RotatedProperties == 0 {
+				log.Printf("Rotation %d replaced encryption key %d with key %d, re-encrypting %d properties",
+					rotation.ID, rotation.OldKeyID, *rotation.NewKeyID, rotation.TotalProperties)
+			}
+		case models.KeyRotationStatusCompleted:
+			log.Printf("Rotation %d of encryption key %d completed, %d properties re-encrypted",
+				rotation.ID, rotation.OldKeyID, rotation.RotatedProperties)
+		}
+	}
+}
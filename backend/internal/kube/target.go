@@ -0,0 +1,112 @@
+package kube
+
+import (
+	"config-manager/internal/syncer"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// fieldManager identifies the server in the managed fields of applied objects
+const fieldManager = "config-manager"
+
+// configMapKeyPattern matches the keys Kubernetes accepts in ConfigMaps and Secrets
+var configMapKeyPattern = regexp.MustCompile(`^[-._a-zA-Z0-9]+$`)
+
+// Target writes the configuration of each bound node into a ConfigMap, and the
+// values of its encrypted properties into a Secret of the same name. Binding
+// destinations are "name" or "namespace/name".
+type Target struct {
+	client *Client
+}
+
+func NewTarget(client *Client) *Target {
+	return &Target{client: client}
+}
+
+func (t *Target) Name() string {
+	return "Kubernetes"
+}
+
+func (t *Target) Sync(ctx context.Context, config syncer.NodeConfig) error {
+	namespace, name := t.client.Namespace(), config.Destination
+	if ns, n, ok := strings.Cut(config.Destination, "/"); ok {
+		namespace, name = ns, n
+	}
+
+	metadata := map[string]interface{}{
+		"name":      name,
+		"namespace": namespace,
+		"labels": map[string]string{
+			"app.kubernetes.io/managed-by": fieldManager,
+		},
+		"annotations": map[string]string{
+			"config-manager/node-id":     strconv.FormatInt(config.NodeID, 10),
+			"config-manager/node-name":   config.NodeName,
+			"config-manager/environment": config.Environment,
+			"config-manager/checksum":    config.Checksum,
+		},
+	}
+
+	data, err := objectData(config.Properties, config.NodeID, false)
+	if err != nil {
+		return err
+	}
+	configMap, err := json.Marshal(map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "ConfigMap",
+		"metadata":   metadata,
+		"data":       data,
+	})
+	if err != nil {
+		return err
+	}
+	if err := t.client.apply(ctx, fmt.Sprintf("/api/v1/namespaces/%s/configmaps/%s", namespace, name), configMap); err != nil {
+		return fmt.Errorf("failed to apply ConfigMap %s/%s: %w", namespace, name, err)
+	}
+
+	secretData, err := objectData(config.Secrets, config.NodeID, true)
+	if err != nil {
+		return err
+	}
+	secret, err := json.Marshal(map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "Secret",
+		"type":       "Opaque",
+		"metadata":   metadata,
+		"data":       secretData,
+	})
+	if err != nil {
+		return err
+	}
+	if err := t.client.apply(ctx, fmt.Sprintf("/api/v1/namespaces/%s/secrets/%s", namespace, name), secret); err != nil {
+		return fmt.Errorf("failed to apply Secret %s/%s: %w", namespace, name, err)
+	}
+	return nil
+}
+
+// objectData renders resolved values as ConfigMap or, base64 encoded, Secret
+// data. Keys Kubernetes does not accept are skipped.
+func objectData(values map[string]interface{}, nodeID int64, encode bool) (map[string]string, error) {
+	data := make(map[string]string, len(values))
+	for key, value := range values {
+		if !configMapKeyPattern.MatchString(key) {
+			log.Printf("Skipping property %q of node %d: not a valid Kubernetes data key", key, nodeID)
+			continue
+		}
+		s, err := syncer.StringValue(value)
+		if err != nil {
+			return nil, err
+		}
+		if encode {
+			s = base64.StdEncoding.EncodeToString([]byte(s))
+		}
+		data[key] = s
+	}
+	return data, nil
+}
@@ -0,0 +1,62 @@
+package materialize
+
+import (
+	"config-manager/internal/database"
+	"config-manager/internal/events"
+	"context"
+	"log"
+	"time"
+)
+
+// Refresher keeps the materialized resolutions up to date. It refreshes the
+// subtree of each config.invalidated event as the outbox delivers it, so that
+// the resolutions a change marked stale are read from the table again.
+type Refresher struct {
+	repo *database.Repository
+}
+
+func New(repo *database.Repository) *Refresher {
+	return &Refresher{repo: repo}
+}
+
+func (r *Refresher) Publish(ctx context.Context, event events.Event) error {
+	return r.PublishBatch(ctx, []events.Event{event})
+}
+
+// PublishBatch refreshes each subtree invalidated in the batch once
+func (r *Refresher) PublishBatch(ctx context.Context, batch []events.Event) error {
+	refreshed := make(map[int64]bool)
+	for _, event := range batch {
+		if event.Type != events.TypeConfigInvalidated || refreshed[event.NodeID] {
+			continue
+		}
+		refreshed[event.NodeID] = true
+
+		nodeID := event.NodeID
+		if _, err := r.repo.RefreshResolutions(ctx, &nodeID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Fill materializes the resolutions of the whole tree if none are yet, e.g.
+// when materialization is first turned on
+func (r *Refresher) Fill(ctx context.Context) {
+	count, err := r.repo.CountMaterializedResolutions(ctx)
+	if err != nil {
+		log.Printf("Failed to count materialized resolutions: %v", err)
+		return
+	}
+	if count > 0 {
+		return
+	}
+
+	start := time.Now()
+	written, err := r.repo.RefreshResolutions(ctx, nil)
+	if err != nil {
+		log.Printf("Failed to materialize resolutions: %v", err)
+		return
+	}
+	log.Printf("Materialized %d resolutions in %s", written, time.Since(start).Round(time.Millisecond))
+}
@@ -0,0 +1,162 @@
+package metrics
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Registry collects HTTP request metrics and exposes them in the Prometheus
+// text exposition format
+type Registry struct {
+	mu       sync.Mutex
+	requests map[requestKey]uint64
+	duration map[routeKey]*summary
+	gauges   map[string]gauge
+}
+
+type requestKey struct {
+	method, route, status string
+}
+
+type routeKey struct {
+	method, route string
+}
+
+type summary struct {
+	sum   float64
+	count uint64
+}
+
+// gauge is a metric whose value is read at scrape time, a gauge or a counter
+// kept by its owner
+type gauge struct {
+	kind  string
+	help  string
+	value func() float64
+}
+
+func NewRegistry() *Registry {
+	return &Registry{
+		requests: make(map[requestKey]uint64),
+		duration: make(map[routeKey]*summary),
+		gauges:   make(map[string]gauge),
+	}
+}
+
+// RegisterGauge adds a gauge whose value is computed at scrape time
+func (reg *Registry) RegisterGauge(name, help string, value func() float64) {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	reg.gauges[name] = gauge{kind: "gauge", help: help, value: value}
+}
+
+// RegisterCounter adds a counter kept by its owner, read at scrape time
+func (reg *Registry) RegisterCounter(name, help string, value func() float64) {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	reg.gauges[name] = gauge{kind: "counter", help: help, value: value}
+}
+
+// Middleware records the count and latency of every request by route template
+func (reg *Registry) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		method := c.Request.Method
+		elapsed := time.Since(start).Seconds()
+
+		reg.mu.Lock()
+		defer reg.mu.Unlock()
+
+		reg.requests[requestKey{method, route, strconv.Itoa(c.Writer.Status())}]++
+		s, ok := reg.duration[routeKey{method, route}]
+		if !ok {
+			s = &summary{}
+			reg.duration[routeKey{method, route}] = s
+		}
+		s.sum += elapsed
+		s.count++
+	}
+}
+
+// Handler serves the collected metrics
+func (reg *Registry) Handler(c *gin.Context) {
+	var b strings.Builder
+	gauges := reg.writeRequests(&b)
+
+	// Gauges may be slow (e.g. ping a database), so they are evaluated
+	// without holding the lock that request recording needs
+	names := make([]string, 0, len(gauges))
+	for name := range gauges {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		g := gauges[name]
+		fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s %s\n%s %g\n", name, g.help, name, g.kind, name, g.value())
+	}
+
+	c.Data(http.StatusOK, "text/plain; version=0.0.4; charset=utf-8", []byte(b.String()))
+}
+
+// writeRequests renders the request metrics and returns a copy of the registered gauges
+func (reg *Registry) writeRequests(b *strings.Builder) map[string]gauge {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+
+	b.WriteString("# HELP config_manager_http_requests_total Total HTTP requests by method, route and status.\n")
+	b.WriteString("# TYPE config_manager_http_requests_total counter\n")
+	requestKeys := make([]requestKey, 0, len(reg.requests))
+	for k := range reg.requests {
+		requestKeys = append(requestKeys, k)
+	}
+	sort.Slice(requestKeys, func(i, j int) bool {
+		a, b := requestKeys[i], requestKeys[j]
+		if a.route != b.route {
+			return a.route < b.route
+		}
+		if a.method != b.method {
+			return a.method < b.method
+		}
+		return a.status < b.status
+	})
+	for _, k := range requestKeys {
+		fmt.Fprintf(b, "config_manager_http_requests_total{method=%q,route=%q,status=%q} %d\n", k.method, k.route, k.status, reg.requests[k])
+	}
+
+	b.WriteString("# HELP config_manager_http_request_duration_seconds HTTP request latency by method and route.\n")
+	b.WriteString("# TYPE config_manager_http_request_duration_seconds summary\n")
+	routeKeys := make([]routeKey, 0, len(reg.duration))
+	for k := range reg.duration {
+		routeKeys = append(routeKeys, k)
+	}
+	sort.Slice(routeKeys, func(i, j int) bool {
+		if routeKeys[i].route != routeKeys[j].route {
+			return routeKeys[i].route < routeKeys[j].route
+		}
+		return routeKeys[i].method < routeKeys[j].method
+	})
+	for _, k := range routeKeys {
+		s := reg.duration[k]
+		fmt.Fprintf(b, "config_manager_http_request_duration_seconds_sum{method=%q,route=%q} %g\n", k.method, k.route, s.sum)
+		fmt.Fprintf(b, "config_manager_http_request_duration_seconds_count{method=%q,route=%q} %d\n", k.method, k.route, s.count)
+	}
+
+	gauges := make(map[string]gauge, len(reg.gauges))
+	for name, g := range reg.gauges {
+		gauges[name] = g
+	}
+	return gauges
+}
@@ -1,6 +1,7 @@
 package models
 
 import (
+        "config-manager/internal/events"
         "time"
 )
 
@@ -22,17 +23,30 @@ const (
         DataTypeObject  DataType = "object"
         DataTypeArray   DataType = "array"
         DataTypeNull    DataType = "null"
+        DataTypeFlag    DataType = "flag" // Feature flag definition with targeting rules, see package flags
 )
 
 // ConfigNode represents a hierarchical configuration node
 type ConfigNode struct {
-        ID          int64     `json:"id" db:"id"`
-        Name        string    `json:"name" db:"name"`
-        NodeType    NodeType  `json:"node_type" db:"node_type"`
-        ParentID    *int64    `json:"parent_id" db:"parent_id"`
-        Description string    `json:"description" db:"description"`
-        CreatedAt   time.Time `json:"created_at" db:"created_at"`
-        UpdatedAt   time.Time `json:"updated_at" db:"updated_at"`
+        ID          int64                  `json:"id" db:"id"`
+        Name        string                 `json:"name" db:"name"`
+        NodeType    NodeType               `json:"node_type" db:"node_type"`
+        ParentID    *int64                 `json:"parent_id" db:"parent_id"`
+        Description string                 `json:"description" db:"description"`
+        Labels      map[string]string      `json:"labels" db:"labels"`         // Groupings across the hierarchy, e.g. tier=gold
+        Metadata    map[string]interface{} `json:"metadata" db:"metadata"`     // Free-form details such as the owner; never resolved
+        SortIndex   int                    `json:"sort_index" db:"sort_index"` // Position among its siblings
+        CreatedAt   time.Time              `json:"created_at" db:"created_at"`
+        UpdatedAt   time.Time              `json:"updated_at" db:"updated_at"`
+        *NodeCounts                        // Only set in node lists
+}
+
+// NodeCounts tells a node list whether its nodes can be expanded, without a
+// request per node
+type NodeCounts struct {
+        HasChildren   bool `json:"has_children"`
+        ChildCount    int  `json:"child_count"`
+        PropertyCount int  `json:"property_count"`
 }
 
 // ConfigProperty represents a configuration property with metadata
@@ -40,10 +54,19 @@ type ConfigProperty struct {
         ID           int64    `json:"id" db:"id"`
         NodeID       int64    `json:"node_id" db:"node_id"`
         Key          string   `json:"key" db:"key"`
+        Environment  *string  `json:"environment,omitempty" db:"environment"` // Nil applies to every environment
         Value        string   `json:"value" db:"value"` // Serialized JSON string
         DataType     DataType `json:"data_type" db:"data_type"`
         DefaultValue *string  `json:"default_value" db:"default_value"` // Optional default value
         Description  string   `json:"description" db:"description"`
+        Encrypted    bool     `json:"encrypted" db:"encrypted"`
+        EncryptionKeyID *int64 `json:"encryption_key_id,omitempty" db:"encryption_key_id"` // Subtree key the value is encrypted with
+        RolloutPercentage *float64 `json:"rollout_percentage,omitempty" db:"rollout_percentage"` // Share of contexts served the value, boolean properties only
+        RolloutKey   *string  `json:"rollout_key,omitempty" db:"rollout_key"` // Context attribute hashed to bucket contexts
+        IsFinal      bool     `json:"is_final" db:"is_final"` // Descendants cannot override the key
+        Namespace    *string  `json:"namespace,omitempty" db:"namespace"` // Optional group of the key, e.g. payments
+        Tags         []string `json:"tags" db:"tags"` // Markers such as restart-required, sorted
+        ExpiresAt    *time.Time `json:"expires_at,omitempty" db:"expires_at"` // Resolution ignores the property from then on
         CreatedAt    time.Time `json:"created_at" db:"created_at"`
         UpdatedAt    time.Time `json:"updated_at" db:"updated_at"`
 }
@@ -54,32 +77,90 @@ type ConfigNodeWithChildren struct {
         Children []ConfigNode `json:"children"`
 }
 
+// ConfigTreeNode represents a node with its descendants nested below it.
+// Children is null when the descendants were not loaded, below a depth limit.
+type ConfigTreeNode struct {
+        ConfigNode
+        Children []ConfigTreeNode `json:"children"`
+}
+
+// ExpandedNode represents a node with the related data requested by ?expand=.
+// Parts that were not requested are left out, rather than empty.
+type ExpandedNode struct {
+        ConfigNode
+        Children   *[]ConfigNode     `json:"children,omitempty"`
+        Properties *[]ConfigProperty `json:"properties,omitempty"`
+        Path       *[]ConfigNode     `json:"path,omitempty"` // From the root to the node
+        ChildrenPage *ChildrenPage   `json:"children_page,omitempty"` // Only set when the children are paged
+}
+
+// ChildrenPage tells where the page of children after those listed starts
+type ChildrenPage struct {
+        NextCursor *int64 `json:"next_cursor"` // ID of the last child listed, to pass as ?after=, null when none were
+        HasMore    bool   `json:"has_more"`
+}
+
 // ConfigNodeWithProperties represents a node with its properties
 type ConfigNodeWithProperties struct {
         ConfigNode
         Properties []ConfigProperty `json:"properties"`
 }
 
+// ResolveOptions selects the environment and the context attributes used to
+// resolve a configuration
+type ResolveOptions struct {
+        Environment   string
+        Context       map[string]interface{}
+        IncludeDrafts bool            // Preview staged drafts as if they were published
+        Preview       []PropertyDraft // Hypothetical edits to resolve as if they were published, applied after drafts
+        AsOf          *time.Time      // Reconstruct the configuration as it was at this time
+        Namespace     string          // Only return the keys whose effective property is in this namespace
+}
+
 // ResolvedConfiguration represents the effective configuration after inheritance
 type ResolvedConfiguration struct {
         NodeID     int64                  `json:"node_id"`
         NodeName   string                 `json:"node_name"`
+        Environment string                `json:"environment,omitempty"`
+        Namespace  string                 `json:"namespace,omitempty"`
         Properties map[string]interface{} `json:"properties"`
         Path       []ConfigNode           `json:"path"`
+        AsOf       *time.Time             `json:"as_of,omitempty"`
+        Checksum   string                 `json:"checksum"`          // SHA-256 of the environment and properties, equal for equal configurations
+        Version    int64                  `json:"version,omitempty"` // Grows with every change to the configuration; PostgreSQL only
+        Warnings   []ResolveWarning       `json:"warnings,omitempty"` // E.g. resolved keys that are deprecated
+}
+
+// ResolveWarning is non-fatal advice about a resolved key
+type ResolveWarning struct {
+        Code           string     `json:"code"`
+        Key            string     `json:"key"`
+        Message        string     `json:"message"`
+        ReplacementKey *string    `json:"replacement_key,omitempty"`
+        SunsetAt       *time.Time `json:"sunset_at,omitempty"`
 }
 
 // CreateNodeRequest represents the request to create a new node
 type CreateNodeRequest struct {
-        Name        string   `json:"name" binding:"required"`
-        NodeType    NodeType `json:"nodeType" binding:"required"`
-        ParentID    *int64   `json:"parentId"`
-        Description string   `json:"description"`
+        Name        string                 `json:"name" binding:"required"`
+        NodeType    NodeType               `json:"nodeType" binding:"required"`
+        ParentID    *int64                 `json:"parentId"`
+        Description string                 `json:"description"`
+        Labels      map[string]string      `json:"labels"`
+        Metadata    map[string]interface{} `json:"metadata"`
+}
+
+// ReorderNodesRequest represents the request to order the children of a node
+type ReorderNodesRequest struct {
+        ChildIDs []int64 `json:"child_ids" binding:"required"` // Every child, in their new order
 }
 
 // UpdateNodeRequest represents the request to update a node
 type UpdateNodeRequest struct {
-        Name        *string `json:"name"`
-        Description *string `json:"description"`
+        Name        *string                `json:"name"`
+        Description *string                `json:"description"`
+        Labels      map[string]string      `json:"labels"`   // Replaces all labels when set
+        Metadata    map[string]interface{} `json:"metadata"` // Replaces all metadata when set
 }
 
 // CreatePropertyRequest represents the request to create/update a property
@@ -89,6 +170,14 @@ type CreatePropertyRequest struct {
         DataType     DataType `json:"data_type" binding:"required"`
         DefaultValue *string  `json:"default_value"`
         Description  string   `json:"description"`
+        Encrypted    bool     `json:"encrypted"` // Encrypt with the nearest subtree key
+        Environment  *string  `json:"environment"` // Override for one environment, nil for all
+        RolloutPercentage *float64 `json:"rollout_percentage"`
+        RolloutKey   *string  `json:"rollout_key"`
+        IsFinal      bool     `json:"is_final"` // Lock the key for descendants
+        Namespace    *string  `json:"namespace"`
+        Tags         []string `json:"tags"`
+        ExpiresAt    *time.Time `json:"expires_at"` // For temporary overrides
 }
 
 // UpdatePropertyRequest represents the request to update a property
@@ -97,4 +186,1115 @@ type UpdatePropertyRequest struct {
         DataType     *DataType `json:"data_type"`
         DefaultValue *string  `json:"default_value"`
         Description  *string  `json:"description"`
-}
\ No newline at end of file
+        RolloutPercentage *float64 `json:"rollout_percentage"`
+        RolloutKey   *string  `json:"rollout_key"`
+        IsFinal      *bool    `json:"is_final"`
+        Namespace    *string  `json:"namespace"` // An empty namespace removes the key from its namespace
+        Tags         []string `json:"tags"` // Replaces all tags when set
+        ExpiresAt    *time.Time `json:"expires_at"`
+        ClearExpiry  bool     `json:"clear_expiry"` // Keep the property indefinitely
+}
+
+// RebuildStepResult represents the outcome of a single derived-data rebuild step
+type RebuildStepResult struct {
+        Name       string `json:"name"`
+        Status     string `json:"status"` // completed, failed or skipped
+        DurationMs int64  `json:"duration_ms"`
+        Error      string `json:"error,omitempty"`
+}
+
+// RebuildReport represents the progress report of a derived-data rebuild
+type RebuildReport struct {
+        StartedAt   time.Time           `json:"started_at"`
+        CompletedAt time.Time           `json:"completed_at"`
+        Success     bool                `json:"success"`
+        Steps       []RebuildStepResult `json:"steps"`
+}
+
+// EncryptionKey represents a data key assigned to a subtree. Key material is never exposed.
+type EncryptionKey struct {
+        ID        int64      `json:"id" db:"id"`
+        NodeID    int64      `json:"node_id" db:"node_id"`
+        CreatedAt time.Time  `json:"created_at" db:"created_at"`
+        RetiredAt *time.Time `json:"retired_at,omitempty" db:"retired_at"` // Set once a rotation replaced the key
+}
+
+// CreateEncryptionKeyRequest represents the request to assign a new key to a subtree
+type CreateEncryptionKeyRequest struct {
+        NodeID int64 `json:"node_id" binding:"required"`
+}
+
+// KeyRotationStatus represents the lifecycle state of a key rotation
+type KeyRotationStatus string
+
+const (
+        KeyRotationStatusPending   KeyRotationStatus = "pending"
+        KeyRotationStatusRunning   KeyRotationStatus = "running"
+        KeyRotationStatusCompleted KeyRotationStatus = "completed"
+        KeyRotationStatusFailed    KeyRotationStatus = "failed"
+        KeyRotationStatusCancelled KeyRotationStatus = "cancelled"
+)
+
+// KeyRotation represents a job replacing a subtree key and re-encrypting the
+// properties encrypted with it
+type KeyRotation struct {
+        ID                int64             `json:"id" db:"id"`
+        NodeID            int64             `json:"node_id" db:"node_id"`
+        OldKeyID          int64             `json:"old_key_id" db:"old_key_id"`
+        NewKeyID          *int64            `json:"new_key_id,omitempty" db:"new_key_id"` // Set when the job starts
+        Status            KeyRotationStatus `json:"status" db:"status"`
+        TotalProperties   int               `json:"total_properties" db:"total_properties"` // Encrypted with the old key when the job started
+        RotatedProperties int               `json:"rotated_properties" db:"rotated_properties"`
+        PercentComplete   float64           `json:"percent_complete"`
+        Error             *string           `json:"error,omitempty" db:"error"`
+        RequestedBy       string            `json:"requested_by" db:"requested_by"`
+        ScheduledAt       time.Time         `json:"scheduled_at" db:"scheduled_at"`
+        CreatedAt         time.Time         `json:"created_at" db:"created_at"`
+        StartedAt         *time.Time        `json:"started_at,omitempty" db:"started_at"`
+        CompletedAt       *time.Time        `json:"completed_at,omitempty" db:"completed_at"`
+}
+
+// CreateKeyRotationRequest represents the request to rotate a subtree key,
+// now or at ScheduledAt
+type CreateKeyRotationRequest struct {
+        ScheduledAt *time.Time `json:"scheduled_at"`
+}
+
+// ComponentStatus represents the health of a single backing component
+type ComponentStatus struct {
+        Name      string  `json:"name"`
+        Status    string  `json:"status"` // up or down
+        LatencyMs float64 `json:"latency_ms"`
+        Error     string  `json:"error,omitempty"`
+}
+
+// HealthStatus represents the response of the liveness and readiness probes
+type HealthStatus struct {
+        Status     string            `json:"status"`
+        Timestamp  time.Time         `json:"timestamp"`
+        Components []ComponentStatus `json:"components"`
+}
+
+// APIKeyScope represents the access granted to an API key
+type APIKeyScope string
+
+const (
+        APIKeyScopeResolve APIKeyScope = "resolve" // resolve and path lookups only
+        APIKeyScopeRead    APIKeyScope = "read"    // all read endpoints
+        APIKeyScopeWrite   APIKeyScope = "write"   // all endpoints
+)
+
+// Allows reports whether a key with this scope may perform an operation requiring required
+func (s APIKeyScope) Allows(required APIKeyScope) bool {
+        switch s {
+        case APIKeyScopeWrite:
+                return true
+        case APIKeyScopeRead:
+                return required == APIKeyScopeRead || required == APIKeyScopeResolve
+        case APIKeyScopeResolve:
+                return required == APIKeyScopeResolve
+        }
+        return false
+}
+
+// APIKey represents a credential issued to a machine consumer. The secret is never stored.
+type APIKey struct {
+        ID         int64         `json:"id" db:"id"`
+        Name       string        `json:"name" db:"name"`
+        Prefix     string        `json:"prefix" db:"prefix"` // Identifies the key without revealing it
+        Scopes     []APIKeyScope `json:"scopes" db:"scopes"`
+        CreatedAt  time.Time     `json:"created_at" db:"created_at"`
+        RevokedAt  *time.Time    `json:"revoked_at" db:"revoked_at"`
+        ExpiresAt  *time.Time    `json:"expires_at" db:"expires_at"`
+        LastUsedAt *time.Time    `json:"last_used_at" db:"last_used_at"` // Recorded to the minute
+        RotatedAt  *time.Time    `json:"rotated_at" db:"rotated_at"`
+
+        // PreviousKeyExpiresAt is the end of the grace period during which the
+        // secret replaced by the last rotation still authenticates
+        PreviousKeyExpiresAt *time.Time `json:"previous_key_expires_at" db:"previous_key_expires_at"`
+}
+
+// CreatedAPIKey is returned once when a key is issued and includes the secret
+type CreatedAPIKey struct {
+        APIKey
+        Key string `json:"key"`
+}
+
+// CreateAPIKeyRequest represents the request to issue a new API key
+type CreateAPIKeyRequest struct {
+        Name      string        `json:"name" binding:"required"`
+        Scopes    []APIKeyScope `json:"scopes" binding:"required,min=1"`
+        ExpiresAt *time.Time    `json:"expires_at"` // The key never expires when omitted
+}
+
+// RotateAPIKeyRequest represents the request to replace the secret of an API key
+type RotateAPIKeyRequest struct {
+        GracePeriod string     `json:"grace_period"` // How long the old secret stays valid, such as 24h
+        ExpiresAt   *time.Time `json:"expires_at"`   // Replaces the expiration date when set
+}
+
+// TOTPEnrollment holds the shared secret of the authenticator app a user
+// enrolled for two-factor authentication. It is pending until the user
+// verifies a first code, proving the app was set up.
+type TOTPEnrollment struct {
+        Subject     string     `json:"subject" db:"subject"`
+        Secret      string     `json:"-" db:"secret"`
+        LastStep    int64      `json:"-" db:"last_step"` // Time step of the last code accepted
+        CreatedAt   time.Time  `json:"created_at" db:"created_at"`
+        ConfirmedAt *time.Time `json:"confirmed_at" db:"confirmed_at"`
+}
+
+// TOTPSecret is returned once when a user enrolls an authenticator app
+type TOTPSecret struct {
+        Secret string `json:"secret"` // Base32, for entering the secret by hand
+        URI    string `json:"uri"`    // otpauth:// URI, usually shown as a QR code
+}
+
+// TOTPStatus describes the two-factor authentication of the signed-in user
+type TOTPStatus struct {
+        Enrolled    bool       `json:"enrolled"`
+        Pending     bool       `json:"pending"`  // Enrolled but no code verified yet
+        Verified    bool       `json:"verified"` // A code was verified in this session
+        ConfirmedAt *time.Time `json:"confirmed_at"`
+}
+
+// TOTPCodeRequest carries a code shown by the user's authenticator app
+type TOTPCodeRequest struct {
+        Code string `json:"code" binding:"required"`
+}
+
+// ValidationWarning represents non-fatal advice about a write
+type ValidationWarning struct {
+        Code    string `json:"code"`
+        Message string `json:"message"`
+}
+
+// PropertyWriteResponse represents a created or updated property with any validation warnings
+type PropertyWriteResponse struct {
+        ConfigProperty
+        Warnings []ValidationWarning `json:"warnings"`
+}
+
+// PropertyDryRunResponse represents the outcome of a property write that was
+// validated but not persisted
+type PropertyDryRunResponse struct {
+        DryRun          bool                `json:"dry_run"`
+        Property        ConfigProperty      `json:"property"` // The property as it would be written
+        Warnings        []ValidationWarning `json:"warnings"`
+        Before          interface{}         `json:"before"` // Resolved value of the key on the node, for the property's environment
+        After           interface{}         `json:"after"`
+        Changed         bool                `json:"changed"`
+        InheritingNodes int                 `json:"inheriting_nodes"` // Descendants that would resolve the key from this property
+}
+
+// WorkspaceStatus represents the lifecycle state of a draft workspace
+type WorkspaceStatus string
+
+const (
+        WorkspaceStatusOpen      WorkspaceStatus = "open"
+        WorkspaceStatusMerged    WorkspaceStatus = "merged"
+        WorkspaceStatusDiscarded WorkspaceStatus = "discarded"
+)
+
+// WorkspaceOp represents the kind of edit recorded in a workspace
+type WorkspaceOp string
+
+const (
+        WorkspaceOpCreateNode     WorkspaceOp = "create_node"
+        WorkspaceOpUpdateNode     WorkspaceOp = "update_node"
+        WorkspaceOpDeleteNode     WorkspaceOp = "delete_node"
+        WorkspaceOpSetProperty    WorkspaceOp = "set_property"
+        WorkspaceOpDeleteProperty WorkspaceOp = "delete_property"
+)
+
+// Workspace represents an isolated draft of edits to a subtree
+type Workspace struct {
+        ID         int64           `json:"id" db:"id"`
+        Name       string          `json:"name" db:"name"`
+        RootNodeID int64           `json:"root_node_id" db:"root_node_id"`
+        Status     WorkspaceStatus `json:"status" db:"status"`
+        CreatedAt  time.Time       `json:"created_at" db:"created_at"`
+        UpdatedAt  time.Time       `json:"updated_at" db:"updated_at"`
+        MergedAt   *time.Time      `json:"merged_at" db:"merged_at"`
+}
+
+// WorkspaceChangeRequest represents a single edit made inside a workspace.
+// Nodes created in the workspace are referenced by the negated ID of their
+// create_node change until the workspace is merged.
+type WorkspaceChangeRequest struct {
+        Op           WorkspaceOp `json:"op" binding:"required"`
+        NodeID       *int64      `json:"node_id,omitempty"`   // update_node, delete_node, set_property, delete_property
+        ParentID     *int64      `json:"parent_id,omitempty"` // create_node
+        Name         *string     `json:"name,omitempty"`
+        NodeType     NodeType    `json:"node_type,omitempty"`
+        Key          string      `json:"key,omitempty"`
+        Value        *string     `json:"value,omitempty"`
+        DataType     DataType    `json:"data_type,omitempty"`
+        DefaultValue *string     `json:"default_value,omitempty"`
+        Description  *string     `json:"description,omitempty"`
+}
+
+// WorkspaceChange represents a recorded workspace edit
+type WorkspaceChange struct {
+        ID            int64                  `json:"id" db:"id"`
+        WorkspaceID   int64                  `json:"workspace_id" db:"workspace_id"`
+        Change        WorkspaceChangeRequest `json:"change" db:"payload"`
+        BaseUpdatedAt *time.Time             `json:"base_updated_at" db:"base_updated_at"` // Live version the edit was based on
+        CreatedAt     time.Time              `json:"created_at" db:"created_at"`
+}
+
+// WorkspaceWithChanges represents a workspace with its recorded edits
+type WorkspaceWithChanges struct {
+        Workspace
+        Changes []WorkspaceChange `json:"changes"`
+}
+
+// CreateWorkspaceRequest represents the request to branch a subtree into a workspace
+type CreateWorkspaceRequest struct {
+        Name       string `json:"name" binding:"required"`
+        RootNodeID int64  `json:"root_node_id" binding:"required"`
+}
+
+// WorkspaceConflict describes a workspace edit whose target changed in the live tree
+type WorkspaceConflict struct {
+        ChangeID int64  `json:"change_id"`
+        Reason   string `json:"reason"`
+}
+
+// WorkspaceMergeResult represents the outcome of merging a workspace
+type WorkspaceMergeResult struct {
+        Workspace
+        NodeIDs map[int64]int64 `json:"node_ids"` // Workspace node references to created node IDs
+}
+
+// Permission represents access granted on a subtree
+type Permission string
+
+const (
+        PermissionRead  Permission = "read"
+        PermissionWrite Permission = "write" // implies read
+)
+
+// NodePermission grants a principal access to a node and all of its descendants.
+// Principals are strings such as "apikey:12", "user:alice" or "group:emea-admins".
+type NodePermission struct {
+        ID         int64      `json:"id" db:"id"`
+        NodeID     int64      `json:"node_id" db:"node_id"`
+        Principal  string     `json:"principal" db:"principal"`
+        Permission Permission `json:"permission" db:"permission"`
+        CreatedAt  time.Time  `json:"created_at" db:"created_at"`
+}
+
+// GrantPermissionRequest represents the request to grant a principal access to a subtree
+type GrantPermissionRequest struct {
+        Principal  string     `json:"principal" binding:"required"`
+        Permission Permission `json:"permission" binding:"required"`
+}
+
+// FlagReason explains how a flag decision was reached
+type FlagReason string
+
+const (
+        FlagReasonRuleMatch FlagReason = "rule_match"
+        FlagReasonDefault   FlagReason = "default"
+        FlagReasonError     FlagReason = "error"
+
+        // Percentage rollouts of boolean properties
+        FlagReasonRollout         FlagReason = "rollout"
+        FlagReasonRolloutExcluded FlagReason = "rollout_excluded"
+)
+
+// FlagDecision is the value served for a feature flag in an evaluation context
+type FlagDecision struct {
+        Value     interface{} `json:"value"`
+        Reason    FlagReason  `json:"reason"`
+        RuleIndex *int        `json:"rule_index,omitempty"`
+        Error     string      `json:"error,omitempty"`
+}
+
+// EvaluateFlagsRequest represents the request to evaluate the flags of a node
+type EvaluateFlagsRequest struct {
+        Context     map[string]interface{} `json:"context"`
+        Environment string                 `json:"environment"`
+        Flags       []string               `json:"flags"` // Keys to evaluate, all flags when empty
+}
+
+// FlagEvaluation holds the decisions for the flags in effect on a node
+type FlagEvaluation struct {
+        NodeID      int64                   `json:"node_id"`
+        Environment string                  `json:"environment,omitempty"`
+        Flags       map[string]FlagDecision `json:"flags"`
+}
+
+// ScheduledChangeStatus represents the lifecycle state of a scheduled property change
+type ScheduledChangeStatus string
+
+const (
+        ScheduledChangeStatusPending   ScheduledChangeStatus = "pending"
+        ScheduledChangeStatusApplied   ScheduledChangeStatus = "applied"
+        ScheduledChangeStatusCancelled ScheduledChangeStatus = "cancelled"
+        ScheduledChangeStatusFailed    ScheduledChangeStatus = "failed"
+)
+
+// ScheduledChange represents a property write that takes effect at a future time
+type ScheduledChange struct {
+        ID           int64                 `json:"id" db:"id"`
+        NodeID       int64                 `json:"node_id" db:"node_id"`
+        Key          string                `json:"key" db:"key"`
+        Environment  *string               `json:"environment,omitempty" db:"environment"`
+        Value        string                `json:"value" db:"value"`
+        DataType     DataType              `json:"data_type" db:"data_type"`
+        DefaultValue *string               `json:"default_value" db:"default_value"`
+        Description  string                `json:"description" db:"description"`
+        EffectiveAt  time.Time             `json:"effective_at" db:"effective_at"`
+        Status       ScheduledChangeStatus `json:"status" db:"status"`
+        PropertyID   *int64                `json:"property_id,omitempty" db:"property_id"` // Property written when applied
+        Error        *string               `json:"error,omitempty" db:"error"`
+        CreatedAt    time.Time             `json:"created_at" db:"created_at"`
+        AppliedAt    *time.Time            `json:"applied_at,omitempty" db:"applied_at"`
+}
+
+// CreateScheduledChangeRequest represents the request to schedule a property write
+type CreateScheduledChangeRequest struct {
+        Key          string    `json:"key" binding:"required"`
+        Value        string    `json:"value" binding:"required"` // JSON string
+        DataType     DataType  `json:"data_type" binding:"required"`
+        Environment  *string   `json:"environment"`
+        DefaultValue *string   `json:"default_value"`
+        Description  string    `json:"description"`
+        EffectiveAt  time.Time `json:"effective_at" binding:"required"`
+}
+
+// PropertyDraft represents a staged property edit that is not served until published
+type PropertyDraft struct {
+        ID           int64     `json:"id" db:"id"`
+        NodeID       int64     `json:"node_id" db:"node_id"`
+        Key          string    `json:"key" db:"key"`
+        Environment  *string   `json:"environment,omitempty" db:"environment"`
+        Value        *string   `json:"value,omitempty" db:"value"`
+        DataType     *DataType `json:"data_type,omitempty" db:"data_type"`
+        DefaultValue *string   `json:"default_value,omitempty" db:"default_value"`
+        Description  string    `json:"description" db:"description"`
+        Delete       bool      `json:"delete" db:"delete"` // Stage removal of the property
+        CreatedAt    time.Time `json:"created_at" db:"created_at"`
+        UpdatedAt    time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// SavePropertyDraftRequest represents the request to stage a property edit.
+// Value and DataType are required unless Delete is set.
+type SavePropertyDraftRequest struct {
+        Key          string    `json:"key" binding:"required"`
+        Environment  *string   `json:"environment"`
+        Value        *string   `json:"value"` // JSON string
+        DataType     *DataType `json:"data_type"`
+        DefaultValue *string   `json:"default_value"`
+        Description  string    `json:"description"`
+        Delete       bool      `json:"delete"`
+}
+
+// PublishDraftsResult reports the live properties written by publishing the drafts of a node
+type PublishDraftsResult struct {
+        NodeID    int64            `json:"node_id"`
+        Published []ConfigProperty `json:"published"`
+        Deleted   []PropertyDraft  `json:"deleted"` // Applied removal drafts
+}
+
+// NodeProtection marks a subtree that may only be modified through approved change requests
+type NodeProtection struct {
+        NodeID            int64     `json:"node_id" db:"node_id"`
+        RequiredApprovals int       `json:"required_approvals" db:"required_approvals"`
+        CreatedAt         time.Time `json:"created_at" db:"created_at"`
+}
+
+// SetNodeProtectionRequest represents the request to protect a subtree
+type SetNodeProtectionRequest struct {
+        RequiredApprovals int `json:"required_approvals" binding:"omitempty,min=1"` // Defaults to 1
+}
+
+// ChangeRequestStatus represents the lifecycle state of a change request
+type ChangeRequestStatus string
+
+const (
+        ChangeRequestStatusOpen      ChangeRequestStatus = "open"
+        ChangeRequestStatusApproved  ChangeRequestStatus = "approved"
+        ChangeRequestStatusRejected  ChangeRequestStatus = "rejected"
+        ChangeRequestStatusApplied   ChangeRequestStatus = "applied"
+        ChangeRequestStatusWithdrawn ChangeRequestStatus = "withdrawn"
+)
+
+// ReviewDecision represents a reviewer's verdict on a change request
+type ReviewDecision string
+
+const (
+        ReviewDecisionApprove ReviewDecision = "approve"
+        ReviewDecisionReject  ReviewDecision = "reject"
+)
+
+// ChangeRequest represents the edits of a workspace submitted for review
+type ChangeRequest struct {
+        ID                int64               `json:"id" db:"id"`
+        WorkspaceID       int64               `json:"workspace_id" db:"workspace_id"`
+        Title             string              `json:"title" db:"title"`
+        Description       string              `json:"description" db:"description"`
+        Author            string              `json:"author" db:"author"` // Principal that submitted the request
+        Status            ChangeRequestStatus `json:"status" db:"status"`
+        RequiredApprovals int                 `json:"required_approvals" db:"required_approvals"`
+        CreatedAt         time.Time           `json:"created_at" db:"created_at"`
+        UpdatedAt         time.Time           `json:"updated_at" db:"updated_at"`
+        AppliedAt         *time.Time          `json:"applied_at,omitempty" db:"applied_at"`
+}
+
+// ChangeRequestReview represents a reviewer's decision on a change request
+type ChangeRequestReview struct {
+        ID              int64          `json:"id" db:"id"`
+        ChangeRequestID int64          `json:"change_request_id" db:"change_request_id"`
+        Reviewer        string         `json:"reviewer" db:"reviewer"`
+        Decision        ReviewDecision `json:"decision" db:"decision"`
+        Comment         string         `json:"comment" db:"comment"`
+        CreatedAt       time.Time      `json:"created_at" db:"created_at"`
+}
+
+// ChangeRequestDetails represents a change request with its reviews and proposed edits
+type ChangeRequestDetails struct {
+        ChangeRequest
+        Reviews []ChangeRequestReview `json:"reviews"`
+        Changes []WorkspaceChange     `json:"changes"`
+}
+
+// CreateChangeRequestRequest represents the request to submit a workspace for review
+type CreateChangeRequestRequest struct {
+        WorkspaceID int64  `json:"workspace_id" binding:"required"`
+        Title       string `json:"title" binding:"required"`
+        Description string `json:"description"`
+}
+
+// ReviewChangeRequestRequest represents the request to approve or reject a change request
+type ReviewChangeRequestRequest struct {
+        Decision ReviewDecision `json:"decision" binding:"required"`
+        Comment  string         `json:"comment"`
+}
+
+// PropertyDiff represents a key whose resolved value differs between two nodes.
+// Left is null for added keys and Right is null for removed ones.
+type PropertyDiff struct {
+        Key   string      `json:"key"`
+        Left  interface{} `json:"left"`
+        Right interface{} `json:"right"`
+}
+
+// PropertyChanges represents the differences between two resolved property sets
+type PropertyChanges struct {
+        Added     []PropertyDiff `json:"added"`   // Keys only the right side resolves
+        Removed   []PropertyDiff `json:"removed"` // Keys only the left side resolves
+        Changed   []PropertyDiff `json:"changed"`
+        Unchanged int            `json:"unchanged"`
+}
+
+// ConfigurationDiff represents the differences between the resolved configurations of two nodes
+type ConfigurationDiff struct {
+        Left        ConfigNode `json:"left"`
+        Right       ConfigNode `json:"right"`
+        Environment string     `json:"environment,omitempty"`
+        PropertyChanges
+}
+
+// ConfigSnapshot represents an immutable, named copy of the resolved
+// configuration of a node. Encrypted properties are left out.
+type ConfigSnapshot struct {
+        ID           int64                  `json:"id" db:"id"`
+        NodeID       int64                  `json:"node_id" db:"node_id"`
+        NodeName     string                 `json:"node_name" db:"node_name"`
+        Name         string                 `json:"name" db:"name"`
+        Description  string                 `json:"description" db:"description"`
+        Environment  string                 `json:"environment,omitempty" db:"environment"`
+        Properties   map[string]interface{} `json:"properties" db:"properties"`
+        RedactedKeys []string               `json:"redacted_keys" db:"redacted_keys"` // Encrypted keys left out of the snapshot
+        CreatedBy    string                 `json:"created_by,omitempty" db:"created_by"`
+        CreatedAt    time.Time              `json:"created_at" db:"created_at"`
+}
+
+// CreateSnapshotRequest represents the request to snapshot a node's resolved configuration
+type CreateSnapshotRequest struct {
+        Name        string `json:"name" binding:"required"`
+        Description string `json:"description"`
+        Environment string `json:"environment"`
+}
+
+// SnapshotDiff represents the differences between a snapshot and another
+// snapshot or the current configuration of its node
+type SnapshotDiff struct {
+        Left  int64  `json:"left"`
+        Right *int64 `json:"right"` // Null when comparing with the current configuration
+        PropertyChanges
+}
+
+// Change is a change event in the change feed
+type Change struct {
+        Cursor int64 `json:"cursor"` // Feed position, pass as ?since= to read the changes after it
+        events.Event
+}
+
+// ChangeFeed represents a page of the change feed
+type ChangeFeed struct {
+        Changes    []Change `json:"changes"`
+        NextCursor int64    `json:"next_cursor"` // Cursor of the last change, or the requested one when there are none
+        HasMore    bool     `json:"has_more"`
+}
+
+// DeclaredNode describes a node and its subtree as a document, as exported and
+// imported by cfgctl. Nodes are identified by their name among their siblings.
+type DeclaredNode struct {
+        Name        string             `json:"name" yaml:"name"`
+        Type        NodeType           `json:"type" yaml:"type"`
+        Description string             `json:"description,omitempty" yaml:"description,omitempty"`
+        Properties  []DeclaredProperty `json:"properties,omitempty" yaml:"properties,omitempty"`
+        Children    []DeclaredNode     `json:"children,omitempty" yaml:"children,omitempty"`
+}
+
+// DeclaredProperty describes a property of a DeclaredNode. Values are plain
+// JSON or YAML values rather than serialized strings; the values of encrypted
+// properties are left out of exports.
+type DeclaredProperty struct {
+        Key         string      `json:"key" yaml:"key"`
+        Environment *string     `json:"environment,omitempty" yaml:"environment,omitempty"`
+        Type        DataType    `json:"type" yaml:"type"`
+        Value       interface{} `json:"value,omitempty" yaml:"value,omitempty"`
+        Description string      `json:"description,omitempty" yaml:"description,omitempty"`
+        Encrypted   bool        `json:"encrypted,omitempty" yaml:"encrypted,omitempty"`
+        Final       bool        `json:"final,omitempty" yaml:"final,omitempty"`
+}
+
+// ApplyRequest represents a declarative document to converge a subtree to.
+// The subtree root is the child of ParentID, or the root node, named like Node.
+type ApplyRequest struct {
+        ParentID *int64       `json:"parent_id" yaml:"parent_id"`
+        Node     DeclaredNode `json:"node" yaml:"node"`
+}
+
+// ApplyChange represents one create, update or delete of an apply plan
+type ApplyChange struct {
+        Action      string      `json:"action"` // create, update or delete
+        Kind        string      `json:"kind"`   // node or property
+        Path        string      `json:"path"`   // Node names from the parent, e.g. /EMEA/Germany
+        NodeID      *int64      `json:"node_id,omitempty"`
+        Key         string      `json:"key,omitempty"`
+        Environment *string     `json:"environment,omitempty"`
+        Fields      []string    `json:"fields,omitempty"` // What an update changes: value, type, description or final
+        Old         interface{} `json:"old,omitempty"`
+        New         interface{} `json:"new,omitempty"`
+}
+
+// ApplyResult represents the plan of an apply, and whether it was carried out
+type ApplyResult struct {
+        DryRun     bool          `json:"dry_run"`
+        RootNodeID *int64        `json:"root_node_id"` // Null when a dry run would create the root
+        Changes    []ApplyChange `json:"changes"`
+        Unchanged  int           `json:"unchanged"` // Declared nodes and properties already in place
+        Warnings   []string      `json:"warnings"`
+}
+
+// Drift represents a difference between a declarative document and the live
+// tree: something declared that is missing, differs, or is not declared
+type Drift struct {
+        Status      string      `json:"status"` // missing, changed or unexpected
+        Kind        string      `json:"kind"`   // node or property
+        Path        string      `json:"path"`
+        NodeID      *int64      `json:"node_id,omitempty"`
+        Key         string      `json:"key,omitempty"`
+        Environment *string     `json:"environment,omitempty"`
+        Fields      []string    `json:"fields,omitempty"`
+        Declared    interface{} `json:"declared,omitempty"`
+        Live        interface{} `json:"live,omitempty"`
+}
+
+// DriftReport lists every difference between a declarative document and the
+// live tree
+type DriftReport struct {
+        RootNodeID *int64   `json:"root_node_id"` // Null when the declared root does not exist
+        InSync     bool     `json:"in_sync"`
+        Drift      []Drift  `json:"drift"`
+        Unchanged  int      `json:"unchanged"`
+        Warnings   []string `json:"warnings"`
+}
+
+// RequiredKey declares a key every leaf below a node must resolve
+type RequiredKey struct {
+        ID          int64     `json:"id" db:"id"`
+        NodeID      int64     `json:"node_id" db:"node_id"`
+        Key         string    `json:"key" db:"key"`
+        Description string    `json:"description" db:"description"`
+        CreatedBy   string    `json:"created_by,omitempty" db:"created_by"`
+        CreatedAt   time.Time `json:"created_at" db:"created_at"`
+}
+
+// CreateRequiredKeyRequest represents the request to require a key below a node
+type CreateRequiredKeyRequest struct {
+        Key         string `json:"key" binding:"required"`
+        Description string `json:"description"`
+}
+
+// IncompleteLeaf is a leaf node that does not resolve some of its required keys
+type IncompleteLeaf struct {
+        NodeID      int64    `json:"node_id"`
+        NodeName    string   `json:"node_name"`
+        Path        string   `json:"path"` // Node names from the root, e.g. /Europe/Germany/Berlin
+        MissingKeys []string `json:"missing_keys"`
+}
+
+// CompletenessReport lists the leaves below a node that are missing required keys
+type CompletenessReport struct {
+        NodeID        int64            `json:"node_id"`
+        Environment   string           `json:"environment,omitempty"`
+        Complete      bool             `json:"complete"`
+        LeavesChecked int              `json:"leaves_checked"`
+        Incomplete    []IncompleteLeaf `json:"incomplete"`
+}
+
+// LintFinding is a problem found in the tree by the linter
+type LintFinding struct {
+        Code        string  `json:"code"` // identical_child_overrides, same_as_inherited, data_type_mismatch or dangling_default_value
+        NodeID      int64   `json:"node_id"`
+        Path        string  `json:"path"`
+        Key         string  `json:"key"`
+        Environment *string `json:"environment,omitempty"`
+        PropertyIDs []int64 `json:"property_ids"` // The properties the finding is about
+        Message     string  `json:"message"`
+}
+
+// LintReport lists the problems found in the tree, or in one subtree
+type LintReport struct {
+        RootNodeID        *int64         `json:"root_node_id,omitempty"`
+        NodesScanned      int            `json:"nodes_scanned"`
+        PropertiesScanned int            `json:"properties_scanned"`
+        Counts            map[string]int `json:"counts"` // Findings per code
+        Findings          []LintFinding  `json:"findings"`
+}
+
+// OverrideLink is one definition of a key along the path to an override
+type OverrideLink struct {
+        NodeID      int64       `json:"node_id"`
+        NodeName    string      `json:"node_name"`
+        PropertyID  int64       `json:"property_id"`
+        Environment *string     `json:"environment,omitempty"`
+        Value       interface{} `json:"value"` // Null for encrypted properties
+        Encrypted   bool        `json:"encrypted,omitempty"`
+}
+
+// Override is a key a node defines again after an ancestor defined it, with
+// the definitions from the root down to the overriding node
+type Override struct {
+        Key     string         `json:"key"`
+        NodeID  int64          `json:"node_id"`
+        Path    string         `json:"path"`
+        Chain   []OverrideLink `json:"chain"`
+        Ignored bool           `json:"ignored,omitempty"` // An ancestor locked the key as final
+}
+
+// OverrideReport lists every override within a subtree
+type OverrideReport struct {
+        NodeID      int64      `json:"node_id"`
+        Environment string     `json:"environment,omitempty"`
+        Overrides   []Override `json:"overrides"`
+}
+
+// SimulatedChange is a hypothetical property edit on a node
+type SimulatedChange struct {
+        NodeID int64 `json:"node_id" binding:"required"`
+        SavePropertyDraftRequest
+}
+
+// SimulateRequest represents the request to resolve a node as if some
+// property edits were applied
+type SimulateRequest struct {
+        Changes []SimulatedChange `json:"changes" binding:"required,min=1,max=100,dive"`
+}
+
+// SimulationResult represents a configuration resolved with hypothetical edits
+// and how it differs from the current one
+type SimulationResult struct {
+        Resolved *ResolvedConfiguration `json:"resolved"`
+        Ignored  []int                  `json:"ignored"` // Indexes of changes an ancestor's final property overrides
+        PropertyChanges
+}
+
+// ImpactedLeaf is a leaf whose value of a key a proposed change would change
+type ImpactedLeaf struct {
+        NodeID    int64       `json:"node_id"`
+        NodeName  string      `json:"node_name"`
+        Path      string      `json:"path"`
+        HadKey    bool        `json:"had_key"`
+        Before    interface{} `json:"before"`
+        HasKey    bool        `json:"has_key"`
+        After     interface{} `json:"after"`
+        Encrypted bool        `json:"encrypted,omitempty"` // Values are left out
+}
+
+// ImpactReport lists the leaves below a node whose value of a key a proposed
+// change of the node's property would change
+type ImpactReport struct {
+        NodeID        int64          `json:"node_id"`
+        Key           string         `json:"key"`
+        Environment   *string        `json:"environment,omitempty"`
+        Ignored       bool           `json:"ignored,omitempty"` // An ancestor locked the key as final
+        LeavesChecked int            `json:"leaves_checked"`
+        Affected      []ImpactedLeaf `json:"affected"`
+}
+
+// PropertySearchResult represents a property found across the tree, with the path of its node
+type PropertySearchResult struct {
+        ConfigProperty
+        Path string `json:"path"`
+}
+
+// ExpiryReport lists the properties that have expired but are still stored,
+// and those expiring within a window
+type ExpiryReport struct {
+        Now      time.Time              `json:"now"`
+        Within   string                 `json:"within,omitempty"`
+        Expired  []PropertySearchResult `json:"expired"`
+        Expiring []PropertySearchResult `json:"expiring"`
+}
+
+// KeyDeprecation marks a key as being retired across the whole tree
+type KeyDeprecation struct {
+        Key            string     `json:"key" db:"key"`
+        ReplacementKey *string    `json:"replacement_key,omitempty" db:"replacement_key"`
+        SunsetAt       *time.Time `json:"sunset_at,omitempty" db:"sunset_at"` // When the key should no longer be defined
+        Reason         string     `json:"reason" db:"reason"`
+        CreatedBy      string     `json:"created_by,omitempty" db:"created_by"`
+        CreatedAt      time.Time  `json:"created_at" db:"created_at"`
+}
+
+// DeprecateKeyRequest represents the request to deprecate a key
+type DeprecateKeyRequest struct {
+        Key            string     `json:"key" binding:"required"`
+        ReplacementKey *string    `json:"replacement_key"`
+        SunsetAt       *time.Time `json:"sunset_at"`
+        Reason         string     `json:"reason"`
+}
+
+// DeprecatedKeyUsage lists the properties still defining a deprecated key
+type DeprecatedKeyUsage struct {
+        KeyDeprecation
+        PastSunset  bool                   `json:"past_sunset"`
+        Definitions []PropertySearchResult `json:"definitions"`
+}
+
+// DeprecationReport lists the deprecated keys still defined within a subtree, or the whole tree
+type DeprecationReport struct {
+        RootNodeID *int64               `json:"root_node_id,omitempty"`
+        Keys       []DeprecatedKeyUsage `json:"keys"`
+}
+
+// TemplateProperty is a property provided by a template
+type TemplateProperty struct {
+        Key         string   `json:"key" binding:"required"`
+        Value       string   `json:"value" binding:"required"` // JSON string
+        DataType    DataType `json:"data_type" binding:"required"`
+        Environment *string  `json:"environment,omitempty"` // Override for one environment, nil for all
+        Description string   `json:"description,omitempty"`
+}
+
+// PropertyTemplate is a named, versioned bundle of properties that can be attached to nodes
+type PropertyTemplate struct {
+        ID            int64     `json:"id" db:"id"`
+        Name          string    `json:"name" db:"name"`
+        Description   string    `json:"description" db:"description"`
+        LatestVersion int       `json:"latest_version" db:"latest_version"`
+        CreatedBy     string    `json:"created_by,omitempty" db:"created_by"`
+        CreatedAt     time.Time `json:"created_at" db:"created_at"`
+        UpdatedAt     time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// TemplateVersion is an immutable version of the properties of a template
+type TemplateVersion struct {
+        TemplateID int64              `json:"template_id" db:"template_id"`
+        Version    int                `json:"version" db:"version"`
+        Properties []TemplateProperty `json:"properties" db:"properties"`
+        Comment    string             `json:"comment" db:"comment"`
+        CreatedBy  string             `json:"created_by,omitempty" db:"created_by"`
+        CreatedAt  time.Time          `json:"created_at" db:"created_at"`
+}
+
+// PropertyTemplateWithVersion represents a template with the properties of its latest version
+type PropertyTemplateWithVersion struct {
+        PropertyTemplate
+        Properties []TemplateProperty `json:"properties"`
+}
+
+// CreateTemplateRequest represents the request to create a template at version 1
+type CreateTemplateRequest struct {
+        Name        string             `json:"name" binding:"required"`
+        Description string             `json:"description"`
+        Properties  []TemplateProperty `json:"properties" binding:"required,dive"`
+}
+
+// CreateTemplateVersionRequest represents the request to publish a new version of a template
+type CreateTemplateVersionRequest struct {
+        Properties []TemplateProperty `json:"properties" binding:"required,dive"`
+        Comment    string             `json:"comment"`
+}
+
+// NodeTemplate is a template attached to a node
+type NodeTemplate struct {
+        ID             int64     `json:"id" db:"id"`
+        NodeID         int64     `json:"node_id" db:"node_id"`
+        TemplateID     int64     `json:"template_id" db:"template_id"`
+        TemplateName   string    `json:"template_name" db:"template_name"`
+        Version        *int      `json:"version" db:"version"` // Pinned version, nil to follow the latest
+        AppliedVersion int       `json:"applied_version" db:"applied_version"`
+        AttachedBy     string    `json:"attached_by,omitempty" db:"attached_by"`
+        AttachedAt     time.Time `json:"attached_at" db:"attached_at"`
+}
+
+// AttachTemplateRequest represents the request to attach a template to a node,
+// or to change the version an attached template is pinned to
+type AttachTemplateRequest struct {
+        TemplateID int64 `json:"template_id" binding:"required"`
+        Version    *int  `json:"version"` // Pin a version, or follow the latest when nil
+}
+
+// PromotePropertyResult reports the outcome of promoting a property
+type PromotePropertyResult struct {
+        Property           ConfigProperty      `json:"property"`             // The property on the parent
+        RemovedPropertyIDs []int64             `json:"removed_property_ids"` // The promoted property and removed sibling overrides
+        NowInheriting      []int64             `json:"now_inheriting"`       // Children of the parent that inherit the promoted value
+        Warnings           []ValidationWarning `json:"warnings"`
+}
+
+// PushDownPropertyResult reports the outcome of pushing a property down to the children of its node
+type PushDownPropertyResult struct {
+        RemovedPropertyID int64            `json:"removed_property_id"`
+        Created           []ConfigProperty `json:"created"` // Copies on the children
+        Kept              []int64          `json:"kept"`    // Children already defining the key, left unchanged
+}
+
+// RenameKeyRequest represents the request to rename a key across the tree
+type RenameKeyRequest struct {
+        NewKey string `json:"new_key" binding:"required"`
+}
+
+// KeyRenameResult reports the outcome of renaming a key across the tree
+type KeyRenameResult struct {
+        OldKey                 string  `json:"old_key"`
+        NewKey                 string  `json:"new_key"`
+        PropertyIDs            []int64 `json:"property_ids"` // Renamed properties
+        NodeIDs                []int64 `json:"node_ids"`     // Nodes defining the renamed properties
+        RenamedDrafts          int     `json:"renamed_drafts"`
+        ReferencingPropertyIDs []int64 `json:"referencing_property_ids"` // Values still interpolating ${old_key}
+        AuditID                int64   `json:"audit_id"`
+}
+
+// AuditEntry represents an administrative operation recorded in the audit log
+type AuditEntry struct {
+        ID         int64                  `json:"id" db:"id"`
+        Action     string                 `json:"action" db:"action"` // E.g. key.rename
+        Actor      string                 `json:"actor" db:"actor"`
+        Details    map[string]interface{} `json:"details" db:"details"`
+        OccurredAt time.Time              `json:"occurred_at" db:"occurred_at"`
+}
+
+// RegisteredKey represents a key in the key registry
+type RegisteredKey struct {
+        Key          string    `json:"key" db:"key"`
+        Description  string    `json:"description" db:"description"`
+        ExpectedType *DataType `json:"expected_type,omitempty" db:"expected_type"` // Writes of another type are warned about
+        OwnerTeam    string    `json:"owner_team" db:"owner_team"`
+        SchemaRef    string    `json:"schema_ref" db:"schema_ref"` // E.g. a URL of the JSON schema of the value
+        CreatedBy    string    `json:"created_by,omitempty" db:"created_by"`
+        CreatedAt    time.Time `json:"created_at" db:"created_at"`
+        UpdatedAt    time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// RegisterKeyRequest represents the request to register a key, or update its registration
+type RegisterKeyRequest struct {
+        Key          string    `json:"key" binding:"required"`
+        Description  string    `json:"description"`
+        ExpectedType *DataType `json:"expected_type"`
+        OwnerTeam    string    `json:"owner_team"`
+        SchemaRef    string    `json:"schema_ref"`
+}
+
+// KeyCatalogEntry represents a registered or defined key with its usage
+type KeyCatalogEntry struct {
+        Key           string         `json:"key"`
+        Registration  *RegisteredKey `json:"registration"` // Nil for keys defined without being registered
+        PropertyCount int            `json:"property_count"`
+        NodeCount     int            `json:"node_count"`
+}
+
+// ReplaceValuesRequest represents the request to find and replace strings in
+// the property values of a subtree
+type ReplaceValuesRequest struct {
+        Find         string `json:"find" binding:"required"`
+        Replace      string `json:"replace"`
+        Regex        bool   `json:"regex"`         // Find is a regular expression, Replace may use $1 for its groups
+        Key          string `json:"key"`           // Only replace in the values of this key
+        PreviewToken string `json:"preview_token"` // Token of the dry run previewing this replacement
+}
+
+// ValueReplacement represents a property whose value a replacement rewrites
+type ValueReplacement struct {
+        PropertyID  int64     `json:"property_id"`
+        NodeID      int64     `json:"node_id"`
+        Path        string    `json:"path"`
+        Key         string    `json:"key"`
+        Environment *string   `json:"environment,omitempty"`
+        Before      string    `json:"before"`
+        After       string    `json:"after"`
+        UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// ReplaceValuesResponse represents the preview or the outcome of a value replacement
+type ReplaceValuesResponse struct {
+        DryRun       bool               `json:"dry_run"`
+        Replacements []ValueReplacement `json:"replacements"`
+        PreviewToken string             `json:"preview_token,omitempty"` // Pass to apply exactly this preview
+        AuditID      int64              `json:"audit_id,omitempty"`
+}
+
+// KeyInventoryEntry represents a key defined within a subtree, with where it is defined
+type KeyInventoryEntry struct {
+        Key           string            `json:"key"`
+        PropertyCount int               `json:"property_count"`
+        NodeCount     int               `json:"node_count"`
+        Environments  []string          `json:"environments"` // Environments with their own definitions
+        Nodes         []KeyDefiningNode `json:"nodes"`
+}
+
+// KeyDefiningNode represents a node defining a key, with its path
+type KeyDefiningNode struct {
+        NodeID int64  `json:"node_id"`
+        Path   string `json:"path"`
+}
+
+// BatchOp represents the kind of write in a batch
+type BatchOp string
+
+const (
+        BatchOpCreateNode     BatchOp = "create_node"
+        BatchOpUpdateNode     BatchOp = "update_node"
+        BatchOpDeleteNode     BatchOp = "delete_node"
+        BatchOpCreateProperty BatchOp = "create_property"
+        BatchOpUpdateProperty BatchOp = "update_property"
+        BatchOpDeleteProperty BatchOp = "delete_property"
+)
+
+// BatchOperation represents one write of a batch, with the request of its op.
+// A negative node ID refers to the node created by an earlier operation of the
+// batch: -1 is the node created by the first operation, -2 by the second.
+type BatchOperation struct {
+        Op             BatchOp                `json:"op" binding:"required"`
+        NodeID         *int64                 `json:"node_id,omitempty"`     // update_node, delete_node, create_property
+        PropertyID     *int64                 `json:"property_id,omitempty"` // update_property, delete_property
+        CreateNode     *CreateNodeRequest     `json:"create_node,omitempty"` // parentId may be negative
+        UpdateNode     *UpdateNodeRequest     `json:"update_node,omitempty"`
+        CreateProperty *CreatePropertyRequest `json:"create_property,omitempty"`
+        UpdateProperty *UpdatePropertyRequest `json:"update_property,omitempty"`
+}
+
+// BatchRequest represents the request to apply writes in one transaction
+type BatchRequest struct {
+        Operations []BatchOperation `json:"operations" binding:"required,min=1,dive"`
+}
+
+// BatchResult represents the outcome of one operation of a batch. Node or
+// Property is the written entity, and both are null for deletes.
+type BatchResult struct {
+        Op         BatchOp         `json:"op"`
+        NodeID     *int64          `json:"node_id,omitempty"`
+        PropertyID *int64          `json:"property_id,omitempty"`
+        Node       *ConfigNode     `json:"node,omitempty"`
+        Property   *ConfigProperty `json:"property,omitempty"`
+}
+
+// BatchResponse represents the results of a batch, in the order of its operations
+type BatchResponse struct {
+        Results []BatchResult `json:"results"`
+}
+
+// OrganizationQuota limits the size of an organization, the subtree of a root
+// node, or of every organization without a quota of its own when RootNodeID is
+// nil. A nil limit falls back to that default quota, 0 lifts the limit.
+type OrganizationQuota struct {
+        RootNodeID           *int64    `json:"root_node_id" db:"root_node_id"`
+        MaxNodes             *int      `json:"max_nodes" db:"max_nodes"`
+        MaxPropertiesPerNode *int      `json:"max_properties_per_node" db:"max_properties_per_node"`
+        MaxDepth             *int      `json:"max_depth" db:"max_depth"` // Levels, the root being the first
+        RequestsPerMinute    *int      `json:"requests_per_minute" db:"requests_per_minute"`
+        UpdatedAt            time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// SetQuotaRequest represents the request to replace a quota
+type SetQuotaRequest struct {
+        MaxNodes             *int `json:"max_nodes" binding:"omitempty,min=0"`
+        MaxPropertiesPerNode *int `json:"max_properties_per_node" binding:"omitempty,min=0"`
+        MaxDepth             *int `json:"max_depth" binding:"omitempty,min=0"`
+        RequestsPerMinute    *int `json:"requests_per_minute" binding:"omitempty,min=0"`
+}
+
+// QuotaUsage compares the usage of a resource with its limit, nil when unlimited
+type QuotaUsage struct {
+        Used  int  `json:"used"`
+        Limit *int `json:"limit"`
+}
+
+// OrganizationUsage represents the usage of an organization against its quota
+type OrganizationUsage struct {
+        RootNodeID        int64      `json:"root_node_id"`
+        RootNodeName      string     `json:"root_node_name"`
+        Nodes             QuotaUsage `json:"nodes"`
+        PropertiesPerNode QuotaUsage `json:"properties_per_node"` // Used is the most properties of one node
+        Depth             QuotaUsage `json:"depth"`
+        RequestsPerMinute QuotaUsage `json:"requests_per_minute"` // Used counts the requests this server handled in the current minute
+}
+
+// AdminStats summarizes the tree for the operations dashboard
+type AdminStats struct {
+        GeneratedAt        time.Time       `json:"generated_at"`
+        Nodes              NodeStats       `json:"nodes"`
+        Properties         PropertyStats   `json:"properties"`
+        DepthDistribution  []DepthCount    `json:"depth_distribution"`
+        MostOverriddenKeys []OverriddenKey `json:"most_overridden_keys"`
+        RecentChanges      ChangeVolume    `json:"recent_changes"`
+}
+
+// NodeStats counts the nodes of the tree, in total and by node type
+type NodeStats struct {
+        Total  int            `json:"total"`
+        ByType map[string]int `json:"by_type"`
+}
+
+// PropertyStats counts the properties of the tree and the secrets among them
+type PropertyStats struct {
+        Total   int `json:"total"`
+        Secrets int `json:"secrets"` // Encrypted properties
+}
+
+// DepthCount represents the number of nodes at a level of the tree
+type DepthCount struct {
+        Depth int `json:"depth"` // Levels, the roots being the first
+        Nodes int `json:"nodes"`
+}
+
+// OverriddenKey represents a key with the number of nodes overriding it, i.e.
+// defining it while an ancestor does too
+type OverriddenKey struct {
+        Key       string `json:"key"`
+        Overrides int    `json:"overrides"`
+}
+
+// ChangeVolume counts the node and property changes of the last week
+type ChangeVolume struct {
+        LastHour    int            `json:"last_hour"`
+        Last24Hours int            `json:"last_24_hours"`
+        Last7Days   int            `json:"last_7_days"`
+        ByDay       []DailyChanges `json:"by_day"`
+        ByType      map[string]int `json:"by_type"` // Event types, e.g. property.changed
+}
+
+// DailyChanges represents the changes of a day, in UTC
+type DailyChanges struct {
+        Date    string `json:"date"`
+        Changes int    `json:"changes"`
+}
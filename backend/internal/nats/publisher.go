@@ -0,0 +1,114 @@
+package nats
+
+import (
+	"config-manager/internal/events"
+	"context"
+	"encoding/json"
+	"errors"
+	"strconv"
+
+	natsgo "github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+// Config describes the NATS servers and JetStream subject events are published to
+type Config struct {
+	URL         string // Comma-separated server URLs
+	Subject     string // Events are published to <Subject>.<event type>
+	Stream      string // Created or updated to capture <Subject>.> when set
+	Credentials string // Path to a .creds file
+}
+
+// Publisher writes change events to JetStream. Each message carries the JSON
+// encoded events.Event, the same payload as the Kafka publisher, on the subject
+// <subject>.<type>, e.g. config-manager.events.property.changed. The event ID is
+// sent as the Nats-Msg-Id header, so the stream drops duplicates.
+type Publisher struct {
+	conn    *natsgo.Conn
+	js      jetstream.JetStream
+	subject string
+}
+
+// NewPublisher connects to cfg.URL. The connection is retried in the
+// background, so the server starts while NATS is unreachable.
+func NewPublisher(ctx context.Context, cfg Config) (*Publisher, error) {
+	if cfg.URL == "" {
+		return nil, errors.New("no NATS URL configured")
+	}
+	if cfg.Subject == "" {
+		return nil, errors.New("no NATS subject configured")
+	}
+
+	options := []natsgo.Option{
+		natsgo.Name("config-manager"),
+		natsgo.MaxReconnects(-1),
+		natsgo.RetryOnFailedConnect(true),
+	}
+	if cfg.Credentials != "" {
+		options = append(options, natsgo.UserCredentials(cfg.Credentials))
+	}
+	conn, err := natsgo.Connect(cfg.URL, options...)
+	if err != nil {
+		return nil, err
+	}
+
+	js, err := jetstream.New(conn)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	if cfg.Stream != "" {
+		_, err := js.CreateOrUpdateStream(ctx, jetstream.StreamConfig{
+			Name:     cfg.Stream,
+			Subjects: []string{cfg.Subject + ".>"},
+		})
+		if err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+
+	return &Publisher{conn: conn, js: js, subject: cfg.Subject}, nil
+}
+
+func (p *Publisher) Publish(ctx context.Context, event events.Event) error {
+	return p.PublishBatch(ctx, []events.Event{event})
+}
+
+// PublishBatch sends every event before waiting for the acknowledgements
+func (p *Publisher) PublishBatch(ctx context.Context, batch []events.Event) error {
+	acks := make([]jetstream.PubAckFuture, 0, len(batch))
+	for _, event := range batch {
+		value, err := json.Marshal(event)
+		if err != nil {
+			return err
+		}
+
+		msg := natsgo.NewMsg(p.subject + "." + string(event.Type))
+		msg.Data = value
+		msg.Header.Set("Type", string(event.Type))
+		msg.Header.Set("Schema-Version", strconv.Itoa(event.SchemaVersion))
+		ack, err := p.js.PublishMsgAsync(msg, jetstream.WithMsgID(event.ID))
+		if err != nil {
+			return err
+		}
+		acks = append(acks, ack)
+	}
+
+	for _, ack := range acks {
+		select {
+		case <-ack.Ok():
+		case err := <-ack.Err():
+			return err
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// Close drains the connection
+func (p *Publisher) Close() error {
+	return p.conn.Drain()
+}
@@ -0,0 +1,118 @@
+package outbox
+
+import (
+	"config-manager/internal/database"
+	"config-manager/internal/events"
+	"context"
+	"log"
+	"sync/atomic"
+	"time"
+)
+
+// batchSize is the number of events published per outbox transaction
+const batchSize = 100
+
+// purgeInterval is how often published events older than the retention are deleted
+const purgeInterval = time.Hour
+
+// Dispatcher delivers the change events recorded in the outbox to a publisher.
+// Events are delivered at least once and in order: a batch is only marked
+// published when every publisher accepted it, and is retried otherwise.
+type Dispatcher struct {
+	repo      *database.Repository
+	publisher events.Publisher
+	interval  time.Duration
+	retention time.Duration
+
+	deliveries atomic.Uint64 // batches handed to the publisher
+	failures   atomic.Uint64 // of which the publisher rejected
+}
+
+// New creates a dispatcher that polls the outbox every interval and keeps
+// published events for retention
+func New(repo *database.Repository, publisher events.Publisher, interval, retention time.Duration) *Dispatcher {
+	return &Dispatcher{repo: repo, publisher: publisher, interval: interval, retention: retention}
+}
+
+// Run dispatches events until ctx is cancelled
+func (d *Dispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.interval)
+	defer ticker.Stop()
+
+	lastPurge := time.Time{}
+	for {
+		d.dispatch(ctx)
+
+		if time.Since(lastPurge) >= purgeInterval {
+			if _, err := d.repo.PurgeOutbox(ctx, time.Now().Add(-d.retention)); err != nil {
+				log.Printf("Failed to purge published events: %v", err)
+			}
+			lastPurge = time.Now()
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// dispatch publishes pending events until the outbox is empty or publishing fails
+func (d *Dispatcher) dispatch(ctx context.Context) {
+	// Events join the change feed whether or not they can be published
+	if _, err := d.repo.SequenceOutbox(ctx); err != nil {
+		log.Printf("Failed to add change events to the feed: %v", err)
+	}
+
+	for ctx.Err() == nil {
+		published, err := d.repo.DispatchOutbox(ctx, batchSize, func(pending []events.Event) error {
+			d.deliveries.Add(1)
+			err := events.PublishAll(ctx, d.publisher, coalesce(pending))
+			if err != nil {
+				d.failures.Add(1)
+			}
+			return err
+		})
+		if err != nil {
+			log.Printf("Failed to dispatch change events, retrying in %s: %v", d.interval, err)
+			return
+		}
+		if published < batchSize {
+			return
+		}
+	}
+}
+
+// Deliveries returns the number of batches of events handed to the publisher
+func (d *Dispatcher) Deliveries() uint64 {
+	return d.deliveries.Load()
+}
+
+// Failures returns the number of batches the publisher failed to deliver, to be
+// retried
+func (d *Dispatcher) Failures() uint64 {
+	return d.failures.Load()
+}
+
+// coalesce drops config.invalidated events that are followed by another one for
+// the same node in the batch, so a merge touching many properties of a node
+// invalidates it once, after its last change
+func coalesce(batch []events.Event) []events.Event {
+	seen := map[int64]bool{}
+	kept := make([]events.Event, 0, len(batch))
+	for i := len(batch) - 1; i >= 0; i-- {
+		event := batch[i]
+		if event.Type == events.TypeConfigInvalidated {
+			if seen[event.NodeID] {
+				continue
+			}
+			seen[event.NodeID] = true
+		}
+		kept = append(kept, event)
+	}
+	for i, j := 0, len(kept)-1; i < j; i, j = i+1, j-1 {
+		kept[i], kept[j] = kept[j], kept[i]
+	}
+	return kept
+}
@@ -0,0 +1,132 @@
+// Package problem writes API error responses as RFC 7807 problem details
+package problem
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ContentType is the media type of problem details responses
+const ContentType = "application/problem+json"
+
+// Code identifies the cause of a problem. Unlike the detail text, codes are
+// stable and meant for clients to branch on.
+type Code string
+
+// Codes returned in the code member of every problem
+const (
+	CodeInvalidRequest        Code = "INVALID_REQUEST"
+	CodeInvalidID             Code = "INVALID_ID"
+	CodeInvalidDataType       Code = "INVALID_DATA_TYPE"
+	CodeInvalidValue          Code = "INVALID_VALUE"
+	CodeInvalidEnvironment    Code = "INVALID_ENVIRONMENT"
+	CodeInvalidNamespace      Code = "INVALID_NAMESPACE"
+	CodeKeyNotRegistered      Code = "KEY_NOT_REGISTERED"
+	CodePreviewRequired       Code = "PREVIEW_REQUIRED"
+	CodeUnauthenticated       Code = "UNAUTHENTICATED"
+	CodePermissionDenied      Code = "PERMISSION_DENIED"
+	CodeAdminRequired         Code = "ADMIN_REQUIRED"
+	CodeTOTPRequired          Code = "TOTP_REQUIRED"
+	CodeNetworkNotAllowed     Code = "NETWORK_NOT_ALLOWED"
+	CodeSubtreeProtected      Code = "SUBTREE_PROTECTED"
+	CodeNotFound              Code = "NOT_FOUND"
+	CodeNodeNotFound          Code = "NODE_NOT_FOUND"
+	CodeParentNotFound        Code = "PARENT_NOT_FOUND"
+	CodePropertyNotFound      Code = "PROPERTY_NOT_FOUND"
+	CodeKeyNotFound           Code = "KEY_NOT_FOUND"
+	CodeTemplateNotFound      Code = "TEMPLATE_NOT_FOUND"
+	CodeWorkspaceNotFound     Code = "WORKSPACE_NOT_FOUND"
+	CodeChangeRequestNotFound Code = "CHANGE_REQUEST_NOT_FOUND"
+	CodeConflict              Code = "CONFLICT"
+	CodeNameTaken             Code = "NAME_TAKEN"
+	CodeKeyConflict           Code = "KEY_CONFLICT"
+	CodeKeyFinal              Code = "KEY_FINAL"
+	CodeInUse                 Code = "IN_USE"
+	CodeVersionConflict       Code = "VERSION_CONFLICT"
+	CodeInvalidState          Code = "INVALID_STATE"
+	CodeUnderReview           Code = "UNDER_REVIEW"
+	CodeRequestInProgress     Code = "REQUEST_IN_PROGRESS"
+	CodeCursorExpired         Code = "CURSOR_EXPIRED"
+	CodeInterpolationFailed   Code = "INTERPOLATION_FAILED"
+	CodeInvalidDocument       Code = "INVALID_DOCUMENT"
+	CodeIdempotencyKeyReused  Code = "IDEMPOTENCY_KEY_REUSED"
+	CodePayloadTooLarge       Code = "PAYLOAD_TOO_LARGE"
+	CodeTooManyAttempts       Code = "TOO_MANY_ATTEMPTS"
+	CodeQuotaExceeded         Code = "QUOTA_EXCEEDED"
+	CodeRateLimited           Code = "RATE_LIMITED"
+	CodeEncryptionUnavailable Code = "ENCRYPTION_UNAVAILABLE"
+	CodeNotImplemented        Code = "NOT_IMPLEMENTED"
+	CodeDatabaseUnavailable   Code = "DATABASE_UNAVAILABLE"
+	CodeTimeout               Code = "TIMEOUT"
+	CodeInternal              Code = "INTERNAL_ERROR"
+)
+
+// Details is an RFC 7807 problem details object. Extensions are further
+// members specific to the problem, such as the ID of a conflicting resource.
+type Details struct {
+	Type       string
+	Title      string
+	Status     int
+	Code       Code
+	Detail     string
+	Instance   string
+	Extensions map[string]interface{}
+}
+
+// MarshalJSON writes the extensions as members next to the standard ones
+func (d Details) MarshalJSON() ([]byte, error) {
+	members := make(map[string]interface{}, len(d.Extensions)+6)
+	for name, value := range d.Extensions {
+		members[name] = value
+	}
+	members["type"] = d.Type
+	members["title"] = d.Title
+	members["status"] = d.Status
+	members["code"] = d.Code
+	members["detail"] = d.Detail
+	members["instance"] = d.Instance
+	return json.Marshal(members)
+}
+
+// TypeURI returns the type of the problems of an HTTP status, relative to the
+// API, e.g. /problems/not-found
+func TypeURI(status int) string {
+	return "/problems/" + strings.ReplaceAll(strings.ToLower(http.StatusText(status)), " ", "-")
+}
+
+// New describes a problem with a request. The extensions are merged into one
+// set of members.
+func New(c *gin.Context, status int, code Code, detail string, extensions ...gin.H) Details {
+	details := Details{
+		Type:     TypeURI(status),
+		Title:    http.StatusText(status),
+		Status:   status,
+		Code:     code,
+		Detail:   detail,
+		Instance: c.Request.URL.Path,
+	}
+	for _, members := range extensions {
+		if details.Extensions == nil {
+			details.Extensions = make(map[string]interface{})
+		}
+		for name, value := range members {
+			details.Extensions[name] = value
+		}
+	}
+	return details
+}
+
+// Respond writes a problem details response
+func Respond(c *gin.Context, status int, code Code, detail string, extensions ...gin.H) {
+	c.Header("Content-Type", ContentType)
+	c.JSON(status, New(c, status, code, detail, extensions...))
+}
+
+// Abort writes a problem details response and stops the remaining handlers
+func Abort(c *gin.Context, status int, code Code, detail string, extensions ...gin.H) {
+	Respond(c, status, code, detail, extensions...)
+	c.Abort()
+}
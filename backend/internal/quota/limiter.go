@@ -0,0 +1,237 @@
+package quota
+
+import (
+	"config-manager/internal/database"
+	"config-manager/internal/models"
+	"config-manager/internal/problem"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// quotasTTL is how long quotas are cached before they are read again
+	quotasTTL = 30 * time.Second
+
+	// rootsTTL is how long the organization of a node or property is cached.
+	// Moving a node to another organization takes effect once it expires.
+	rootsTTL = time.Minute
+
+	// maxCachedRoots bounds the cache of organizations, which is cleared when full
+	maxCachedRoots = 100000
+)
+
+// Limiter caps the requests per minute to each organization, the subtree of a
+// root node. Requests are attributed by their :nodeId or :propertyId route
+// parameter; others, such as searches across the tree, are not counted.
+// Requests are counted in fixed one minute windows by each server, so with
+// several replicas an organization gets the limit from each.
+type Limiter struct {
+	repo database.ConfigRepository
+
+	quotasMu       sync.Mutex
+	quotas         map[int64]int // requests per minute by root
+	defaultLimit   int
+	quotasLoadedAt time.Time
+
+	rootsMu sync.Mutex
+	roots   map[string]cachedRoot
+
+	windowsMu sync.Mutex
+	windows   map[int64]*window
+}
+
+type cachedRoot struct {
+	rootID  int64
+	expires time.Time
+}
+
+// window counts the requests to an organization in the minute from start
+type window struct {
+	start time.Time
+	count int
+}
+
+// NewLimiter creates a limiter enforcing the requests per minute of the quotas in repo
+func NewLimiter(repo database.ConfigRepository) *Limiter {
+	return &Limiter{
+		repo:    repo,
+		roots:   make(map[string]cachedRoot),
+		windows: make(map[int64]*window),
+	}
+}
+
+// Middleware rejects requests over the limit of their organization with 429
+func (l *Limiter) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+		if !l.hasLimits(ctx) {
+			c.Next()
+			return
+		}
+
+		rootID, ok := l.organization(c)
+		if !ok {
+			c.Next()
+			return
+		}
+
+		limit := l.limit(rootID)
+		if limit == 0 {
+			c.Next()
+			return
+		}
+
+		allowed, retryAfter := l.take(rootID, limit, time.Now())
+		if !allowed {
+			c.Header("Retry-After", strconv.Itoa(int(retryAfter/time.Second)+1))
+			problem.Abort(c, http.StatusTooManyRequests, problem.CodeRateLimited,
+				fmt.Sprintf("The organization exceeded its quota of %d requests per minute", limit), gin.H{
+					"root_node_id": rootID,
+				})
+			return
+		}
+		c.Next()
+	}
+}
+
+// Used returns the requests this server counted for an organization in the current minute
+func (l *Limiter) Used(rootID int64) int {
+	l.windowsMu.Lock()
+	defer l.windowsMu.Unlock()
+
+	w, ok := l.windows[rootID]
+	if !ok || time.Since(w.start) >= time.Minute {
+		return 0
+	}
+	return w.count
+}
+
+// take counts a request to an organization, returning false and when the
+// window ends if it is over limit
+func (l *Limiter) take(rootID int64, limit int, now time.Time) (bool, time.Duration) {
+	l.windowsMu.Lock()
+	defer l.windowsMu.Unlock()
+
+	w, ok := l.windows[rootID]
+	if !ok || now.Sub(w.start) >= time.Minute {
+		w = &window{start: now.Truncate(time.Minute)}
+		l.windows[rootID] = w
+	}
+	if w.count >= limit {
+		return false, w.start.Add(time.Minute).Sub(now)
+	}
+	w.count++
+	return true, 0
+}
+
+// hasLimits reports whether any quota limits requests, reloading the quotas
+// when they are stale. Without quota support, nothing is limited.
+func (l *Limiter) hasLimits(ctx context.Context) bool {
+	l.quotasMu.Lock()
+	defer l.quotasMu.Unlock()
+
+	if time.Since(l.quotasLoadedAt) > quotasTTL {
+		l.quotasLoadedAt = time.Now()
+		quotas, err := l.repo.GetQuotas(ctx)
+		switch {
+		case errors.Is(err, database.ErrNotSupported):
+			l.quotas, l.defaultLimit = nil, 0
+		case err != nil:
+			// Keep enforcing the quotas last read
+			log.Printf("Failed to load organization quotas: %v", err)
+		default:
+			l.loadQuotas(quotas)
+		}
+	}
+	return l.defaultLimit > 0 || len(l.quotas) > 0
+}
+
+func (l *Limiter) loadQuotas(quotas []models.OrganizationQuota) {
+	l.quotas, l.defaultLimit = make(map[int64]int), 0
+	for _, quota := range quotas {
+		if quota.RequestsPerMinute == nil {
+			continue
+		}
+		if quota.RootNodeID == nil {
+			l.defaultLimit = *quota.RequestsPerMinute
+		} else {
+			l.quotas[*quota.RootNodeID] = *quota.RequestsPerMinute
+		}
+	}
+}
+
+// limit returns the requests per minute allowed to an organization, 0 for no limit
+func (l *Limiter) limit(rootID int64) int {
+	l.quotasMu.Lock()
+	defer l.quotasMu.Unlock()
+
+	if limit, ok := l.quotas[rootID]; ok {
+		return limit
+	}
+	return l.defaultLimit
+}
+
+// organization returns the root of the node or property a request is about
+func (l *Limiter) organization(c *gin.Context) (int64, bool) {
+	var cacheKey string
+	var lookup func(ctx context.Context) (*int64, error)
+	if param := c.Param("nodeId"); param != "" {
+		nodeID, err := strconv.ParseInt(param, 10, 64)
+		if err != nil {
+			return 0, false
+		}
+		cacheKey = "node:" + param
+		lookup = func(ctx context.Context) (*int64, error) {
+			return l.repo.GetRootNodeID(ctx, nodeID)
+		}
+	} else if param := c.Param("propertyId"); param != "" {
+		propertyID, err := strconv.ParseInt(param, 10, 64)
+		if err != nil {
+			return 0, false
+		}
+		cacheKey = "property:" + param
+		lookup = func(ctx context.Context) (*int64, error) {
+			nodeID, err := l.repo.GetPropertyNodeID(ctx, propertyID)
+			if nodeID == nil || err != nil {
+				return nil, err
+			}
+			return l.repo.GetRootNodeID(ctx, *nodeID)
+		}
+	} else {
+		return 0, false
+	}
+
+	now := time.Now()
+	l.rootsMu.Lock()
+	cached, ok := l.roots[cacheKey]
+	l.rootsMu.Unlock()
+	if ok && now.Before(cached.expires) {
+		return cached.rootID, true
+	}
+
+	// Requests for nodes or properties that cannot be found are left to the handler
+	rootID, err := lookup(c.Request.Context())
+	if err != nil {
+		log.Printf("Failed to look up the organization of %s: %v", cacheKey, err)
+		return 0, false
+	}
+	if rootID == nil {
+		return 0, false
+	}
+
+	l.rootsMu.Lock()
+	if len(l.roots) >= maxCachedRoots {
+		l.roots = make(map[string]cachedRoot)
+	}
+	l.roots[cacheKey] = cachedRoot{rootID: *rootID, expires: now.Add(rootsTTL)}
+	l.rootsMu.Unlock()
+	return *rootID, true
+}
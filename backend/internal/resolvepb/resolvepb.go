@@ -0,0 +1,155 @@
+// Package resolvepb encodes resolved configurations as the protocol buffer
+// messages of resolved_configuration.proto, so that clients refreshing their
+// configuration often can skip JSON parsing. The messages are small and only
+// ever encoded, so they are written field by field rather than generated.
+package resolvepb
+
+import (
+	"config-manager/internal/models"
+	"encoding/json"
+	"math"
+	"sort"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// ContentType is the media type of encoded resolved configurations
+const ContentType = "application/x-protobuf"
+
+// Marshal encodes a resolved configuration as a ResolvedConfiguration message
+func Marshal(resolved *models.ResolvedConfiguration) []byte {
+	var b []byte
+	b = appendInt(b, 1, resolved.NodeID)
+	b = appendString(b, 2, resolved.NodeName)
+	b = appendString(b, 3, resolved.Environment)
+	b = appendString(b, 4, resolved.Namespace)
+	b = appendMessage(b, 5, appendStruct(nil, resolved.Properties))
+	for _, node := range resolved.Path {
+		b = appendMessage(b, 6, appendNode(nil, node))
+	}
+	if resolved.AsOf != nil {
+		b = appendMessage(b, 7, appendTimestamp(nil, *resolved.AsOf))
+	}
+	b = appendString(b, 8, resolved.Checksum)
+	b = appendInt(b, 9, resolved.Version)
+	for _, warning := range resolved.Warnings {
+		b = appendMessage(b, 10, appendWarning(nil, warning))
+	}
+	return b
+}
+
+func appendNode(b []byte, node models.ConfigNode) []byte {
+	b = appendInt(b, 1, node.ID)
+	b = appendString(b, 2, node.Name)
+	b = appendString(b, 3, string(node.NodeType))
+	if node.ParentID != nil {
+		b = protowire.AppendTag(b, 4, protowire.VarintType)
+		b = protowire.AppendVarint(b, uint64(*node.ParentID))
+	}
+	for _, key := range sortedKeys(node.Labels) {
+		var entry []byte
+		entry = appendString(entry, 1, key)
+		entry = appendString(entry, 2, node.Labels[key])
+		b = appendMessage(b, 5, entry)
+	}
+	return b
+}
+
+func appendWarning(b []byte, warning models.ResolveWarning) []byte {
+	b = appendString(b, 1, warning.Code)
+	b = appendString(b, 2, warning.Key)
+	b = appendString(b, 3, warning.Message)
+	if warning.ReplacementKey != nil {
+		b = protowire.AppendTag(b, 4, protowire.BytesType)
+		b = protowire.AppendString(b, *warning.ReplacementKey)
+	}
+	if warning.SunsetAt != nil {
+		b = appendMessage(b, 5, appendTimestamp(nil, *warning.SunsetAt))
+	}
+	return b
+}
+
+// appendTimestamp appends the fields of a google.protobuf.Timestamp
+func appendTimestamp(b []byte, t time.Time) []byte {
+	b = appendInt(b, 1, t.Unix())
+	return appendInt(b, 2, int64(t.Nanosecond()))
+}
+
+// appendStruct appends the fields of a google.protobuf.Struct, in key order
+// so that equal configurations encode the same
+func appendStruct(b []byte, fields map[string]interface{}) []byte {
+	for _, key := range sortedKeys(fields) {
+		var entry []byte
+		entry = appendString(entry, 1, key)
+		entry = appendMessage(entry, 2, appendValue(nil, fields[key]))
+		b = appendMessage(b, 1, entry)
+	}
+	return b
+}
+
+// appendValue appends the fields of a google.protobuf.Value holding a value
+// decoded from JSON. Other values are converted through JSON first.
+func appendValue(b []byte, value interface{}) []byte {
+	switch v := value.(type) {
+	case nil:
+		b = protowire.AppendTag(b, 1, protowire.VarintType)
+		return protowire.AppendVarint(b, 0)
+	case float64:
+		b = protowire.AppendTag(b, 2, protowire.Fixed64Type)
+		return protowire.AppendFixed64(b, math.Float64bits(v))
+	case string:
+		b = protowire.AppendTag(b, 3, protowire.BytesType)
+		return protowire.AppendString(b, v)
+	case bool:
+		b = protowire.AppendTag(b, 4, protowire.VarintType)
+		return protowire.AppendVarint(b, protowire.EncodeBool(v))
+	case map[string]interface{}:
+		return appendMessage(b, 5, appendStruct(nil, v))
+	case []interface{}:
+		var list []byte
+		for _, item := range v {
+			list = appendMessage(list, 1, appendValue(nil, item))
+		}
+		return appendMessage(b, 6, list)
+	}
+
+	var decoded interface{}
+	if encoded, err := json.Marshal(value); err == nil && json.Unmarshal(encoded, &decoded) == nil {
+		return appendValue(b, decoded)
+	}
+	return appendValue(b, nil)
+}
+
+// appendInt appends an int64 or int32 field, unless it has the default value
+func appendInt(b []byte, num protowire.Number, v int64) []byte {
+	if v == 0 {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.VarintType)
+	return protowire.AppendVarint(b, uint64(v))
+}
+
+// appendString appends a string field, unless it has the default value
+func appendString(b []byte, num protowire.Number, v string) []byte {
+	if v == "" {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendString(b, v)
+}
+
+// appendMessage appends an embedded message field, even an empty one
+func appendMessage(b []byte, num protowire.Number, message []byte) []byte {
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendBytes(b, message)
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,114 @@
+package scheduler
+
+import (
+	"config-manager/internal/database"
+	"context"
+	"log"
+	"sync/atomic"
+	"time"
+)
+
+// Scheduler applies scheduled property changes once they are due. Their change
+// events are recorded in the outbox by the database.
+type Scheduler struct {
+	repo     *database.Repository
+	interval time.Duration
+
+	lag     atomic.Int64 // nanoseconds, see Lag
+	lastRun atomic.Int64 // unix nanoseconds, see LastRun
+}
+
+// New creates a scheduler that checks for due changes at least every interval,
+// and wakes up early when the next change is due sooner
+func New(repo *database.Repository, interval time.Duration) *Scheduler {
+	return &Scheduler{repo: repo, interval: interval}
+}
+
+// Run applies due changes until ctx is cancelled
+func (s *Scheduler) Run(ctx context.Context) {
+	for {
+		applied := s.applyDue(ctx)
+
+		wait, overdue := s.nextWait(ctx)
+		if overdue > applied {
+			applied = overdue
+		}
+		s.lag.Store(int64(applied))
+		s.lastRun.Store(time.Now().UnixNano())
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+	}
+}
+
+// Interval returns the longest time between two runs
+func (s *Scheduler) Interval() time.Duration {
+	return s.interval
+}
+
+// Lag returns how late the last run was: how long past their time it applied
+// changes, or how long a change it left pending is overdue
+func (s *Scheduler) Lag() time.Duration {
+	return time.Duration(s.lag.Load())
+}
+
+// LastRun returns when the last run ended, zero before the first one
+func (s *Scheduler) LastRun() time.Time {
+	nanos := s.lastRun.Load()
+	if nanos == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, nanos)
+}
+
+// applyDue applies the changes due now, returning how late the latest of them
+// was applied
+func (s *Scheduler) applyDue(ctx context.Context) time.Duration {
+	var lag time.Duration
+	for ctx.Err() == nil {
+		change, err := s.repo.ApplyDueScheduledChange(ctx, time.Now())
+		if change == nil {
+			if err != nil {
+				log.Printf("Failed to apply scheduled changes: %v", err)
+			}
+			return lag
+		}
+		if late := time.Since(change.EffectiveAt); late > lag {
+			lag = late
+		}
+		if err != nil {
+			log.Printf("Scheduled change %d to %s on node %d failed: %v", change.ID, change.Key, change.NodeID, err)
+			continue
+		}
+
+		log.Printf("Applied scheduled change %d to %s on node %d", change.ID, change.Key, change.NodeID)
+	}
+	return lag
+}
+
+// nextWait returns how long to sleep until the next change is due, capped at
+// the interval, and how long it is overdue if it already is
+func (s *Scheduler) nextWait(ctx context.Context) (time.Duration, time.Duration) {
+	next, err := s.repo.NextScheduledChangeAt(ctx)
+	if err != nil {
+		log.Printf("Failed to look up the next scheduled change: %v", err)
+		return s.interval, 0
+	}
+	if next == nil {
+		return s.interval, 0
+	}
+
+	wait := time.Until(*next)
+	if wait < 0 {
+		return 0, -wait
+	}
+	if wait > s.interval {
+		return s.interval, 0
+	}
+	return wait, 0
+}
@@ -0,0 +1,199 @@
+package siem
+
+import (
+	"config-manager/internal/database"
+	"config-manager/internal/events"
+	"config-manager/internal/models"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+)
+
+// batchSize is the number of records sent, and of audit entries read, at once
+const batchSize = 100
+
+// Delivery is retried with exponential backoff between these bounds
+const (
+	minBackoff = time.Second
+	maxBackoff = time.Minute
+)
+
+// Kinds of records
+const (
+	KindAudit  = "audit"  // An entry of the audit log
+	KindChange = "change" // A change event of the configuration tree
+)
+
+var ErrBufferFull = errors.New("the SIEM export buffer is full")
+
+// Record is an entry sent to the SIEM
+type Record struct {
+	Kind       string
+	OccurredAt time.Time
+	Data       interface{} // models.AuditEntry or events.Event, sent JSON encoded
+}
+
+// sink delivers records to a SIEM. send returns nil only once every record
+// was accepted.
+type sink interface {
+	send(ctx context.Context, records []Record) error
+}
+
+// Config describes the SIEM records are sent to
+type Config struct {
+	URL        string // https:// for a Splunk HTTP Event Collector, udp://, tcp:// or tcp+tls:// for syslog
+	Token      string // HEC token
+	BufferSize int    // Records held while the SIEM is unreachable, at least batchSize
+	Interval   time.Duration
+}
+
+// Exporter streams the audit log and the change events of the tree to a SIEM.
+// Audit entries are read from the database, change events are passed by the
+// outbox dispatcher, as the Exporter is an events.Publisher. Both are held in
+// a buffer until the SIEM accepts them, and delivery is retried with backoff.
+// When the buffer is full, audit entries stay in the database and change
+// events in the outbox until there is room again, so nothing is dropped.
+type Exporter struct {
+	repo     *database.Repository
+	sink     sink
+	interval time.Duration
+	size     int
+
+	mu      sync.Mutex
+	pending []Record
+	wake    chan struct{}
+}
+
+// New creates an exporter for the SIEM at cfg.URL
+func New(repo *database.Repository, cfg Config) (*Exporter, error) {
+	target, err := url.Parse(cfg.URL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid SIEM URL: %w", err)
+	}
+
+	host, _ := os.Hostname()
+	var s sink
+	switch target.Scheme {
+	case "http", "https":
+		if cfg.Token == "" {
+			return nil, errors.New("a HEC token is required")
+		}
+		s = newHECSink(cfg.URL, cfg.Token, host)
+	case "udp", "tcp", "tcp+tls":
+		if target.Host == "" {
+			return nil, errors.New("the syslog URL needs a host and port")
+		}
+		s = newSyslogSink(target.Scheme, target.Host, host)
+	default:
+		return nil, fmt.Errorf("unsupported SIEM URL scheme %q", target.Scheme)
+	}
+
+	return &Exporter{repo: repo, sink: s, interval: cfg.Interval, size: cfg.BufferSize, wake: make(chan struct{}, 1)}, nil
+}
+
+func (e *Exporter) Publish(ctx context.Context, event events.Event) error {
+	return e.PublishBatch(ctx, []events.Event{event})
+}
+
+// PublishBatch buffers the events, or fails with ErrBufferFull without
+// buffering any of them
+func (e *Exporter) PublishBatch(ctx context.Context, batch []events.Event) error {
+	records := make([]Record, len(batch))
+	for i, event := range batch {
+		records[i] = Record{Kind: KindChange, OccurredAt: event.OccurredAt, Data: event}
+	}
+	return e.enqueue(records)
+}
+
+// Run exports the audit log and delivers the buffered records until ctx is
+// cancelled
+func (e *Exporter) Run(ctx context.Context) {
+	ticker := time.NewTicker(e.interval)
+	defer ticker.Stop()
+
+	var backoff time.Duration
+	for {
+		e.collectAuditLog(ctx)
+
+		// New records are sent right away, unless delivery is backing off
+		wait, wake := (<-chan time.Time)(ticker.C), e.wake
+		if err := e.flush(ctx); err != nil && ctx.Err() == nil {
+			backoff = min(max(2*backoff, minBackoff), maxBackoff)
+			log.Printf("Failed to export to the SIEM, retrying in %s: %v", backoff, err)
+			wait, wake = time.After(backoff), nil
+		} else {
+			backoff = 0
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-wait:
+		case <-wake:
+		}
+	}
+}
+
+// collectAuditLog buffers the audit entries that were not exported yet
+func (e *Exporter) collectAuditLog(ctx context.Context) {
+	for ctx.Err() == nil {
+		exported, err := e.repo.DispatchAuditLog(ctx, batchSize, func(entries []models.AuditEntry) error {
+			records := make([]Record, len(entries))
+			for i, entry := range entries {
+				records[i] = Record{Kind: KindAudit, OccurredAt: entry.OccurredAt, Data: entry}
+			}
+			return e.enqueue(records)
+		})
+		if err != nil {
+			if !errors.Is(err, ErrBufferFull) {
+				log.Printf("Failed to read the audit log for the SIEM: %v", err)
+			}
+			return
+		}
+		if exported < batchSize {
+			return
+		}
+	}
+}
+
+// flush sends the buffered records in batches until none are left, or sending
+// fails and the remaining records are kept
+func (e *Exporter) flush(ctx context.Context) error {
+	for {
+		e.mu.Lock()
+		batch := e.pending[:min(len(e.pending), batchSize)]
+		e.mu.Unlock()
+		if len(batch) == 0 {
+			return nil
+		}
+
+		if err := e.sink.send(ctx, batch); err != nil {
+			return err
+		}
+
+		e.mu.Lock()
+		e.pending = e.pending[len(batch):]
+		e.mu.Unlock()
+	}
+}
+
+func (e *Exporter) enqueue(records []Record) error {
+	e.mu.Lock()
+	if len(e.pending)+len(records) > e.size {
+		e.mu.Unlock()
+		return ErrBufferFull
+	}
+	e.pending = append(e.pending, records...)
+	e.mu.Unlock()
+
+	select {
+	case e.wake <- struct{}{}:
+	default:
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package siem
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// source is the source of the records in the SIEM
+const source = "config-manager"
+
+// hecEvent is the envelope of an event sent to a Splunk HTTP Event Collector
+type hecEvent struct {
+	Time       float64     `json:"time"` // Seconds since the epoch
+	Host       string      `json:"host,omitempty"`
+	Source     string      `json:"source"`
+	Sourcetype string      `json:"sourcetype"`
+	Event      interface{} `json:"event"`
+}
+
+// hecSink posts records to a Splunk HTTP Event Collector, a batch per request.
+// The sourcetype is config-manager:audit or config-manager:change.
+type hecSink struct {
+	url    string
+	token  string
+	host   string
+	client *http.Client
+}
+
+func newHECSink(url, token, host string) *hecSink {
+	return &hecSink{url: url, token: token, host: host, client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+func (s *hecSink) send(ctx context.Context, records []Record) error {
+	var body bytes.Buffer
+	encoder := json.NewEncoder(&body)
+	for _, record := range records {
+		err := encoder.Encode(hecEvent{
+			Time:       float64(record.OccurredAt.UnixMilli()) / 1000,
+			Host:       s.host,
+			Source:     source,
+			Sourcetype: source + ":" + record.Kind,
+			Event:      record.Data,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, &body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Splunk "+s.token)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("HEC responded %s: %s", resp.Status, bytes.TrimSpace(detail))
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package siem
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net"
+	"time"
+)
+
+// syslogPriority is the log audit facility (13) at informational severity (6)
+const syslogPriority = 13*8 + 6
+
+// syslogTimeout bounds dialing and writing a batch
+const syslogTimeout = 10 * time.Second
+
+// syslogSink sends records as RFC 5424 messages whose MSGID is the kind of the
+// record and whose message is the JSON encoded record. Over TCP, messages are
+// framed by octet counting (RFC 6587); over UDP each is a datagram. The
+// connection is kept open and dialed again after a failure.
+type syslogSink struct {
+	network string // udp, tcp or tcp+tls
+	address string
+	host    string
+	conn    net.Conn
+}
+
+func newSyslogSink(network, address, host string) *syslogSink {
+	if host == "" {
+		host = "-"
+	}
+	return &syslogSink{network: network, address: address, host: host}
+}
+
+func (s *syslogSink) send(ctx context.Context, records []Record) error {
+	if s.conn == nil {
+		conn, err := s.dial(ctx)
+		if err != nil {
+			return err
+		}
+		s.conn = conn
+	}
+
+	deadline := time.Now().Add(syslogTimeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	if err := s.conn.SetWriteDeadline(deadline); err != nil {
+		return s.fail(err)
+	}
+
+	for _, record := range records {
+		payload, err := json.Marshal(record.Data)
+		if err != nil {
+			return err
+		}
+
+		message := fmt.Sprintf("<%d>1 %s %s %s - %s - %s", syslogPriority,
+			record.OccurredAt.UTC().Format(time.RFC3339Nano), s.host, source, record.Kind, payload)
+		if s.network != "udp" {
+			message = fmt.Sprintf("%d %s", len(message), message)
+		}
+		if _, err := s.conn.Write([]byte(message)); err != nil {
+			return s.fail(err)
+		}
+	}
+	return nil
+}
+
+func (s *syslogSink) dial(ctx context.Context) (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: syslogTimeout}
+	if s.network == "tcp+tls" {
+		tlsDialer := &tls.Dialer{NetDialer: dialer, Config: &tls.Config{MinVersion: tls.VersionTLS12}}
+		return tlsDialer.DialContext(ctx, "tcp", s.address)
+	}
+	return dialer.DialContext(ctx, s.network, s.address)
+}
+
+// fail closes the connection after a write error, as part of a message may
+// have been sent
+func (s *syslogSink) fail(err error) error {
+	s.conn.Close()
+	s.conn = nil
+	return err
+}
@@ -0,0 +1,135 @@
+// Package signing signs resolved configurations with a server key, as detached
+// JSON Web Signatures (RFC 7515, appendix F), so that agents can verify that a
+// payload was not altered by intermediaries or a compromised cache. The public
+// key is published as a JSON Web Key for agents to verify with.
+package signing
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+)
+
+var b64 = base64.RawURLEncoding
+
+// JWK is a public key in JSON Web Key form (RFC 7517)
+type JWK struct {
+	Kty string `json:"kty"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y,omitempty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+}
+
+// Signer signs payloads with an Ed25519 (EdDSA) or ECDSA P-256 (ES256) key
+type Signer struct {
+	key    crypto.Signer
+	header string // Encoded protected header, the same for every signature
+	public JWK
+}
+
+// Load reads a PEM-encoded private key from path, see New
+func Load(path, keyID string) (*Signer, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return New(data, keyID)
+}
+
+// New creates a signer from a PEM-encoded Ed25519 or ECDSA P-256 private key,
+// in PKCS #8 or, for ECDSA, SEC 1 form. Signatures name keyID, or the RFC 7638
+// thumbprint of the public key when it is empty.
+func New(keyPEM []byte, keyID string) (*Signer, error) {
+	block, _ := pem.Decode(keyPEM)
+	if block == nil {
+		return nil, errors.New("no PEM-encoded private key found")
+	}
+
+	var key interface{}
+	var err error
+	switch block.Type {
+	case "EC PRIVATE KEY":
+		key, err = x509.ParseECPrivateKey(block.Bytes)
+	default:
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid private key: %w", err)
+	}
+
+	s := &Signer{}
+	switch k := key.(type) {
+	case ed25519.PrivateKey:
+		s.key = k
+		s.public = JWK{Kty: "OKP", Crv: "Ed25519", X: b64.EncodeToString(k.Public().(ed25519.PublicKey)), Alg: "EdDSA"}
+	case *ecdsa.PrivateKey:
+		if k.Curve != elliptic.P256() {
+			return nil, errors.New("ECDSA signing keys must use the P-256 curve")
+		}
+		s.key = k
+		s.public = JWK{Kty: "EC", Crv: "P-256", X: b64.EncodeToString(k.X.FillBytes(make([]byte, 32))), Y: b64.EncodeToString(k.Y.FillBytes(make([]byte, 32))), Alg: "ES256"}
+	default:
+		return nil, fmt.Errorf("signing keys must be Ed25519 or ECDSA P-256, got %T", key)
+	}
+	s.public.Use = "sig"
+	s.public.Kid = keyID
+	if keyID == "" {
+		s.public.Kid = thumbprint(s.public)
+	}
+
+	header, _ := json.Marshal(map[string]string{"alg": s.public.Alg, "kid": s.public.Kid})
+	s.header = b64.EncodeToString(header)
+	return s, nil
+}
+
+// thumbprint returns the RFC 7638 thumbprint of a public key, hashing its
+// required members in lexicographic order
+func thumbprint(key JWK) string {
+	var members string
+	if key.Kty == "EC" {
+		members = fmt.Sprintf(`{"crv":%q,"kty":%q,"x":%q,"y":%q}`, key.Crv, key.Kty, key.X, key.Y)
+	} else {
+		members = fmt.Sprintf(`{"crv":%q,"kty":%q,"x":%q}`, key.Crv, key.Kty, key.X)
+	}
+	sum := sha256.Sum256([]byte(members))
+	return b64.EncodeToString(sum[:])
+}
+
+// PublicKey returns the key signatures are verified with
+func (s *Signer) PublicKey() JWK {
+	return s.public
+}
+
+// Sign returns the detached compact serialization of a signature over
+// payload, "header..signature", as the payload is sent on its own
+func (s *Signer) Sign(payload []byte) (string, error) {
+	input := s.header + "." + b64.EncodeToString(payload)
+
+	var signature []byte
+	switch key := s.key.(type) {
+	case ed25519.PrivateKey:
+		signature = ed25519.Sign(key, []byte(input))
+	case *ecdsa.PrivateKey:
+		// JWS signatures are R and S as fixed-size big-endian integers, not ASN.1
+		digest := sha256.Sum256([]byte(input))
+		r, sig, err := ecdsa.Sign(rand.Reader, key, digest[:])
+		if err != nil {
+			return "", err
+		}
+		signature = append(r.FillBytes(make([]byte, 32)), sig.FillBytes(make([]byte, 32))...)
+	}
+	return s.header + ".." + b64.EncodeToString(signature), nil
+}
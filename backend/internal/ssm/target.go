@@ -0,0 +1,209 @@
+package ssm
+
+import (
+	"config-manager/internal/syncer"
+	"context"
+	"log"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	"github.com/aws/aws-sdk-go-v2/service/ssm/types"
+)
+
+// parameterKeyPattern matches the property keys usable as the last segment of a parameter name
+var parameterKeyPattern = regexp.MustCompile(`^[a-zA-Z0-9_.-]+$`)
+
+// deleteBatchSize is the most parameters DeleteParameters accepts at once
+const deleteBatchSize = 10
+
+// Target mirrors the configuration of each bound node into Parameter Store,
+// one parameter per property named <prefix>/<destination>/<key>. Encrypted
+// properties are stored as SecureString parameters. Parameters under that path
+// that the node no longer resolves are deleted.
+type Target struct {
+	client   *ssm.Client
+	prefix   string
+	kmsKeyID string
+}
+
+// NewTarget creates a target using the default AWS credential chain
+// (environment, shared config, web identity or instance role). SecureString
+// parameters are encrypted with kmsKeyID, or the account's default SSM key.
+func NewTarget(ctx context.Context, region, prefix, kmsKeyID string) (*Target, error) {
+	var opts []func(*config.LoadOptions) error
+	if region != "" {
+		opts = append(opts, config.WithRegion(region))
+	}
+	cfg, err := config.LoadDefaultConfig(ctx, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Target{
+		client:   ssm.NewFromConfig(cfg),
+		prefix:   "/" + strings.Trim(prefix, "/"),
+		kmsKeyID: kmsKeyID,
+	}, nil
+}
+
+func (t *Target) Name() string {
+	return "SSM Parameter Store"
+}
+
+// parameter is the desired state of a parameter
+type parameter struct {
+	value  string
+	secure bool
+}
+
+func (t *Target) path(config syncer.NodeConfig) string {
+	return strings.TrimSuffix(t.prefix, "/") + "/" + strings.Trim(config.Destination, "/")
+}
+
+// desired renders the parameters of a configuration. Keys that are not valid
+// in parameter names, and empty values, which Parameter Store rejects, are skipped.
+func (t *Target) desired(config syncer.NodeConfig) (map[string]parameter, error) {
+	base := t.path(config)
+	parameters := make(map[string]parameter, len(config.Properties)+len(config.Secrets))
+	add := func(values map[string]interface{}, secure bool) error {
+		for key, value := range values {
+			if !parameterKeyPattern.MatchString(key) {
+				log.Printf("Skipping property %q of node %d: not a valid parameter name", key, config.NodeID)
+				continue
+			}
+			s, err := syncer.StringValue(value)
+			if err != nil {
+				return err
+			}
+			if s == "" {
+				log.Printf("Skipping property %q of node %d: Parameter Store does not accept empty values", key, config.NodeID)
+				continue
+			}
+			parameters[base+"/"+key] = parameter{value: s, secure: secure}
+		}
+		return nil
+	}
+	if err := add(config.Properties, false); err != nil {
+		return nil, err
+	}
+	if err := add(config.Secrets, true); err != nil {
+		return nil, err
+	}
+	return parameters, nil
+}
+
+// existing returns the parameters currently stored under path
+func (t *Target) existing(ctx context.Context, path string) (map[string]parameter, error) {
+	parameters := make(map[string]parameter)
+	paginator := ssm.NewGetParametersByPathPaginator(t.client, &ssm.GetParametersByPathInput{
+		Path:           aws.String(path),
+		Recursive:      aws.Bool(true),
+		WithDecryption: aws.Bool(true),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range page.Parameters {
+			parameters[aws.ToString(p.Name)] = parameter{
+				value:  aws.ToString(p.Value),
+				secure: p.Type == types.ParameterTypeSecureString,
+			}
+		}
+	}
+	return parameters, nil
+}
+
+// Plan lists the parameters a sync would create, update or delete
+func (t *Target) Plan(ctx context.Context, config syncer.NodeConfig) ([]syncer.Change, error) {
+	desired, err := t.desired(config)
+	if err != nil {
+		return nil, err
+	}
+	existing, err := t.existing(ctx, t.path(config))
+	if err != nil {
+		return nil, err
+	}
+	return plan(desired, existing), nil
+}
+
+func plan(desired, existing map[string]parameter) []syncer.Change {
+	var changes []syncer.Change
+	for name, want := range desired {
+		have, ok := existing[name]
+		switch {
+		case !ok:
+			changes = append(changes, syncer.Change{Action: "create", Name: name, Secret: want.secure})
+		case have != want:
+			changes = append(changes, syncer.Change{Action: "update", Name: name, Secret: want.secure})
+		}
+	}
+	for name, have := range existing {
+		if _, ok := desired[name]; !ok {
+			changes = append(changes, syncer.Change{Action: "delete", Name: name, Secret: have.secure})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Name < changes[j].Name })
+	return changes
+}
+
+func (t *Target) Sync(ctx context.Context, config syncer.NodeConfig) error {
+	desired, err := t.desired(config)
+	if err != nil {
+		return err
+	}
+	existing, err := t.existing(ctx, t.path(config))
+	if err != nil {
+		return err
+	}
+
+	var deletes []string
+	for _, change := range plan(desired, existing) {
+		if change.Action == "delete" {
+			deletes = append(deletes, change.Name)
+			continue
+		}
+
+		// The type of a parameter cannot be changed by overwriting it
+		if have, ok := existing[change.Name]; ok && have.secure != desired[change.Name].secure {
+			if _, err := t.client.DeleteParameter(ctx, &ssm.DeleteParameterInput{Name: aws.String(change.Name)}); err != nil {
+				return err
+			}
+		}
+
+		want := desired[change.Name]
+		input := &ssm.PutParameterInput{
+			Name:      aws.String(change.Name),
+			Value:     aws.String(want.value),
+			Type:      types.ParameterTypeString,
+			Overwrite: aws.Bool(true),
+			// Values over 4 KB are stored as advanced parameters automatically
+			Tier: types.ParameterTierIntelligentTiering,
+		}
+		if want.secure {
+			input.Type = types.ParameterTypeSecureString
+			if t.kmsKeyID != "" {
+				input.KeyId = aws.String(t.kmsKeyID)
+			}
+		}
+		if _, err := t.client.PutParameter(ctx, input); err != nil {
+			return err
+		}
+	}
+
+	for start := 0; start < len(deletes); start += deleteBatchSize {
+		end := start + deleteBatchSize
+		if end > len(deletes) {
+			end = len(deletes)
+		}
+		if _, err := t.client.DeleteParameters(ctx, &ssm.DeleteParametersInput{Names: deletes[start:end]}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
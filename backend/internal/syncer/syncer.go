@@ -0,0 +1,265 @@
+package syncer
+
+import (
+	"config-manager/internal/database"
+	"config-manager/internal/events"
+	"config-manager/internal/models"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Binding selects a node whose resolved configuration is mirrored into a
+// target, and where the target writes it
+type Binding struct {
+	NodeID      int64
+	Environment string
+	Destination string // Target specific, e.g. "namespace/name" for Kubernetes
+}
+
+// ParseBindings parses a comma-separated list of nodeID=destination entries,
+// where the node ID may carry an environment as nodeID@environment
+func ParseBindings(value string) ([]Binding, error) {
+	var bindings []Binding
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		node, destination, ok := strings.Cut(entry, "=")
+		if !ok || strings.TrimSpace(destination) == "" {
+			return nil, fmt.Errorf("invalid sync binding %q, expected nodeID=destination", entry)
+		}
+		node, environment, _ := strings.Cut(strings.TrimSpace(node), "@")
+		nodeID, err := strconv.ParseInt(node, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid node ID in sync binding %q", entry)
+		}
+
+		bindings = append(bindings, Binding{NodeID: nodeID, Environment: environment, Destination: strings.TrimSpace(destination)})
+	}
+	return bindings, nil
+}
+
+// NodeConfig is the resolved configuration of a bound node, with the values of
+// encrypted properties split out so targets can store them as secrets
+type NodeConfig struct {
+	Binding
+	NodeName   string
+	Properties map[string]interface{}
+	Secrets    map[string]interface{}
+	Checksum   string
+}
+
+// Target receives the configuration of bound nodes
+type Target interface {
+	// Name identifies the target in logs
+	Name() string
+	// Sync writes the configuration, replacing what was written for the same
+	// binding before
+	Sync(ctx context.Context, config NodeConfig) error
+}
+
+// Change is a write a target would make to converge on a configuration
+type Change struct {
+	Action string `json:"action"` // create, update or delete
+	Name   string `json:"name"`   // What is written, e.g. a parameter name
+	Secret bool   `json:"secret,omitempty"`
+}
+
+// Planner is implemented by targets that can report the changes a sync would
+// make without making them, for dry runs and drift reports
+type Planner interface {
+	Plan(ctx context.Context, config NodeConfig) ([]Change, error)
+}
+
+// Drift lists the changes needed to bring the target of a binding back in
+// sync, i.e. where it diverges from the resolved configuration
+type Drift struct {
+	NodeID      int64    `json:"node_id"`
+	Environment string   `json:"environment,omitempty"`
+	Destination string   `json:"destination"`
+	Changes     []Change `json:"changes"`
+	Error       string   `json:"error,omitempty"`
+}
+
+// Controller keeps a target in sync with the resolved configuration of the
+// bound nodes. It checks for changes every interval, and immediately when it is
+// published a change event; only bindings whose configuration changed since
+// they were last synced are written.
+type Controller struct {
+	repo     *database.Repository
+	target   Target
+	bindings []Binding
+	interval time.Duration
+	notify   chan struct{}
+	synced   map[int]string // Checksum last synced per binding index
+
+	// DryRun logs the changes a sync would make instead of making them. It
+	// requires the target to be a Planner.
+	DryRun bool
+}
+
+// New creates a controller for the given bindings
+func New(repo *database.Repository, target Target, bindings []Binding, interval time.Duration) *Controller {
+	return &Controller{
+		repo:     repo,
+		target:   target,
+		bindings: bindings,
+		interval: interval,
+		notify:   make(chan struct{}, 1),
+		synced:   make(map[int]string),
+	}
+}
+
+// Publish triggers a sync check, so the controller can be passed wherever
+// change events are published
+func (c *Controller) Publish(ctx context.Context, event events.Event) error {
+	select {
+	case c.notify <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// Run syncs the bound nodes until ctx is cancelled
+func (c *Controller) Run(ctx context.Context) {
+	log.Printf("Syncing %d nodes to %s every %s", len(c.bindings), c.target.Name(), c.interval)
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+
+	for {
+		c.syncAll(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-c.notify:
+		}
+	}
+}
+
+func (c *Controller) syncAll(ctx context.Context) {
+	for i, binding := range c.bindings {
+		if ctx.Err() != nil {
+			return
+		}
+
+		config, err := Resolve(ctx, c.repo, binding)
+		if err != nil {
+			log.Printf("Failed to resolve node %d for %s: %v", binding.NodeID, c.target.Name(), err)
+			continue
+		}
+		if c.synced[i] == config.Checksum {
+			continue
+		}
+
+		if planner, ok := c.target.(Planner); ok && c.DryRun {
+			changes, err := planner.Plan(ctx, *config)
+			if err != nil {
+				log.Printf("Failed to plan sync of node %d to %s %s: %v", binding.NodeID, c.target.Name(), binding.Destination, err)
+				continue
+			}
+			for _, change := range changes {
+				log.Printf("Dry run: would %s %s in %s for node %d", change.Action, change.Name, c.target.Name(), binding.NodeID)
+			}
+			c.synced[i] = config.Checksum
+			continue
+		}
+
+		if err := c.target.Sync(ctx, *config); err != nil {
+			log.Printf("Failed to sync node %d to %s %s: %v", binding.NodeID, c.target.Name(), binding.Destination, err)
+			continue
+		}
+		c.synced[i] = config.Checksum
+		log.Printf("Synced node %d to %s %s", binding.NodeID, c.target.Name(), binding.Destination)
+	}
+}
+
+// ErrDriftNotSupported is returned for drift reports of targets that cannot plan
+var ErrDriftNotSupported = errors.New("drift reports are not supported")
+
+// Drift plans a sync of every binding without making it. It fails if the
+// target cannot plan.
+func (c *Controller) Drift(ctx context.Context) ([]Drift, error) {
+	planner, ok := c.target.(Planner)
+	if !ok {
+		return nil, fmt.Errorf("%s sync: %w", c.target.Name(), ErrDriftNotSupported)
+	}
+
+	drifts := make([]Drift, 0, len(c.bindings))
+	for _, binding := range c.bindings {
+		drift := Drift{NodeID: binding.NodeID, Environment: binding.Environment, Destination: binding.Destination, Changes: []Change{}}
+		config, err := Resolve(ctx, c.repo, binding)
+		if err == nil {
+			var changes []Change
+			if changes, err = planner.Plan(ctx, *config); err == nil {
+				drift.Changes = append(drift.Changes, changes...)
+			}
+		}
+		if err != nil {
+			drift.Error = err.Error()
+		}
+		drifts = append(drifts, drift)
+	}
+	return drifts, nil
+}
+
+// Resolve resolves the configuration of a bound node and splits out the values
+// of encrypted properties
+func Resolve(ctx context.Context, repo *database.Repository, binding Binding) (*NodeConfig, error) {
+	resolved, err := repo.ResolveConfiguration(ctx, binding.NodeID, models.ResolveOptions{Environment: binding.Environment})
+	if err != nil {
+		return nil, err
+	}
+	effective, err := repo.GetEffectiveProperties(ctx, binding.NodeID, binding.Environment)
+	if err != nil {
+		return nil, err
+	}
+
+	config := &NodeConfig{
+		Binding:    binding,
+		NodeName:   resolved.NodeName,
+		Properties: make(map[string]interface{}),
+		Secrets:    make(map[string]interface{}),
+	}
+	for key, value := range resolved.Properties {
+		if prop, ok := effective[key]; ok && prop.Encrypted {
+			config.Secrets[key] = value
+		} else {
+			config.Properties[key] = value
+		}
+	}
+
+	// Map keys are encoded in sorted order, so equal configurations hash equally
+	payload, err := json.Marshal([]interface{}{config.NodeName, config.Properties, config.Secrets})
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(payload)
+	config.Checksum = hex.EncodeToString(sum[:])
+
+	return config, nil
+}
+
+// StringValue renders a resolved value for targets that store strings: strings
+// as-is, other values as JSON
+func StringValue(value interface{}) (string, error) {
+	if s, ok := value.(string); ok {
+		return s, nil
+	}
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+	return string(encoded), nil
+}